@@ -0,0 +1,159 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	v20170831 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20170831"
+	v20180331 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20180331"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	convertName             = "convert"
+	convertShortDescription = "Convert an api model to another API version"
+	convertLongDescription  = "Convert an api model to another API version, such as an api model of a former Azure Container Service API version to vlabs, and report the fields the target version cannot represent"
+)
+
+type convertCmd struct {
+	// user input
+	apiModelPath string
+	toVersion    string
+	outputPath   string
+	strict       bool
+
+	// derived
+	locale *gotext.Locale
+	out    io.Writer
+}
+
+func newConvertCmd() *cobra.Command {
+	cc := convertCmd{
+		out: os.Stdout,
+	}
+
+	command := &cobra.Command{
+		Use:     convertName,
+		Short:   convertShortDescription,
+		Long:    convertLongDescription,
+		Example: "  aks-engine convert -m old.json --to vlabs -o kubernetes.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cc.validate(cmd, args); err != nil {
+				return errors.Wrap(err, "validating convert command")
+			}
+			return cc.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&cc.apiModelPath, "api-model", "m", "", "path to the api model to convert (required)")
+	f.StringVar(&cc.toVersion, "to", "", "API version to convert the api model to, such as vlabs (required)")
+	f.StringVarP(&cc.outputPath, "output", "o", "", "path to write the converted api model to, standard output if absent")
+	f.BoolVar(&cc.strict, "strict", false, "fail rather than drop the fields the target version cannot represent")
+
+	return command
+}
+
+func (cc *convertCmd) validate(cmd *cobra.Command, args []string) error {
+	var err error
+
+	cc.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if cc.apiModelPath == "" {
+		if len(args) == 1 {
+			cc.apiModelPath = args[0]
+		} else if len(args) > 1 {
+			cmd.Usage()
+			return errors.New("too many arguments were provided to 'convert'")
+		} else {
+			cmd.Usage()
+			return errors.New("--api-model must be specified")
+		}
+	}
+
+	if _, err = os.Stat(cc.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", cc.apiModelPath)
+	}
+
+	if cc.toVersion == "" {
+		cmd.Usage()
+		return errors.New("--to must be specified")
+	}
+	if isAgentPoolOnlyAPIVersion(cc.toVersion) {
+		return errors.Errorf("api models cannot be converted to the agent pool only API version %s", cc.toVersion)
+	}
+
+	contents, err := ioutil.ReadFile(cc.apiModelPath)
+	if err != nil {
+		return errors.Wrapf(err, "reading the api model %s", cc.apiModelPath)
+	}
+	m := &api.TypeMeta{}
+	if err = json.Unmarshal(contents, m); err != nil {
+		return errors.Wrapf(err, "parsing the api model %s", cc.apiModelPath)
+	}
+	if isAgentPoolOnlyAPIVersion(m.APIVersion) {
+		return errors.Errorf("api models of the agent pool only API version %s cannot be converted", m.APIVersion)
+	}
+	return nil
+}
+
+// isAgentPoolOnlyAPIVersion reports whether version is one of the agent pool only API versions, whose hosted
+// master the other API versions cannot represent
+func isAgentPoolOnlyAPIVersion(version string) bool {
+	return version == v20170831.APIVersion || version == v20180331.APIVersion
+}
+
+func (cc *convertCmd) run() error {
+	contents, err := ioutil.ReadFile(cc.apiModelPath)
+	if err != nil {
+		return errors.Wrapf(err, "reading the api model %s", cc.apiModelPath)
+	}
+
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: cc.locale,
+		},
+	}
+	converted, lost, err := apiloader.ConvertAPIModel(contents, cc.toVersion)
+	if err != nil {
+		return errors.Wrapf(err, "converting the api model to %s", cc.toVersion)
+	}
+
+	for _, path := range lost {
+		log.Warnf("%s cannot be represented in API version %s and was dropped", path, cc.toVersion)
+	}
+	if len(lost) > 0 && cc.strict {
+		return errors.Errorf("API version %s cannot represent all the fields of the api model", cc.toVersion)
+	}
+
+	if cc.outputPath == "" {
+		_, err = fmt.Fprintln(cc.out, string(converted))
+		return err
+	}
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: cc.locale,
+		},
+	}
+	if err = f.SaveFile(filepath.Dir(cc.outputPath), filepath.Base(cc.outputPath), converted); err != nil {
+		return err
+	}
+	log.Infof("Converted the api model to API version %s with %d fields dropped", cc.toVersion, len(lost))
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+func TestNewConvertCmd(t *testing.T) {
+	command := newConvertCmd()
+	if command.Use != convertName || command.Short != convertShortDescription || command.Long != convertLongDescription {
+		t.Fatalf("convert command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, convertName, command.Short, convertShortDescription, command.Long, convertLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "to", "output", "strict"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("convert command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling convert with no arguments")
+	}
+}
+
+func TestConvertCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/v20160930/defaults.json"
+	cases := []struct {
+		name      string
+		c         *convertCmd
+		args      []string
+		expectErr bool
+	}{
+		{"valid", &convertCmd{apiModelPath: apiModelPath, toVersion: "vlabs"}, nil, false},
+		{"api model argument", &convertCmd{toVersion: "vlabs"}, []string{apiModelPath}, false},
+		{"too many arguments", &convertCmd{toVersion: "vlabs"}, []string{apiModelPath, apiModelPath}, true},
+		{"missing api model", &convertCmd{toVersion: "vlabs"}, nil, true},
+		{"api model not found", &convertCmd{apiModelPath: "missing.json", toVersion: "vlabs"}, nil, true},
+		{"missing version", &convertCmd{apiModelPath: apiModelPath}, nil, true},
+		{"to agent pool only version", &convertCmd{apiModelPath: apiModelPath, toVersion: "2018-03-31"}, nil, true},
+		{"agent pool only api model", &convertCmd{apiModelPath: "../pkg/engine/testdata/agentPoolOnly/v20180331/agents.json", toVersion: "vlabs"}, nil, true},
+	}
+	for _, c := range cases {
+		err := c.c.validate(&cobra.Command{}, c.args)
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the convert flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the convert flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestConvertCmdRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convert")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name       string
+		toVersion  string
+		outputPath string
+		strict     bool
+		expectErr  bool
+	}{
+		{name: "standard output", toVersion: "vlabs"},
+		{name: "output file", toVersion: "vlabs", outputPath: filepath.Join(dir, "vlabs", "kubernetes.json")},
+		{name: "fields dropped", toVersion: "2016-03-30", outputPath: filepath.Join(dir, "kubernetes.json")},
+		{name: "strict", toVersion: "2016-03-30", strict: true, expectErr: true},
+		{name: "unknown version", toVersion: "2019-01-01", expectErr: true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cc := &convertCmd{
+				apiModelPath: "../pkg/engine/testdata/v20160930/defaults.json",
+				toVersion:    c.toVersion,
+				outputPath:   c.outputPath,
+				strict:       c.strict,
+				out:          out,
+			}
+			err := cc.run()
+			if c.expectErr {
+				if err == nil {
+					t.Fatal("expected an error converting the api model")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error converting the api model: %s", err)
+			}
+
+			converted := out.Bytes()
+			if c.outputPath != "" {
+				if out.Len() != 0 {
+					t.Errorf("expected nothing to be written to the standard output, got %s", out.String())
+				}
+				if converted, err = ioutil.ReadFile(c.outputPath); err != nil {
+					t.Fatalf("unexpected error reading the converted api model: %s", err)
+				}
+			}
+			m := &api.TypeMeta{}
+			if err = json.Unmarshal(converted, m); err != nil {
+				t.Fatalf("unexpected error parsing the converted api model: %s", err)
+			}
+			if m.APIVersion != c.toVersion {
+				t.Errorf("expected the converted api model to be in API version %s, got %s", c.toVersion, m.APIVersion)
+			}
+		})
+	}
+}
@@ -7,27 +7,19 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"os"
-	"path"
 	"path/filepath"
-	"strconv"
-	"time"
 
 	"github.com/leonelquinteros/gotext"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"encoding/json"
-
+	"github.com/Azure/aks-engine/pkg/aksengine"
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
-	"github.com/Azure/aks-engine/pkg/engine"
 	"github.com/Azure/aks-engine/pkg/engine/transform"
 	"github.com/Azure/aks-engine/pkg/helpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
-	"github.com/Azure/azure-sdk-for-go/services/graphrbac/1.6/graphrbac"
-	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 )
 
@@ -39,6 +31,8 @@ const (
 
 type deployCmd struct {
 	authProvider
+	secretArgs
+
 	apimodelPath      string
 	dnsPrefix         string
 	autoSuffix        bool
@@ -46,17 +40,19 @@ type deployCmd struct {
 	forceOverwrite    bool
 	caCertificatePath string
 	caPrivateKeyPath  string
+	caChainPath       string
 	parametersOnly    bool
 	set               []string
+	overlays          []string
 
 	// derived
 	containerService *api.ContainerService
 	apiVersion       string
 	locale           *gotext.Locale
+	secretStore      *api.SecretStore
 
 	client        armhelpers.AKSEngineClient
 	resourceGroup string
-	random        *rand.Rand
 	location      string
 }
 
@@ -79,13 +75,6 @@ func newDeployCmd() *cobra.Command {
 			if err := dc.loadAPIModel(); err != nil {
 				return errors.Wrap(err, "loading API model")
 			}
-			if dc.apiVersion == "vlabs" {
-				if err := dc.validateAPIModelAsVLabs(); err != nil {
-					return errors.Wrap(err, "validating API model after populating values")
-				}
-			} else {
-				log.Warnf("API model validation is only available for \"apiVersion\": \"vlabs\", skipping validation...")
-			}
 			return dc.run()
 		},
 	}
@@ -97,12 +86,15 @@ func newDeployCmd() *cobra.Command {
 	f.StringVarP(&dc.outputDirectory, "output-directory", "o", "", "output directory (derived from FQDN if absent)")
 	f.StringVar(&dc.caCertificatePath, "ca-certificate-path", "", "path to the CA certificate to use for Kubernetes PKI assets")
 	f.StringVar(&dc.caPrivateKeyPath, "ca-private-key-path", "", "path to the CA private key to use for Kubernetes PKI assets")
+	f.StringVar(&dc.caChainPath, "ca-certificate-chain-path", "", "path to the certificates that chain an intermediate CA certificate to a root CA, the issuer of the CA certificate first")
 	f.StringVarP(&dc.resourceGroup, "resource-group", "g", "", "resource group to deploy to (will use the DNS prefix from the apimodel if not specified)")
 	f.StringVarP(&dc.location, "location", "l", "", "location to deploy to (required)")
 	f.BoolVarP(&dc.forceOverwrite, "force-overwrite", "f", false, "automatically overwrite existing files in the output directory")
 	f.StringArrayVar(&dc.set, "set", []string{}, "set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	f.StringArrayVar(&dc.overlays, "overlay", []string{}, "path to an api model overlay merged on top of the api model (can specify multiple, applied in order)")
 
 	addAuthFlags(dc.getAuthArgs(), f)
+	addSecretFlags(&dc.secretArgs, f)
 
 	return deployCmd
 }
@@ -130,12 +122,18 @@ func (dc *deployCmd) validateArgs(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	for _, overlay := range dc.overlays {
+		if _, err := os.Stat(overlay); os.IsNotExist(err) {
+			return errors.Errorf("specified api model overlay does not exist (%s)", overlay)
+		}
+	}
+
 	if dc.location == "" {
 		return errors.New("--location must be specified")
 	}
 	dc.location = helpers.NormalizeAzureRegion(dc.location)
 
-	return nil
+	return dc.validateSecretArgs()
 }
 
 func (dc *deployCmd) mergeAPIModel() error {
@@ -157,6 +155,16 @@ func (dc *deployCmd) mergeAPIModel() error {
 		dc.apimodelPath = f.Name()
 	}
 
+	// if --overlay flag has been used
+	if len(dc.overlays) > 0 {
+		dc.apimodelPath, err = transform.MergeAPIModelOverlays(dc.apimodelPath, dc.overlays)
+		if err != nil {
+			return errors.Wrapf(err, "error merging --overlay files with the api model: %s", dc.apimodelPath)
+		}
+
+		log.Infoln(fmt.Sprintf("new api model file has been generated during overlay merge: %s", dc.apimodelPath))
+	}
+
 	// if --set flag has been used
 	if len(dc.set) > 0 {
 		m := make(map[string]transform.APIModelValue)
@@ -195,6 +203,9 @@ func (dc *deployCmd) loadAPIModel() error {
 	if (dc.caCertificatePath != "" && dc.caPrivateKeyPath == "") || (dc.caCertificatePath == "" && dc.caPrivateKeyPath != "") {
 		return errors.New("--ca-certificate-path and --ca-private-key-path must be specified together")
 	}
+	if dc.caChainPath != "" && dc.caCertificatePath == "" {
+		return errors.New("--ca-certificate-chain-path requires --ca-certificate-path")
+	}
 
 	if dc.caCertificatePath != "" {
 		if caCertificateBytes, err = ioutil.ReadFile(dc.caCertificatePath); err != nil {
@@ -210,6 +221,13 @@ func (dc *deployCmd) loadAPIModel() error {
 		}
 		prop.CertificateProfile.CaCertificate = string(caCertificateBytes)
 		prop.CertificateProfile.CaPrivateKey = string(caKeyBytes)
+		if dc.caChainPath != "" {
+			caChainBytes, err := ioutil.ReadFile(dc.caChainPath)
+			if err != nil {
+				return errors.Wrap(err, "failed to read CA certificate chain file")
+			}
+			prop.CertificateProfile.CaCertificateChain = string(caChainBytes)
+		}
 	}
 
 	if dc.containerService.Location == "" {
@@ -235,201 +253,37 @@ func (dc *deployCmd) loadAPIModel() error {
 		return errors.Wrap(err, "failed to get client")
 	}
 
-	if err = autofillApimodel(dc); err != nil {
+	if dc.secretStore, err = dc.getSecretStore(dc.client, dc.getAuthArgs().SubscriptionID.String()); err != nil {
 		return err
 	}
-
-	dc.random = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	return nil
-}
-
-func autofillApimodel(dc *deployCmd) error {
-	var err error
-
-	if dc.containerService.Properties.LinuxProfile != nil {
-		if dc.containerService.Properties.LinuxProfile.AdminUsername == "" {
-			log.Warnf("apimodel: no linuxProfile.adminUsername was specified. Will use 'azureuser'.")
-			dc.containerService.Properties.LinuxProfile.AdminUsername = "azureuser"
-		}
-	}
-
-	if dc.dnsPrefix != "" && dc.containerService.Properties.MasterProfile.DNSPrefix != "" {
-		return errors.New("invalid configuration: the apimodel masterProfile.dnsPrefix and --dns-prefix were both specified")
-	}
-	if dc.containerService.Properties.MasterProfile.DNSPrefix == "" {
-		if dc.dnsPrefix == "" {
-			return errors.New("apimodel: missing masterProfile.dnsPrefix and --dns-prefix was not specified")
-		}
-		log.Warnf("apimodel: missing masterProfile.dnsPrefix will use %q", dc.dnsPrefix)
-		dc.containerService.Properties.MasterProfile.DNSPrefix = dc.dnsPrefix
-	}
-
-	if dc.autoSuffix {
-		suffix := strconv.FormatInt(time.Now().Unix(), 16)
-		dc.containerService.Properties.MasterProfile.DNSPrefix += "-" + suffix
-	}
-
-	if dc.outputDirectory == "" {
-		if dc.containerService.Properties.MasterProfile != nil {
-			dc.outputDirectory = path.Join("_output", dc.containerService.Properties.MasterProfile.DNSPrefix)
-		} else {
-			dc.outputDirectory = path.Join("_output", dc.containerService.Properties.HostedMasterProfile.DNSPrefix)
-		}
-	}
-
-	if _, err = os.Stat(dc.outputDirectory); !dc.forceOverwrite && err == nil {
-		return errors.Errorf("Output directory already exists and forceOverwrite flag is not set: %s", dc.outputDirectory)
-	}
-
-	if dc.resourceGroup == "" {
-		dnsPrefix := dc.containerService.Properties.MasterProfile.DNSPrefix
-		log.Warnf("--resource-group was not specified. Using the DNS prefix from the apimodel as the resource group name: %s", dnsPrefix)
-		dc.resourceGroup = dnsPrefix
-		if dc.location == "" {
-			return errors.New("--resource-group was not specified. --location must be specified in case the resource group needs creation")
-		}
-	}
-
-	if dc.containerService.Properties.LinuxProfile != nil && (dc.containerService.Properties.LinuxProfile.SSH.PublicKeys == nil ||
-		len(dc.containerService.Properties.LinuxProfile.SSH.PublicKeys) == 0 ||
-		dc.containerService.Properties.LinuxProfile.SSH.PublicKeys[0].KeyData == "") {
-		translator := &i18n.Translator{
-			Locale: dc.locale,
-		}
-		var publicKey string
-		_, publicKey, err = helpers.CreateSaveSSH(dc.containerService.Properties.LinuxProfile.AdminUsername, dc.outputDirectory, translator)
-		if err != nil {
-			return errors.Wrap(err, "Failed to generate SSH Key")
-		}
-
-		dc.containerService.Properties.LinuxProfile.SSH.PublicKeys = []api.PublicKey{{KeyData: publicKey}}
+	if err = dc.secretStore.Resolve(dc.containerService); err != nil {
+		return errors.Wrap(err, "resolving the secrets of the api model")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
-	defer cancel()
-	_, err = dc.client.EnsureResourceGroup(ctx, dc.resourceGroup, dc.location, nil)
-	if err != nil {
-		return err
-	}
-
-	k8sConfig := dc.containerService.Properties.OrchestratorProfile.KubernetesConfig
-
-	useManagedIdentity := k8sConfig != nil && k8sConfig.UseManagedIdentity
-
-	if !useManagedIdentity {
-		spp := dc.containerService.Properties.ServicePrincipalProfile
-		if spp != nil && spp.ClientID == "" && spp.Secret == "" && spp.KeyvaultSecretRef == nil && (dc.getAuthArgs().ClientID.String() == "" || dc.getAuthArgs().ClientID.String() == "00000000-0000-0000-0000-000000000000") && dc.getAuthArgs().ClientSecret == "" {
-			log.Warnln("apimodel: ServicePrincipalProfile was missing or empty, creating application...")
-
-			// TODO: consider caching the creds here so they persist between subsequent runs of 'deploy'
-			appName := dc.containerService.Properties.MasterProfile.DNSPrefix
-			appURL := fmt.Sprintf("https://%s/", appName)
-			var replyURLs *[]string
-			var requiredResourceAccess *[]graphrbac.RequiredResourceAccess
-			applicationResp, servicePrincipalObjectID, secret, err := dc.client.CreateApp(ctx, appName, appURL, replyURLs, requiredResourceAccess)
-			if err != nil {
-				return errors.Wrap(err, "apimodel invalid: ServicePrincipalProfile was empty, and we failed to create valid credentials")
-			}
-			applicationID := to.String(applicationResp.AppID)
-			log.Warnf("created application with applicationID (%s) and servicePrincipalObjectID (%s).", applicationID, servicePrincipalObjectID)
-
-			log.Warnln("apimodel: ServicePrincipalProfile was empty, assigning role to application...")
-
-			err = dc.client.CreateRoleAssignmentSimple(ctx, dc.resourceGroup, servicePrincipalObjectID)
-			if err != nil {
-				return errors.Wrap(err, "apimodel: could not create or assign ServicePrincipal")
-
-			}
-
-			dc.containerService.Properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{
-				ClientID: applicationID,
-				Secret:   secret,
-				ObjectID: servicePrincipalObjectID,
-			}
-		} else if (dc.containerService.Properties.ServicePrincipalProfile == nil || ((dc.containerService.Properties.ServicePrincipalProfile.ClientID == "" || dc.containerService.Properties.ServicePrincipalProfile.ClientID == "00000000-0000-0000-0000-000000000000") && dc.containerService.Properties.ServicePrincipalProfile.Secret == "")) && dc.getAuthArgs().ClientID.String() != "" && dc.getAuthArgs().ClientSecret != "" {
-			dc.containerService.Properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{
-				ClientID: dc.getAuthArgs().ClientID.String(),
-				Secret:   dc.getAuthArgs().ClientSecret,
-			}
-		}
-	}
 	return nil
 }
 
-// validateAPIModelAsVLabs converts the ContainerService object to a vlabs ContainerService object and validates it
-func (dc *deployCmd) validateAPIModelAsVLabs() error {
-	return api.ConvertContainerServiceToVLabs(dc.containerService).Validate(false)
-}
-
 func (dc *deployCmd) run() error {
-	ctx := engine.Context{
-		Translator: &i18n.Translator{
-			Locale: dc.locale,
-		},
-	}
-
-	templateGenerator, err := engine.InitializeTemplateGenerator(ctx)
-	if err != nil {
-		return errors.Wrap(err, "initializing template generator")
-	}
-
-	certsgenerated, err := dc.containerService.SetPropertiesDefaults(false, false)
-	if err != nil {
-		return errors.Wrapf(err, "in SetPropertiesDefaults template %s", dc.apimodelPath)
-	}
-
-	template, parameters, err := templateGenerator.GenerateTemplateV2(dc.containerService, engine.DefaultGeneratorCode, BuildTag)
-	if err != nil {
-		return errors.Wrapf(err, "generating template %s", dc.apimodelPath)
-	}
-
-	if template, err = transform.PrettyPrintArmTemplate(template); err != nil {
-		return errors.Wrap(err, "pretty-printing template")
-	}
-	var parametersFile string
-	if parametersFile, err = transform.BuildAzureParametersFile(parameters); err != nil {
-		return errors.Wrap(err, "pretty-printing template parameters")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
 
-	writer := &engine.ArtifactWriter{
+	_, err := aksengine.Deploy(ctx, dc.client, log.NewEntry(log.StandardLogger()), aksengine.DeployOptions{
+		ContainerService: dc.containerService,
+		APIVersion:       dc.apiVersion,
+		ResourceGroup:    dc.resourceGroup,
+		Location:         dc.location,
+		DNSPrefix:        dc.dnsPrefix,
+		AutoSuffix:       dc.autoSuffix,
+		OutputDirectory:  dc.outputDirectory,
+		ForceOverwrite:   dc.forceOverwrite,
+		ParametersOnly:   dc.parametersOnly,
+		ClientID:         dc.getAuthArgs().ClientID.String(),
+		ClientSecret:     dc.getAuthArgs().ClientSecret,
+		Secrets:          dc.secretStore,
+		BuildTag:         BuildTag,
 		Translator: &i18n.Translator{
 			Locale: dc.locale,
 		},
-	}
-	if err = writer.WriteTLSArtifacts(dc.containerService, dc.apiVersion, template, parametersFile, dc.outputDirectory, certsgenerated, dc.parametersOnly); err != nil {
-		return errors.Wrap(err, "writing artifacts")
-	}
-
-	templateJSON := make(map[string]interface{})
-	parametersJSON := make(map[string]interface{})
-
-	if err = json.Unmarshal([]byte(template), &templateJSON); err != nil {
-		return err
-	}
-
-	if err = json.Unmarshal([]byte(parameters), &parametersJSON); err != nil {
-		return err
-	}
-
-	deploymentSuffix := dc.random.Int31()
-	cx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
-	defer cancel()
-
-	if res, err := dc.client.DeployTemplate(
-		cx,
-		dc.resourceGroup,
-		fmt.Sprintf("%s-%d", dc.resourceGroup, deploymentSuffix),
-		templateJSON,
-		parametersJSON,
-	); err != nil {
-		if res.Response.Response != nil && res.Body != nil {
-			defer res.Body.Close()
-			body, _ := ioutil.ReadAll(res.Body)
-			log.Errorf(string(body))
-		}
-		return err
-	}
-
-	return nil
+	})
+	return err
 }
@@ -0,0 +1,261 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/nodeexec"
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	execName             = "exec"
+	execShortDescription = "Run a command on the nodes of a cluster"
+	execLongDescription  = "Run a shell command on the selected Linux nodes of a cluster, and as PowerShell on its Windows nodes with windowsProfile.sshEnabled, over SSH through the master load balancer, printing the output of every node prefixed with its name. The nodes are the VMs and scale set instances of the cluster in its resource group"
+)
+
+type execCmd struct {
+	authArgs
+	sshArgs
+
+	// user input
+	apiModelPath      string
+	resourceGroupName string
+	sshFilepath       string
+	masterFQDN        string
+	pools             []string
+	roles             []string
+	nodeNames         []string
+	sudo              bool
+	concurrency       int
+	dryRun            bool
+	command           string
+
+	// derived
+	containerService *api.ContainerService
+	client           armhelpers.AKSEngineClient
+	nodes            []nodeexec.Node
+	locale           *gotext.Locale
+	stdout           io.Writer
+}
+
+func newExecCmd() *cobra.Command {
+	ec := execCmd{
+		stdout: os.Stdout,
+	}
+
+	command := &cobra.Command{
+		Use:     execName,
+		Short:   execShortDescription,
+		Long:    execLongDescription,
+		Example: "  aks-engine exec -m _output/mycluster/apimodel.json -g mycluster --pool agentpool1 --sudo -- systemctl restart kubelet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ec.validate(cmd, args); err != nil {
+				return errors.Wrap(err, "validating exec command")
+			}
+			if err := ec.loadAPIModel(); err != nil {
+				return errors.Wrap(err, "loading API model")
+			}
+			var err error
+			if ec.client, err = ec.getAuthArgs().getClient(); err != nil {
+				return errors.Wrap(err, "failed to get client")
+			}
+			if err = ec.loadNodes(); err != nil {
+				return errors.Wrap(err, "listing the nodes of the cluster")
+			}
+			return ec.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&ec.apiModelPath, "api-model", "m", "", "path to the generated apimodel.json file (required)")
+	f.StringVarP(&ec.resourceGroupName, "resource-group", "g", "", "the resource group where the cluster is deployed (required)")
+	f.StringVar(&ec.sshFilepath, "ssh", "", "the filepath of a valid private ssh key to access the cluster's nodes (the <adminUsername>_rsa file next to the api model if it exists)")
+	f.StringVar(&ec.masterFQDN, "apiserver", "", "FQDN of the master load balancer to reach the nodes through (the FQDN of the api model by default)")
+	f.StringSliceVar(&ec.pools, "pool", nil, "run on the nodes of these agent pools (can specify multiple)")
+	f.StringSliceVar(&ec.roles, "role", nil, "run on the nodes of these roles, master or agent (can specify multiple)")
+	f.StringSliceVar(&ec.nodeNames, "node", nil, "run on the nodes named like these glob patterns, such as k8s-master-* (can specify multiple)")
+	f.BoolVar(&ec.sudo, "sudo", false, "run the command as root on the Linux nodes")
+	f.IntVar(&ec.concurrency, "concurrency", nodeexec.DefaultConcurrency, "number of nodes to run the command on at once")
+	f.BoolVar(&ec.dryRun, "dry-run", false, "list the selected nodes without running anything")
+	addSSHFlags(&ec.sshArgs, f)
+	addAuthFlags(&ec.authArgs, f)
+
+	return command
+}
+
+func (ec *execCmd) validate(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ec.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if ec.apiModelPath == "" {
+		cmd.Usage()
+		return errors.New("--api-model must be specified")
+	}
+	if _, err = os.Stat(ec.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", ec.apiModelPath)
+	}
+
+	if len(args) == 0 && !ec.dryRun {
+		cmd.Usage()
+		return errors.New("a command to run must be specified")
+	}
+	ec.command = strings.Join(args, " ")
+
+	for _, role := range ec.roles {
+		if role != nodeexec.RoleMaster && role != nodeexec.RoleAgent {
+			return errors.Errorf("--role must be %s or %s, got %q", nodeexec.RoleMaster, nodeexec.RoleAgent, role)
+		}
+	}
+	if err = ec.selector().Validate(); err != nil {
+		return errors.Wrap(err, "parsing --node")
+	}
+	if ec.concurrency <= 0 {
+		return errors.New("--concurrency must be positive")
+	}
+	if ec.sshFilepath != "" {
+		if _, err = os.Stat(ec.sshFilepath); os.IsNotExist(err) {
+			return errors.Errorf("specified ssh filepath does not exist (%s)", ec.sshFilepath)
+		}
+	}
+	if err = ec.validateSSHArgs(); err != nil {
+		return err
+	}
+	if ec.resourceGroupName == "" {
+		cmd.Usage()
+		return errors.New("--resource-group must be specified")
+	}
+	return ec.getAuthArgs().validateAuthArgs()
+}
+
+func (ec *execCmd) selector() nodeexec.Selector {
+	return nodeexec.Selector{
+		Pools: ec.pools,
+		Roles: ec.roles,
+		Names: ec.nodeNames,
+	}
+}
+
+func (ec *execCmd) loadAPIModel() error {
+	var err error
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: ec.locale,
+		},
+	}
+	ec.containerService, _, err = apiloader.LoadContainerServiceFromFile(ec.apiModelPath, false, true, nil)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the api model")
+	}
+
+	properties := ec.containerService.Properties
+	if !properties.OrchestratorProfile.IsKubernetes() || properties.MasterProfile == nil || properties.LinuxProfile == nil {
+		return errors.New("commands can only be run on Kubernetes clusters with a masterProfile and a linuxProfile")
+	}
+
+	if ec.masterFQDN == "" {
+		ec.masterFQDN = properties.MasterProfile.FQDN
+	}
+	if ec.masterFQDN == "" && ec.containerService.Location != "" {
+		ec.masterFQDN = ec.containerService.GetAzureProdFQDN()
+	}
+	ec.masterFQDN = strings.TrimPrefix(strings.TrimPrefix(ec.masterFQDN, "https://"), "http://")
+	return nil
+}
+
+// loadNodes lists the nodes of the cluster in its resource group and selects those to run the command on
+func (ec *execCmd) loadNodes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), armhelpers.DefaultARMOperationTimeout)
+	defer cancel()
+	nodes, err := nodeexec.FromAzure(ctx, ec.client, ec.resourceGroupName, ec.containerService)
+	if err != nil {
+		return err
+	}
+	ec.nodes = ec.selector().Select(nodes)
+	if len(ec.nodes) == 0 {
+		return errors.New("no node matches the selection")
+	}
+	properties := ec.containerService.Properties
+	for _, node := range ec.nodes {
+		if node.OS == nodeexec.OSWindows && (properties.WindowsProfile == nil || !properties.WindowsProfile.SSHEnabled) {
+			return errors.Errorf("Windows node %s is selected, but windowsProfile.sshEnabled is not set", node.Name)
+		}
+	}
+	return nil
+}
+
+func (ec *execCmd) run() error {
+	if ec.dryRun {
+		w := tabwriter.NewWriter(ec.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tROLE\tOS\tPOOL")
+		for _, node := range ec.nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.Name, node.Role, node.OS, node.Pool)
+		}
+		return w.Flush()
+	}
+
+	if ec.masterFQDN == "" {
+		return errors.New("--apiserver must be specified, the api model has no location to find the master FQDN with")
+	}
+	properties := ec.containerService.Properties
+	if ec.sshFilepath == "" && !ec.UseAgent {
+		defaultKey := filepath.Join(filepath.Dir(ec.apiModelPath), properties.LinuxProfile.AdminUsername+"_rsa")
+		if _, err := os.Stat(defaultKey); err != nil {
+			return errors.Errorf("--ssh or --ssh-agent must be specified, %s does not exist", defaultKey)
+		}
+		ec.sshFilepath = defaultKey
+	}
+	pool, err := ec.newSSHPool(properties.LinuxProfile.AdminUsername, ec.sshFilepath, ssh.Endpoint{Host: ec.masterFQDN})
+	if err != nil {
+		return errors.Wrap(err, "setting up SSH")
+	}
+	defer pool.Close()
+
+	log.Debugf("Running %q on %d nodes", ec.command, len(ec.nodes))
+	executor := &nodeexec.Executor{Concurrency: ec.concurrency}
+	results := executor.Run(context.Background(), ec.nodes, ec.task(pool, nodeexec.NewOutput(ec.stdout)))
+	for _, result := range results.Failed() {
+		log.Errorf("%s: %s", result.Node.Name, result.Err)
+	}
+	return results.Err()
+}
+
+// task returns the task running the command on a node, as PowerShell on Windows, streaming its output
+func (ec *execCmd) task(pool *ssh.Pool, output *nodeexec.Output) nodeexec.Task {
+	return func(ctx context.Context, node nodeexec.Node) (string, error) {
+		client, err := pool.Client(ssh.Endpoint{User: node.User, Host: node.Name})
+		if err != nil {
+			return "", err
+		}
+		command := ec.command
+		if node.OS == nodeexec.OSWindows {
+			command = nodeexec.PowerShellCommand(command)
+		} else if ec.sudo {
+			client = client.Sudo()
+		}
+		stdout, stderr := output.Writer(node.Name+": "), output.Writer(node.Name+": ")
+		err = client.Stream(ctx, command, nil, stdout, stderr)
+		stdout.Flush()
+		stderr.Flush()
+		return "", err
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/operations/nodeexec"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/spf13/cobra"
+)
+
+func TestNewExecCmd(t *testing.T) {
+	command := newExecCmd()
+	if command.Use != execName || command.Short != execShortDescription || command.Long != execLongDescription {
+		t.Fatalf("exec command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, execName, command.Short, execShortDescription, command.Long, execLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "resource-group", "subscription-id", "client-id", "client-secret", "ssh", "apiserver", "pool", "role", "node", "sudo", "concurrency", "dry-run", "ssh-known-hosts", "ssh-jump-host"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("exec command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling exec with no arguments")
+	}
+}
+
+func execTestAuthArgs() authArgs {
+	return authArgs{
+		RawAzureEnvironment: "AzurePublicCloud",
+		rawSubscriptionID:   "6dc93fae-9a76-421f-bbe5-cc6460ea81cb",
+		AuthMethod:          "client_secret",
+		rawClientID:         "b829b379-ca1f-4f1d-91a2-0d26b244680d",
+		ClientSecret:        "secret",
+	}
+}
+
+// execTestClient returns a client listing the VMs of the nodes the api model of ec names, tagged like aks-engine
+// tags them
+func execTestClient(t *testing.T, ec *execCmd) *armhelpers.MockAKSEngineClient {
+	t.Helper()
+	nodes, err := nodeexec.FromContainerService(ec.containerService)
+	if err != nil {
+		t.Fatalf("unexpected error naming the nodes: %s", err)
+	}
+	id := ec.containerService.Properties.GetClusterID()
+	client := &armhelpers.MockAKSEngineClient{}
+	client.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+		var vms []compute.VirtualMachine
+		for _, node := range nodes {
+			pool, suffix, osType := node.Pool, id, compute.Linux
+			if node.Role == nodeexec.RoleMaster {
+				pool = "master"
+			}
+			if node.OS == nodeexec.OSWindows {
+				suffix, osType = id[:4], compute.Windows
+			}
+			vms = append(vms, compute.VirtualMachine{
+				Name: to.StringPtr(node.Name),
+				Tags: map[string]*string{"poolName": to.StringPtr(pool), "resourceNameSuffix": to.StringPtr(suffix)},
+				VirtualMachineProperties: &compute.VirtualMachineProperties{
+					StorageProfile: &compute.StorageProfile{OsDisk: &compute.OSDisk{OsType: osType}},
+				},
+			})
+		}
+		return vms
+	}
+	return client
+}
+
+func TestExecCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/simple/kubernetes.json"
+	cases := []struct {
+		name        string
+		ec          execCmd
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "no api model",
+			ec:          execCmd{concurrency: 1},
+			args:        []string{"uptime"},
+			expectedErr: "--api-model must be specified",
+		},
+		{
+			name:        "missing api model",
+			ec:          execCmd{apiModelPath: "does-not-exist.json", concurrency: 1},
+			args:        []string{"uptime"},
+			expectedErr: "specified api model does not exist (does-not-exist.json)",
+		},
+		{
+			name:        "no command",
+			ec:          execCmd{apiModelPath: apiModelPath, concurrency: 1},
+			expectedErr: "a command to run must be specified",
+		},
+		{
+			name: "no command on a dry run",
+			ec:   execCmd{authArgs: execTestAuthArgs(), apiModelPath: apiModelPath, resourceGroupName: "rg", concurrency: 1, dryRun: true},
+		},
+		{
+			name:        "unknown role",
+			ec:          execCmd{apiModelPath: apiModelPath, concurrency: 1, roles: []string{"worker"}},
+			args:        []string{"uptime"},
+			expectedErr: `--role must be master or agent, got "worker"`,
+		},
+		{
+			name:        "malformed node pattern",
+			ec:          execCmd{apiModelPath: apiModelPath, concurrency: 1, nodeNames: []string{"k8s-[master"}},
+			args:        []string{"uptime"},
+			expectedErr: `parsing --node: invalid node name pattern "k8s-[master"`,
+		},
+		{
+			name:        "no concurrency",
+			ec:          execCmd{apiModelPath: apiModelPath},
+			args:        []string{"uptime"},
+			expectedErr: "--concurrency must be positive",
+		},
+		{
+			name:        "missing ssh key",
+			ec:          execCmd{apiModelPath: apiModelPath, concurrency: 1, sshFilepath: "does-not-exist_rsa"},
+			args:        []string{"uptime"},
+			expectedErr: "specified ssh filepath does not exist (does-not-exist_rsa)",
+		},
+		{
+			name:        "invalid ssh args",
+			ec:          execCmd{apiModelPath: apiModelPath, concurrency: 1, sshArgs: sshArgs{StrictHostKeyChecking: "maybe"}},
+			args:        []string{"uptime"},
+			expectedErr: `--ssh-strict-host-key-checking must be yes, accept-new or no, got "maybe"`,
+		},
+		{
+			name:        "no resource group",
+			ec:          execCmd{authArgs: execTestAuthArgs(), apiModelPath: apiModelPath, concurrency: 1},
+			args:        []string{"uptime"},
+			expectedErr: "--resource-group must be specified",
+		},
+		{
+			name:        "no credentials",
+			ec:          execCmd{authArgs: authArgs{AuthMethod: "client_secret"}, apiModelPath: apiModelPath, resourceGroupName: "rg", concurrency: 1},
+			args:        []string{"uptime"},
+			expectedErr: `--client-id and --client-secret must be specified when --auth-method="client_secret"`,
+		},
+		{
+			name: "command",
+			ec:   execCmd{authArgs: execTestAuthArgs(), apiModelPath: apiModelPath, resourceGroupName: "rg", concurrency: 1, roles: []string{"master"}},
+			args: []string{"systemctl", "status", "kubelet"},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.ec.validate(&cobra.Command{}, c.args)
+			if c.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if c.ec.command != strings.Join(c.args, " ") {
+					t.Errorf("expected the command %q, got %q", strings.Join(c.args, " "), c.ec.command)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.expectedErr {
+				t.Fatalf("expected error %q, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestExecCmdDryRun(t *testing.T) {
+	var stdout bytes.Buffer
+	ec := execCmd{
+		authArgs:          execTestAuthArgs(),
+		apiModelPath:      "../pkg/engine/testdata/simple/kubernetes.json",
+		resourceGroupName: "rg",
+		pools:             []string{"agentpool2"},
+		nodeNames:         []string{"*-0", "*-2"},
+		concurrency:       1,
+		dryRun:            true,
+		stdout:            &stdout,
+	}
+	if err := ec.validate(&cobra.Command{}, nil); err != nil {
+		t.Fatalf("unexpected error validating: %s", err)
+	}
+	if err := ec.loadAPIModel(); err != nil {
+		t.Fatalf("unexpected error loading the api model: %s", err)
+	}
+	ec.client = execTestClient(t, &ec)
+	if err := ec.loadNodes(); err != nil {
+		t.Fatalf("unexpected error listing the nodes: %s", err)
+	}
+	if err := ec.run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	prefix := ec.containerService.Properties.GetAgentVMPrefix(ec.containerService.Properties.AgentPoolProfiles[1], 1)
+	expected := "NODE" + strings.Repeat(" ", len(prefix)+1-len("NODE")) + "  ROLE   OS     POOL\n" +
+		prefix + "0  agent  linux  agentpool2\n" +
+		prefix + "2  agent  linux  agentpool2\n"
+	if stdout.String() != expected {
+		t.Errorf("expected the dry run to list\n%s\ngot\n%s", expected, stdout.String())
+	}
+}
+
+func TestExecCmdSelection(t *testing.T) {
+	cases := []struct {
+		name          string
+		apiModelPath  string
+		ec            execCmd
+		expectedNodes int
+		expectedFQDN  string
+		expectedErr   string
+	}{
+		{
+			name:          "everything",
+			apiModelPath:  "../pkg/engine/testdata/simple/kubernetes.json",
+			expectedNodes: 7,
+		},
+		{
+			name:          "masters",
+			apiModelPath:  "../pkg/engine/testdata/simple/kubernetes.json",
+			ec:            execCmd{roles: []string{nodeexec.RoleMaster}, masterFQDN: "https://mycluster.example.com"},
+			expectedNodes: 1,
+			expectedFQDN:  "mycluster.example.com",
+		},
+		{
+			name:         "nothing",
+			apiModelPath: "../pkg/engine/testdata/simple/kubernetes.json",
+			ec:           execCmd{pools: []string{"gpupool"}},
+			expectedErr:  "no node matches the selection",
+		},
+		{
+			name:         "windows without ssh",
+			apiModelPath: "../pkg/engine/testdata/windows/kubernetes.json",
+			expectedErr:  "is selected, but windowsProfile.sshEnabled is not set",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.ec.apiModelPath = c.apiModelPath
+			err := c.ec.loadAPIModel()
+			if err == nil {
+				c.ec.client = execTestClient(t, &c.ec)
+				err = c.ec.loadNodes()
+			}
+			if c.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(c.ec.nodes) != c.expectedNodes {
+				t.Errorf("expected %d nodes, got %d", c.expectedNodes, len(c.ec.nodes))
+			}
+			if c.ec.masterFQDN != c.expectedFQDN {
+				t.Errorf("expected the master FQDN %q, got %q", c.expectedFQDN, c.ec.masterFQDN)
+			}
+		})
+	}
+}
+
+func TestExecCmdMasterFQDN(t *testing.T) {
+	apiModel, err := ioutil.ReadFile("../pkg/engine/testdata/simple/kubernetes.json")
+	if err != nil {
+		t.Fatalf("unexpected error reading the api model: %s", err)
+	}
+	dir, err := ioutil.TempDir("", "exec")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	apiModelPath := filepath.Join(dir, "apimodel.json")
+	apiModel = bytes.Replace(apiModel, []byte(`"apiVersion": "vlabs",`), []byte(`"apiVersion": "vlabs", "location": "westus2",`), 1)
+	if err = ioutil.WriteFile(apiModelPath, apiModel, 0600); err != nil {
+		t.Fatalf("unexpected error writing the api model: %s", err)
+	}
+
+	ec := execCmd{apiModelPath: apiModelPath}
+	if err = ec.loadAPIModel(); err != nil {
+		t.Fatalf("unexpected error loading the api model: %s", err)
+	}
+	if ec.masterFQDN != "masterdns1.westus2.cloudapp.azure.com" {
+		t.Errorf("expected the FQDN of the location of the api model, got %s", ec.masterFQDN)
+	}
+
+	// without a location, nor an FQDN, the master is unknown
+	ec = execCmd{apiModelPath: "../pkg/engine/testdata/simple/kubernetes.json", command: "uptime"}
+	if err = ec.loadAPIModel(); err != nil {
+		t.Fatalf("unexpected error loading the api model: %s", err)
+	}
+	expectedErr := "--apiserver must be specified, the api model has no location to find the master FQDN with"
+	if err = ec.run(); err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+
+	// nor the ssh key, without the default key next to the api model
+	ec = execCmd{apiModelPath: apiModelPath, command: "uptime"}
+	if err = ec.loadAPIModel(); err != nil {
+		t.Fatalf("unexpected error loading the api model: %s", err)
+	}
+	expectedErr = "--ssh or --ssh-agent must be specified, " + filepath.Join(dir, "azureuser_rsa") + " does not exist"
+	if err = ec.run(); err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+}
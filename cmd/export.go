@@ -0,0 +1,240 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	exportName             = "export"
+	exportShortDescription = "Export a shareable api model and an encrypted bundle of its secrets"
+	exportLongDescription  = "Export a copy of an api model with its secrets redacted, which can be shared, along with a bundle of the secrets encrypted for the given recipients, and a bundle of the kubeconfigs, certificates, private keys and SSH keys next to the api model encrypted the same way. `aks-engine import` merges the bundles back."
+)
+
+// names of the encrypted bundles of secrets and of files, suffixed with the encryption tool
+const (
+	secretsBundleFilename = "secrets.json"
+	filesBundleFilename   = "files.json"
+)
+
+// exportedFilePatterns match the artifacts next to the api model that hold secrets, or belong with them
+var exportedFilePatterns = []string{"*.key", "*.crt", "*_rsa", "kubeconfig/*"}
+
+type exportCmd struct {
+	// user input
+	apiModelPath    string
+	outputDirectory string
+	encryption      string
+	recipients      []string
+
+	// derived
+	containerService *api.ContainerService
+	apiVersion       string
+	locale           *gotext.Locale
+	encrypter        *secrets.EncryptedFileProvider
+}
+
+func newExportCmd() *cobra.Command {
+	ec := exportCmd{}
+
+	command := &cobra.Command{
+		Use:   exportName,
+		Short: exportShortDescription,
+		Long:  exportLongDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ec.validate(cmd, args); err != nil {
+				return errors.Wrap(err, "validating export command")
+			}
+			if err := ec.loadAPIModel(); err != nil {
+				return errors.Wrap(err, "loading API model")
+			}
+			return ec.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&ec.apiModelPath, "api-model", "m", "", "path to the generated apimodel.json file (required)")
+	f.StringVarP(&ec.outputDirectory, "output-directory", "o", "", "directory to export the redacted api model and the secrets bundle to (required)")
+	f.StringVar(&ec.encryption, "encryption", secrets.Age, "tool to encrypt the secrets bundle with (age or gpg)")
+	f.StringSliceVar(&ec.recipients, "secret-recipient", nil, "age public key or gpg key ID to encrypt the secrets bundle for (required, can specify multiple)")
+
+	return command
+}
+
+func (ec *exportCmd) validate(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ec.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if ec.apiModelPath == "" {
+		if len(args) == 1 {
+			ec.apiModelPath = args[0]
+		} else if len(args) > 1 {
+			cmd.Usage()
+			return errors.New("too many arguments were provided to 'export'")
+		} else {
+			cmd.Usage()
+			return errors.New("--api-model must be specified")
+		}
+	}
+
+	if _, err = os.Stat(ec.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", ec.apiModelPath)
+	}
+
+	if ec.outputDirectory == "" {
+		cmd.Usage()
+		return errors.New("--output-directory must be specified")
+	}
+	// never write the redacted api model over the original, which still holds the secrets
+	outputPath, err := filepath.Abs(filepath.Join(ec.outputDirectory, apiModelFilename))
+	if err != nil {
+		return err
+	}
+	if apiModelPath, err := filepath.Abs(ec.apiModelPath); err == nil && apiModelPath == outputPath {
+		return errors.New("--output-directory must not be the directory of the api model")
+	}
+
+	if len(ec.recipients) == 0 {
+		cmd.Usage()
+		return errors.New("--secret-recipient must be specified")
+	}
+
+	if ec.encrypter, err = secrets.NewEncryptedFileProvider(ec.encryption, ec.outputDirectory, ec.recipients, ""); err != nil {
+		return errors.Wrap(err, "--encryption")
+	}
+	return nil
+}
+
+func (ec *exportCmd) loadAPIModel() error {
+	var err error
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: ec.locale,
+		},
+	}
+	// the api model is not validated, exporting it does not change it other than redacting its secrets
+	ec.containerService, ec.apiVersion, err = apiloader.LoadContainerServiceFromFile(ec.apiModelPath, false, true, nil)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the api model")
+	}
+	return nil
+}
+
+func (ec *exportCmd) run() error {
+	redacted := ec.containerService.Redact()
+
+	bundle, err := json.Marshal(redacted)
+	if err != nil {
+		return errors.Wrap(err, "serializing the secrets bundle")
+	}
+	encrypted, err := ec.encrypter.Encrypt(bundle)
+	if err != nil {
+		return err
+	}
+	files, err := readExportedFiles(filepath.Dir(ec.apiModelPath))
+	if err != nil {
+		return err
+	}
+	var encryptedFiles []byte
+	if len(files) > 0 {
+		b, err := json.Marshal(files)
+		if err != nil {
+			return errors.Wrap(err, "serializing the files bundle")
+		}
+		if encryptedFiles, err = ec.encrypter.Encrypt(b); err != nil {
+			return err
+		}
+	}
+
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: ec.locale,
+		},
+	}
+	b, err := apiloader.SerializeContainerService(ec.containerService, ec.apiVersion)
+	if err != nil {
+		return errors.Wrap(err, "serializing the redacted api model")
+	}
+
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: ec.locale,
+		},
+	}
+	if err = f.SaveFile(ec.outputDirectory, apiModelFilename, b); err != nil {
+		return err
+	}
+	bundleFilename := secretsBundleFilename + "." + ec.encryption
+	if err = f.SaveFile(ec.outputDirectory, bundleFilename, encrypted); err != nil {
+		return err
+	}
+
+	log.Infof("Exported the api model with %d secrets redacted to %s, and the secrets to %s", len(redacted), filepath.Join(ec.outputDirectory, apiModelFilename), filepath.Join(ec.outputDirectory, bundleFilename))
+
+	if len(files) == 0 {
+		log.Infof("No kubeconfig, certificate or key file is next to the api model, no files bundle was exported")
+		return nil
+	}
+	filesBundle := filesBundleFilename + "." + ec.encryption
+	if err = f.SaveFile(ec.outputDirectory, filesBundle, encryptedFiles); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Infof("Exported %s to %s", strings.Join(names, ", "), filepath.Join(ec.outputDirectory, filesBundle))
+	return nil
+}
+
+// readExportedFiles returns the contents of the files of dir matching exportedFilePatterns, by their slash-separated
+// paths relative to dir
+func readExportedFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, pattern := range exportedFilePatterns {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing the files matching %s", pattern)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				continue
+			}
+			b, err := ioutil.ReadFile(match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading %s", match)
+			}
+			name, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+			files[filepath.ToSlash(name)] = string(b)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+func TestNewExportCmd(t *testing.T) {
+	command := newExportCmd()
+	if command.Use != exportName || command.Short != exportShortDescription || command.Long != exportLongDescription {
+		t.Fatalf("export command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, exportName, command.Short, exportShortDescription, command.Long, exportLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "output-directory", "encryption", "secret-recipient"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("export command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling export with no arguments")
+	}
+}
+
+func TestExportCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/simple/kubernetes.json"
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	generatedAPIModelPath := filepath.Join(dir, apiModelFilename)
+	if err = ioutil.WriteFile(generatedAPIModelPath, []byte("{}"), 0600); err != nil {
+		t.Fatalf("unexpected error writing the api model: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		e         *exportCmd
+		expectErr bool
+	}{
+		{"valid", &exportCmd{apiModelPath: apiModelPath, outputDirectory: "_output/share", encryption: "age", recipients: []string{"age1recipient"}}, false},
+		{"gpg", &exportCmd{apiModelPath: apiModelPath, outputDirectory: "_output/share", encryption: "gpg", recipients: []string{"ops@example.com"}}, false},
+		{"missing api model", &exportCmd{apiModelPath: "missing.json", outputDirectory: "_output/share", encryption: "age", recipients: []string{"age1recipient"}}, true},
+		{"missing output directory", &exportCmd{apiModelPath: apiModelPath, encryption: "age", recipients: []string{"age1recipient"}}, true},
+		{"output directory of the api model", &exportCmd{apiModelPath: generatedAPIModelPath, outputDirectory: dir, encryption: "age", recipients: []string{"age1recipient"}}, true},
+		{"missing recipient", &exportCmd{apiModelPath: apiModelPath, outputDirectory: "_output/share", encryption: "age"}, true},
+		{"unsupported encryption", &exportCmd{apiModelPath: apiModelPath, outputDirectory: "_output/share", encryption: "zip", recipients: []string{"age1recipient"}}, true},
+	}
+	for _, c := range cases {
+		err = c.e.validate(&cobra.Command{}, []string{})
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the export flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the export flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestReadExportedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	written := map[string]string{
+		"apimodel.json":                      "{}",
+		"azuredeploy.json":                   "{}",
+		"ca.key":                             "key",
+		"ca.crt":                             "certificate",
+		"azureuser_rsa":                      "ssh key",
+		"kubeconfig/kubeconfig.westus2.json": "kubeconfig",
+	}
+	for name, content := range written {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("unexpected error creating the directory of %s: %s", name, err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected error writing %s: %s", name, err)
+		}
+	}
+
+	files, err := readExportedFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]string{
+		"ca.key":                             "key",
+		"ca.crt":                             "certificate",
+		"azureuser_rsa":                      "ssh key",
+		"kubeconfig/kubeconfig.westus2.json": "kubeconfig",
+	}
+	if diff := cmp.Diff(expected, files); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
@@ -4,14 +4,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
 
+	"github.com/Azure/aks-engine/pkg/aksengine"
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
 	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/helpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
 	"github.com/leonelquinteros/gotext"
 	"github.com/pkg/errors"
@@ -25,19 +28,37 @@ const (
 	generateLongDescription  = "Generates an Azure Resource Manager template, parameters file and other assets for a cluster"
 )
 
+const (
+	outputFormatARM       = "arm"
+	outputFormatTerraform = "terraform"
+)
+
 type generateCmd struct {
+	secretArgs
+
 	apimodelPath      string
 	outputDirectory   string // can be auto-determined from clusterDefinition
 	caCertificatePath string
 	caPrivateKeyPath  string
+	caChainPath       string
 	noPrettyPrint     bool
 	parametersOnly    bool
+	materialize       bool
+	deterministic     bool
 	set               []string
+	overlays          []string
+	outputFormat      string
+	subscriptionID    string
+	tenantID          string
+	resourceGroup     string
 
 	// derived
 	containerService *api.ContainerService
 	apiVersion       string
 	locale           *gotext.Locale
+	secretStore      *api.SecretStore
+	// entropy is the source of the generated certificates and keys, deterministic test entropy with --deterministic
+	entropy *helpers.Entropy
 }
 
 func newGenerateCmd() *cobra.Command {
@@ -69,9 +90,20 @@ func newGenerateCmd() *cobra.Command {
 	f.StringVarP(&gc.outputDirectory, "output-directory", "o", "", "output directory (derived from FQDN if absent)")
 	f.StringVar(&gc.caCertificatePath, "ca-certificate-path", "", "path to the CA certificate to use for Kubernetes PKI assets")
 	f.StringVar(&gc.caPrivateKeyPath, "ca-private-key-path", "", "path to the CA private key to use for Kubernetes PKI assets")
+	f.StringVar(&gc.caChainPath, "ca-certificate-chain-path", "", "path to the certificates that chain an intermediate CA certificate to a root CA, the issuer of the CA certificate first")
 	f.StringArrayVar(&gc.set, "set", []string{}, "set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	f.StringArrayVar(&gc.overlays, "overlay", []string{}, "path to an api model overlay merged on top of the api model (can specify multiple, applied in order)")
 	f.BoolVar(&gc.noPrettyPrint, "no-pretty-print", false, "skip pretty printing the output")
 	f.BoolVar(&gc.parametersOnly, "parameters-only", false, "only output parameters files")
+	f.BoolVar(&gc.materialize, "materialize", false, "also output the ARM template with its parameters, variables and copy loops resolved into concrete resources")
+	f.BoolVar(&gc.deterministic, "deterministic", false, "for tests only: generate byte-identical output across runs, with the test keys published with aks-engine")
+	f.StringVar(&gc.outputFormat, "output-format", outputFormatARM, "format of the generated deployment, either arm for an Azure Resource Manager template or terraform for a Terraform configuration")
+	f.StringVarP(&gc.subscriptionID, "subscription-id", "s", "", "azure subscription id to deploy to (required with --output-format terraform, resolves resource IDs with --materialize)")
+	f.StringVar(&gc.tenantID, "tenant-id", "", "azure tenant id of the subscription (required with --output-format terraform, optional with --materialize)")
+	f.StringVarP(&gc.resourceGroup, "resource-group", "g", "", "resource group to deploy to (required with --output-format terraform, resolves resource IDs with --materialize)")
+
+	addSecretFlags(&gc.secretArgs, f)
+	f.MarkHidden("deterministic")
 
 	return generateCmd
 }
@@ -100,11 +132,54 @@ func (gc *generateCmd) validate(cmd *cobra.Command, args []string) error {
 		return errors.Errorf("specified api model does not exist (%s)", gc.apimodelPath)
 	}
 
+	for _, overlay := range gc.overlays {
+		if _, err := os.Stat(overlay); os.IsNotExist(err) {
+			return errors.Errorf("specified api model overlay does not exist (%s)", overlay)
+		}
+	}
+
+	switch gc.outputFormat {
+	case "", outputFormatARM:
+	case outputFormatTerraform:
+		if gc.subscriptionID == "" || gc.tenantID == "" || gc.resourceGroup == "" {
+			return errors.New("--subscription-id, --tenant-id and --resource-group are required with --output-format terraform")
+		}
+		if gc.parametersOnly {
+			return errors.New("--parameters-only is not supported with --output-format terraform")
+		}
+		if gc.materialize {
+			return errors.New("--materialize is not supported with --output-format terraform")
+		}
+	default:
+		return errors.Errorf("unsupported --output-format %s, must be %s or %s", gc.outputFormat, outputFormatARM, outputFormatTerraform)
+	}
+
+	if gc.materialize && gc.parametersOnly {
+		return errors.New("--materialize is not supported with --parameters-only")
+	}
+
+	if err = gc.validateSecretArgs(); err != nil {
+		return err
+	}
+	if gc.SecretProvider == secretProviderKeyVault {
+		return errors.New(`--secret-provider="keyvault" is not supported by generate, as writing to Key Vault requires Azure credentials, use deploy instead`)
+	}
+
 	return nil
 }
 
 func (gc *generateCmd) mergeAPIModel() error {
 	var err error
+	// if --overlay flag has been used
+	if len(gc.overlays) > 0 {
+		gc.apimodelPath, err = transform.MergeAPIModelOverlays(gc.apimodelPath, gc.overlays)
+		if err != nil {
+			return errors.Wrap(err, "error merging --overlay files with the api model")
+		}
+
+		log.Infoln(fmt.Sprintf("new api model file has been generated during overlay merge: %s", gc.apimodelPath))
+	}
+
 	// if --set flag has been used
 	if gc.set != nil && len(gc.set) > 0 {
 		m := make(map[string]transform.APIModelValue)
@@ -127,22 +202,35 @@ func (gc *generateCmd) loadAPIModel() error {
 	var caKeyBytes []byte
 	var err error
 
+	if gc.deterministic {
+		log.Warnln("--deterministic generates certificates and keys from the test keys published with aks-engine, its output must never be deployed")
+		gc.entropy = helpers.NewDeterministicEntropy(nil)
+	}
+
 	apiloader := &api.Apiloader{
 		Translator: &i18n.Translator{
 			Locale: gc.locale,
 		},
+		Entropy: gc.entropy,
 	}
 	gc.containerService, gc.apiVersion, err = apiloader.LoadContainerServiceFromFile(gc.apimodelPath, true, false, nil)
 	if err != nil {
 		return errors.Wrap(err, "error parsing the api model")
 	}
 
+	if gc.secretStore, err = gc.getSecretStore(nil, ""); err != nil {
+		return err
+	}
+	if err = gc.secretStore.Resolve(gc.containerService); err != nil {
+		return errors.Wrap(err, "resolving the secrets of the api model")
+	}
+
+	if pkiProfile := gc.containerService.Properties.PKIProfile; gc.deterministic && pkiProfile != nil && pkiProfile.KeyAlgorithm == helpers.PkiKeyAlgorithmECDSA {
+		return errors.New("--deterministic requires RSA keys in the pkiProfile, ECDSA signatures are randomized")
+	}
+
 	if gc.outputDirectory == "" {
-		if gc.containerService.Properties.MasterProfile != nil {
-			gc.outputDirectory = path.Join("_output", gc.containerService.Properties.MasterProfile.DNSPrefix)
-		} else {
-			gc.outputDirectory = path.Join("_output", gc.containerService.Properties.HostedMasterProfile.DNSPrefix)
-		}
+		gc.outputDirectory = aksengine.DefaultOutputDirectory(gc.containerService)
 	}
 
 	// consume gc.caCertificatePath and gc.caPrivateKeyPath
@@ -150,6 +238,9 @@ func (gc *generateCmd) loadAPIModel() error {
 	if (gc.caCertificatePath != "" && gc.caPrivateKeyPath == "") || (gc.caCertificatePath == "" && gc.caPrivateKeyPath != "") {
 		return errors.New("--ca-certificate-path and --ca-private-key-path must be specified together")
 	}
+	if gc.caChainPath != "" && gc.caCertificatePath == "" {
+		return errors.New("--ca-certificate-chain-path requires --ca-certificate-path")
+	}
 	if gc.caCertificatePath != "" {
 		if caCertificateBytes, err = ioutil.ReadFile(gc.caCertificatePath); err != nil {
 			return errors.Wrap(err, "failed to read CA certificate file")
@@ -164,14 +255,87 @@ func (gc *generateCmd) loadAPIModel() error {
 		}
 		prop.CertificateProfile.CaCertificate = string(caCertificateBytes)
 		prop.CertificateProfile.CaPrivateKey = string(caKeyBytes)
+		if gc.caChainPath != "" {
+			caChainBytes, err := ioutil.ReadFile(gc.caChainPath)
+			if err != nil {
+				return errors.Wrap(err, "failed to read CA certificate chain file")
+			}
+			prop.CertificateProfile.CaCertificateChain = string(caChainBytes)
+		}
 	}
 
 	return nil
 }
 
 func (gc *generateCmd) run() error {
+	if gc.outputFormat == outputFormatTerraform {
+		return gc.runTerraform()
+	}
+
+	translator := &i18n.Translator{
+		Locale: gc.locale,
+	}
+	result, err := aksengine.Generate(context.Background(), log.NewEntry(log.StandardLogger()), aksengine.GenerateOptions{
+		ContainerService: gc.containerService,
+		APIVersion:       gc.apiVersion,
+		OutputDirectory:  gc.outputDirectory,
+		ParametersOnly:   gc.parametersOnly,
+		NoPrettyPrint:    gc.noPrettyPrint,
+		Entropy:          gc.entropy,
+		Secrets:          gc.secretStore,
+		BuildTag:         BuildTag,
+		Translator:       translator,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "generating the assets of %s", gc.apimodelPath)
+	}
+
+	if gc.materialize {
+		writer := &engine.ArtifactWriter{
+			Translator: translator,
+		}
+		return gc.writeMaterializedTemplate(writer, result.Template, result.Parameters)
+	}
+
+	return nil
+}
+
+// writeMaterializedTemplate resolves the generated template and parameters into concrete resources.
+// Without --subscription-id and --resource-group, expressions built on the resource group ID stay unresolved.
+func (gc *generateCmd) writeMaterializedTemplate(writer *engine.ArtifactWriter, template, parameters string) error {
+	env := armeval.Environment{
+		SubscriptionID:        gc.subscriptionID,
+		TenantID:              gc.tenantID,
+		ResourceGroupName:     gc.resourceGroup,
+		ResourceGroupLocation: gc.containerService.Location,
+	}
+	materialized, warnings, err := engine.MaterializeTemplate([]byte(template), []byte(parameters), env)
+	if err != nil {
+		return errors.Wrapf(err, "materializing template %s", gc.apimodelPath)
+	}
+	for _, warning := range warnings {
+		log.Warnln(warning)
+	}
+
+	if err = writer.WriteMaterializedTemplate(materialized, gc.outputDirectory); err != nil {
+		return errors.Wrap(err, "writing materialized template")
+	}
+
+	return nil
+}
+
+// runTerraform generates a Terraform configuration instead of an ARM template. ARM parameters and variables
+// are resolved for the deployment scope, so the configuration is specific to a subscription and resource group.
+func (gc *generateCmd) runTerraform() error {
 	log.Infoln(fmt.Sprintf("Generating assets into %s...", gc.outputDirectory))
 
+	if gc.containerService.Location == "" {
+		return errors.New("the api model must specify a location to generate a Terraform configuration")
+	}
+	if !gc.containerService.Properties.OrchestratorProfile.IsKubernetes() {
+		return errors.Errorf("--output-format terraform only supports the %s orchestrator, not %s", api.Kubernetes, gc.containerService.Properties.OrchestratorProfile.OrchestratorType)
+	}
+
 	ctx := engine.Context{
 		Translator: &i18n.Translator{
 			Locale: gc.locale,
@@ -182,35 +346,33 @@ func (gc *generateCmd) run() error {
 		return errors.Wrap(err, "initializing template generator")
 	}
 
-	certsGenerated, err := gc.containerService.SetPropertiesDefaults(false, false)
+	certsGenerated, err := gc.containerService.SetPropertiesDefaultsWithEntropy(false, false, gc.entropy)
 	if err != nil {
 		return errors.Wrapf(err, "in SetPropertiesDefaults template %s", gc.apimodelPath)
 	}
 
-	//TODO remove these debug statements when we're new template generation implementation is enabled!
-	//bts, _ := json.Marshal(gc.containerService)
-	//log.Info(string(bts))
+	writer := &engine.ArtifactWriter{
+		Translator: ctx.Translator,
+	}
+	if gc.secretStore != nil && gc.secretStore.InUse() {
+		writer.Secrets = gc.secretStore
+	}
 
-	template, parameters, err := templateGenerator.GenerateTemplateV2(gc.containerService, engine.DefaultGeneratorCode, BuildTag)
+	env := armeval.Environment{
+		SubscriptionID:        gc.subscriptionID,
+		TenantID:              gc.tenantID,
+		ResourceGroupName:     gc.resourceGroup,
+		ResourceGroupLocation: gc.containerService.Location,
+	}
+	config, warnings, err := templateGenerator.GenerateTerraformConfig(gc.containerService, engine.DefaultGeneratorCode, BuildTag, env)
 	if err != nil {
-		return errors.Wrapf(err, "generating template %s", gc.apimodelPath)
+		return errors.Wrapf(err, "generating Terraform configuration %s", gc.apimodelPath)
 	}
-
-	if !gc.noPrettyPrint {
-		if template, err = transform.PrettyPrintArmTemplate(template); err != nil {
-			return errors.Wrap(err, "pretty-printing template")
-		}
-		if parameters, err = transform.BuildAzureParametersFile(parameters); err != nil {
-			return errors.Wrap(err, "pretty-printing template parameters")
-		}
+	for _, warning := range warnings {
+		log.Warnln(warning)
 	}
 
-	writer := &engine.ArtifactWriter{
-		Translator: &i18n.Translator{
-			Locale: gc.locale,
-		},
-	}
-	if err = writer.WriteTLSArtifacts(gc.containerService, gc.apiVersion, template, parameters, gc.outputDirectory, certsGenerated, gc.parametersOnly); err != nil {
+	if err = writer.WriteTerraformArtifacts(gc.containerService, gc.apiVersion, config, gc.outputDirectory, certsGenerated); err != nil {
 		return errors.Wrap(err, "writing artifacts")
 	}
 
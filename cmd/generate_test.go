@@ -15,7 +15,7 @@ func TestNewGenerateCmd(t *testing.T) {
 		t.Fatalf("generate command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, generateName, command.Short, generateShortDescription, command.Long, generateLongDescription)
 	}
 
-	expectedFlags := []string{"api-model", "output-directory", "ca-certificate-path", "ca-private-key-path", "set", "no-pretty-print", "parameters-only"}
+	expectedFlags := []string{"api-model", "output-directory", "ca-certificate-path", "ca-private-key-path", "ca-certificate-chain-path", "set", "overlay", "no-pretty-print", "parameters-only", "materialize", "deterministic", "output-format", "subscription-id", "tenant-id", "resource-group", "secret-provider", "secret-dir", "secret-recipient", "secret-key-vault-id", "secret-identity"}
 	for _, f := range expectedFlags {
 		if command.Flags().Lookup(f) == nil {
 			t.Fatalf("generate command should have flag %s", f)
@@ -40,6 +40,15 @@ func TestGenerateCmdValidate(t *testing.T) {
 
 	g = &generateCmd{}
 
+	// validate cmd with a missing overlay
+	g.overlays = []string{"../pkg/engine/testdata/simple/does-not-exist.json"}
+	err = g.validate(r, []string{"../pkg/engine/testdata/simple/kubernetes.json"})
+	if err == nil {
+		t.Fatalf("expected error validating a missing overlay")
+	}
+
+	g = &generateCmd{}
+
 	// validate cmd with 0 args
 	err = g.validate(r, []string{})
 	t.Logf(err.Error())
@@ -56,6 +65,37 @@ func TestGenerateCmdValidate(t *testing.T) {
 		t.Fatalf("expected error validating multiple args")
 	}
 
+	// validate --output-format, --materialize and --deterministic
+	cases := []struct {
+		name      string
+		g         *generateCmd
+		expectErr bool
+	}{
+		{"arm", &generateCmd{outputFormat: "arm"}, false},
+		{"terraform", &generateCmd{outputFormat: "terraform", subscriptionID: "sub", tenantID: "tenant", resourceGroup: "rg"}, false},
+		{"terraform without subscription", &generateCmd{outputFormat: "terraform", tenantID: "tenant", resourceGroup: "rg"}, true},
+		{"terraform without tenant", &generateCmd{outputFormat: "terraform", subscriptionID: "sub", resourceGroup: "rg"}, true},
+		{"terraform without resource group", &generateCmd{outputFormat: "terraform", subscriptionID: "sub", tenantID: "tenant"}, true},
+		{"terraform parameters only", &generateCmd{outputFormat: "terraform", subscriptionID: "sub", tenantID: "tenant", resourceGroup: "rg", parametersOnly: true}, true},
+		{"unknown format", &generateCmd{outputFormat: "bicep"}, true},
+		{"materialize", &generateCmd{materialize: true}, false},
+		{"materialize terraform", &generateCmd{outputFormat: "terraform", subscriptionID: "sub", tenantID: "tenant", resourceGroup: "rg", materialize: true}, true},
+		{"materialize parameters only", &generateCmd{materialize: true, parametersOnly: true}, true},
+		{"deterministic", &generateCmd{deterministic: true}, false},
+		{"file secret provider", &generateCmd{secretArgs: secretArgs{SecretProvider: "file", SecretDir: "_output/secrets"}}, false},
+		{"file secret provider without directory", &generateCmd{secretArgs: secretArgs{SecretProvider: "file"}}, true},
+		{"keyvault secret provider", &generateCmd{secretArgs: secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv"}}, true},
+	}
+	for _, c := range cases {
+		err = c.g.validate(r, []string{"../pkg/engine/testdata/simple/kubernetes.json"})
+		if c.expectErr && err == nil {
+			t.Fatalf("expected error validating the output flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Fatalf("unexpected error validating the output flags for case %s: %s", c.name, err.Error())
+		}
+	}
+
 }
 
 func TestGenerateCmdMergeAPIModel(t *testing.T) {
@@ -107,6 +147,24 @@ func TestGenerateCmdMergeAPIModel(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error calling mergeAPIModel with one --set flag to override an array property: %s", err.Error())
 	}
+
+	// test with an overlay and a --set flag applied on top of it
+	g = &generateCmd{}
+	g.apimodelPath = "../pkg/engine/transform/transformtestfiles/overlays/base.json"
+	g.overlays = []string{"../pkg/engine/transform/transformtestfiles/overlays/prod.json"}
+	g.set = []string{"masterProfile.count=3"}
+	err = g.mergeAPIModel()
+	if err != nil {
+		t.Fatalf("unexpected error calling mergeAPIModel with one --overlay flag: %s", err.Error())
+	}
+
+	g = &generateCmd{}
+	g.apimodelPath = "../pkg/engine/transform/transformtestfiles/overlays/base.json"
+	g.overlays = []string{"../pkg/engine/transform/transformtestfiles/overlays/unnamed-pool.json"}
+	err = g.mergeAPIModel()
+	if err == nil {
+		t.Fatalf("expected error calling mergeAPIModel with an overlay agent pool without a name")
+	}
 }
 
 func TestGenerateCmdMLoadAPIModel(t *testing.T) {
@@ -123,3 +181,31 @@ func TestGenerateCmdMLoadAPIModel(t *testing.T) {
 		t.Fatalf("unexpected error loading api model: %s", err.Error())
 	}
 }
+
+func TestGenerateCmdLoadAPIModelDeterministicECDSA(t *testing.T) {
+	g := &generateCmd{deterministic: true}
+	r := &cobra.Command{}
+
+	g.apimodelPath = "../pkg/engine/testdata/simple/kubernetes.json"
+	g.set = []string{"pkiProfile.keyAlgorithm=ECDSA"}
+
+	g.validate(r, []string{"../pkg/engine/testdata/simple/kubernetes.json"})
+	g.mergeAPIModel()
+	if err := g.loadAPIModel(); err == nil {
+		t.Fatalf("expected an error loading an api model with ECDSA keys for a deterministic generate")
+	}
+}
+
+func TestGenerateCmdLoadAPIModelCAChain(t *testing.T) {
+	g := &generateCmd{caChainPath: "chain.pem"}
+	r := &cobra.Command{}
+
+	g.apimodelPath = "../pkg/engine/testdata/simple/kubernetes.json"
+
+	g.validate(r, []string{"../pkg/engine/testdata/simple/kubernetes.json"})
+	g.mergeAPIModel()
+	expectedErr := "--ca-certificate-chain-path requires --ca-certificate-path"
+	if err := g.loadAPIModel(); err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %q loading an api model with a CA chain and no CA, got %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	getCredentialsName             = "get-credentials"
+	getCredentialsShortDescription = "Write kubeconfigs for the users or the admins of a cluster"
+	getCredentialsLongDescription  = "Write a kubeconfig for every location of a cluster, either for its users, who authenticate with the Azure AD applications of its aadProfile, or for its admins, with a client certificate that expires after --ttl"
+)
+
+const (
+	// defaultAdminCredentialTTL is how long admin client certificates are valid by default
+	defaultAdminCredentialTTL = time.Hour
+	// maxCredentialTTL bounds how long client certificates are valid, they cannot be revoked
+	maxCredentialTTL = 24 * time.Hour * 7
+)
+
+type getCredentialsCmd struct {
+	secretArgs
+
+	// user input
+	apiModelPath    string
+	location        string
+	outputDirectory string
+	user            bool
+	admin           bool
+	auth            string
+	ttl             time.Duration
+
+	// derived
+	containerService *api.ContainerService
+	locale           *gotext.Locale
+}
+
+func newGetCredentialsCmd() *cobra.Command {
+	gcc := getCredentialsCmd{}
+
+	command := &cobra.Command{
+		Use:   getCredentialsName,
+		Short: getCredentialsShortDescription,
+		Long:  getCredentialsLongDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := gcc.validate(cmd); err != nil {
+				return errors.Wrap(err, "validating get-credentials command")
+			}
+			if err := gcc.loadAPIModel(); err != nil {
+				return errors.Wrap(err, "loading API model")
+			}
+			return gcc.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&gcc.apiModelPath, "api-model", "m", "", "path to the generated apimodel.json file (required)")
+	f.StringVarP(&gcc.location, "location", "l", "", "location of the cluster (all locations if absent from the api model too)")
+	f.StringVarP(&gcc.outputDirectory, "output-directory", "o", "", "directory to write the kubeconfigs to (the kubeconfig directory next to the api model if absent)")
+	f.BoolVar(&gcc.user, "user", false, "write kubeconfigs that authenticate users with Azure AD")
+	f.BoolVar(&gcc.admin, "admin", false, "write kubeconfigs with a short-lived admin client certificate signed by the cluster CA")
+	f.StringVar(&gcc.auth, "auth", string(engine.KubeConfigAuthProvider), "how users get Azure AD tokens, either auth-provider for the azure auth provider of kubectl or exec for the kubelogin exec plugin (used with --user)")
+	f.DurationVar(&gcc.ttl, "ttl", defaultAdminCredentialTTL, "how long the admin client certificate is valid (used with --admin)")
+	addSecretReadFlags(&gcc.secretArgs, f)
+
+	return command
+}
+
+func (gcc *getCredentialsCmd) validate(cmd *cobra.Command) error {
+	var err error
+
+	gcc.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if gcc.apiModelPath == "" {
+		cmd.Usage()
+		return errors.New("--api-model must be specified")
+	}
+	if _, err = os.Stat(gcc.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", gcc.apiModelPath)
+	}
+
+	if gcc.user == gcc.admin {
+		cmd.Usage()
+		return errors.New("exactly one of --user and --admin must be specified")
+	}
+	if gcc.user {
+		switch engine.KubeConfigAuth(gcc.auth) {
+		case engine.KubeConfigAuthProvider, engine.KubeConfigExec:
+		default:
+			return errors.Errorf("--auth must be either %s or %s", engine.KubeConfigAuthProvider, engine.KubeConfigExec)
+		}
+	}
+	if gcc.admin && (gcc.ttl <= 0 || gcc.ttl > maxCredentialTTL) {
+		return errors.Errorf("--ttl must be positive and at most %s", maxCredentialTTL)
+	}
+
+	if gcc.location != "" {
+		gcc.location = helpers.NormalizeAzureRegion(gcc.location)
+	}
+	if gcc.outputDirectory == "" {
+		gcc.outputDirectory = path.Join(filepath.Dir(gcc.apiModelPath), "kubeconfig")
+	}
+	return nil
+}
+
+func (gcc *getCredentialsCmd) loadAPIModel() error {
+	var err error
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: gcc.locale,
+		},
+	}
+	gcc.containerService, _, err = apiloader.LoadContainerServiceFromFile(gcc.apiModelPath, false, true, nil)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the api model")
+	}
+
+	secretStore, err := gcc.getSecretStore(nil, "")
+	if err != nil {
+		return err
+	}
+	if err = secretStore.Resolve(gcc.containerService); err != nil {
+		return errors.Wrap(err, "resolving the secrets of the api model")
+	}
+
+	properties := gcc.containerService.Properties
+	if !properties.OrchestratorProfile.IsKubernetes() || properties.MasterProfile == nil {
+		return errors.New("credentials can only be written for Kubernetes clusters with a masterProfile")
+	}
+	if properties.CertificateProfile == nil {
+		return errors.New("the api model has no certificateProfile, generate the cluster first")
+	}
+	if gcc.user && properties.AADProfile == nil {
+		return errors.New("--user requires an aadProfile in the api model")
+	}
+
+	if gcc.location == "" {
+		gcc.location = gcc.containerService.Location
+	} else if gcc.containerService.Location != "" && gcc.containerService.Location != gcc.location {
+		return errors.New("--location does not match api model location")
+	}
+	return nil
+}
+
+func (gcc *getCredentialsCmd) run() error {
+	properties := gcc.containerService.Properties
+
+	var kind string
+	var generate func(location string) (string, error)
+	if gcc.user {
+		kind = "user"
+		auth := engine.KubeConfigAuth(gcc.auth)
+		generate = func(location string) (string, error) {
+			return engine.GenerateUserKubeConfig(properties, location, auth)
+		}
+	} else {
+		kind = "admin"
+		caPair := &helpers.PkiKeyCertPair{
+			CertificatePem:      properties.CertificateProfile.CaCertificate,
+			PrivateKeyPem:       properties.CertificateProfile.CaPrivateKey,
+			CertificateChainPem: properties.CertificateProfile.CaCertificateChain,
+		}
+		clientPair, err := helpers.CreateClientCertificate(caPair, "client", []string{"system:masters"}, gcc.ttl, nil, properties.GetPkiOptions())
+		if err != nil {
+			return errors.Wrap(err, "signing the admin client certificate")
+		}
+		generate = func(location string) (string, error) {
+			return engine.GenerateAdminKubeConfig(properties, location, clientPair.CertificatePem, clientPair.PrivateKeyPem)
+		}
+	}
+
+	locations := []string{gcc.location}
+	if gcc.location == "" {
+		locations = helpers.GetAzureLocations()
+	}
+
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: gcc.locale,
+		},
+	}
+	for _, location := range locations {
+		kubeconfig, err := generate(location)
+		if err != nil {
+			return errors.Wrapf(err, "generating the %s kubeconfig for %s", kind, location)
+		}
+		if err = f.SaveFileString(gcc.outputDirectory, fmt.Sprintf("kubeconfig.%s.%s.json", location, kind), kubeconfig); err != nil {
+			return err
+		}
+	}
+
+	if gcc.admin {
+		log.Infof("Wrote admin kubeconfigs to %s, valid until %s", gcc.outputDirectory, time.Now().Add(gcc.ttl).Format(time.RFC3339))
+	} else {
+		log.Infof("Wrote user kubeconfigs to %s", gcc.outputDirectory)
+	}
+	return nil
+}
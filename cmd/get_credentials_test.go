@@ -0,0 +1,130 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/spf13/cobra"
+)
+
+func TestNewGetCredentialsCmd(t *testing.T) {
+	command := newGetCredentialsCmd()
+	if command.Use != getCredentialsName || command.Short != getCredentialsShortDescription || command.Long != getCredentialsLongDescription {
+		t.Fatalf("get-credentials command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, getCredentialsName, command.Short, getCredentialsShortDescription, command.Long, getCredentialsLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "location", "output-directory", "user", "admin", "auth", "ttl", "secret-identity"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("get-credentials command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling get-credentials with no arguments")
+	}
+}
+
+func TestGetCredentialsCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/simple/kubernetes.json"
+	cases := []struct {
+		name      string
+		g         *getCredentialsCmd
+		expectErr bool
+	}{
+		{"user", &getCredentialsCmd{apiModelPath: apiModelPath, user: true, auth: "auth-provider"}, false},
+		{"user with exec", &getCredentialsCmd{apiModelPath: apiModelPath, user: true, auth: "exec"}, false},
+		{"user with unsupported auth", &getCredentialsCmd{apiModelPath: apiModelPath, user: true, auth: "password"}, true},
+		{"admin", &getCredentialsCmd{apiModelPath: apiModelPath, admin: true, ttl: time.Hour}, false},
+		{"admin without ttl", &getCredentialsCmd{apiModelPath: apiModelPath, admin: true}, true},
+		{"admin with a long ttl", &getCredentialsCmd{apiModelPath: apiModelPath, admin: true, ttl: 30 * 24 * time.Hour}, true},
+		{"user and admin", &getCredentialsCmd{apiModelPath: apiModelPath, user: true, admin: true, auth: "exec", ttl: time.Hour}, true},
+		{"neither user nor admin", &getCredentialsCmd{apiModelPath: apiModelPath}, true},
+		{"missing api model", &getCredentialsCmd{admin: true, ttl: time.Hour}, true},
+		{"nonexistent api model", &getCredentialsCmd{apiModelPath: "missing.json", admin: true, ttl: time.Hour}, true},
+	}
+	for _, c := range cases {
+		err := c.g.validate(&cobra.Command{})
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the get-credentials flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the get-credentials flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestGetCredentialsCmdRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "get-credentials")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caPair, err := helpers.CreatePkiKeyCertPair("ca", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating the CA: %s", err)
+	}
+	containerService := &api.ContainerService{
+		Location: "westus2",
+		Properties: &api.Properties{
+			OrchestratorProfile: &api.OrchestratorProfile{
+				OrchestratorType: api.Kubernetes,
+			},
+			MasterProfile: &api.MasterProfile{
+				DNSPrefix: "mycluster",
+			},
+			CertificateProfile: &api.CertificateProfile{
+				CaCertificate: caPair.CertificatePem,
+				CaPrivateKey:  caPair.PrivateKeyPem,
+			},
+			AADProfile: &api.AADProfile{
+				ClientAppID: "clientappid",
+				ServerAppID: "serverappid",
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		g        *getCredentialsCmd
+		filename string
+		userName string
+	}{
+		{"user", &getCredentialsCmd{user: true, auth: "exec"}, "kubeconfig.westus2.user.json", "mycluster-user"},
+		{"admin", &getCredentialsCmd{admin: true, ttl: time.Hour}, "kubeconfig.westus2.admin.json", "mycluster-admin"},
+	}
+	for _, c := range cases {
+		c.g.containerService = containerService
+		c.g.location = "westus2"
+		c.g.outputDirectory = dir
+		if err = c.g.run(); err != nil {
+			t.Fatalf("%s: unexpected error writing credentials: %s", c.name, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, c.filename))
+		if err != nil {
+			t.Fatalf("%s: expected the kubeconfig %s to be written: %s", c.name, c.filename, err)
+		}
+		var kubeconfig struct {
+			Users []struct {
+				Name string `json:"name"`
+			} `json:"users"`
+		}
+		if err = json.Unmarshal(b, &kubeconfig); err != nil {
+			t.Fatalf("%s: unexpected error parsing the kubeconfig: %s", c.name, err)
+		}
+		if len(kubeconfig.Users) != 1 || kubeconfig.Users[0].Name != c.userName {
+			t.Errorf("%s: expected the kubeconfig user %s, got %v", c.name, c.userName, kubeconfig.Users)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	importName             = "import"
+	importShortDescription = "Merge an encrypted bundle of secrets back into an exported api model"
+	importLongDescription  = "Merge the encrypted bundle of secrets written by `aks-engine export` back into the redacted api model, so that the cluster can be scaled or upgraded with it, and restore the kubeconfigs, certificates and keys of the files bundle next to it, if any"
+)
+
+type importCmd struct {
+	secretArgs
+
+	// user input
+	apiModelPath      string
+	secretsBundlePath string
+	outputDirectory   string
+
+	// derived
+	containerService *api.ContainerService
+	apiVersion       string
+	locale           *gotext.Locale
+	decrypter        *secrets.EncryptedFileProvider
+}
+
+func newImportCmd() *cobra.Command {
+	ic := importCmd{}
+
+	command := &cobra.Command{
+		Use:   importName,
+		Short: importShortDescription,
+		Long:  importLongDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ic.validate(cmd); err != nil {
+				return errors.Wrap(err, "validating import command")
+			}
+			if err := ic.loadAPIModel(); err != nil {
+				return errors.Wrap(err, "loading API model")
+			}
+			return ic.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&ic.apiModelPath, "api-model", "m", "", "path to the exported apimodel.json file (required)")
+	f.StringVar(&ic.secretsBundlePath, "secrets-bundle", "", "path to the encrypted secrets bundle, secrets.json.age or secrets.json.gpg (required)")
+	f.StringVarP(&ic.outputDirectory, "output-directory", "o", "", "directory to write the api model with its secrets to (derived from DNS prefix if absent)")
+	addSecretReadFlags(&ic.secretArgs, f)
+
+	return command
+}
+
+func (ic *importCmd) validate(cmd *cobra.Command) error {
+	var err error
+
+	ic.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if ic.apiModelPath == "" {
+		cmd.Usage()
+		return errors.New("--api-model must be specified")
+	}
+	if _, err = os.Stat(ic.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", ic.apiModelPath)
+	}
+
+	if ic.secretsBundlePath == "" {
+		cmd.Usage()
+		return errors.New("--secrets-bundle must be specified")
+	}
+	if _, err = os.Stat(ic.secretsBundlePath); os.IsNotExist(err) {
+		return errors.Errorf("specified secrets bundle does not exist (%s)", ic.secretsBundlePath)
+	}
+
+	// the encryption tool is the extension of the bundle
+	tool := strings.TrimPrefix(filepath.Ext(ic.secretsBundlePath), ".")
+	if ic.decrypter, err = secrets.NewEncryptedFileProvider(tool, "", nil, ic.SecretIdentity); err != nil {
+		return errors.Wrap(err, "--secrets-bundle")
+	}
+	return nil
+}
+
+func (ic *importCmd) loadAPIModel() error {
+	var err error
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: ic.locale,
+		},
+	}
+	// the api model is not validated until its secrets are merged back
+	ic.containerService, ic.apiVersion, err = apiloader.LoadContainerServiceFromFile(ic.apiModelPath, false, true, nil)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the api model")
+	}
+
+	if ic.outputDirectory == "" {
+		if ic.containerService.Properties.MasterProfile != nil {
+			ic.outputDirectory = path.Join("_output", ic.containerService.Properties.MasterProfile.DNSPrefix)
+		} else {
+			ic.outputDirectory = path.Join("_output", ic.containerService.Properties.HostedMasterProfile.DNSPrefix)
+		}
+	}
+	return nil
+}
+
+func (ic *importCmd) run() error {
+	bundle, err := ic.decrypter.Decrypt(ic.secretsBundlePath)
+	if err != nil {
+		return err
+	}
+	redacted := api.RedactedSecrets{}
+	if err = json.Unmarshal(bundle, &redacted); err != nil {
+		return errors.Wrap(err, "parsing the secrets bundle")
+	}
+	if err = ic.containerService.MergeSecrets(redacted); err != nil {
+		return errors.Wrap(err, "merging the secrets bundle")
+	}
+
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: ic.locale,
+		},
+	}
+	b, err := apiloader.SerializeContainerService(ic.containerService, ic.apiVersion)
+	if err != nil {
+		return errors.Wrap(err, "serializing the api model")
+	}
+
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: ic.locale,
+		},
+	}
+	if err = f.SaveFile(ic.outputDirectory, apiModelFilename, b); err != nil {
+		return err
+	}
+	log.Infof("Merged %d secrets into %s", len(redacted), filepath.Join(ic.outputDirectory, apiModelFilename))
+
+	// the files bundle is exported next to the secrets bundle, encrypted with the same tool
+	filesBundlePath := filepath.Join(filepath.Dir(ic.secretsBundlePath), filesBundleFilename+filepath.Ext(ic.secretsBundlePath))
+	if _, err = os.Stat(filesBundlePath); os.IsNotExist(err) {
+		log.Infof("There is no files bundle %s, the kubeconfigs, certificates and keys are written again by generate", filesBundlePath)
+		return nil
+	}
+	decrypted, err := ic.decrypter.Decrypt(filesBundlePath)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{}
+	if err = json.Unmarshal(decrypted, &files); err != nil {
+		return errors.Wrap(err, "parsing the files bundle")
+	}
+	if err = writeImportedFiles(&f, ic.outputDirectory, files); err != nil {
+		return err
+	}
+	log.Infof("Restored %d files of %s to %s", len(files), filesBundlePath, ic.outputDirectory)
+	return nil
+}
+
+// writeImportedFiles writes files, by their slash-separated paths relative to dir, refusing the paths out of dir
+func writeImportedFiles(f *helpers.FileSaver, dir string, files map[string]string) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		clean := path.Clean(name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.Errorf("the files bundle holds %s, which is not in the output directory", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.FromSlash(path.Clean(name))
+		if err := f.SaveFileString(filepath.Join(dir, filepath.Dir(file)), filepath.Base(file), files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
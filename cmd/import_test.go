@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/spf13/cobra"
+)
+
+func TestNewImportCmd(t *testing.T) {
+	command := newImportCmd()
+	if command.Use != importName || command.Short != importShortDescription || command.Long != importLongDescription {
+		t.Fatalf("import command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, importName, command.Short, importShortDescription, command.Long, importLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "secrets-bundle", "output-directory", "secret-identity"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("import command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling import with no arguments")
+	}
+}
+
+func TestImportCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/simple/kubernetes.json"
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	bundles := map[string]string{}
+	for _, name := range []string{"secrets.json.age", "secrets.json.gpg", "secrets.json"} {
+		bundles[name] = filepath.Join(dir, name)
+		if err = ioutil.WriteFile(bundles[name], []byte("encrypted"), 0600); err != nil {
+			t.Fatalf("unexpected error writing the secrets bundle: %s", err)
+		}
+	}
+
+	cases := []struct {
+		name      string
+		i         *importCmd
+		expectErr bool
+	}{
+		{"age", &importCmd{apiModelPath: apiModelPath, secretsBundlePath: bundles["secrets.json.age"], secretArgs: secretArgs{SecretIdentity: "key.txt"}}, false},
+		{"gpg", &importCmd{apiModelPath: apiModelPath, secretsBundlePath: bundles["secrets.json.gpg"]}, false},
+		{"missing api model", &importCmd{secretsBundlePath: bundles["secrets.json.age"]}, true},
+		{"missing secrets bundle", &importCmd{apiModelPath: apiModelPath}, true},
+		{"nonexistent secrets bundle", &importCmd{apiModelPath: apiModelPath, secretsBundlePath: filepath.Join(dir, "missing.age")}, true},
+		{"unencrypted secrets bundle", &importCmd{apiModelPath: apiModelPath, secretsBundlePath: bundles["secrets.json"]}, true},
+	}
+	for _, c := range cases {
+		err = c.i.validate(&cobra.Command{})
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the import flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the import flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestWriteImportedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	f := &helpers.FileSaver{Translator: &i18n.Translator{}}
+
+	files := map[string]string{"ca.key": "key", "kubeconfig/kubeconfig.westus2.json": "kubeconfig"}
+	if err = writeImportedFiles(f, dir, files); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for name, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("unexpected error reading %s: %s", name, err)
+		}
+		if string(b) != content {
+			t.Errorf("expected %s to hold %q, got %q", name, content, b)
+		}
+	}
+
+	for _, name := range []string{"../ca.key", "kubeconfig/../../ca.key", "/etc/ca.key"} {
+		if err = writeImportedFiles(f, dir, map[string]string{name: "key"}); err == nil {
+			t.Errorf("expected an error writing %s out of the output directory", name)
+		}
+	}
+}
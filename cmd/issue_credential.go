@@ -0,0 +1,262 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	issueCredentialName             = "issue-credential"
+	issueCredentialShortDescription = "Issue a short-lived client certificate and kubeconfig for a user"
+	issueCredentialLongDescription  = "Sign a client certificate for a user and its groups with the cluster CA, valid for --ttl, and write a kubeconfig that authenticates with it. The serial numbers of issued certificates can be recorded in a ledger for later audits."
+)
+
+// defaultCredentialTTL is how long the client certificates of issue-credential are valid by default
+const defaultCredentialTTL = 8 * time.Hour
+
+// credentialUserRe matches the user names that can be issued credentials, which also name the kubeconfig file
+var credentialUserRe = regexp.MustCompile(`^[a-zA-Z0-9@._-]+$`)
+
+type issueCredentialCmd struct {
+	secretArgs
+
+	// user input
+	apiModelPath    string
+	location        string
+	outputDirectory string
+	user            string
+	groups          []string
+	ttl             time.Duration
+	ledgerPath      string
+
+	// derived
+	containerService *api.ContainerService
+	locale           *gotext.Locale
+}
+
+// credentialLedgerEntry is a line of the ledger of issued client certificates
+type credentialLedgerEntry struct {
+	SerialNumber string    `json:"serialNumber"`
+	Fingerprint  string    `json:"sha256Fingerprint"`
+	Cluster      string    `json:"cluster"`
+	User         string    `json:"user"`
+	Groups       []string  `json:"groups,omitempty"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+func newIssueCredentialCmd() *cobra.Command {
+	icc := issueCredentialCmd{}
+
+	command := &cobra.Command{
+		Use:   issueCredentialName,
+		Short: issueCredentialShortDescription,
+		Long:  issueCredentialLongDescription,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := icc.validate(cmd); err != nil {
+				return errors.Wrap(err, "validating issue-credential command")
+			}
+			if err := icc.loadAPIModel(); err != nil {
+				return errors.Wrap(err, "loading API model")
+			}
+			return icc.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&icc.apiModelPath, "api-model", "m", "", "path to the generated apimodel.json file (required)")
+	f.StringVarP(&icc.location, "location", "l", "", "location of the cluster (required if absent from the api model)")
+	f.StringVarP(&icc.outputDirectory, "output-directory", "o", "", "directory to write the kubeconfig to (the kubeconfig directory next to the api model if absent)")
+	f.StringVar(&icc.user, "user", "", "user name, the common name of the client certificate (required)")
+	f.StringSliceVar(&icc.groups, "group", nil, "group of the user, an organization of the client certificate (can specify multiple)")
+	f.DurationVar(&icc.ttl, "ttl", defaultCredentialTTL, "how long the client certificate is valid")
+	f.StringVar(&icc.ledgerPath, "ledger", "", "file to record the serial number of the client certificate in")
+	addSecretReadFlags(&icc.secretArgs, f)
+
+	return command
+}
+
+func (icc *issueCredentialCmd) validate(cmd *cobra.Command) error {
+	var err error
+
+	icc.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if icc.apiModelPath == "" {
+		cmd.Usage()
+		return errors.New("--api-model must be specified")
+	}
+	if _, err = os.Stat(icc.apiModelPath); os.IsNotExist(err) {
+		return errors.Errorf("specified api model does not exist (%s)", icc.apiModelPath)
+	}
+
+	if icc.user == "" {
+		cmd.Usage()
+		return errors.New("--user must be specified")
+	}
+	if !credentialUserRe.MatchString(icc.user) {
+		return errors.Errorf("--user %q must only contain letters, digits and the characters @._-", icc.user)
+	}
+	for _, group := range icc.groups {
+		if group == "" {
+			return errors.New("--group must not be empty")
+		}
+	}
+	if icc.ttl <= 0 || icc.ttl > maxCredentialTTL {
+		return errors.Errorf("--ttl must be positive and at most %s", maxCredentialTTL)
+	}
+
+	if icc.location != "" {
+		icc.location = helpers.NormalizeAzureRegion(icc.location)
+	}
+	if icc.outputDirectory == "" {
+		icc.outputDirectory = path.Join(filepath.Dir(icc.apiModelPath), "kubeconfig")
+	}
+	return nil
+}
+
+func (icc *issueCredentialCmd) loadAPIModel() error {
+	var err error
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: icc.locale,
+		},
+	}
+	icc.containerService, _, err = apiloader.LoadContainerServiceFromFile(icc.apiModelPath, false, true, nil)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the api model")
+	}
+
+	secretStore, err := icc.getSecretStore(nil, "")
+	if err != nil {
+		return err
+	}
+	if err = secretStore.Resolve(icc.containerService); err != nil {
+		return errors.Wrap(err, "resolving the secrets of the api model")
+	}
+
+	properties := icc.containerService.Properties
+	if !properties.OrchestratorProfile.IsKubernetes() || properties.MasterProfile == nil {
+		return errors.New("credentials can only be issued for Kubernetes clusters with a masterProfile")
+	}
+	if properties.CertificateProfile == nil || properties.CertificateProfile.CaPrivateKey == "" {
+		return errors.New("the api model has no CA private key to sign client certificates with")
+	}
+
+	if icc.location == "" {
+		icc.location = icc.containerService.Location
+	} else if icc.containerService.Location != "" && icc.containerService.Location != icc.location {
+		return errors.New("--location does not match api model location")
+	}
+	if icc.location == "" {
+		return errors.New("--location must be specified when the api model has no location")
+	}
+	return nil
+}
+
+func (icc *issueCredentialCmd) run() error {
+	properties := icc.containerService.Properties
+	caPair := &helpers.PkiKeyCertPair{
+		CertificatePem:      properties.CertificateProfile.CaCertificate,
+		PrivateKeyPem:       properties.CertificateProfile.CaPrivateKey,
+		CertificateChainPem: properties.CertificateProfile.CaCertificateChain,
+	}
+	clientPair, err := helpers.CreateClientCertificate(caPair, icc.user, icc.groups, icc.ttl, nil, properties.GetPkiOptions())
+	if err != nil {
+		return errors.Wrap(err, "signing the client certificate")
+	}
+
+	kubeconfig, err := engine.GenerateCertificateKubeConfig(properties, icc.location, icc.user, clientPair.CertificatePem, clientPair.PrivateKeyPem)
+	if err != nil {
+		return errors.Wrap(err, "generating the kubeconfig")
+	}
+
+	entry, err := icc.ledgerEntry(clientPair.CertificatePem)
+	if err != nil {
+		return err
+	}
+	// record the certificate before handing it out, so that no certificate escapes the ledger
+	if icc.ledgerPath != "" {
+		if err = appendCredentialLedger(icc.ledgerPath, entry); err != nil {
+			return err
+		}
+	}
+
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: icc.locale,
+		},
+	}
+	filename := fmt.Sprintf("kubeconfig.%s.%s.json", icc.location, icc.user)
+	if err = f.SaveFileString(icc.outputDirectory, filename, kubeconfig); err != nil {
+		return err
+	}
+
+	log.Infof("Wrote the kubeconfig of %s to %s, serial number %s, valid until %s", icc.user, filepath.Join(icc.outputDirectory, filename), entry.SerialNumber, entry.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// ledgerEntry returns the ledger entry of the PEM encoded client certificate
+func (icc *issueCredentialCmd) ledgerEntry(certificatePem string) (*credentialLedgerEntry, error) {
+	block, _ := pem.Decode([]byte(certificatePem))
+	if block == nil {
+		return nil, errors.New("the client certificate is not PEM encoded")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing the client certificate")
+	}
+	fingerprint := sha256.Sum256(certificate.Raw)
+	return &credentialLedgerEntry{
+		SerialNumber: certificate.SerialNumber.Text(16),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		Cluster:      icc.containerService.Properties.MasterProfile.DNSPrefix,
+		User:         certificate.Subject.CommonName,
+		Groups:       certificate.Subject.Organization,
+		NotBefore:    certificate.NotBefore.UTC(),
+		NotAfter:     certificate.NotAfter.UTC(),
+	}, nil
+}
+
+// appendCredentialLedger appends entry as a line of JSON to the ledger at path
+func appendCredentialLedger(path string, entry *credentialLedgerEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ledger, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening the credential ledger")
+	}
+	if _, err = ledger.Write(append(b, '\n')); err != nil {
+		ledger.Close()
+		return errors.Wrap(err, "writing to the credential ledger")
+	}
+	if err = ledger.Close(); err != nil {
+		return errors.Wrap(err, "writing to the credential ledger")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+func TestNewIssueCredentialCmd(t *testing.T) {
+	command := newIssueCredentialCmd()
+	if command.Use != issueCredentialName || command.Short != issueCredentialShortDescription || command.Long != issueCredentialLongDescription {
+		t.Fatalf("issue-credential command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, issueCredentialName, command.Short, issueCredentialShortDescription, command.Long, issueCredentialLongDescription)
+	}
+
+	expectedFlags := []string{"api-model", "location", "output-directory", "user", "group", "ttl", "ledger", "secret-identity"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("issue-credential command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling issue-credential with no arguments")
+	}
+}
+
+func TestIssueCredentialCmdValidate(t *testing.T) {
+	apiModelPath := "../pkg/engine/testdata/simple/kubernetes.json"
+	cases := []struct {
+		name      string
+		i         *issueCredentialCmd
+		expectErr bool
+	}{
+		{"user", &issueCredentialCmd{apiModelPath: apiModelPath, user: "alice", ttl: time.Hour}, false},
+		{"user with groups", &issueCredentialCmd{apiModelPath: apiModelPath, user: "alice@contoso.com", groups: []string{"devs", "ops"}, ttl: time.Hour}, false},
+		{"missing user", &issueCredentialCmd{apiModelPath: apiModelPath, ttl: time.Hour}, true},
+		{"user with a path", &issueCredentialCmd{apiModelPath: apiModelPath, user: "../alice", ttl: time.Hour}, true},
+		{"empty group", &issueCredentialCmd{apiModelPath: apiModelPath, user: "alice", groups: []string{""}, ttl: time.Hour}, true},
+		{"missing ttl", &issueCredentialCmd{apiModelPath: apiModelPath, user: "alice"}, true},
+		{"long ttl", &issueCredentialCmd{apiModelPath: apiModelPath, user: "alice", ttl: 30 * 24 * time.Hour}, true},
+		{"missing api model", &issueCredentialCmd{user: "alice", ttl: time.Hour}, true},
+	}
+	for _, c := range cases {
+		err := c.i.validate(&cobra.Command{})
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the issue-credential flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the issue-credential flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestIssueCredentialCmdRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-credential")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caPair, err := helpers.CreatePkiKeyCertPair("ca", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating the CA: %s", err)
+	}
+	icc := &issueCredentialCmd{
+		location:        "westus2",
+		outputDirectory: dir,
+		user:            "alice",
+		groups:          []string{"devs"},
+		ttl:             8 * time.Hour,
+		ledgerPath:      filepath.Join(dir, "ledger.json"),
+		containerService: &api.ContainerService{
+			Location: "westus2",
+			Properties: &api.Properties{
+				OrchestratorProfile: &api.OrchestratorProfile{
+					OrchestratorType: api.Kubernetes,
+				},
+				MasterProfile: &api.MasterProfile{
+					DNSPrefix: "mycluster",
+				},
+				CertificateProfile: &api.CertificateProfile{
+					CaCertificate: caPair.CertificatePem,
+					CaPrivateKey:  caPair.PrivateKeyPem,
+				},
+			},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if err = icc.run(); err != nil {
+			t.Fatalf("unexpected error issuing a credential: %s", err)
+		}
+	}
+
+	kubeconfig, err := ioutil.ReadFile(filepath.Join(dir, "kubeconfig.westus2.alice.json"))
+	if err != nil {
+		t.Fatalf("expected the kubeconfig of alice to be written: %s", err)
+	}
+	if !strings.Contains(string(kubeconfig), `"name": "mycluster-alice"`) {
+		t.Errorf("expected the kubeconfig user mycluster-alice, got %s", kubeconfig)
+	}
+
+	ledger, err := ioutil.ReadFile(icc.ledgerPath)
+	if err != nil {
+		t.Fatalf("expected the ledger to be written: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(ledger)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a ledger entry for each credential, got %s", ledger)
+	}
+	serials := map[string]bool{}
+	for _, line := range lines {
+		var entry credentialLedgerEntry
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("unexpected error parsing the ledger entry %s: %s", line, err)
+		}
+		serials[entry.SerialNumber] = true
+		if entry.Cluster != "mycluster" || entry.User != "alice" || !cmp.Equal(entry.Groups, []string{"devs"}) {
+			t.Errorf("unexpected ledger entry %s", line)
+		}
+		if validity := entry.NotAfter.Sub(entry.NotBefore); validity != 8*time.Hour {
+			t.Errorf("expected the credential to be valid for 8h, got %s", validity)
+		}
+	}
+	if len(serials) != 2 {
+		t.Errorf("expected every credential to have its own serial number, got %s", ledger)
+	}
+}
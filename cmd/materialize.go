@@ -0,0 +1,136 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/leonelquinteros/gotext"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	materializeName             = "materialize"
+	materializeShortDescription = "Resolve an existing ARM template into concrete resources"
+	materializeLongDescription  = "Resolve the parameters, variables and copy loops of an existing azuredeploy.json and azuredeploy.parameters.json into the flat list of resources they deploy, so that two templates can be compared with any diff tool"
+)
+
+type materializeCmd struct {
+	// user input
+	templatePath   string
+	parametersPath string
+	outputPath     string
+	subscriptionID string
+	tenantID       string
+	resourceGroup  string
+	location       string
+
+	// derived
+	locale *gotext.Locale
+	out    io.Writer
+}
+
+func newMaterializeCmd() *cobra.Command {
+	mc := materializeCmd{
+		out: os.Stdout,
+	}
+
+	command := &cobra.Command{
+		Use:     materializeName,
+		Short:   materializeShortDescription,
+		Long:    materializeLongDescription,
+		Example: "  aks-engine materialize -t _output/mycluster/azuredeploy.json -p _output/mycluster/azuredeploy.parameters.json -o azuredeploy.materialized.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := mc.validate(cmd, args); err != nil {
+				return errors.Wrap(err, "validating materialize command")
+			}
+			return mc.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVarP(&mc.templatePath, "template", "t", "", "path to the ARM template, such as azuredeploy.json (required)")
+	f.StringVarP(&mc.parametersPath, "parameters", "p", "", "path to the ARM template parameters, such as azuredeploy.parameters.json (required)")
+	f.StringVarP(&mc.outputPath, "output", "o", "", "path to write the materialized template to, standard output if absent")
+	f.StringVarP(&mc.subscriptionID, "subscription-id", "s", "", "azure subscription id the template is deployed to, resolves resource IDs")
+	f.StringVar(&mc.tenantID, "tenant-id", "", "azure tenant id of the subscription")
+	f.StringVarP(&mc.resourceGroup, "resource-group", "g", "", "resource group the template is deployed to, resolves resource IDs")
+	f.StringVarP(&mc.location, "location", "l", "", "location of the resource group the template is deployed to")
+
+	return command
+}
+
+func (mc *materializeCmd) validate(cmd *cobra.Command, args []string) error {
+	var err error
+
+	mc.locale, err = i18n.LoadTranslations()
+	if err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+
+	if len(args) > 0 {
+		cmd.Usage()
+		return errors.New("'materialize' does not take arguments, use --template and --parameters")
+	}
+	if mc.templatePath == "" {
+		cmd.Usage()
+		return errors.New("--template must be specified")
+	}
+	if mc.parametersPath == "" {
+		cmd.Usage()
+		return errors.New("--parameters must be specified")
+	}
+	for _, path := range []string{mc.templatePath, mc.parametersPath} {
+		if _, err = os.Stat(path); os.IsNotExist(err) {
+			return errors.Errorf("specified file does not exist (%s)", path)
+		}
+	}
+	return nil
+}
+
+func (mc *materializeCmd) run() error {
+	template, err := ioutil.ReadFile(mc.templatePath)
+	if err != nil {
+		return errors.Wrapf(err, "reading the template %s", mc.templatePath)
+	}
+	parameters, err := ioutil.ReadFile(mc.parametersPath)
+	if err != nil {
+		return errors.Wrapf(err, "reading the template parameters %s", mc.parametersPath)
+	}
+
+	env := armeval.Environment{
+		SubscriptionID:        mc.subscriptionID,
+		TenantID:              mc.tenantID,
+		ResourceGroupName:     mc.resourceGroup,
+		ResourceGroupLocation: mc.location,
+	}
+	materialized, warnings, err := engine.MaterializeTemplate(template, parameters, env)
+	if err != nil {
+		return errors.Wrapf(err, "materializing template %s", mc.templatePath)
+	}
+	for _, warning := range warnings {
+		log.Warnln(warning)
+	}
+
+	if mc.outputPath == "" {
+		_, err = fmt.Fprintln(mc.out, materialized)
+		return err
+	}
+	f := helpers.FileSaver{
+		Translator: &i18n.Translator{
+			Locale: mc.locale,
+		},
+	}
+	return f.SaveFileString(filepath.Dir(mc.outputPath), filepath.Base(mc.outputPath), materialized)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+const materializeTestTemplate = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "nameSuffix": {"type": "string"},
+    "count": {"type": "int", "defaultValue": 1}
+  },
+  "variables": {
+    "vmNamePrefix": "[concat('k8s-master-', parameters('nameSuffix'), '-')]"
+  },
+  "resources": [
+    {
+      "type": "Microsoft.Network/networkInterfaces",
+      "name": "[concat(variables('vmNamePrefix'), 'nic-', copyIndex())]",
+      "apiVersion": "2018-08-01",
+      "copy": {"name": "nicLoop", "count": "[parameters('count')]"},
+      "properties": {}
+    }
+  ]
+}`
+
+const materializeTestParameters = `{
+  "$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentParameters.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "nameSuffix": {"value": "12345678"},
+    "count": {"value": 2}
+  }
+}`
+
+func TestNewMaterializeCmd(t *testing.T) {
+	command := newMaterializeCmd()
+	if command.Use != materializeName || command.Short != materializeShortDescription || command.Long != materializeLongDescription {
+		t.Fatalf("materialize command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, materializeName, command.Short, materializeShortDescription, command.Long, materializeLongDescription)
+	}
+
+	expectedFlags := []string{"template", "parameters", "output", "subscription-id", "tenant-id", "resource-group", "location"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("materialize command should have flag %s", f)
+		}
+	}
+
+	command.SetArgs([]string{})
+	if err := command.Execute(); err == nil {
+		t.Fatalf("expected an error when calling materialize with no arguments")
+	}
+}
+
+func TestMaterializeCmdValidate(t *testing.T) {
+	dir, templatePath, parametersPath := writeMaterializeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name      string
+		m         *materializeCmd
+		args      []string
+		expectErr bool
+	}{
+		{"valid", &materializeCmd{templatePath: templatePath, parametersPath: parametersPath}, nil, false},
+		{"arguments", &materializeCmd{templatePath: templatePath, parametersPath: parametersPath}, []string{templatePath}, true},
+		{"missing template", &materializeCmd{parametersPath: parametersPath}, nil, true},
+		{"missing parameters", &materializeCmd{templatePath: templatePath}, nil, true},
+		{"template not found", &materializeCmd{templatePath: filepath.Join(dir, "missing.json"), parametersPath: parametersPath}, nil, true},
+		{"parameters not found", &materializeCmd{templatePath: templatePath, parametersPath: filepath.Join(dir, "missing.json")}, nil, true},
+	}
+	for _, c := range cases {
+		err := c.m.validate(&cobra.Command{}, c.args)
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the materialize flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the materialize flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestMaterializeCmdRun(t *testing.T) {
+	dir, templatePath, parametersPath := writeMaterializeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	for _, outputPath := range []string{"", filepath.Join(dir, "out", "azuredeploy.materialized.json")} {
+		out := &bytes.Buffer{}
+		mc := &materializeCmd{
+			templatePath:   templatePath,
+			parametersPath: parametersPath,
+			outputPath:     outputPath,
+			out:            out,
+		}
+		if err := mc.run(); err != nil {
+			t.Fatalf("unexpected error materializing the template: %s", err)
+		}
+
+		materialized := out.Bytes()
+		if outputPath != "" {
+			var err error
+			if materialized, err = ioutil.ReadFile(outputPath); err != nil {
+				t.Fatalf("unexpected error reading the materialized template: %s", err)
+			}
+		}
+		var result armeval.MaterializedTemplate
+		if err := json.Unmarshal(materialized, &result); err != nil {
+			t.Fatalf("unexpected error decoding the materialized template: %s", err)
+		}
+		var names []string
+		for _, r := range result.Resources {
+			names = append(names, r.(map[string]interface{})["name"].(string))
+		}
+		if diff := cmp.Diff([]string{"k8s-master-12345678-nic-0", "k8s-master-12345678-nic-1"}, names); diff != "" {
+			t.Errorf("unexpected materialized resources (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func writeMaterializeTestFiles(t *testing.T) (dir, templatePath, parametersPath string) {
+	dir, err := ioutil.TempDir("", "materialize")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	templatePath = filepath.Join(dir, "azuredeploy.json")
+	parametersPath = filepath.Join(dir, "azuredeploy.parameters.json")
+	if err = ioutil.WriteFile(templatePath, []byte(materializeTestTemplate), 0600); err != nil {
+		t.Fatalf("unexpected error writing the template: %s", err)
+	}
+	if err = ioutil.WriteFile(parametersPath, []byte(materializeTestParameters), 0600); err != nil {
+		t.Fatalf("unexpected error writing the template parameters: %s", err)
+	}
+	return dir, templatePath, parametersPath
+}
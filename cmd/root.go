@@ -66,10 +66,18 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(newGenerateCmd())
 	rootCmd.AddCommand(newDeployCmd())
 	rootCmd.AddCommand(newGetVersionsCmd())
+	rootCmd.AddCommand(newGetCredentialsCmd())
 	rootCmd.AddCommand(newOrchestratorsCmd())
 	rootCmd.AddCommand(newUpgradeCmd())
 	rootCmd.AddCommand(newScaleCmd())
 	rootCmd.AddCommand(newRotateCertsCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newImportCmd())
+	rootCmd.AddCommand(newIssueCredentialCmd())
+	rootCmd.AddCommand(newExecCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newConvertCmd())
+	rootCmd.AddCommand(newMaterializeCmd())
 	rootCmd.AddCommand(getCompletionCmd(rootCmd))
 
 	return rootCmd
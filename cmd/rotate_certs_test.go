@@ -4,26 +4,15 @@
 package cmd
 
 import (
-	"context"
 	"os"
 	"testing"
 
-	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
 	"github.com/gofrs/uuid"
-	. "github.com/onsi/gomega"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"golang.org/x/crypto/ssh"
-	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func mockExecuteCmd(command, masterFQDN, hostname string, port string, config *ssh.ClientConfig) (string, error) {
-	if masterFQDN != "valid" {
-		return "error running command", errors.New("executeCmd failed")
-	}
+func mockExecuteCmd(command, hostname string) (string, error) {
 	return "success", nil
 }
 
@@ -33,7 +22,7 @@ func TestNewRotateCertsCmd(t *testing.T) {
 		t.Fatalf("rotate-certs command should have use %s equal %s, short %s equal %s and long %s equal to %s", output.Use, rotateCertsName, output.Short, rotateCertsShortDescription, output.Long, rotateCertsLongDescription)
 	}
 
-	expectedFlags := []string{"location", "resource-group", "apiserver", "api-model", "ssh"}
+	expectedFlags := []string{"location", "resource-group", "apiserver", "api-model", "ssh", "secret-provider", "secret-identity", "ssh-known-hosts", "ssh-strict-host-key-checking", "ssh-host-key", "ssh-agent", "ssh-jump-host", "ssh-command-timeout"}
 	for _, f := range expectedFlags {
 		if output.Flags().Lookup(f) == nil {
 			t.Fatalf("rotate-certs command should have flag %s", f)
@@ -66,7 +55,7 @@ func TestRotateCertsCmdRun(t *testing.T) {
 		t.Fatalf("unable to create test sshFilepath: %s", err.Error())
 	}
 	defer os.Remove(rcc.sshFilepath)
-	defer os.Remove(rcc.outputDirectory)
+	defer os.RemoveAll(rcc.outputDirectory)
 
 	fakeRawSubscriptionID := "6dc93fae-9a76-421f-bbe5-cc6460ea81cb"
 	fakeSubscriptionID, err := uuid.FromString(fakeRawSubscriptionID)
@@ -89,270 +78,3 @@ func TestRotateCertsCmdRun(t *testing.T) {
 		t.Fatalf("Failed to run rotate-certs command: %s", err)
 	}
 }
-
-func TestGetClusterNodes(t *testing.T) {
-	g := NewGomegaWithT(t)
-	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
-	mockClient.MockKubernetesClient.FailListNodes = true
-	rcc := rotateCertsCmd{
-		authProvider:     &authArgs{},
-		client:           mockClient,
-		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
-	}
-	err := rcc.getClusterNodes()
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to get cluster nodes"))
-
-	mockClient.MockKubernetesClient.FailListNodes = false
-	err = rcc.getClusterNodes()
-	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(len(rcc.masterNodes)).To(Equal(1))
-	g.Expect(len(rcc.agentNodes)).To(Equal(1))
-}
-
-func TestDeleteAllPods(t *testing.T) {
-	g := NewGomegaWithT(t)
-	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
-	mockClient.MockKubernetesClient.FailListPods = true
-	rcc := rotateCertsCmd{
-		authProvider:     &authArgs{},
-		client:           mockClient,
-		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
-	}
-	err := rcc.deleteAllPods()
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to get pods"))
-
-	mockClient.MockKubernetesClient.FailListPods = false
-	mockClient.MockKubernetesClient.FailDeletePod = true
-	mockClient.MockKubernetesClient.PodsList = &v1.PodList{
-		Items: []v1.Pod{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "kube-dns",
-					Namespace: "kube-system",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "test-pod",
-					Namespace: "kube-system",
-				},
-			},
-		},
-	}
-	err = rcc.deleteAllPods()
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to delete pod"))
-
-	mockClient.MockKubernetesClient.FailDeletePod = false
-	err = rcc.deleteAllPods()
-	g.Expect(err).NotTo(HaveOccurred())
-}
-
-func TestRebootAllNodes(t *testing.T) {
-	ctx := context.Background()
-	g := NewGomegaWithT(t)
-	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
-	mockClient.FailListVirtualMachines = true
-	mockClient.FailListVirtualMachineScaleSets = false
-	mockClient.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
-		scalesetName := "scalesetName"
-		sku := compute.Sku{}
-		location := "eastus"
-		return []compute.VirtualMachineScaleSet{
-			{
-				Name:     &scalesetName,
-				Sku:      &sku,
-				Location: &location,
-			},
-		}
-	}
-	rcc := rotateCertsCmd{
-		authProvider:      &authArgs{},
-		client:            mockClient,
-		containerService:  api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
-		resourceGroupName: "test-rg",
-	}
-	err := rcc.rebootAllNodes(ctx)
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to list Virtual Machines in resource group test-rg"))
-
-	mockClient.FailListVirtualMachines = false
-	mockClient.FailListVirtualMachineScaleSets = true
-	err = rcc.rebootAllNodes(ctx)
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to list Virtual Machine Scale Sets in resource group test-rg"))
-
-	mockClient.FailListVirtualMachines = false
-	mockClient.FailListVirtualMachineScaleSets = false
-	mockClient.FailRestartVirtualMachine = true
-	mockClient.FailRestartVirtualMachineScaleSets = false
-	err = rcc.rebootAllNodes(ctx)
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to restart Virtual Machine"))
-
-	mockClient.FailRestartVirtualMachine = false
-	mockClient.FailRestartVirtualMachineScaleSets = true
-	err = rcc.rebootAllNodes(ctx)
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to restart Virtual Machine Scale Sets"))
-
-	mockClient.FailRestartVirtualMachine = false
-	mockClient.FailRestartVirtualMachineScaleSets = false
-	err = rcc.rebootAllNodes(ctx)
-	g.Expect(err).NotTo(HaveOccurred())
-}
-
-func TestDeleteServiceAccounts(t *testing.T) {
-	g := NewGomegaWithT(t)
-	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
-	mockClient.MockKubernetesClient.FailListServiceAccounts = true
-	mockClient.MockKubernetesClient.ServiceAccountList = &v1.ServiceAccountList{
-		Items: []v1.ServiceAccount{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "kube-dns",
-					Namespace: "kube-system",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "test-sa",
-					Namespace: "kube-system",
-				},
-			},
-		},
-	}
-	rcc := rotateCertsCmd{
-		authProvider:     &authArgs{},
-		client:           mockClient,
-		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
-	}
-	err := rcc.deleteServiceAccounts()
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to get cluster service accounts in namespace kube-system"))
-
-	mockClient.MockKubernetesClient.FailListServiceAccounts = false
-	mockClient.MockKubernetesClient.FailDeleteServiceAccount = true
-	err = rcc.deleteServiceAccounts()
-	g.Expect(err).To(HaveOccurred())
-	g.Expect(err.Error()).To(ContainSubstring("failed to delete service account kube-dns"))
-
-	mockClient.MockKubernetesClient.FailDeleteServiceAccount = false
-	err = rcc.deleteServiceAccounts()
-	g.Expect(err).NotTo(HaveOccurred())
-}
-
-func TestWriteArtifacts(t *testing.T) {
-	g := NewGomegaWithT(t)
-	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
-	cs.SetPropertiesDefaults(false, false)
-	rcc := rotateCertsCmd{
-		authProvider:     &authArgs{},
-		containerService: cs,
-		apiVersion:       "vlabs",
-		outputDirectory:  "_test_output",
-	}
-	defer os.RemoveAll(rcc.outputDirectory)
-	err := rcc.writeArtifacts()
-	g.Expect(err).NotTo(HaveOccurred())
-}
-
-func TestUpdateKubeconfig(t *testing.T) {
-	g := NewGomegaWithT(t)
-	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
-	cs.SetPropertiesDefaults(false, false)
-	rcc := rotateCertsCmd{
-		authProvider:       &authArgs{},
-		containerService:   cs,
-		apiVersion:         "vlabs",
-		sshCommandExecuter: mockExecuteCmd,
-		masterFQDN:         "valid",
-		masterNodes: []v1.Node{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-master-1234-0",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-master-1234-2",
-				},
-			},
-		},
-	}
-	err := rcc.updateKubeconfig()
-	g.Expect(err).NotTo(HaveOccurred())
-
-	rcc.masterFQDN = "invalid"
-	err = rcc.updateKubeconfig()
-	g.Expect(err).To(HaveOccurred())
-}
-
-func TestRotateCerts(t *testing.T) {
-	ctx := context.Background()
-	g := NewGomegaWithT(t)
-	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
-	cs.SetPropertiesDefaults(false, false)
-	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
-	rcc := rotateCertsCmd{
-		authProvider:       &authArgs{},
-		containerService:   cs,
-		sshCommandExecuter: mockExecuteCmd,
-		masterFQDN:         "valid",
-		client:             mockClient,
-		masterNodes: []v1.Node{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-master-1234-0",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-master-1234-1",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-master-1234-2",
-				},
-			},
-		},
-		agentNodes: []v1.Node{
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-agents-1234-0",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-agents-1234-1",
-				},
-			},
-			{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "k8s-agents-1234-2",
-				},
-			},
-		},
-	}
-	err := rcc.rotateEtcd(ctx)
-	g.Expect(err).NotTo(HaveOccurred())
-
-	err = rcc.rotateApiserver()
-	g.Expect(err).NotTo(HaveOccurred())
-
-	err = rcc.rotateKubelet()
-	g.Expect(err).NotTo(HaveOccurred())
-
-	rcc.masterFQDN = "invalid"
-	err = rcc.rotateEtcd(ctx)
-	g.Expect(err).To(HaveOccurred())
-
-	err = rcc.rotateApiserver()
-	g.Expect(err).To(HaveOccurred())
-
-	err = rcc.rotateKubelet()
-	g.Expect(err).To(HaveOccurred())
-}
@@ -16,7 +16,7 @@ func TestNewScaleCmd(t *testing.T) {
 		t.Fatalf("scale command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, scaleName, command.Short, scaleShortDescription, command.Long, scaleLongDescription)
 	}
 
-	expectedFlags := []string{"location", "resource-group", "api-model", "new-node-count", "node-pool", "master-FQDN"}
+	expectedFlags := []string{"location", "resource-group", "api-model", "new-node-count", "node-pool", "master-FQDN", "secret-identity"}
 	for _, f := range expectedFlags {
 		if command.Flags().Lookup(f) == nil {
 			t.Fatalf("scale command should have flag %s", f)
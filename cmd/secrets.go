@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	secretProviderFile     = "file"
+	secretProviderKeyVault = "keyvault"
+)
+
+type secretArgs struct {
+	SecretProvider   string
+	SecretDir        string
+	SecretRecipients []string
+	SecretIdentity   string
+	SecretKeyVaultID string
+}
+
+// addSecretFlags adds the flags of the commands that generate secrets
+func addSecretFlags(secretArgs *secretArgs, f *flag.FlagSet) {
+	f.StringVar(&secretArgs.SecretProvider, "secret-provider", "", "provider to keep generated secrets in, rather than the api model (file, age, gpg or keyvault)")
+	f.StringVar(&secretArgs.SecretDir, "secret-dir", "", "directory of the secret files (used with --secret-provider=[file|age|gpg])")
+	f.StringSliceVar(&secretArgs.SecretRecipients, "secret-recipient", nil, "age public key or gpg key ID to encrypt secrets for (used with --secret-provider=[age|gpg], can specify multiple)")
+	f.StringVar(&secretArgs.SecretKeyVaultID, "secret-key-vault-id", "", "resource ID of the Key Vault to keep secrets in (used with --secret-provider=keyvault)")
+	addSecretReadFlags(secretArgs, f)
+}
+
+// addSecretReadFlags adds the flags of the commands that only read secrets back
+func addSecretReadFlags(secretArgs *secretArgs, f *flag.FlagSet) {
+	f.StringVar(&secretArgs.SecretIdentity, "secret-identity", "", "age identity file to decrypt the secrets the api model refers to")
+}
+
+func (secretArgs *secretArgs) validateSecretArgs() error {
+	switch secretArgs.SecretProvider {
+	case "":
+		if secretArgs.SecretDir != "" || len(secretArgs.SecretRecipients) > 0 || secretArgs.SecretKeyVaultID != "" {
+			return errors.New("--secret-dir, --secret-recipient and --secret-key-vault-id require --secret-provider")
+		}
+	case secretProviderFile:
+		if secretArgs.SecretDir == "" {
+			return errors.New(`--secret-dir must be specified when --secret-provider="file"`)
+		}
+	case secrets.Age, secrets.GPG:
+		if secretArgs.SecretDir == "" || len(secretArgs.SecretRecipients) == 0 {
+			return errors.Errorf(`--secret-dir and --secret-recipient must be specified when --secret-provider="%s"`, secretArgs.SecretProvider)
+		}
+	case secretProviderKeyVault:
+		if secretArgs.SecretKeyVaultID == "" {
+			return errors.New(`--secret-key-vault-id must be specified when --secret-provider="keyvault"`)
+		}
+	default:
+		return errors.Errorf("--secret-provider: ERROR: provider unsupported. provider=%q", secretArgs.SecretProvider)
+	}
+	return nil
+}
+
+// getSecretStore returns the store that puts generated secrets in the provider of --secret-provider, and
+// resolves the secret references of the api model. Key Vault secrets are written with a deployment in
+// subscriptionID, which commands that do not authenticate with Azure cannot do, they pass a nil client.
+func (secretArgs *secretArgs) getSecretStore(client secrets.TemplateDeployer, subscriptionID string) (*api.SecretStore, error) {
+	file := &secrets.FileProvider{Dir: secretArgs.SecretDir}
+	age, err := secrets.NewEncryptedFileProvider(secrets.Age, secretArgs.SecretDir, secretArgs.SecretRecipients, secretArgs.SecretIdentity)
+	if err != nil {
+		return nil, err
+	}
+	gpg, err := secrets.NewEncryptedFileProvider(secrets.GPG, secretArgs.SecretDir, secretArgs.SecretRecipients, secretArgs.SecretIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	var provider secrets.Provider
+	switch secretArgs.SecretProvider {
+	case secretProviderFile:
+		provider = file
+	case secrets.Age:
+		provider = age
+	case secrets.GPG:
+		provider = gpg
+	case secretProviderKeyVault:
+		if client == nil {
+			return nil, errors.New(`--secret-provider="keyvault" is only supported by commands that authenticate with Azure, such as deploy`)
+		}
+		keyVault, err := secrets.NewKeyVaultProvider(secretArgs.SecretKeyVaultID, client)
+		if err != nil {
+			return nil, errors.Wrap(err, "--secret-key-vault-id")
+		}
+		if !strings.EqualFold(keyVault.SubscriptionID(), subscriptionID) {
+			return nil, errors.Errorf("--secret-key-vault-id must be a Key Vault of subscription %s", subscriptionID)
+		}
+		provider = keyVault
+	}
+	return api.NewSecretStore(provider, file, age, gpg), nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/secrets"
+)
+
+const testKeyVaultID = "/subscriptions/6dc93fae-9a76-421f-bbe5-cc6460ea81cb/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv"
+
+func TestValidateSecretArgs(t *testing.T) {
+	cases := []struct {
+		name      string
+		args      secretArgs
+		expectErr bool
+	}{
+		{"no provider", secretArgs{}, false},
+		{"directory without provider", secretArgs{SecretDir: "secrets"}, true},
+		{"file", secretArgs{SecretProvider: "file", SecretDir: "secrets"}, false},
+		{"file without directory", secretArgs{SecretProvider: "file"}, true},
+		{"age", secretArgs{SecretProvider: "age", SecretDir: "secrets", SecretRecipients: []string{"age1recipient"}}, false},
+		{"gpg without recipient", secretArgs{SecretProvider: "gpg", SecretDir: "secrets"}, true},
+		{"keyvault", secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: testKeyVaultID}, false},
+		{"keyvault without vault", secretArgs{SecretProvider: "keyvault"}, true},
+		{"unsupported provider", secretArgs{SecretProvider: "vault"}, true},
+	}
+	for _, c := range cases {
+		err := c.args.validateSecretArgs()
+		if c.expectErr && err == nil {
+			t.Errorf("expected error validating the secret flags for case %s", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error validating the secret flags for case %s: %s", c.name, err)
+		}
+	}
+}
+
+func TestGetSecretStore(t *testing.T) {
+	client := &armhelpers.MockAKSEngineClient{}
+	subscriptionID := "6dc93fae-9a76-421f-bbe5-cc6460ea81cb"
+
+	cases := []struct {
+		name        string
+		args        secretArgs
+		client      secrets.TemplateDeployer
+		expectErr   bool
+		expectInUse bool
+		expectByARM bool
+	}{
+		{"no provider", secretArgs{}, nil, false, false, false},
+		{"file", secretArgs{SecretProvider: "file", SecretDir: "secrets"}, nil, false, true, false},
+		{"keyvault", secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: testKeyVaultID}, client, false, true, true},
+		{"keyvault without client", secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: testKeyVaultID}, nil, true, false, false},
+		{"keyvault of another subscription", secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: "/subscriptions/other/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv"}, client, true, false, false},
+		{"invalid keyvault", secretArgs{SecretProvider: "keyvault", SecretKeyVaultID: "kv"}, client, true, false, false},
+	}
+	for _, c := range cases {
+		store, err := c.args.getSecretStore(c.client, subscriptionID)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expected error getting the secret store for case %s", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error getting the secret store for case %s: %s", c.name, err)
+			continue
+		}
+		if store.InUse() != c.expectInUse {
+			t.Errorf("expected the secret store of case %s to be in use: %t", c.name, c.expectInUse)
+		}
+		if store.ResolvedByARM() != c.expectByARM {
+			t.Errorf("expected the secret store of case %s to be resolved by ARM: %t", c.name, c.expectByARM)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/server"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	serveName             = "serve"
+	serveShortDescription = "Serve the validation, generation, versions and upgrade plans of api models over HTTP"
+	serveLongDescription  = "Serve a JSON REST API validating api models, generating their ARM templates, listing the supported Kubernetes versions and planning upgrades. It works offline, Azure credentials only let upgrade plans look up the VMs of deployed clusters"
+)
+
+// serveShutdownTimeout is how long the requests in flight are given to complete on shutdown
+const serveShutdownTimeout = 30 * time.Second
+
+// serveAuthFlags are the flags that, when set, make serve log in to Azure
+var serveAuthFlags = []string{"subscription-id", "auth-method", "client-id", "client-secret", "certificate-path", "private-key-path"}
+
+type serveCmd struct {
+	authArgs
+
+	// user input
+	listenAddress            string
+	maxConcurrentGenerations int
+
+	// derived
+	useAzure bool
+	server   *server.Server
+}
+
+func newServeCmd() *cobra.Command {
+	sc := serveCmd{}
+
+	command := &cobra.Command{
+		Use:     serveName,
+		Short:   serveShortDescription,
+		Long:    serveLongDescription,
+		Example: "  aks-engine serve --listen-address localhost:8080\n  curl -X POST --data @kubernetes.json localhost:8080/v1/apimodel/generate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := sc.validate(cmd); err != nil {
+				return errors.Wrap(err, "validating serve command")
+			}
+			if err := sc.loadServer(); err != nil {
+				return errors.Wrap(err, "setting up the server")
+			}
+			return sc.run()
+		},
+	}
+
+	f := command.Flags()
+	f.StringVar(&sc.listenAddress, "listen-address", "localhost:8080", "the host:port to serve the API on")
+	f.IntVar(&sc.maxConcurrentGenerations, "max-concurrent-generations", 0, "how many templates are generated at once, the other generate requests are rejected as busy (default the number of CPUs)")
+	addAuthFlags(&sc.authArgs, f)
+
+	return command
+}
+
+func (sc *serveCmd) validate(cmd *cobra.Command) error {
+	if sc.listenAddress == "" {
+		cmd.Usage()
+		return errors.New("--listen-address must be specified")
+	}
+	if sc.maxConcurrentGenerations < 0 {
+		return errors.New("--max-concurrent-generations must not be negative")
+	}
+	sc.useAzure = anyFlagChanged(cmd.Flags(), serveAuthFlags)
+	if sc.useAzure {
+		return sc.getAuthArgs().validateAuthArgs()
+	}
+	return nil
+}
+
+// anyFlagChanged returns whether any of names is set on the command line
+func anyFlagChanged(f *flag.FlagSet, names []string) bool {
+	for _, name := range names {
+		if f.Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *serveCmd) loadServer() error {
+	if _, err := i18n.LoadTranslations(); err != nil {
+		return errors.Wrap(err, "loading translation files")
+	}
+	sc.server = &server.Server{
+		BuildTag:                 BuildTag,
+		DefaultLanguage:          i18n.GetLanguage(),
+		MaxConcurrentGenerations: sc.maxConcurrentGenerations,
+		Logger:                   log.NewEntry(log.StandardLogger()),
+	}
+	if sc.useAzure {
+		client, err := sc.getAuthArgs().getClient()
+		if err != nil {
+			return errors.Wrap(err, "failed to get client")
+		}
+		sc.server.Client = client
+	} else {
+		log.Info("No Azure credentials given, the upgrades of deployed clusters cannot be planned")
+	}
+	return nil
+}
+
+func (sc *serveCmd) run() error {
+	httpServer := &http.Server{
+		Addr:    sc.listenAddress,
+		Handler: sc.server.Handler(),
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-stop
+		log.Info("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Warnf("shutting down: %s", err)
+		}
+	}()
+
+	log.Infof("Serving on %s", sc.listenAddress)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return errors.Wrap(err, "serving")
+	}
+	// the requests in flight complete before Shutdown returns
+	<-stopped
+	return nil
+}
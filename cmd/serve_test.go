@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestNewServeCmd(t *testing.T) {
+	command := newServeCmd()
+	if command.Use != serveName || command.Short != serveShortDescription || command.Long != serveLongDescription {
+		t.Fatalf("serve command should have use %s equal %s, short %s equal %s and long %s equal to %s", command.Use, serveName, command.Short, serveShortDescription, command.Long, serveLongDescription)
+	}
+
+	expectedFlags := []string{"listen-address", "max-concurrent-generations", "subscription-id", "auth-method", "client-id", "client-secret", "language"}
+	for _, f := range expectedFlags {
+		if command.Flags().Lookup(f) == nil {
+			t.Fatalf("serve command should have flag %s", f)
+		}
+	}
+}
+
+func TestServeCmdValidate(t *testing.T) {
+	cases := []struct {
+		name             string
+		args             []string
+		expectedUseAzure bool
+		expectedErr      string
+	}{
+		{
+			name: "offline",
+		},
+		{
+			name:        "no listen address",
+			args:        []string{"--listen-address="},
+			expectedErr: "--listen-address must be specified",
+		},
+		{
+			name:        "negative max concurrent generations",
+			args:        []string{"--max-concurrent-generations=-1"},
+			expectedErr: "--max-concurrent-generations must not be negative",
+		},
+		{
+			name:        "incomplete credentials",
+			args:        []string{"--subscription-id", "d0bfc3b5-e3c6-4b6e-8bb6-3fba3d3dcb3a"},
+			expectedErr: `--client-id and --client-secret must be specified when --auth-method="client_secret"`,
+		},
+		{
+			name:             "credentials",
+			args:             []string{"--subscription-id", "d0bfc3b5-e3c6-4b6e-8bb6-3fba3d3dcb3a", "--client-id", "2d5f8a2d-77a4-4f07-90d6-14ae08e0d3ef", "--client-secret", "secret"},
+			expectedUseAzure: true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			sc := serveCmd{}
+			command := &cobra.Command{}
+			f := command.Flags()
+			f.StringVar(&sc.listenAddress, "listen-address", "localhost:8080", "")
+			f.IntVar(&sc.maxConcurrentGenerations, "max-concurrent-generations", 0, "")
+			addAuthFlags(&sc.authArgs, f)
+			if err := f.Parse(c.args); err != nil {
+				t.Fatalf("unexpected error parsing the flags: %s", err)
+			}
+
+			err := sc.validate(command)
+			if c.expectedErr != "" {
+				if err == nil || err.Error() != c.expectedErr {
+					t.Fatalf("expected error %q, got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if sc.useAzure != c.expectedUseAzure {
+				t.Errorf("expected useAzure to be %t, got %t", c.expectedUseAzure, sc.useAzure)
+			}
+		})
+	}
+}
+
+func TestServeCmdLoadServer(t *testing.T) {
+	sc := serveCmd{listenAddress: "localhost:8080"}
+	if err := sc.loadServer(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sc.server.Client != nil {
+		t.Error("expected no Azure client without credentials")
+	}
+	if sc.server.BuildTag != BuildTag || sc.server.DefaultLanguage == "" {
+		t.Errorf("expected the server to stamp templates with %s and translate errors, got %+v", BuildTag, sc.server)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	strictHostKeyCheckingYes       = "yes"
+	strictHostKeyCheckingAcceptNew = "accept-new"
+	strictHostKeyCheckingNo        = "no"
+)
+
+type sshArgs struct {
+	KnownHostsPath        string
+	StrictHostKeyChecking string
+	HostKeys              []string
+	UseAgent              bool
+	JumpHosts             []string
+	CommandTimeout        time.Duration
+}
+
+// addSSHFlags adds the flags of the commands that run commands on the cluster nodes over SSH
+func addSSHFlags(sshArgs *sshArgs, f *flag.FlagSet) {
+	f.StringVar(&sshArgs.KnownHostsPath, "ssh-known-hosts", "", "known_hosts file to verify the host keys of the nodes with (default ~/.ssh/known_hosts)")
+	f.StringVar(&sshArgs.StrictHostKeyChecking, "ssh-strict-host-key-checking", strictHostKeyCheckingAcceptNew, "yes to refuse the nodes missing from the known hosts, accept-new to add them, no to skip verifying host keys")
+	f.StringArrayVar(&sshArgs.HostKeys, "ssh-host-key", nil, "host key to pin, rather than using the known hosts, as host=\"ssh-ed25519 AAAA...\" (can specify multiple)")
+	f.BoolVar(&sshArgs.UseAgent, "ssh-agent", false, "authenticate with the keys of the ssh-agent too")
+	f.StringArrayVar(&sshArgs.JumpHosts, "ssh-jump-host", nil, "[user@]host[:port] to reach the nodes through, such as the jumpbox of a private cluster, rather than the master load balancer (can specify multiple, in order)")
+	f.DurationVar(&sshArgs.CommandTimeout, "ssh-command-timeout", 0, "timeout of each command run on the nodes (default no timeout)")
+}
+
+func (sshArgs *sshArgs) validateSSHArgs() error {
+	switch sshArgs.StrictHostKeyChecking {
+	case "", strictHostKeyCheckingYes, strictHostKeyCheckingAcceptNew, strictHostKeyCheckingNo:
+	default:
+		return errors.Errorf("--ssh-strict-host-key-checking must be yes, accept-new or no, got %q", sshArgs.StrictHostKeyChecking)
+	}
+	if len(sshArgs.HostKeys) > 0 && (sshArgs.KnownHostsPath != "" || sshArgs.StrictHostKeyChecking == strictHostKeyCheckingNo) {
+		return errors.New("--ssh-host-key cannot be combined with --ssh-known-hosts or --ssh-strict-host-key-checking=no")
+	}
+	if len(sshArgs.HostKeys) > 0 {
+		if _, err := sshArgs.pinnedHostKeys(); err != nil {
+			return err
+		}
+	}
+	for _, jumpHost := range sshArgs.JumpHosts {
+		if _, err := ssh.ParseEndpoint(jumpHost); err != nil {
+			return errors.Wrap(err, "parsing --ssh-jump-host")
+		}
+	}
+	if sshArgs.CommandTimeout < 0 {
+		return errors.New("--ssh-command-timeout must not be negative")
+	}
+	return nil
+}
+
+func (sshArgs *sshArgs) pinnedHostKeys() (ssh.PinnedHostKeys, error) {
+	pins := map[string][]string{}
+	for _, hostKey := range sshArgs.HostKeys {
+		parts := strings.SplitN(hostKey, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.ContainsAny(parts[0], " \t") {
+			return nil, errors.Errorf("--ssh-host-key must be host=key, got %q", hostKey)
+		}
+		pins[parts[0]] = append(pins[parts[0]], parts[1])
+	}
+	return ssh.ParsePinnedHostKeys(pins)
+}
+
+// hostKeys returns the verifier of the host keys of the nodes
+func (sshArgs *sshArgs) hostKeys() (ssh.HostKeyVerifier, error) {
+	if len(sshArgs.HostKeys) > 0 {
+		return sshArgs.pinnedHostKeys()
+	}
+	if sshArgs.StrictHostKeyChecking == strictHostKeyCheckingNo {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	path := sshArgs.KnownHostsPath
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, errors.Wrap(err, "finding the default known hosts file")
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return ssh.LoadKnownHosts(path, sshArgs.StrictHostKeyChecking != strictHostKeyCheckingYes)
+}
+
+// newSSHPool returns a pool of SSH connections to the nodes, logging in as user with the private key at
+// privateKeyPath, if any, through the jump hosts, or else through defaultJumpHost
+func (sshArgs *sshArgs) newSSHPool(user, privateKeyPath string, defaultJumpHost ssh.Endpoint) (*ssh.Pool, error) {
+	hostKeys, err := sshArgs.hostKeys()
+	if err != nil {
+		return nil, err
+	}
+	config := ssh.Config{
+		User:           user,
+		UseAgent:       sshArgs.UseAgent,
+		HostKeys:       hostKeys,
+		JumpHosts:      []ssh.Endpoint{defaultJumpHost},
+		CommandTimeout: sshArgs.CommandTimeout,
+	}
+	if privateKeyPath != "" {
+		privateKey, err := ioutil.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading the SSH private key")
+		}
+		config.PrivateKeys = [][]byte{privateKey}
+	}
+	if len(sshArgs.JumpHosts) > 0 {
+		config.JumpHosts = nil
+		for _, jumpHost := range sshArgs.JumpHosts {
+			endpoint, err := ssh.ParseEndpoint(jumpHost)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing --ssh-jump-host")
+			}
+			config.JumpHosts = append(config.JumpHosts, endpoint)
+		}
+	}
+	return ssh.NewPool(config)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
+	"github.com/pkg/errors"
+)
+
+const testHostKey = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBMo3Opzggt1/DAbQSQvP+mXg2JgMG314kM0rx9laLclbMGd/zRaTbHHw6jSNlJcc//2n71QL8dQThLykUDIpBSI="
+
+func TestValidateSSHArgs(t *testing.T) {
+	cases := []struct {
+		name        string
+		sshArgs     sshArgs
+		expectedErr error
+	}{
+		{
+			name:    "defaults",
+			sshArgs: sshArgs{StrictHostKeyChecking: strictHostKeyCheckingAcceptNew},
+		},
+		{
+			name:    "pinned host keys and jump hosts",
+			sshArgs: sshArgs{HostKeys: []string{"k8s-master-12345-0=" + testHostKey}, JumpHosts: []string{"azureuser@jumpbox:2222"}},
+		},
+		{
+			name:        "invalid strict host key checking",
+			sshArgs:     sshArgs{StrictHostKeyChecking: "ask"},
+			expectedErr: errors.New(`--ssh-strict-host-key-checking must be yes, accept-new or no, got "ask"`),
+		},
+		{
+			name:        "pinned host keys and no host key checking",
+			sshArgs:     sshArgs{StrictHostKeyChecking: strictHostKeyCheckingNo, HostKeys: []string{"k8s-master-12345-0=" + testHostKey}},
+			expectedErr: errors.New("--ssh-host-key cannot be combined with --ssh-known-hosts or --ssh-strict-host-key-checking=no"),
+		},
+		{
+			name:        "pinned host key without a host",
+			sshArgs:     sshArgs{HostKeys: []string{testHostKey}},
+			expectedErr: errors.Errorf("--ssh-host-key must be host=key, got %q", testHostKey),
+		},
+		{
+			name:        "invalid jump host",
+			sshArgs:     sshArgs{JumpHosts: []string{"jumpbox:ssh"}},
+			expectedErr: errors.New(`parsing --ssh-jump-host: "jumpbox:ssh" has an invalid port`),
+		},
+		{
+			name:        "negative command timeout",
+			sshArgs:     sshArgs{CommandTimeout: -1},
+			expectedErr: errors.New("--ssh-command-timeout must not be negative"),
+		},
+	}
+	for _, c := range cases {
+		err := c.sshArgs.validateSSHArgs()
+		if c.expectedErr == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		}
+		if c.expectedErr != nil && (err == nil || err.Error() != c.expectedErr.Error()) {
+			t.Errorf("%s: expected error %q, got %v", c.name, c.expectedErr, err)
+		}
+	}
+}
+
+func TestSSHArgsHostKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostKeys, err := (&sshArgs{HostKeys: []string{"k8s-master-12345-0=" + testHostKey}}).hostKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pinned, ok := hostKeys.(ssh.PinnedHostKeys); !ok || len(pinned["k8s-master-12345-0"]) != 1 {
+		t.Errorf("expected the pinned host key, got %#v", hostKeys)
+	}
+
+	hostKeys, err = (&sshArgs{StrictHostKeyChecking: strictHostKeyCheckingNo}).hostKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hostKeys != ssh.InsecureIgnoreHostKey() {
+		t.Errorf("expected host keys not to be verified, got %#v", hostKeys)
+	}
+
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	if err = ioutil.WriteFile(knownHostsPath, []byte("k8s-master-12345-0 "+testHostKey+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write the known hosts: %s", err)
+	}
+	hostKeys, err = (&sshArgs{KnownHostsPath: knownHostsPath, StrictHostKeyChecking: strictHostKeyCheckingYes}).hostKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if algorithms := hostKeys.HostKeyAlgorithms("k8s-master-12345-0:22"); len(algorithms) != 1 || algorithms[0] != "ecdsa-sha2-nistp256" {
+		t.Errorf("expected the known host key of the master, got %v", algorithms)
+	}
+}
+
+func TestSSHArgsNewSSHPool(t *testing.T) {
+	a := &sshArgs{StrictHostKeyChecking: strictHostKeyCheckingNo}
+	if _, err := a.newSSHPool("azureuser", "missing_ssh_key", ssh.Endpoint{Host: "master"}); err == nil {
+		t.Errorf("expected an error reading a missing private key")
+	}
+	if _, err := a.newSSHPool("azureuser", "", ssh.Endpoint{Host: "master"}); err == nil {
+		t.Errorf("expected an error without a private key or ssh-agent to authenticate with")
+	}
+}
@@ -5,17 +5,15 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/Azure/aks-engine/pkg/aksengine"
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
-	"github.com/Azure/aks-engine/pkg/engine"
 	"github.com/Azure/aks-engine/pkg/helpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
-	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
 	"github.com/leonelquinteros/gotext"
 	"github.com/pkg/errors"
 
@@ -31,6 +29,7 @@ const (
 
 type upgradeCmd struct {
 	authProvider
+	secretArgs
 
 	// user input
 	resourceGroupName           string
@@ -43,14 +42,13 @@ type upgradeCmd struct {
 	force                       bool
 
 	// derived
-	containerService    *api.ContainerService
-	apiVersion          string
-	client              armhelpers.AKSEngineClient
-	locale              *gotext.Locale
-	nameSuffix          string
-	agentPoolsToUpgrade map[string]bool
-	timeout             *time.Duration
-	cordonDrainTimeout  *time.Duration
+	containerService   *api.ContainerService
+	apiVersion         string
+	client             armhelpers.AKSEngineClient
+	locale             *gotext.Locale
+	timeout            *time.Duration
+	cordonDrainTimeout *time.Duration
+	secretStore        *api.SecretStore
 }
 
 func newUpgradeCmd() *cobra.Command {
@@ -75,6 +73,7 @@ func newUpgradeCmd() *cobra.Command {
 	f.IntVar(&uc.cordonDrainTimeoutInMinutes, "cordon-drain-timeout", -1, "how long to wait for each vm to be cordoned in minutes")
 	f.BoolVarP(&uc.force, "force", "f", false, "force upgrading the cluster to desired version. Allows same version upgrades and downgrades.")
 	addAuthFlags(uc.getAuthArgs(), f)
+	addSecretReadFlags(&uc.secretArgs, f)
 
 	f.MarkDeprecated("deployment-dir", "deployment-dir is no longer required for scale or upgrade. Please use --api-model.")
 
@@ -155,6 +154,13 @@ func (uc *upgradeCmd) loadCluster() error {
 		return errors.Wrap(err, "error parsing the api model")
 	}
 
+	if uc.secretStore, err = uc.getSecretStore(nil, ""); err != nil {
+		return err
+	}
+	if err = uc.secretStore.Resolve(uc.containerService); err != nil {
+		return errors.Wrap(err, "resolving the secrets of the api model")
+	}
+
 	if uc.containerService.Properties.IsAzureStackCloud() {
 		writeCustomCloudProfile(uc.containerService)
 		err = uc.containerService.Properties.SetAzureStackCloudSpec()
@@ -184,21 +190,8 @@ func (uc *upgradeCmd) loadCluster() error {
 }
 
 func (uc *upgradeCmd) validateTargetVersion() error {
-	// Get available upgrades for container service.
-	orchestratorInfo, err := api.GetOrchestratorVersionProfile(uc.containerService.Properties.OrchestratorProfile, uc.containerService.Properties.HasWindows())
-	if err != nil {
-		return errors.Wrap(err, "error getting list of available upgrades")
-	}
-
-	found := false
-	for _, up := range orchestratorInfo.Upgrades {
-		if up.OrchestratorVersion == uc.upgradeVersion {
-			found = true
-			break
-		}
-	}
-	if !found {
-		return errors.Errorf("upgrading from Kubernetes version %s to version %s is not supported. To see a list of available upgrades, use 'aks-engine get-versions --version %s'", uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion, uc.upgradeVersion, uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion)
+	if err := aksengine.ValidateUpgradeVersion(uc.containerService, uc.upgradeVersion); err != nil {
+		return errors.Errorf("%s. To see a list of available upgrades, use 'aks-engine get-versions --version %s'", err, uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion)
 	}
 	return nil
 }
@@ -217,17 +210,6 @@ func (uc *upgradeCmd) initialize() error {
 		}
 	}
 	uc.containerService.Properties.OrchestratorProfile.OrchestratorVersion = uc.upgradeVersion
-
-	//allows to identify VMs in the resource group that belong to this cluster.
-	uc.nameSuffix = uc.containerService.Properties.GetClusterID()
-
-	log.Infoln(fmt.Sprintf("Upgrading cluster with name suffix: %s", uc.nameSuffix))
-
-	uc.agentPoolsToUpgrade = make(map[string]bool)
-	uc.agentPoolsToUpgrade[kubernetesupgrade.MasterPoolName] = true
-	for _, agentPool := range uc.containerService.Properties.AgentPoolProfiles {
-		uc.agentPoolsToUpgrade[agentPool.Name] = true
-	}
 	return nil
 }
 
@@ -242,49 +224,24 @@ func (uc *upgradeCmd) run(cmd *cobra.Command, args []string) error {
 		return errors.Wrap(err, "loading existing cluster")
 	}
 
-	upgradeCluster := kubernetesupgrade.UpgradeCluster{
-		Translator: &i18n.Translator{
-			Locale: uc.locale,
-		},
-		Logger:             log.NewEntry(log.New()),
-		Client:             uc.client,
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = aksengine.Upgrade(ctx, uc.client, log.NewEntry(log.New()), aksengine.UpgradeOptions{
+		ContainerService:   uc.containerService,
+		APIVersion:         uc.apiVersion,
+		APIModelPath:       uc.apiModelPath,
+		SubscriptionID:     uc.getAuthArgs().SubscriptionID.String(),
+		ResourceGroup:      uc.resourceGroupName,
+		Version:            uc.upgradeVersion,
+		Force:              uc.force,
 		StepTimeout:        uc.timeout,
 		CordonDrainTimeout: uc.cordonDrainTimeout,
-	}
-
-	upgradeCluster.ClusterTopology = kubernetesupgrade.ClusterTopology{}
-	upgradeCluster.SubscriptionID = uc.getAuthArgs().SubscriptionID.String()
-	upgradeCluster.ResourceGroup = uc.resourceGroupName
-	upgradeCluster.DataModel = uc.containerService
-	upgradeCluster.NameSuffix = uc.nameSuffix
-	upgradeCluster.AgentPoolsToUpgrade = uc.agentPoolsToUpgrade
-	upgradeCluster.Force = uc.force
-
-	kubeConfig, err := engine.GenerateKubeConfig(uc.containerService.Properties, uc.location)
-	if err != nil {
-		return errors.Wrap(err, "generating kubeconfig")
-	}
-
-	if err = upgradeCluster.UpgradeCluster(uc.client, kubeConfig, BuildTag); err != nil {
-		return errors.Wrap(err, "upgrading cluster")
-	}
-
-	// Save the new apimodel to reflect the cluster's state.
-	apiloader := &api.Apiloader{
+		Secrets:            uc.secretStore,
+		BuildTag:           BuildTag,
 		Translator: &i18n.Translator{
 			Locale: uc.locale,
 		},
-	}
-	b, err := apiloader.SerializeContainerService(uc.containerService, uc.apiVersion)
-	if err != nil {
-		return err
-	}
-
-	f := helpers.FileSaver{
-		Translator: &i18n.Translator{
-			Locale: uc.locale,
-		},
-	}
-	dir, file := filepath.Split(uc.apiModelPath)
-	return f.SaveFile(dir, file, b)
+	})
+	return err
 }
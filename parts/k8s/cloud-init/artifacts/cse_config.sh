@@ -185,9 +185,11 @@ configureK8s() {
     "vmType": "${VM_TYPE}",
     "subnetName": "${SUBNET}",
     "securityGroupName": "${NETWORK_SECURITY_GROUP}",
+    "securityGroupResourceGroup": "${NETWORK_SECURITY_GROUP_RESOURCE_GROUP}",
     "vnetName": "${VIRTUAL_NETWORK}",
     "vnetResourceGroup": "${VIRTUAL_NETWORK_RESOURCE_GROUP}",
     "routeTableName": "${ROUTE_TABLE}",
+    "routeTableResourceGroup": "${ROUTE_TABLE_RESOURCE_GROUP}",
     "primaryAvailabilitySetName": "${PRIMARY_AVAILABILITY_SET}",
     "primaryScaleSetName": "${PRIMARY_SCALE_SET}",
     "cloudProviderBackoff": ${CLOUDPROVIDER_BACKOFF},
@@ -202,6 +204,7 @@ configureK8s() {
     "userAssignedIdentityID": "${USER_ASSIGNED_IDENTITY_ID}",
     "useInstanceMetadata": ${USE_INSTANCE_METADATA},
     "loadBalancerSku": "${LOAD_BALANCER_SKU}",
+    "outboundType": "${OUTBOUND_TYPE}",
     "excludeMasterFromStandardLB": ${EXCLUDE_MASTER_FROM_STANDARD_LB},
     "providerVaultName": "${KMS_PROVIDER_VAULT_NAME}",
     "maximumLoadBalancerRuleCount": ${MAXIMUM_LOADBALANCER_RULE_COUNT},
@@ -438,6 +441,30 @@ configACIConnectorAddon() {
     sed -i "s|<key>|$ACI_CONNECTOR_KEY|g" $ACI_CONNECTOR_ADDON_FILE
 }
 
+configGMSAWebhookAddon() {
+    # every master signs its own serving pair with the cluster CA, the gmsa-webhook-secret is EnsureExists so that
+    # the first pair the addon managers create is kept rather than overwritten by the other masters
+    GMSA_WEBHOOK_KEY_PATH=/etc/kubernetes/certs/gmsa-webhook.key
+    GMSA_WEBHOOK_CERT_PATH=/etc/kubernetes/certs/gmsa-webhook.crt
+    GMSA_WEBHOOK_CSR_PATH=$(mktemp)
+    GMSA_WEBHOOK_EXT_PATH=$(mktemp)
+    echo "subjectAltName=DNS:gmsa-webhook.kube-system.svc" > $GMSA_WEBHOOK_EXT_PATH
+    openssl genrsa -out $GMSA_WEBHOOK_KEY_PATH 2048
+    chmod 0600 $GMSA_WEBHOOK_KEY_PATH
+    openssl req -new -key $GMSA_WEBHOOK_KEY_PATH -out $GMSA_WEBHOOK_CSR_PATH -subj "/CN=gmsa-webhook.kube-system.svc"
+    openssl x509 -req -days 730 -in $GMSA_WEBHOOK_CSR_PATH -CA /etc/kubernetes/certs/ca.crt -CAkey /etc/kubernetes/certs/ca.key -CAcreateserial -extfile $GMSA_WEBHOOK_EXT_PATH -out $GMSA_WEBHOOK_CERT_PATH
+    rm -f $GMSA_WEBHOOK_CSR_PATH $GMSA_WEBHOOK_EXT_PATH
+    GMSA_WEBHOOK_KEY=$(base64 $GMSA_WEBHOOK_KEY_PATH -w0)
+    GMSA_WEBHOOK_CERT=$(base64 $GMSA_WEBHOOK_CERT_PATH -w0)
+    GMSA_WEBHOOK_CA_BUNDLE=$(base64 /etc/kubernetes/certs/ca.crt -w0)
+
+    GMSA_WEBHOOK_ADDON_FILE=/etc/kubernetes/addons/gmsa-webhook-deployment.yaml
+    wait_for_file 1200 1 $GMSA_WEBHOOK_ADDON_FILE || exit $ERR_FILE_WATCH_TIMEOUT
+    sed -i "s|<cert>|$GMSA_WEBHOOK_CERT|g" $GMSA_WEBHOOK_ADDON_FILE
+    sed -i "s|<key>|$GMSA_WEBHOOK_KEY|g" $GMSA_WEBHOOK_ADDON_FILE
+    sed -i "s|<caBundle>|$GMSA_WEBHOOK_CA_BUNDLE|g" $GMSA_WEBHOOK_ADDON_FILE
+}
+
 configAddons() {
     if [[ "${CLUSTER_AUTOSCALER_ADDON}" = True ]]; then
         configClusterAutoscalerAddon
@@ -446,6 +473,10 @@ configAddons() {
     if [[ "${ACI_CONNECTOR_ADDON}" = True ]]; then
         configACIConnectorAddon
     fi
+
+    if [[ "${GMSA_WEBHOOK_ADDON}" = true ]]; then
+        configGMSAWebhookAddon
+    fi
 }
 
 configGPUDrivers() {
@@ -48,7 +48,10 @@ param(
 
     [parameter(Mandatory=$true)]
     [ValidateNotNullOrEmpty()]
-    $TargetEnvironment
+    $TargetEnvironment,
+
+    [parameter()]
+    $DomainJoinPassword # base64
 )
 
 
@@ -71,6 +74,21 @@ $global:KubeBinariesVersion = "{{WrapAsParameter "kubeBinariesVersion"}}"
 ## Docker Version
 $global:DockerVersion = "{{WrapAsParameter "windowsDockerVersion"}}"
 
+## Container runtime
+$global:ContainerRuntime = "{{WrapAsParameter "containerRuntime"}}"
+{{if NeedsContainerd}}
+$global:ContainerdURL = "{{WrapAsParameter "windowsContainerdURL"}}"
+{{end}}
+$global:WindowsIsolation = "{{.GetWindowsIsolation}}"
+$global:PrePullImages = @({{GetWindowsPrePullImages}})
+{{with GetWindowsDomainJoin}}
+
+## Active Directory domain the node joins, the password is passed as a parameter
+$global:DomainName = "{{.Domain}}"
+$global:DomainOrganizationalUnit = "{{.OrganizationalUnit}}"
+$global:DomainUserName = "{{.UserName}}"
+{{end}}
+
 ## VM configuration passed by Azure
 $global:WindowsTelemetryGUID = "{{WrapAsParameter "windowsTelemetryGUID"}}"
 {{if eq GetIdentitySystem "adfs"}}
@@ -84,8 +102,10 @@ $global:VmType = "{{WrapAsVariable "vmType"}}"
 $global:SubnetName = "{{WrapAsVariable "subnetName"}}"
 $global:MasterSubnet = "{{GetWindowsMasterSubnetARMParam}}"
 $global:SecurityGroupName = "{{WrapAsVariable "nsgName"}}"
+$global:SecurityGroupResourceGroup = "{{WrapAsVariable "nsgResourceGroupName"}}"
 $global:VNetName = "{{WrapAsVariable "virtualNetworkName"}}"
 $global:RouteTableName = "{{WrapAsVariable "routeTableName"}}"
+$global:RouteTableResourceGroup = "{{WrapAsVariable "routeTableResourceGroupName"}}"
 $global:PrimaryAvailabilitySetName = "{{WrapAsVariable "primaryAvailabilitySetName"}}"
 $global:PrimaryScaleSetName = "{{WrapAsVariable "primaryScaleSetName"}}"
 
@@ -104,6 +124,7 @@ $global:UserAssignedClientID = "{{WrapAsVariable "userAssignedClientID"}}"
 $global:UseInstanceMetadata = "{{WrapAsVariable "useInstanceMetadata"}}"
 
 $global:LoadBalancerSku = "{{WrapAsVariable "loadBalancerSku"}}"
+$global:OutboundType = "{{WrapAsVariable "outboundType"}}"
 $global:ExcludeMasterFromStandardLB = "{{WrapAsVariable "excludeMasterFromStandardLB"}}"
 
 
@@ -142,13 +163,18 @@ Expand-Archive scripts.zip -DestinationPath "C:\\AzureData\\"
 . c:\AzureData\k8s\windowscnifunc.ps1
 . c:\AzureData\k8s\windowsazurecnifunc.ps1
 . c:\AzureData\k8s\windowsinstallopensshfunc.ps1
+. c:\AzureData\k8s\windowscontainerdfunc.ps1
 
 function
 Update-ServiceFailureActions()
 {
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerRuntime
+    )
     sc.exe failure "kubelet" actions= restart/60000/restart/60000/restart/60000 reset= 900
     sc.exe failure "kubeproxy" actions= restart/60000/restart/60000/restart/60000 reset= 900
-    sc.exe failure "docker" actions= restart/60000/restart/60000/restart/60000 reset= 900
+    sc.exe failure $ContainerRuntime actions= restart/60000/restart/60000/restart/60000 reset= 900
 }
 
 try
@@ -172,8 +198,18 @@ try
         Write-Log "Create required data directories as needed"
         Initialize-DataDirectories
 
+        {{if NeedsContainerd}}
+        Write-Log "Install containerd"
+        Install-Containerd -ContainerdURL $global:ContainerdURL
+
+        if ($global:WindowsIsolation -eq "hyperv") {
+            Write-Log "Enable Hyper-V to isolate the pods"
+            Enable-HyperV
+        }
+        {{else}}
         Write-Log "Install docker"
         Install-Docker -DockerVersion $global:DockerVersion
+        {{end}}
 
         Write-Log "Download kubelet binaries and unzip"
         Get-KubePackage -KubeBinariesSASURL $global:KubeBinariesPackageSASURL
@@ -200,14 +236,17 @@ try
             -VmType $global:VmType `
             -SubnetName $global:SubnetName `
             -SecurityGroupName $global:SecurityGroupName `
+            -SecurityGroupResourceGroup $global:SecurityGroupResourceGroup `
             -VNetName $global:VNetName `
             -RouteTableName $global:RouteTableName `
+            -RouteTableResourceGroup $global:RouteTableResourceGroup `
             -PrimaryAvailabilitySetName $global:PrimaryAvailabilitySetName `
             -PrimaryScaleSetName $global:PrimaryScaleSetName `
             -UseManagedIdentityExtension $global:UseManagedIdentityExtension `
             -UserAssignedClientID $global:UserAssignedClientID `
             -UseInstanceMetadata $global:UseInstanceMetadata `
             -LoadBalancerSku $global:LoadBalancerSku `
+            -OutboundType $global:OutboundType `
             -ExcludeMasterFromStandardLB $global:ExcludeMasterFromStandardLB `
             -TargetEnvironment $TargetEnvironment
 
@@ -230,8 +269,10 @@ try
                          -AgentCertificate $global:AgentCertificate
 
 
+        {{if not NeedsContainerd}}
         Write-Log "Create the Pause Container kubletwin/pause"
         New-InfraContainer -KubeDir $global:KubeDir
+        {{end}}
 
         Write-Log "Configuring networking with NetworkPlugin:$global:NetworkPlugin"
 
@@ -265,6 +306,25 @@ try
             Get-HnsPsm1 -HNSModule $global:HNSModule
         }
 
+        {{if NeedsContainerd}}
+        Write-Log "Configure and start containerd with $global:WindowsIsolation isolation"
+        if ($global:NetworkPlugin -eq "azure") {
+            Set-ContainerdConfig -CNIBinDir $global:AzureCNIBinDir `
+                                 -CNIConfDir $global:AzureCNIConfDir `
+                                 -WindowsIsolation $global:WindowsIsolation
+        } else {
+            Set-ContainerdConfig -CNIBinDir $global:CNIPath `
+                                 -CNIConfDir $global:CNIConfigPath `
+                                 -WindowsIsolation $global:WindowsIsolation
+        }
+        Start-ContainerdService
+        {{end}}
+
+        if ($global:PrePullImages.Count -gt 0) {
+            Write-Log "Pre-pull container images"
+            Get-ContainerImages -ContainerRuntime $global:ContainerRuntime -Images $global:PrePullImages
+        }
+
         Write-Log "Write kubelet startfile with pod CIDR of $podCIDR"
         Install-KubernetesServices `
             -KubeletConfigArgs $global:KubeletConfigArgs `
@@ -283,7 +343,8 @@ try
             -KubeClusterCIDR $global:KubeClusterCIDR `
             -KubeServiceCIDR $global:KubeServiceCIDR `
             -HNSModule $global:HNSModule `
-            -KubeletNodeLabels $global:KubeletNodeLabels
+            -KubeletNodeLabels $global:KubeletNodeLabels `
+            -ContainerRuntime $global:ContainerRuntime
 
         # Install OpenSSH if SSH enabled
         $sshEnabled = [System.Convert]::ToBoolean("{{ WindowsSSHEnabled }}")
@@ -302,7 +363,15 @@ try
         PREPROVISION_EXTENSION
 
         Write-Log "Update service failure actions"
-        Update-ServiceFailureActions
+        Update-ServiceFailureActions -ContainerRuntime $global:ContainerRuntime
+
+        {{if HasWindowsDomainJoin}}
+        Write-Log "Join the Active Directory domain $global:DomainName"
+        Join-Domain -DomainName $global:DomainName `
+                    -OrganizationalUnit $global:DomainOrganizationalUnit `
+                    -UserName $global:DomainUserName `
+                    -Password $([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($DomainJoinPassword)))
+        {{end}}
 
         Write-Log "Setup Complete, reboot computer"
         Restart-Computer
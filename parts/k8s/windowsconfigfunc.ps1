@@ -82,6 +82,67 @@ function Install-Docker
     }
 }
 
+function Get-ContainerImages
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerRuntime,
+        [Parameter(Mandatory=$true)][string[]]
+        $Images
+    )
+
+    foreach ($image in $Images) {
+        Write-Log "Pre-pulling container image $image"
+        for ($i = 0; $i -lt 5; $i++) {
+            if ($ContainerRuntime -eq "containerd") {
+                & ctr.exe -n k8s.io images pull $image
+            } else {
+                & docker pull $image
+            }
+            if ($LASTEXITCODE -eq 0) {
+                break
+            }
+            Start-Sleep -Seconds 10
+        }
+        if ($LASTEXITCODE -ne 0) {
+            # not fatal, the kubelet pulls the image when a pod needs it
+            Write-Log "Failed to pre-pull container image $image"
+        }
+    }
+}
+
+function Join-Domain
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $DomainName,
+        [Parameter(Mandatory=$false)][string]
+        $OrganizationalUnit,
+        [Parameter(Mandatory=$true)][string]
+        $UserName,
+        [Parameter(Mandatory=$true)][string]
+        $Password
+    )
+
+    # a plain account name is qualified with the domain, a UPN or down-level name is used as is
+    if ($UserName -notmatch "[\\@]") {
+        $UserName = "$DomainName\$UserName"
+    }
+    $securePassword = ConvertTo-SecureString -String $Password -AsPlainText -Force
+    $credential = New-Object System.Management.Automation.PSCredential($UserName, $securePassword)
+
+    $joinParams = @{
+        DomainName = $DomainName
+        Credential = $credential
+        Force = $true
+    }
+    if ($OrganizationalUnit) {
+        $joinParams.OUPath = $OrganizationalUnit
+    }
+    # the node is restarted at the end of the setup, which completes the join
+    Add-Computer @joinParams -ErrorAction Stop
+}
+
 # Pagefile adjustments
 function Adjust-PageFileSize()
 {
@@ -0,0 +1,104 @@
+$global:ContainerdInstallLocation = "$Env:ProgramFiles\containerd"
+
+function Install-Containerd
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerdURL
+    )
+
+    $tempdir = New-TemporaryDirectory
+    $containerdPackage = [Io.path]::Combine($tempdir, "containerd.tar.gz")
+    for ($i = 0; $i -le 10; $i++) {
+        DownloadFileOverHttp -Url $ContainerdURL -DestinationPath $containerdPackage
+        if ($?) {
+            break
+        }
+        else {
+            Write-Log $Error[0].Exception.Message
+        }
+    }
+
+    # using tar to minimize dependencies
+    tar -xzf $containerdPackage -C $tempdir
+
+    if (!(Test-Path $global:ContainerdInstallLocation)) {
+        mkdir $global:ContainerdInstallLocation
+    }
+    # the release packages keep the binaries under bin, look them up wherever they are
+    Get-ChildItem -Path $tempdir -Recurse -Include containerd.exe, containerd-shim-runhcs-v1.exe, ctr.exe | Copy-Item -Destination $global:ContainerdInstallLocation -Force
+
+    # remove temp folder created when unzipping
+    del $tempdir -Recurse
+
+    # the kubelet start script cleans up the containers with ctr.exe
+    $machinePath = [System.Environment]::GetEnvironmentVariable("Path", [System.EnvironmentVariableTarget]::Machine)
+    if (-not $machinePath.Contains($global:ContainerdInstallLocation)) {
+        [System.Environment]::SetEnvironmentVariable("Path", "$machinePath;$global:ContainerdInstallLocation", [System.EnvironmentVariableTarget]::Machine)
+    }
+    $env:Path += ";$global:ContainerdInstallLocation"
+}
+
+function Set-ContainerdConfig
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $CNIBinDir,
+        [Parameter(Mandatory=$true)][string]
+        $CNIConfDir,
+        [Parameter(Mandatory=$true)][ValidateSet("process", "hyperv")][string]
+        $WindowsIsolation,
+        # multi-arch image running on all the Windows Server versions with process isolation
+        [string]
+        $PauseImage = "mcr.microsoft.com/oss/kubernetes/pause:1.4.0"
+    )
+
+    # Both runtime handlers are declared so that RuntimeClasses can pick either isolation, the pods
+    # of the agent pool not asking for one get the isolation of the pool.
+    $defaultRuntime = "runhcs-wcow-process"
+    if ($WindowsIsolation -eq "hyperv") {
+        $defaultRuntime = "runhcs-wcow-hypervisor"
+    }
+
+    # backslashes are escaped in TOML strings
+    $config = @"
+version = 2
+root = "C:\\ProgramData\\containerd\\root"
+state = "C:\\ProgramData\\containerd\\state"
+
+[plugins]
+  [plugins."io.containerd.grpc.v1.cri"]
+    sandbox_image = "$PauseImage"
+    [plugins."io.containerd.grpc.v1.cri".containerd]
+      snapshotter = "windows"
+      default_runtime_name = "$defaultRuntime"
+      [plugins."io.containerd.grpc.v1.cri".containerd.runtimes]
+        [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-process]
+          runtime_type = "io.containerd.runhcs.v1"
+        [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-hypervisor]
+          runtime_type = "io.containerd.runhcs.v1"
+          [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-hypervisor.options]
+            SandboxIsolation = 1
+    [plugins."io.containerd.grpc.v1.cri".cni]
+      bin_dir = "$($CNIBinDir.Replace("\", "\\"))"
+      conf_dir = "$($CNIConfDir.Replace("\", "\\"))"
+"@
+
+    $configFile = [Io.path]::Combine($global:ContainerdInstallLocation, "config.toml")
+    $config | Out-File -encoding ASCII -filepath $configFile
+}
+
+function Start-ContainerdService
+{
+    $configFile = [Io.path]::Combine($global:ContainerdInstallLocation, "config.toml")
+    & "$global:ContainerdInstallLocation\containerd.exe" --register-service --config $configFile
+    Set-Service -Name containerd -StartupType Automatic
+    Start-Service containerd
+}
+
+function Enable-HyperV
+{
+    # pods isolated with Hyper-V run in utility VMs, the VM size of the agent pool must support nested
+    # virtualization. The feature is enabled by the reboot ending the provisioning.
+    Install-WindowsFeature -Name Hyper-V
+}
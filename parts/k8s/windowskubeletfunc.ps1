@@ -21,9 +21,13 @@ Write-AzureConfig {
         [Parameter(Mandatory = $true)][string]
         $SecurityGroupName,
         [Parameter(Mandatory = $true)][string]
+        $SecurityGroupResourceGroup,
+        [Parameter(Mandatory = $true)][string]
         $VNetName,
         [Parameter(Mandatory = $true)][string]
         $RouteTableName,
+        [Parameter(Mandatory = $true)][string]
+        $RouteTableResourceGroup,
         [Parameter(Mandatory = $false)][string] # Need one of these configured
         $PrimaryAvailabilitySetName,
         [Parameter(Mandatory = $false)][string] # Need one of these configured
@@ -37,6 +41,8 @@ Write-AzureConfig {
         [Parameter(Mandatory = $true)][string]
         $LoadBalancerSku,
         [Parameter(Mandatory = $true)][string]
+        $OutboundType,
+        [Parameter(Mandatory = $true)][string]
         $ExcludeMasterFromStandardLB,
         [Parameter(Mandatory = $true)][string]
         $KubeDir,
@@ -62,14 +68,17 @@ Write-AzureConfig {
     "vmType": "$VmType",
     "subnetName": "$SubnetName",
     "securityGroupName": "$SecurityGroupName",
+    "securityGroupResourceGroup": "$SecurityGroupResourceGroup",
     "vnetName": "$VNetName",
     "routeTableName": "$RouteTableName",
+    "routeTableResourceGroup": "$RouteTableResourceGroup",
     "primaryAvailabilitySetName": "$PrimaryAvailabilitySetName",
     "primaryScaleSetName": "$PrimaryScaleSetName",
     "useManagedIdentityExtension": $UseManagedIdentityExtension,
     "userAssignedIdentityID": $UserAssignedClientID,
     "useInstanceMetadata": $UseInstanceMetadata,
     "loadBalancerSku": "$LoadBalancerSku",
+    "outboundType": "$OutboundType",
     "excludeMasterFromStandardLB": $ExcludeMasterFromStandardLB
 }
 "@
@@ -238,7 +247,10 @@ New-NSSMService {
         $KubeletStartFile,
         [string]
         [Parameter(Mandatory = $true)]
-        $KubeProxyStartFile
+        $KubeProxyStartFile,
+        # the service of the container runtime is named after it
+        [string]
+        $ContainerRuntime = "docker"
     )
 
     # setup kubelet
@@ -247,7 +259,7 @@ New-NSSMService {
     & "$KubeDir\nssm.exe" set Kubelet AppParameters $KubeletStartFile
     & "$KubeDir\nssm.exe" set Kubelet DisplayName Kubelet
     & "$KubeDir\nssm.exe" set Kubelet AppRestartDelay 5000
-    & "$KubeDir\nssm.exe" set Kubelet DependOnService docker
+    & "$KubeDir\nssm.exe" set Kubelet DependOnService $ContainerRuntime
     & "$KubeDir\nssm.exe" set Kubelet Description Kubelet
     & "$KubeDir\nssm.exe" set Kubelet Start SERVICE_AUTO_START
     & "$KubeDir\nssm.exe" set Kubelet ObjectName LocalSystem
@@ -318,7 +330,9 @@ Install-KubernetesServices {
         [Parameter(Mandatory = $true)][string]
         $HNSModule,
         [Parameter(Mandatory = $true)][string]
-        $KubeletNodeLabels
+        $KubeletNodeLabels,
+        [string]
+        $ContainerRuntime = "docker"
     )
 
     # Calculate some local paths
@@ -356,6 +370,19 @@ Install-KubernetesServices {
         throw "Unknown network type $NetworkPlugin, can't configure kubelet"
     }
 
+    # containerd gets the CNI plugins from its own configuration, written by Set-ContainerdConfig
+    if ($ContainerRuntime -eq "containerd") {
+        $KubeletArgList += @("--container-runtime=remote", "--runtime-request-timeout=15m", "--container-runtime-endpoint=npipe:////./pipe/containerd-containerd")
+    }
+
+    # Containers left over by a restart are removed with the CLI of the container runtime
+    if ($ContainerRuntime -eq "containerd") {
+        $CleanupContainers = "ctr.exe -n k8s.io containers ls -q | foreach {ctr.exe -n k8s.io tasks rm -f `$_; ctr.exe -n k8s.io containers rm `$_}"
+    }
+    else {
+        $CleanupContainers = "docker ps -q | foreach {docker rm `$_ -f}"
+    }
+
     # Used in WinCNI version of kubeletstart.ps1
     $KubeletArgListStr = ""
     $KubeletArgList | Foreach-Object {
@@ -417,7 +444,7 @@ if (!`$hnsNetwork)
 if (`$hnsNetwork)
 {
     # Cleanup all containers
-    docker ps -q | foreach {docker rm `$_ -f}
+    $CleanupContainers
 
     Write-Host "Cleaning up old HNS network found"
     Remove-HnsNetwork `$hnsNetwork
@@ -563,7 +590,7 @@ try
     {
         # Kubelet has been restarted with existing network.
         # Cleanup all containers
-        docker ps -q | foreach {docker rm `$_ -f}
+        $CleanupContainers
         # cleanup network
         Write-Host "Cleaning up old HNS network found"
         Remove-HnsNetwork `$hnsNetwork
@@ -619,5 +646,6 @@ $KubeDir\kube-proxy.exe --v=3 --proxy-mode=kernelspace --hostname-override=$env:
 
     New-NSSMService -KubeDir $KubeDir `
         -KubeletStartFile $KubeletStartFile `
-        -KubeProxyStartFile $KubeProxyStartFile
+        -KubeProxyStartFile $KubeProxyStartFile `
+        -ContainerRuntime $ContainerRuntime
 }
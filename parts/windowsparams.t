@@ -29,6 +29,22 @@
       },
       "type": "string"
     },
+  {{if NeedsContainerd}}
+    "windowsContainerdURL": {
+      "metadata": {
+        "description": "The download url for the containerd binaries of windows nodes"
+      },
+      "type": "string"
+    },
+  {{end}}
+  {{if HasWindowsDomainJoin}}
+    "windowsDomainJoinPassword": {
+      "metadata": {
+        "description": "Password of the account joining the windows nodes to the Active Directory domain."
+      },
+      "type": "securestring"
+    },
+  {{end}}
  {{end}}
     "windowsAdminUsername": {
       "type": "string",
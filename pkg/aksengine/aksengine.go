@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/pkg/errors"
+)
+
+// DefaultOutputDirectory returns the directory the artifacts of cs are written to when none is given,
+// _output/<DNS prefix>
+func DefaultOutputDirectory(cs *api.ContainerService) string {
+	if cs.Properties.MasterProfile != nil {
+		return path.Join("_output", cs.Properties.MasterProfile.DNSPrefix)
+	}
+	return path.Join("_output", cs.Properties.HostedMasterProfile.DNSPrefix)
+}
+
+// translatorOrDefault returns translator, or a translator leaving messages untranslated when it is nil
+func translatorOrDefault(translator *i18n.Translator) *i18n.Translator {
+	if translator == nil {
+		return &i18n.Translator{}
+	}
+	return translator
+}
+
+// artifactWriter returns the writer of the artifacts of an api model whose secrets are kept in secrets
+func artifactWriter(translator *i18n.Translator, secrets *api.SecretStore) *engine.ArtifactWriter {
+	writer := &engine.ArtifactWriter{
+		Translator: translator,
+	}
+	if secrets != nil && secrets.InUse() {
+		writer.Secrets = secrets
+	}
+	return writer
+}
+
+// generateTemplate generates the ARM template and parameters of cs, which must have its defaults set. Key Vault
+// references are resolved by Azure Resource Manager, so the parameters refer to the secrets in the vault rather
+// than holding them.
+func generateTemplate(cs *api.ContainerService, secrets *api.SecretStore, translator *i18n.Translator, buildTag string) (string, string, error) {
+	templateGenerator, err := engine.InitializeTemplateGenerator(engine.Context{Translator: translator})
+	if err != nil {
+		return "", "", errors.Wrap(err, "initializing template generator")
+	}
+
+	resolvedByARM := secrets != nil && secrets.ResolvedByARM()
+	if resolvedByARM {
+		if err = secrets.Externalize(cs); err != nil {
+			return "", "", errors.Wrap(err, "putting the secrets of the api model in Key Vault")
+		}
+	}
+
+	template, parameters, err := templateGenerator.GenerateTemplateV2(cs, engine.DefaultGeneratorCode, buildTag)
+	if err != nil {
+		return "", "", errors.Wrap(err, "generating template")
+	}
+
+	if resolvedByARM {
+		if err = secrets.Resolve(cs); err != nil {
+			return "", "", errors.Wrap(err, "resolving the secrets of the api model")
+		}
+	}
+	return template, parameters, nil
+}
+
+// prettyPrint indents template and turns parameters into an Azure parameters file
+func prettyPrint(template, parameters string) (string, string, error) {
+	template, err := transform.PrettyPrintArmTemplate(template)
+	if err != nil {
+		return "", "", errors.Wrap(err, "pretty-printing template")
+	}
+	if parameters, err = transform.BuildAzureParametersFile(parameters); err != nil {
+		return "", "", errors.Wrap(err, "pretty-printing template parameters")
+	}
+	return template, parameters, nil
+}
+
+// saveAPIModel writes cs to apiModelPath in apiVersion. The secrets kept in secrets are put back where they came
+// from, so that the saved api model keeps referring to them.
+func saveAPIModel(cs *api.ContainerService, apiVersion, apiModelPath string, secrets *api.SecretStore, translator *i18n.Translator) error {
+	if secrets != nil && secrets.InUse() {
+		if err := secrets.Externalize(cs); err != nil {
+			return errors.Wrap(err, "putting back the secrets of the api model")
+		}
+	}
+	apiloader := &api.Apiloader{
+		Translator: translator,
+	}
+	b, err := apiloader.SerializeContainerService(cs, apiVersion)
+	if err != nil {
+		return err
+	}
+	f := helpers.FileSaver{
+		Translator: translator,
+	}
+	dir, file := filepath.Split(apiModelPath)
+	return f.SaveFile(dir, file, b)
+}
+
+// newDeploymentName returns a name for a new deployment to resourceGroup
+func newDeploymentName(resourceGroup string) string {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return fmt.Sprintf("%s-%d", resourceGroup, random.Int31())
+}
@@ -0,0 +1,297 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/graphrbac/1.6/graphrbac"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// emptyClientID is the client ID of a service principal left unset
+const emptyClientID = "00000000-0000-0000-0000-000000000000"
+
+// DeployOptions are the inputs of Deploy
+type DeployOptions struct {
+	// ContainerService is the api model of the cluster to deploy, it is filled in and defaulted by Deploy
+	ContainerService *api.ContainerService
+	// APIVersion is the version of the API the api model is written in, only vlabs api models are validated
+	APIVersion string
+	// ResourceGroup is the resource group to deploy to, created when it does not exist. The DNS prefix of the
+	// cluster when empty.
+	ResourceGroup string
+	// Location is the location of the resource group when it is created
+	Location string
+	// DNSPrefix is the DNS prefix of a cluster whose api model has none
+	DNSPrefix string
+	// AutoSuffix appends a compressed timestamp to the DNS prefix, to make it unique
+	AutoSuffix bool
+	// OutputDirectory is where the template, parameters, certificates, SSH key and api model are written,
+	// _output/<DNS prefix> when empty
+	OutputDirectory string
+	// ForceOverwrite overwrites the files of an existing output directory
+	ForceOverwrite bool
+	// ParametersOnly writes the parameters and the api model, but not the template
+	ParametersOnly bool
+	// ClientID and ClientSecret are the service principal of a cluster whose api model has none. A service
+	// principal is created when they are empty too, unless the cluster uses managed identities.
+	ClientID     string
+	ClientSecret string
+	// Secrets, when set, keeps the secrets of the api model in a secret provider
+	Secrets *api.SecretStore
+	// BuildTag is the version of aks-engine the template is stamped with
+	BuildTag   string
+	Translator *i18n.Translator
+}
+
+// DeployResult is the output of Deploy
+type DeployResult struct {
+	ResourceGroup   string
+	DeploymentName  string
+	OutputDirectory string
+}
+
+// Deploy fills in the api model, generates its ARM template and parameters, writes them to the output directory
+// along with the certificates and the api model, and deploys the template to the resource group.
+func Deploy(ctx context.Context, client armhelpers.AKSEngineClient, logger *logrus.Entry, options DeployOptions) (*DeployResult, error) {
+	translator := translatorOrDefault(options.Translator)
+	cs := options.ContainerService
+
+	if err := AutofillAPIModel(ctx, client, logger, &options); err != nil {
+		return nil, err
+	}
+
+	if options.APIVersion == vlabs.APIVersion {
+		if err := api.ConvertContainerServiceToVLabs(cs).Validate(false); err != nil {
+			return nil, errors.Wrap(err, "validating API model after populating values")
+		}
+	} else {
+		logger.Warnf("API model validation is only available for \"apiVersion\": \"vlabs\", skipping validation...")
+	}
+
+	if err := validateImageOSTypes(ctx, client, cs); err != nil {
+		return nil, err
+	}
+
+	certsGenerated, err := cs.SetPropertiesDefaults(false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "setting the defaults of the api model")
+	}
+
+	template, parameters, err := generateTemplate(cs, options.Secrets, translator, options.BuildTag)
+	if err != nil {
+		return nil, err
+	}
+	// the parameters file is written, while the parameters themselves are deployed
+	template, parametersFile, err := prettyPrint(template, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := artifactWriter(translator, options.Secrets)
+	if err = writer.WriteTLSArtifacts(cs, options.APIVersion, template, parametersFile, options.OutputDirectory, certsGenerated, options.ParametersOnly); err != nil {
+		return nil, errors.Wrap(err, "writing artifacts")
+	}
+
+	templateJSON := make(map[string]interface{})
+	parametersJSON := make(map[string]interface{})
+
+	if err = json.Unmarshal([]byte(template), &templateJSON); err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(parameters), &parametersJSON); err != nil {
+		return nil, err
+	}
+
+	deploymentName := newDeploymentName(options.ResourceGroup)
+	if res, err := client.DeployTemplate(ctx, options.ResourceGroup, deploymentName, templateJSON, parametersJSON); err != nil {
+		if res.Response.Response != nil && res.Body != nil {
+			defer res.Body.Close()
+			body, _ := ioutil.ReadAll(res.Body)
+			logger.Error(string(body))
+		}
+		return nil, err
+	}
+
+	return &DeployResult{
+		ResourceGroup:   options.ResourceGroup,
+		DeploymentName:  deploymentName,
+		OutputDirectory: options.OutputDirectory,
+	}, nil
+}
+
+// validateImageOSTypes checks that the managed and Shared Image Gallery images of the masters and the agent pools
+// hold the operating system of the nodes they provision
+func validateImageOSTypes(ctx context.Context, client armhelpers.AKSEngineClient, cs *api.ContainerService) error {
+	p := cs.Properties
+	if p.MasterProfile != nil && p.MasterProfile.HasImageRef() {
+		if err := validateImageOSType(ctx, client, p.MasterProfile.ImageRef, api.Linux, "the masters"); err != nil {
+			return err
+		}
+	}
+	for _, profile := range p.AgentPoolProfiles {
+		imageRef, osType := profile.ImageRef, api.Linux
+		if profile.IsWindows() {
+			imageRef, osType = p.GetWindowsImageRef(profile), api.Windows
+		}
+		if imageRef == nil || imageRef.Name == "" || imageRef.ResourceGroup == "" {
+			continue
+		}
+		if err := validateImageOSType(ctx, client, imageRef, osType, fmt.Sprintf("agent pool %s", profile.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateImageOSType(ctx context.Context, client armhelpers.AKSEngineClient, imageRef *api.ImageReference, osType api.OSType, nodes string) error {
+	var imageOSType compute.OperatingSystemTypes
+	if imageRef.IsGalleryImage() {
+		image, err := client.GetGalleryImage(ctx, imageRef.SubscriptionID, imageRef.ResourceGroup, imageRef.Gallery, imageRef.Name)
+		if err != nil {
+			return errors.Wrapf(err, "getting the Shared Image Gallery image %s of %s", imageRef.Name, nodes)
+		}
+		if image.GalleryImageProperties != nil {
+			imageOSType = image.OsType
+		}
+	} else {
+		image, err := client.GetImage(ctx, imageRef.ResourceGroup, imageRef.Name)
+		if err != nil {
+			return errors.Wrapf(err, "getting the image %s of %s", imageRef.Name, nodes)
+		}
+		if image.ImageProperties != nil && image.StorageProfile != nil && image.StorageProfile.OsDisk != nil {
+			imageOSType = image.StorageProfile.OsDisk.OsType
+		}
+	}
+	if !strings.EqualFold(string(imageOSType), string(osType)) {
+		return errors.Errorf("the image %s of %s is a %s image, not a %s image", imageRef.Name, nodes, imageOSType, osType)
+	}
+	return nil
+}
+
+// AutofillAPIModel fills in the api model of options with the values a deployment needs that it leaves out: the
+// admin username, the DNS prefix, the SSH key and the service principal. It also creates the resource group, and
+// sets the output directory and resource group of options when they are empty.
+func AutofillAPIModel(ctx context.Context, client armhelpers.AKSEngineClient, logger *logrus.Entry, options *DeployOptions) error {
+	var err error
+	properties := options.ContainerService.Properties
+
+	if properties.LinuxProfile != nil {
+		if properties.LinuxProfile.AdminUsername == "" {
+			logger.Warnf("apimodel: no linuxProfile.adminUsername was specified. Will use 'azureuser'.")
+			properties.LinuxProfile.AdminUsername = "azureuser"
+		}
+	}
+
+	if options.DNSPrefix != "" && properties.MasterProfile.DNSPrefix != "" {
+		return errors.New("invalid configuration: the apimodel masterProfile.dnsPrefix and DeployOptions.DNSPrefix were both specified")
+	}
+	if properties.MasterProfile.DNSPrefix == "" {
+		if options.DNSPrefix == "" {
+			return errors.New("apimodel: missing masterProfile.dnsPrefix and DeployOptions.DNSPrefix was not specified")
+		}
+		logger.Warnf("apimodel: missing masterProfile.dnsPrefix will use %q", options.DNSPrefix)
+		properties.MasterProfile.DNSPrefix = options.DNSPrefix
+	}
+
+	if options.AutoSuffix {
+		suffix := strconv.FormatInt(time.Now().Unix(), 16)
+		properties.MasterProfile.DNSPrefix += "-" + suffix
+	}
+
+	if options.OutputDirectory == "" {
+		options.OutputDirectory = DefaultOutputDirectory(options.ContainerService)
+	}
+
+	if _, err = os.Stat(options.OutputDirectory); !options.ForceOverwrite && err == nil {
+		return errors.Errorf("Output directory already exists and forceOverwrite flag is not set: %s", options.OutputDirectory)
+	}
+
+	if options.ResourceGroup == "" {
+		dnsPrefix := properties.MasterProfile.DNSPrefix
+		logger.Warnf("DeployOptions.ResourceGroup was not specified. Using the DNS prefix from the apimodel as the resource group name: %s", dnsPrefix)
+		options.ResourceGroup = dnsPrefix
+		if options.Location == "" {
+			return errors.New("DeployOptions.ResourceGroup was not specified. DeployOptions.Location must be specified in case the resource group needs creation")
+		}
+	}
+
+	if properties.LinuxProfile != nil && (properties.LinuxProfile.SSH.PublicKeys == nil ||
+		len(properties.LinuxProfile.SSH.PublicKeys) == 0 ||
+		properties.LinuxProfile.SSH.PublicKeys[0].KeyData == "") {
+		var publicKey string
+		_, publicKey, err = helpers.CreateSaveSSH(properties.LinuxProfile.AdminUsername, options.OutputDirectory, translatorOrDefault(options.Translator))
+		if err != nil {
+			return errors.Wrap(err, "Failed to generate SSH Key")
+		}
+
+		properties.LinuxProfile.SSH.PublicKeys = []api.PublicKey{{KeyData: publicKey}}
+	}
+
+	_, err = client.EnsureResourceGroup(ctx, options.ResourceGroup, options.Location, nil)
+	if err != nil {
+		return err
+	}
+
+	k8sConfig := properties.OrchestratorProfile.KubernetesConfig
+
+	useManagedIdentity := k8sConfig != nil && k8sConfig.UseManagedIdentity
+
+	if !useManagedIdentity {
+		spp := properties.ServicePrincipalProfile
+		hasClientID := options.ClientID != "" && options.ClientID != emptyClientID
+		if spp != nil && spp.ClientID == "" && spp.Secret == "" && spp.KeyvaultSecretRef == nil && !hasClientID && options.ClientSecret == "" {
+			logger.Warnln("apimodel: ServicePrincipalProfile was missing or empty, creating application...")
+
+			// TODO: consider caching the creds here so they persist between subsequent runs of 'deploy'
+			appName := properties.MasterProfile.DNSPrefix
+			appURL := fmt.Sprintf("https://%s/", appName)
+			var replyURLs *[]string
+			var requiredResourceAccess *[]graphrbac.RequiredResourceAccess
+			applicationResp, servicePrincipalObjectID, secret, err := client.CreateApp(ctx, appName, appURL, replyURLs, requiredResourceAccess)
+			if err != nil {
+				return errors.Wrap(err, "apimodel invalid: ServicePrincipalProfile was empty, and we failed to create valid credentials")
+			}
+			applicationID := to.String(applicationResp.AppID)
+			logger.Warnf("created application with applicationID (%s) and servicePrincipalObjectID (%s).", applicationID, servicePrincipalObjectID)
+
+			logger.Warnln("apimodel: ServicePrincipalProfile was empty, assigning role to application...")
+
+			err = client.CreateRoleAssignmentSimple(ctx, options.ResourceGroup, servicePrincipalObjectID)
+			if err != nil {
+				return errors.Wrap(err, "apimodel: could not create or assign ServicePrincipal")
+
+			}
+
+			properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{
+				ClientID: applicationID,
+				Secret:   secret,
+				ObjectID: servicePrincipalObjectID,
+			}
+		} else if (spp == nil || ((spp.ClientID == "" || spp.ClientID == emptyClientID) && spp.Secret == "")) && hasClientID && options.ClientSecret != "" {
+			properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{
+				ClientID: options.ClientID,
+				Secret:   options.ClientSecret,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,513 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const ExampleAPIModel = `{
+  "apiVersion": "vlabs",
+  "properties": {
+		"orchestratorProfile": { "orchestratorType": "Kubernetes", "kubernetesConfig": { "useManagedIdentity": %s, "etcdVersion" : "2.3.8" } },
+    "masterProfile": { "count": 1, "dnsPrefix": "", "vmSize": "Standard_D2_v2" },
+    "agentPoolProfiles": [ { "name": "linuxpool1", "count": 2, "vmSize": "Standard_D2_v2", "availabilityProfile": "AvailabilitySet" } ],
+    "windowsProfile": { "adminUsername": "azureuser", "adminPassword": "replacepassword1234$" },
+    "linuxProfile": { "adminUsername": "azureuser", "ssh": { "publicKeys": [ { "keyData": "" } ] }
+    },
+    "servicePrincipalProfile": { "clientId": "%s", "secret": "%s" }
+  }
+}
+`
+
+const ExampleAPIModelWithDNSPrefix = `{
+	"apiVersion": "vlabs",
+	"properties": {
+		  "orchestratorProfile": { "orchestratorType": "Kubernetes", "kubernetesConfig": { "useManagedIdentity": %s, "etcdVersion" : "2.3.8" } },
+	  "masterProfile": { "count": 1, "dnsPrefix": "mytestcluster", "vmSize": "Standard_D2_v2" },
+	  "agentPoolProfiles": [ { "name": "linuxpool1", "count": 2, "vmSize": "Standard_D2_v2", "availabilityProfile": "AvailabilitySet" } ],
+	  "windowsProfile": { "adminUsername": "azureuser", "adminPassword": "replacepassword1234$" },
+	  "linuxProfile": { "adminUsername": "azureuser", "ssh": { "publicKeys": [ { "keyData": "" } ] }
+	  },
+	  "servicePrincipalProfile": { "clientId": "%s", "secret": "%s" }
+	}
+  }
+  `
+
+const ExampleAPIModelWithoutDNSPrefix = `{
+	"apiVersion": "vlabs",
+	"properties": {
+		  "orchestratorProfile": { "orchestratorType": "Kubernetes", "kubernetesConfig": { "useManagedIdentity": %s, "etcdVersion" : "2.3.8" } },
+	  "masterProfile": { "count": 1, "vmSize": "Standard_D2_v2" },
+	  "agentPoolProfiles": [ { "name": "linuxpool1", "count": 2, "vmSize": "Standard_D2_v2", "availabilityProfile": "AvailabilitySet" } ],
+	  "windowsProfile": { "adminUsername": "azureuser", "adminPassword": "replacepassword1234$" },
+	  "linuxProfile": { "adminUsername": "azureuser", "ssh": { "publicKeys": [ { "keyData": "" } ] }
+	  },
+	  "servicePrincipalProfile": { "clientId": "%s", "secret": "%s" }
+	}
+  }
+  `
+
+const ExampleAPIModelWithoutServicePrincipalProfile = `{
+	"apiVersion": "vlabs",
+	"properties": {
+		  "orchestratorProfile": { "orchestratorType": "Kubernetes", "kubernetesConfig": { "useManagedIdentity": %s, "etcdVersion" : "2.3.8" } },
+	  "masterProfile": { "count": 1, "dnsPrefix": "mytestcluster", "vmSize": "Standard_D2_v2" },
+	  "agentPoolProfiles": [ { "name": "linuxpool1", "count": 2, "vmSize": "Standard_D2_v2", "availabilityProfile": "AvailabilitySet" } ],
+	  "windowsProfile": { "adminUsername": "azureuser", "adminPassword": "replacepassword1234$" },
+	  "linuxProfile": { "adminUsername": "azureuser", "ssh": { "publicKeys": [ { "keyData": "" } ] }
+	  }
+	}
+  }
+  `
+
+func getExampleAPIModel(useManagedIdentity bool, clientID, clientSecret string) string {
+	return getAPIModel(ExampleAPIModel, useManagedIdentity, clientID, clientSecret)
+}
+
+func getAPIModel(baseAPIModel string, useManagedIdentity bool, clientID, clientSecret string) string {
+	return fmt.Sprintf(
+		baseAPIModel,
+		strconv.FormatBool(useManagedIdentity),
+		clientID,
+		clientSecret)
+}
+
+func getAPIModelWithoutServicePrincipalProfile(baseAPIModel string, useManagedIdentity bool) string {
+	return fmt.Sprintf(
+		baseAPIModel,
+		strconv.FormatBool(useManagedIdentity))
+}
+
+func TestAutofillApimodelWithoutManagedIdentityCreatesCreds(t *testing.T) {
+	testAutodeployCredentialHandling(t, false, "", "")
+}
+
+func TestAutofillApimodelWithManagedIdentitySkipsCreds(t *testing.T) {
+	testAutodeployCredentialHandling(t, true, "", "")
+}
+
+func TestAutofillApimodelAllowsPrespecifiedCreds(t *testing.T) {
+	testAutodeployCredentialHandling(t, false, "clientID", "clientSecret")
+}
+
+func TestAutoSufixWithDnsPrefixInApiModel(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModel(ExampleAPIModelWithDNSPrefix, false, "clientID", "clientSecret")
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := &DeployOptions{
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		AutoSuffix:       true,
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.ContainerService.Properties.MasterProfile.DNSPrefix == "mytestcluster" {
+		t.Fatalf("expected %s-{timestampsuffix} but got %s", "mytestcluster", options.ContainerService.Properties.MasterProfile.DNSPrefix)
+	}
+
+}
+
+func TestAPIModelWithoutServicePrincipalProfileAndClientIdAndSecretInOptions(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModelWithoutServicePrincipalProfile(ExampleAPIModelWithoutServicePrincipalProfile, false)
+	TestClientIDInOptions, err := uuid.FromString("DEC923E3-1EF1-4745-9516-37906D56DEC4")
+	if err != nil {
+		t.Fatalf("Invalid ClientID in Test: %s", err)
+	}
+
+	TestClientSecretInOptions := "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := &DeployOptions{
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+	options.ClientID = TestClientIDInOptions.String()
+	options.ClientSecret = TestClientSecretInOptions
+
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.ContainerService.Properties.ServicePrincipalProfile == nil || options.ContainerService.Properties.ServicePrincipalProfile.ClientID == "" || options.ContainerService.Properties.ServicePrincipalProfile.Secret == "" {
+		t.Fatalf("expected service principal profile to be populated from deployment command arguments")
+	}
+
+	if options.ContainerService.Properties.ServicePrincipalProfile.ClientID != TestClientIDInOptions.String() {
+		t.Fatalf("expected service principal profile client id to be %s but got %s", TestClientIDInOptions.String(), options.ContainerService.Properties.ServicePrincipalProfile.ClientID)
+	}
+
+	if options.ContainerService.Properties.ServicePrincipalProfile.Secret != TestClientSecretInOptions {
+		t.Fatalf("expected service principal profile client secret to be %s but got %s", TestClientSecretInOptions, options.ContainerService.Properties.ServicePrincipalProfile.Secret)
+	}
+}
+
+func TestAPIModelWithEmptyServicePrincipalProfileAndClientIdAndSecretInOptions(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModel(ExampleAPIModelWithDNSPrefix, false, "", "")
+	TestClientIDInOptions, err := uuid.FromString("DEC923E3-1EF1-4745-9516-37906D56DEC4")
+	if err != nil {
+		t.Fatalf("Invalid ClientID in Test: %s", err)
+	}
+
+	TestClientSecretInOptions := "DEC923E3-1EF1-4745-9516-37906D56DEC4"
+
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := &DeployOptions{
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+	options.ClientID = TestClientIDInOptions.String()
+	options.ClientSecret = TestClientSecretInOptions
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.ContainerService.Properties.ServicePrincipalProfile == nil || options.ContainerService.Properties.ServicePrincipalProfile.ClientID == "" || options.ContainerService.Properties.ServicePrincipalProfile.Secret == "" {
+		t.Fatalf("expected service principal profile to be populated from deployment command arguments")
+	}
+
+	if options.ContainerService.Properties.ServicePrincipalProfile.ClientID != TestClientIDInOptions.String() {
+		t.Fatalf("expected service principal profile client id to be %s but got %s", TestClientIDInOptions.String(), options.ContainerService.Properties.ServicePrincipalProfile.ClientID)
+	}
+
+	if options.ContainerService.Properties.ServicePrincipalProfile.Secret != TestClientSecretInOptions {
+		t.Fatalf("expected service principal profile client secret to be %s but got %s", TestClientSecretInOptions, options.ContainerService.Properties.ServicePrincipalProfile.Secret)
+	}
+}
+
+func TestAPIModelWithoutServicePrincipalProfileAndWithoutClientIdAndSecretInOptions(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModelWithoutServicePrincipalProfile(ExampleAPIModelWithoutServicePrincipalProfile, false)
+
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := &DeployOptions{
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.ContainerService.Properties.ServicePrincipalProfile != nil {
+		t.Fatalf("expected service principal profile to be nil for unmanaged identity, where client id and secret are not supplied in api model and deployment command")
+	}
+
+}
+
+func TestAPIModelWithEmptyServicePrincipalProfileAndWithoutClientIdAndSecretInOptions(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModel(ExampleAPIModelWithDNSPrefix, false, "", "")
+
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := &DeployOptions{
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.ContainerService.Properties.ServicePrincipalProfile == nil {
+		t.Fatalf("expected service principal profile to be Empty and not nil for unmanaged identity, where client id and secret are not supplied in api model and deployment command")
+	}
+
+	// mockclient returns "app-id" for ClientID when empty
+	if options.ContainerService.Properties.ServicePrincipalProfile.ClientID != "app-id" {
+		t.Fatalf("expected service principal profile client id to be empty but got %s", options.ContainerService.Properties.ServicePrincipalProfile.ClientID)
+	}
+
+	// mockcliet returns "client-secret" when empty
+	if options.ContainerService.Properties.ServicePrincipalProfile.Secret != "client-secret" {
+		t.Fatalf("expected service principal profile client secret to be empty but got %s", options.ContainerService.Properties.ServicePrincipalProfile.Secret)
+	}
+
+}
+
+func testAutodeployCredentialHandling(t *testing.T, useManagedIdentity bool, clientID, clientSecret string) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getExampleAPIModel(useManagedIdentity, clientID, clientSecret)
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+
+	// deserialization happens when loading the api model, but we are testing just the default
+	// setting that occurs in AutofillAPIModel (which is called from Deploy)
+	// Thus, it assumes that ContainerService/APIVersion are already populated
+	options := &DeployOptions{
+		DNSPrefix:       "dnsPrefix1",
+		OutputDirectory: "_test_output",
+		ForceOverwrite:  true,
+		Location:        "westus",
+
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	// cleanup, since auto-populations creates dirs and saves the SSH private key that it might create
+	defer os.RemoveAll(options.OutputDirectory)
+
+	err = api.ConvertContainerServiceToVLabs(options.ContainerService).Validate(false)
+	if err != nil {
+		t.Fatalf("unexpected error validating apimodel after populating defaults: %s", err)
+	}
+
+	if useManagedIdentity {
+		if cs.Properties.ServicePrincipalProfile != nil &&
+			(cs.Properties.ServicePrincipalProfile.ClientID != "" || cs.Properties.ServicePrincipalProfile.Secret != "") {
+			t.Fatalf("Unexpected credentials were populated even though MSI was active.")
+		}
+	} else {
+		if cs.Properties.ServicePrincipalProfile == nil ||
+			cs.Properties.ServicePrincipalProfile.ClientID == "" || cs.Properties.ServicePrincipalProfile.Secret == "" {
+			t.Fatalf("Credentials were missing even though MSI was not active.")
+		}
+	}
+}
+
+func TestOutputDirectoryWithDNSPrefix(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModel(ExampleAPIModelWithoutDNSPrefix, false, "clientID", "clientSecret")
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+
+	options := &DeployOptions{
+		OutputDirectory:  "",
+		DNSPrefix:        "dnsPrefix1",
+		ForceOverwrite:   true,
+		Location:         "westus",
+		ContainerService: cs,
+		APIVersion:       ver,
+	}
+
+	err = AutofillAPIModel(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error autofilling the example apimodel: %s", err)
+	}
+
+	defer os.RemoveAll(options.OutputDirectory)
+
+	if options.OutputDirectory != path.Join("_output", options.DNSPrefix) {
+		t.Fatalf("Calculated output directory should be %s, actual value %s", path.Join("_output", options.DNSPrefix), options.OutputDirectory)
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	apiloader := &api.Apiloader{
+		Translator: nil,
+	}
+
+	apimodel := getAPIModel(ExampleAPIModelWithDNSPrefix, false, "clientID", "clientSecret")
+	cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+	}
+	options := DeployOptions{
+		ContainerService: cs,
+		APIVersion:       ver,
+		OutputDirectory:  "_test_output",
+		ForceOverwrite:   true,
+		Location:         "westus",
+	}
+	defer os.RemoveAll(options.OutputDirectory)
+
+	result, err := Deploy(context.Background(), &armhelpers.MockAKSEngineClient{}, log.NewEntry(log.New()), options)
+	if err != nil {
+		t.Fatalf("unexpected error deploying the example apimodel: %s", err)
+	}
+
+	if result.ResourceGroup != "mytestcluster" {
+		t.Errorf("expected the resource group to default to the DNS prefix mytestcluster, got %s", result.ResourceGroup)
+	}
+	if !strings.HasPrefix(result.DeploymentName, "mytestcluster-") {
+		t.Errorf("expected the deployment to be named after the resource group, got %s", result.DeploymentName)
+	}
+	if result.OutputDirectory != options.OutputDirectory {
+		t.Errorf("expected the output directory to be %s, got %s", options.OutputDirectory, result.OutputDirectory)
+	}
+	for _, file := range []string{"apimodel.json", "azuredeploy.json", "azuredeploy.parameters.json"} {
+		if _, err := os.Stat(path.Join(options.OutputDirectory, file)); err != nil {
+			t.Errorf("expected %s to be written: %s", file, err)
+		}
+	}
+}
+
+func TestValidateImageOSTypes(t *testing.T) {
+	windowsImage := compute.Image{
+		ImageProperties: &compute.ImageProperties{
+			StorageProfile: &compute.ImageStorageProfile{
+				OsDisk: &compute.ImageOSDisk{OsType: compute.Windows},
+			},
+		},
+	}
+	windowsGalleryImage := compute.GalleryImage{
+		GalleryImageProperties: &compute.GalleryImageProperties{OsType: compute.Windows},
+	}
+	managedImageRef := &api.ImageReference{Name: "image", ResourceGroup: "images"}
+	galleryImageRef := &api.ImageReference{Name: "image", ResourceGroup: "images", SubscriptionID: "subscription", Gallery: "gallery", Version: "1.0.0"}
+
+	cases := []struct {
+		name          string
+		osType        api.OSType
+		imageRef      *api.ImageReference
+		windowsImages bool
+		expectedErr   string
+	}{
+		{
+			name:   "no image",
+			osType: api.Windows,
+		},
+		{
+			name:     "linux pool of a linux image",
+			osType:   api.Linux,
+			imageRef: managedImageRef,
+		},
+		{
+			name:          "windows pool of a windows image",
+			osType:        api.Windows,
+			imageRef:      managedImageRef,
+			windowsImages: true,
+		},
+		{
+			name:          "windows pool of a windows gallery image",
+			osType:        api.Windows,
+			imageRef:      galleryImageRef,
+			windowsImages: true,
+		},
+		{
+			name:        "windows pool of a linux image",
+			osType:      api.Windows,
+			imageRef:    managedImageRef,
+			expectedErr: "the image image of agent pool agentpool1 is a Linux image, not a Windows image",
+		},
+		{
+			name:        "windows pool of a linux gallery image",
+			osType:      api.Windows,
+			imageRef:    galleryImageRef,
+			expectedErr: "the image image of agent pool agentpool1 is a Linux image, not a Windows image",
+		},
+		{
+			name:          "linux pool of a windows image",
+			osType:        api.Linux,
+			imageRef:      managedImageRef,
+			windowsImages: true,
+			expectedErr:   "the image image of agent pool agentpool1 is a Windows image, not a Linux image",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			client := &armhelpers.MockAKSEngineClient{}
+			if c.windowsImages {
+				client.FakeGetImageResult = func() compute.Image { return windowsImage }
+				client.FakeGetGalleryImageResult = func() compute.GalleryImage { return windowsGalleryImage }
+			}
+			cs := api.CreateMockContainerService("testcluster", "1.15.2", 1, 1, false)
+			cs.Properties.AgentPoolProfiles[0].OSType = c.osType
+			cs.Properties.AgentPoolProfiles[0].ImageRef = c.imageRef
+
+			err := validateImageOSTypes(context.Background(), client, cs)
+			if c.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error validating the image OS types: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.expectedErr {
+				t.Errorf("expected error %q validating the image OS types, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
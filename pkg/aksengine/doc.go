@@ -0,0 +1,8 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package aksengine is the Go API of the aks-engine commands. Generate, Deploy, Scale, Upgrade and RotateCerts
+// run the operations of the commands of the same names on an api model loaded with the api.Apiloader, so that
+// other tools can embed them rather than running the aks-engine binary. The commands are thin wrappers over them,
+// parsing their flags into the options of the operations.
+package aksengine
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GenerateOptions are the inputs of Generate
+type GenerateOptions struct {
+	// ContainerService is the api model to generate the template of, its defaults are set by Generate
+	ContainerService *api.ContainerService
+	// APIVersion is the version of the API the api model is written in
+	APIVersion string
+	// OutputDirectory is where the template, parameters, certificates and api model are written. Nothing is
+	// written when it is empty.
+	OutputDirectory string
+	// ParametersOnly writes the parameters and the certificates, but neither the template nor the api model
+	ParametersOnly bool
+	// NoPrettyPrint leaves the template and parameters as generated
+	NoPrettyPrint bool
+	// Entropy is the source of the generated certificates and keys, crypto/rand when nil
+	Entropy *helpers.Entropy
+	// Secrets, when set, keeps the secrets of the api model in a secret provider
+	Secrets *api.SecretStore
+	// BuildTag is the version of aks-engine the template is stamped with
+	BuildTag   string
+	Translator *i18n.Translator
+}
+
+// GenerateResult is the output of Generate
+type GenerateResult struct {
+	Template   string
+	Parameters string
+	// CertificatesGenerated is true if the certificates of the cluster were generated rather than given
+	CertificatesGenerated bool
+}
+
+// Generate generates the ARM template and parameters of an api model, and writes them to the output directory
+// along with the certificates and the api model.
+func Generate(ctx context.Context, logger *logrus.Entry, options GenerateOptions) (*GenerateResult, error) {
+	translator := translatorOrDefault(options.Translator)
+	cs := options.ContainerService
+
+	certsGenerated, err := cs.SetPropertiesDefaultsWithEntropy(false, false, options.Entropy)
+	if err != nil {
+		return nil, errors.Wrap(err, "setting the defaults of the api model")
+	}
+
+	template, parameters, err := generateTemplate(cs, options.Secrets, translator, options.BuildTag)
+	if err != nil {
+		return nil, err
+	}
+
+	if !options.NoPrettyPrint {
+		if template, parameters, err = prettyPrint(template, parameters); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.OutputDirectory != "" {
+		logger.Infof("Generating assets into %s...", options.OutputDirectory)
+		writer := artifactWriter(translator, options.Secrets)
+		if err = writer.WriteTLSArtifacts(cs, options.APIVersion, template, parameters, options.OutputDirectory, certsGenerated, options.ParametersOnly); err != nil {
+			return nil, errors.Wrap(err, "writing artifacts")
+		}
+	}
+
+	return &GenerateResult{
+		Template:              template,
+		Parameters:            parameters,
+		CertificatesGenerated: certsGenerated,
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestGenerate(t *testing.T) {
+	cases := []struct {
+		name            string
+		outputDirectory string
+		parametersOnly  bool
+		expectedFiles   []string
+		unexpectedFiles []string
+	}{
+		{
+			name:            "no output directory",
+			unexpectedFiles: []string{"apimodel.json"},
+		},
+		{
+			name:            "output directory",
+			outputDirectory: "_test_output",
+			expectedFiles:   []string{"apimodel.json", "azuredeploy.json", "azuredeploy.parameters.json"},
+		},
+		{
+			name:            "parameters only",
+			outputDirectory: "_test_output",
+			parametersOnly:  true,
+			expectedFiles:   []string{"azuredeploy.parameters.json", "ca.crt"},
+			unexpectedFiles: []string{"apimodel.json", "azuredeploy.json"},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			apiloader := &api.Apiloader{}
+			apimodel := getAPIModel(ExampleAPIModelWithDNSPrefix, false, "clientID", "clientSecret")
+			cs, ver, err := apiloader.DeserializeContainerService([]byte(apimodel), false, false, nil)
+			if err != nil {
+				t.Fatalf("unexpected error deserializing the example apimodel: %s", err)
+			}
+			defer os.RemoveAll("_test_output")
+
+			result, err := Generate(context.Background(), log.NewEntry(log.New()), GenerateOptions{
+				ContainerService: cs,
+				APIVersion:       ver,
+				OutputDirectory:  c.outputDirectory,
+				ParametersOnly:   c.parametersOnly,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error generating the example apimodel: %s", err)
+			}
+			if result.Template == "" || result.Parameters == "" {
+				t.Error("expected the template and parameters to be generated")
+			}
+			if !result.CertificatesGenerated {
+				t.Error("expected the certificates to be generated")
+			}
+			for _, file := range c.expectedFiles {
+				if _, err := os.Stat(path.Join(c.outputDirectory, file)); err != nil {
+					t.Errorf("expected %s to be written: %s", file, err)
+				}
+			}
+			for _, file := range c.unexpectedFiles {
+				if _, err := os.Stat(path.Join(c.outputDirectory, file)); err == nil {
+					t.Errorf("expected %s not to be written", file)
+				}
+			}
+		})
+	}
+}
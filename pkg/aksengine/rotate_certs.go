@@ -0,0 +1,429 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/nodeexec"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const kubeSystemNamespace = "kube-system"
+
+// CommandRunner runs a shell command on the node of a cluster named hostname, usually over SSH, and returns its output
+type CommandRunner func(command, hostname string) (string, error)
+
+// RotateCertsOptions are the inputs of RotateCerts
+type RotateCertsOptions struct {
+	// ContainerService is the api model of the deployed cluster, loaded as an update with its secrets resolved.
+	// Its certificates are replaced by RotateCerts.
+	ContainerService *api.ContainerService
+	// APIVersion is the version of the API the api model is written in
+	APIVersion string
+	// Location is the location the cluster is deployed in
+	Location      string
+	ResourceGroup string
+	// OutputDirectory is where the new certificates, template, parameters and api model are written,
+	// _output/<DNS prefix> when empty
+	OutputDirectory string
+	// RunCommand runs the commands replacing the certificates on the nodes
+	RunCommand CommandRunner
+	// NodeRetry is the retry policy of the operations run on all of the nodes at once
+	NodeRetry nodeexec.RetryPolicy
+	// Secrets, when set, keeps the secrets of the api model in a secret provider
+	Secrets *api.SecretStore
+	// BuildTag is the version of aks-engine the template is stamped with
+	BuildTag   string
+	Translator *i18n.Translator
+}
+
+// RotateCertsResult is the output of RotateCerts
+type RotateCertsResult struct {
+	OutputDirectory string
+	// MasterNodes and AgentNodes are the nodes whose certificates were rotated
+	MasterNodes []string
+	AgentNodes  []string
+}
+
+// certRotator holds the state of a certificate rotation
+type certRotator struct {
+	client     armhelpers.AKSEngineClient
+	logger     *logrus.Entry
+	translator *i18n.Translator
+
+	containerService *api.ContainerService
+	apiVersion       string
+	location         string
+	resourceGroup    string
+	outputDirectory  string
+	secrets          *api.SecretStore
+	buildTag         string
+	runCommand       CommandRunner
+	nodeRetry        nodeexec.RetryPolicy
+
+	masterNodes []v1.Node
+	agentNodes  []v1.Node
+}
+
+// RotateCerts rotates the CA, etcd, kubelet, kubeconfig and apiserver certificates of a deployed Kubernetes
+// cluster: it generates new certificates, replaces them on the nodes, reboots the nodes, recreates the pods and the
+// service accounts holding tokens signed by the old CA, and writes the new certificates and api model.
+func RotateCerts(ctx context.Context, client armhelpers.AKSEngineClient, logger *logrus.Entry, options RotateCertsOptions) (*RotateCertsResult, error) {
+	if options.RunCommand == nil {
+		return nil, errors.New("a command runner is required to replace the certificates on the nodes")
+	}
+	rcc := &certRotator{
+		client:           client,
+		logger:           logger,
+		translator:       translatorOrDefault(options.Translator),
+		containerService: options.ContainerService,
+		apiVersion:       options.APIVersion,
+		location:         options.Location,
+		resourceGroup:    options.ResourceGroup,
+		outputDirectory:  options.OutputDirectory,
+		secrets:          options.Secrets,
+		buildTag:         options.BuildTag,
+		runCommand:       options.RunCommand,
+		nodeRetry:        options.NodeRetry,
+	}
+	if rcc.outputDirectory == "" {
+		rcc.outputDirectory = DefaultOutputDirectory(rcc.containerService)
+	}
+	if err := rcc.run(ctx); err != nil {
+		return nil, err
+	}
+	result := &RotateCertsResult{
+		OutputDirectory: rcc.outputDirectory,
+	}
+	for _, node := range rcc.masterNodes {
+		result.MasterNodes = append(result.MasterNodes, node.Name)
+	}
+	for _, node := range rcc.agentNodes {
+		result.AgentNodes = append(result.AgentNodes, node.Name)
+	}
+	return result, nil
+}
+
+func (rcc *certRotator) run(ctx context.Context) error {
+	rcc.logger.Debugf("Getting cluster nodes")
+
+	err := rcc.getClusterNodes()
+	if err != nil {
+		return errors.Wrap(err, "listing cluster nodes")
+	}
+
+	rcc.logger.Infoln("Generating new certificates")
+
+	// reset the certificateProfile and use the exisiting certificate generation code to generate new certificates.
+	rcc.containerService.Properties.CertificateProfile = &api.CertificateProfile{}
+	certsGenerated, _, err := rcc.containerService.SetDefaultCerts()
+	if err != nil {
+		return errors.Wrap(err, "generating new certificates")
+	}
+	if !certsGenerated {
+		return errors.New("generating new certificates: no certificates were generated")
+	}
+
+	rcc.logger.Infoln("Rotating apiserver certificate")
+
+	err = rcc.rotateApiserver()
+	if err != nil {
+		return errors.Wrap(err, "rotating apiserver")
+	}
+
+	rcc.logger.Infoln("Rotating kubelet certificate")
+
+	err = rcc.rotateKubelet()
+	if err != nil {
+		return errors.Wrap(err, "rotating kubelet")
+	}
+
+	rcc.logger.Infoln("Rotating etcd certificates")
+
+	err = rcc.rotateEtcd(ctx)
+	if err != nil {
+		return errors.Wrap(err, "rotating etcd cluster")
+	}
+
+	rcc.logger.Infoln("Updating kubeconfig")
+	err = rcc.updateKubeconfig()
+	if err != nil {
+		return errors.Wrap(err, "updating kubeconfig")
+	}
+
+	rcc.logger.Debugf("Deleting Service Accoutns")
+	err = rcc.deleteServiceAccounts()
+	if err != nil {
+		return errors.Wrap(err, "deleting service accounts")
+	}
+
+	rcc.logger.Debugf("Deleting all pods")
+	err = rcc.deleteAllPods()
+	if err != nil {
+		return errors.Wrap(err, "deleting all the pods")
+	}
+
+	err = rcc.writeArtifacts()
+	if err != nil {
+		return errors.Wrap(err, "writing artifacts")
+	}
+
+	rcc.logger.Infoln("Successfully rotated etcd and cluster certificates.")
+
+	return nil
+}
+
+func (rcc *certRotator) writeArtifacts() error {
+	template, parameters, err := generateTemplate(rcc.containerService, rcc.secrets, rcc.translator, rcc.buildTag)
+	if err != nil {
+		return err
+	}
+	if template, parameters, err = prettyPrint(template, parameters); err != nil {
+		return err
+	}
+	writer := artifactWriter(rcc.translator, rcc.secrets)
+	return writer.WriteTLSArtifacts(rcc.containerService, rcc.apiVersion, template, parameters, rcc.outputDirectory, true, false)
+}
+
+func (rcc *certRotator) getClusterNodes() error {
+	kubeClient, err := rcc.getKubeClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get Kubernetes Client")
+	}
+	nodeList, err := kubeClient.ListNodes()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster nodes")
+	}
+	for _, node := range nodeList.Items {
+		if strings.Contains(node.Name, "master") {
+			rcc.masterNodes = append(rcc.masterNodes, node)
+		} else {
+			rcc.agentNodes = append(rcc.agentNodes, node)
+		}
+	}
+	return nil
+}
+
+func (rcc *certRotator) rebootAllNodes(ctx context.Context) error {
+	vmListPage, err := rcc.client.ListVirtualMachines(ctx, rcc.resourceGroup)
+	if err != nil {
+		return errors.Wrap(err, "failed to list Virtual Machines in resource group "+rcc.resourceGroup)
+	}
+	vmssListPage, err := rcc.client.ListVirtualMachineScaleSets(ctx, rcc.resourceGroup)
+	if err != nil {
+		return errors.Wrap(err, "failed to list Virtual Machine Scale Sets in resource group "+rcc.resourceGroup)
+	}
+	executor := rcc.nodeExecutor()
+	var vms, scaleSets []nodeexec.Node
+	for _, vm := range vmListPage.Values() {
+		vms = append(vms, nodeexec.Node{Name: *vm.Name})
+	}
+	for _, scaleSet := range vmssListPage.Values() {
+		scaleSets = append(scaleSets, nodeexec.Node{Name: *scaleSet.Name})
+	}
+	results := executor.Run(ctx, vms, func(ctx context.Context, vm nodeexec.Node) (string, error) {
+		return "", rcc.client.RestartVirtualMachine(ctx, rcc.resourceGroup, vm.Name)
+	})
+	if err = results.Err(); err != nil {
+		return errors.Wrap(err, "failed to restart Virtual Machines")
+	}
+	results = executor.Run(ctx, scaleSets, func(ctx context.Context, scaleSet nodeexec.Node) (string, error) {
+		return "", rcc.client.RestartVirtualMachineScaleSets(ctx, rcc.resourceGroup, scaleSet.Name, nil)
+	})
+	if err = results.Err(); err != nil {
+		return errors.Wrap(err, "failed to restart Virtual Machine Scale Sets")
+	}
+	return nil
+}
+
+func (rcc *certRotator) deleteAllPods() error {
+	kubeClient, err := rcc.getKubeClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get Kubernetes Client")
+	}
+	pods, err := kubeClient.ListAllPods()
+	if err != nil {
+		return errors.Wrap(err, "failed to get pods")
+	}
+	for _, pod := range pods.Items {
+		rcc.logger.Debugf("Deleting pod %s", pod.Name)
+		err = kubeClient.DeletePod(&pod)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete pod "+pod.Name)
+		}
+	}
+	return nil
+}
+
+func (rcc *certRotator) deleteServiceAccounts() error {
+	kubeClient, err := rcc.getKubeClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get Kubernetes Client")
+	}
+	saList, err := kubeClient.ListServiceAccounts(kubeSystemNamespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster service accounts in namespace "+kubeSystemNamespace)
+	}
+	for _, sa := range saList.Items {
+		switch sa.Name {
+		case "kube-dns", "kubernetes-dashboard", "metrics-server":
+			rcc.logger.Debugf("Deleting service account %s", sa.Name)
+			err = kubeClient.DeleteServiceAccount(&sa)
+			if err != nil {
+				return errors.Wrap(err, "failed to delete service account "+sa.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (rcc *certRotator) updateKubeconfig() error {
+	kubeconfig, err := engine.GenerateKubeConfig(rcc.containerService.Properties, rcc.location)
+	if err != nil {
+		return errors.Wrap(err, "generating kubeconfig")
+	}
+
+	for _, host := range rcc.masterNodes {
+		cmd := "sudo bash -c \"cat > ~/.kube/config << EOL \n" + strings.Replace(kubeconfig, "\"", "\\\"", -1) + "EOL\""
+		out, err := rcc.runCommand(cmd, host.Name)
+		if err != nil {
+			rcc.logger.Printf("Command %s output: %s\n", cmd, out)
+			return errors.Wrap(err, "failed replacing kubeconfig file")
+		}
+	}
+	return nil
+}
+
+func (rcc *certRotator) getKubeClient() (armhelpers.KubernetesClient, error) {
+	kubeconfig, err := engine.GenerateKubeConfig(rcc.containerService.Properties, rcc.location)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating kubeconfig")
+	}
+	var kubeClient armhelpers.KubernetesClient
+	if rcc.client != nil {
+		kubeClient, err = rcc.client.GetKubernetesClient("", kubeconfig, time.Second*1, time.Duration(60)*time.Minute)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get a Kubernetes client")
+		}
+		return kubeClient, nil
+	}
+	return nil, errors.Wrap(err, "AKSEngineClient was nil")
+}
+
+// Rotate etcd CA and certificates in all of the master nodes.
+func (rcc *certRotator) rotateEtcd(ctx context.Context) error {
+	caPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/ca.key << EOL \n" + rcc.containerService.Properties.CertificateProfile.CaPrivateKey + "EOL\""
+	caCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/ca.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.CaCertificate + "EOL\""
+	etcdServerPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdserver.key << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdServerPrivateKey + "EOL\""
+	etcdServerCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdserver.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdServerCertificate + "EOL\""
+	etcdClientPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdclient.key << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdClientPrivateKey + "EOL\""
+	etcdClientCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdclient.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdClientCertificate + "EOL\""
+
+	for i, host := range rcc.masterNodes {
+		rcc.logger.Debugf("Ranging over node: %s\n", host.Name)
+		etcdPeerPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdpeer" + strconv.Itoa(i) + ".key << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdPeerPrivateKeys[i] + "EOL\""
+		etcdPeerCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/etcdpeer" + strconv.Itoa(i) + ".crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.EtcdPeerCertificates[i] + "EOL\""
+
+		for _, cmd := range []string{caPrivateKeyCmd, caCertificateCmd} {
+			out, err := rcc.runCommand(cmd, host.Name)
+			if err != nil {
+				rcc.logger.Printf("Command %s output: %s\n", cmd, out)
+				return errors.Wrap(err, "failed replacing certificate file")
+			}
+		}
+
+		for _, cmd := range []string{etcdServerPrivateKeyCmd, etcdServerCertificateCmd, etcdClientPrivateKeyCmd, etcdClientCertificateCmd, etcdPeerPrivateKeyCmd, etcdPeerCertificateCmd} {
+			out, err := rcc.runCommand(cmd, host.Name)
+			if err != nil {
+				rcc.logger.Printf("Command %s output: %s\n", cmd, out)
+				return errors.Wrap(err, "failed replacing certificate file")
+			}
+		}
+	}
+
+	rcc.logger.Infoln("Rebooting all nodes... This might take a few minutes")
+	err := rcc.rebootAllNodes(ctx)
+	if err != nil {
+		return errors.Wrap(err, "rebooting the nodes")
+	}
+
+	for _, host := range rcc.masterNodes {
+		rcc.logger.Debugf("Restarting etcd on node %s", host.Name)
+		out, err := rcc.runCommand("sudo systemctl restart etcd", host.Name)
+		if err != nil {
+			rcc.logger.Printf("Command `sudo systemctl restart etcd` output: %s\n", out)
+			return errors.Wrap(err, "failed to restart etcd")
+		}
+	}
+
+	return nil
+}
+
+// From the first master node, rotate apiserver certificates in the nodes.
+func (rcc *certRotator) rotateApiserver() error {
+	caCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/ca.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.CaCertificate + "EOL\""
+	apiServerPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/apiserver.key << EOL \n" + rcc.containerService.Properties.CertificateProfile.APIServerPrivateKey + "EOL\""
+	apiServerCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/apiserver.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.APIServerCertificate + "EOL\""
+
+	for _, host := range rcc.masterNodes {
+		rcc.logger.Debugf("Ranging over node: %s\n", host.Name)
+		for _, cmd := range []string{apiServerPrivateKeyCmd, apiServerCertificateCmd} {
+			out, err := rcc.runCommand(cmd, host.Name)
+			if err != nil {
+				rcc.logger.Printf("Command %s output: %s\n", cmd, out)
+				return errors.Wrap(err, "failed replacing certificate file")
+			}
+		}
+	}
+
+	for _, host := range rcc.agentNodes {
+		rcc.logger.Debugf("Ranging over node: %s\n", host.Name)
+		for _, cmd := range []string{caCertificateCmd, apiServerCertificateCmd} {
+			out, err := rcc.runCommand(cmd, host.Name)
+			if err != nil {
+				rcc.logger.Printf("Command %s output: %s\n", cmd, out)
+				return errors.Wrap(err, "failed replacing certificate file")
+			}
+		}
+	}
+	return nil
+}
+
+func (rcc *certRotator) rotateKubelet() error {
+	clientCertificateCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/client.crt << EOL \n" + rcc.containerService.Properties.CertificateProfile.ClientCertificate + "EOL\""
+	clientPrivateKeyCmd := "sudo bash -c \"cat > /etc/kubernetes/certs/client.key << EOL \n" + rcc.containerService.Properties.CertificateProfile.ClientPrivateKey + "EOL\""
+
+	nodes := nodeexec.FromKubernetesNodes(append(rcc.masterNodes, rcc.agentNodes...))
+	results := rcc.nodeExecutor().Run(context.Background(), nodes, func(ctx context.Context, node nodeexec.Node) (string, error) {
+		rcc.logger.Debugf("Rotating the kubelet certificate of node: %s\n", node.Name)
+		for _, cmd := range []string{clientCertificateCmd, clientPrivateKeyCmd} {
+			if out, err := rcc.runCommand(cmd, node.Name); err != nil {
+				return out, err
+			}
+		}
+		return "", nil
+	})
+	for _, result := range results.Failed() {
+		rcc.logger.Printf("Rotating the kubelet certificate of node %s output: %s\n", result.Node.Name, result.Output)
+	}
+	if err := results.Err(); err != nil {
+		return errors.Wrap(err, "failed replacing certificate file")
+	}
+	return nil
+}
+
+// nodeExecutor returns the executor of the operations run on all of the nodes at once
+func (rcc *certRotator) nodeExecutor() *nodeexec.Executor {
+	return &nodeexec.Executor{Retry: rcc.nodeRetry}
+}
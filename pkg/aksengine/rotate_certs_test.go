@@ -0,0 +1,304 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func mockExecuteCmd(command, hostname string) (string, error) {
+	return "success", nil
+}
+
+func mockFailingExecuteCmd(command, hostname string) (string, error) {
+	return "error running command", errors.New("executeCmd failed")
+}
+
+func TestGetClusterNodes(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+	mockClient.MockKubernetesClient.FailListNodes = true
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		client:           mockClient,
+		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
+	}
+	err := rcc.getClusterNodes()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to get cluster nodes"))
+
+	mockClient.MockKubernetesClient.FailListNodes = false
+	err = rcc.getClusterNodes()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(rcc.masterNodes)).To(Equal(1))
+	g.Expect(len(rcc.agentNodes)).To(Equal(1))
+}
+
+func TestDeleteAllPods(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+	mockClient.MockKubernetesClient.FailListPods = true
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		client:           mockClient,
+		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
+	}
+	err := rcc.deleteAllPods()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to get pods"))
+
+	mockClient.MockKubernetesClient.FailListPods = false
+	mockClient.MockKubernetesClient.FailDeletePod = true
+	mockClient.MockKubernetesClient.PodsList = &v1.PodList{
+		Items: []v1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kube-dns",
+					Namespace: "kube-system",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "kube-system",
+				},
+			},
+		},
+	}
+	err = rcc.deleteAllPods()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to delete pod"))
+
+	mockClient.MockKubernetesClient.FailDeletePod = false
+	err = rcc.deleteAllPods()
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestRebootAllNodes(t *testing.T) {
+	ctx := context.Background()
+	g := NewGomegaWithT(t)
+	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+	mockClient.FailListVirtualMachines = true
+	mockClient.FailListVirtualMachineScaleSets = false
+	mockClient.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
+		scalesetName := "scalesetName"
+		sku := compute.Sku{}
+		location := "eastus"
+		return []compute.VirtualMachineScaleSet{
+			{
+				Name:     &scalesetName,
+				Sku:      &sku,
+				Location: &location,
+			},
+		}
+	}
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		client:           mockClient,
+		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
+		resourceGroup:    "test-rg",
+	}
+	err := rcc.rebootAllNodes(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to list Virtual Machines in resource group test-rg"))
+
+	mockClient.FailListVirtualMachines = false
+	mockClient.FailListVirtualMachineScaleSets = true
+	err = rcc.rebootAllNodes(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to list Virtual Machine Scale Sets in resource group test-rg"))
+
+	mockClient.FailListVirtualMachines = false
+	mockClient.FailListVirtualMachineScaleSets = false
+	mockClient.FailRestartVirtualMachine = true
+	mockClient.FailRestartVirtualMachineScaleSets = false
+	err = rcc.rebootAllNodes(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to restart Virtual Machine"))
+
+	mockClient.FailRestartVirtualMachine = false
+	mockClient.FailRestartVirtualMachineScaleSets = true
+	err = rcc.rebootAllNodes(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to restart Virtual Machine Scale Sets"))
+
+	mockClient.FailRestartVirtualMachine = false
+	mockClient.FailRestartVirtualMachineScaleSets = false
+	err = rcc.rebootAllNodes(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestDeleteServiceAccounts(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+	mockClient.MockKubernetesClient.FailListServiceAccounts = true
+	mockClient.MockKubernetesClient.ServiceAccountList = &v1.ServiceAccountList{
+		Items: []v1.ServiceAccount{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kube-dns",
+					Namespace: "kube-system",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sa",
+					Namespace: "kube-system",
+				},
+			},
+		},
+	}
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		client:           mockClient,
+		containerService: api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false),
+	}
+	err := rcc.deleteServiceAccounts()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to get cluster service accounts in namespace kube-system"))
+
+	mockClient.MockKubernetesClient.FailListServiceAccounts = false
+	mockClient.MockKubernetesClient.FailDeleteServiceAccount = true
+	err = rcc.deleteServiceAccounts()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed to delete service account kube-dns"))
+
+	mockClient.MockKubernetesClient.FailDeleteServiceAccount = false
+	err = rcc.deleteServiceAccounts()
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestWriteArtifacts(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	cs.SetPropertiesDefaults(false, false)
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		containerService: cs,
+		translator:       &i18n.Translator{},
+		apiVersion:       "vlabs",
+		outputDirectory:  "_test_output",
+	}
+	defer os.RemoveAll(rcc.outputDirectory)
+	err := rcc.writeArtifacts()
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestUpdateKubeconfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	cs.SetPropertiesDefaults(false, false)
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		containerService: cs,
+		apiVersion:       "vlabs",
+		runCommand:       mockExecuteCmd,
+		masterNodes: []v1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-master-1234-0",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-master-1234-2",
+				},
+			},
+		},
+	}
+	err := rcc.updateKubeconfig()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rcc.runCommand = mockFailingExecuteCmd
+	err = rcc.updateKubeconfig()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRotateCerts(t *testing.T) {
+	ctx := context.Background()
+	g := NewGomegaWithT(t)
+	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	cs.SetPropertiesDefaults(false, false)
+	mockClient := &armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+	rcc := certRotator{
+		logger:           logrus.NewEntry(logrus.New()),
+		containerService: cs,
+		runCommand:       mockExecuteCmd,
+		client:           mockClient,
+		masterNodes: []v1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-master-1234-0",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-master-1234-1",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-master-1234-2",
+				},
+			},
+		},
+		agentNodes: []v1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-agents-1234-0",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-agents-1234-1",
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "k8s-agents-1234-2",
+				},
+			},
+		},
+	}
+	err := rcc.rotateEtcd(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = rcc.rotateApiserver()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = rcc.rotateKubelet()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rcc.runCommand = mockFailingExecuteCmd
+	err = rcc.rotateEtcd(ctx)
+	g.Expect(err).To(HaveOccurred())
+
+	err = rcc.rotateApiserver()
+	g.Expect(err).To(HaveOccurred())
+
+	err = rcc.rotateKubelet()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed on 6 of 6 nodes: k8s-master-1234-0: executeCmd failed"))
+}
+
+func TestRotateCertsWithoutCommandRunner(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cs := api.CreateMockContainerService("testcluster", "1.10.13", 3, 2, false)
+	_, err := RotateCerts(context.Background(), &armhelpers.MockAKSEngineClient{}, logrus.NewEntry(logrus.New()), RotateCertsOptions{
+		ContainerService: cs,
+	})
+	g.Expect(err).To(MatchError("a command runner is required to replace the certificates on the nodes"))
+}
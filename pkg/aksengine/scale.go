@@ -0,0 +1,500 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ScaleOptions are the inputs of Scale
+type ScaleOptions struct {
+	// ContainerService is the api model of the deployed cluster, loaded as an update. Scale generates the
+	// template of the pool from it, which leaves it with that pool only.
+	ContainerService *api.ContainerService
+	// APIModelPath is the api model file ContainerService was loaded from, the new count of the pool is saved
+	// to it when it is set
+	APIModelPath   string
+	SubscriptionID string
+	ResourceGroup  string
+	// PoolName is the agent pool to scale, it can be left empty when the api model has a single pool
+	PoolName string
+	// NodeCount is the desired number of nodes of the pool
+	NodeCount int
+	// APIServerURL is the https endpoint of the apiserver, used to list the nodes of the pool and to cordon and
+	// drain them before scaling down. It is required to scale down a pool of availability sets.
+	APIServerURL string
+	// BuildTag is the version of aks-engine the template is stamped with
+	BuildTag   string
+	Translator *i18n.Translator
+}
+
+// ScaleResult is the output of Scale
+type ScaleResult struct {
+	PoolName string
+	// PreviousNodeCount is the number of VMs of the pool before scaling
+	PreviousNodeCount int
+	NodeCount         int
+	// DeploymentName is the deployment adding the new nodes, empty when the pool was scaled down
+	DeploymentName string
+	// DeletedVMs are the VMs deleted to scale down a pool of availability sets
+	DeletedVMs []string
+}
+
+// scaler holds the state of a scale operation
+type scaler struct {
+	options    ScaleOptions
+	client     armhelpers.AKSEngineClient
+	logger     *logrus.Entry
+	translator *i18n.Translator
+
+	agentPool      *api.AgentPoolProfile
+	agentPoolIndex int
+	nameSuffix     string
+	kubeconfig     string
+	nodes          []v1.Node
+}
+
+// Scale sets the number of nodes of an agent pool of a deployed cluster. Nodes are added by deploying the
+// template of the pool. Nodes of availability sets are removed by cordoning, draining and deleting their VMs,
+// scale sets are scaled down without draining their nodes.
+func Scale(ctx context.Context, client armhelpers.AKSEngineClient, logger *logrus.Entry, options ScaleOptions) (*ScaleResult, error) {
+	s := &scaler{
+		options:    options,
+		client:     client,
+		logger:     logger,
+		translator: translatorOrDefault(options.Translator),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.run(ctx)
+}
+
+// load finds the pool to scale and derives what identifies its VMs and nodes
+func (s *scaler) load() error {
+	cs := s.options.ContainerService
+	if s.options.PoolName == "" {
+		agentPoolCount := len(cs.Properties.AgentPoolProfiles)
+		if agentPoolCount > 1 {
+			return errors.New("ScaleOptions.PoolName is required if more than one agent pool is defined in the container service")
+		} else if agentPoolCount == 1 {
+			s.agentPool = cs.Properties.AgentPoolProfiles[0]
+			s.agentPoolIndex = 0
+			s.options.PoolName = cs.Properties.AgentPoolProfiles[0].Name
+		} else {
+			return errors.New("No node pools found to scale")
+		}
+	} else {
+		agentPoolIndex := -1
+		for i, pool := range cs.Properties.AgentPoolProfiles {
+			if pool.Name == s.options.PoolName {
+				agentPoolIndex = i
+				s.agentPool = pool
+				s.agentPoolIndex = i
+			}
+		}
+		if agentPoolIndex == -1 {
+			return errors.Errorf("node pool %s was not found in the deployed api model", s.options.PoolName)
+		}
+	}
+
+	//allows to identify VMs in the resource group that belong to this cluster.
+	s.nameSuffix = cs.Properties.GetClusterID()
+	s.logger.Debugf("Cluster ID used in all agent pools: %s", s.nameSuffix)
+
+	if s.options.APIServerURL != "" {
+		var err error
+		s.kubeconfig, err = engine.GenerateKubeConfig(cs.Properties, cs.Location)
+		if err != nil {
+			return errors.New("Unable to derive kubeconfig from api model")
+		}
+	}
+	return nil
+}
+
+func (s *scaler) run(ctx context.Context) (*ScaleResult, error) {
+	cs := s.options.ContainerService
+	poolName := s.options.PoolName
+	result := &ScaleResult{
+		PoolName:  poolName,
+		NodeCount: s.options.NodeCount,
+	}
+	orchestratorInfo := cs.Properties.OrchestratorProfile
+	var currentNodeCount, highestUsedIndex, index, winPoolIndex int
+	winPoolIndex = -1
+	indexes := make([]int, 0)
+	indexToVM := make(map[int]string)
+
+	// Get nodes list from the k8s API before scaling for the desired pool
+	if s.options.APIServerURL != "" && orchestratorInfo.OrchestratorType == api.Kubernetes {
+		nodes, err := operations.GetNodes(s.client, s.logger, s.options.APIServerURL, s.kubeconfig, time.Duration(5)*time.Minute, poolName, -1)
+		if err == nil && nodes != nil {
+			s.nodes = nodes
+		}
+	}
+
+	if s.agentPool.IsAvailabilitySets() {
+		availabilitySetIDs := []string{}
+
+		for vmsListPage, err := s.client.ListVirtualMachines(ctx, s.options.ResourceGroup); vmsListPage.NotDone(); err = vmsListPage.Next() {
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get VMs in the resource group")
+			} else if len(vmsListPage.Values()) < 1 {
+				return nil, errors.New("The provided resource group does not contain any VMs")
+			}
+			for _, vm := range vmsListPage.Values() {
+				vmName := *vm.Name
+				if !s.vmInAgentPool(vmName, vm.Tags) {
+					continue
+				}
+
+				if vm.AvailabilitySet != nil {
+					availabilitySetIDs = append(availabilitySetIDs, *vm.AvailabilitySet.ID)
+				}
+
+				osPublisher := vm.StorageProfile.ImageReference.Publisher
+				if osPublisher != nil && strings.EqualFold(*osPublisher, "MicrosoftWindowsServer") {
+					_, _, winPoolIndex, index, err = utils.WindowsVMNameParts(vmName)
+				} else {
+					_, _, index, err = utils.K8sLinuxVMNameParts(vmName)
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				indexToVM[index] = vmName
+				indexes = append(indexes, index)
+			}
+		}
+		sortedIndexes := sort.IntSlice(indexes)
+		sortedIndexes.Sort()
+		indexes = []int(sortedIndexes)
+		currentNodeCount = len(indexes)
+		result.PreviousNodeCount = currentNodeCount
+
+		if currentNodeCount == s.options.NodeCount {
+			s.printScaleTargetEqualsExisting(currentNodeCount)
+			return result, nil
+		}
+		highestUsedIndex = indexes[len(indexes)-1]
+
+		// set the VMAS platformFaultDomainCount to match the existing value
+		fdCount, err := s.client.GetAvailabilitySetFaultDomainCount(ctx, s.options.ResourceGroup, availabilitySetIDs)
+		if err != nil {
+			return nil, err
+		}
+		cs.SetPlatformFaultDomainCount(fdCount)
+
+		// VMAS Scale down Scenario
+		if currentNodeCount > s.options.NodeCount {
+			if s.options.APIServerURL == "" {
+				return nil, errors.New("ScaleOptions.APIServerURL is required to scale down a kubernetes cluster's agent pool")
+			}
+
+			if s.nodes != nil {
+				if len(s.nodes) == 1 {
+					s.logger.Infof("There is %d node in pool %s before scaling down to %d:\n", len(s.nodes), poolName, s.options.NodeCount)
+				} else {
+					s.logger.Infof("There are %d nodes in pool %s before scaling down to %d:\n", len(s.nodes), poolName, s.options.NodeCount)
+				}
+				operations.PrintNodes(s.nodes)
+				numNodesFromK8sAPI := len(s.nodes)
+				if currentNodeCount != numNodesFromK8sAPI {
+					s.logger.Warnf("There are %d VMs named \"*%s*\" in the resource group %s, but there are %d nodes named \"*%s*\" in the Kubernetes cluster\n", currentNodeCount, poolName, s.options.ResourceGroup, numNodesFromK8sAPI, poolName)
+				} else {
+					nodesToDelete := currentNodeCount - s.options.NodeCount
+					if nodesToDelete > 1 {
+						s.logger.Infof("%d nodes will be deleted\n", nodesToDelete)
+					} else {
+						s.logger.Infof("%d node will be deleted\n", nodesToDelete)
+					}
+				}
+			}
+
+			vmsToDelete := make([]string, 0)
+			for i := currentNodeCount - 1; i >= s.options.NodeCount; i-- {
+				index = indexes[i]
+				vmsToDelete = append(vmsToDelete, indexToVM[index])
+			}
+
+			for _, node := range vmsToDelete {
+				s.logger.Infof("Node %s will be cordoned and drained\n", node)
+			}
+			if orchestratorInfo.OrchestratorType == api.Kubernetes {
+				err := s.drainNodes(vmsToDelete)
+				if err != nil {
+					return nil, errors.Wrap(err, "Got error while draining the nodes to be deleted")
+				}
+			}
+
+			for _, node := range vmsToDelete {
+				s.logger.Infof("Node %s's VM will be deleted\n", node)
+			}
+			errList := operations.ScaleDownVMs(s.client, s.logger, s.options.SubscriptionID, s.options.ResourceGroup, vmsToDelete...)
+			if errList != nil {
+				var err error
+				format := "Node '%s' failed to delete with error: '%s'"
+				for element := errList.Front(); element != nil; element = element.Next() {
+					vmError, ok := element.Value.(*operations.VMScalingErrorDetails)
+					if ok {
+						if err == nil {
+							err = errors.Errorf(format, vmError.Name, vmError.Error.Error())
+						} else {
+							err = errors.Wrapf(err, format, vmError.Name, vmError.Error.Error())
+						}
+					}
+				}
+				return nil, err
+			}
+			result.DeletedVMs = vmsToDelete
+			s.printNodesAfterScaling()
+
+			return result, s.saveAPIModel()
+		}
+	} else {
+		for vmssListPage, err := s.client.ListVirtualMachineScaleSets(ctx, s.options.ResourceGroup); vmssListPage.NotDone(); err = vmssListPage.NextWithContext(ctx) {
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get VMSS list in the resource group")
+			}
+			for _, vmss := range vmssListPage.Values() {
+				vmName := *vmss.Name
+				if !s.vmInAgentPool(vmName, vmss.Tags) {
+					continue
+				}
+
+				if vmss.Sku != nil {
+					currentNodeCount = int(*vmss.Sku.Capacity)
+					result.PreviousNodeCount = currentNodeCount
+					if int(*vmss.Sku.Capacity) == s.options.NodeCount {
+						s.printScaleTargetEqualsExisting(currentNodeCount)
+						return result, nil
+					} else if int(*vmss.Sku.Capacity) > s.options.NodeCount {
+						s.logger.Warnf("VMSS scale down is an alpha feature: VMSS VM nodes will not be cordoned and drained before scaling down!")
+					}
+				}
+
+				osPublisher := vmss.VirtualMachineProfile.StorageProfile.ImageReference.Publisher
+				if osPublisher != nil && strings.EqualFold(*osPublisher, "MicrosoftWindowsServer") {
+					_, _, winPoolIndex, _, err = utils.WindowsVMNameParts(vmName)
+					s.logger.Errorln(err)
+				}
+
+				currentNodeCount = int(*vmss.Sku.Capacity)
+				highestUsedIndex = 0
+			}
+		}
+	}
+
+	templateGenerator, err := engine.InitializeTemplateGenerator(engine.Context{Translator: s.translator})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize template generator")
+	}
+
+	// Our templates generate a range of nodes based on a count and offset, it is possible for there to be holes in the template
+	// So we need to set the count in the template to get enough nodes for the range, if there are holes that number will be larger than the desired count
+	countForTemplate := s.options.NodeCount
+	if highestUsedIndex != 0 {
+		countForTemplate += highestUsedIndex + 1 - currentNodeCount
+	}
+	s.agentPool.Count = countForTemplate
+	cs.Properties.AgentPoolProfiles = []*api.AgentPoolProfile{s.agentPool}
+
+	_, err = cs.SetPropertiesDefaults(false, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in SetPropertiesDefaults")
+	}
+	template, parameters, err := templateGenerator.GenerateTemplateV2(cs, engine.DefaultGeneratorCode, s.options.BuildTag)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating template")
+	}
+
+	if template, err = transform.PrettyPrintArmTemplate(template); err != nil {
+		return nil, errors.Wrap(err, "error pretty printing template")
+	}
+
+	templateJSON := make(map[string]interface{})
+	parametersJSON := make(map[string]interface{})
+
+	err = json.Unmarshal([]byte(template), &templateJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling template")
+	}
+
+	err = json.Unmarshal([]byte(parameters), &parametersJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling parameters")
+	}
+
+	transformer := transform.Transformer{Translator: s.translator}
+
+	addValue(parametersJSON, s.agentPool.Name+"Count", countForTemplate)
+
+	// The agent pool is set to index 0 for the scale operation, we need to overwrite the template variables that rely on pool index.
+	if winPoolIndex != -1 {
+		templateJSON["variables"].(map[string]interface{})[s.agentPool.Name+"Index"] = winPoolIndex
+		templateJSON["variables"].(map[string]interface{})[s.agentPool.Name+"VMNamePrefix"] = cs.Properties.GetAgentVMPrefix(s.agentPool, winPoolIndex)
+	}
+	if orchestratorInfo.OrchestratorType == api.Kubernetes {
+		if orchestratorInfo.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+			err = transformer.NormalizeForK8sSLBScalingOrUpgrade(s.logger, templateJSON)
+			if err != nil {
+				return nil, errors.Wrap(err, "error transforming the template for scaling with SLB")
+			}
+		}
+		err = transformer.NormalizeForK8sVMASScalingUp(s.logger, templateJSON)
+		if err != nil {
+			return nil, errors.Wrap(err, "error transforming the template for scaling template")
+		}
+		if s.agentPool.IsAvailabilitySets() {
+			addValue(parametersJSON, fmt.Sprintf("%sOffset", s.agentPool.Name), highestUsedIndex+1)
+		}
+	}
+
+	if s.nodes != nil {
+		s.logger.Infof("Nodes in pool %s before scaling:\n", poolName)
+		operations.PrintNodes(s.nodes)
+	}
+	result.DeploymentName = newDeploymentName(s.options.ResourceGroup)
+	_, err = s.client.DeployTemplate(
+		ctx,
+		s.options.ResourceGroup,
+		result.DeploymentName,
+		templateJSON,
+		parametersJSON)
+	if err != nil {
+		return nil, err
+	}
+	s.printNodesAfterScaling()
+
+	return result, s.saveAPIModel()
+}
+
+// saveAPIModel saves the new count of the pool to the api model file, as it was loaded rather than as defaulted
+func (s *scaler) saveAPIModel() error {
+	if s.options.APIModelPath == "" {
+		return nil
+	}
+	apiloader := &api.Apiloader{
+		Translator: s.translator,
+	}
+	cs, apiVersion, err := apiloader.LoadContainerServiceFromFile(s.options.APIModelPath, false, true, nil)
+	if err != nil {
+		return err
+	}
+	cs.Properties.AgentPoolProfiles[s.agentPoolIndex].Count = s.options.NodeCount
+
+	b, err := apiloader.SerializeContainerService(cs, apiVersion)
+	if err != nil {
+		return err
+	}
+
+	f := helpers.FileSaver{
+		Translator: s.translator,
+	}
+	dir, file := filepath.Split(s.options.APIModelPath)
+	return f.SaveFile(dir, file, b)
+}
+
+func (s *scaler) vmInAgentPool(vmName string, tags map[string]*string) bool {
+	// Try to locate the VM's agent pool by expected tags.
+	if tags != nil {
+		if poolName, ok := tags["poolName"]; ok {
+			if nameSuffix, ok := tags["resourceNameSuffix"]; ok {
+				// Use strings.Contains for the nameSuffix as the Windows Agent Pools use only
+				// a substring of the first 5 characters of the entire nameSuffix.
+				if strings.EqualFold(*poolName, s.options.PoolName) && strings.Contains(s.nameSuffix, *nameSuffix) {
+					return true
+				}
+			}
+		}
+	}
+
+	// Fall back to checking the VM name to see if it fits the naming pattern.
+	return strings.Contains(vmName, s.nameSuffix[:5]) && strings.Contains(vmName, s.options.PoolName)
+}
+
+type paramsMap map[string]interface{}
+
+func addValue(m paramsMap, k string, v interface{}) {
+	m[k] = paramsMap{
+		"value": v,
+	}
+}
+
+func (s *scaler) drainNodes(vmsToDelete []string) error {
+	numVmsToDrain := len(vmsToDelete)
+	errChan := make(chan *operations.VMScalingErrorDetails, numVmsToDrain)
+	defer close(errChan)
+	for _, vmName := range vmsToDelete {
+		go func(vmName string) {
+			err := operations.SafelyDrainNode(s.client, s.logger,
+				s.options.APIServerURL, s.kubeconfig, vmName, time.Duration(60)*time.Minute)
+			if err != nil {
+				s.logger.Errorf("Failed to drain node %s, got error %v", vmName, err)
+				errChan <- &operations.VMScalingErrorDetails{Error: err, Name: vmName}
+				return
+			}
+			errChan <- nil
+		}(vmName)
+	}
+
+	for i := 0; i < numVmsToDrain; i++ {
+		errDetails := <-errChan
+		if errDetails != nil {
+			return errors.Wrapf(errDetails.Error, "Node %q failed to drain with error", errDetails.Name)
+		}
+	}
+
+	return nil
+}
+
+// printNodesAfterScaling prints the nodes of the pool once they reach the desired count
+func (s *scaler) printNodesAfterScaling() {
+	if s.nodes == nil {
+		return
+	}
+	nodes, err := operations.GetNodes(s.client, s.logger, s.options.APIServerURL, s.kubeconfig, time.Duration(5)*time.Minute, s.options.PoolName, s.options.NodeCount)
+	if err == nil && nodes != nil {
+		s.nodes = nodes
+		s.logger.Infof("Nodes in pool %s after scaling:\n", s.options.PoolName)
+		operations.PrintNodes(s.nodes)
+	} else {
+		s.logger.Warningf("Unable to get nodes in pool %s after scaling:\n", s.options.PoolName)
+	}
+}
+
+func (s *scaler) printScaleTargetEqualsExisting(currentNodeCount int) {
+	var printNodes bool
+	trailingChar := "."
+	if s.nodes != nil {
+		printNodes = true
+		trailingChar = ":"
+	}
+	s.logger.Infof("Node pool %s is already at the desired count %d%s", s.options.PoolName, s.options.NodeCount, trailingChar)
+	if printNodes {
+		operations.PrintNodes(s.nodes)
+	}
+	numNodesFromK8sAPI := len(s.nodes)
+	if currentNodeCount != numNodesFromK8sAPI {
+		s.logger.Warnf("There are %d nodes named \"*%s*\" in the Kubernetes cluster, but there are %d VMs named \"*%s*\" in the resource group %s\n", numNodesFromK8sAPI, s.options.PoolName, currentNodeCount, s.options.PoolName, s.options.ResourceGroup)
+	}
+}
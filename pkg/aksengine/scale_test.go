@@ -0,0 +1,164 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+func newScaleTestContainerService(poolNames ...string) *api.ContainerService {
+	cs := api.CreateMockContainerService("testcluster", common.GetDefaultKubernetesVersion(false), 1, 1, false)
+	cs.Properties.ClusterID = "12345678"
+	template := cs.Properties.AgentPoolProfiles[0]
+	cs.Properties.AgentPoolProfiles = nil
+	for _, name := range poolNames {
+		pool := *template
+		pool.Name = name
+		cs.Properties.AgentPoolProfiles = append(cs.Properties.AgentPoolProfiles, &pool)
+	}
+	return cs
+}
+
+func TestScalePoolSelection(t *testing.T) {
+	cases := []struct {
+		name          string
+		pools         []string
+		poolName      string
+		expectedPool  string
+		expectedIndex int
+		expectedErr   string
+	}{
+		{
+			name:         "single pool",
+			pools:        []string{"agentpool1"},
+			expectedPool: "agentpool1",
+		},
+		{
+			name:          "named pool",
+			pools:         []string{"agentpool1", "agentpool2"},
+			poolName:      "agentpool2",
+			expectedPool:  "agentpool2",
+			expectedIndex: 1,
+		},
+		{
+			name:        "several pools without a name",
+			pools:       []string{"agentpool1", "agentpool2"},
+			expectedErr: "ScaleOptions.PoolName is required if more than one agent pool is defined in the container service",
+		},
+		{
+			name:        "unknown pool",
+			pools:       []string{"agentpool1", "agentpool2"},
+			poolName:    "agentpool3",
+			expectedErr: "node pool agentpool3 was not found in the deployed api model",
+		},
+		{
+			name:        "no pools",
+			expectedErr: "No node pools found to scale",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			s := &scaler{
+				options: ScaleOptions{
+					ContainerService: newScaleTestContainerService(c.pools...),
+					PoolName:         c.poolName,
+				},
+				client: &armhelpers.MockAKSEngineClient{},
+				logger: logrus.NewEntry(logrus.New()),
+			}
+			err := s.load()
+			if c.expectedErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(c.expectedErr))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(s.options.PoolName).To(Equal(c.expectedPool))
+			g.Expect(s.agentPool.Name).To(Equal(c.expectedPool))
+			g.Expect(s.agentPoolIndex).To(Equal(c.expectedIndex))
+		})
+	}
+}
+
+func TestScaleUp(t *testing.T) {
+	cases := []struct {
+		name                string
+		availabilityProfile string
+	}{
+		{
+			name:                "availability set",
+			availabilityProfile: api.AvailabilitySet,
+		},
+		{
+			name:                "scale set",
+			availabilityProfile: api.VirtualMachineScaleSets,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			mockClient := &armhelpers.MockAKSEngineClient{}
+			imageReference := &compute.ImageReference{Publisher: to.StringPtr("Canonical")}
+			mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+				vm := mockClient.MakeFakeVirtualMachine(armhelpers.DefaultFakeVMName, common.GetDefaultKubernetesVersion(false))
+				vm.AvailabilitySet = &compute.SubResource{ID: to.StringPtr("MockAvailabilitySet")}
+				vm.StorageProfile.ImageReference = imageReference
+				return []compute.VirtualMachine{vm}
+			}
+			mockClient.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
+				return []compute.VirtualMachineScaleSet{
+					{
+						Name: to.StringPtr("k8s-agentpool1-12345678-vmss"),
+						Tags: map[string]*string{
+							"poolName":           to.StringPtr("agentpool1"),
+							"resourceNameSuffix": to.StringPtr("12345678"),
+						},
+						Sku: &compute.Sku{Capacity: to.Int64Ptr(1)},
+						VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+							VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+								StorageProfile: &compute.VirtualMachineScaleSetStorageProfile{
+									ImageReference: imageReference,
+								},
+							},
+						},
+					},
+				}
+			}
+
+			cs := newScaleTestContainerService("agentpool1")
+			cs.Properties.AgentPoolProfiles[0].AvailabilityProfile = c.availabilityProfile
+			if c.availabilityProfile == api.VirtualMachineScaleSets {
+				cs.Properties.AgentPoolProfiles[0].StorageProfile = api.ManagedDisks
+			}
+
+			result, err := Scale(context.Background(), mockClient, logrus.NewEntry(logrus.New()), ScaleOptions{
+				ContainerService: cs,
+				SubscriptionID:   "DEC923E3-1EF1-4745-9516-37906D56DEC4",
+				ResourceGroup:    "test-rg",
+				NodeCount:        2,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.PoolName).To(Equal("agentpool1"))
+			g.Expect(result.PreviousNodeCount).To(Equal(1))
+			g.Expect(result.NodeCount).To(Equal(2))
+			g.Expect(result.DeploymentName).NotTo(BeEmpty())
+			g.Expect(result.DeletedVMs).To(BeEmpty())
+			g.Expect(cs.Properties.AgentPoolProfiles[0].Count).To(Equal(2))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// UpgradeOptions are the inputs of Upgrade
+type UpgradeOptions struct {
+	// ContainerService is the api model of the deployed cluster, loaded as an update
+	ContainerService *api.ContainerService
+	// APIVersion is the version of the API the api model is written in
+	APIVersion string
+	// APIModelPath is where the upgraded api model is saved when it is set
+	APIModelPath   string
+	SubscriptionID string
+	ResourceGroup  string
+	// Version is the Kubernetes version to upgrade to. Upgrade does not check it, callers not forcing the upgrade
+	// check it first with ValidateUpgradeVersion
+	Version string
+	// Force upgrades the nodes already at Version too, and goes on when the cluster autoscaler cannot be paused
+	Force bool
+	// StepTimeout is how long to wait for each VM to be upgraded, the upgrader's default when nil
+	StepTimeout *time.Duration
+	// CordonDrainTimeout is how long to wait for each node to be cordoned and drained, the upgrader's default
+	// when nil
+	CordonDrainTimeout *time.Duration
+	// Secrets, when set, holds the secrets resolved in the api model, put back where they came from when it is saved
+	Secrets *api.SecretStore
+	// BuildTag is the version of aks-engine the templates are stamped with
+	BuildTag   string
+	Translator *i18n.Translator
+}
+
+// UpgradeResult is the output of Upgrade
+type UpgradeResult struct {
+	// NameSuffix identifies the VMs of the cluster in its resource group
+	NameSuffix string
+	Version    string
+}
+
+// Upgrade upgrades the masters and agent pools of a deployed Kubernetes cluster to a new version, replacing their
+// nodes one at a time, and saves the upgraded api model.
+func Upgrade(ctx context.Context, client armhelpers.AKSEngineClient, logger *logrus.Entry, options UpgradeOptions) (*UpgradeResult, error) {
+	translator := translatorOrDefault(options.Translator)
+	cs := options.ContainerService
+	cs.Properties.OrchestratorProfile.OrchestratorVersion = options.Version
+
+	upgradeCluster := kubernetesupgrade.UpgradeCluster{
+		Translator:         translator,
+		Logger:             logger,
+		Client:             client,
+		StepTimeout:        options.StepTimeout,
+		CordonDrainTimeout: options.CordonDrainTimeout,
+		Force:              options.Force,
+	}
+	upgradeCluster.ClusterTopology = kubernetesupgrade.ClusterTopology{}
+	upgradeCluster.SubscriptionID = options.SubscriptionID
+	upgradeCluster.ResourceGroup = options.ResourceGroup
+	upgradeCluster.DataModel = cs
+	//allows to identify VMs in the resource group that belong to this cluster.
+	upgradeCluster.NameSuffix = cs.Properties.GetClusterID()
+	upgradeCluster.AgentPoolsToUpgrade = map[string]bool{kubernetesupgrade.MasterPoolName: true}
+	for _, agentPool := range cs.Properties.AgentPoolProfiles {
+		upgradeCluster.AgentPoolsToUpgrade[agentPool.Name] = true
+	}
+	logger.Infof("Upgrading cluster with name suffix: %s", upgradeCluster.NameSuffix)
+	result := &UpgradeResult{
+		NameSuffix: upgradeCluster.NameSuffix,
+		Version:    options.Version,
+	}
+
+	kubeConfig, err := engine.GenerateKubeConfig(cs.Properties, cs.Location)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating kubeconfig")
+	}
+
+	if err = upgradeCluster.UpgradeCluster(client, kubeConfig, options.BuildTag); err != nil {
+		return nil, errors.Wrap(err, "upgrading cluster")
+	}
+
+	// Save the new apimodel to reflect the cluster's state.
+	if options.APIModelPath != "" {
+		if err = saveAPIModel(cs, options.APIVersion, options.APIModelPath, options.Secrets, translator); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ValidateUpgradeVersion returns an error unless version is one of the upgrades of the Kubernetes version of cs
+func ValidateUpgradeVersion(cs *api.ContainerService, version string) error {
+	// Get available upgrades for container service.
+	orchestratorInfo, err := api.GetOrchestratorVersionProfile(cs.Properties.OrchestratorProfile, cs.Properties.HasWindows())
+	if err != nil {
+		return errors.Wrap(err, "error getting list of available upgrades")
+	}
+
+	for _, up := range orchestratorInfo.Upgrades {
+		if up.OrchestratorVersion == version {
+			return nil
+		}
+	}
+	return errors.Errorf("upgrading from Kubernetes version %s to version %s is not supported", cs.Properties.OrchestratorProfile.OrchestratorVersion, version)
+}
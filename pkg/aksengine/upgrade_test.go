@@ -0,0 +1,58 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package aksengine
+
+import (
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+)
+
+func TestValidateUpgradeVersion(t *testing.T) {
+	validVersionsBackup := common.AllKubernetesSupportedVersions
+	defer func() { common.AllKubernetesSupportedVersions = validVersionsBackup }()
+	common.AllKubernetesSupportedVersions = map[string]bool{
+		"1.10.12": true,
+		"1.10.13": true,
+		"1.11.9":  false,
+	}
+
+	cases := []struct {
+		name        string
+		version     string
+		expectedErr string
+	}{
+		{
+			name:    "patch upgrade",
+			version: "1.10.13",
+		},
+		{
+			name:        "same version",
+			version:     "1.10.12",
+			expectedErr: "upgrading from Kubernetes version 1.10.12 to version 1.10.12 is not supported",
+		},
+		{
+			name:        "unsupported version",
+			version:     "1.11.9",
+			expectedErr: "upgrading from Kubernetes version 1.10.12 to version 1.11.9 is not supported",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			cs := api.CreateMockContainerService("testcluster", "1.10.12", 3, 2, false)
+			err := ValidateUpgradeVersion(cs, c.version)
+			if c.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.expectedErr {
+				t.Fatalf("expected error %q, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
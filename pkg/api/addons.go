@@ -345,6 +345,21 @@ func (cs *ContainerService) setAddonsConfig(isUpdate bool) {
 		},
 	}
 
+	defaultGMSAWebhookAddonsConfig := KubernetesAddon{
+		Name:    GMSAWebhookAddonName,
+		Enabled: to.BoolPtr(cs.Properties.WindowsProfile.HasDomainJoin()),
+		Containers: []KubernetesContainerSpec{
+			{
+				Name:           GMSAWebhookAddonName,
+				Image:          "sigwindowstools/k8s-gmsa-webhook:v0.1.0",
+				CPURequests:    "10m",
+				MemoryRequests: "64Mi",
+				CPULimits:      "100m",
+				MemoryLimits:   "128Mi",
+			},
+		},
+	}
+
 	defaultAddons := []KubernetesAddon{
 		defaultsHeapsterAddonsConfig,
 		defaultTillerAddonsConfig,
@@ -365,6 +380,7 @@ func (cs *ContainerService) setAddonsConfig(isUpdate bool) {
 		defaultsCalicoDaemonSetAddonsConfig,
 		defaultsAADPodIdentityAddonsConfig,
 		defaultAppGwAddonsConfig,
+		defaultGMSAWebhookAddonsConfig,
 	}
 	// Add default addons specification, if no user-provided spec exists
 	if o.KubernetesConfig.Addons == nil {
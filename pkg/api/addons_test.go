@@ -3198,3 +3198,52 @@ func TestSetAddonsConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestSetAddonsConfigGMSAWebhook(t *testing.T) {
+	cases := []struct {
+		name            string
+		windowsProfile  *WindowsProfile
+		expectedEnabled bool
+	}{
+		{
+			name: "no windows profile",
+		},
+		{
+			name: "windows without domain join",
+			windowsProfile: &WindowsProfile{
+				AdminUsername: "azureuser",
+			},
+		},
+		{
+			name: "windows with domain join",
+			windowsProfile: &WindowsProfile{
+				AdminUsername: "azureuser",
+				DomainJoin: &WindowsDomainJoin{
+					Domain:   "corp.contoso.com",
+					UserName: "joiner",
+				},
+			},
+			expectedEnabled: true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			cs := &ContainerService{
+				Properties: &Properties{
+					OrchestratorProfile: &OrchestratorProfile{
+						OrchestratorVersion: "1.14.3",
+						KubernetesConfig:    &KubernetesConfig{},
+					},
+					WindowsProfile: c.windowsProfile,
+				},
+			}
+			cs.setAddonsConfig(false)
+			if enabled := cs.Properties.OrchestratorProfile.KubernetesConfig.IsAddonEnabled(GMSAWebhookAddonName); enabled != c.expectedEnabled {
+				t.Fatalf("expected addon %s to have Enabled value %t, instead got %t", GMSAWebhookAddonName, c.expectedEnabled, enabled)
+			}
+		})
+	}
+}
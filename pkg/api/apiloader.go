@@ -4,7 +4,6 @@
 package api
 
 import (
-	"crypto/rand"
 	"encoding/json"
 	"io/ioutil"
 	"reflect"
@@ -37,6 +36,8 @@ const (
 // Apiloader represents the object that loads api model
 type Apiloader struct {
 	Translator *i18n.Translator
+	// Entropy is the source of the generated SSH key, crypto/rand when nil
+	Entropy *helpers.Entropy
 }
 
 // LoadContainerServiceFromFile loads an AKS Cluster API Model from a JSON file
@@ -342,7 +343,7 @@ func (a *Apiloader) LoadContainerServiceForAgentPoolOnlyCluster(
 		if !hasExistingCS && managedCluster.Properties.LinuxProfile == nil {
 			linuxProfile := &v20180331.LinuxProfile{}
 			linuxProfile.AdminUsername = "azureuser"
-			_, publicKey, err := helpers.CreateSSH(rand.Reader, a.Translator)
+			_, publicKey, err := helpers.CreateSSHWithEntropy(a.Entropy, a.Translator)
 			if err != nil {
 				return nil, IsSSHAutoGenerated, err
 			}
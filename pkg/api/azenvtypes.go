@@ -49,6 +49,7 @@ type KubernetesSpecConfig struct {
 	VnetCNILinuxPluginsDownloadURL   string `json:"vnetCNILinuxPluginsDownloadURL,omitempty"`
 	VnetCNIWindowsPluginsDownloadURL string `json:"vnetCNIWindowsPluginsDownloadURL,omitempty"`
 	ContainerdDownloadURLBase        string `json:"containerdDownloadURLBase,omitempty"`
+	WindowsContainerdDownloadURL     string `json:"windowsContainerdDownloadURL,omitempty"`
 }
 
 //AzureEndpointConfig describes an Azure endpoint
@@ -80,6 +81,7 @@ var (
 		VnetCNILinuxPluginsDownloadURL:   "https://acs-mirror.azureedge.net/cni/azure-vnet-cni-linux-amd64-" + AzureCniPluginVerLinux + ".tgz",
 		VnetCNIWindowsPluginsDownloadURL: "https://acs-mirror.azureedge.net/cni/azure-vnet-cni-windows-amd64-" + AzureCniPluginVerWindows + ".zip",
 		ContainerdDownloadURLBase:        "https://storage.googleapis.com/cri-containerd-release/",
+		WindowsContainerdDownloadURL:     "https://github.com/containerd/containerd/releases/download/v" + WindowsContainerdVer + "/containerd-" + WindowsContainerdVer + "-windows-amd64.tar.gz",
 	}
 
 	//DefaultDCOSSpecConfig is the default DC/OS binary download URL.
@@ -247,6 +249,7 @@ var (
 			VnetCNILinuxPluginsDownloadURL:   "https://mirror.azk8s.cn/kubernetes/azure-container-networking/azure-vnet-cni-linux-amd64-" + AzureCniPluginVerLinux + ".tgz",
 			VnetCNIWindowsPluginsDownloadURL: "https://mirror.azk8s.cn/kubernetes/azure-container-networking/azure-vnet-cni-windows-amd64-" + AzureCniPluginVerWindows + ".zip",
 			ContainerdDownloadURLBase:        "https://mirror.azk8s.cn/kubernetes/containerd/",
+			WindowsContainerdDownloadURL:     DefaultKubernetesSpecConfig.WindowsContainerdDownloadURL,
 		},
 		DCOSSpecConfig: DCOSSpecConfig{
 			DCOS188BootstrapDownloadURL:     fmt.Sprintf(AzureChinaCloudDCOSBootstrapDownloadURL, "5df43052907c021eeb5de145419a3da1898c58a5"),
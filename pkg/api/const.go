@@ -92,6 +92,10 @@ const (
 	DefaultVnetResourceGroupSegmentIndex = 4
 	// DefaultVnetNameResourceSegmentIndex specifies the default virtual network name segment index.
 	DefaultVnetNameResourceSegmentIndex = 8
+	// DefaultResourceGroupSegmentIndex specifies the resource group segment index of a top-level resource ID.
+	DefaultResourceGroupSegmentIndex = 4
+	// DefaultResourceNameSegmentIndex specifies the name segment index of a top-level resource ID.
+	DefaultResourceNameSegmentIndex = 8
 	// VirtualMachineScaleSets means that the vms are in a virtual machine scaleset
 	VirtualMachineScaleSets = "VirtualMachineScaleSets"
 	// ScaleSetPriorityRegular is the default ScaleSet Priority
@@ -111,6 +115,14 @@ const (
 	Containerd     = "containerd"
 )
 
+// Windows container isolation modes
+const (
+	// WindowsIsolationProcess runs the containers of Windows nodes as processes sharing the kernel of the node
+	WindowsIsolationProcess = "process"
+	// WindowsIsolationHyperV runs each pod of Windows nodes in its own Hyper-V utility VM
+	WindowsIsolationHyperV = "hyperv"
+)
+
 // storage profiles
 const (
 	// StorageAccount means that the nodes use raw storage accounts for their os and attached volumes
@@ -157,6 +169,14 @@ const (
 	DefaultLoadBalancerSku = "Basic"
 	// StandardLoadBalancerSku is the string const for Azure Standard Load Balancer
 	StandardLoadBalancerSku = "Standard"
+	// OutboundTypeLoadBalancer routes outbound traffic through the cluster load balancers
+	OutboundTypeLoadBalancer = "loadBalancer"
+	// OutboundTypeUserDefinedRouting routes outbound traffic through the user-defined routes of an existing route table
+	OutboundTypeUserDefinedRouting = "userDefinedRouting"
+	// OutboundTypeNATGateway routes outbound traffic through a NAT gateway associated with the cluster subnets
+	OutboundTypeNATGateway = "natGateway"
+	// DefaultOutboundType determines the aks-engine provided default for the outbound type of the cluster
+	DefaultOutboundType = OutboundTypeLoadBalancer
 	// DefaultExcludeMasterFromStandardLB determines the aks-engine provided default for excluding master nodes from standard load balancer.
 	DefaultExcludeMasterFromStandardLB = true
 	// DefaultSecureKubeletEnabled determines the aks-engine provided default for securing kubelet communications
@@ -205,8 +225,14 @@ const (
 	IPMASQAgentAddonName = "ip-masq-agent"
 	// PodSecurityPolicyAddonName is the name of the PodSecurityPolicy addon
 	PodSecurityPolicyAddonName = "pod-security-policy"
+	// GMSAWebhookAddonName is the name of the gMSA credential spec admission webhook addon
+	GMSAWebhookAddonName = "gmsa-webhook"
 	// DefaultPrivateClusterEnabled determines the aks-engine provided default for enabling kubernetes Private Cluster
 	DefaultPrivateClusterEnabled = false
+	// DefaultPrivateClusterFullyPrivate determines the aks-engine provided default for removing all public IP addresses from a Private Cluster
+	DefaultPrivateClusterFullyPrivate = false
+	// DefaultPrivateDNSZoneSuffix is appended to the master DNS prefix to name the private DNS zone of a fully private cluster
+	DefaultPrivateDNSZoneSuffix = ".privatecluster.internal"
 	// NetworkPolicyAzure is the string expression for Azure CNI network policy manager
 	NetworkPolicyAzure = "azure"
 	// NetworkPolicyNone is the string expression for the deprecated NetworkPolicy usage pattern "none"
@@ -338,6 +364,9 @@ const (
 	// CNIPluginVer specifies the version of CNI implementation
 	// https://github.com/containernetworking/plugins
 	CNIPluginVer = "v0.7.5"
+	// WindowsContainerdVer specifies the version of containerd installed on Windows nodes using the containerd runtime
+	// https://github.com/containerd/containerd/releases
+	WindowsContainerdVer = "1.6.2"
 )
 
 const (
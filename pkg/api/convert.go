@@ -0,0 +1,100 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConvertAPIModel converts an api model to the API version toVersion. It returns the converted api model along
+// with the JSON paths of the fields of the api model, such as properties.masterProfile.availabilityProfile, that
+// were dropped or changed because toVersion cannot represent them. The api model is neither validated nor
+// defaulted, other than by the defaults the API version it is written in sets when it is loaded.
+func (a *Apiloader) ConvertAPIModel(contents []byte, toVersion string) ([]byte, []string, error) {
+	cs, _, err := a.DeserializeContainerService(contents, false, true, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted, err := a.SerializeContainerService(cs, toVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	// what the target version kept of the api model is what loading it back gives
+	roundTripped, _, err := a.DeserializeContainerService(converted, false, true, nil)
+	if err != nil {
+		return nil, nil, a.Translator.Errorf("error loading the api model converted to %s: %s", toVersion, err.Error())
+	}
+
+	var lost []string
+	walkLostFields(reflect.ValueOf(cs).Elem(), reflect.ValueOf(roundTripped).Elem(), "", func(path string) {
+		lost = append(lost, path)
+	})
+	return converted, lost, nil
+}
+
+// walkLostFields calls fn with the JSON paths of the fields set in from whose values differ in to. A struct, list or
+// map missing altogether from to is reported once rather than field by field.
+func walkLostFields(from, to reflect.Value, path string, fn func(path string)) {
+	if isZero(from) {
+		return
+	}
+	switch from.Kind() {
+	case reflect.Ptr:
+		if to.IsNil() {
+			fn(path)
+			return
+		}
+		walkLostFields(from.Elem(), to.Elem(), path, fn)
+	case reflect.Struct:
+		for i := 0; i < from.NumField(); i++ {
+			field, fieldPath, _, ok := structField(from, i, path)
+			if !ok {
+				continue
+			}
+			walkLostFields(field, to.Field(i), fieldPath, fn)
+		}
+	case reflect.Slice:
+		if from.Len() != to.Len() {
+			fn(path)
+			return
+		}
+		for i := 0; i < from.Len(); i++ {
+			walkLostFields(from.Index(i), to.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case reflect.Map:
+		if to.Len() == 0 {
+			fn(path)
+			return
+		}
+		keys := from.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			keyPath := fmt.Sprintf("%s[%q]", path, fmt.Sprint(key.Interface()))
+			value := to.MapIndex(key)
+			if !value.IsValid() {
+				fn(keyPath)
+				continue
+			}
+			walkLostFields(from.MapIndex(key), value, keyPath, fn)
+		}
+	default:
+		if !reflect.DeepEqual(from.Interface(), to.Interface()) {
+			fn(path)
+		}
+	}
+}
+
+// isZero returns whether v holds the zero value of its type, or an empty list or map
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/i18n"
+)
+
+func TestConvertAPIModel(t *testing.T) {
+	cases := []struct {
+		name         string
+		apiModelPath string
+		toVersion    string
+		expectedLost []string
+		expectedErr  bool
+	}{
+		{
+			name:         "2016-09-30 to vlabs",
+			apiModelPath: "../engine/testdata/v20160930/defaults.json",
+			toVersion:    "vlabs",
+		},
+		{
+			name:         "2017-07-01 to vlabs",
+			apiModelPath: "../engine/testdata/v20170701/kubernetes.json",
+			toVersion:    "vlabs",
+		},
+		{
+			name:         "2016-09-30 to 2016-03-30",
+			apiModelPath: "../engine/testdata/v20160930/defaults.json",
+			toVersion:    "2016-03-30",
+			expectedLost: []string{"properties.agentPoolProfiles[0].availabilityProfile"},
+		},
+		{
+			name:         "agent pool only to vlabs",
+			apiModelPath: "../engine/testdata/agentPoolOnly/v20180331/agents.json",
+			toVersion:    "vlabs",
+			expectedLost: []string{"properties.hostedMasterProfile"},
+		},
+		{
+			name:         "Kubernetes to 2016-03-30",
+			apiModelPath: "../engine/testdata/v20170701/kubernetes.json",
+			toVersion:    "2016-03-30",
+			expectedErr:  true,
+		},
+		{
+			name:         "unknown version",
+			apiModelPath: "../engine/testdata/v20170701/kubernetes.json",
+			toVersion:    "2019-01-01",
+			expectedErr:  true,
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			contents, err := ioutil.ReadFile(c.apiModelPath)
+			if err != nil {
+				t.Fatalf("unexpected error reading the api model: %s", err)
+			}
+			apiloader := &Apiloader{
+				Translator: &i18n.Translator{},
+			}
+			converted, lost, err := apiloader.ConvertAPIModel(contents, c.toVersion)
+			if c.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error converting the api model to %s", c.toVersion)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error converting the api model: %s", err)
+			}
+			if !reflect.DeepEqual(lost, c.expectedLost) {
+				t.Errorf("expected the fields %v to be lost, got %v", c.expectedLost, lost)
+			}
+			m := &TypeMeta{}
+			if err = json.Unmarshal(converted, m); err != nil {
+				t.Fatalf("unexpected error parsing the converted api model: %s", err)
+			}
+			if m.APIVersion != c.toVersion {
+				t.Errorf("expected the converted api model to be in API version %s, got %s", c.toVersion, m.APIVersion)
+			}
+		})
+	}
+}
+
+func TestWalkLostFields(t *testing.T) {
+	from := &KubernetesConfig{
+		NetworkPlugin: "azure",
+		KubeletConfig: map[string]string{"--max-pods": "30", "--node-labels": "role=agent"},
+		Addons: []KubernetesAddon{
+			{Name: "tiller", Config: map[string]string{"max-history": "10"}},
+		},
+		APIServerConfig: map[string]string{"--audit-log-maxage": "30"},
+	}
+	to := &KubernetesConfig{
+		NetworkPlugin: "kubenet",
+		KubeletConfig: map[string]string{"--max-pods": "30"},
+		Addons: []KubernetesAddon{
+			{Name: "tiller"},
+		},
+	}
+
+	var lost []string
+	walkLostFields(reflect.ValueOf(from).Elem(), reflect.ValueOf(to).Elem(), "kubernetesConfig", func(path string) {
+		lost = append(lost, path)
+	})
+	expected := []string{
+		`kubernetesConfig.networkPlugin`,
+		`kubernetesConfig.addons[0].config`,
+		`kubernetesConfig.kubeletConfig["--node-labels"]`,
+		`kubernetesConfig.apiServerConfig`,
+	}
+	if !reflect.DeepEqual(lost, expected) {
+		t.Errorf("expected the fields %v to be lost, got %v", expected, lost)
+	}
+}
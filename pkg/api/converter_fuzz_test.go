@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+//go:build go1.18
+// +build go1.18
+
+package api
+
+import (
+	"testing"
+
+	v20180331 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20180331"
+	"github.com/Azure/aks-engine/pkg/api/v20170701"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+)
+
+// The fuzz targets look for the api models the converters lose fields of, seeding the random data the round trips
+// populate the api models with, e.g.
+//   go test ./pkg/api -run '^$' -fuzz FuzzVLabsConverterRoundTrip -fuzztime 1m
+
+func FuzzVLabsConverterRoundTrip(f *testing.F) {
+	fuzzConverterRoundTrip(f, vlabs.APIVersion)
+}
+
+func FuzzV20170701ConverterRoundTrip(f *testing.F) {
+	fuzzConverterRoundTrip(f, v20170701.APIVersion)
+}
+
+func FuzzV20180331AgentPoolOnlyConverterRoundTrip(f *testing.F) {
+	fuzzConverterRoundTrip(f, v20180331.APIVersion)
+}
+
+func fuzzConverterRoundTrip(f *testing.F, apiVersion string) {
+	var rt converterRoundTrip
+	for _, r := range converterRoundTrips {
+		if r.apiVersion == apiVersion {
+			rt = r
+		}
+	}
+	if rt.roundTrip == nil {
+		f.Fatalf("no converter round trip for API version %s", apiVersion)
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		checkConverterRoundTrip(t, rt, seed)
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	v20180331 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20180331"
+	"github.com/Azure/aks-engine/pkg/api/v20170701"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+)
+
+// converterRoundTrip converts a versioned api model populated with random data to the unversioned model and back
+type converterRoundTrip struct {
+	// apiVersion is the API version the round trip goes through
+	apiVersion string
+	// roundTrip returns the random versioned api model and the one converting it to the unversioned model and back gives
+	roundTrip func(r *rand.Rand) (from, to interface{}, err error)
+	// knownLost are the fields, with the indices of their paths elided, the API version is expected to lose,
+	// and why
+	knownLost map[string]string
+}
+
+var converterRoundTrips = []converterRoundTrip{
+	{
+		apiVersion: vlabs.APIVersion,
+		roundTrip: func(r *rand.Rand) (interface{}, interface{}, error) {
+			v := &vlabs.ContainerService{}
+			fillRandom(reflect.ValueOf(v).Elem(), r, 0)
+			// releases and DC/OS settings are checked against the orchestrator when converting
+			v.Properties.OrchestratorProfile.OrchestratorType = Kubernetes
+			v.Properties.OrchestratorProfile.OrchestratorRelease = ""
+			v.Properties.OrchestratorProfile.OrchestratorVersion = "1.14.5"
+			v.Properties.OrchestratorProfile.DcosConfig = nil
+			cs, err := ConvertVLabsContainerService(v, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			return v, ConvertContainerServiceToVLabs(cs), nil
+		},
+		knownLost: map[string]string{
+			"properties.orchestratorProfile.kubernetesConfig.dockerEngineVersion": "dockerEngineVersion is deprecated",
+			"properties.masterProfile.kubernetesConfig.dockerEngineVersion":       "dockerEngineVersion is deprecated",
+			"properties.agentPoolProfiles[].kubernetesConfig.dockerEngineVersion": "dockerEngineVersion is deprecated",
+			"properties.masterProfile.kubernetesConfig.networkPolicy":             "the network policy is cluster wide, only the orchestrator profile sets it",
+			"properties.agentPoolProfiles[].kubernetesConfig.networkPolicy":       "the network policy is cluster wide, only the orchestrator profile sets it",
+		},
+	},
+	{
+		apiVersion: v20170701.APIVersion,
+		roundTrip: func(r *rand.Rand) (interface{}, interface{}, error) {
+			v := &v20170701.ContainerService{}
+			fillRandom(reflect.ValueOf(v).Elem(), r, 0)
+			v.Properties.OrchestratorProfile.OrchestratorType = Kubernetes
+			v.Properties.OrchestratorProfile.OrchestratorVersion = "1.14.5"
+			return v, ConvertContainerServiceToV20170701(ConvertV20170701ContainerService(v, true)), nil
+		},
+	},
+	{
+		apiVersion: v20180331.APIVersion,
+		roundTrip: func(r *rand.Rand) (interface{}, interface{}, error) {
+			v := &v20180331.ManagedCluster{}
+			fillRandom(reflect.ValueOf(v).Elem(), r, 0)
+			v.Properties.KubernetesVersion = "1.14.5"
+			// the network profile only holds for the network plugins the API version knows, and its pod CIDR
+			// only for kubenet
+			if r.Intn(2) == 0 {
+				v.Properties.NetworkProfile.NetworkPlugin = v20180331.Azure
+				v.Properties.NetworkProfile.PodCidr = ""
+			} else {
+				v.Properties.NetworkProfile.NetworkPlugin = v20180331.Kubenet
+			}
+			return v, ConvertContainerServiceToV20180331AgentPoolOnly(ConvertV20180331AgentPoolOnly(v)), nil
+		},
+		knownLost: map[string]string{
+			"properties.accessProfiles":    "the unversioned model has no access profiles",
+			"properties.nodeResourceGroup": "the unversioned model has no node resource group",
+		},
+	},
+}
+
+// pathIndex matches the list indices and map keys of the JSON paths walkLostFields reports
+var pathIndex = regexp.MustCompile(`\[[^]]*\]`)
+
+// checkConverterRoundTrip runs the round trip of rt with the random data of seed, and fails t with the fields the
+// round trip lost that it is not expected to
+func checkConverterRoundTrip(t *testing.T, rt converterRoundTrip, seed int64) {
+	t.Helper()
+	from, to, err := rt.roundTrip(rand.New(rand.NewSource(seed)))
+	if err != nil {
+		t.Fatalf("seed %d: unexpected error converting the %s api model: %s", seed, rt.apiVersion, err)
+	}
+	walkLostFields(reflect.ValueOf(from).Elem(), reflect.ValueOf(to).Elem(), "", func(path string) {
+		if _, ok := rt.knownLost[pathIndex.ReplaceAllString(path, "[]")]; !ok {
+			t.Errorf("seed %d: %s did not survive the conversion of the %s api model to the unversioned model and back", seed, path, rt.apiVersion)
+		}
+	})
+}
+
+func TestConverterRoundTrip(t *testing.T) {
+	for _, rt := range converterRoundTrips {
+		rt := rt
+		t.Run(rt.apiVersion, func(t *testing.T) {
+			for seed := int64(0); seed < 100; seed++ {
+				checkConverterRoundTrip(t, rt, seed)
+			}
+		})
+	}
+}
+
+// fillRandomMaxDepth bounds how deep fillRandom recurses, should a type ever refer to itself
+const fillRandomMaxDepth = 12
+
+// fillRandom sets every exported field of v serialized to JSON, recursively, to a random non zero value
+func fillRandom(v reflect.Value, r *rand.Rand, depth int) {
+	if depth > fillRandomMaxDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(randomString(r))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(1 + r.Intn(100)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(1 + r.Intn(100)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1 + r.Float64())
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillRandom(v.Elem(), r, depth+1)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" || strings.Split(f.Tag.Get("json"), ",")[0] == "-" {
+				continue
+			}
+			fillRandom(v.Field(i), r, depth+1)
+		}
+	case reflect.Slice:
+		n := 1 + r.Intn(2)
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			fillRandom(s.Index(i), r, depth+1)
+		}
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for i := 1 + r.Intn(2); i > 0; i-- {
+			key := reflect.New(v.Type().Key()).Elem()
+			fillRandom(key, r, depth+1)
+			value := reflect.New(v.Type().Elem()).Elem()
+			fillRandom(value, r, depth+1)
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	}
+}
+
+func randomString(r *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
@@ -473,6 +473,10 @@ func convertPropertiesToVLabs(api *Properties, vlabsProps *vlabs.Properties) {
 		vlabsProps.CertificateProfile = &vlabs.CertificateProfile{}
 		convertCertificateProfileToVLabs(api.CertificateProfile, vlabsProps.CertificateProfile)
 	}
+	if api.PKIProfile != nil {
+		vlabsProps.PKIProfile = &vlabs.PKIProfile{}
+		convertPKIProfileToVLabs(api.PKIProfile, vlabsProps.PKIProfile)
+	}
 	if api.AADProfile != nil {
 		vlabsProps.AADProfile = &vlabs.AADProfile{}
 		convertAADProfileToVLabs(api.AADProfile, vlabsProps.AADProfile)
@@ -487,6 +491,11 @@ func convertPropertiesToVLabs(api *Properties, vlabsProps *vlabs.Properties) {
 		vlabsProps.CustomCloudProfile = &vlabs.CustomCloudProfile{}
 		convertCloudProfileToVLabs(api.CustomCloudProfile, vlabsProps.CustomCloudProfile)
 	}
+
+	if api.NetworkSecurityGroupProfile != nil {
+		vlabsProps.NetworkSecurityGroupProfile = &vlabs.NetworkSecurityGroupProfile{}
+		convertNetworkSecurityGroupProfileToVLabs(api.NetworkSecurityGroupProfile, vlabsProps.NetworkSecurityGroupProfile)
+	}
 }
 
 func convertLinuxProfileToV20160930(api *LinuxProfile, obj *v20160930.LinuxProfile) {
@@ -613,6 +622,28 @@ func convertWindowsProfileToVLabs(api *WindowsProfile, vlabsProfile *vlabs.Windo
 	}
 	vlabsProfile.SSHEnabled = api.SSHEnabled
 	vlabsProfile.EnableAutomaticUpdates = api.EnableAutomaticUpdates
+	if api.ImageRef != nil {
+		vlabsProfile.ImageRef = &vlabs.ImageReference{}
+		vlabsProfile.ImageRef.Name = api.ImageRef.Name
+		vlabsProfile.ImageRef.ResourceGroup = api.ImageRef.ResourceGroup
+		vlabsProfile.ImageRef.SubscriptionID = api.ImageRef.SubscriptionID
+		vlabsProfile.ImageRef.Gallery = api.ImageRef.Gallery
+		vlabsProfile.ImageRef.Version = api.ImageRef.Version
+	}
+	vlabsProfile.PrePullImages = api.PrePullImages
+	if api.DomainJoin != nil {
+		vlabsProfile.DomainJoin = &vlabs.WindowsDomainJoin{}
+		vlabsProfile.DomainJoin.Domain = api.DomainJoin.Domain
+		vlabsProfile.DomainJoin.OrganizationalUnit = api.DomainJoin.OrganizationalUnit
+		vlabsProfile.DomainJoin.UserName = api.DomainJoin.UserName
+		if api.DomainJoin.KeyvaultSecretRef != nil {
+			vlabsProfile.DomainJoin.KeyvaultSecretRef = &vlabs.KeyvaultSecretRef{
+				VaultID:       api.DomainJoin.KeyvaultSecretRef.VaultID,
+				SecretName:    api.DomainJoin.KeyvaultSecretRef.SecretName,
+				SecretVersion: api.DomainJoin.KeyvaultSecretRef.SecretVersion,
+			}
+		}
+	}
 }
 
 func convertOrchestratorProfileToV20160930(api *OrchestratorProfile, o *v20160930.OrchestratorProfile) {
@@ -729,6 +760,7 @@ func convertKubernetesConfigToVLabs(apiCfg *KubernetesConfig, vlabsCfg *vlabs.Ku
 	vlabsCfg.UseCloudControllerManager = apiCfg.UseCloudControllerManager
 	vlabsCfg.CustomWindowsPackageURL = apiCfg.CustomWindowsPackageURL
 	vlabsCfg.WindowsNodeBinariesURL = apiCfg.WindowsNodeBinariesURL
+	vlabsCfg.WindowsContainerdURL = apiCfg.WindowsContainerdURL
 	vlabsCfg.UseInstanceMetadata = apiCfg.UseInstanceMetadata
 	vlabsCfg.LoadBalancerSku = apiCfg.LoadBalancerSku
 	vlabsCfg.ExcludeMasterFromStandardLB = apiCfg.ExcludeMasterFromStandardLB
@@ -751,6 +783,7 @@ func convertKubernetesConfigToVLabs(apiCfg *KubernetesConfig, vlabsCfg *vlabs.Ku
 	vlabsCfg.ProxyMode = vlabs.KubeProxyMode(apiCfg.ProxyMode)
 	vlabsCfg.PrivateAzureRegistryServer = apiCfg.PrivateAzureRegistryServer
 	vlabsCfg.OutboundRuleIdleTimeoutInMinutes = apiCfg.OutboundRuleIdleTimeoutInMinutes
+	vlabsCfg.OutboundType = apiCfg.OutboundType
 	convertAddonsToVlabs(apiCfg, vlabsCfg)
 	convertKubeletConfigToVlabs(apiCfg, vlabsCfg)
 	convertControllerManagerConfigToVlabs(apiCfg, vlabsCfg)
@@ -819,6 +852,8 @@ func convertPrivateClusterToVlabs(a *KubernetesConfig, v *vlabs.KubernetesConfig
 	if a.PrivateCluster != nil {
 		v.PrivateCluster = &vlabs.PrivateCluster{}
 		v.PrivateCluster.Enabled = a.PrivateCluster.Enabled
+		v.PrivateCluster.FullyPrivate = a.PrivateCluster.FullyPrivate
+		v.PrivateCluster.PrivateDNSZoneName = a.PrivateCluster.PrivateDNSZoneName
 		if a.PrivateCluster.JumpboxProfile != nil {
 			v.PrivateCluster.JumpboxProfile = &vlabs.PrivateJumpboxProfile{}
 			convertPrivateJumpboxProfileToVlabs(a.PrivateCluster.JumpboxProfile, v.PrivateCluster.JumpboxProfile)
@@ -905,12 +940,17 @@ func convertMasterProfileToVLabs(api *MasterProfile, vlabsProfile *vlabs.MasterP
 	vlabsProfile.OSDiskSizeGB = api.OSDiskSizeGB
 	vlabsProfile.VnetSubnetID = api.VnetSubnetID
 	vlabsProfile.AgentVnetSubnetID = api.AgentVnetSubnetID
+	vlabsProfile.RouteTableID = api.RouteTableID
+	vlabsProfile.NetworkSecurityGroupID = api.NetworkSecurityGroupID
 	vlabsProfile.FirstConsecutiveStaticIP = api.FirstConsecutiveStaticIP
 	vlabsProfile.VnetCidr = api.VnetCidr
 	vlabsProfile.SetSubnet(api.Subnet)
 	vlabsProfile.SetSubnetIPv6(api.SubnetIPv6)
+	vlabsProfile.IPAddressCount = api.IPAddressCount
 	vlabsProfile.FQDN = api.FQDN
 	vlabsProfile.StorageProfile = api.StorageProfile
+	vlabsProfile.HTTPSourceAddressPrefix = api.HTTPSourceAddressPrefix
+	vlabsProfile.OAuthEnabled = api.OAuthEnabled
 	if api.PreprovisionExtension != nil {
 		vlabsExtension := &vlabs.Extension{}
 		convertExtensionToVLabs(api.PreprovisionExtension, vlabsExtension)
@@ -1008,6 +1048,7 @@ func convertAgentPoolProfileToVLabs(api *AgentPoolProfile, p *vlabs.AgentPoolPro
 	p.OSDiskSizeGB = api.OSDiskSizeGB
 	p.DNSPrefix = api.DNSPrefix
 	p.OSType = vlabs.OSType(api.OSType)
+	p.WindowsIsolation = api.WindowsIsolation
 	p.Ports = []int{}
 	p.Ports = append(p.Ports, api.Ports...)
 	p.AvailabilityProfile = api.AvailabilityProfile
@@ -1018,6 +1059,7 @@ func convertAgentPoolProfileToVLabs(api *AgentPoolProfile, p *vlabs.AgentPoolPro
 	p.DiskSizesGB = append(p.DiskSizesGB, api.DiskSizesGB...)
 	p.VnetSubnetID = api.VnetSubnetID
 	p.SetSubnet(api.Subnet)
+	p.IPAddressCount = api.IPAddressCount
 	p.FQDN = api.FQDN
 	p.CustomNodeLabels = map[string]string{}
 	p.AcceleratedNetworkingEnabled = api.AcceleratedNetworkingEnabled
@@ -1166,6 +1208,7 @@ func convertServicePrincipalProfileToVLabs(api *ServicePrincipalProfile, v *vlab
 func convertCertificateProfileToVLabs(api *CertificateProfile, vlabs *vlabs.CertificateProfile) {
 	vlabs.CaCertificate = api.CaCertificate
 	vlabs.CaPrivateKey = api.CaPrivateKey
+	vlabs.CaCertificateChain = api.CaCertificateChain
 	vlabs.APIServerCertificate = api.APIServerCertificate
 	vlabs.APIServerPrivateKey = api.APIServerPrivateKey
 	vlabs.ClientCertificate = api.ClientCertificate
@@ -1216,6 +1259,8 @@ func convertCloudProfileToVLabs(api *CustomCloudProfile, vlabsccp *vlabs.CustomC
 		vlabsccp.Environment.ResourceManagerVMDNSSuffix = api.Environment.ResourceManagerVMDNSSuffix
 		vlabsccp.Environment.ContainerRegistryDNSSuffix = api.Environment.ContainerRegistryDNSSuffix
 		vlabsccp.Environment.TokenAudience = api.Environment.TokenAudience
+		vlabsccp.Environment.CosmosDBDNSSuffix = api.Environment.CosmosDBDNSSuffix
+		vlabsccp.Environment.ResourceIdentifiers = api.Environment.ResourceIdentifiers
 	}
 
 	if api.AzureEnvironmentSpecConfig != nil {
@@ -1262,6 +1307,7 @@ func convertAzureEnvironmentSpecConfigToVLabs(api *AzureEnvironmentSpecConfig, v
 		CNIPluginsDownloadURL:            api.KubernetesSpecConfig.CNIPluginsDownloadURL,
 		VnetCNILinuxPluginsDownloadURL:   api.KubernetesSpecConfig.VnetCNILinuxPluginsDownloadURL,
 		VnetCNIWindowsPluginsDownloadURL: api.KubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL,
+		WindowsContainerdDownloadURL:     api.KubernetesSpecConfig.WindowsContainerdDownloadURL,
 		ContainerdDownloadURLBase:        api.KubernetesSpecConfig.ContainerdDownloadURLBase,
 	}
 	vlabses.OSImageConfig = map[vlabs.Distro]vlabs.AzureOSImageConfig{}
@@ -1274,3 +1320,42 @@ func convertAzureEnvironmentSpecConfigToVLabs(api *AzureEnvironmentSpecConfig, v
 		}
 	}
 }
+
+func convertPKIProfileToVLabs(api *PKIProfile, vlabs *vlabs.PKIProfile) {
+	vlabs.KeyAlgorithm = api.KeyAlgorithm
+	vlabs.KeySize = api.KeySize
+	vlabs.CAValidityDays = api.CAValidityDays
+	vlabs.LeafValidityDays = api.LeafValidityDays
+	if api.ExtraSubjectAltNames != nil {
+		vlabs.ExtraSubjectAltNames = map[string][]string{}
+		for component, names := range api.ExtraSubjectAltNames {
+			vlabs.ExtraSubjectAltNames[component] = append([]string{}, names...)
+		}
+	}
+}
+
+func convertNetworkSecurityGroupProfileToVLabs(api *NetworkSecurityGroupProfile, vlabsnsgp *vlabs.NetworkSecurityGroupProfile) {
+	if api.SSHSourceAddressPrefixes != nil {
+		vlabsnsgp.SSHSourceAddressPrefixes = make([]string, len(api.SSHSourceAddressPrefixes))
+		copy(vlabsnsgp.SSHSourceAddressPrefixes, api.SSHSourceAddressPrefixes)
+	}
+	if api.SecurityRules != nil {
+		vlabsnsgp.SecurityRules = []vlabs.SecurityRule{}
+		for _, r := range api.SecurityRules {
+			vlabsnsgp.SecurityRules = append(vlabsnsgp.SecurityRules, vlabs.SecurityRule{
+				Name:                       r.Name,
+				Description:                r.Description,
+				Protocol:                   r.Protocol,
+				SourcePortRange:            r.SourcePortRange,
+				DestinationPortRange:       r.DestinationPortRange,
+				SourceAddressPrefix:        r.SourceAddressPrefix,
+				SourceAddressPrefixes:      r.SourceAddressPrefixes,
+				DestinationAddressPrefix:   r.DestinationAddressPrefix,
+				DestinationAddressPrefixes: r.DestinationAddressPrefixes,
+				Access:                     r.Access,
+				Priority:                   r.Priority,
+				Direction:                  r.Direction,
+			})
+		}
+	}
+}
@@ -5,6 +5,7 @@ package api
 
 import (
 	"net/url"
+	"reflect"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/to"
@@ -312,6 +313,70 @@ func TestConvertAzureEnvironmentSpecConfigToVLabs(t *testing.T) {
 	}
 }
 
+func TestConvertNetworkSecurityGroupProfileToVLabs(t *testing.T) {
+	nsgProfile := &NetworkSecurityGroupProfile{
+		SSHSourceAddressPrefixes: []string{"10.100.0.0/16", "192.168.1.10"},
+		SecurityRules: []SecurityRule{
+			{
+				Name:                 "allow_prometheus",
+				Description:          "Allow prometheus scraping from the monitoring network",
+				Protocol:             "Tcp",
+				SourcePortRange:      "*",
+				DestinationPortRange: "9100",
+				SourceAddressPrefix:  "10.100.0.0/16",
+				Access:               "Allow",
+				Priority:             200,
+				Direction:            "Inbound",
+			},
+			{
+				Name:                       "allow_outbound_registry",
+				Protocol:                   "*",
+				DestinationAddressPrefixes: []string{"52.0.0.0/8", "13.0.0.0/8"},
+				SourceAddressPrefixes:      []string{"10.240.0.0/16"},
+				Access:                     "Allow",
+				Priority:                   3000,
+				Direction:                  "Outbound",
+			},
+		},
+	}
+
+	vlabsNSGProfile := &vlabs.NetworkSecurityGroupProfile{}
+	convertNetworkSecurityGroupProfileToVLabs(nsgProfile, vlabsNSGProfile)
+
+	if !reflect.DeepEqual(vlabsNSGProfile.SSHSourceAddressPrefixes, nsgProfile.SSHSourceAddressPrefixes) {
+		t.Errorf("incorrect SSHSourceAddressPrefixes, expect: '%v', actual: '%v'", nsgProfile.SSHSourceAddressPrefixes, vlabsNSGProfile.SSHSourceAddressPrefixes)
+	}
+
+	// the profile must survive a round trip so that scale and upgrade preserve the custom rules
+	roundTripped := &NetworkSecurityGroupProfile{}
+	convertVLabsNetworkSecurityGroupProfile(vlabsNSGProfile, roundTripped)
+	if !reflect.DeepEqual(roundTripped, nsgProfile) {
+		t.Errorf("incorrect NetworkSecurityGroupProfile after round trip, expect: '%v', actual: '%v'", nsgProfile, roundTripped)
+	}
+}
+
+func TestConvertPKIProfileToVLabs(t *testing.T) {
+	pkiProfile := &PKIProfile{
+		KeyAlgorithm:     "ECDSA",
+		KeySize:          256,
+		CAValidityDays:   3650,
+		LeafValidityDays: 365,
+		ExtraSubjectAltNames: map[string][]string{
+			"apiserver": {"api.mydomain.com", "10.0.0.100"},
+		},
+	}
+
+	vlabsPKIProfile := &vlabs.PKIProfile{}
+	convertPKIProfileToVLabs(pkiProfile, vlabsPKIProfile)
+
+	// the profile must survive a round trip so that rotate-certs generates certificates the same way
+	roundTripped := &PKIProfile{}
+	convertVLabsPKIProfile(vlabsPKIProfile, roundTripped)
+	if !reflect.DeepEqual(roundTripped, pkiProfile) {
+		t.Errorf("incorrect PKIProfile after round trip, expect: '%v', actual: '%v'", pkiProfile, roundTripped)
+	}
+}
+
 func TestConvertContainerServiceToV20160330(t *testing.T) {
 	cs := getDefaultContainerService()
 	v20160330cs := ConvertContainerServiceToV20160330(cs)
@@ -399,6 +399,11 @@ func convertVLabsProperties(vlabs *vlabs.Properties, api *Properties, isUpdate b
 		convertVLabsCertificateProfile(vlabs.CertificateProfile, api.CertificateProfile)
 	}
 
+	if vlabs.PKIProfile != nil {
+		api.PKIProfile = &PKIProfile{}
+		convertVLabsPKIProfile(vlabs.PKIProfile, api.PKIProfile)
+	}
+
 	if vlabs.AADProfile != nil {
 		api.AADProfile = &AADProfile{}
 		convertVLabsAADProfile(vlabs.AADProfile, api.AADProfile)
@@ -414,6 +419,11 @@ func convertVLabsProperties(vlabs *vlabs.Properties, api *Properties, isUpdate b
 		convertVLabsCustomCloudProfile(vlabs.CustomCloudProfile, api.CustomCloudProfile)
 	}
 
+	if vlabs.NetworkSecurityGroupProfile != nil {
+		api.NetworkSecurityGroupProfile = &NetworkSecurityGroupProfile{}
+		convertVLabsNetworkSecurityGroupProfile(vlabs.NetworkSecurityGroupProfile, api.NetworkSecurityGroupProfile)
+	}
+
 	return nil
 }
 
@@ -544,6 +554,28 @@ func convertVLabsWindowsProfile(vlabs *vlabs.WindowsProfile, api *WindowsProfile
 	}
 	api.SSHEnabled = vlabs.SSHEnabled
 	api.EnableAutomaticUpdates = vlabs.EnableAutomaticUpdates
+	if vlabs.ImageRef != nil {
+		api.ImageRef = &ImageReference{}
+		api.ImageRef.Name = vlabs.ImageRef.Name
+		api.ImageRef.ResourceGroup = vlabs.ImageRef.ResourceGroup
+		api.ImageRef.SubscriptionID = vlabs.ImageRef.SubscriptionID
+		api.ImageRef.Gallery = vlabs.ImageRef.Gallery
+		api.ImageRef.Version = vlabs.ImageRef.Version
+	}
+	api.PrePullImages = vlabs.PrePullImages
+	if vlabs.DomainJoin != nil {
+		api.DomainJoin = &WindowsDomainJoin{}
+		api.DomainJoin.Domain = vlabs.DomainJoin.Domain
+		api.DomainJoin.OrganizationalUnit = vlabs.DomainJoin.OrganizationalUnit
+		api.DomainJoin.UserName = vlabs.DomainJoin.UserName
+		if vlabs.DomainJoin.KeyvaultSecretRef != nil {
+			api.DomainJoin.KeyvaultSecretRef = &KeyvaultSecretRef{
+				VaultID:       vlabs.DomainJoin.KeyvaultSecretRef.VaultID,
+				SecretName:    vlabs.DomainJoin.KeyvaultSecretRef.SecretName,
+				SecretVersion: vlabs.DomainJoin.KeyvaultSecretRef.SecretVersion,
+			}
+		}
+	}
 }
 
 func convertV20160930OrchestratorProfile(v20160930 *v20160930.OrchestratorProfile, api *OrchestratorProfile) {
@@ -694,6 +726,7 @@ func convertVLabsKubernetesConfig(vlabs *vlabs.KubernetesConfig, api *Kubernetes
 	api.UseCloudControllerManager = vlabs.UseCloudControllerManager
 	api.CustomWindowsPackageURL = vlabs.CustomWindowsPackageURL
 	api.WindowsNodeBinariesURL = vlabs.WindowsNodeBinariesURL
+	api.WindowsContainerdURL = vlabs.WindowsContainerdURL
 	api.UseInstanceMetadata = vlabs.UseInstanceMetadata
 	api.LoadBalancerSku = vlabs.LoadBalancerSku
 	api.ExcludeMasterFromStandardLB = vlabs.ExcludeMasterFromStandardLB
@@ -716,6 +749,7 @@ func convertVLabsKubernetesConfig(vlabs *vlabs.KubernetesConfig, api *Kubernetes
 	api.ProxyMode = KubeProxyMode(vlabs.ProxyMode)
 	api.PrivateAzureRegistryServer = vlabs.PrivateAzureRegistryServer
 	api.OutboundRuleIdleTimeoutInMinutes = vlabs.OutboundRuleIdleTimeoutInMinutes
+	api.OutboundType = vlabs.OutboundType
 	convertAddonsToAPI(vlabs, api)
 	convertKubeletConfigToAPI(vlabs, api)
 	convertControllerManagerConfigToAPI(vlabs, api)
@@ -840,6 +874,8 @@ func convertPrivateClusterToAPI(v *vlabs.KubernetesConfig, a *KubernetesConfig)
 	if v.PrivateCluster != nil {
 		a.PrivateCluster = &PrivateCluster{}
 		a.PrivateCluster.Enabled = v.PrivateCluster.Enabled
+		a.PrivateCluster.FullyPrivate = v.PrivateCluster.FullyPrivate
+		a.PrivateCluster.PrivateDNSZoneName = v.PrivateCluster.PrivateDNSZoneName
 		if v.PrivateCluster.JumpboxProfile != nil {
 			a.PrivateCluster.JumpboxProfile = &PrivateJumpboxProfile{}
 			convertPrivateJumpboxProfileToAPI(v.PrivateCluster.JumpboxProfile, a.PrivateCluster.JumpboxProfile)
@@ -908,6 +944,8 @@ func convertVLabsMasterProfile(vlabs *vlabs.MasterProfile, api *MasterProfile) {
 	api.OSDiskSizeGB = vlabs.OSDiskSizeGB
 	api.VnetSubnetID = vlabs.VnetSubnetID
 	api.AgentVnetSubnetID = vlabs.AgentVnetSubnetID
+	api.RouteTableID = vlabs.RouteTableID
+	api.NetworkSecurityGroupID = vlabs.NetworkSecurityGroupID
 	api.FirstConsecutiveStaticIP = vlabs.FirstConsecutiveStaticIP
 	api.VnetCidr = vlabs.VnetCidr
 	api.Subnet = vlabs.GetSubnet()
@@ -1026,6 +1064,7 @@ func convertVLabsAgentPoolProfile(vlabs *vlabs.AgentPoolProfile, api *AgentPoolP
 	api.OSDiskSizeGB = vlabs.OSDiskSizeGB
 	api.DNSPrefix = vlabs.DNSPrefix
 	api.OSType = OSType(vlabs.OSType)
+	api.WindowsIsolation = vlabs.WindowsIsolation
 	api.Ports = []int{}
 	api.Ports = append(api.Ports, vlabs.Ports...)
 	api.AvailabilityProfile = vlabs.AvailabilityProfile
@@ -1198,6 +1237,7 @@ func convertV20170701CustomProfile(v20170701 *v20170701.CustomProfile, api *Cust
 func convertVLabsCertificateProfile(vlabs *vlabs.CertificateProfile, api *CertificateProfile) {
 	api.CaCertificate = vlabs.CaCertificate
 	api.CaPrivateKey = vlabs.CaPrivateKey
+	api.CaCertificateChain = vlabs.CaCertificateChain
 	api.APIServerCertificate = vlabs.APIServerCertificate
 	api.APIServerPrivateKey = vlabs.APIServerPrivateKey
 	api.ClientCertificate = vlabs.ClientCertificate
@@ -1267,6 +1307,8 @@ func convertVLabsCustomCloudProfile(vlabs *vlabs.CustomCloudProfile, api *Custom
 		api.Environment.ResourceManagerVMDNSSuffix = vlabs.Environment.ResourceManagerVMDNSSuffix
 		api.Environment.ContainerRegistryDNSSuffix = vlabs.Environment.ContainerRegistryDNSSuffix
 		api.Environment.TokenAudience = vlabs.Environment.TokenAudience
+		api.Environment.CosmosDBDNSSuffix = vlabs.Environment.CosmosDBDNSSuffix
+		api.Environment.ResourceIdentifiers = vlabs.Environment.ResourceIdentifiers
 	}
 	if vlabs.AzureEnvironmentSpecConfig != nil {
 		api.AzureEnvironmentSpecConfig = &AzureEnvironmentSpecConfig{}
@@ -1313,6 +1355,7 @@ func convertAzureEnvironmentSpecConfig(vlabses *vlabs.AzureEnvironmentSpecConfig
 		CNIPluginsDownloadURL:            vlabses.KubernetesSpecConfig.CNIPluginsDownloadURL,
 		VnetCNILinuxPluginsDownloadURL:   vlabses.KubernetesSpecConfig.VnetCNILinuxPluginsDownloadURL,
 		VnetCNIWindowsPluginsDownloadURL: vlabses.KubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL,
+		WindowsContainerdDownloadURL:     vlabses.KubernetesSpecConfig.WindowsContainerdDownloadURL,
 		ContainerdDownloadURLBase:        vlabses.KubernetesSpecConfig.ContainerdDownloadURLBase,
 	}
 	api.OSImageConfig = map[Distro]AzureOSImageConfig{}
@@ -1325,3 +1368,42 @@ func convertAzureEnvironmentSpecConfig(vlabses *vlabs.AzureEnvironmentSpecConfig
 		}
 	}
 }
+
+func convertVLabsPKIProfile(vlabs *vlabs.PKIProfile, api *PKIProfile) {
+	api.KeyAlgorithm = vlabs.KeyAlgorithm
+	api.KeySize = vlabs.KeySize
+	api.CAValidityDays = vlabs.CAValidityDays
+	api.LeafValidityDays = vlabs.LeafValidityDays
+	if vlabs.ExtraSubjectAltNames != nil {
+		api.ExtraSubjectAltNames = map[string][]string{}
+		for component, names := range vlabs.ExtraSubjectAltNames {
+			api.ExtraSubjectAltNames[component] = append([]string{}, names...)
+		}
+	}
+}
+
+func convertVLabsNetworkSecurityGroupProfile(vlabs *vlabs.NetworkSecurityGroupProfile, api *NetworkSecurityGroupProfile) {
+	if vlabs.SSHSourceAddressPrefixes != nil {
+		api.SSHSourceAddressPrefixes = make([]string, len(vlabs.SSHSourceAddressPrefixes))
+		copy(api.SSHSourceAddressPrefixes, vlabs.SSHSourceAddressPrefixes)
+	}
+	if vlabs.SecurityRules != nil {
+		api.SecurityRules = []SecurityRule{}
+		for _, r := range vlabs.SecurityRules {
+			api.SecurityRules = append(api.SecurityRules, SecurityRule{
+				Name:                       r.Name,
+				Description:                r.Description,
+				Protocol:                   r.Protocol,
+				SourcePortRange:            r.SourcePortRange,
+				DestinationPortRange:       r.DestinationPortRange,
+				SourceAddressPrefix:        r.SourceAddressPrefix,
+				SourceAddressPrefixes:      r.SourceAddressPrefixes,
+				DestinationAddressPrefix:   r.DestinationAddressPrefix,
+				DestinationAddressPrefixes: r.DestinationAddressPrefixes,
+				Access:                     r.Access,
+				Priority:                   r.Priority,
+				Direction:                  r.Direction,
+			})
+		}
+	}
+}
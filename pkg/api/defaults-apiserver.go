@@ -134,6 +134,11 @@ func (cs *ContainerService) setAPIServerConfig() {
 		o.KubernetesConfig.APIServerConfig[key] = val
 	}
 
+	// gMSA of domain joined Windows nodes is alpha before 1.16
+	if cs.Properties.WindowsProfile.HasDomainJoin() && !common.IsKubernetesVersionGe(o.OrchestratorVersion, "1.16.0") {
+		addDefaultFeatureGates(o.KubernetesConfig.APIServerConfig, o.OrchestratorVersion, "1.14.0", "WindowsGMSA=true")
+	}
+
 	// Remove flags for secure communication to kubelet, if configured
 	if !to.Bool(o.KubernetesConfig.EnableSecureKubelet) {
 		for _, key := range []string{"--kubelet-client-certificate", "--kubelet-client-key"} {
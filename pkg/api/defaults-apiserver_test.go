@@ -470,3 +470,27 @@ func TestAPIServerWeakCipherSuites(t *testing.T) {
 		}
 	}
 }
+
+func TestAPIServerWindowsGMSAFeatureGate(t *testing.T) {
+	for _, c := range []struct {
+		version    string
+		domainJoin bool
+		expected   bool
+	}{
+		{"1.14.4", true, true},
+		{"1.15.2", true, true},
+		{"1.15.2", false, false},
+		{"1.16.0", true, false},
+	} {
+		cs := CreateMockContainerService("testcluster", c.version, 3, 2, false)
+		cs.Properties.WindowsProfile = &WindowsProfile{}
+		if c.domainJoin {
+			cs.Properties.WindowsProfile.DomainJoin = &WindowsDomainJoin{Domain: "corp.contoso.com"}
+		}
+		cs.setAPIServerConfig()
+		featureGates := cs.Properties.OrchestratorProfile.KubernetesConfig.APIServerConfig["--feature-gates"]
+		if strings.Contains(featureGates, "WindowsGMSA=true") != c.expected {
+			t.Fatalf("expected WindowsGMSA=true presence in --feature-gates of Kubernetes version %s with domain join %t to be %t, got %s", c.version, c.domainJoin, c.expected, featureGates)
+		}
+	}
+}
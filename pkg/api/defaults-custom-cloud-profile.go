@@ -143,6 +143,7 @@ func (p *Properties) SetAzureStackCloudSpec() error {
 			azureStackCloudSpec.KubernetesSpecConfig.VnetCNILinuxPluginsDownloadURL = helpers.EnsureString(asccKubernetesSpecConfig.VnetCNILinuxPluginsDownloadURL, azsKubernetesSpecConfig.VnetCNILinuxPluginsDownloadURL)
 			azureStackCloudSpec.KubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL = helpers.EnsureString(asccKubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL, azsKubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL)
 			azureStackCloudSpec.KubernetesSpecConfig.WindowsTelemetryGUID = helpers.EnsureString(asccKubernetesSpecConfig.WindowsTelemetryGUID, azsKubernetesSpecConfig.WindowsTelemetryGUID)
+			azureStackCloudSpec.KubernetesSpecConfig.WindowsContainerdDownloadURL = helpers.EnsureString(asccKubernetesSpecConfig.WindowsContainerdDownloadURL, azsKubernetesSpecConfig.WindowsContainerdDownloadURL)
 
 			//EndpointConfig
 			asccEndpointConfig := ascc.EndpointConfig
@@ -209,6 +209,11 @@ func (cs *ContainerService) setKubeletConfig(isUpgrade bool) {
 			}
 		}
 
+		// gMSA of domain joined Windows nodes is alpha before 1.16
+		if profile.OSType == Windows && cs.Properties.WindowsProfile.HasDomainJoin() && !common.IsKubernetesVersionGe(o.OrchestratorVersion, "1.16.0") {
+			addDefaultFeatureGates(profile.KubernetesConfig.KubeletConfig, o.OrchestratorVersion, "1.14.0", "WindowsGMSA=true")
+		}
+
 		if isUpgrade && common.IsKubernetesVersionGe(o.OrchestratorVersion, "1.14.0") {
 			hasSupportPodPidsLimitFeatureGate := strings.Contains(profile.KubernetesConfig.KubeletConfig["--feature-gates"], "SupportPodPidsLimit=true")
 			podMaxPids, err := strconv.Atoi(profile.KubernetesConfig.KubeletConfig["--pod-max-pids"])
@@ -1917,3 +1917,31 @@ func TestSupportPodPidsLimitFeatureGateInAgentPool(t *testing.T) {
 	}
 
 }
+
+func TestKubeletConfigWindowsGMSAFeatureGate(t *testing.T) {
+	for _, c := range []struct {
+		version    string
+		domainJoin bool
+		expected   bool
+	}{
+		{"1.14.4", true, true},
+		{"1.15.2", true, true},
+		{"1.15.2", false, false},
+		{"1.16.0", true, false},
+	} {
+		cs := CreateMockContainerService("testcluster", c.version, 3, 2, false)
+		cs.Properties.AgentPoolProfiles[0].OSType = Windows
+		cs.Properties.WindowsProfile = &WindowsProfile{}
+		if c.domainJoin {
+			cs.Properties.WindowsProfile.DomainJoin = &WindowsDomainJoin{Domain: "corp.contoso.com"}
+		}
+		cs.setKubeletConfig(false)
+		featureGates := cs.Properties.AgentPoolProfiles[0].KubernetesConfig.KubeletConfig["--feature-gates"]
+		if strings.Contains(featureGates, "WindowsGMSA=true") != c.expected {
+			t.Fatalf("expected WindowsGMSA=true presence in --feature-gates of Kubernetes version %s with domain join %t to be %t, got %s", c.version, c.domainJoin, c.expected, featureGates)
+		}
+		if strings.Contains(cs.Properties.OrchestratorProfile.KubernetesConfig.KubeletConfig["--feature-gates"], "WindowsGMSA") {
+			t.Fatalf("expected no WindowsGMSA feature gate in the default kubelet config of Kubernetes version %s", c.version)
+		}
+	}
+}
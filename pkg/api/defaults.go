@@ -5,14 +5,15 @@ package api
 
 import (
 	"bytes"
-	"crypto/rand"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
 
@@ -28,6 +29,12 @@ var DistroValues = []Distro{"", Ubuntu, Ubuntu1804, RHEL, CoreOS, AKSUbuntu1604,
 
 // SetPropertiesDefaults for the container Properties, returns true if certs are generated
 func (cs *ContainerService) SetPropertiesDefaults(isUpgrade, isScale bool) (bool, error) {
+	return cs.SetPropertiesDefaultsWithEntropy(isUpgrade, isScale, nil)
+}
+
+// SetPropertiesDefaultsWithEntropy is SetPropertiesDefaults drawing the generated certificates and keys from entropy,
+// a nil entropy generates them from crypto/rand
+func (cs *ContainerService) SetPropertiesDefaultsWithEntropy(isUpgrade, isScale bool, entropy *helpers.Entropy) (bool, error) {
 	properties := cs.Properties
 
 	// Set custom cloud profile defaults if this cluster configuration has custom cloud profile
@@ -39,6 +46,7 @@ func (cs *ContainerService) SetPropertiesDefaults(isUpgrade, isScale bool) (bool
 	}
 
 	cs.setOrchestratorDefaults(isUpgrade, isScale)
+	cs.setEtcdEncryptionKeyDefaults(entropy)
 
 	cloudName := cs.GetCloudSpecConfig().CloudName
 
@@ -69,7 +77,7 @@ func (cs *ContainerService) SetPropertiesDefaults(isUpgrade, isScale bool) (bool
 		properties.setWindowsProfileDefaults(isUpgrade, isScale)
 	}
 
-	certsGenerated, _, e := cs.SetDefaultCerts()
+	certsGenerated, _, e := cs.setDefaultCerts(isUpgrade || isScale, entropy)
 	if e != nil {
 		return false, e
 	}
@@ -238,6 +246,14 @@ func (cs *ContainerService) setOrchestratorDefaults(isUpgrade, isScale bool) {
 			o.KubernetesConfig.PrivateCluster.Enabled = to.BoolPtr(DefaultPrivateClusterEnabled)
 		}
 
+		if o.KubernetesConfig.PrivateCluster.FullyPrivate == nil {
+			o.KubernetesConfig.PrivateCluster.FullyPrivate = to.BoolPtr(DefaultPrivateClusterFullyPrivate)
+		}
+
+		if o.IsFullyPrivateCluster() && o.KubernetesConfig.PrivateCluster.PrivateDNSZoneName == "" && a.MasterProfile != nil {
+			o.KubernetesConfig.PrivateCluster.PrivateDNSZoneName = a.MasterProfile.DNSPrefix + DefaultPrivateDNSZoneSuffix
+		}
+
 		if "" == a.OrchestratorProfile.KubernetesConfig.EtcdDiskSizeGB {
 			switch {
 			case a.TotalNodes() > 20:
@@ -261,12 +277,6 @@ func (cs *ContainerService) setOrchestratorDefaults(isUpgrade, isScale bool) {
 			}
 		}
 
-		if to.Bool(o.KubernetesConfig.EnableDataEncryptionAtRest) {
-			if "" == a.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey {
-				a.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey = generateEtcdEncryptionKey()
-			}
-		}
-
 		if a.OrchestratorProfile.KubernetesConfig.PrivateJumpboxProvision() && a.OrchestratorProfile.KubernetesConfig.PrivateCluster.JumpboxProfile.OSDiskSizeGB == 0 {
 			a.OrchestratorProfile.KubernetesConfig.PrivateCluster.JumpboxProfile.OSDiskSizeGB = DefaultJumpboxDiskSize
 		}
@@ -336,6 +346,13 @@ func (cs *ContainerService) setOrchestratorDefaults(isUpgrade, isScale bool) {
 			a.OrchestratorProfile.KubernetesConfig.OutboundRuleIdleTimeoutInMinutes == 0 {
 			a.OrchestratorProfile.KubernetesConfig.OutboundRuleIdleTimeoutInMinutes = DefaultOutboundRuleIdleTimeoutInMinutes
 		}
+		if a.OrchestratorProfile.KubernetesConfig.OutboundType == "" {
+			if a.OrchestratorProfile.IsFullyPrivateCluster() {
+				a.OrchestratorProfile.KubernetesConfig.OutboundType = OutboundTypeUserDefinedRouting
+			} else {
+				a.OrchestratorProfile.KubernetesConfig.OutboundType = DefaultOutboundType
+			}
+		}
 
 		// First, Configure addons
 		cs.setAddonsConfig(isUpdate)
@@ -741,8 +758,31 @@ func (p *Properties) setHostedMasterProfileDefaults() {
 	p.HostedMasterProfile.Subnet = DefaultKubernetesMasterSubnet
 }
 
+// setEtcdEncryptionKeyDefaults generates the key etcd encrypts data at rest with, if encryption is enabled
+func (cs *ContainerService) setEtcdEncryptionKeyDefaults(entropy *helpers.Entropy) {
+	o := cs.Properties.OrchestratorProfile
+	if o == nil || o.KubernetesConfig == nil {
+		return
+	}
+	if to.Bool(o.KubernetesConfig.EnableDataEncryptionAtRest) && "" == o.KubernetesConfig.EtcdEncryptionKey {
+		o.KubernetesConfig.EtcdEncryptionKey = generateEtcdEncryptionKey(entropy.Reader("etcdencryptionkey"))
+	}
+}
+
 // SetDefaultCerts generates and sets defaults for the container certificateProfile, returns true if certs are generated
 func (cs *ContainerService) SetDefaultCerts() (bool, []net.IP, error) {
+	return cs.SetDefaultCertsWithEntropy(nil)
+}
+
+// SetDefaultCertsWithEntropy is SetDefaultCerts drawing the generated certificates and keys from entropy,
+// a nil entropy generates them from crypto/rand
+func (cs *ContainerService) SetDefaultCertsWithEntropy(entropy *helpers.Entropy) (bool, []net.IP, error) {
+	return cs.setDefaultCerts(false, entropy)
+}
+
+// setDefaultCerts only validates a provided Certificate Authority pair for a new deployment, isUpdate keeps the CA of
+// an existing cluster working up to its expiry
+func (cs *ContainerService) setDefaultCerts(isUpdate bool, entropy *helpers.Entropy) (bool, []net.IP, error) {
 	p := cs.Properties
 	if p.MasterProfile == nil || p.OrchestratorProfile.OrchestratorType != Kubernetes {
 		return false, nil, nil
@@ -761,6 +801,9 @@ func (cs *ContainerService) SetDefaultCerts() (bool, []net.IP, error) {
 
 	masterExtraFQDNs := append(azureProdFQDNs, p.MasterProfile.SubjectAltNames...)
 	masterExtraFQDNs = append(masterExtraFQDNs, "localhost")
+	if privateFQDN := p.GetPrivateAPIServerFQDN(); privateFQDN != "" {
+		masterExtraFQDNs = append(masterExtraFQDNs, privateFQDN)
+	}
 	firstMasterIP := net.ParseIP(p.MasterProfile.FirstConsecutiveStaticIP).To4()
 	localhostIP := net.ParseIP("127.0.0.1").To4()
 
@@ -798,10 +841,15 @@ func (cs *ContainerService) SetDefaultCerts() (bool, []net.IP, error) {
 	// use the specified Certificate Authority pair, or generate p new pair
 	var caPair *helpers.PkiKeyCertPair
 	if provided["ca"] {
-		caPair = &helpers.PkiKeyCertPair{CertificatePem: p.CertificateProfile.CaCertificate, PrivateKeyPem: p.CertificateProfile.CaPrivateKey}
+		caPair = &helpers.PkiKeyCertPair{CertificatePem: p.CertificateProfile.CaCertificate, PrivateKeyPem: p.CertificateProfile.CaPrivateKey, CertificateChainPem: p.CertificateProfile.CaCertificateChain}
+		if !isUpdate {
+			if err := helpers.ValidateCAKeyCertPair(caPair, time.Now()); err != nil {
+				return false, ips, errors.Wrap(err, "validating the provided CA")
+			}
+		}
 	} else {
 		var err error
-		caPair, err = helpers.CreatePkiKeyCertPair("ca")
+		caPair, err = helpers.CreatePkiKeyCertPairWithOptions("ca", entropy, p.GetPkiOptions())
 		if err != nil {
 			return false, ips, err
 		}
@@ -815,7 +863,7 @@ func (cs *ContainerService) SetDefaultCerts() (bool, []net.IP, error) {
 	}
 	ips = append(ips, cidrFirstIP)
 
-	apiServerPair, clientPair, kubeConfigPair, etcdServerPair, etcdClientPair, etcdPeerPairs, err := helpers.CreatePki(masterExtraFQDNs, ips, DefaultKubernetesClusterDomain, caPair, p.MasterProfile.Count)
+	apiServerPair, clientPair, kubeConfigPair, etcdServerPair, etcdClientPair, etcdPeerPairs, err := helpers.CreatePki(masterExtraFQDNs, ips, DefaultKubernetesClusterDomain, caPair, p.MasterProfile.Count, entropy, p.GetPkiOptions())
 	if err != nil {
 		return false, ips, err
 	}
@@ -942,8 +990,8 @@ func mapToString(valueMap map[string]string) string {
 	return strings.TrimSuffix(buf.String(), ",")
 }
 
-func generateEtcdEncryptionKey() string {
+func generateEtcdEncryptionKey(r io.Reader) string {
 	b := make([]byte, 32)
-	rand.Read(b)
+	io.ReadFull(r, b)
 	return base64.StdEncoding.EncodeToString(b)
 }
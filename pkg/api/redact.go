@@ -0,0 +1,156 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/pkg/errors"
+)
+
+// redactTag marks the fields of the api model that hold secrets
+const redactTag = "redact"
+
+// RedactedSecrets maps the JSON paths of the redacted fields of an api model, such as
+// properties.certificateProfile.caPrivateKey or properties.certificateProfile.etcdPeerPrivateKeys[0],
+// to the secrets they held
+type RedactedSecrets map[string]string
+
+// Redact clears the fields of cs tagged with `conform:"redact"` and returns the secrets they held, so that
+// the api model can be shared and the secrets merged back into it with MergeSecrets. Secret references are
+// left alone, they do not hold the secrets themselves.
+func (cs *ContainerService) Redact() RedactedSecrets {
+	redacted := RedactedSecrets{}
+	walkRedactedFields(reflect.ValueOf(cs).Elem(), "", nil, func(path string, field reflect.Value) {
+		switch field.Kind() {
+		case reflect.String:
+			if value := field.String(); value != "" && !secrets.IsReference(value) {
+				redacted[path] = value
+				field.SetString("")
+			}
+		case reflect.Slice:
+			// the secrets are blanked in place so that they line up with the references kept in the list
+			kept := 0
+			for i := 0; i < field.Len(); i++ {
+				element := field.Index(i)
+				if value := element.String(); secrets.IsReference(value) {
+					kept++
+				} else if value != "" {
+					redacted[fmt.Sprintf("%s[%d]", path, i)] = value
+					element.SetString("")
+				}
+			}
+			if kept == 0 {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	})
+	return redacted
+}
+
+// MergeSecrets sets the fields of cs redacted by Redact back to the secrets they held
+func (cs *ContainerService) MergeSecrets(redacted RedactedSecrets) error {
+	merged := map[string]bool{}
+	walkRedactedFields(reflect.ValueOf(cs).Elem(), "", redacted, func(path string, field reflect.Value) {
+		switch field.Kind() {
+		case reflect.String:
+			if value, ok := redacted[path]; ok {
+				field.SetString(value)
+				merged[path] = true
+			}
+		case reflect.Slice:
+			for i := 0; ; i++ {
+				elementPath := fmt.Sprintf("%s[%d]", path, i)
+				value, ok := redacted[elementPath]
+				if !ok {
+					break
+				}
+				if i >= field.Len() {
+					field.Set(reflect.Append(field, reflect.Zero(field.Type().Elem())))
+				}
+				field.Index(i).SetString(value)
+				merged[elementPath] = true
+			}
+		}
+	})
+
+	var unknown []string
+	for path := range redacted {
+		if !merged[path] {
+			unknown = append(unknown, path)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("the api model has no redacted fields %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// walkRedactedFields calls fn for the string and string slice fields under v tagged `conform:"redact"`. Nil
+// structs on the way to the paths of redacted are allocated, as they were dropped from the api model along
+// with their redacted fields.
+func walkRedactedFields(v reflect.Value, path string, redacted RedactedSecrets, fn func(path string, field reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			if v.Type().Elem().Kind() != reflect.Struct || !hasRedactedPrefix(redacted, path+".") {
+				return
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		walkRedactedFields(v.Elem(), path, redacted, fn)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkRedactedFields(v.Index(i), fmt.Sprintf("%s[%d]", path, i), redacted, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field, fieldPath, redact, ok := structField(v, i, path)
+			if !ok {
+				continue
+			}
+			if redact {
+				fn(fieldPath, field)
+				continue
+			}
+			walkRedactedFields(field, fieldPath, redacted, fn)
+		}
+	}
+}
+
+// structField returns the i-th field of the struct v along with its JSON path, and whether it is redacted
+func structField(v reflect.Value, i int, path string) (reflect.Value, string, bool, bool) {
+	f := v.Type().Field(i)
+	if f.PkgPath != "" {
+		return reflect.Value{}, "", false, false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return reflect.Value{}, "", false, false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	if path != "" {
+		name = path + "." + name
+	}
+	field := v.Field(i)
+	redact := f.Tag.Get("conform") == redactTag &&
+		(field.Kind() == reflect.String || field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String)
+	return field, name, redact, true
+}
+
+func hasRedactedPrefix(redacted RedactedSecrets, prefix string) bool {
+	for path := range redacted {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
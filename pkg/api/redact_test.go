@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func getRedactTestContainerService() *ContainerService {
+	return &ContainerService{
+		Properties: &Properties{
+			OrchestratorProfile: &OrchestratorProfile{
+				OrchestratorType: Kubernetes,
+				KubernetesConfig: &KubernetesConfig{
+					EtcdEncryptionKey: "encryptionkey",
+				},
+			},
+			MasterProfile: &MasterProfile{
+				Count:     3,
+				DNSPrefix: "mycluster",
+			},
+			ServicePrincipalProfile: &ServicePrincipalProfile{
+				ClientID: "clientid",
+				Secret:   "clientsecret",
+			},
+			CertificateProfile: &CertificateProfile{
+				CaCertificate:        "cacert",
+				CaPrivateKey:         "cakey",
+				EtcdPeerCertificates: []string{"peercert0", "peercert1"},
+				EtcdPeerPrivateKeys:  []string{"peerkey0", "file:///secrets/peerkey1"},
+			},
+			AADProfile: &AADProfile{
+				ClientAppID:     "clientappid",
+				ServerAppSecret: "serverappsecret",
+			},
+			WindowsProfile: &WindowsProfile{
+				AdminUsername: "azureuser",
+				AdminPassword: "password",
+			},
+		},
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cs := getRedactTestContainerService()
+	redacted := cs.Redact()
+
+	expectedRedacted := RedactedSecrets{
+		"properties.orchestratorProfile.kubernetesConfig.etcdEncryptionKey": "encryptionkey",
+		"properties.servicePrincipalProfile.secret":                         "clientsecret",
+		"properties.certificateProfile.caCertificate":                       "cacert",
+		"properties.certificateProfile.caPrivateKey":                        "cakey",
+		"properties.certificateProfile.etcdPeerCertificates[0]":             "peercert0",
+		"properties.certificateProfile.etcdPeerCertificates[1]":             "peercert1",
+		"properties.certificateProfile.etcdPeerPrivateKeys[0]":              "peerkey0",
+		"properties.aadProfile.serverAppSecret":                             "serverappsecret",
+		"properties.windowsProfile.adminPassword":                           "password",
+	}
+	if diff := cmp.Diff(expectedRedacted, redacted); diff != "" {
+		t.Errorf("unexpected redacted secrets (-want +got):\n%s", diff)
+	}
+
+	expected := getRedactTestContainerService()
+	expected.Properties.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey = ""
+	expected.Properties.ServicePrincipalProfile.Secret = ""
+	expected.Properties.CertificateProfile = &CertificateProfile{
+		// the reference is kept in place, after the secret it was listed with
+		EtcdPeerPrivateKeys: []string{"", "file:///secrets/peerkey1"},
+	}
+	expected.Properties.AADProfile.ServerAppSecret = ""
+	expected.Properties.WindowsProfile.AdminPassword = ""
+	if diff := cmp.Diff(expected, cs); diff != "" {
+		t.Errorf("unexpected redacted api model (-want +got):\n%s", diff)
+	}
+
+	if err := cs.MergeSecrets(redacted); err != nil {
+		t.Fatalf("unexpected error merging the secrets back: %s", err)
+	}
+	if diff := cmp.Diff(getRedactTestContainerService(), cs); diff != "" {
+		t.Errorf("unexpected api model with the secrets merged back (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeSecrets(t *testing.T) {
+	cases := []struct {
+		name      string
+		redacted  RedactedSecrets
+		expected  *ContainerService
+		expectErr bool
+	}{
+		{
+			name:     "nothing to merge",
+			redacted: RedactedSecrets{},
+			expected: &ContainerService{Properties: &Properties{}},
+		},
+		{
+			name: "dropped profiles are recreated",
+			redacted: RedactedSecrets{
+				"properties.certificateProfile.caPrivateKey":           "cakey",
+				"properties.certificateProfile.etcdPeerPrivateKeys[0]": "peerkey0",
+				"properties.certificateProfile.etcdPeerPrivateKeys[1]": "peerkey1",
+			},
+			expected: &ContainerService{
+				Properties: &Properties{
+					CertificateProfile: &CertificateProfile{
+						CaPrivateKey:        "cakey",
+						EtcdPeerPrivateKeys: []string{"peerkey0", "peerkey1"},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown field",
+			redacted: RedactedSecrets{
+				"properties.masterProfile.dnsPrefix": "mycluster",
+			},
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		cs := &ContainerService{Properties: &Properties{}}
+		err := cs.MergeSecrets(c.redacted)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error merging secrets", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error merging secrets: %s", c.name, err)
+			continue
+		}
+		if diff := cmp.Diff(c.expected, cs); diff != "" {
+			t.Errorf("%s: unexpected api model (-want +got):\n%s", c.name, diff)
+		}
+	}
+}
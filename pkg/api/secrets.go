@@ -0,0 +1,230 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/pkg/errors"
+)
+
+// SecretStore moves the generated secrets of an api model, such as private keys, the service principal secret,
+// the Windows admin password and the etcd encryption key, in and out of a secret provider. The api model written
+// to disk then only holds references to them, which later commands resolve again.
+type SecretStore struct {
+	provider secrets.Provider
+	readers  []secrets.Provider
+
+	// stored are the secrets the store put or resolved, by reference
+	stored map[string]storedSecret
+	// references are the last reference of every secret, by secret name
+	references map[string]string
+}
+
+type storedSecret struct {
+	value string
+	owner secrets.Provider
+}
+
+// secretField is a secret of an api model, named after the ARM parameter that carries it
+type secretField struct {
+	name  string
+	value *string
+	// encoded is true for the secrets the ARM parameters carry base64 encoded
+	encoded bool
+}
+
+// NewSecretStore returns a SecretStore that puts new secrets in provider, and resolves references with provider
+// or readers. A store without a provider leaves new secrets in the api model, unless they replace a secret of
+// another provider.
+func NewSecretStore(provider secrets.Provider, readers ...secrets.Provider) *SecretStore {
+	return &SecretStore{
+		provider:   provider,
+		readers:    readers,
+		stored:     map[string]storedSecret{},
+		references: map[string]string{},
+	}
+}
+
+// InUse returns true if the store puts secrets in a provider or resolved any, in which case the api model
+// written to disk refers to the secrets rather than holding them
+func (s *SecretStore) InUse() bool {
+	return s.provider != nil || len(s.stored) > 0
+}
+
+// ResolvedByARM returns true if Azure Resource Manager resolves the references of the provider of the store,
+// so that the parameters of a template generated from an api model with references still refer to the secrets
+func (s *SecretStore) ResolvedByARM() bool {
+	_, ok := s.provider.(*secrets.KeyVaultProvider)
+	return ok
+}
+
+// Resolve replaces the secret references of cs with the secrets they refer to. Key Vault references that were not
+// put by the store are left alone, Azure Resource Manager resolves them when deploying.
+func (s *SecretStore) Resolve(cs *ContainerService) error {
+	for _, f := range cs.secretFields() {
+		reference := *f.value
+		if !secrets.IsReference(reference) {
+			continue
+		}
+		value, ok, err := s.get(reference)
+		if err != nil {
+			return errors.Wrapf(err, "resolving %s", f.name)
+		}
+		if ok {
+			*f.value = value
+			s.references[f.name] = reference
+		}
+	}
+
+	if sp := cs.Properties.ServicePrincipalProfile; sp != nil && sp.KeyvaultSecretRef != nil {
+		reference := sp.KeyvaultSecretRef.VaultID + "/secrets/" + sp.KeyvaultSecretRef.SecretName
+		if sp.KeyvaultSecretRef.SecretVersion != "" {
+			reference += "/" + sp.KeyvaultSecretRef.SecretVersion
+		}
+		if stored, ok := s.stored[reference]; ok {
+			sp.Secret = stored.value
+			sp.KeyvaultSecretRef = nil
+			s.references["servicePrincipalClientSecret"] = reference
+		}
+	}
+	return nil
+}
+
+// Externalize replaces the secrets of cs with references to them, putting the secrets that changed since they
+// were resolved, or are new, in the provider of the store
+func (s *SecretStore) Externalize(cs *ContainerService) error {
+	var owners []secrets.Provider
+	for _, f := range cs.secretFields() {
+		value := *f.value
+		if value == "" || secrets.IsReference(value) {
+			continue
+		}
+		previous := s.references[f.name]
+		if stored, ok := s.stored[previous]; ok && stored.value == value {
+			*f.value = previous
+			continue
+		}
+
+		owner := s.provider
+		if owner == nil {
+			// keep the secret where the one it replaces was kept
+			owner = s.stored[previous].owner
+		}
+		if owner == nil {
+			continue
+		}
+		put := value
+		if _, ok := owner.(*secrets.KeyVaultProvider); ok && f.encoded {
+			// the parameters file refers to the secret in place of the base64 encoded value
+			put = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		reference, err := owner.Put(cs.secretName(f.name), put)
+		if err != nil {
+			return errors.Wrapf(err, "storing %s", f.name)
+		}
+		s.stored[reference] = storedSecret{value: value, owner: owner}
+		s.references[f.name] = reference
+		*f.value = reference
+		owners = appendProvider(owners, owner)
+	}
+
+	for _, owner := range owners {
+		if flusher, ok := owner.(secrets.Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the service principal refers to a Key Vault secret with its own field
+	if sp := cs.Properties.ServicePrincipalProfile; sp != nil {
+		if vaultID, name, version, ok := secrets.ParseKeyVaultReference(sp.Secret); ok {
+			sp.Secret = ""
+			sp.KeyvaultSecretRef = &KeyvaultSecretRef{
+				VaultID:       vaultID,
+				SecretName:    name,
+				SecretVersion: version,
+			}
+		}
+	}
+	return nil
+}
+
+// get returns the secret reference refers to, and false if the store cannot read it
+func (s *SecretStore) get(reference string) (string, bool, error) {
+	if stored, ok := s.stored[reference]; ok {
+		return stored.value, true, nil
+	}
+	if secrets.IsKeyVaultReference(reference) {
+		return "", false, nil
+	}
+	for _, p := range append([]secrets.Provider{s.provider}, s.readers...) {
+		if p == nil || !p.Owns(reference) {
+			continue
+		}
+		value, err := p.Get(reference)
+		if err != nil {
+			return "", false, err
+		}
+		s.stored[reference] = storedSecret{value: value, owner: p}
+		return value, true, nil
+	}
+	return "", false, errors.Errorf("no secret provider can read %s", reference)
+}
+
+// secretFields returns the secrets of cs
+func (cs *ContainerService) secretFields() []secretField {
+	p := cs.Properties
+	var fields []secretField
+	if c := p.CertificateProfile; c != nil {
+		fields = append(fields,
+			secretField{name: "caPrivateKey", value: &c.CaPrivateKey, encoded: true},
+			secretField{name: "apiServerPrivateKey", value: &c.APIServerPrivateKey, encoded: true},
+			secretField{name: "clientPrivateKey", value: &c.ClientPrivateKey, encoded: true},
+			secretField{name: "kubeConfigPrivateKey", value: &c.KubeConfigPrivateKey, encoded: true},
+			secretField{name: "etcdServerPrivateKey", value: &c.EtcdServerPrivateKey, encoded: true},
+			secretField{name: "etcdClientPrivateKey", value: &c.EtcdClientPrivateKey, encoded: true},
+		)
+		for i := range c.EtcdPeerPrivateKeys {
+			fields = append(fields, secretField{name: "etcdPeerPrivateKey" + strconv.Itoa(i), value: &c.EtcdPeerPrivateKeys[i], encoded: true})
+		}
+	}
+	if p.ServicePrincipalProfile != nil {
+		fields = append(fields, secretField{name: "servicePrincipalClientSecret", value: &p.ServicePrincipalProfile.Secret})
+	}
+	if p.WindowsProfile != nil {
+		fields = append(fields, secretField{name: "windowsAdminPassword", value: &p.WindowsProfile.AdminPassword})
+	}
+	if p.OrchestratorProfile != nil && p.OrchestratorProfile.KubernetesConfig != nil {
+		fields = append(fields, secretField{name: "etcdEncryptionKey", value: &p.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey})
+	}
+	return fields
+}
+
+// secretName returns the name a secret of cs is stored under, prefixed by the DNS prefix of the cluster so that
+// clusters can share a provider
+func (cs *ContainerService) secretName(name string) string {
+	var dnsPrefix string
+	if cs.Properties.MasterProfile != nil {
+		dnsPrefix = cs.Properties.MasterProfile.DNSPrefix
+	} else if cs.Properties.HostedMasterProfile != nil {
+		dnsPrefix = cs.Properties.HostedMasterProfile.DNSPrefix
+	}
+	if dnsPrefix == "" {
+		return name
+	}
+	return dnsPrefix + "-" + name
+}
+
+func appendProvider(providers []secrets.Provider, p secrets.Provider) []secrets.Provider {
+	for _, existing := range providers {
+		if existing == p {
+			return providers
+		}
+	}
+	return append(providers, p)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/secrets"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// memoryProvider keeps secrets in memory, behind references that look like file references
+type memoryProvider struct {
+	values map[string]string
+	puts   []string
+}
+
+func (m *memoryProvider) Put(name, value string) (string, error) {
+	if m.values == nil {
+		m.values = map[string]string{}
+	}
+	reference := "file:///memory/" + name
+	m.values[reference] = value
+	m.puts = append(m.puts, name)
+	return reference, nil
+}
+
+func (m *memoryProvider) Get(reference string) (string, error) {
+	value, ok := m.values[reference]
+	if !ok {
+		return "", errors.Errorf("no secret %s", reference)
+	}
+	return value, nil
+}
+
+func (m *memoryProvider) Owns(reference string) bool {
+	return strings.HasPrefix(reference, "file:///memory/")
+}
+
+type recordingDeployer struct {
+	parameters map[string]interface{}
+}
+
+func (r *recordingDeployer) DeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) (resources.DeploymentExtended, error) {
+	r.parameters = parameters
+	return resources.DeploymentExtended{}, nil
+}
+
+func getSecretStoreTestContainerService() *ContainerService {
+	return &ContainerService{
+		Properties: &Properties{
+			OrchestratorProfile: &OrchestratorProfile{
+				OrchestratorType: Kubernetes,
+				KubernetesConfig: &KubernetesConfig{
+					EtcdEncryptionKey: "etcd key",
+				},
+			},
+			MasterProfile: &MasterProfile{
+				DNSPrefix: "cluster",
+			},
+			CertificateProfile: &CertificateProfile{
+				CaCertificate:       "ca certificate",
+				CaPrivateKey:        "ca key",
+				EtcdPeerPrivateKeys: []string{"peer key"},
+			},
+			ServicePrincipalProfile: &ServicePrincipalProfile{
+				ClientID: "client",
+				Secret:   "client secret",
+			},
+			WindowsProfile: &WindowsProfile{
+				AdminUsername: "azureuser",
+				AdminPassword: "Passw0rd!",
+			},
+		},
+	}
+}
+
+func TestSecretStoreExternalizeAndResolve(t *testing.T) {
+	provider := &memoryProvider{}
+	cs := getSecretStoreTestContainerService()
+	if err := NewSecretStore(provider).Externalize(cs); err != nil {
+		t.Fatalf("unexpected error externalizing secrets: %s", err)
+	}
+
+	actualReferences := map[string]string{
+		"caPrivateKey":  cs.Properties.CertificateProfile.CaPrivateKey,
+		"peer key":      cs.Properties.CertificateProfile.EtcdPeerPrivateKeys[0],
+		"client secret": cs.Properties.ServicePrincipalProfile.Secret,
+		"password":      cs.Properties.WindowsProfile.AdminPassword,
+		"etcd key":      cs.Properties.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey,
+	}
+	expectedReferences := map[string]string{
+		"caPrivateKey":  "file:///memory/cluster-caPrivateKey",
+		"peer key":      "file:///memory/cluster-etcdPeerPrivateKey0",
+		"client secret": "file:///memory/cluster-servicePrincipalClientSecret",
+		"password":      "file:///memory/cluster-windowsAdminPassword",
+		"etcd key":      "file:///memory/cluster-etcdEncryptionKey",
+	}
+	if diff := cmp.Diff(expectedReferences, actualReferences); diff != "" {
+		t.Errorf("unexpected references (-want +got):\n%s", diff)
+	}
+	if cs.Properties.CertificateProfile.CaCertificate != "ca certificate" {
+		t.Errorf("expected the CA certificate to stay in the api model")
+	}
+	if provider.values["file:///memory/cluster-caPrivateKey"] != "ca key" {
+		t.Errorf("expected the CA private key to be stored as is, got %q", provider.values["file:///memory/cluster-caPrivateKey"])
+	}
+
+	// a later command resolves the references, changes one secret and externalizes them again
+	store := NewSecretStore(nil, provider)
+	if err := store.Resolve(cs); err != nil {
+		t.Fatalf("unexpected error resolving secrets: %s", err)
+	}
+	if diff := cmp.Diff(getSecretStoreTestContainerService(), cs); diff != "" {
+		t.Errorf("expected resolving to restore the secrets (-want +got):\n%s", diff)
+	}
+	provider.puts = nil
+	cs.Properties.CertificateProfile.CaPrivateKey = "new ca key"
+	if err := store.Externalize(cs); err != nil {
+		t.Fatalf("unexpected error externalizing secrets: %s", err)
+	}
+	if diff := cmp.Diff([]string{"cluster-caPrivateKey"}, provider.puts); diff != "" {
+		t.Errorf("expected only the changed secret to be stored again (-want +got):\n%s", diff)
+	}
+	if provider.values["file:///memory/cluster-caPrivateKey"] != "new ca key" {
+		t.Errorf("expected the new CA private key to be stored")
+	}
+	if cs.Properties.WindowsProfile.AdminPassword != "file:///memory/cluster-windowsAdminPassword" {
+		t.Errorf("expected the unchanged password to refer to its secret, got %s", cs.Properties.WindowsProfile.AdminPassword)
+	}
+}
+
+func TestSecretStoreWithoutProvider(t *testing.T) {
+	cs := getSecretStoreTestContainerService()
+	if err := NewSecretStore(nil).Externalize(cs); err != nil {
+		t.Fatalf("unexpected error externalizing secrets: %s", err)
+	}
+	if diff := cmp.Diff(getSecretStoreTestContainerService(), cs); diff != "" {
+		t.Errorf("expected a store without a provider to leave the secrets alone (-want +got):\n%s", diff)
+	}
+
+	cs.Properties.CertificateProfile.CaPrivateKey = "file:///memory/cluster-caPrivateKey"
+	if err := NewSecretStore(nil).Resolve(cs); err == nil {
+		t.Errorf("expected an error resolving a reference no provider can read")
+	}
+}
+
+func TestSecretStoreKeyVault(t *testing.T) {
+	vaultID := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/vault"
+	deployer := &recordingDeployer{}
+	provider, err := secrets.NewKeyVaultProvider(vaultID, deployer)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	store := NewSecretStore(provider)
+	if !store.ResolvedByARM() {
+		t.Errorf("expected Key Vault references to be resolved by ARM")
+	}
+
+	cs := getSecretStoreTestContainerService()
+	if err = store.Externalize(cs); err != nil {
+		t.Fatalf("unexpected error externalizing secrets: %s", err)
+	}
+	if cs.Properties.CertificateProfile.CaPrivateKey != vaultID+"/secrets/cluster-caPrivateKey" {
+		t.Errorf("unexpected CA private key reference %s", cs.Properties.CertificateProfile.CaPrivateKey)
+	}
+	expectedRef := &KeyvaultSecretRef{VaultID: vaultID, SecretName: "cluster-servicePrincipalClientSecret"}
+	if diff := cmp.Diff(expectedRef, cs.Properties.ServicePrincipalProfile.KeyvaultSecretRef); diff != "" {
+		t.Errorf("expected the service principal to refer to its Key Vault secret (-want +got):\n%s", diff)
+	}
+	if cs.Properties.ServicePrincipalProfile.Secret != "" {
+		t.Errorf("expected the service principal secret to be cleared")
+	}
+
+	// the ARM parameters carry private keys base64 encoded, and the other secrets as they are
+	values := map[string]bool{}
+	for _, p := range deployer.parameters {
+		values[p.(map[string]interface{})["value"].(string)] = true
+	}
+	for _, expected := range []string{base64.StdEncoding.EncodeToString([]byte("ca key")), "Passw0rd!", "client secret", "etcd key"} {
+		if !values[expected] {
+			t.Errorf("expected %q to be written to the vault", expected)
+		}
+	}
+
+	// the store resolves the secrets it put itself, which writing the kubeconfig needs
+	if err = store.Resolve(cs); err != nil {
+		t.Fatalf("unexpected error resolving secrets: %s", err)
+	}
+	if diff := cmp.Diff(getSecretStoreTestContainerService(), cs); diff != "" {
+		t.Errorf("expected resolving to restore the secrets (-want +got):\n%s", diff)
+	}
+
+	// Key Vault references are left for ARM to resolve
+	cs = getSecretStoreTestContainerService()
+	cs.Properties.CertificateProfile.CaPrivateKey = vaultID + "/secrets/other"
+	if err = NewSecretStore(nil).Resolve(cs); err != nil {
+		t.Fatalf("unexpected error resolving secrets: %s", err)
+	}
+	if cs.Properties.CertificateProfile.CaPrivateKey != vaultID+"/secrets/other" {
+		t.Errorf("expected the Key Vault reference to be left alone")
+	}
+}
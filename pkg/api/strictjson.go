@@ -6,6 +6,7 @@ package api
 import (
 	"encoding/json"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -22,7 +23,14 @@ func checkJSONKeys(data []byte, types ...reflect.Type) error {
 
 func checkMapKeys(o map[string]interface{}, types ...reflect.Type) error {
 	fieldMap := createJSONFieldMap(types)
-	for k, v := range o {
+	// check the keys in order, so that the same api model always reports the same unknown tag
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := o[k]
 		f, present := fieldMap[strings.ToLower(k)]
 		if !present {
 			return errors.Errorf("Unknown JSON tag %s", k)
@@ -80,6 +80,26 @@ func TestCheckFailsOnUnexpectedJSONKeyAtTopLevel(t *testing.T) {
 	}
 }
 
+func TestCheckReportsTheFirstUnexpectedJSONKeyInOrder(t *testing.T) {
+	json := `
+	{
+		"fz": "uh-oh",
+		"f1": 1,
+		"fx": "uh-oh",
+		"fy": "uh-oh"
+	}
+	`
+	for i := 0; i < 10; i++ {
+		e := checkJSONKeys([]byte(json), reflect.TypeOf(TestProfile{}))
+		if e == nil {
+			t.Fatal("Unexpected JSON key was not detected")
+		}
+		if e.Error() != "Unknown JSON tag fx" {
+			t.Fatalf("Error message did not name the first unexpected JSON key 'fx': was %v", e)
+		}
+	}
+}
+
 func TestCheckFailsOnUnexpectedJSONKeyAtSubLevel(t *testing.T) {
 	json := `
 	{
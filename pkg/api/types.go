@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	v20170831 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20170831"
 	v20180331 "github.com/Azure/aks-engine/pkg/api/agentPoolOnlyApi/v20180331"
@@ -73,24 +74,26 @@ type AgentPoolResource struct {
 
 // Properties represents the AKS cluster definition
 type Properties struct {
-	ClusterID               string
-	ProvisioningState       ProvisioningState        `json:"provisioningState,omitempty"`
-	OrchestratorProfile     *OrchestratorProfile     `json:"orchestratorProfile,omitempty"`
-	MasterProfile           *MasterProfile           `json:"masterProfile,omitempty"`
-	AgentPoolProfiles       []*AgentPoolProfile      `json:"agentPoolProfiles,omitempty"`
-	LinuxProfile            *LinuxProfile            `json:"linuxProfile,omitempty"`
-	WindowsProfile          *WindowsProfile          `json:"windowsProfile,omitempty"`
-	ExtensionProfiles       []*ExtensionProfile      `json:"extensionProfiles"`
-	DiagnosticsProfile      *DiagnosticsProfile      `json:"diagnosticsProfile,omitempty"`
-	JumpboxProfile          *JumpboxProfile          `json:"jumpboxProfile,omitempty"`
-	ServicePrincipalProfile *ServicePrincipalProfile `json:"servicePrincipalProfile,omitempty"`
-	CertificateProfile      *CertificateProfile      `json:"certificateProfile,omitempty"`
-	AADProfile              *AADProfile              `json:"aadProfile,omitempty"`
-	CustomProfile           *CustomProfile           `json:"customProfile,omitempty"`
-	HostedMasterProfile     *HostedMasterProfile     `json:"hostedMasterProfile,omitempty"`
-	AddonProfiles           map[string]AddonProfile  `json:"addonProfiles,omitempty"`
-	FeatureFlags            *FeatureFlags            `json:"featureFlags,omitempty"`
-	CustomCloudProfile      *CustomCloudProfile      `json:"customCloudProfile,omitempty"`
+	ClusterID                   string
+	ProvisioningState           ProvisioningState            `json:"provisioningState,omitempty"`
+	OrchestratorProfile         *OrchestratorProfile         `json:"orchestratorProfile,omitempty"`
+	MasterProfile               *MasterProfile               `json:"masterProfile,omitempty"`
+	AgentPoolProfiles           []*AgentPoolProfile          `json:"agentPoolProfiles,omitempty"`
+	LinuxProfile                *LinuxProfile                `json:"linuxProfile,omitempty"`
+	WindowsProfile              *WindowsProfile              `json:"windowsProfile,omitempty"`
+	ExtensionProfiles           []*ExtensionProfile          `json:"extensionProfiles"`
+	DiagnosticsProfile          *DiagnosticsProfile          `json:"diagnosticsProfile,omitempty"`
+	JumpboxProfile              *JumpboxProfile              `json:"jumpboxProfile,omitempty"`
+	ServicePrincipalProfile     *ServicePrincipalProfile     `json:"servicePrincipalProfile,omitempty"`
+	CertificateProfile          *CertificateProfile          `json:"certificateProfile,omitempty"`
+	PKIProfile                  *PKIProfile                  `json:"pkiProfile,omitempty"`
+	AADProfile                  *AADProfile                  `json:"aadProfile,omitempty"`
+	CustomProfile               *CustomProfile               `json:"customProfile,omitempty"`
+	HostedMasterProfile         *HostedMasterProfile         `json:"hostedMasterProfile,omitempty"`
+	AddonProfiles               map[string]AddonProfile      `json:"addonProfiles,omitempty"`
+	FeatureFlags                *FeatureFlags                `json:"featureFlags,omitempty"`
+	CustomCloudProfile          *CustomCloudProfile          `json:"customCloudProfile,omitempty"`
+	NetworkSecurityGroupProfile *NetworkSecurityGroupProfile `json:"networkSecurityGroupProfile,omitempty"`
 }
 
 // ClusterMetadata represents the metadata of the AKS cluster.
@@ -140,6 +143,9 @@ type CertificateProfile struct {
 	CaCertificate string `json:"caCertificate,omitempty" conform:"redact"`
 	// CaPrivateKey is the certificate authority key.
 	CaPrivateKey string `json:"caPrivateKey,omitempty" conform:"redact"`
+	// CaCertificateChain holds the PEM encoded certificates that chain an intermediate CaCertificate to a root CA,
+	// the issuer of CaCertificate first
+	CaCertificateChain string `json:"caCertificateChain,omitempty" conform:"redact"`
 	// ApiServerCertificate is the rest api server certificate, and signed by the CA
 	APIServerCertificate string `json:"apiServerCertificate,omitempty" conform:"redact"`
 	// ApiServerPrivateKey is the rest api server private key, and signed by the CA
@@ -166,6 +172,30 @@ type CertificateProfile struct {
 	EtcdPeerPrivateKeys []string `json:"etcdPeerPrivateKeys,omitempty" conform:"redact"`
 }
 
+// PKIProfile configures the keys and certificates generated for the cluster
+type PKIProfile struct {
+	// KeyAlgorithm is the algorithm of the generated private keys, either RSA or ECDSA.
+	// If not specified, RSA.
+	// Optional
+	KeyAlgorithm string `json:"keyAlgorithm,omitempty"`
+	// KeySize is the size in bits of RSA keys, 2048, 3072 or 4096, or of the curve of ECDSA keys, 256 or 384.
+	// If not specified, 4096 for RSA and 256 for ECDSA.
+	// Optional
+	KeySize int `json:"keySize,omitempty"`
+	// CAValidityDays is how many days a generated CA certificate is valid.
+	// If not specified, 30 years.
+	// Optional
+	CAValidityDays int `json:"caValidityDays,omitempty"`
+	// LeafValidityDays is how many days the certificates signed by the CA are valid.
+	// If not specified, 30 years.
+	// Optional
+	LeafValidityDays int `json:"leafValidityDays,omitempty"`
+	// ExtraSubjectAltNames are DNS names and IP addresses added to the certificates of a component,
+	// keyed by apiserver, etcdserver, etcdclient or etcdpeer
+	// Optional
+	ExtraSubjectAltNames map[string][]string `json:"extraSubjectAltNames,omitempty"`
+}
+
 // LinuxProfile represents the linux parameters passed to the cluster
 type LinuxProfile struct {
 	AdminUsername string `json:"adminUsername"`
@@ -199,17 +229,29 @@ type CustomNodesDNS struct {
 
 // WindowsProfile represents the windows parameters passed to the cluster
 type WindowsProfile struct {
-	AdminUsername          string            `json:"adminUsername"`
-	AdminPassword          string            `json:"adminPassword" conform:"redact"`
-	ImageVersion           string            `json:"imageVersion"`
-	WindowsImageSourceURL  string            `json:"windowsImageSourceURL"`
-	WindowsPublisher       string            `json:"windowsPublisher"`
-	WindowsOffer           string            `json:"windowsOffer"`
-	WindowsSku             string            `json:"windowsSku"`
-	WindowsDockerVersion   string            `json:"windowsDockerVersion"`
-	Secrets                []KeyVaultSecrets `json:"secrets,omitempty"`
-	SSHEnabled             bool              `json:"sshEnabled,omitempty"`
-	EnableAutomaticUpdates *bool             `json:"enableAutomaticUpdates,omitempty"`
+	AdminUsername          string             `json:"adminUsername"`
+	AdminPassword          string             `json:"adminPassword" conform:"redact"`
+	ImageVersion           string             `json:"imageVersion"`
+	WindowsImageSourceURL  string             `json:"windowsImageSourceURL"`
+	WindowsPublisher       string             `json:"windowsPublisher"`
+	WindowsOffer           string             `json:"windowsOffer"`
+	WindowsSku             string             `json:"windowsSku"`
+	WindowsDockerVersion   string             `json:"windowsDockerVersion"`
+	Secrets                []KeyVaultSecrets  `json:"secrets,omitempty"`
+	SSHEnabled             bool               `json:"sshEnabled,omitempty"`
+	EnableAutomaticUpdates *bool              `json:"enableAutomaticUpdates,omitempty"`
+	ImageRef               *ImageReference    `json:"imageReference,omitempty"`
+	PrePullImages          []string           `json:"prePullImages,omitempty"`
+	DomainJoin             *WindowsDomainJoin `json:"domainJoin,omitempty"`
+}
+
+// WindowsDomainJoin represents the Active Directory domain the Windows nodes join, for the containers to run as
+// group Managed Service Accounts. The password of the join account is only read from Key Vault at deployment time.
+type WindowsDomainJoin struct {
+	Domain             string             `json:"domain"`
+	OrganizationalUnit string             `json:"organizationalUnit,omitempty"`
+	UserName           string             `json:"userName"`
+	KeyvaultSecretRef  *KeyvaultSecretRef `json:"keyvaultSecretRef"`
 }
 
 // ProvisioningState represents the current state of container service resource.
@@ -292,8 +334,10 @@ func (a KubernetesAddon) GetAddonContainersIndexByName(containerName string) int
 
 // PrivateCluster defines the configuration for a private cluster
 type PrivateCluster struct {
-	Enabled        *bool                  `json:"enabled,omitempty"`
-	JumpboxProfile *PrivateJumpboxProfile `json:"jumpboxProfile,omitempty"`
+	Enabled            *bool                  `json:"enabled,omitempty"`
+	FullyPrivate       *bool                  `json:"fullyPrivate,omitempty"`
+	PrivateDNSZoneName string                 `json:"privateDNSZoneName,omitempty"`
+	JumpboxProfile     *PrivateJumpboxProfile `json:"jumpboxProfile,omitempty"`
 }
 
 // PrivateJumpboxProfile represents a jumpbox definition
@@ -363,6 +407,7 @@ type KubernetesConfig struct {
 	UseCloudControllerManager        *bool             `json:"useCloudControllerManager,omitempty"`
 	CustomWindowsPackageURL          string            `json:"customWindowsPackageURL,omitempty"`
 	WindowsNodeBinariesURL           string            `json:"windowsNodeBinariesURL,omitempty"`
+	WindowsContainerdURL             string            `json:"windowsContainerdURL,omitempty"`
 	UseInstanceMetadata              *bool             `json:"useInstanceMetadata,omitempty"`
 	EnableRbac                       *bool             `json:"enableRbac,omitempty"`
 	EnableSecureKubelet              *bool             `json:"enableSecureKubelet,omitempty"`
@@ -372,7 +417,7 @@ type KubernetesConfig struct {
 	GCLowThreshold                   int               `json:"gclowthreshold,omitempty"`
 	EtcdVersion                      string            `json:"etcdVersion,omitempty"`
 	EtcdDiskSizeGB                   string            `json:"etcdDiskSizeGB,omitempty"`
-	EtcdEncryptionKey                string            `json:"etcdEncryptionKey,omitempty"`
+	EtcdEncryptionKey                string            `json:"etcdEncryptionKey,omitempty" conform:"redact"`
 	EnableDataEncryptionAtRest       *bool             `json:"enableDataEncryptionAtRest,omitempty"`
 	EnableEncryptionWithExternalKms  *bool             `json:"enableEncryptionWithExternalKms,omitempty"`
 	EnablePodSecurityPolicy          *bool             `json:"enablePodSecurityPolicy,omitempty"`
@@ -407,6 +452,7 @@ type KubernetesConfig struct {
 	ProxyMode                        KubeProxyMode     `json:"kubeProxyMode,omitempty"`
 	PrivateAzureRegistryServer       string            `json:"privateAzureRegistryServer,omitempty"`
 	OutboundRuleIdleTimeoutInMinutes int32             `json:"outboundRuleIdleTimeoutInMinutes,omitempty"`
+	OutboundType                     string            `json:"outboundType,omitempty"`
 }
 
 // CustomFile has source as the full absolute source path to a file and dest
@@ -458,6 +504,8 @@ type MasterProfile struct {
 	VnetSubnetID             string            `json:"vnetSubnetID,omitempty"`
 	VnetCidr                 string            `json:"vnetCidr,omitempty"`
 	AgentVnetSubnetID        string            `json:"agentVnetSubnetID,omitempty"`
+	RouteTableID             string            `json:"routeTableID,omitempty"`
+	NetworkSecurityGroupID   string            `json:"networkSecurityGroupID,omitempty"`
 	FirstConsecutiveStaticIP string            `json:"firstConsecutiveStaticIP,omitempty"`
 	Subnet                   string            `json:"subnet"`
 	SubnetIPv6               string            `json:"subnetIPv6"`
@@ -557,6 +605,7 @@ type AgentPoolProfile struct {
 	LoadBalancerBackendAddressPoolIDs   []string             `json:"loadBalancerBackendAddressPoolIDs,omitempty"`
 	AuditDEnabled                       *bool                `json:"auditDEnabled,omitempty"`
 	CustomVMTags                        map[string]string    `json:"customVMTags,omitempty"`
+	WindowsIsolation                    string               `json:"windowsIsolation,omitempty"`
 }
 
 // AgentPoolProfileRole represents an agent role
@@ -759,6 +808,33 @@ type CustomCloudProfile struct {
 	PortalURL                  string                      `json:"portalURL,omitempty"`
 }
 
+// NetworkSecurityGroupProfile customizes the network security group generated for the cluster
+type NetworkSecurityGroupProfile struct {
+	// SSHSourceAddressPrefixes restricts the source address ranges allowed by the SSH rules.
+	// If not specified, SSH is allowed from any source.
+	// Optional
+	SSHSourceAddressPrefixes []string `json:"sshSourceAddressPrefixes,omitempty"`
+	// SecurityRules are custom rules merged into the generated network security group
+	// Optional
+	SecurityRules []SecurityRule `json:"securityRules,omitempty"`
+}
+
+// SecurityRule represents a custom network security group rule
+type SecurityRule struct {
+	Name                       string   `json:"name"`
+	Description                string   `json:"description,omitempty"`
+	Protocol                   string   `json:"protocol"`
+	SourcePortRange            string   `json:"sourcePortRange,omitempty"`
+	DestinationPortRange       string   `json:"destinationPortRange,omitempty"`
+	SourceAddressPrefix        string   `json:"sourceAddressPrefix,omitempty"`
+	SourceAddressPrefixes      []string `json:"sourceAddressPrefixes,omitempty"`
+	DestinationAddressPrefix   string   `json:"destinationAddressPrefix,omitempty"`
+	DestinationAddressPrefixes []string `json:"destinationAddressPrefixes,omitempty"`
+	Access                     string   `json:"access"`
+	Priority                   int32    `json:"priority"`
+	Direction                  string   `json:"direction"`
+}
+
 // HasCoreOS returns true if the cluster contains coreos nodes
 func (p *Properties) HasCoreOS() bool {
 	for _, agentPoolProfile := range p.AgentPoolProfiles {
@@ -779,6 +855,18 @@ func (p *Properties) HasWindows() bool {
 	return false
 }
 
+// GetWindowsImageRef returns the os image the customer brought for a Windows agent pool, the one of the agent pool
+// or else the one of the windows profile, nil when the agent pool runs a marketplace or WindowsImageSourceURL image
+func (p *Properties) GetWindowsImageRef(a *AgentPoolProfile) *ImageReference {
+	if a.HasImageRef() {
+		return a.ImageRef
+	}
+	if p.WindowsProfile != nil && p.WindowsProfile.HasImageRef() {
+		return p.WindowsProfile.ImageRef
+	}
+	return nil
+}
+
 // HasManagedDisks returns true if the cluster contains Managed Disks
 func (p *Properties) HasManagedDisks() bool {
 	if p.MasterProfile != nil && p.MasterProfile.StorageProfile == ManagedDisks {
@@ -933,14 +1021,53 @@ func (p *Properties) GetResourcePrefix() string {
 
 // GetRouteTableName returns the route table name of the cluster.
 func (p *Properties) GetRouteTableName() string {
+	if p.MasterProfile != nil && p.MasterProfile.IsCustomRouteTable() {
+		return strings.Split(p.MasterProfile.RouteTableID, "/")[DefaultResourceNameSegmentIndex]
+	}
 	return p.GetResourcePrefix() + "routetable"
 }
 
+// GetRouteTableResourceGroupName returns the resource group name of the route table of the cluster,
+// or an empty string if the route table is created in the cluster resource group.
+func (p *Properties) GetRouteTableResourceGroupName() string {
+	if p.MasterProfile != nil && p.MasterProfile.IsCustomRouteTable() {
+		return strings.Split(p.MasterProfile.RouteTableID, "/")[DefaultResourceGroupSegmentIndex]
+	}
+	return ""
+}
+
+// GetPrivateAPIServerFQDN returns the FQDN of the API server in the private DNS zone of a fully private cluster,
+// or an empty string if the cluster is not fully private. The API server record is created at the zone apex.
+func (p *Properties) GetPrivateAPIServerFQDN() string {
+	if p.OrchestratorProfile == nil || !p.OrchestratorProfile.IsFullyPrivateCluster() {
+		return ""
+	}
+	if p.OrchestratorProfile.KubernetesConfig.PrivateCluster.PrivateDNSZoneName != "" {
+		return p.OrchestratorProfile.KubernetesConfig.PrivateCluster.PrivateDNSZoneName
+	}
+	if p.MasterProfile != nil {
+		return p.MasterProfile.DNSPrefix + DefaultPrivateDNSZoneSuffix
+	}
+	return ""
+}
+
 // GetNSGName returns the name of the network security group of the cluster.
 func (p *Properties) GetNSGName() string {
+	if p.MasterProfile != nil && p.MasterProfile.IsCustomNSG() {
+		return strings.Split(p.MasterProfile.NetworkSecurityGroupID, "/")[DefaultResourceNameSegmentIndex]
+	}
 	return p.GetResourcePrefix() + "nsg"
 }
 
+// GetNSGResourceGroupName returns the resource group name of the network security group of the cluster,
+// or an empty string if the network security group is created in the cluster resource group.
+func (p *Properties) GetNSGResourceGroupName() string {
+	if p.MasterProfile != nil && p.MasterProfile.IsCustomNSG() {
+		return strings.Split(p.MasterProfile.NetworkSecurityGroupID, "/")[DefaultResourceGroupSegmentIndex]
+	}
+	return ""
+}
+
 // GetPrimaryAvailabilitySetName returns the name of the primary availability set of the cluster
 func (p *Properties) GetPrimaryAvailabilitySetName() string {
 	if len(p.AgentPoolProfiles) > 0 {
@@ -1220,6 +1347,11 @@ func (p *Properties) AnyAgentHasLoadBalancerBackendAddressPoolIDs() bool {
 	return false
 }
 
+// IsGalleryImage returns true if the image is an image version of a Shared Image Gallery
+func (i *ImageReference) IsGalleryImage() bool {
+	return i != nil && len(i.SubscriptionID) > 0 && len(i.Gallery) > 0 && len(i.Version) > 0
+}
+
 // HasImageRef returns true if the customer brought os image
 func (m *MasterProfile) HasImageRef() bool {
 	return m.ImageRef != nil && len(m.ImageRef.Name) > 0 && len(m.ImageRef.ResourceGroup) > 0
@@ -1235,6 +1367,16 @@ func (m *MasterProfile) IsCustomVNET() bool {
 	return len(m.VnetSubnetID) > 0
 }
 
+// IsCustomRouteTable returns true if the customer brought their own route table
+func (m *MasterProfile) IsCustomRouteTable() bool {
+	return len(m.RouteTableID) > 0
+}
+
+// IsCustomNSG returns true if the customer brought their own network security group
+func (m *MasterProfile) IsCustomNSG() bool {
+	return len(m.NetworkSecurityGroupID) > 0
+}
+
 // IsManagedDisks returns true if the master specified managed disks
 func (m *MasterProfile) IsManagedDisks() bool {
 	return m.StorageProfile == ManagedDisks
@@ -1355,8 +1497,7 @@ func (a *AgentPoolProfile) HasImageRef() bool {
 
 // HasImageGallery returns true if the customer brought os image from Shared Image Gallery
 func (a *AgentPoolProfile) HasImageGallery() bool {
-	imageRef := a.ImageRef
-	return imageRef != nil && len(imageRef.SubscriptionID) > 0 && len(imageRef.Gallery) > 0 && len(imageRef.Version) > 0
+	return a.ImageRef.IsGalleryImage()
 }
 
 // IsCustomVNET returns true if the customer brought their own VNET
@@ -1369,6 +1510,19 @@ func (a *AgentPoolProfile) IsWindows() bool {
 	return a.OSType == Windows
 }
 
+// IsHyperVIsolation returns true if the agent pool is windows and runs its pods in Hyper-V utility VMs
+func (a *AgentPoolProfile) IsHyperVIsolation() bool {
+	return a.IsWindows() && a.WindowsIsolation == WindowsIsolationHyperV
+}
+
+// GetWindowsIsolation returns the isolation of the containers of a windows agent pool, process isolation by default
+func (a *AgentPoolProfile) GetWindowsIsolation() string {
+	if a.WindowsIsolation != "" {
+		return a.WindowsIsolation
+	}
+	return WindowsIsolationProcess
+}
+
 // IsLinux returns true if the agent pool is linux
 func (a *AgentPoolProfile) IsLinux() bool {
 	return a.OSType == Linux
@@ -1504,6 +1658,16 @@ func (w *WindowsProfile) HasCustomImage() bool {
 	return len(w.WindowsImageSourceURL) > 0
 }
 
+// HasDomainJoin returns true if the Windows nodes join an Active Directory domain
+func (w *WindowsProfile) HasDomainJoin() bool {
+	return w != nil && w.DomainJoin != nil
+}
+
+// HasImageRef returns true if the customer brought the os image of the Windows agent pools
+func (w *WindowsProfile) HasImageRef() bool {
+	return w.ImageRef != nil && len(w.ImageRef.Name) > 0 && len(w.ImageRef.ResourceGroup) > 0
+}
+
 // GetWindowsDockerVersion gets the docker version specified or returns default value
 func (w *WindowsProfile) GetWindowsDockerVersion() string {
 	if w.WindowsDockerVersion != "" {
@@ -1604,6 +1768,11 @@ func (o *OrchestratorProfile) IsPrivateCluster() bool {
 	return o.KubernetesConfig != nil && o.KubernetesConfig.PrivateCluster != nil && to.Bool(o.KubernetesConfig.PrivateCluster.Enabled)
 }
 
+// IsFullyPrivateCluster returns true if this is a private cluster with no public IP addresses
+func (o *OrchestratorProfile) IsFullyPrivateCluster() bool {
+	return o.IsPrivateCluster() && to.Bool(o.KubernetesConfig.PrivateCluster.FullyPrivate)
+}
+
 // NeedsExecHealthz returns whether or not we have a configuration that requires exechealthz pod anywhere
 func (o *OrchestratorProfile) NeedsExecHealthz() bool {
 	return o.IsKubernetes() &&
@@ -1616,6 +1785,28 @@ func (p *Properties) HasAadProfile() bool {
 	return p.AADProfile != nil
 }
 
+// GetCaCertificateBundle returns the CA certificate followed by its chain, if any, for clients to trust
+func (c *CertificateProfile) GetCaCertificateBundle() string {
+	if c.CaCertificateChain == "" {
+		return c.CaCertificate
+	}
+	return strings.TrimRight(c.CaCertificate, "\n") + "\n" + c.CaCertificateChain
+}
+
+// GetPkiOptions returns the options the PKI of the cluster is generated with, nil for the defaults
+func (p *Properties) GetPkiOptions() *helpers.PkiOptions {
+	if p.PKIProfile == nil {
+		return nil
+	}
+	return &helpers.PkiOptions{
+		KeyAlgorithm:         p.PKIProfile.KeyAlgorithm,
+		KeySize:              p.PKIProfile.KeySize,
+		CAValidity:           time.Duration(p.PKIProfile.CAValidityDays) * 24 * time.Hour,
+		LeafValidity:         time.Duration(p.PKIProfile.LeafValidityDays) * 24 * time.Hour,
+		ExtraSubjectAltNames: p.PKIProfile.ExtraSubjectAltNames,
+	}
+}
+
 // GetAPIServerEtcdAPIVersion Used to set apiserver's etcdapi version
 func (o *OrchestratorProfile) GetAPIServerEtcdAPIVersion() string {
 	if o.KubernetesConfig != nil {
@@ -1857,6 +2048,16 @@ func (k *KubernetesConfig) IsReschedulerEnabled() bool {
 	return k.IsAddonEnabled(ReschedulerAddonName)
 }
 
+// IsLoadBalancerOutbound checks if outbound traffic is routed through the cluster load balancers
+func (k *KubernetesConfig) IsLoadBalancerOutbound() bool {
+	return k != nil && (k.OutboundType == "" || k.OutboundType == OutboundTypeLoadBalancer)
+}
+
+// IsNATGatewayOutbound checks if outbound traffic is routed through a NAT gateway created by aks-engine
+func (k *KubernetesConfig) IsNATGatewayOutbound() bool {
+	return k != nil && k.OutboundType == OutboundTypeNATGateway
+}
+
 // PrivateJumpboxProvision checks if a private cluster has jumpbox auto-provisioning
 func (k *KubernetesConfig) PrivateJumpboxProvision() bool {
 	if k != nil && k.PrivateCluster != nil && *k.PrivateCluster.Enabled && k.PrivateCluster.JumpboxProfile != nil {
@@ -1903,6 +2104,14 @@ func (k *KubernetesConfig) GetAzureCNIURLWindows(cloudSpecConfig AzureEnvironmen
 	return cloudSpecConfig.KubernetesSpecConfig.VnetCNIWindowsPluginsDownloadURL
 }
 
+// GetWindowsContainerdURL returns the full URL to source the containerd binaries of windows nodes from
+func (k *KubernetesConfig) GetWindowsContainerdURL(cloudSpecConfig AzureEnvironmentSpecConfig) string {
+	if k.WindowsContainerdURL != "" {
+		return k.WindowsContainerdURL
+	}
+	return cloudSpecConfig.KubernetesSpecConfig.WindowsContainerdDownloadURL
+}
+
 // IsFeatureEnabled returns true if a feature flag is on for the provided feature
 func (f *FeatureFlags) IsFeatureEnabled(feature string) bool {
 	if f != nil {
@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
 
@@ -265,6 +266,125 @@ func TestAgentPoolProfileIsVHDDistro(t *testing.T) {
 	}
 }
 
+func TestGetWindowsImageRef(t *testing.T) {
+	poolImage := &ImageReference{
+		Name:          "pool",
+		ResourceGroup: "images",
+	}
+	clusterImage := &ImageReference{
+		Name:           "cluster",
+		ResourceGroup:  "images",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		Gallery:        "gallery",
+		Version:        "1.0.0",
+	}
+	cases := []struct {
+		name           string
+		poolImage      *ImageReference
+		windowsProfile *WindowsProfile
+		expected       *ImageReference
+	}{
+		{
+			name:           "marketplace image",
+			windowsProfile: &WindowsProfile{},
+		},
+		{
+			name:           "image of the agent pool",
+			poolImage:      poolImage,
+			windowsProfile: &WindowsProfile{},
+			expected:       poolImage,
+		},
+		{
+			name:           "image of the windows profile",
+			windowsProfile: &WindowsProfile{ImageRef: clusterImage},
+			expected:       clusterImage,
+		},
+		{
+			name:           "agent pool overriding the windows profile",
+			poolImage:      poolImage,
+			windowsProfile: &WindowsProfile{ImageRef: clusterImage},
+			expected:       poolImage,
+		},
+		{
+			name:           "incomplete image of the agent pool",
+			poolImage:      &ImageReference{Name: "pool"},
+			windowsProfile: &WindowsProfile{ImageRef: clusterImage},
+			expected:       clusterImage,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			p := &Properties{WindowsProfile: c.windowsProfile}
+			a := &AgentPoolProfile{OSType: Windows, ImageRef: c.poolImage}
+			if actual := p.GetWindowsImageRef(a); actual != c.expected {
+				t.Errorf("expected image %v, got %v", c.expected, actual)
+			}
+		})
+	}
+
+	if poolImage.IsGalleryImage() || !clusterImage.IsGalleryImage() {
+		t.Errorf("expected only the image with a subscription, gallery and version to be a Shared Image Gallery image")
+	}
+}
+
+func TestAgentPoolProfileWindowsIsolation(t *testing.T) {
+	cases := []struct {
+		name              string
+		ap                AgentPoolProfile
+		expectedIsolation string
+		expectedHyperV    bool
+	}{
+		{
+			name: "windows pool without isolation",
+			ap: AgentPoolProfile{
+				OSType: Windows,
+			},
+			expectedIsolation: WindowsIsolationProcess,
+		},
+		{
+			name: "windows pool with process isolation",
+			ap: AgentPoolProfile{
+				OSType:           Windows,
+				WindowsIsolation: WindowsIsolationProcess,
+			},
+			expectedIsolation: WindowsIsolationProcess,
+		},
+		{
+			name: "windows pool with Hyper-V isolation",
+			ap: AgentPoolProfile{
+				OSType:           Windows,
+				WindowsIsolation: WindowsIsolationHyperV,
+			},
+			expectedIsolation: WindowsIsolationHyperV,
+			expectedHyperV:    true,
+		},
+		{
+			name: "linux pool",
+			ap: AgentPoolProfile{
+				OSType:           Linux,
+				WindowsIsolation: WindowsIsolationHyperV,
+			},
+			expectedIsolation: WindowsIsolationHyperV,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			if isolation := c.ap.GetWindowsIsolation(); isolation != c.expectedIsolation {
+				t.Errorf("Got unexpected AgentPoolProfile.GetWindowsIsolation() result. Expected: %s. Got: %s.", c.expectedIsolation, isolation)
+			}
+			if hyperV := c.ap.IsHyperVIsolation(); hyperV != c.expectedHyperV {
+				t.Errorf("Got unexpected AgentPoolProfile.IsHyperVIsolation() result. Expected: %t. Got: %t.", c.expectedHyperV, hyperV)
+			}
+		})
+	}
+}
+
 func TestAgentPoolProfileIsUbuntuNonVHD(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -2688,6 +2808,96 @@ func TestIsPrivateCluster(t *testing.T) {
 	}
 }
 
+func TestIsFullyPrivateCluster(t *testing.T) {
+	cases := []struct {
+		p            Properties
+		expected     bool
+		expectedFQDN string
+	}{
+		{
+			p: Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType: Kubernetes,
+				},
+			},
+			expected: false,
+		},
+		{
+			p: Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType: Kubernetes,
+					KubernetesConfig: &KubernetesConfig{
+						PrivateCluster: &PrivateCluster{
+							Enabled: to.BoolPtr(true),
+						},
+					},
+				},
+			},
+			expected: false,
+		},
+		{
+			p: Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType: Kubernetes,
+					KubernetesConfig: &KubernetesConfig{
+						PrivateCluster: &PrivateCluster{
+							Enabled:      to.BoolPtr(false),
+							FullyPrivate: to.BoolPtr(true),
+						},
+					},
+				},
+			},
+			expected: false,
+		},
+		{
+			p: Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType: Kubernetes,
+					KubernetesConfig: &KubernetesConfig{
+						PrivateCluster: &PrivateCluster{
+							Enabled:      to.BoolPtr(true),
+							FullyPrivate: to.BoolPtr(true),
+						},
+					},
+				},
+				MasterProfile: &MasterProfile{
+					DNSPrefix: "contoso",
+				},
+			},
+			expected:     true,
+			expectedFQDN: "contoso.privatecluster.internal",
+		},
+		{
+			p: Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType: Kubernetes,
+					KubernetesConfig: &KubernetesConfig{
+						PrivateCluster: &PrivateCluster{
+							Enabled:            to.BoolPtr(true),
+							FullyPrivate:       to.BoolPtr(true),
+							PrivateDNSZoneName: "k8s.contoso.internal",
+						},
+					},
+				},
+				MasterProfile: &MasterProfile{
+					DNSPrefix: "contoso",
+				},
+			},
+			expected:     true,
+			expectedFQDN: "k8s.contoso.internal",
+		},
+	}
+
+	for _, c := range cases {
+		if c.p.OrchestratorProfile.IsFullyPrivateCluster() != c.expected {
+			t.Fatalf("expected IsFullyPrivateCluster() to return %t but instead got %t", c.expected, c.p.OrchestratorProfile.IsFullyPrivateCluster())
+		}
+		if c.p.GetPrivateAPIServerFQDN() != c.expectedFQDN {
+			t.Fatalf("expected GetPrivateAPIServerFQDN() to return %s but instead got %s", c.expectedFQDN, c.p.GetPrivateAPIServerFQDN())
+		}
+	}
+}
+
 func TestOrchestratorProfileNeedsExecHealthz(t *testing.T) {
 	cases := []struct {
 		p        Properties
@@ -3024,6 +3234,36 @@ func TestHasAadProfile(t *testing.T) {
 
 }
 
+func TestGetPkiOptions(t *testing.T) {
+	p := Properties{}
+
+	if p.GetPkiOptions() != nil {
+		t.Fatalf("Expected GetPkiOptions() to return nil without a pkiProfile")
+	}
+
+	p.PKIProfile = &PKIProfile{
+		KeyAlgorithm:     "ECDSA",
+		KeySize:          384,
+		CAValidityDays:   3650,
+		LeafValidityDays: 365,
+		ExtraSubjectAltNames: map[string][]string{
+			"apiserver": {"api.mydomain.com"},
+		},
+	}
+	expected := &helpers.PkiOptions{
+		KeyAlgorithm: "ECDSA",
+		KeySize:      384,
+		CAValidity:   3650 * 24 * time.Hour,
+		LeafValidity: 365 * 24 * time.Hour,
+		ExtraSubjectAltNames: map[string][]string{
+			"apiserver": {"api.mydomain.com"},
+		},
+	}
+	if options := p.GetPkiOptions(); !reflect.DeepEqual(options, expected) {
+		t.Fatalf("Expected GetPkiOptions() to return %v, got %v", expected, options)
+	}
+}
+
 func TestCustomHyperkubeImageField(t *testing.T) {
 	apiloader := &Apiloader{
 		Translator: nil,
@@ -3471,6 +3711,25 @@ func TestGetAzureCNIURLFuncs(t *testing.T) {
 	}
 }
 
+func TestGetWindowsContainerdURL(t *testing.T) {
+	cs := CreateMockContainerService("testcluster", defaultTestClusterVer, 1, 3, false)
+	cs.Location = "eastus"
+	cloudSpecConfig := cs.GetCloudSpecConfig()
+
+	k := &KubernetesConfig{}
+	if url := k.GetWindowsContainerdURL(cloudSpecConfig); url != cloudSpecConfig.KubernetesSpecConfig.WindowsContainerdDownloadURL {
+		t.Fatalf("GetWindowsContainerdURL() should return default %s, instead returned %s", cloudSpecConfig.KubernetesSpecConfig.WindowsContainerdDownloadURL, url)
+	}
+
+	customURL := "https://custom-url/containerd-windows-amd64.tar.gz"
+	k = &KubernetesConfig{
+		WindowsContainerdURL: customURL,
+	}
+	if url := k.GetWindowsContainerdURL(cloudSpecConfig); url != customURL {
+		t.Fatalf("GetWindowsContainerdURL() should return custom URL %s, instead returned %s", customURL, url)
+	}
+}
+
 func TestCloudProviderDefaults(t *testing.T) {
 	// Test cloudprovider defaults when no user-provided values
 	v := "1.8.0"
@@ -4154,6 +4413,29 @@ func TestGetRouteTableName(t *testing.T) {
 	if actualNSGName != expectedNSGName {
 		t.Errorf("expected route table name %s, but got %s", actualNSGName, expectedNSGName)
 	}
+
+	if p.GetRouteTableResourceGroupName() != "" || p.GetNSGResourceGroupName() != "" {
+		t.Errorf("expected empty resource group names for cluster-owned route table and nsg")
+	}
+
+	p.MasterProfile.RouteTableID = "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable"
+	p.MasterProfile.NetworkSecurityGroupID = "/subscriptions/SUB_ID/resourceGroups/SECURITY_RG/providers/Microsoft.Network/networkSecurityGroups/hub-nsg"
+
+	if actual := p.GetRouteTableName(); actual != "hub-routetable" {
+		t.Errorf("expected route table name %s, but got %s", "hub-routetable", actual)
+	}
+
+	if actual := p.GetRouteTableResourceGroupName(); actual != "HUB_RG" {
+		t.Errorf("expected route table resource group name %s, but got %s", "HUB_RG", actual)
+	}
+
+	if actual := p.GetNSGName(); actual != "hub-nsg" {
+		t.Errorf("expected nsg name %s, but got %s", "hub-nsg", actual)
+	}
+
+	if actual := p.GetNSGResourceGroupName(); actual != "SECURITY_RG" {
+		t.Errorf("expected nsg resource group name %s, but got %s", "SECURITY_RG", actual)
+	}
 }
 
 func TestGetSubnetName(t *testing.T) {
@@ -5789,3 +6071,32 @@ func TestAnyAgentIsLinux(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCaCertificateBundle(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  CertificateProfile
+		expected string
+	}{
+		{
+			name:     "no chain",
+			profile:  CertificateProfile{CaCertificate: "ca\n"},
+			expected: "ca\n",
+		},
+		{
+			name:     "chain",
+			profile:  CertificateProfile{CaCertificate: "ca\n", CaCertificateChain: "root\n"},
+			expected: "ca\nroot\n",
+		},
+		{
+			name:     "CA without a trailing newline",
+			profile:  CertificateProfile{CaCertificate: "ca", CaCertificateChain: "root\n"},
+			expected: "ca\nroot\n",
+		},
+	}
+	for _, c := range cases {
+		if actual := c.profile.GetCaCertificateBundle(); actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, actual)
+		}
+	}
+}
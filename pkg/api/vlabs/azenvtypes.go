@@ -47,6 +47,7 @@ type KubernetesSpecConfig struct {
 	VnetCNILinuxPluginsDownloadURL   string `json:"vnetCNILinuxPluginsDownloadURL,omitempty"`
 	VnetCNIWindowsPluginsDownloadURL string `json:"vnetCNIWindowsPluginsDownloadURL,omitempty"`
 	ContainerdDownloadURLBase        string `json:"containerdDownloadURLBase,omitempty"`
+	WindowsContainerdDownloadURL     string `json:"windowsContainerdDownloadURL,omitempty"`
 }
 
 //AzureEndpointConfig describes an Azure endpoint
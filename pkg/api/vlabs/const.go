@@ -87,6 +87,12 @@ const (
 	Containerd     = "containerd"
 )
 
+// Windows container isolation modes
+const (
+	WindowsIsolationProcess = "process"
+	WindowsIsolationHyperV  = "hyperv"
+)
+
 var (
 	// NetworkPluginValues holds the valid values for network plugin implementation
 	NetworkPluginValues = [...]string{"", "kubenet", "azure", NetworkPluginCilium, "flannel"}
@@ -98,6 +104,9 @@ var (
 	// ContainerRuntimeValues holds the valid values for container runtimes
 	ContainerRuntimeValues = [...]string{"", Docker, KataContainers, Containerd}
 
+	// WindowsIsolationValues holds the valid values for the isolation of the containers of Windows agent pools
+	WindowsIsolationValues = [...]string{"", WindowsIsolationProcess, WindowsIsolationHyperV}
+
 	// DistroValues holds the valid values for OS distros
 	DistroValues = []Distro{"", Ubuntu, Ubuntu1804, RHEL, CoreOS, AKSUbuntu1604, AKSUbuntu1804, ACC1604}
 
@@ -166,3 +175,42 @@ const (
 
 // StandardLoadBalancerSku is the string const for Azure Standard Load Balancer
 const StandardLoadBalancerSku = "Standard"
+
+const (
+	// OutboundTypeLoadBalancer routes outbound traffic through the cluster load balancers
+	OutboundTypeLoadBalancer = "loadBalancer"
+	// OutboundTypeUserDefinedRouting routes outbound traffic through the user-defined routes of an existing route table
+	OutboundTypeUserDefinedRouting = "userDefinedRouting"
+	// OutboundTypeNATGateway routes outbound traffic through a NAT gateway associated with the cluster subnets
+	OutboundTypeNATGateway = "natGateway"
+)
+
+// OutboundTypeValues holds the valid values for KubernetesConfig.OutboundType
+var OutboundTypeValues = [...]string{OutboundTypeLoadBalancer, OutboundTypeUserDefinedRouting, OutboundTypeNATGateway}
+
+const (
+	// MinSecurityRulePriority is the lowest priority value allowed for a network security group rule
+	MinSecurityRulePriority = 100
+	// MaxSecurityRulePriority is the highest priority value allowed for a network security group rule
+	MaxSecurityRulePriority = 4096
+)
+
+var (
+	// ReservedSecurityRuleNames are the names of the rules aks-engine generates in the cluster network security group
+	ReservedSecurityRuleNames = [...]string{"allow_ssh", "allow_kube_tls", "allow_rdp", "allow_vnet", "block_outbound", "allow_vnet_inbound", "allow_vnet_outbound"}
+	// ReservedSecurityRulePriorities are the priorities of the rules aks-engine generates in the cluster network security group
+	ReservedSecurityRulePriorities = [...]int32{100, 101, 102, 110, 120, 4095}
+	// SecurityRuleProtocolValues holds the valid values for a custom security rule protocol
+	SecurityRuleProtocolValues = [...]string{"Tcp", "Udp", "*"}
+	// SecurityRuleAccessValues holds the valid values for a custom security rule access
+	SecurityRuleAccessValues = [...]string{"Allow", "Deny"}
+	// SecurityRuleDirectionValues holds the valid values for a custom security rule direction
+	SecurityRuleDirectionValues = [...]string{"Inbound", "Outbound"}
+)
+
+var (
+	// PKIRSAKeySizeValues holds the valid pkiProfile key sizes of RSA keys
+	PKIRSAKeySizeValues = [...]int{2048, 3072, 4096}
+	// PKIECDSAKeySizeValues holds the valid pkiProfile key sizes of ECDSA keys, the sizes of the P-256 and P-384 curves
+	PKIECDSAKeySizeValues = [...]int{256, 384}
+)
@@ -38,18 +38,20 @@ type ContainerService struct {
 
 // Properties represents the AKS cluster definition
 type Properties struct {
-	ProvisioningState       ProvisioningState        `json:"provisioningState,omitempty"`
-	OrchestratorProfile     *OrchestratorProfile     `json:"orchestratorProfile,omitempty" validate:"required"`
-	MasterProfile           *MasterProfile           `json:"masterProfile,omitempty" validate:"required"`
-	AgentPoolProfiles       []*AgentPoolProfile      `json:"agentPoolProfiles,omitempty" validate:"dive,required"`
-	LinuxProfile            *LinuxProfile            `json:"linuxProfile,omitempty" validate:"required"`
-	ExtensionProfiles       []*ExtensionProfile      `json:"extensionProfiles,omitempty"`
-	WindowsProfile          *WindowsProfile          `json:"windowsProfile,omitempty"`
-	ServicePrincipalProfile *ServicePrincipalProfile `json:"servicePrincipalProfile,omitempty"`
-	CertificateProfile      *CertificateProfile      `json:"certificateProfile,omitempty"`
-	AADProfile              *AADProfile              `json:"aadProfile,omitempty"`
-	FeatureFlags            *FeatureFlags            `json:"featureFlags,omitempty"`
-	CustomCloudProfile      *CustomCloudProfile      `json:"customCloudProfile,omitempty"`
+	ProvisioningState           ProvisioningState            `json:"provisioningState,omitempty"`
+	OrchestratorProfile         *OrchestratorProfile         `json:"orchestratorProfile,omitempty" validate:"required"`
+	MasterProfile               *MasterProfile               `json:"masterProfile,omitempty" validate:"required"`
+	AgentPoolProfiles           []*AgentPoolProfile          `json:"agentPoolProfiles,omitempty" validate:"dive,required"`
+	LinuxProfile                *LinuxProfile                `json:"linuxProfile,omitempty" validate:"required"`
+	ExtensionProfiles           []*ExtensionProfile          `json:"extensionProfiles,omitempty"`
+	WindowsProfile              *WindowsProfile              `json:"windowsProfile,omitempty"`
+	ServicePrincipalProfile     *ServicePrincipalProfile     `json:"servicePrincipalProfile,omitempty"`
+	CertificateProfile          *CertificateProfile          `json:"certificateProfile,omitempty"`
+	PKIProfile                  *PKIProfile                  `json:"pkiProfile,omitempty"`
+	AADProfile                  *AADProfile                  `json:"aadProfile,omitempty"`
+	FeatureFlags                *FeatureFlags                `json:"featureFlags,omitempty"`
+	CustomCloudProfile          *CustomCloudProfile          `json:"customCloudProfile,omitempty"`
+	NetworkSecurityGroupProfile *NetworkSecurityGroupProfile `json:"networkSecurityGroupProfile,omitempty"`
 }
 
 // FeatureFlags defines feature-flag restricted functionality
@@ -100,6 +102,9 @@ type CertificateProfile struct {
 	CaCertificate string `json:"caCertificate,omitempty"`
 	// CaPrivateKey is the certificate authority key.
 	CaPrivateKey string `json:"caPrivateKey,omitempty"`
+	// CaCertificateChain holds the PEM encoded certificates that chain an intermediate CaCertificate to a root CA,
+	// the issuer of CaCertificate first
+	CaCertificateChain string `json:"caCertificateChain,omitempty"`
 	// ApiServerCertificate is the rest api server certificate, and signed by the CA
 	APIServerCertificate string `json:"apiServerCertificate,omitempty"`
 	// ApiServerPrivateKey is the rest api server private key, and signed by the CA
@@ -126,6 +131,30 @@ type CertificateProfile struct {
 	EtcdPeerPrivateKeys []string `json:"etcdPeerPrivateKeys,omitempty"`
 }
 
+// PKIProfile configures the keys and certificates generated for the cluster
+type PKIProfile struct {
+	// KeyAlgorithm is the algorithm of the generated private keys, either RSA or ECDSA.
+	// If not specified, RSA.
+	// Optional
+	KeyAlgorithm string `json:"keyAlgorithm,omitempty"`
+	// KeySize is the size in bits of RSA keys, 2048, 3072 or 4096, or of the curve of ECDSA keys, 256 or 384.
+	// If not specified, 4096 for RSA and 256 for ECDSA.
+	// Optional
+	KeySize int `json:"keySize,omitempty"`
+	// CAValidityDays is how many days a generated CA certificate is valid.
+	// If not specified, 30 years.
+	// Optional
+	CAValidityDays int `json:"caValidityDays,omitempty"`
+	// LeafValidityDays is how many days the certificates signed by the CA are valid.
+	// If not specified, 30 years.
+	// Optional
+	LeafValidityDays int `json:"leafValidityDays,omitempty"`
+	// ExtraSubjectAltNames are DNS names and IP addresses added to the certificates of a component,
+	// keyed by apiserver, etcdserver, etcdclient or etcdpeer
+	// Optional
+	ExtraSubjectAltNames map[string][]string `json:"extraSubjectAltNames,omitempty"`
+}
+
 // LinuxProfile represents the linux parameters passed to the cluster
 type LinuxProfile struct {
 	AdminUsername string `json:"adminUsername" validate:"required"`
@@ -157,17 +186,29 @@ type CustomNodesDNS struct {
 
 // WindowsProfile represents the windows parameters passed to the cluster
 type WindowsProfile struct {
-	AdminUsername          string            `json:"adminUsername,omitempty"`
-	AdminPassword          string            `json:"adminPassword,omitempty"`
-	ImageVersion           string            `json:"imageVersion,omitempty"`
-	WindowsImageSourceURL  string            `json:"WindowsImageSourceUrl"`
-	WindowsPublisher       string            `json:"WindowsPublisher"`
-	WindowsOffer           string            `json:"WindowsOffer"`
-	WindowsSku             string            `json:"WindowsSku"`
-	WindowsDockerVersion   string            `json:"windowsDockerVersion"`
-	Secrets                []KeyVaultSecrets `json:"secrets,omitempty"`
-	SSHEnabled             bool              `json:"sshEnabled,omitempty"`
-	EnableAutomaticUpdates *bool             `json:"enableAutomaticUpdates,omitempty"`
+	AdminUsername          string             `json:"adminUsername,omitempty"`
+	AdminPassword          string             `json:"adminPassword,omitempty"`
+	ImageVersion           string             `json:"imageVersion,omitempty"`
+	WindowsImageSourceURL  string             `json:"WindowsImageSourceUrl"`
+	WindowsPublisher       string             `json:"WindowsPublisher"`
+	WindowsOffer           string             `json:"WindowsOffer"`
+	WindowsSku             string             `json:"WindowsSku"`
+	WindowsDockerVersion   string             `json:"windowsDockerVersion"`
+	Secrets                []KeyVaultSecrets  `json:"secrets,omitempty"`
+	SSHEnabled             bool               `json:"sshEnabled,omitempty"`
+	EnableAutomaticUpdates *bool              `json:"enableAutomaticUpdates,omitempty"`
+	ImageRef               *ImageReference    `json:"imageReference,omitempty"`
+	PrePullImages          []string           `json:"prePullImages,omitempty"`
+	DomainJoin             *WindowsDomainJoin `json:"domainJoin,omitempty"`
+}
+
+// WindowsDomainJoin represents the Active Directory domain the Windows nodes join, for the containers to run as
+// group Managed Service Accounts. The password of the join account is only read from Key Vault at deployment time.
+type WindowsDomainJoin struct {
+	Domain             string             `json:"domain"`
+	OrganizationalUnit string             `json:"organizationalUnit,omitempty"`
+	UserName           string             `json:"userName"`
+	KeyvaultSecretRef  *KeyvaultSecretRef `json:"keyvaultSecretRef"`
 }
 
 // ProvisioningState represents the current state of container service resource.
@@ -246,8 +287,10 @@ type KubernetesAddon struct {
 
 // PrivateCluster defines the configuration for a private cluster
 type PrivateCluster struct {
-	Enabled        *bool                  `json:"enabled,omitempty"`
-	JumpboxProfile *PrivateJumpboxProfile `json:"jumpboxProfile,omitempty"`
+	Enabled            *bool                  `json:"enabled,omitempty"`
+	FullyPrivate       *bool                  `json:"fullyPrivate,omitempty"`
+	PrivateDNSZoneName string                 `json:"privateDNSZoneName,omitempty"`
+	JumpboxProfile     *PrivateJumpboxProfile `json:"jumpboxProfile,omitempty"`
 }
 
 // PrivateJumpboxProfile represents a jumpbox definition
@@ -292,6 +335,7 @@ type KubernetesConfig struct {
 	UseCloudControllerManager        *bool             `json:"useCloudControllerManager,omitempty"`
 	CustomWindowsPackageURL          string            `json:"customWindowsPackageURL,omitempty"`
 	WindowsNodeBinariesURL           string            `json:"windowsNodeBinariesURL,omitempty"`
+	WindowsContainerdURL             string            `json:"windowsContainerdURL,omitempty"`
 	UseInstanceMetadata              *bool             `json:"useInstanceMetadata,omitempty"`
 	EnableRbac                       *bool             `json:"enableRbac,omitempty"`
 	EnableSecureKubelet              *bool             `json:"enableSecureKubelet,omitempty"`
@@ -330,6 +374,7 @@ type KubernetesConfig struct {
 	ProxyMode                        KubeProxyMode     `json:"kubeProxyMode,omitempty"`
 	PrivateAzureRegistryServer       string            `json:"privateAzureRegistryServer,omitempty"`
 	OutboundRuleIdleTimeoutInMinutes int32             `json:"outboundRuleIdleTimeoutInMinutes,omitempty"`
+	OutboundType                     string            `json:"outboundType,omitempty"`
 }
 
 // CustomFile has source as the full absolute source path to a file and dest
@@ -371,6 +416,8 @@ type MasterProfile struct {
 	VnetSubnetID             string            `json:"vnetSubnetID,omitempty"`
 	VnetCidr                 string            `json:"vnetCidr,omitempty"`
 	AgentVnetSubnetID        string            `json:"agentVnetSubnetID,omitempty"`
+	RouteTableID             string            `json:"routeTableID,omitempty"`
+	NetworkSecurityGroupID   string            `json:"networkSecurityGroupID,omitempty"`
 	FirstConsecutiveStaticIP string            `json:"firstConsecutiveStaticIP,omitempty"`
 	IPAddressCount           int               `json:"ipAddressCount,omitempty" validate:"min=0,max=256"`
 	StorageProfile           string            `json:"storageProfile,omitempty" validate:"eq=StorageAccount|eq=ManagedDisks|len=0"`
@@ -456,6 +503,7 @@ type AgentPoolProfile struct {
 	VMSSOverProvisioningEnabled         *bool                `json:"vmssOverProvisioningEnabled,omitempty"`
 	AuditDEnabled                       *bool                `json:"auditDEnabled,omitempty"`
 	CustomVMTags                        map[string]string    `json:"customVMTags,omitempty"`
+	WindowsIsolation                    string               `json:"windowsIsolation,omitempty"`
 
 	// subnet is internal
 	subnet string
@@ -531,6 +579,33 @@ type CustomCloudProfile struct {
 	PortalURL                  string                      `json:"portalURL,omitempty"`
 }
 
+// NetworkSecurityGroupProfile customizes the network security group generated for the cluster
+type NetworkSecurityGroupProfile struct {
+	// SSHSourceAddressPrefixes restricts the source address ranges allowed by the SSH rules.
+	// If not specified, SSH is allowed from any source.
+	// Optional
+	SSHSourceAddressPrefixes []string `json:"sshSourceAddressPrefixes,omitempty"`
+	// SecurityRules are custom rules merged into the generated network security group
+	// Optional
+	SecurityRules []SecurityRule `json:"securityRules,omitempty"`
+}
+
+// SecurityRule represents a custom network security group rule
+type SecurityRule struct {
+	Name                       string   `json:"name"`
+	Description                string   `json:"description,omitempty"`
+	Protocol                   string   `json:"protocol"`
+	SourcePortRange            string   `json:"sourcePortRange,omitempty"`
+	DestinationPortRange       string   `json:"destinationPortRange,omitempty"`
+	SourceAddressPrefix        string   `json:"sourceAddressPrefix,omitempty"`
+	SourceAddressPrefixes      []string `json:"sourceAddressPrefixes,omitempty"`
+	DestinationAddressPrefix   string   `json:"destinationAddressPrefix,omitempty"`
+	DestinationAddressPrefixes []string `json:"destinationAddressPrefixes,omitempty"`
+	Access                     string   `json:"access"`
+	Priority                   int32    `json:"priority"`
+	Direction                  string   `json:"direction"`
+}
+
 // HasCoreOS returns true if the cluster contains coreos nodes
 func (p *Properties) HasCoreOS() bool {
 	for _, agentPoolProfile := range p.AgentPoolProfiles {
@@ -575,6 +650,16 @@ func (m *MasterProfile) IsCustomVNET() bool {
 	return len(m.VnetSubnetID) > 0
 }
 
+// IsCustomRouteTable returns true if the customer brought their own route table
+func (m *MasterProfile) IsCustomRouteTable() bool {
+	return len(m.RouteTableID) > 0
+}
+
+// IsCustomNSG returns true if the customer brought their own network security group
+func (m *MasterProfile) IsCustomNSG() bool {
+	return len(m.NetworkSecurityGroupID) > 0
+}
+
 // GetSubnet returns the read-only subnet for the master
 func (m *MasterProfile) GetSubnet() string {
 	return m.subnet
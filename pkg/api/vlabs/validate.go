@@ -17,6 +17,7 @@ import (
 
 	"github.com/Azure/aks-engine/pkg/api/common"
 	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/secrets"
 	"github.com/blang/semver"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
@@ -25,10 +26,15 @@ import (
 )
 
 var (
-	validate        *validator.Validate
-	keyvaultIDRegex *regexp.Regexp
-	labelValueRegex *regexp.Regexp
-	labelKeyRegex   *regexp.Regexp
+	validate          *validator.Validate
+	keyvaultIDRegex   *regexp.Regexp
+	routeTableIDRegex *regexp.Regexp
+	nsgIDRegex        *regexp.Regexp
+	dnsZoneNameRegex  *regexp.Regexp
+	dnsNameRegex      *regexp.Regexp
+	labelValueRegex   *regexp.Regexp
+	labelKeyRegex     *regexp.Regexp
+	imageNameRegex    *regexp.Regexp
 	// Any version has to be mirrored in https://acs-mirror.azureedge.net/github-coreos/etcd-v[Version]-linux-amd64.tar.gz
 	etcdValidVersions = [...]string{"2.2.5", "2.3.0", "2.3.1", "2.3.2", "2.3.3", "2.3.4", "2.3.5", "2.3.6", "2.3.7", "2.3.8",
 		"3.0.0", "3.0.1", "3.0.2", "3.0.3", "3.0.4", "3.0.5", "3.0.6", "3.0.7", "3.0.8", "3.0.9", "3.0.10", "3.0.11", "3.0.12", "3.0.13", "3.0.14", "3.0.15", "3.0.16", "3.0.17",
@@ -106,8 +112,13 @@ type k8sNetworkConfig struct {
 func init() {
 	validate = validator.New()
 	keyvaultIDRegex = regexp.MustCompile(`^/subscriptions/\S+/resourceGroups/\S+/providers/Microsoft.KeyVault/vaults/[^/\s]+$`)
+	routeTableIDRegex = regexp.MustCompile(`^/subscriptions/[^/\s]+/resourceGroups/[^/\s]+/providers/Microsoft.Network/routeTables/[^/\s]+$`)
+	nsgIDRegex = regexp.MustCompile(`^/subscriptions/[^/\s]+/resourceGroups/[^/\s]+/providers/Microsoft.Network/networkSecurityGroups/[^/\s]+$`)
+	dnsZoneNameRegex = regexp.MustCompile(`^(?i)([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	dnsNameRegex = regexp.MustCompile(`^(?i)([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
 	labelValueRegex = regexp.MustCompile(labelValueFormat)
 	labelKeyRegex = regexp.MustCompile(labelKeyFormat)
+	imageNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@-]*$`)
 }
 
 // Validate implements APIObject
@@ -150,6 +161,26 @@ func (a *Properties) validate(isUpdate bool) error {
 	if e := a.validateAADProfile(); e != nil {
 		return e
 	}
+
+	if e := a.validateNetworkSecurityGroupProfile(); e != nil {
+		return e
+	}
+
+	if e := a.validateCertificateProfile(); e != nil {
+		return e
+	}
+
+	if e := a.validatePKIProfile(); e != nil {
+		return e
+	}
+
+	if e := a.validateOutboundType(); e != nil {
+		return e
+	}
+
+	if e := a.validatePrivateCluster(); e != nil {
+		return e
+	}
 	return nil
 }
 
@@ -240,7 +271,7 @@ func (a *Properties) ValidateOrchestratorProfile(isUpdate bool) error {
 						return errors.Errorf("enableDataEncryptionAtRest is only available in Kubernetes version %s or greater; unable to validate for Kubernetes version %s",
 							minVersion.String(), o.OrchestratorVersion)
 					}
-					if o.KubernetesConfig.EtcdEncryptionKey != "" {
+					if o.KubernetesConfig.EtcdEncryptionKey != "" && !secrets.IsReference(o.KubernetesConfig.EtcdEncryptionKey) {
 						_, err = base64.StdEncoding.DecodeString(o.KubernetesConfig.EtcdEncryptionKey)
 						if err != nil {
 							return errors.New("etcdEncryptionKey must be base64 encoded. Please provide a valid base64 encoded value or leave the etcdEncryptionKey empty to auto-generate the value")
@@ -353,7 +384,7 @@ func (a *Properties) ValidateOrchestratorProfile(isUpdate bool) error {
 		return errors.Errorf("DcosConfig can be specified only when OrchestratorType is DCOS")
 	}
 
-	return a.validateContainerRuntime()
+	return a.validateContainerRuntime(isUpdate)
 }
 
 func (a *Properties) validateMasterProfile(isUpdate bool) error {
@@ -479,7 +510,9 @@ func (a *Properties) validateAgentPoolProfiles(isUpdate bool) error {
 		}
 
 		if agentPoolProfile.ImageRef != nil {
-			return agentPoolProfile.ImageRef.validateImageNameAndGroup()
+			if e := agentPoolProfile.ImageRef.validateImageNameAndGroup(); e != nil {
+				return e
+			}
 		}
 
 		if e := agentPoolProfile.validateAvailabilityProfile(); e != nil {
@@ -534,6 +567,10 @@ func (a *Properties) validateAgentPoolProfiles(isUpdate bool) error {
 			return e
 		}
 
+		if e := agentPoolProfile.validateWindowsIsolation(a.OrchestratorProfile); e != nil {
+			return e
+		}
+
 		if e := agentPoolProfile.validateLoadBalancerBackendAddressPoolIDs(); e != nil {
 			return e
 		}
@@ -737,6 +774,26 @@ func (a *Properties) validateVNET() error {
 			}
 		}
 	}
+
+	if a.MasterProfile.IsCustomRouteTable() || a.MasterProfile.IsCustomNSG() {
+		if a.OrchestratorProfile.OrchestratorType != Kubernetes {
+			return errors.Errorf("MasterProfile.RouteTableID and MasterProfile.NetworkSecurityGroupID are only supported by orchestrator '%v'", Kubernetes)
+		}
+		if !isCustomVNET {
+			return errors.New("MasterProfile.RouteTableID and MasterProfile.NetworkSecurityGroupID can only be specified with a custom VNET Subnet (MasterProfile.VnetSubnetID)")
+		}
+	}
+	if a.MasterProfile.IsCustomRouteTable() && !routeTableIDRegex.MatchString(a.MasterProfile.RouteTableID) {
+		return errors.Errorf("MasterProfile.RouteTableID '%s' is not a valid route table ID; expected format: /subscriptions/<SUB_ID>/resourceGroups/<RG_NAME>/providers/Microsoft.Network/routeTables/<ROUTE_TABLE_NAME>", a.MasterProfile.RouteTableID)
+	}
+	if a.MasterProfile.IsCustomNSG() {
+		if !nsgIDRegex.MatchString(a.MasterProfile.NetworkSecurityGroupID) {
+			return errors.Errorf("MasterProfile.NetworkSecurityGroupID '%s' is not a valid network security group ID; expected format: /subscriptions/<SUB_ID>/resourceGroups/<RG_NAME>/providers/Microsoft.Network/networkSecurityGroups/<NSG_NAME>", a.MasterProfile.NetworkSecurityGroupID)
+		}
+		if a.NetworkSecurityGroupProfile != nil && (len(a.NetworkSecurityGroupProfile.SecurityRules) > 0 || len(a.NetworkSecurityGroupProfile.SSHSourceAddressPrefixes) > 0) {
+			return errors.New("networkSecurityGroupProfile rules can't be applied to an existing network security group (MasterProfile.NetworkSecurityGroupID); manage its rules outside of aks-engine")
+		}
+	}
 	return nil
 }
 
@@ -839,6 +896,246 @@ func (a *Properties) validateAADProfile() error {
 	return nil
 }
 
+func (a *Properties) validateOutboundType() error {
+	if a.OrchestratorProfile == nil || a.OrchestratorProfile.KubernetesConfig == nil || a.OrchestratorProfile.KubernetesConfig.OutboundType == "" {
+		return nil
+	}
+	k := a.OrchestratorProfile.KubernetesConfig
+	if !isValidValue(k.OutboundType, OutboundTypeValues[:]) {
+		return errors.Errorf("outboundType '%s' is invalid, valid values are %v", k.OutboundType, OutboundTypeValues)
+	}
+	switch k.OutboundType {
+	case OutboundTypeUserDefinedRouting:
+		if a.MasterProfile == nil || !a.MasterProfile.IsCustomRouteTable() {
+			return errors.Errorf("outboundType '%s' requires an existing route table (masterProfile.routeTableID) with a user-defined route for outbound traffic", k.OutboundType)
+		}
+	case OutboundTypeNATGateway:
+		if k.LoadBalancerSku != StandardLoadBalancerSku {
+			return errors.Errorf("outboundType '%s' requires loadBalancerSku '%s'", k.OutboundType, StandardLoadBalancerSku)
+		}
+		if a.MasterProfile != nil && a.MasterProfile.IsCustomVNET() {
+			return errors.Errorf("outboundType '%s' is only supported when aks-engine creates the VNET; associate a NAT gateway with the subnets of a custom VNET outside of aks-engine", k.OutboundType)
+		}
+		if a.FeatureFlags.IsIPv6DualStackEnabled() {
+			return errors.Errorf("outboundType '%s' is not supported with the IPv6DualStack feature", k.OutboundType)
+		}
+	}
+	return nil
+}
+
+func (a *Properties) validatePrivateCluster() error {
+	if a.OrchestratorProfile == nil || a.OrchestratorProfile.KubernetesConfig == nil || a.OrchestratorProfile.KubernetesConfig.PrivateCluster == nil {
+		return nil
+	}
+	privateCluster := a.OrchestratorProfile.KubernetesConfig.PrivateCluster
+	if !to.Bool(privateCluster.FullyPrivate) {
+		if privateCluster.PrivateDNSZoneName != "" {
+			return errors.New("privateCluster.privateDNSZoneName can only be specified when privateCluster.fullyPrivate is true")
+		}
+		return nil
+	}
+	if !to.Bool(privateCluster.Enabled) {
+		return errors.New("privateCluster.fullyPrivate requires privateCluster.enabled to be true")
+	}
+	if a.MasterProfile == nil || !a.MasterProfile.IsCustomVNET() {
+		return errors.New("privateCluster.fullyPrivate requires a custom VNET Subnet (masterProfile.vnetSubnetID)")
+	}
+	if !a.MasterProfile.IsCustomRouteTable() {
+		return errors.New("privateCluster.fullyPrivate requires an existing route table (masterProfile.routeTableID) with a user-defined route for outbound traffic")
+	}
+	if outboundType := a.OrchestratorProfile.KubernetesConfig.OutboundType; outboundType != "" && outboundType != OutboundTypeUserDefinedRouting {
+		return errors.Errorf("privateCluster.fullyPrivate requires outboundType '%s'", OutboundTypeUserDefinedRouting)
+	}
+	if a.FeatureFlags.IsIPv6DualStackEnabled() {
+		return errors.New("privateCluster.fullyPrivate is not supported with the IPv6DualStack feature, which requires public IP addresses")
+	}
+	for _, addon := range a.OrchestratorProfile.KubernetesConfig.Addons {
+		if addon.Name == "appgw-ingress" && to.Bool(addon.Enabled) {
+			return errors.New("privateCluster.fullyPrivate is not supported with the appgw-ingress addon, which requires a public IP address")
+		}
+	}
+	for _, agentPool := range a.AgentPoolProfiles {
+		if to.Bool(agentPool.EnableVMSSNodePublicIP) {
+			return errors.Errorf("privateCluster.fullyPrivate is not supported with enableVMSSNodePublicIP on agent pool '%s'", agentPool.Name)
+		}
+	}
+	if privateCluster.PrivateDNSZoneName != "" && !dnsZoneNameRegex.MatchString(privateCluster.PrivateDNSZoneName) {
+		return errors.Errorf("privateCluster.privateDNSZoneName '%s' is not a valid DNS zone name", privateCluster.PrivateDNSZoneName)
+	}
+	return nil
+}
+
+func (a *Properties) validateNetworkSecurityGroupProfile() error {
+	profile := a.NetworkSecurityGroupProfile
+	if profile == nil {
+		return nil
+	}
+	if a.OrchestratorProfile.OrchestratorType != Kubernetes {
+		return errors.Errorf("'networkSecurityGroupProfile' is only supported by orchestrator '%v'", Kubernetes)
+	}
+	for _, prefix := range profile.SSHSourceAddressPrefixes {
+		if e := validateSecurityRuleAddressPrefix(prefix); e != nil {
+			return errors.Wrap(e, "networkSecurityGroupProfile.sshSourceAddressPrefixes")
+		}
+	}
+
+	names := make(map[string]bool)
+	priorities := make(map[string]string)
+	for _, rule := range profile.SecurityRules {
+		if rule.Name == "" {
+			return errors.New("networkSecurityGroupProfile.securityRules must have a name")
+		}
+		if names[rule.Name] {
+			return errors.Errorf("networkSecurityGroupProfile.securityRules name '%s' is used more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		for _, reserved := range ReservedSecurityRuleNames {
+			if rule.Name == reserved {
+				return errors.Errorf("security rule name '%s' is reserved for the rules generated by aks-engine", rule.Name)
+			}
+		}
+		if !isValidValue(rule.Protocol, SecurityRuleProtocolValues[:]) {
+			return errors.Errorf("security rule '%s' has an invalid protocol '%s', valid values are %v", rule.Name, rule.Protocol, SecurityRuleProtocolValues)
+		}
+		if !isValidValue(rule.Access, SecurityRuleAccessValues[:]) {
+			return errors.Errorf("security rule '%s' has an invalid access '%s', valid values are %v", rule.Name, rule.Access, SecurityRuleAccessValues)
+		}
+		if !isValidValue(rule.Direction, SecurityRuleDirectionValues[:]) {
+			return errors.Errorf("security rule '%s' has an invalid direction '%s', valid values are %v", rule.Name, rule.Direction, SecurityRuleDirectionValues)
+		}
+		if rule.Priority < MinSecurityRulePriority || rule.Priority > MaxSecurityRulePriority {
+			return errors.Errorf("security rule '%s' priority %d must be between %d and %d", rule.Name, rule.Priority, MinSecurityRulePriority, MaxSecurityRulePriority)
+		}
+		for _, reserved := range ReservedSecurityRulePriorities {
+			if rule.Priority == reserved {
+				return errors.Errorf("security rule '%s' priority %d is reserved for the rules generated by aks-engine, reserved priorities are %v", rule.Name, rule.Priority, ReservedSecurityRulePriorities)
+			}
+		}
+		key := fmt.Sprintf("%s/%d", rule.Direction, rule.Priority)
+		if other, ok := priorities[key]; ok {
+			return errors.Errorf("security rules '%s' and '%s' have the same %s priority %d", other, rule.Name, strings.ToLower(rule.Direction), rule.Priority)
+		}
+		priorities[key] = rule.Name
+
+		if rule.SourceAddressPrefix != "" && len(rule.SourceAddressPrefixes) > 0 {
+			return errors.Errorf("security rule '%s' can only specify one of sourceAddressPrefix and sourceAddressPrefixes", rule.Name)
+		}
+		if rule.DestinationAddressPrefix != "" && len(rule.DestinationAddressPrefixes) > 0 {
+			return errors.Errorf("security rule '%s' can only specify one of destinationAddressPrefix and destinationAddressPrefixes", rule.Name)
+		}
+		prefixes := append(append([]string{}, rule.SourceAddressPrefixes...), rule.DestinationAddressPrefixes...)
+		for _, prefix := range []string{rule.SourceAddressPrefix, rule.DestinationAddressPrefix} {
+			if prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		for _, prefix := range prefixes {
+			if e := validateSecurityRuleAddressPrefix(prefix); e != nil {
+				return errors.Wrapf(e, "security rule '%s'", rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Properties) validateCertificateProfile() error {
+	profile := a.CertificateProfile
+	if profile == nil || profile.CaCertificateChain == "" {
+		return nil
+	}
+	// the chain is verified against the CA when the certificates are generated
+	if profile.CaCertificate == "" || profile.CaPrivateKey == "" {
+		return errors.New("certificateProfile.caCertificateChain requires certificateProfile.caCertificate and certificateProfile.caPrivateKey")
+	}
+	return nil
+}
+
+func (a *Properties) validatePKIProfile() error {
+	profile := a.PKIProfile
+	if profile == nil {
+		return nil
+	}
+	if a.OrchestratorProfile.OrchestratorType != Kubernetes {
+		return errors.Errorf("'pkiProfile' is only supported by orchestrator '%v'", Kubernetes)
+	}
+
+	var keySizes []int
+	switch profile.KeyAlgorithm {
+	case "", helpers.PkiKeyAlgorithmRSA:
+		keySizes = PKIRSAKeySizeValues[:]
+	case helpers.PkiKeyAlgorithmECDSA:
+		keySizes = PKIECDSAKeySizeValues[:]
+	default:
+		return errors.Errorf("pkiProfile.keyAlgorithm '%s' is invalid, valid values are %s and %s", profile.KeyAlgorithm, helpers.PkiKeyAlgorithmRSA, helpers.PkiKeyAlgorithmECDSA)
+	}
+	if profile.KeySize != 0 {
+		valid := false
+		for _, size := range keySizes {
+			valid = valid || profile.KeySize == size
+		}
+		if !valid {
+			return errors.Errorf("pkiProfile.keySize %d is invalid for the key algorithm, valid values are %v", profile.KeySize, keySizes)
+		}
+	}
+
+	if profile.CAValidityDays < 0 {
+		return errors.Errorf("pkiProfile.caValidityDays %d must not be negative", profile.CAValidityDays)
+	}
+	if profile.LeafValidityDays < 0 {
+		return errors.Errorf("pkiProfile.leafValidityDays %d must not be negative", profile.LeafValidityDays)
+	}
+	// certificates cannot outlive the CA that signed them
+	caValidity := time.Duration(profile.CAValidityDays) * 24 * time.Hour
+	if caValidity == 0 {
+		caValidity = helpers.ValidityDuration
+	}
+	leafValidity := time.Duration(profile.LeafValidityDays) * 24 * time.Hour
+	if leafValidity == 0 {
+		leafValidity = helpers.ValidityDuration
+	}
+	if leafValidity > caValidity {
+		return errors.Errorf("pkiProfile.leafValidityDays must not exceed the validity of the CA, %d days", int(caValidity.Hours()/24))
+	}
+
+	for component, names := range profile.ExtraSubjectAltNames {
+		if !isValidValue(component, helpers.PkiComponents[:]) {
+			return errors.Errorf("pkiProfile.extraSubjectAltNames component '%s' is invalid, valid values are %v", component, helpers.PkiComponents)
+		}
+		for _, name := range names {
+			if net.ParseIP(name) == nil && !dnsNameRegex.MatchString(name) {
+				return errors.Errorf("pkiProfile.extraSubjectAltNames of %s: '%s' is neither an IP address nor a DNS name", component, name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSecurityRuleAddressPrefix accepts a CIDR, an IP address, a service tag or '*'
+func validateSecurityRuleAddressPrefix(prefix string) error {
+	if prefix == "" {
+		return errors.New("address prefix must not be empty")
+	}
+	if strings.Contains(prefix, "/") {
+		if _, _, err := net.ParseCIDR(prefix); err != nil {
+			return errors.Errorf("address prefix '%s' is not a valid CIDR", prefix)
+		}
+		return nil
+	}
+	if strings.Trim(prefix, "0123456789.:") == "" && net.ParseIP(prefix) == nil {
+		return errors.Errorf("address prefix '%s' is not a valid IP address", prefix)
+	}
+	return nil
+}
+
+func isValidValue(value string, validValues []string) bool {
+	for _, v := range validValues {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *AgentPoolProfile) validateAvailabilityProfile() error {
 	switch a.AvailabilityProfile {
 	case AvailabilitySet:
@@ -971,6 +1268,10 @@ func (a *AgentPoolProfile) validateWindows(o *OrchestratorProfile, w *WindowsPro
 		if version == "" {
 			return errors.Errorf("Orchestrator %s version %s does not support Windows", o.OrchestratorType, o.OrchestratorVersion)
 		}
+		// gMSA is alpha behind the WindowsGMSA feature gate from 1.14 on
+		if w != nil && w.DomainJoin != nil && !common.IsKubernetesVersionGe(version, "1.14.0") {
+			return errors.Errorf("WindowsProfile.DomainJoin is only supported in Kubernetes version 1.14.0 or greater, got %s", version)
+		}
 	default:
 		return errors.Errorf("Orchestrator %s does not support Windows", o.OrchestratorType)
 	}
@@ -981,6 +1282,43 @@ func (a *AgentPoolProfile) validateWindows(o *OrchestratorProfile, w *WindowsPro
 	} else {
 		return errors.New("WindowsProfile is required when the cluster definition contains Windows agent pool(s)")
 	}
+	if a.ImageRef != nil {
+		if o.OrchestratorType != Kubernetes {
+			return errors.Errorf("imageReference of Windows agent pool %s is only supported if the Orchestrator Type is Kubernetes", a.Name)
+		}
+		if w.WindowsImageSourceURL != "" {
+			return errors.Errorf("Windows agent pool %s cannot specify an imageReference when WindowsProfile.WindowsImageSourceURL is provided", a.Name)
+		}
+		if e := a.ImageRef.validateImageGallery(); e != nil {
+			return errors.Wrapf(e, "invalid imageReference in Windows agent pool %s", a.Name)
+		}
+	}
+	return nil
+}
+
+func (a *AgentPoolProfile) validateWindowsIsolation(o *OrchestratorProfile) error {
+	if a.WindowsIsolation == "" {
+		return nil
+	}
+	valid := false
+	for _, isolation := range WindowsIsolationValues {
+		if a.WindowsIsolation == isolation {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.Errorf("unknown windowsIsolation %q specified in agent pool %s", a.WindowsIsolation, a.Name)
+	}
+	if a.OSType != Windows {
+		return errors.Errorf("windowsIsolation is only supported by Windows agent pools, but agent pool %s is not of os type %s", a.Name, Windows)
+	}
+	if a.WindowsIsolation == WindowsIsolationHyperV {
+		// the kubelet runs pods in Hyper-V utility VMs through the runtime handlers of containerd only
+		if o.OrchestratorType != Kubernetes || o.KubernetesConfig == nil || o.KubernetesConfig.ContainerRuntime != Containerd {
+			return errors.Errorf("windowsIsolation %q in agent pool %s requires the Kubernetes containerRuntime %q", a.WindowsIsolation, a.Name, Containerd)
+		}
+	}
 	return nil
 }
 
@@ -1071,18 +1409,75 @@ func (w *WindowsProfile) Validate(orchestratorType string) error {
 			return errors.New("Windows Custom Images are only supported if the Orchestrator Type is DCOS or Kubernetes")
 		}
 	}
+	if w.ImageRef != nil {
+		if orchestratorType != Kubernetes {
+			return errors.New("WindowsProfile.ImageRef is only supported if the Orchestrator Type is Kubernetes")
+		}
+		if w.WindowsImageSourceURL != "" {
+			return errors.New("WindowsProfile.ImageRef and WindowsProfile.WindowsImageSourceURL cannot both be provided")
+		}
+		if e := w.ImageRef.validateImageNameAndGroup(); e != nil {
+			return errors.Wrap(e, "invalid WindowsProfile.ImageRef")
+		}
+		if e := w.ImageRef.validateImageGallery(); e != nil {
+			return errors.Wrap(e, "invalid WindowsProfile.ImageRef")
+		}
+	}
+	if len(w.PrePullImages) > 0 {
+		if orchestratorType != Kubernetes {
+			return errors.New("WindowsProfile.PrePullImages are only supported if the Orchestrator Type is Kubernetes")
+		}
+		for _, image := range w.PrePullImages {
+			if !imageNameRegex.MatchString(image) {
+				return errors.Errorf("WindowsProfile.PrePullImages contains the invalid container image %q", image)
+			}
+		}
+	}
+	if w.DomainJoin != nil {
+		if orchestratorType != Kubernetes {
+			return errors.New("WindowsProfile.DomainJoin is only supported if the Orchestrator Type is Kubernetes")
+		}
+		if e := w.DomainJoin.Validate(); e != nil {
+			return e
+		}
+	}
 	if e := validate.Var(w.AdminUsername, "required"); e != nil {
 		return errors.New("WindowsProfile.AdminUsername is required, when agent pool specifies windows")
 	}
 	if e := validate.Var(w.AdminPassword, "required"); e != nil {
 		return errors.New("WindowsProfile.AdminPassword is required, when agent pool specifies windows")
 	}
-	if !validatePasswordComplexity(w.AdminUsername, w.AdminPassword) {
+	// a reference to a secret provider stands in for the password, which was validated when it was generated
+	if !secrets.IsReference(w.AdminPassword) && !validatePasswordComplexity(w.AdminUsername, w.AdminPassword) {
 		return errors.New("WindowsProfile.AdminPassword complexity not met. Windows password should contain 3 of the following categories - uppercase letters(A-Z), lowercase(a-z) letters, digits(0-9), special characters (~!@#$%^&*_-+=`|\\(){}[]:;<>,.?/')")
 	}
 	return validateKeyVaultSecrets(w.Secrets, true)
 }
 
+// Validate implements APIObject
+func (d *WindowsDomainJoin) Validate() error {
+	if !dnsZoneNameRegex.MatchString(d.Domain) {
+		return errors.Errorf("WindowsProfile.DomainJoin.Domain '%s' is not a valid DNS domain name", d.Domain)
+	}
+	if e := validate.Var(d.UserName, "required"); e != nil {
+		return errors.New("WindowsProfile.DomainJoin.UserName is required")
+	}
+	// both are written into a PowerShell string of the node setup script
+	if strings.ContainsAny(d.UserName, "\"`$") || strings.ContainsAny(d.OrganizationalUnit, "\"`$") {
+		return errors.New("WindowsProfile.DomainJoin.UserName and WindowsProfile.DomainJoin.OrganizationalUnit cannot contain the characters \", ` or $")
+	}
+	if d.KeyvaultSecretRef == nil {
+		return errors.New("WindowsProfile.DomainJoin.KeyvaultSecretRef is required, the domain join password is only read from Key Vault")
+	}
+	if e := validate.Var(d.KeyvaultSecretRef.SecretName, "required"); e != nil {
+		return errors.New("WindowsProfile.DomainJoin.KeyvaultSecretRef.SecretName is required")
+	}
+	if !keyvaultIDRegex.MatchString(d.KeyvaultSecretRef.VaultID) {
+		return errors.Errorf("WindowsProfile.DomainJoin.KeyvaultSecretRef.VaultID '%s' is of incorrect format", d.KeyvaultSecretRef.VaultID)
+	}
+	return nil
+}
+
 func validatePasswordComplexity(name string, password string) (out bool) {
 
 	if strings.EqualFold(name, password) {
@@ -1369,7 +1764,7 @@ func (k *KubernetesConfig) validateNetworkPluginPlusPolicy() error {
 	return errors.Errorf("networkPolicy '%s' is not supported with networkPlugin '%s'", config.networkPolicy, config.networkPlugin)
 }
 
-func (a *Properties) validateContainerRuntime() error {
+func (a *Properties) validateContainerRuntime(isUpdate bool) error {
 	var containerRuntime string
 
 	switch a.OrchestratorProfile.OrchestratorType {
@@ -1394,10 +1789,24 @@ func (a *Properties) validateContainerRuntime() error {
 	}
 
 	// Make sure we don't use unsupported container runtimes on windows.
-	if (containerRuntime == KataContainers || containerRuntime == Containerd) && a.HasWindows() {
+	if containerRuntime == KataContainers && a.HasWindows() {
 		return errors.Errorf("containerRuntime %q is not supporting windows agents", containerRuntime)
 	}
 
+	// containerd is only provisioned on Windows agents from Kubernetes 1.15 on.
+	if containerRuntime == Containerd && a.HasWindows() {
+		o := a.OrchestratorProfile
+		version := common.RationalizeReleaseAndVersion(
+			o.OrchestratorType,
+			o.OrchestratorRelease,
+			o.OrchestratorVersion,
+			isUpdate,
+			true)
+		if version == "" || !common.IsKubernetesVersionGe(version, "1.15.0") {
+			return errors.Errorf("containerRuntime %q on windows agents requires Kubernetes version 1.15.0 or greater", containerRuntime)
+		}
+	}
+
 	return nil
 }
 
@@ -1511,6 +1920,21 @@ func (i *ImageReference) validateImageNameAndGroup() error {
 	return nil
 }
 
+// validateImageGallery ensures a Shared Image Gallery image is specified by all of its subscription, gallery and
+// version, a partial specification would otherwise silently refer to a managed image
+func (i *ImageReference) validateImageGallery() error {
+	if i.SubscriptionID == "" && i.Gallery == "" && i.Version == "" {
+		return nil
+	}
+	if i.SubscriptionID == "" || i.Gallery == "" || i.Version == "" {
+		return errors.New("subscriptionId, gallery and version need to be specified together to use an image of a Shared Image Gallery")
+	}
+	if i.Name == "" {
+		return errors.New("name needs to be specified to use an image of a Shared Image Gallery")
+	}
+	return nil
+}
+
 func (cs *ContainerService) validateCustomCloudProfile() error {
 	a := cs.Properties
 	if a.CustomCloudProfile != nil {
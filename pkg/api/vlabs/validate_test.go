@@ -159,6 +159,18 @@ func Test_OrchestratorProfile_Validate(t *testing.T) {
 			},
 			expectedError: "etcdEncryptionKey must be base64 encoded. Please provide a valid base64 encoded value or leave the etcdEncryptionKey empty to auto-generate the value",
 		},
+		"should not error when KubernetesConfig has enableDataEncryptionAtRest enabled with an encryption key kept by a secret provider": {
+			properties: &Properties{
+				OrchestratorProfile: &OrchestratorProfile{
+					OrchestratorType:    "Kubernetes",
+					OrchestratorVersion: "1.10.13",
+					KubernetesConfig: &KubernetesConfig{
+						EnableDataEncryptionAtRest: &trueVal,
+						EtcdEncryptionKey:          "file:///secrets/cluster-etcdEncryptionKey",
+					},
+				},
+			},
+		},
 		"should error when KubernetesConfig has enableEncryptionWithExternalKms enabled with invalid version": {
 			properties: &Properties{
 				OrchestratorProfile: &OrchestratorProfile{
@@ -1258,7 +1270,7 @@ func Test_Properties_ValidateContainerRuntime(t *testing.T) {
 	for _, runtime := range ContainerRuntimeValues {
 		p.OrchestratorProfile.KubernetesConfig = &KubernetesConfig{}
 		p.OrchestratorProfile.KubernetesConfig.ContainerRuntime = runtime
-		if err := p.validateContainerRuntime(); err != nil {
+		if err := p.validateContainerRuntime(false); err != nil {
 			t.Errorf(
 				"should not error on containerRuntime=\"%s\"",
 				runtime,
@@ -1267,7 +1279,7 @@ func Test_Properties_ValidateContainerRuntime(t *testing.T) {
 	}
 
 	p.OrchestratorProfile.KubernetesConfig.ContainerRuntime = "not-existing"
-	if err := p.validateContainerRuntime(); err == nil {
+	if err := p.validateContainerRuntime(false); err == nil {
 		t.Errorf(
 			"should error on invalid containerRuntime",
 		)
@@ -1279,7 +1291,7 @@ func Test_Properties_ValidateContainerRuntime(t *testing.T) {
 			OSType: Windows,
 		},
 	}
-	if err := p.validateContainerRuntime(); err == nil {
+	if err := p.validateContainerRuntime(false); err == nil {
 		t.Errorf(
 			"should error on kata-containers for windows clusters",
 		)
@@ -1291,9 +1303,24 @@ func Test_Properties_ValidateContainerRuntime(t *testing.T) {
 			OSType: Windows,
 		},
 	}
-	if err := p.validateContainerRuntime(); err == nil {
+	if err := p.validateContainerRuntime(false); err == nil {
+		t.Errorf(
+			"should error on containerd for windows clusters of the default Kubernetes version",
+		)
+	}
+
+	p.OrchestratorProfile.OrchestratorRelease = "1.14"
+	if err := p.validateContainerRuntime(false); err == nil {
+		t.Errorf(
+			"should error on containerd for windows clusters of Kubernetes release 1.14",
+		)
+	}
+
+	p.OrchestratorProfile.OrchestratorRelease = "1.15"
+	if err := p.validateContainerRuntime(false); err != nil {
 		t.Errorf(
-			"should error on containerd for windows clusters",
+			"should not error on containerd for windows clusters of Kubernetes release 1.15, got %s",
+			err,
 		)
 	}
 }
@@ -2363,6 +2390,338 @@ func TestProperties_ValidateVNET(t *testing.T) {
 	}
 }
 
+func TestProperties_ValidateCustomRouteTableAndNSG(t *testing.T) {
+	validVNetSubnetID := "/subscriptions/SUB_ID/resourceGroups/RG_NAME/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME"
+	validRouteTableID := "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable"
+	validNSGID := "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/networkSecurityGroups/hub-nsg"
+
+	tests := []struct {
+		name                   string
+		vnetSubnetID           string
+		routeTableID           string
+		networkSecurityGroupID string
+		nsgProfile             *NetworkSecurityGroupProfile
+		expectedMsg            string
+	}{
+		{
+			name:                   "valid route table and network security group",
+			vnetSubnetID:           validVNetSubnetID,
+			routeTableID:           validRouteTableID,
+			networkSecurityGroupID: validNSGID,
+		},
+		{
+			name:         "route table without custom VNET",
+			routeTableID: validRouteTableID,
+			expectedMsg:  "MasterProfile.RouteTableID and MasterProfile.NetworkSecurityGroupID can only be specified with a custom VNET Subnet (MasterProfile.VnetSubnetID)",
+		},
+		{
+			name:                   "network security group without custom VNET",
+			networkSecurityGroupID: validNSGID,
+			expectedMsg:            "MasterProfile.RouteTableID and MasterProfile.NetworkSecurityGroupID can only be specified with a custom VNET Subnet (MasterProfile.VnetSubnetID)",
+		},
+		{
+			name:         "invalid route table ID",
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validNSGID,
+			expectedMsg:  "MasterProfile.RouteTableID '" + validNSGID + "' is not a valid route table ID; expected format: /subscriptions/<SUB_ID>/resourceGroups/<RG_NAME>/providers/Microsoft.Network/routeTables/<ROUTE_TABLE_NAME>",
+		},
+		{
+			name:                   "invalid network security group ID",
+			vnetSubnetID:           validVNetSubnetID,
+			networkSecurityGroupID: "hub-nsg",
+			expectedMsg:            "MasterProfile.NetworkSecurityGroupID 'hub-nsg' is not a valid network security group ID; expected format: /subscriptions/<SUB_ID>/resourceGroups/<RG_NAME>/providers/Microsoft.Network/networkSecurityGroups/<NSG_NAME>",
+		},
+		{
+			name:                   "network security group with custom rules",
+			vnetSubnetID:           validVNetSubnetID,
+			networkSecurityGroupID: validNSGID,
+			nsgProfile: &NetworkSecurityGroupProfile{
+				SSHSourceAddressPrefixes: []string{"10.100.0.0/16"},
+			},
+			expectedMsg: "networkSecurityGroupProfile rules can't be applied to an existing network security group (MasterProfile.NetworkSecurityGroupID); manage its rules outside of aks-engine",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(true)
+			cs.Properties.MasterProfile.VnetSubnetID = test.vnetSubnetID
+			cs.Properties.MasterProfile.FirstConsecutiveStaticIP = "10.0.0.4"
+			cs.Properties.MasterProfile.RouteTableID = test.routeTableID
+			cs.Properties.MasterProfile.NetworkSecurityGroupID = test.networkSecurityGroupID
+			for _, agentPool := range cs.Properties.AgentPoolProfiles {
+				agentPool.VnetSubnetID = test.vnetSubnetID
+			}
+			cs.Properties.NetworkSecurityGroupProfile = test.nsgProfile
+			err := cs.Properties.validateVNET()
+			if test.expectedMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %s", err.Error())
+				}
+			} else if err == nil || err.Error() != test.expectedMsg {
+				t.Errorf("expected error message : %s, but got %v", test.expectedMsg, err)
+			}
+		})
+	}
+}
+
+func TestProperties_ValidatePrivateCluster(t *testing.T) {
+	validVNetSubnetID := "/subscriptions/SUB_ID/resourceGroups/RG_NAME/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME"
+	validRouteTableID := "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable"
+
+	tests := []struct {
+		name           string
+		privateCluster *PrivateCluster
+		vnetSubnetID   string
+		routeTableID   string
+		featureFlags   *FeatureFlags
+		addons         []KubernetesAddon
+		nodePublicIP   bool
+		outboundType   string
+		expectedMsg    string
+	}{
+		{
+			name: "private cluster",
+			privateCluster: &PrivateCluster{
+				Enabled: to.BoolPtr(true),
+			},
+		},
+		{
+			name: "fully private cluster",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+		},
+		{
+			name: "fully private cluster with private DNS zone name",
+			privateCluster: &PrivateCluster{
+				Enabled:            to.BoolPtr(true),
+				FullyPrivate:       to.BoolPtr(true),
+				PrivateDNSZoneName: "k8s.contoso.internal",
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+		},
+		{
+			name: "private DNS zone name without fully private",
+			privateCluster: &PrivateCluster{
+				Enabled:            to.BoolPtr(true),
+				PrivateDNSZoneName: "k8s.contoso.internal",
+			},
+			expectedMsg: "privateCluster.privateDNSZoneName can only be specified when privateCluster.fullyPrivate is true",
+		},
+		{
+			name: "fully private without private cluster",
+			privateCluster: &PrivateCluster{
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			expectedMsg:  "privateCluster.fullyPrivate requires privateCluster.enabled to be true",
+		},
+		{
+			name: "fully private without custom VNET",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			expectedMsg: "privateCluster.fullyPrivate requires a custom VNET Subnet (masterProfile.vnetSubnetID)",
+		},
+		{
+			name: "fully private without route table",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			expectedMsg:  "privateCluster.fullyPrivate requires an existing route table (masterProfile.routeTableID) with a user-defined route for outbound traffic",
+		},
+		{
+			name: "fully private with IPv6 dual stack",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			featureFlags: &FeatureFlags{
+				EnableIPv6DualStack: true,
+			},
+			expectedMsg: "privateCluster.fullyPrivate is not supported with the IPv6DualStack feature, which requires public IP addresses",
+		},
+		{
+			name: "fully private with appgw-ingress addon",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			addons: []KubernetesAddon{
+				{
+					Name:    "appgw-ingress",
+					Enabled: to.BoolPtr(true),
+				},
+			},
+			expectedMsg: "privateCluster.fullyPrivate is not supported with the appgw-ingress addon, which requires a public IP address",
+		},
+		{
+			name: "fully private with node public IPs",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			nodePublicIP: true,
+			expectedMsg:  "privateCluster.fullyPrivate is not supported with enableVMSSNodePublicIP on agent pool 'agentpool'",
+		},
+		{
+			name: "fully private with load balancer outbound",
+			privateCluster: &PrivateCluster{
+				Enabled:      to.BoolPtr(true),
+				FullyPrivate: to.BoolPtr(true),
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			outboundType: OutboundTypeLoadBalancer,
+			expectedMsg:  "privateCluster.fullyPrivate requires outboundType 'userDefinedRouting'",
+		},
+		{
+			name: "fully private with invalid private DNS zone name",
+			privateCluster: &PrivateCluster{
+				Enabled:            to.BoolPtr(true),
+				FullyPrivate:       to.BoolPtr(true),
+				PrivateDNSZoneName: "contoso_internal",
+			},
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+			expectedMsg:  "privateCluster.privateDNSZoneName 'contoso_internal' is not a valid DNS zone name",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(true)
+			cs.Properties.OrchestratorProfile.KubernetesConfig = &KubernetesConfig{
+				PrivateCluster: test.privateCluster,
+				Addons:         test.addons,
+				OutboundType:   test.outboundType,
+			}
+			cs.Properties.MasterProfile.VnetSubnetID = test.vnetSubnetID
+			cs.Properties.MasterProfile.RouteTableID = test.routeTableID
+			cs.Properties.FeatureFlags = test.featureFlags
+			for _, agentPool := range cs.Properties.AgentPoolProfiles {
+				agentPool.EnableVMSSNodePublicIP = to.BoolPtr(test.nodePublicIP)
+			}
+			err := cs.Properties.validatePrivateCluster()
+			if test.expectedMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %s", err.Error())
+				}
+			} else if err == nil || err.Error() != test.expectedMsg {
+				t.Errorf("expected error message : %s, but got %v", test.expectedMsg, err)
+			}
+		})
+	}
+}
+
+func TestProperties_ValidateOutboundType(t *testing.T) {
+	validVNetSubnetID := "/subscriptions/SUB_ID/resourceGroups/RG_NAME/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME"
+	validRouteTableID := "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable"
+
+	tests := []struct {
+		name            string
+		outboundType    string
+		loadBalancerSku string
+		vnetSubnetID    string
+		routeTableID    string
+		featureFlags    *FeatureFlags
+		expectedMsg     string
+	}{
+		{
+			name: "default outbound type",
+		},
+		{
+			name:         "load balancer",
+			outboundType: OutboundTypeLoadBalancer,
+		},
+		{
+			name:         "invalid outbound type",
+			outboundType: "publicIP",
+			expectedMsg:  "outboundType 'publicIP' is invalid, valid values are [loadBalancer userDefinedRouting natGateway]",
+		},
+		{
+			name:         "user-defined routing",
+			outboundType: OutboundTypeUserDefinedRouting,
+			vnetSubnetID: validVNetSubnetID,
+			routeTableID: validRouteTableID,
+		},
+		{
+			name:         "user-defined routing without route table",
+			outboundType: OutboundTypeUserDefinedRouting,
+			vnetSubnetID: validVNetSubnetID,
+			expectedMsg:  "outboundType 'userDefinedRouting' requires an existing route table (masterProfile.routeTableID) with a user-defined route for outbound traffic",
+		},
+		{
+			name:            "NAT gateway",
+			outboundType:    OutboundTypeNATGateway,
+			loadBalancerSku: StandardLoadBalancerSku,
+		},
+		{
+			name:         "NAT gateway with basic load balancer",
+			outboundType: OutboundTypeNATGateway,
+			expectedMsg:  "outboundType 'natGateway' requires loadBalancerSku 'Standard'",
+		},
+		{
+			name:            "NAT gateway with custom VNET",
+			outboundType:    OutboundTypeNATGateway,
+			loadBalancerSku: StandardLoadBalancerSku,
+			vnetSubnetID:    validVNetSubnetID,
+			expectedMsg:     "outboundType 'natGateway' is only supported when aks-engine creates the VNET; associate a NAT gateway with the subnets of a custom VNET outside of aks-engine",
+		},
+		{
+			name:            "NAT gateway with IPv6 dual stack",
+			outboundType:    OutboundTypeNATGateway,
+			loadBalancerSku: StandardLoadBalancerSku,
+			featureFlags: &FeatureFlags{
+				EnableIPv6DualStack: true,
+			},
+			expectedMsg: "outboundType 'natGateway' is not supported with the IPv6DualStack feature",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(true)
+			cs.Properties.OrchestratorProfile.KubernetesConfig = &KubernetesConfig{
+				OutboundType:    test.outboundType,
+				LoadBalancerSku: test.loadBalancerSku,
+			}
+			cs.Properties.MasterProfile.VnetSubnetID = test.vnetSubnetID
+			cs.Properties.MasterProfile.RouteTableID = test.routeTableID
+			cs.Properties.FeatureFlags = test.featureFlags
+			err := cs.Properties.validateOutboundType()
+			if test.expectedMsg == "" {
+				if err != nil {
+					t.Errorf("expected no error, but got %s", err.Error())
+				}
+			} else if err == nil || err.Error() != test.expectedMsg {
+				t.Errorf("expected error message : %s, but got %v", test.expectedMsg, err)
+			}
+		})
+	}
+}
+
 func TestWindowsProfile_Validate(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -2398,6 +2757,132 @@ func TestWindowsProfile_Validate(t *testing.T) {
 			},
 			expectedMsg: "WindowsProfile.AdminPassword is required, when agent pool specifies windows",
 		},
+		{
+			name:             "image reference with unsupported orchestrator",
+			orchestratorType: "DCOS",
+			w: &WindowsProfile{
+				ImageRef: &ImageReference{
+					Name:          "windows",
+					ResourceGroup: "images",
+				},
+			},
+			expectedMsg: "WindowsProfile.ImageRef is only supported if the Orchestrator Type is Kubernetes",
+		},
+		{
+			name:             "image reference and image source url",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				WindowsImageSourceURL: "http://fakeWindowsImageSourceURL",
+				ImageRef: &ImageReference{
+					Name:          "windows",
+					ResourceGroup: "images",
+				},
+			},
+			expectedMsg: "WindowsProfile.ImageRef and WindowsProfile.WindowsImageSourceURL cannot both be provided",
+		},
+		{
+			name:             "image reference without resource group",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				ImageRef: &ImageReference{
+					Name: "windows",
+				},
+			},
+			expectedMsg: "invalid WindowsProfile.ImageRef: imageResourceGroup needs to be specified when imageName is provided",
+		},
+		{
+			name:             "image reference to a gallery without version",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				ImageRef: &ImageReference{
+					Name:           "windows",
+					ResourceGroup:  "images",
+					SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					Gallery:        "gallery",
+				},
+			},
+			expectedMsg: "invalid WindowsProfile.ImageRef: subscriptionId, gallery and version need to be specified together to use an image of a Shared Image Gallery",
+		},
+		{
+			name:             "pre-pull images with unsupported orchestrator",
+			orchestratorType: "DCOS",
+			w: &WindowsProfile{
+				PrePullImages: []string{"mcr.microsoft.com/windows/servercore:1809"},
+			},
+			expectedMsg: "WindowsProfile.PrePullImages are only supported if the Orchestrator Type is Kubernetes",
+		},
+		{
+			name:             "invalid pre-pull image",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				PrePullImages: []string{"mcr.microsoft.com/windows/servercore:1809", "servercore\"; Remove-Item C:\\"},
+			},
+			expectedMsg: `WindowsProfile.PrePullImages contains the invalid container image "servercore\"; Remove-Item C:\\"`,
+		},
+		{
+			name:             "domain join with unsupported orchestrator",
+			orchestratorType: "DCOS",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{Domain: "contoso.com"},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin is only supported if the Orchestrator Type is Kubernetes",
+		},
+		{
+			name:             "domain join with invalid domain",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{Domain: "contoso"},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.Domain 'contoso' is not a valid DNS domain name",
+		},
+		{
+			name:             "domain join without userName",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{Domain: "contoso.com"},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.UserName is required",
+		},
+		{
+			name:             "domain join with unsafe organizational unit",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{Domain: "contoso.com", UserName: "joiner", OrganizationalUnit: "OU=$(Remove-Item C:\\)"},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.UserName and WindowsProfile.DomainJoin.OrganizationalUnit cannot contain the characters \", ` or $",
+		},
+		{
+			name:             "domain join without keyvault reference",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{Domain: "contoso.com", UserName: "joiner"},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.KeyvaultSecretRef is required, the domain join password is only read from Key Vault",
+		},
+		{
+			name:             "domain join without secret name",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{
+					Domain:            "contoso.com",
+					UserName:          "joiner",
+					KeyvaultSecretRef: &KeyvaultSecretRef{VaultID: "/subscriptions/SUB-ID/resourceGroups/RG-NAME/providers/Microsoft.KeyVault/vaults/KV-NAME"},
+				},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.KeyvaultSecretRef.SecretName is required",
+		},
+		{
+			name:             "domain join with invalid vault id",
+			orchestratorType: "Kubernetes",
+			w: &WindowsProfile{
+				DomainJoin: &WindowsDomainJoin{
+					Domain:            "contoso.com",
+					UserName:          "joiner",
+					KeyvaultSecretRef: &KeyvaultSecretRef{VaultID: "KV-NAME", SecretName: "domain-join"},
+				},
+			},
+			expectedMsg: "WindowsProfile.DomainJoin.KeyvaultSecretRef.VaultID 'KV-NAME' is of incorrect format",
+		},
 	}
 
 	for _, test := range tests {
@@ -2412,6 +2897,75 @@ func TestWindowsProfile_Validate(t *testing.T) {
 	}
 }
 
+func TestWindowsProfile_ValidateDomainJoin(t *testing.T) {
+	w := &WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "replacePassword1234$",
+		DomainJoin: &WindowsDomainJoin{
+			Domain:             "corp.contoso.com",
+			OrganizationalUnit: "OU=k8s,DC=corp,DC=contoso,DC=com",
+			UserName:           "joiner",
+			KeyvaultSecretRef: &KeyvaultSecretRef{
+				VaultID:    "/subscriptions/SUB-ID/resourceGroups/RG-NAME/providers/Microsoft.KeyVault/vaults/KV-NAME",
+				SecretName: "domain-join",
+			},
+		},
+	}
+	if err := w.Validate(Kubernetes); err != nil {
+		t.Errorf("should not error on a valid domain join, but got : %s", err)
+	}
+}
+
+func TestAgentPoolProfile_ValidateWindowsDomainJoinVersion(t *testing.T) {
+	for _, c := range []struct {
+		release     string
+		expectedErr bool
+	}{
+		{"1.13", true},
+		{"1.14", false},
+		{"1.15", false},
+	} {
+		version := common.RationalizeReleaseAndVersion(Kubernetes, c.release, "", false, true)
+		o := &OrchestratorProfile{
+			OrchestratorType:    Kubernetes,
+			OrchestratorVersion: version,
+		}
+		w := &WindowsProfile{
+			AdminUsername: "azureuser",
+			AdminPassword: "replacePassword1234$",
+			DomainJoin: &WindowsDomainJoin{
+				Domain:   "corp.contoso.com",
+				UserName: "joiner",
+				KeyvaultSecretRef: &KeyvaultSecretRef{
+					VaultID:    "/subscriptions/SUB-ID/resourceGroups/RG-NAME/providers/Microsoft.KeyVault/vaults/KV-NAME",
+					SecretName: "domain-join",
+				},
+			},
+		}
+		a := &AgentPoolProfile{
+			Name:   "agentpool",
+			OSType: Windows,
+		}
+		err := a.validateWindows(o, w, false)
+		if c.expectedErr && err == nil {
+			t.Errorf("should error on a domain join in Kubernetes version %s", version)
+		}
+		if !c.expectedErr && err != nil {
+			t.Errorf("should not error on a domain join in Kubernetes version %s, but got : %s", version, err)
+		}
+	}
+}
+
+func TestWindowsProfile_ValidateSecretReference(t *testing.T) {
+	w := &WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "file:///secrets/cluster-windowsAdminPassword",
+	}
+	if err := w.Validate("Kubernetes"); err != nil {
+		t.Errorf("expected a password kept by a secret provider to be valid, got %s", err)
+	}
+}
+
 func TestValidate_VaultKeySecrets(t *testing.T) {
 
 	tests := []struct {
@@ -2760,6 +3314,172 @@ func TestAgentPoolProfile_ValidateAuditDEnabled(t *testing.T) {
 	})
 }
 
+func TestAgentPoolProfile_ValidateWindowsIsolation(t *testing.T) {
+	tests := []struct {
+		name             string
+		osType           OSType
+		containerRuntime string
+		isolation        string
+		expectedErr      string
+	}{
+		{
+			name:   "no isolation",
+			osType: Windows,
+		},
+		{
+			name:      "process isolation with docker",
+			osType:    Windows,
+			isolation: WindowsIsolationProcess,
+		},
+		{
+			name:             "process isolation with containerd",
+			osType:           Windows,
+			containerRuntime: Containerd,
+			isolation:        WindowsIsolationProcess,
+		},
+		{
+			name:             "Hyper-V isolation with containerd",
+			osType:           Windows,
+			containerRuntime: Containerd,
+			isolation:        WindowsIsolationHyperV,
+		},
+		{
+			name:        "Hyper-V isolation with docker",
+			osType:      Windows,
+			isolation:   WindowsIsolationHyperV,
+			expectedErr: `windowsIsolation "hyperv" in agent pool agentpool requires the Kubernetes containerRuntime "containerd"`,
+		},
+		{
+			name:             "unknown isolation",
+			osType:           Windows,
+			containerRuntime: Containerd,
+			isolation:        "vm",
+			expectedErr:      `unknown windowsIsolation "vm" specified in agent pool agentpool`,
+		},
+		{
+			name:             "linux pool",
+			osType:           Linux,
+			containerRuntime: Containerd,
+			isolation:        WindowsIsolationHyperV,
+			expectedErr:      "windowsIsolation is only supported by Windows agent pools, but agent pool agentpool is not of os type Windows",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			o := &OrchestratorProfile{
+				OrchestratorType: Kubernetes,
+				KubernetesConfig: &KubernetesConfig{
+					ContainerRuntime: test.containerRuntime,
+				},
+			}
+			a := &AgentPoolProfile{
+				Name:             "agentpool",
+				OSType:           test.osType,
+				WindowsIsolation: test.isolation,
+			}
+			err := a.validateWindowsIsolation(o)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAgentPoolProfile_ValidateWindowsImageRef(t *testing.T) {
+	tests := []struct {
+		name             string
+		orchestratorType string
+		imageSourceURL   string
+		imageRef         *ImageReference
+		expectedErr      string
+	}{
+		{
+			name:             "managed image",
+			orchestratorType: Kubernetes,
+			imageRef: &ImageReference{
+				Name:          "windows",
+				ResourceGroup: "images",
+			},
+		},
+		{
+			name:             "Shared Image Gallery image",
+			orchestratorType: Kubernetes,
+			imageRef: &ImageReference{
+				Name:           "windows",
+				ResourceGroup:  "images",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				Gallery:        "gallery",
+				Version:        "1.0.0",
+			},
+		},
+		{
+			name:             "Shared Image Gallery image without subscription",
+			orchestratorType: Kubernetes,
+			imageRef: &ImageReference{
+				Name:          "windows",
+				ResourceGroup: "images",
+				Gallery:       "gallery",
+				Version:       "1.0.0",
+			},
+			expectedErr: "invalid imageReference in Windows agent pool agentpool: subscriptionId, gallery and version need to be specified together to use an image of a Shared Image Gallery",
+		},
+		{
+			name:             "image source url",
+			orchestratorType: Kubernetes,
+			imageSourceURL:   "http://fakeWindowsImageSourceURL",
+			imageRef: &ImageReference{
+				Name:          "windows",
+				ResourceGroup: "images",
+			},
+			expectedErr: "Windows agent pool agentpool cannot specify an imageReference when WindowsProfile.WindowsImageSourceURL is provided",
+		},
+		{
+			name:             "unsupported orchestrator",
+			orchestratorType: DCOS,
+			imageRef: &ImageReference{
+				Name:          "windows",
+				ResourceGroup: "images",
+			},
+			expectedErr: "imageReference of Windows agent pool agentpool is only supported if the Orchestrator Type is Kubernetes",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			o := &OrchestratorProfile{
+				OrchestratorType: test.orchestratorType,
+			}
+			w := &WindowsProfile{
+				AdminUsername:         "azureuser",
+				AdminPassword:         "replacepassword1234$",
+				WindowsImageSourceURL: test.imageSourceURL,
+			}
+			a := &AgentPoolProfile{
+				Name:     "agentpool",
+				OSType:   Windows,
+				ImageRef: test.imageRef,
+			}
+			err := a.validateWindows(o, w, false)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("expected error %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
 func TestMasterProfile_ValidateAuditDEnabled(t *testing.T) {
 	t.Run("Should have proper validation for auditd + distro combinations", func(t *testing.T) {
 		t.Parallel()
@@ -3143,3 +3863,419 @@ func TestValidateLocation(t *testing.T) {
 		})
 	}
 }
+
+func TestProperties_ValidateNetworkSecurityGroupProfile(t *testing.T) {
+	validRule := func() SecurityRule {
+		return SecurityRule{
+			Name:                 "allow_prometheus",
+			Protocol:             "Tcp",
+			SourceAddressPrefix:  "10.100.0.0/16",
+			DestinationPortRange: "9100",
+			Access:               "Allow",
+			Priority:             200,
+			Direction:            "Inbound",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		profile     *NetworkSecurityGroupProfile
+		expectedErr error
+	}{
+		{
+			name:        "profile is nil",
+			profile:     nil,
+			expectedErr: nil,
+		},
+		{
+			name: "valid profile",
+			profile: &NetworkSecurityGroupProfile{
+				SSHSourceAddressPrefixes: []string{"10.100.0.0/16", "192.168.1.10", "VirtualNetwork"},
+				SecurityRules: []SecurityRule{
+					validRule(),
+					{
+						Name:                       "allow_outbound_registry",
+						Protocol:                   "*",
+						DestinationAddressPrefixes: []string{"52.0.0.0/8", "13.0.0.0/8"},
+						Access:                     "Allow",
+						Priority:                   200,
+						Direction:                  "Outbound",
+					},
+					{
+						Name:                     "deny_outbound_internet",
+						Protocol:                 "*",
+						DestinationAddressPrefix: "Internet",
+						Access:                   "Deny",
+						Priority:                 4000,
+						Direction:                "Outbound",
+					},
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "invalid ssh source address prefix",
+			profile: &NetworkSecurityGroupProfile{
+				SSHSourceAddressPrefixes: []string{"10.100.0.0/33"},
+			},
+			expectedErr: errors.New("networkSecurityGroupProfile.sshSourceAddressPrefixes: address prefix '10.100.0.0/33' is not a valid CIDR"),
+		},
+		{
+			name: "missing rule name",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Name = ""; return r }(),
+				},
+			},
+			expectedErr: errors.New("networkSecurityGroupProfile.securityRules must have a name"),
+		},
+		{
+			name: "duplicate rule name",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					validRule(),
+					func() SecurityRule { r := validRule(); r.Priority = 201; return r }(),
+				},
+			},
+			expectedErr: errors.New("networkSecurityGroupProfile.securityRules name 'allow_prometheus' is used more than once"),
+		},
+		{
+			name: "reserved rule name",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Name = "allow_ssh"; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule name 'allow_ssh' is reserved for the rules generated by aks-engine"),
+		},
+		{
+			name: "invalid protocol",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Protocol = "Icmp"; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' has an invalid protocol 'Icmp', valid values are [Tcp Udp *]"),
+		},
+		{
+			name: "invalid access",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Access = "allow"; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' has an invalid access 'allow', valid values are [Allow Deny]"),
+		},
+		{
+			name: "invalid direction",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Direction = ""; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' has an invalid direction '', valid values are [Inbound Outbound]"),
+		},
+		{
+			name: "priority out of range",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Priority = 5000; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' priority 5000 must be between 100 and 4096"),
+		},
+		{
+			name: "reserved priority",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule { r := validRule(); r.Priority = 101; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' priority 101 is reserved for the rules generated by aks-engine, reserved priorities are [100 101 102 110 120 4095]"),
+		},
+		{
+			name: "duplicate priority in the same direction",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					validRule(),
+					func() SecurityRule { r := validRule(); r.Name = "allow_node_exporter"; return r }(),
+				},
+			},
+			expectedErr: errors.New("security rules 'allow_prometheus' and 'allow_node_exporter' have the same inbound priority 200"),
+		},
+		{
+			name: "both source address prefix and prefixes",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule {
+						r := validRule()
+						r.SourceAddressPrefixes = []string{"10.0.0.0/8"}
+						return r
+					}(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus' can only specify one of sourceAddressPrefix and sourceAddressPrefixes"),
+		},
+		{
+			name: "invalid destination address prefixes",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule {
+						r := validRule()
+						r.DestinationAddressPrefixes = []string{"10.0.0/8"}
+						return r
+					}(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus': address prefix '10.0.0/8' is not a valid CIDR"),
+		},
+		{
+			name: "invalid source address prefix",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule {
+						r := validRule()
+						r.SourceAddressPrefix = "10.100.0.0/33"
+						return r
+					}(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus': address prefix '10.100.0.0/33' is not a valid CIDR"),
+		},
+		{
+			name: "invalid destination address prefix",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule {
+						r := validRule()
+						r.DestinationAddressPrefix = "10.0.0"
+						return r
+					}(),
+				},
+			},
+			expectedErr: errors.New("security rule 'allow_prometheus': address prefix '10.0.0' is not a valid IP address"),
+		},
+		{
+			name: "valid destination address prefix service tag",
+			profile: &NetworkSecurityGroupProfile{
+				SecurityRules: []SecurityRule{
+					func() SecurityRule {
+						r := validRule()
+						r.DestinationAddressPrefix = "VirtualNetwork"
+						return r
+					}(),
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(false)
+			cs.Properties.NetworkSecurityGroupProfile = test.profile
+			gotErr := cs.Properties.validateNetworkSecurityGroupProfile()
+			if !helpers.EqualError(gotErr, test.expectedErr) {
+				t.Logf("scenario %q", test.name)
+				t.Errorf("expected error: %v, got: %v", test.expectedErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestProperties_ValidatePKIProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     *PKIProfile
+		expectedErr error
+	}{
+		{
+			name:        "profile is nil",
+			profile:     nil,
+			expectedErr: nil,
+		},
+		{
+			name:        "empty profile",
+			profile:     &PKIProfile{},
+			expectedErr: nil,
+		},
+		{
+			name: "valid ECDSA profile",
+			profile: &PKIProfile{
+				KeyAlgorithm:     "ECDSA",
+				KeySize:          256,
+				CAValidityDays:   3650,
+				LeafValidityDays: 700,
+				ExtraSubjectAltNames: map[string][]string{
+					"apiserver": {"api.mydomain.com", "10.0.0.100", "kubernetes"},
+					"etcdpeer":  {"etcd.mydomain.com"},
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "valid RSA profile",
+			profile: &PKIProfile{
+				KeyAlgorithm:     "RSA",
+				KeySize:          3072,
+				LeafValidityDays: 700,
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "invalid key algorithm",
+			profile: &PKIProfile{
+				KeyAlgorithm: "DSA",
+			},
+			expectedErr: errors.New("pkiProfile.keyAlgorithm 'DSA' is invalid, valid values are RSA and ECDSA"),
+		},
+		{
+			name: "invalid RSA key size",
+			profile: &PKIProfile{
+				KeySize: 1024,
+			},
+			expectedErr: errors.New("pkiProfile.keySize 1024 is invalid for the key algorithm, valid values are [2048 3072 4096]"),
+		},
+		{
+			name: "invalid ECDSA key size",
+			profile: &PKIProfile{
+				KeyAlgorithm: "ECDSA",
+				KeySize:      3072,
+			},
+			expectedErr: errors.New("pkiProfile.keySize 3072 is invalid for the key algorithm, valid values are [256 384]"),
+		},
+		{
+			name: "negative CA validity",
+			profile: &PKIProfile{
+				CAValidityDays: -1,
+			},
+			expectedErr: errors.New("pkiProfile.caValidityDays -1 must not be negative"),
+		},
+		{
+			name: "negative leaf validity",
+			profile: &PKIProfile{
+				LeafValidityDays: -1,
+			},
+			expectedErr: errors.New("pkiProfile.leafValidityDays -1 must not be negative"),
+		},
+		{
+			name: "leaf outlives the CA",
+			profile: &PKIProfile{
+				CAValidityDays:   365,
+				LeafValidityDays: 730,
+			},
+			expectedErr: errors.New("pkiProfile.leafValidityDays must not exceed the validity of the CA, 365 days"),
+		},
+		{
+			name: "leaf outlives the default CA",
+			profile: &PKIProfile{
+				LeafValidityDays: 365 * 31,
+			},
+			expectedErr: errors.New("pkiProfile.leafValidityDays must not exceed the validity of the CA, 10950 days"),
+		},
+		{
+			name: "invalid component",
+			profile: &PKIProfile{
+				ExtraSubjectAltNames: map[string][]string{
+					"kubelet": {"node.mydomain.com"},
+				},
+			},
+			expectedErr: errors.New("pkiProfile.extraSubjectAltNames component 'kubelet' is invalid, valid values are [apiserver etcdserver etcdclient etcdpeer]"),
+		},
+		{
+			name: "invalid subject alternative name",
+			profile: &PKIProfile{
+				ExtraSubjectAltNames: map[string][]string{
+					"etcdserver": {"https://etcd.mydomain.com"},
+				},
+			},
+			expectedErr: errors.New("pkiProfile.extraSubjectAltNames of etcdserver: 'https://etcd.mydomain.com' is neither an IP address nor a DNS name"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(false)
+			cs.Properties.PKIProfile = test.profile
+			gotErr := cs.Properties.validatePKIProfile()
+			if !helpers.EqualError(gotErr, test.expectedErr) {
+				t.Logf("scenario %q", test.name)
+				t.Errorf("expected error: %v, got: %v", test.expectedErr, gotErr)
+			}
+		})
+	}
+
+	t.Run("not kubernetes", func(t *testing.T) {
+		cs := getK8sDefaultContainerService(false)
+		cs.Properties.OrchestratorProfile.OrchestratorType = DCOS
+		cs.Properties.PKIProfile = &PKIProfile{}
+		expectedErr := errors.New("'pkiProfile' is only supported by orchestrator 'Kubernetes'")
+		if gotErr := cs.Properties.validatePKIProfile(); !helpers.EqualError(gotErr, expectedErr) {
+			t.Errorf("expected error: %v, got: %v", expectedErr, gotErr)
+		}
+	})
+}
+
+func TestProperties_ValidateCertificateProfile(t *testing.T) {
+	chainErr := errors.New("certificateProfile.caCertificateChain requires certificateProfile.caCertificate and certificateProfile.caPrivateKey")
+	tests := []struct {
+		name        string
+		profile     *CertificateProfile
+		expectedErr error
+	}{
+		{
+			name:        "profile is nil",
+			profile:     nil,
+			expectedErr: nil,
+		},
+		{
+			name: "CA without a chain",
+			profile: &CertificateProfile{
+				CaCertificate: "cert",
+				CaPrivateKey:  "key",
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "CA with a chain",
+			profile: &CertificateProfile{
+				CaCertificate:      "cert",
+				CaPrivateKey:       "key",
+				CaCertificateChain: "chain",
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "chain without a CA certificate",
+			profile: &CertificateProfile{
+				CaPrivateKey:       "key",
+				CaCertificateChain: "chain",
+			},
+			expectedErr: chainErr,
+		},
+		{
+			name: "chain without a CA private key",
+			profile: &CertificateProfile{
+				CaCertificate:      "cert",
+				CaCertificateChain: "chain",
+			},
+			expectedErr: chainErr,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			cs := getK8sDefaultContainerService(false)
+			cs.Properties.CertificateProfile = test.profile
+			gotErr := cs.Properties.validateCertificateProfile()
+			if !helpers.EqualError(gotErr, test.expectedErr) {
+				t.Errorf("expected error: %v, got: %v", test.expectedErr, gotErr)
+			}
+		})
+	}
+}
@@ -68,6 +68,8 @@ type AzureClient struct {
 	virtualMachineExtensionsClient  compute.VirtualMachineExtensionsClient
 	disksClient                     compute.DisksClient
 	availabilitySetsClient          compute.AvailabilitySetsClient
+	imagesClient                    compute.ImagesClient
+	galleryImagesClient             compute.GalleryImagesClient
 
 	applicationsClient      graphrbac.ApplicationsClient
 	servicePrincipalsClient graphrbac.ServicePrincipalsClient
@@ -350,6 +352,8 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 		virtualMachineExtensionsClient:  compute.NewVirtualMachineExtensionsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 		disksClient:                     compute.NewDisksClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 		availabilitySetsClient:          compute.NewAvailabilitySetsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		imagesClient:                    compute.NewImagesClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
+		galleryImagesClient:             compute.NewGalleryImagesClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID),
 
 		applicationsClient:      graphrbac.NewApplicationsClientWithBaseURI(env.GraphEndpoint, tenantID),
 		servicePrincipalsClient: graphrbac.NewServicePrincipalsClientWithBaseURI(env.GraphEndpoint, tenantID),
@@ -369,6 +373,8 @@ func getClient(env azure.Environment, subscriptionID, tenantID string, armAuthor
 	c.virtualMachineScaleSetVMsClient.Authorizer = armAuthorizer
 	c.disksClient.Authorizer = armAuthorizer
 	c.availabilitySetsClient.Authorizer = armAuthorizer
+	c.imagesClient.Authorizer = armAuthorizer
+	c.galleryImagesClient.Authorizer = armAuthorizer
 
 	c.deploymentsClient.PollingDelay = time.Second * 5
 	c.resourcesClient.PollingDelay = time.Second * 5
@@ -472,6 +478,8 @@ func (az *AzureClient) AddAcceptLanguages(languages []string) {
 	az.virtualMachinesClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.virtualMachineScaleSetsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.disksClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.imagesClient.Client.RequestInspector = az.addAcceptLanguages()
+	az.galleryImagesClient.Client.RequestInspector = az.addAcceptLanguages()
 
 	az.applicationsClient.Client.RequestInspector = az.addAcceptLanguages()
 	az.servicePrincipalsClient.Client.RequestInspector = az.addAcceptLanguages()
@@ -535,6 +543,8 @@ func (az *AzureClient) AddAuxiliaryTokens(tokens []string) {
 	az.virtualMachinesClient.Client.RequestInspector = requestWithTokens
 	az.virtualMachineScaleSetsClient.Client.RequestInspector = requestWithTokens
 	az.disksClient.Client.RequestInspector = requestWithTokens
+	az.imagesClient.Client.RequestInspector = requestWithTokens
+	az.galleryImagesClient.Client.RequestInspector = requestWithTokens
 
 	az.applicationsClient.Client.RequestInspector = requestWithTokens
 	az.servicePrincipalsClient.Client.RequestInspector = requestWithTokens
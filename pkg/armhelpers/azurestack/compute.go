@@ -11,6 +11,7 @@ import (
 	"github.com/Azure/aks-engine/pkg/armhelpers"
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2017-03-30/compute"
 	azcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -195,3 +196,23 @@ func (az *AzureClient) GetAvailabilitySetFaultDomainCount(ctx context.Context, r
 	}
 	return count, nil
 }
+
+// GetImage retrieves the specified managed image.
+func (az *AzureClient) GetImage(ctx context.Context, resourceGroup, imageName string) (azcompute.Image, error) {
+	azImage := azcompute.Image{}
+	image, err := az.imagesClient.Get(ctx, resourceGroup, imageName, "")
+	if err != nil {
+		log.Printf("fail to get image, %v", err)
+		return azImage, err
+	}
+	if err = DeepCopy(&azImage, image); err != nil {
+		log.Printf("fail to convert image, %v", err)
+		return azImage, err
+	}
+	return azImage, nil
+}
+
+// GetGalleryImage is not supported, Azure Stack has no Shared Image Gallery.
+func (az *AzureClient) GetGalleryImage(ctx context.Context, subscriptionID, resourceGroup, galleryName, galleryImageName string) (azcompute.GalleryImage, error) {
+	return azcompute.GalleryImage{}, errors.New("Shared Image Gallery images are not supported on Azure Stack")
+}
@@ -150,3 +150,15 @@ func (az *AzureClient) GetAvailabilitySetFaultDomainCount(ctx context.Context, r
 	}
 	return count, nil
 }
+
+// GetImage retrieves the specified managed image.
+func (az *AzureClient) GetImage(ctx context.Context, resourceGroup, imageName string) (compute.Image, error) {
+	return az.imagesClient.Get(ctx, resourceGroup, imageName, "")
+}
+
+// GetGalleryImage retrieves the specified Shared Image Gallery image definition of the specified subscription.
+func (az *AzureClient) GetGalleryImage(ctx context.Context, subscriptionID, resourceGroup, galleryName, galleryImageName string) (compute.GalleryImage, error) {
+	client := az.galleryImagesClient
+	client.SubscriptionID = subscriptionID
+	return client.Get(ctx, resourceGroup, galleryName, galleryImageName)
+}
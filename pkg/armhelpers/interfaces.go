@@ -135,6 +135,12 @@ type AKSEngineClient interface {
 	// VM availability set IDs provided.
 	GetAvailabilitySetFaultDomainCount(ctx context.Context, resourceGroup string, vmasIDs []string) (int, error)
 
+	// GetImage retrieves the specified managed image.
+	GetImage(ctx context.Context, resourceGroup, imageName string) (compute.Image, error)
+
+	// GetGalleryImage retrieves the specified Shared Image Gallery image definition of the specified subscription.
+	GetGalleryImage(ctx context.Context, subscriptionID, resourceGroup, galleryName, galleryImageName string) (compute.GalleryImage, error)
+
 	//
 	// STORAGE
 
@@ -53,12 +53,16 @@ type MockAKSEngineClient struct {
 	FailDeleteNetworkInterface              bool
 	FailGetKubernetesClient                 bool
 	FailListProviders                       bool
+	FailGetImage                            bool
+	FailGetGalleryImage                     bool
 	ShouldSupportVMIdentity                 bool
 	FailDeleteRoleAssignment                bool
 	MockKubernetesClient                    *MockKubernetesClient
 	FakeListVirtualMachineScaleSetsResult   func() []compute.VirtualMachineScaleSet
 	FakeListVirtualMachineResult            func() []compute.VirtualMachine
 	FakeListVirtualMachineScaleSetVMsResult func() []compute.VirtualMachineScaleSetVM
+	FakeGetImageResult                      func() compute.Image
+	FakeGetGalleryImageResult               func() compute.GalleryImage
 }
 
 //MockStorageClient mock implementation of StorageClient
@@ -797,6 +801,44 @@ func (mc *MockAKSEngineClient) GetAvailabilitySetFaultDomainCount(ctx context.Co
 	return 3, nil
 }
 
+// GetImage mock
+func (mc *MockAKSEngineClient) GetImage(ctx context.Context, resourceGroup, imageName string) (compute.Image, error) {
+	if mc.FailGetImage {
+		return compute.Image{}, errors.New("GetImage failed")
+	}
+	if mc.FakeGetImageResult != nil {
+		return mc.FakeGetImageResult(), nil
+	}
+
+	return compute.Image{
+		Name: to.StringPtr(imageName),
+		ImageProperties: &compute.ImageProperties{
+			StorageProfile: &compute.ImageStorageProfile{
+				OsDisk: &compute.ImageOSDisk{
+					OsType: compute.Linux,
+				},
+			},
+		},
+	}, nil
+}
+
+// GetGalleryImage mock
+func (mc *MockAKSEngineClient) GetGalleryImage(ctx context.Context, subscriptionID, resourceGroup, galleryName, galleryImageName string) (compute.GalleryImage, error) {
+	if mc.FailGetGalleryImage {
+		return compute.GalleryImage{}, errors.New("GetGalleryImage failed")
+	}
+	if mc.FakeGetGalleryImageResult != nil {
+		return mc.FakeGetGalleryImageResult(), nil
+	}
+
+	return compute.GalleryImage{
+		Name: to.StringPtr(galleryImageName),
+		GalleryImageProperties: &compute.GalleryImageProperties{
+			OsType: compute.Linux,
+		},
+	}, nil
+}
+
 //GetStorageClient mock
 func (mc *MockAKSEngineClient) GetStorageClient(ctx context.Context, resourceGroup, accountName string) (AKSStorageClient, error) {
 	if mc.FailGetStorageClient {
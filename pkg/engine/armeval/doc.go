@@ -0,0 +1,6 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package armeval evaluates Azure Resource Manager template language expressions offline,
+// for the subset of template functions emitted by the engine.
+package armeval
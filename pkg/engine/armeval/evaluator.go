@@ -0,0 +1,502 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Environment describes the deployment scope read by the resourceGroup(), subscription()
+// and deployment() template functions. Empty fields are treated as unknown.
+type Environment struct {
+	SubscriptionID        string
+	TenantID              string
+	ResourceGroupName     string
+	ResourceGroupLocation string
+	DeploymentName        string
+}
+
+// UnresolvableError is returned when an expression depends on a value that only exists at
+// deployment time, such as the runtime state of another resource.
+type UnresolvableError struct {
+	Expression string
+	Reason     string
+}
+
+func (e *UnresolvableError) Error() string {
+	if e.Expression == "" {
+		return fmt.Sprintf("cannot be resolved offline: %s", e.Reason)
+	}
+	return fmt.Sprintf("expression %q cannot be resolved offline: %s", e.Expression, e.Reason)
+}
+
+// IsUnresolvable returns true if err, or the error it wraps, is an UnresolvableError.
+func IsUnresolvable(err error) bool {
+	_, ok := errors.Cause(err).(*UnresolvableError)
+	return ok
+}
+
+// Reference is the value of a reference() call. Its runtime state is only known after
+// deployment, so it can only be consumed by a ReferenceResolver.
+type Reference struct {
+	// ResourceID is the first argument given to reference(): a resource name,
+	// a "type/name" pair or a full resource ID.
+	ResourceID string
+	APIVersion string
+	Full       bool
+	// Path holds the properties dereferenced from the reference, e.g. ["identity", "principalId"].
+	Path []string
+}
+
+// ReferenceResolver converts a Reference that is the result of an expression, or an argument
+// of concat(), into a value.
+type ReferenceResolver func(ref Reference) (interface{}, error)
+
+// Evaluator resolves template language expressions against the parameters and variables of a template.
+type Evaluator struct {
+	env                Environment
+	parameters         map[string]interface{}
+	parameterValues    map[string]interface{}
+	variables          map[string]interface{}
+	resolvedParameters map[string]interface{}
+	resolvedVariables  map[string]interface{}
+	resolving          map[string]bool
+	copyLoops          []copyLoop
+	resolveReference   ReferenceResolver
+}
+
+type copyLoop struct {
+	name  string
+	index int
+}
+
+// NewEvaluator returns an Evaluator for template, a decoded ARM template, deployed with
+// parameterValues, the "parameters" object of a deployment parameters file.
+// Parameters without a value fall back to the defaultValue declared in the template.
+func NewEvaluator(template map[string]interface{}, parameterValues map[string]interface{}, env Environment) (*Evaluator, error) {
+	e := &Evaluator{
+		env:                env,
+		parameters:         map[string]interface{}{},
+		parameterValues:    map[string]interface{}{},
+		variables:          map[string]interface{}{},
+		resolvedParameters: map[string]interface{}{},
+		resolvedVariables:  map[string]interface{}{},
+		resolving:          map[string]bool{},
+	}
+
+	if params, ok := template["parameters"]; ok && params != nil {
+		m, ok := params.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("template parameters must be an object")
+		}
+		for k, v := range m {
+			e.parameters[strings.ToLower(k)] = Normalize(v)
+		}
+	}
+	if vars, ok := template["variables"]; ok && vars != nil {
+		m, ok := vars.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("template variables must be an object")
+		}
+		for k, v := range m {
+			e.variables[strings.ToLower(k)] = Normalize(v)
+		}
+	}
+	for k, v := range parameterValues {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("value of parameter %s must be an object", k)
+		}
+		if value, ok := m["value"]; ok {
+			e.parameterValues[strings.ToLower(k)] = Normalize(value)
+		}
+	}
+	return e, nil
+}
+
+// SetReferenceResolver sets the function used to convert the result of a reference() expression.
+// Without one, such expressions are unresolvable.
+func (e *Evaluator) SetReferenceResolver(r ReferenceResolver) {
+	e.resolveReference = r
+}
+
+// WithCopyIndex returns an Evaluator in which copyIndex() returns index for the copy loop named loop,
+// which becomes the innermost loop. The returned Evaluator shares resolved parameters and variables with e.
+func (e *Evaluator) WithCopyIndex(loop string, index int) *Evaluator {
+	c := *e
+	c.copyLoops = append(append([]copyLoop{}, e.copyLoops...), copyLoop{name: loop, index: index})
+	return &c
+}
+
+// Parameter returns the value of a template parameter.
+func (e *Evaluator) Parameter(name string) (interface{}, error) {
+	key := strings.ToLower(name)
+	if v, ok := e.resolvedParameters[key]; ok {
+		return v, nil
+	}
+	raw, err := e.rawParameter(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := e.resolve("parameters", key, raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluating parameter %s", name)
+	}
+	e.resolvedParameters[key] = v
+	return v, nil
+}
+
+// rawParameter returns the unevaluated value of a template parameter: its value from the
+// parameters file, or its default value.
+func (e *Evaluator) rawParameter(name string) (interface{}, error) {
+	key := strings.ToLower(name)
+	if v, ok := e.parameterValues[key]; ok {
+		return v, nil
+	}
+	def, ok := e.parameters[key]
+	if !ok {
+		return nil, errors.Errorf("parameter %s is not defined in the template", name)
+	}
+	m, _ := def.(map[string]interface{})
+	raw, ok := m["defaultValue"]
+	if !ok {
+		return nil, errors.Errorf("parameter %s has neither a value nor a default value", name)
+	}
+	return raw, nil
+}
+
+// Variable returns the value of a template variable.
+func (e *Evaluator) Variable(name string) (interface{}, error) {
+	key := strings.ToLower(name)
+	if v, ok := e.resolvedVariables[key]; ok {
+		return v, nil
+	}
+	raw, ok := e.variables[key]
+	if !ok {
+		return nil, errors.Errorf("variable %s is not defined in the template", name)
+	}
+	v, err := e.resolve("variables", key, raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluating variable %s", name)
+	}
+	e.resolvedVariables[key] = v
+	return v, nil
+}
+
+// resolve evaluates a parameter or variable with cycle detection.
+// Copy loops do not apply to parameters and variables.
+func (e *Evaluator) resolve(kind, key string, raw interface{}) (interface{}, error) {
+	id := kind + "/" + key
+	if e.resolving[id] {
+		return nil, errors.Errorf("circular reference to %s('%s')", kind, key)
+	}
+	e.resolving[id] = true
+	defer delete(e.resolving, id)
+
+	scoped := *e
+	scoped.copyLoops = nil
+	return scoped.Evaluate(raw)
+}
+
+// Evaluate resolves every expression found in v, which may be a string, an array or an object.
+func (e *Evaluator) Evaluate(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return e.EvaluateString(t)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			r, err := e.Evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			key, err := e.evaluateKey(k)
+			if err != nil {
+				return nil, err
+			}
+			r, err := e.Evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = r
+		}
+		return out, nil
+	default:
+		return Normalize(v), nil
+	}
+}
+
+// evaluateKey resolves an object property name, which may itself be an expression.
+func (e *Evaluator) evaluateKey(k string) (string, error) {
+	v, err := e.EvaluateString(k)
+	if err != nil {
+		return "", err
+	}
+	key, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("property name %q must evaluate to a string, got %v", k, v)
+	}
+	return key, nil
+}
+
+// EvaluatePartial resolves every expression found in v like Evaluate, but leaves the
+// unresolvable ones in place, simplified as far as possible, and returns them instead of failing.
+func (e *Evaluator) EvaluatePartial(v interface{}) (interface{}, []*UnresolvableError, error) {
+	var unresolved []*UnresolvableError
+	var walk func(v interface{}) (interface{}, error)
+	walk = func(v interface{}) (interface{}, error) {
+		switch t := v.(type) {
+		case string:
+			r, err := e.EvaluateString(t)
+			if err != nil {
+				if ue, ok := errors.Cause(err).(*UnresolvableError); ok {
+					unresolved = append(unresolved, &UnresolvableError{Expression: t, Reason: ue.Reason})
+					return e.Simplify(t), nil
+				}
+				return nil, err
+			}
+			return r, nil
+		case []interface{}:
+			out := make([]interface{}, len(t))
+			for i, item := range t {
+				r, err := walk(item)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = r
+			}
+			return out, nil
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(t))
+			for k, item := range t {
+				key, err := e.evaluateKey(k)
+				if err != nil {
+					if ue, ok := errors.Cause(err).(*UnresolvableError); ok {
+						unresolved = append(unresolved, &UnresolvableError{Expression: k, Reason: ue.Reason})
+						key = e.Simplify(k)
+					} else {
+						return nil, err
+					}
+				}
+				r, err := walk(item)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = r
+			}
+			return out, nil
+		default:
+			return Normalize(v), nil
+		}
+	}
+	out, err := walk(v)
+	return out, unresolved, err
+}
+
+// EvaluateString resolves s if it is a template language expression, unescapes it if it
+// starts with "[[", and returns it unchanged otherwise.
+func (e *Evaluator) EvaluateString(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "[[") {
+		return s[1:], nil
+	}
+	if !IsExpression(s) {
+		return s, nil
+	}
+	n, err := parseExpression(s[1 : len(s)-1])
+	if err != nil {
+		return nil, err
+	}
+	v, err := n.eval(e)
+	if err != nil {
+		if ue, ok := errors.Cause(err).(*UnresolvableError); ok && ue.Expression == "" {
+			return nil, &UnresolvableError{Expression: s, Reason: ue.Reason}
+		}
+		return nil, errors.Wrapf(err, "evaluating %q", s)
+	}
+	if ref, ok := v.(Reference); ok {
+		if e.resolveReference == nil {
+			return nil, &UnresolvableError{Expression: s, Reason: "reference() reads the runtime state of a resource"}
+		}
+		return e.resolveReference(ref)
+	}
+	return v, nil
+}
+
+// Normalize converts decoded JSON numbers into int64 where they are integral, which is the only
+// numeric type of the template language.
+func Normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < math.MaxInt64 {
+			return int64(t)
+		}
+		return t
+	case int:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = Normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = Normalize(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (n literalNode) eval(e *Evaluator) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n callNode) eval(e *Evaluator) (interface{}, error) {
+	name := strings.ToLower(n.name)
+	// if() only evaluates the branch it selects, so that an unresolvable or
+	// invalid expression in the other branch does not fail the whole expression.
+	if name == "if" {
+		if len(n.args) != 3 {
+			return nil, errors.Errorf("if() expects 3 arguments, got %d", len(n.args))
+		}
+		cond, err := n.args[0].eval(e)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return nil, errors.Errorf("the condition of if() must be a boolean, got %v", cond)
+		}
+		if b {
+			return n.args[1].eval(e)
+		}
+		return n.args[2].eval(e)
+	}
+
+	fn, ok := functions[name]
+	if !ok {
+		if _, ok := runtimeFunctions[name]; ok || strings.HasPrefix(name, "list") {
+			return nil, &UnresolvableError{Reason: fmt.Sprintf("%s() is only known at deployment time", n.name)}
+		}
+		return nil, errors.Errorf("unsupported template function %s()", n.name)
+	}
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if ref, isRef := v.(Reference); isRef && name != "reference" {
+			// concat() only copies its arguments, so it is the one function that can
+			// consume a resolved reference, e.g. a placeholder for a value known later
+			if name != "concat" || e.resolveReference == nil {
+				return nil, &UnresolvableError{Reason: fmt.Sprintf("the result of reference() is passed to %s()", n.name)}
+			}
+			if v, err = e.resolveReference(ref); err != nil {
+				return nil, err
+			}
+		}
+		args[i] = v
+	}
+	v, err := fn(e, args)
+	if err != nil {
+		if IsUnresolvable(err) {
+			return nil, err
+		}
+		return nil, errors.Wrapf(err, "%s()", n.name)
+	}
+	return v, nil
+}
+
+func (n propertyNode) eval(e *Evaluator) (interface{}, error) {
+	target, err := n.target.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return property(target, n.name)
+}
+
+func (n indexNode) eval(e *Evaluator) (interface{}, error) {
+	target, err := n.target.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch t := target.(type) {
+	case []interface{}:
+		i, ok := index.(int64)
+		if !ok {
+			return nil, errors.Errorf("array index must be an integer, got %v", index)
+		}
+		if i < 0 || i >= int64(len(t)) {
+			return nil, errors.Errorf("array index %d is out of range [0, %d)", i, len(t))
+		}
+		return t[i], nil
+	default:
+		name, ok := index.(string)
+		if !ok {
+			return nil, errors.Errorf("cannot index %T with %v", target, index)
+		}
+		return property(target, name)
+	}
+}
+
+// unknownValue is a property of the deployment scope, e.g. subscription().tenantId,
+// that is not given by the Environment of the evaluator.
+type unknownValue struct {
+	reason string
+}
+
+// property returns the named property of an object. Property names are case-insensitive.
+func property(target interface{}, name string) (interface{}, error) {
+	switch t := target.(type) {
+	case Reference:
+		t.Path = append(append([]string{}, t.Path...), name)
+		return t, nil
+	case map[string]interface{}:
+		v, ok := t[name]
+		if !ok {
+			for k := range t {
+				if strings.EqualFold(k, name) {
+					v, ok = t[k], true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, errors.Errorf("property %s does not exist", name)
+		}
+		if u, isUnknown := v.(unknownValue); isUnknown {
+			return nil, &UnresolvableError{Reason: u.reason}
+		}
+		return v, nil
+	default:
+		return nil, errors.Errorf("cannot read property %s of %T", name, target)
+	}
+}
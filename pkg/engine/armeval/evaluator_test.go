@@ -0,0 +1,337 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testTemplate = `{
+  "parameters": {
+    "location": {"type": "string", "defaultValue": ""},
+    "masterCount": {"type": "int", "defaultValue": 1},
+    "vnetCidr": {"type": "string", "defaultValue": "10.0.0.0/8"},
+    "firstConsecutiveStaticIP": {"type": "string"},
+    "nameSuffix": {"type": "string", "defaultValue": "[concat('x', parameters('masterCount'))]"}
+  },
+  "variables": {
+    "location": "[variables('locations')[mod(add(2,length(parameters('location'))),add(1,length(parameters('location'))))]]",
+    "locations": ["[resourceGroup().location]", "[parameters('location')]"],
+    "masterVMNamePrefix": "[concat('k8s-master-', parameters('nameSuffix'), '-')]",
+    "masterFirstAddrOctets": "[split(parameters('firstConsecutiveStaticIP'),'.')]",
+    "masterFirstAddr": "[int(variables('masterFirstAddrOctets')[3])]",
+    "vnetID": "[resourceId('Microsoft.Network/virtualNetworks', 'k8s-vnet')]",
+    "vnetSubnetID": "[concat(variables('vnetID'),'/subnets/','k8s-subnet')]",
+    "truncatedResourceGroup": "[take(replace(replace(resourceGroup().name, '(', '-'), ')', '-'), 63)]",
+    "roleDefinitionId": "[concat('/subscriptions/', subscription().subscriptionId, '/providers/Microsoft.Authorization/roleDefinitions/', 'b24988ac')]",
+    "cycleA": "[variables('cycleB')]",
+    "cycleB": "[variables('cycleA')]"
+  }
+}`
+
+var testEnvironment = Environment{
+	SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+	TenantID:              "11111111-1111-1111-1111-111111111111",
+	ResourceGroupName:     "my(rg)",
+	ResourceGroupLocation: "westus2",
+}
+
+func newTestEvaluator(t *testing.T, env Environment) *Evaluator {
+	t.Helper()
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(testTemplate), &template); err != nil {
+		t.Fatalf("unexpected error decoding the test template: %s", err)
+	}
+	params := map[string]interface{}{
+		"masterCount":              map[string]interface{}{"value": float64(3)},
+		"firstConsecutiveStaticIP": map[string]interface{}{"value": "10.255.255.5"},
+	}
+	e, err := NewEvaluator(template, params, env)
+	if err != nil {
+		t.Fatalf("unexpected error creating the evaluator: %s", err)
+	}
+	return e
+}
+
+func TestEvaluateString(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"plain string", "plain string"},
+		{"[[escaped]", "[escaped]"},
+		{"[parameters('masterCount')]", int64(3)},
+		{"[parameters('MasterCount')]", int64(3)},
+		{"[parameters('nameSuffix')]", "x3"},
+		{"[variables('location')]", "westus2"},
+		{"[variables('masterVMNamePrefix')]", "k8s-master-x3-"},
+		{"[variables('masterFirstAddr')]", int64(5)},
+		{"[variables('vnetSubnetID')]", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my(rg)/providers/Microsoft.Network/virtualNetworks/k8s-vnet/subnets/k8s-subnet"},
+		{"[variables('truncatedResourceGroup')]", "my-rg-"},
+		{"[variables('roleDefinitionId')]", "/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/b24988ac"},
+		{"[resourceGroup().id]", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my(rg)"},
+		{"[subscription().tenantId]", "11111111-1111-1111-1111-111111111111"},
+		{"[resourceId('other-rg', 'Microsoft.Network/virtualNetworks/subnets', 'vnet', 'subnet')]", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other-rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"},
+		{"[resourceId('Microsoft.ManagedIdentity/userAssignedIdentities/', 'id')]", "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my(rg)/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id"},
+		{"[concat('SSH-', variables('masterVMNamePrefix'), 0)]", "SSH-k8s-master-x3-0"},
+		{"[concat(createArray(1, 2), createArray('a'))]", []interface{}{int64(1), int64(2), "a"}},
+		{"[if(contains(split('eastus,westus2', ','), variables('location')), 3, 2)]", int64(3)},
+		{"[if(equals('centraluseuap', variables('location')), 1, 2)]", int64(2)},
+		{"[if(true(), 'a', parameters('undefined'))]", "a"},
+		{"[or(or(endsWith('abc-', '-'), endsWith('abc', '_')), false())]", true},
+		{"[and(true(), not(false()))]", true},
+		{"[sub(parameters('masterCount'), 1)]", int64(2)},
+		{"[div(7, 2)]", int64(3)},
+		{"[mul(3, 4)]", int64(12)},
+		{"[string(add(1, 2))]", "3"},
+		{"[string(true())]", "True"},
+		{"[toLower('ABC')]", "abc"},
+		{"[toUpper('abc')]", "ABC"},
+		{"[substring('abcdef', 2, 3)]", "cde"},
+		{"[take('abcdef', 100)]", "abcdef"},
+		{"[skip(createArray(1, 2, 3), 2)]", []interface{}{int64(3)}},
+		{"[length(variables('locations'))]", int64(2)},
+		{"[empty('')]", true},
+		{"[first('abc')]", "a"},
+		{"[last(createArray(1, 2))]", int64(2)},
+		{"[padLeft(7, 3, '0')]", "007"},
+		{"[format('{0}-{1}', 'a', 2)]", "a-2"},
+		{"[base64('hello')]", "aGVsbG8="},
+		{"[base64ToString('aGVsbG8=')]", "hello"},
+		{"[startsWith('ABCdef', 'abc')]", true},
+		{"[indexOf('abcabc', 'C')]", int64(2)},
+		{"[lastIndexOf('abcabc', 'c')]", int64(5)},
+		{"[greater(2, 1)]", true},
+		{"[lessOrEquals('a', 'a')]", true},
+		{"[coalesce(null(), 'x')]", "x"},
+		{"[int('42')]", int64(42)},
+		{"[bool('true')]", true},
+		{"[replace('it''s', '''', '')]", "its"},
+		{"[ concat( 'a' , 'b' ) ]", "ab"},
+	}
+
+	e := newTestEvaluator(t, testEnvironment)
+	for _, c := range cases {
+		c := c
+		t.Run(c.expr, func(t *testing.T) {
+			actual, err := e.EvaluateString(c.expr)
+			if err != nil {
+				t.Fatalf("unexpected error evaluating %s: %s", c.expr, err)
+			}
+			if diff := cmp.Diff(c.expected, actual); diff != "" {
+				t.Errorf("unexpected result evaluating %s (-want +got):\n%s", c.expr, diff)
+			}
+		})
+	}
+}
+
+func TestEvaluateStringErrors(t *testing.T) {
+	cases := []struct {
+		expr        string
+		expectedErr string
+	}{
+		{"[concat('a']", "expected ',' or ')'"},
+		{"[concat('a)]", "unterminated string literal"},
+		{"[variables('missing')]", "variable missing is not defined in the template"},
+		{"[parameters('missing')]", "parameter missing is not defined in the template"},
+		{"[variables('cycleA')]", "circular reference to variables('cyclea')"},
+		{"[copyIndex()]", "copyIndex() used outside of a copy loop"},
+		{"[div(1, 0)]", "division by zero"},
+		{"[variables('locations')[2]]", "array index 2 is out of range"},
+		{"[resourceGroup().missing]", "property missing does not exist"},
+		{"[resourceId('Microsoft.Network/virtualNetworks/subnets', 'vnet')]", "expects 2 names, got 1"},
+		{"[concat('a') 'b']", "unexpected"},
+		{"[createObject()]", "unsupported template function createObject()"},
+	}
+
+	e := newTestEvaluator(t, testEnvironment)
+	for _, c := range cases {
+		_, err := e.EvaluateString(c.expr)
+		if err == nil {
+			t.Errorf("expected an error evaluating %s", c.expr)
+			continue
+		}
+		if IsUnresolvable(err) {
+			t.Errorf("expected %s to be invalid rather than unresolvable: %s", c.expr, err)
+		}
+		if !strings.Contains(err.Error(), c.expectedErr) {
+			t.Errorf("expected error evaluating %s to contain %q, got %q", c.expr, c.expectedErr, err)
+		}
+	}
+}
+
+func TestEvaluateUnresolvable(t *testing.T) {
+	cases := []struct {
+		name string
+		env  Environment
+		expr string
+	}{
+		{"guid", testEnvironment, "[guid(concat('Microsoft.Compute/virtualMachines/', variables('masterVMNamePrefix')))]"},
+		{"uniqueString", testEnvironment, "[uniqueString(resourceGroup().id)]"},
+		{"listKeys", testEnvironment, "[listKeys(resourceId('Microsoft.Storage/storageAccounts', 'sa'), '2018-02-01').keys[0].value]"},
+		{"reference", testEnvironment, "[reference(concat('Microsoft.Network/publicIPAddresses/', 'ip')).dnsSettings.fqdn]"},
+		{"reference in concat", testEnvironment, "[concat('https://', reference('ip').dnsSettings.fqdn)]"},
+		{"unknown resource group", Environment{}, "[variables('vnetSubnetID')]"},
+		{"unknown subscription", Environment{}, "[subscription().subscriptionId]"},
+		{"unknown tenant", Environment{SubscriptionID: "sub"}, "[subscription().tenantId]"},
+		{"unknown deployment", testEnvironment, "[deployment().name]"},
+	}
+
+	for _, c := range cases {
+		e := newTestEvaluator(t, c.env)
+		_, err := e.EvaluateString(c.expr)
+		if !IsUnresolvable(err) {
+			t.Errorf("%s: expected an unresolvable error, got %v", c.name, err)
+		}
+	}
+}
+
+func TestEvaluatePartialEnvironment(t *testing.T) {
+	e := newTestEvaluator(t, Environment{ResourceGroupLocation: "westus2"})
+	actual, err := e.EvaluateString("[resourceGroup().location]")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual != "westus2" {
+		t.Errorf("expected the location of the resource group to be westus2, got %v", actual)
+	}
+	for _, expr := range []string{"[resourceGroup().id]", "[resourceGroup().name]", "[subscription().tenantId]"} {
+		if _, err = e.EvaluateString(expr); !IsUnresolvable(err) {
+			t.Errorf("expected %s to be unresolvable, got %v", expr, err)
+		}
+	}
+}
+
+func TestEvaluateCopyIndex(t *testing.T) {
+	e := newTestEvaluator(t, testEnvironment)
+
+	loop := e.WithCopyIndex("vmLoopNode", 2)
+	for expr, expected := range map[string]interface{}{
+		"[copyIndex()]":                 int64(2),
+		"[copyIndex(1)]":                int64(3),
+		"[copyIndex('vmLoopNode')]":     int64(2),
+		"[copyIndex('VMLOOPNODE', 10)]": int64(12),
+		"[concat(variables('masterVMNamePrefix'), copyIndex())]": "k8s-master-x3-2",
+	} {
+		actual, err := loop.EvaluateString(expr)
+		if err != nil {
+			t.Fatalf("unexpected error evaluating %s: %s", expr, err)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("unexpected result evaluating %s (-want +got):\n%s", expr, diff)
+		}
+	}
+
+	nested := loop.WithCopyIndex("inner", 0)
+	actual, err := nested.EvaluateString("[concat(copyIndex(), '-', copyIndex('vmLoopNode'))]")
+	if err != nil {
+		t.Fatalf("unexpected error evaluating nested copy loops: %s", err)
+	}
+	if actual != "0-2" {
+		t.Errorf("expected nested copy loops to evaluate to 0-2, got %v", actual)
+	}
+
+	if _, err := loop.EvaluateString("[copyIndex('other')]"); err == nil || !strings.Contains(err.Error(), "copy loop other is not in scope") {
+		t.Errorf("expected an out of scope copy loop error, got %v", err)
+	}
+}
+
+func TestEvaluatePartial(t *testing.T) {
+	e := newTestEvaluator(t, testEnvironment)
+	resource := map[string]interface{}{
+		"name":     "[guid(variables('masterVMNamePrefix'))]",
+		"location": "[variables('location')]",
+		"properties": map[string]interface{}{
+			"principalId": "[reference('vm', '2017-03-30', 'Full').identity.principalId]",
+			"count":       float64(2),
+			"userAssignedIdentities": map[string]interface{}{
+				"[variables('vnetID')]": map[string]interface{}{},
+			},
+		},
+	}
+
+	actual, unresolved, err := e.EvaluatePartial(resource)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"name":     "[guid('k8s-master-x3-')]",
+		"location": "westus2",
+		"properties": map[string]interface{}{
+			"principalId": "[reference('vm', '2017-03-30', 'Full').identity.principalId]",
+			"count":       int64(2),
+			"userAssignedIdentities": map[string]interface{}{
+				"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my(rg)/providers/Microsoft.Network/virtualNetworks/k8s-vnet": map[string]interface{}{},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected partially evaluated resource (-want +got):\n%s", diff)
+	}
+	if len(unresolved) != 2 {
+		t.Errorf("expected 2 unresolved expressions, got %d", len(unresolved))
+	}
+
+	if _, _, err := e.EvaluatePartial(map[string]interface{}{"name": "[variables('missing')]"}); err == nil {
+		t.Error("expected invalid expressions to fail partial evaluation")
+	}
+}
+
+func TestReferenceResolver(t *testing.T) {
+	e := newTestEvaluator(t, testEnvironment)
+	var got Reference
+	e.SetReferenceResolver(func(ref Reference) (interface{}, error) {
+		got = ref
+		return "resolved", nil
+	})
+
+	actual, err := e.EvaluateString("[reference(concat('Microsoft.Compute/virtualMachines/', variables('masterVMNamePrefix'), 0), '2017-03-30', 'Full').identity.principalId]")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual != "resolved" {
+		t.Errorf("expected the resolver result, got %v", actual)
+	}
+	expected := Reference{
+		ResourceID: "Microsoft.Compute/virtualMachines/k8s-master-x3-0",
+		APIVersion: "2017-03-30",
+		Full:       true,
+		Path:       []string{"identity", "principalId"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected reference (-want +got):\n%s", diff)
+	}
+
+	actual, err = e.EvaluateString("[concat('ID=', reference(variables('masterVMNamePrefix')).clientId)]")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual != "ID=resolved" {
+		t.Errorf("expected the resolver result to be concatenated, got %v", actual)
+	}
+
+	_, err = e.EvaluateString("[toLower(reference(variables('masterVMNamePrefix')).clientId)]")
+	if !IsUnresolvable(err) {
+		t.Errorf("expected a reference passed to toLower() to be unresolvable, got %v", err)
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	id, err := ResourceID("sub", "rg", "Microsoft.Compute/virtualMachines/extensions", "vm", "cse")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm/extensions/cse"
+	if id != expected {
+		t.Errorf("expected %s, got %s", expected, id)
+	}
+
+	if _, err := ResourceID("sub", "rg", "Microsoft.Compute/virtualMachines", "vm", "cse"); err == nil {
+		t.Error("expected an error for a mismatched number of names")
+	}
+}
@@ -0,0 +1,901 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type templateFunction func(e *Evaluator, args []interface{}) (interface{}, error)
+
+// functions holds the template functions that can be evaluated offline, keyed by lowercase name.
+// if() is evaluated lazily by callNode.
+var functions map[string]templateFunction
+
+// runtimeFunctions are template functions whose result is only known at deployment time.
+// reference() is evaluated into a Reference instead.
+var runtimeFunctions = map[string]bool{
+	"guid":         true,
+	"uniquestring": true,
+	"newguid":      true,
+	"utcnow":       true,
+	"environment":  true,
+	"providers":    true,
+}
+
+func init() {
+	functions = map[string]templateFunction{
+		"parameters":      fnParameters,
+		"variables":       fnVariables,
+		"copyindex":       fnCopyIndex,
+		"resourcegroup":   fnResourceGroup,
+		"subscription":    fnSubscription,
+		"deployment":      fnDeployment,
+		"resourceid":      fnResourceID,
+		"reference":       fnReference,
+		"concat":          fnConcat,
+		"format":          fnFormat,
+		"string":          fnString,
+		"int":             fnInt,
+		"bool":            fnBool,
+		"true":            func(e *Evaluator, args []interface{}) (interface{}, error) { return true, checkArgs(args, 0, 0) },
+		"false":           func(e *Evaluator, args []interface{}) (interface{}, error) { return false, checkArgs(args, 0, 0) },
+		"null":            func(e *Evaluator, args []interface{}) (interface{}, error) { return nil, checkArgs(args, 0, 0) },
+		"add":             arithmetic(func(a, b int64) (int64, error) { return a + b, nil }),
+		"sub":             arithmetic(func(a, b int64) (int64, error) { return a - b, nil }),
+		"mul":             arithmetic(func(a, b int64) (int64, error) { return a * b, nil }),
+		"div":             arithmetic(divide),
+		"mod":             arithmetic(modulo),
+		"equals":          fnEquals,
+		"not":             fnNot,
+		"and":             logical(true),
+		"or":              logical(false),
+		"less":            comparison(func(c int) bool { return c < 0 }),
+		"lessorequals":    comparison(func(c int) bool { return c <= 0 }),
+		"greater":         comparison(func(c int) bool { return c > 0 }),
+		"greaterorequals": comparison(func(c int) bool { return c >= 0 }),
+		"contains":        fnContains,
+		"empty":           fnEmpty,
+		"length":          fnLength,
+		"first":           fnFirst,
+		"last":            fnLast,
+		"take":            fnTake,
+		"skip":            fnSkip,
+		"createarray": func(e *Evaluator, args []interface{}) (interface{}, error) {
+			return append([]interface{}{}, args...), nil
+		},
+		"coalesce":       fnCoalesce,
+		"split":          fnSplit,
+		"tolower":        stringFunction(strings.ToLower),
+		"toupper":        stringFunction(strings.ToUpper),
+		"trim":           stringFunction(strings.TrimSpace),
+		"replace":        fnReplace,
+		"substring":      fnSubstring,
+		"startswith":     stringPredicate(strings.HasPrefix),
+		"endswith":       stringPredicate(strings.HasSuffix),
+		"indexof":        stringIndex(strings.Index),
+		"lastindexof":    stringIndex(strings.LastIndex),
+		"padleft":        fnPadLeft,
+		"base64":         fnBase64,
+		"base64tostring": fnBase64ToString,
+		"json":           fnJSON,
+	}
+}
+
+func checkArgs(args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return errors.Errorf("expects %d arguments, got %d", min, len(args))
+		}
+		return errors.Errorf("expects between %d and %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+func stringArg(args []interface{}, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", errors.Errorf("argument %d must be a string, got %v", i+1, args[i])
+	}
+	return s, nil
+}
+
+func intArg(args []interface{}, i int) (int64, error) {
+	n, ok := args[i].(int64)
+	if !ok {
+		return 0, errors.Errorf("argument %d must be an integer, got %v", i+1, args[i])
+	}
+	return n, nil
+}
+
+func fnParameters(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return e.Parameter(name)
+}
+
+func fnVariables(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	name, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return e.Variable(name)
+}
+
+// fnCopyIndex implements copyIndex([loopName], [offset]).
+func fnCopyIndex(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 2); err != nil {
+		return nil, err
+	}
+	var loop string
+	var offset int64
+	for i, a := range args {
+		switch t := a.(type) {
+		case string:
+			loop = t
+		case int64:
+			offset = t
+		default:
+			return nil, errors.Errorf("argument %d must be a loop name or an offset, got %v", i+1, a)
+		}
+	}
+	if len(e.copyLoops) == 0 {
+		return nil, errors.New("copyIndex() used outside of a copy loop")
+	}
+	if loop == "" {
+		return int64(e.copyLoops[len(e.copyLoops)-1].index) + offset, nil
+	}
+	for i := len(e.copyLoops) - 1; i >= 0; i-- {
+		if strings.EqualFold(e.copyLoops[i].name, loop) {
+			return int64(e.copyLoops[i].index) + offset, nil
+		}
+	}
+	return nil, errors.Errorf("copy loop %s is not in scope", loop)
+}
+
+func fnResourceGroup(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	rg := map[string]interface{}{
+		"id":       unknownValue{"the target subscription and resource group are not known"},
+		"name":     unknownValue{"the target resource group is not known"},
+		"type":     "Microsoft.Resources/resourceGroups",
+		"location": unknownValue{"the location of the target resource group is not known"},
+	}
+	if e.env.SubscriptionID != "" && e.env.ResourceGroupName != "" {
+		rg["id"] = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", e.env.SubscriptionID, e.env.ResourceGroupName)
+	}
+	if e.env.ResourceGroupName != "" {
+		rg["name"] = e.env.ResourceGroupName
+	}
+	if e.env.ResourceGroupLocation != "" {
+		rg["location"] = e.env.ResourceGroupLocation
+	}
+	return rg, nil
+}
+
+func fnSubscription(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	sub := map[string]interface{}{
+		"id":             unknownValue{"the target subscription is not known"},
+		"subscriptionId": unknownValue{"the target subscription is not known"},
+		"tenantId":       unknownValue{"the tenant of the target subscription is not known"},
+	}
+	if e.env.SubscriptionID != "" {
+		sub["id"] = "/subscriptions/" + e.env.SubscriptionID
+		sub["subscriptionId"] = e.env.SubscriptionID
+	}
+	if e.env.TenantID != "" {
+		sub["tenantId"] = e.env.TenantID
+	}
+	return sub, nil
+}
+
+func fnDeployment(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	if e.env.DeploymentName == "" {
+		return map[string]interface{}{"name": unknownValue{"the deployment name is not known"}}, nil
+	}
+	return map[string]interface{}{"name": e.env.DeploymentName}, nil
+}
+
+// fnResourceID implements resourceId([subscriptionId], [resourceGroupName], resourceType, resourceName1, ...).
+// The resource type is the first argument containing a slash.
+func fnResourceID(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, -1); err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(args))
+	typeIndex := -1
+	for i := range args {
+		s, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+		if typeIndex < 0 && strings.Contains(s, "/") {
+			typeIndex = i
+		}
+	}
+	if typeIndex < 0 || typeIndex > 2 {
+		return nil, errors.New("a resource type must be given as one of the first three arguments")
+	}
+
+	sub, rg := e.env.SubscriptionID, e.env.ResourceGroupName
+	switch typeIndex {
+	case 1:
+		rg = strs[0]
+	case 2:
+		sub, rg = strs[0], strs[1]
+	}
+	if sub == "" || rg == "" {
+		return nil, &UnresolvableError{Reason: "the target subscription and resource group are not known"}
+	}
+	return ResourceID(sub, rg, strs[typeIndex], strs[typeIndex+1:]...)
+}
+
+// ResourceID returns the ID of a resource in a resource group. resourceType is a fully qualified type
+// such as "Microsoft.Network/virtualNetworks/subnets", with one name per type segment after the namespace.
+func ResourceID(subscriptionID, resourceGroup, resourceType string, names ...string) (string, error) {
+	typeSegments := strings.Split(strings.Trim(resourceType, "/"), "/")
+	if len(typeSegments)-1 != len(names) {
+		return "", errors.Errorf("resource type %s expects %d names, got %d", resourceType, len(typeSegments)-1, len(names))
+	}
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s", subscriptionID, resourceGroup, typeSegments[0])
+	for i, name := range names {
+		id += "/" + typeSegments[i+1] + "/" + name
+	}
+	return id, nil
+}
+
+// fnReference implements reference(resourceNameOrID, [apiVersion], ['Full']).
+func fnReference(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 3); err != nil {
+		return nil, err
+	}
+	ref := Reference{}
+	var err error
+	if ref.ResourceID, err = stringArg(args, 0); err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		if ref.APIVersion, err = stringArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 2 {
+		full, err := stringArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		ref.Full = strings.EqualFold(full, "Full")
+	}
+	return ref, nil
+}
+
+// fnConcat concatenates strings, or arrays if the first argument is an array.
+func fnConcat(e *Evaluator, args []interface{}) (interface{}, error) {
+	if len(args) > 0 {
+		if _, ok := args[0].([]interface{}); ok {
+			out := []interface{}{}
+			for i, a := range args {
+				arr, ok := a.([]interface{})
+				if !ok {
+					return nil, errors.Errorf("argument %d must be an array, got %v", i+1, a)
+				}
+				out = append(out, arr...)
+			}
+			return out, nil
+		}
+	}
+	var sb strings.Builder
+	for _, a := range args {
+		s, err := toString(a)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+// fnFormat implements format(formatString, arg0, ...) for the composite format items {0}, {1}, ...
+func fnFormat(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, -1); err != nil {
+		return nil, err
+	}
+	format, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '{' && i+1 < len(format) && format[i+1] == '{':
+			sb.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(format) && format[i+1] == '}':
+			sb.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated format item in %q", format)
+			}
+			n, err := strconv.Atoi(format[i+1 : i+end])
+			if err != nil || n < 0 || n+1 >= len(args) {
+				return nil, errors.Errorf("invalid format item %s in %q", format[i:i+end+1], format)
+			}
+			s, err := toString(args[n+1])
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(s)
+			i += end
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+func fnString(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return toString(args[0])
+}
+
+// toString converts a value to a string the way string() does.
+func toString(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case bool:
+		if t {
+			return "True", nil
+		}
+		return "False", nil
+	case nil:
+		return "", nil
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+func fnInt(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case int64:
+		return t, nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("cannot convert %q to an integer", t)
+		}
+		return i, nil
+	default:
+		return nil, errors.Errorf("cannot convert %v to an integer", args[0])
+	}
+}
+
+func fnBool(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case bool:
+		return t, nil
+	case int64:
+		return t != 0, nil
+	case string:
+		b, err := strconv.ParseBool(strings.ToLower(t))
+		if err != nil {
+			return nil, errors.Errorf("cannot convert %q to a boolean", t)
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("cannot convert %v to a boolean", args[0])
+	}
+}
+
+func arithmetic(op func(a, b int64) (int64, error)) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+		a, err := intArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		b, err := intArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return op(a, b)
+	}
+}
+
+func divide(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+func modulo(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a % b, nil
+}
+
+func fnEquals(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return reflect.DeepEqual(args[0], args[1]), nil
+}
+
+func fnNot(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	b, ok := args[0].(bool)
+	if !ok {
+		return nil, errors.Errorf("argument must be a boolean, got %v", args[0])
+	}
+	return !b, nil
+}
+
+// logical returns and() when all is true, or() otherwise.
+func logical(all bool) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, -1); err != nil {
+			return nil, err
+		}
+		for i, a := range args {
+			b, ok := a.(bool)
+			if !ok {
+				return nil, errors.Errorf("argument %d must be a boolean, got %v", i+1, a)
+			}
+			if b != all {
+				return !all, nil
+			}
+		}
+		return all, nil
+	}
+}
+
+func comparison(result func(c int) bool) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+		switch a := args[0].(type) {
+		case int64:
+			b, err := intArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case a < b:
+				return result(-1), nil
+			case a > b:
+				return result(1), nil
+			}
+			return result(0), nil
+		case string:
+			b, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return result(strings.Compare(a, b)), nil
+		default:
+			return nil, errors.Errorf("cannot compare %v", args[0])
+		}
+	}
+}
+
+// fnContains checks for a substring (case-sensitive), an array element or an object key (case-insensitive).
+func fnContains(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		s, err := toString(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(t, s), nil
+	case []interface{}:
+		for _, item := range t {
+			if reflect.DeepEqual(item, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		for k := range t {
+			if strings.EqualFold(k, key) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return nil, errors.Errorf("cannot search %v", args[0])
+	}
+}
+
+func length(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case string:
+		return len(t), nil
+	case []interface{}:
+		return len(t), nil
+	case map[string]interface{}:
+		return len(t), nil
+	case nil:
+		return 0, nil
+	default:
+		return 0, errors.Errorf("cannot get the length of %v", v)
+	}
+}
+
+func fnEmpty(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := length(args[0])
+	return n == 0, err
+}
+
+func fnLength(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := length(args[0])
+	return int64(n), err
+}
+
+func fnFirst(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		if t == "" {
+			return "", nil
+		}
+		return t[:1], nil
+	case []interface{}:
+		if len(t) == 0 {
+			return nil, nil
+		}
+		return t[0], nil
+	default:
+		return nil, errors.Errorf("argument must be a string or an array, got %v", args[0])
+	}
+}
+
+func fnLast(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		if t == "" {
+			return "", nil
+		}
+		return t[len(t)-1:], nil
+	case []interface{}:
+		if len(t) == 0 {
+			return nil, nil
+		}
+		return t[len(t)-1], nil
+	default:
+		return nil, errors.Errorf("argument must be a string or an array, got %v", args[0])
+	}
+}
+
+// clamp limits n to [0, max].
+func clamp(n int64, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > int64(max) {
+		return max
+	}
+	return int(n)
+}
+
+func fnTake(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	n, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		return t[:clamp(n, len(t))], nil
+	case []interface{}:
+		return append([]interface{}{}, t[:clamp(n, len(t))]...), nil
+	default:
+		return nil, errors.Errorf("argument 1 must be a string or an array, got %v", args[0])
+	}
+}
+
+func fnSkip(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	n, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		return t[clamp(n, len(t)):], nil
+	case []interface{}:
+		return append([]interface{}{}, t[clamp(n, len(t)):]...), nil
+	default:
+		return nil, errors.Errorf("argument 1 must be a string or an array, got %v", args[0])
+	}
+}
+
+func fnCoalesce(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, -1); err != nil {
+		return nil, err
+	}
+	for _, a := range args {
+		if a != nil {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+// fnSplit implements split(inputString, delimiter), where delimiter is a string or an array of strings.
+func fnSplit(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var delimiters []string
+	switch t := args[1].(type) {
+	case string:
+		delimiters = []string{t}
+	case []interface{}:
+		for i := range t {
+			d, err := stringArg(t, i)
+			if err != nil {
+				return nil, err
+			}
+			delimiters = append(delimiters, d)
+		}
+	default:
+		return nil, errors.Errorf("argument 2 must be a string or an array, got %v", args[1])
+	}
+
+	parts := []string{s}
+	for _, d := range delimiters {
+		if d == "" {
+			continue
+		}
+		var next []string
+		for _, p := range parts {
+			next = append(next, strings.Split(p, d)...)
+		}
+		parts = next
+	}
+	out := make([]interface{}, len(parts))
+	for i, p := range parts {
+		out[i] = p
+	}
+	return out, nil
+}
+
+func stringFunction(f func(string) string) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+// stringPredicate returns a case-insensitive string test such as startsWith().
+func stringPredicate(f func(s, v string) bool) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		v, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(strings.ToLower(s), strings.ToLower(v)), nil
+	}
+}
+
+// stringIndex returns a case-insensitive string search such as indexOf().
+func stringIndex(f func(s, v string) int) templateFunction {
+	return func(e *Evaluator, args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		v, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return int64(f(strings.ToLower(s), strings.ToLower(v))), nil
+	}
+}
+
+func fnReplace(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return nil, err
+	}
+	strs := make([]string, 3)
+	for i := range args {
+		s, err := stringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strings.Replace(strs[0], strs[1], strs[2], -1), nil
+}
+
+func fnSubstring(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 3); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var start int64
+	if len(args) > 1 {
+		if start, err = intArg(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || start > int64(len(s)) {
+		return nil, errors.Errorf("start index %d is out of range for %q", start, s)
+	}
+	end := int64(len(s))
+	if len(args) > 2 {
+		n, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || start+n > int64(len(s)) {
+			return nil, errors.Errorf("length %d is out of range for %q", n, s)
+		}
+		end = start + n
+	}
+	return s[start:end], nil
+}
+
+func fnPadLeft(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 3); err != nil {
+		return nil, err
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	n, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	pad := " "
+	if len(args) > 2 {
+		if pad, err = stringArg(args, 2); err != nil {
+			return nil, err
+		}
+		if len(pad) != 1 {
+			return nil, errors.Errorf("padding must be a single character, got %q", pad)
+		}
+	}
+	if int64(len(s)) >= n {
+		return s, nil
+	}
+	return strings.Repeat(pad, int(n)-len(s)) + s, nil
+}
+
+func fnBase64(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+func fnBase64ToString(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding base64")
+	}
+	return string(b), nil
+}
+
+func fnJSON(e *Evaluator, args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "decoding JSON")
+	}
+	return Normalize(v), nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MaterializedTemplate is an ARM template with its parameters, variables and copy loops resolved:
+// a flat list of the resources it deploys, with concrete names and properties.
+type MaterializedTemplate struct {
+	Resources []interface{}          `json:"resources"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// Materialize resolves every expression of template, a decoded ARM template, deployed with
+// parameterValues, the "parameters" object of a deployment parameters file.
+// Copy loops are expanded in template order, nested resources are flattened into top-level
+// resources, and resources whose condition evaluates to false are left out.
+// Expressions that can only be resolved at deployment time are left in place and returned.
+func Materialize(template, parameterValues map[string]interface{}, env Environment) (*MaterializedTemplate, []*UnresolvableError, error) {
+	e, err := NewEvaluator(template, parameterValues, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &materializer{
+		evaluator: e,
+		template: &MaterializedTemplate{
+			Resources: []interface{}{},
+		},
+	}
+
+	resources, ok := template["resources"].([]interface{})
+	if !ok && template["resources"] != nil {
+		return nil, nil, errors.New("template resources must be an array")
+	}
+	for _, r := range resources {
+		if err := m.addResource(e, r, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if outputs, ok := template["outputs"].(map[string]interface{}); ok && len(outputs) > 0 {
+		evaluated, unresolved, err := e.EvaluatePartial(outputs)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "evaluating template outputs")
+		}
+		m.unresolved = append(m.unresolved, unresolved...)
+		m.template.Outputs = evaluated.(map[string]interface{})
+	}
+
+	return m.template, m.unresolved, nil
+}
+
+type materializer struct {
+	evaluator  *Evaluator
+	template   *MaterializedTemplate
+	unresolved []*UnresolvableError
+}
+
+// addResource appends every instance of resource to the materialized template. parent is the
+// evaluated parent of a nested resource.
+func (m *materializer) addResource(e *Evaluator, resource interface{}, parent map[string]interface{}) error {
+	raw, ok := resource.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("template resources must be objects, got %v", resource)
+	}
+	raw = shallowCopy(raw)
+
+	count, loop := 1, ""
+	if c, ok := raw["copy"].(map[string]interface{}); ok {
+		loop, _ = c["name"].(string)
+		n, err := e.Evaluate(c["count"])
+		if err != nil {
+			return errors.Wrapf(err, "evaluating the copy count of %v %v", raw["type"], raw["name"])
+		}
+		i, ok := n.(int64)
+		if !ok || i < 0 {
+			return errors.Errorf("the copy count of %v %v must be a non-negative integer, got %v", raw["type"], raw["name"], n)
+		}
+		count = int(i)
+	}
+	delete(raw, "copy")
+
+	children, _ := raw["resources"].([]interface{})
+	delete(raw, "resources")
+
+	for i := 0; i < count; i++ {
+		instance := e
+		if loop != "" {
+			instance = e.WithCopyIndex(loop, i)
+		}
+
+		if condition, ok := raw["condition"]; ok {
+			v, err := instance.Evaluate(condition)
+			if err != nil && !IsUnresolvable(err) {
+				return errors.Wrapf(err, "evaluating the condition of %v %v", raw["type"], raw["name"])
+			}
+			if v == false {
+				continue
+			}
+		}
+
+		evaluated, unresolved, err := instance.EvaluatePartial(raw)
+		if err != nil {
+			return errors.Wrapf(err, "evaluating %v %v", raw["type"], raw["name"])
+		}
+		m.unresolved = append(m.unresolved, unresolved...)
+		r := evaluated.(map[string]interface{})
+		if r["condition"] == true {
+			delete(r, "condition")
+		}
+		if parent != nil {
+			qualifyChildResource(r, parent)
+		}
+		m.template.Resources = append(m.template.Resources, r)
+
+		for _, child := range children {
+			if err := m.addResource(instance, child, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// qualifyChildResource converts a nested resource into a top-level resource by prefixing its type
+// and name with the ones of its parent, and adding a dependency on the parent.
+func qualifyChildResource(r, parent map[string]interface{}) {
+	parentType, _ := parent["type"].(string)
+	parentName, _ := parent["name"].(string)
+	if t, ok := r["type"].(string); ok && !strings.Contains(t, "/") {
+		r["type"] = parentType + "/" + t
+		if name, ok := r["name"].(string); ok {
+			r["name"] = parentName + "/" + name
+		}
+	}
+	dependsOn, _ := r["dependsOn"].([]interface{})
+	r["dependsOn"] = append(dependsOn, parentType+"/"+parentName)
+}
+
+func shallowCopy(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
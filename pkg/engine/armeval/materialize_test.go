@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testMaterializeTemplate = `{
+  "parameters": {
+    "count": {"type": "int", "defaultValue": 1},
+    "prefix": {"type": "string"},
+    "enableDiagnostics": {"type": "bool", "defaultValue": false}
+  },
+  "variables": {
+    "vmName": "[concat(parameters('prefix'), '-vm-')]"
+  },
+  "resources": [
+    {
+      "type": "Microsoft.Network/networkInterfaces",
+      "name": "[concat(variables('vmName'), copyIndex(), '-nic')]",
+      "location": "[resourceGroup().location]",
+      "copy": {"name": "nicLoop", "count": "[parameters('count')]"}
+    },
+    {
+      "type": "Microsoft.Compute/virtualMachines",
+      "name": "[concat(variables('vmName'), copyIndex())]",
+      "location": "[resourceGroup().location]",
+      "copy": {"name": "vmLoop", "count": "[parameters('count')]"},
+      "dependsOn": ["[concat('Microsoft.Network/networkInterfaces/', variables('vmName'), copyIndex(), '-nic')]"],
+      "properties": {
+        "principalId": "[reference('identity').principalId]"
+      },
+      "resources": [
+        {
+          "type": "extensions",
+          "name": "cse",
+          "location": "[resourceGroup().location]"
+        }
+      ]
+    },
+    {
+      "condition": "[parameters('enableDiagnostics')]",
+      "type": "Microsoft.Storage/storageAccounts",
+      "name": "diagnostics"
+    }
+  ],
+  "outputs": {
+    "vmName": {"type": "string", "value": "[concat(variables('vmName'), '0')]"}
+  }
+}`
+
+func TestMaterialize(t *testing.T) {
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(testMaterializeTemplate), &template); err != nil {
+		t.Fatalf("unexpected error decoding the test template: %s", err)
+	}
+	params := map[string]interface{}{
+		"count":  map[string]interface{}{"value": float64(2)},
+		"prefix": map[string]interface{}{"value": "k8s"},
+	}
+
+	actual, unresolved, err := Materialize(template, params, Environment{ResourceGroupLocation: "westus2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	vm := func(i string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "Microsoft.Compute/virtualMachines",
+			"name":      "k8s-vm-" + i,
+			"location":  "westus2",
+			"dependsOn": []interface{}{"Microsoft.Network/networkInterfaces/k8s-vm-" + i + "-nic"},
+			"properties": map[string]interface{}{
+				"principalId": "[reference('identity').principalId]",
+			},
+		}
+	}
+	extension := func(i string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "Microsoft.Compute/virtualMachines/extensions",
+			"name":      "k8s-vm-" + i + "/cse",
+			"location":  "westus2",
+			"dependsOn": []interface{}{"Microsoft.Compute/virtualMachines/k8s-vm-" + i},
+		}
+	}
+	expected := &MaterializedTemplate{
+		Resources: []interface{}{
+			map[string]interface{}{"type": "Microsoft.Network/networkInterfaces", "name": "k8s-vm-0-nic", "location": "westus2"},
+			map[string]interface{}{"type": "Microsoft.Network/networkInterfaces", "name": "k8s-vm-1-nic", "location": "westus2"},
+			vm("0"),
+			extension("0"),
+			vm("1"),
+			extension("1"),
+		},
+		Outputs: map[string]interface{}{
+			"vmName": map[string]interface{}{"type": "string", "value": "k8s-vm-0"},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected materialized template (-want +got):\n%s", diff)
+	}
+	if len(unresolved) != 2 {
+		t.Errorf("expected the principalId of both VMs to be unresolved, got %v", unresolved)
+	}
+
+	params["enableDiagnostics"] = map[string]interface{}{"value": true}
+	actual, _, err = Materialize(template, params, Environment{ResourceGroupLocation: "westus2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	last := actual.Resources[len(actual.Resources)-1]
+	if diff := cmp.Diff(map[string]interface{}{"type": "Microsoft.Storage/storageAccounts", "name": "diagnostics"}, last); diff != "" {
+		t.Errorf("expected the conditional resource to be deployed (-want +got):\n%s", diff)
+	}
+}
+
+func TestMaterializeErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+	}{
+		{"resources", `{"resources": {}}`},
+		{"copy count", `{"resources": [{"type": "t", "name": "n", "copy": {"name": "loop", "count": "n"}}]}`},
+		{"invalid expression", `{"resources": [{"type": "t", "name": "[variables('missing')]"}]}`},
+	}
+	for _, c := range cases {
+		var template map[string]interface{}
+		if err := json.Unmarshal([]byte(c.template), &template); err != nil {
+			t.Fatalf("%s: unexpected error decoding the test template: %s", c.name, err)
+		}
+		if _, _, err := Materialize(template, nil, Environment{}); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// node is an element of a parsed template language expression.
+type node interface {
+	eval(e *Evaluator) (interface{}, error)
+}
+
+// literalNode is a string or integer literal.
+type literalNode struct {
+	value interface{}
+}
+
+// callNode is a template function call, e.g. concat('a', variables('b')).
+type callNode struct {
+	name string
+	args []node
+}
+
+// propertyNode is a property dereference, e.g. resourceGroup().location.
+type propertyNode struct {
+	target node
+	name   string
+}
+
+// indexNode is an array index or an object property lookup, e.g. variables('ips')[0].
+type indexNode struct {
+	target node
+	index  node
+}
+
+// IsExpression returns true if s is a template language expression, i.e. it is enclosed in
+// square brackets and the opening bracket is not escaped by a second one.
+func IsExpression(s string) bool {
+	return len(s) >= 2 && strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "[[") && strings.HasSuffix(s, "]")
+}
+
+// parseExpression parses the body of a template language expression, without the enclosing brackets.
+func parseExpression(s string) (node, error) {
+	p := &parser{input: s}
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.input) {
+		return nil, errors.Errorf("unexpected %q at position %d in expression %q", p.input[p.pos:], p.pos, s)
+	}
+	return n, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.input) {
+			return errors.Errorf("expected %q but reached the end of expression %q", c, p.input)
+		}
+		return errors.Errorf("expected %q at position %d in expression %q", c, p.pos, p.input)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseExpr() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			p.skipSpace()
+			name := p.parseIdentifier()
+			if name == "" {
+				return nil, errors.Errorf("expected a property name at position %d in expression %q", p.pos, p.input)
+			}
+			n = propertyNode{target: n, name: name}
+		case '[':
+			p.pos++
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(']'); err != nil {
+				return nil, err
+			}
+			n = indexNode{target: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, errors.Errorf("unexpected end of expression %q", p.input)
+	case c == '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalNode{value: s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseInteger()
+	}
+
+	name := p.parseIdentifier()
+	if name == "" {
+		return nil, errors.Errorf("unexpected %q at position %d in expression %q", c, p.pos, p.input)
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	call := callNode{name: name}
+	if p.peek() == ')' {
+		p.pos++
+		return call, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return call, nil
+		default:
+			return nil, errors.Errorf("expected ',' or ')' at position %d in expression %q", p.pos, p.input)
+		}
+	}
+}
+
+func (p *parser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// parseString parses a single-quoted string literal, in which a quote is escaped by doubling it.
+func (p *parser) parseString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		p.pos++
+		if c == '\'' {
+			if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+				sb.WriteByte('\'')
+				p.pos++
+				continue
+			}
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+	return "", errors.Errorf("unterminated string literal in expression %q", p.input)
+}
+
+func (p *parser) parseInteger() (node, error) {
+	start := p.pos
+	if p.input[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	i, err := strconv.ParseInt(p.input[start:p.pos], 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid integer %q in expression %q", p.input[start:p.pos], p.input)
+	}
+	return literalNode{value: i}, nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Simplify returns the template language expression s with every subexpression that can be
+// evaluated replaced by its value, so that only the parts that depend on deployment time values
+// are left, e.g. "[concat(variables('prefix'), uniqueString(resourceGroup().id))]" becomes
+// "[concat('k8s', uniqueString('/subscriptions/.../resourceGroups/rg'))]".
+// The result doesn't depend on the parameters, variables or copy loops of the template anymore.
+// Strings that are not valid expressions are returned unchanged.
+func (e *Evaluator) Simplify(s string) string {
+	if !IsExpression(s) {
+		return s
+	}
+	n, err := parseExpression(s[1 : len(s)-1])
+	if err != nil {
+		return s
+	}
+	return "[" + e.simplify(n) + "]"
+}
+
+func (e *Evaluator) simplify(n node) string {
+	if v, err := n.eval(e); err == nil {
+		if literal, ok := expressionLiteral(v); ok {
+			return literal
+		}
+	}
+
+	switch t := n.(type) {
+	case literalNode:
+		literal, _ := expressionLiteral(t.value)
+		return literal
+	case propertyNode:
+		return e.simplify(t.target) + "." + t.name
+	case indexNode:
+		return e.simplify(t.target) + "[" + e.simplify(t.index) + "]"
+	case callNode:
+		if strings.EqualFold(t.name, "if") && len(t.args) == 3 {
+			if cond, err := t.args[0].eval(e); err == nil {
+				if b, ok := cond.(bool); ok && b {
+					return e.simplify(t.args[1])
+				} else if ok {
+					return e.simplify(t.args[2])
+				}
+			}
+		}
+		if inlined, ok := e.simplifyDefinition(t); ok {
+			return inlined
+		}
+		args := make([]string, len(t.args))
+		for i, a := range t.args {
+			args[i] = e.simplify(a)
+		}
+		return t.name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return ""
+}
+
+// simplifyDefinition inlines the simplified definition of the parameter or variable read by call,
+// since the result of Simplify must not depend on them.
+func (e *Evaluator) simplifyDefinition(call callNode) (string, bool) {
+	kind := strings.ToLower(call.name)
+	if (kind != "parameters" && kind != "variables") || len(call.args) != 1 {
+		return "", false
+	}
+	v, err := call.args[0].eval(e)
+	if err != nil {
+		return "", false
+	}
+	name, ok := v.(string)
+	if !ok {
+		return "", false
+	}
+	var raw interface{}
+	if kind == "parameters" {
+		raw, err = e.rawParameter(name)
+	} else {
+		raw, ok = e.variables[strings.ToLower(name)]
+		if !ok {
+			err = errors.Errorf("variable %s is not defined in the template", name)
+		}
+	}
+	if err != nil {
+		return "", false
+	}
+	id := kind + "/" + strings.ToLower(name)
+	if e.resolving[id] {
+		return "", false
+	}
+	e.resolving[id] = true
+	defer delete(e.resolving, id)
+	scoped := *e
+	scoped.copyLoops = nil
+	return scoped.simplifyValue(raw)
+}
+
+// simplifyValue returns the simplified expression of a parameter or variable definition.
+// Objects holding expressions are not supported.
+func (e *Evaluator) simplifyValue(raw interface{}) (string, bool) {
+	switch t := raw.(type) {
+	case string:
+		if !IsExpression(t) {
+			v, _ := e.EvaluateString(t)
+			return expressionLiteral(v)
+		}
+		n, err := parseExpression(t[1 : len(t)-1])
+		if err != nil {
+			return "", false
+		}
+		return e.simplify(n), true
+	case []interface{}:
+		items := make([]string, len(t))
+		for i, item := range t {
+			s, ok := e.simplifyValue(item)
+			if !ok {
+				return "", false
+			}
+			items[i] = s
+		}
+		return "createArray(" + strings.Join(items, ", ") + ")", true
+	}
+	if v, err := e.Evaluate(raw); err == nil {
+		return expressionLiteral(v)
+	}
+	return "", false
+}
+
+// expressionLiteral returns the template language expression of a value, or false if v is
+// a Reference or holds a property of the deployment scope that is not known.
+func expressionLiteral(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return "'" + strings.Replace(t, "'", "''", -1) + "'", true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case bool:
+		if t {
+			return "true()", true
+		}
+		return "false()", true
+	case nil:
+		return "null()", true
+	case Reference, unknownValue:
+		return "", false
+	}
+	if !isLiteralValue(v) {
+		return "", false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	s, _ := expressionLiteral(string(b))
+	return "json(" + s + ")", true
+}
+
+func isLiteralValue(v interface{}) bool {
+	switch t := v.(type) {
+	case Reference, unknownValue:
+		return false
+	case []interface{}:
+		for _, item := range t {
+			if !isLiteralValue(item) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range t {
+			if !isLiteralValue(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
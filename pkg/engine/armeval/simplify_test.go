@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package armeval
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSimplify(t *testing.T) {
+	e := newTestEvaluator(t, Environment{ResourceGroupLocation: "westus2"}).WithCopyIndex("loop", 1)
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{"[guid(variables('masterVMNamePrefix'))]", "[guid('k8s-master-x3-')]"},
+		{"[concat(variables('masterVMNamePrefix'), copyIndex(), '-', uniqueString(variables('vnetID')))]",
+			"[concat('k8s-master-x3-', 1, '-', uniqueString(resourceId('Microsoft.Network/virtualNetworks', 'k8s-vnet')))]"},
+		{"[reference(concat(variables('masterVMNamePrefix'), copyIndex(1))).dnsSettings['fqdn']]", "[reference('k8s-master-x3-2').dnsSettings['fqdn']]"},
+		{"[if(equals(parameters('masterCount'), 3), guid('a'), parameters('location'))]", "[guid('a')]"},
+		{"[concat(variables('masterFirstAddrOctets'), createArray(newGuid()))]", "[concat(json('[\"10\",\"255\",\"255\",\"5\"]'), createArray(newGuid()))]"},
+		{"[concat(resourceGroup().name, '''')]", "[concat(resourceGroup().name, '''')]"},
+		{"[resourceGroup().location]", "['westus2']"},
+		{"plain", "plain"},
+		{"[concat('a']", "[concat('a']"},
+	}
+	for _, c := range cases {
+		if actual := e.Simplify(c.expr); actual != c.expected {
+			t.Errorf("expected %s to be simplified to %s, got %s", c.expr, c.expected, actual)
+		}
+	}
+
+	// simplified expressions evaluate to the same value, when they can be evaluated
+	for _, expr := range []string{"[json('{\"a\":[1,true,null]}').a[1]]", "[concat(variables('masterFirstAddrOctets'), createArray(1))]"} {
+		expected, err := e.EvaluateString(expr)
+		if err != nil {
+			t.Fatalf("unexpected error evaluating %s: %s", expr, err)
+		}
+		actual, err := e.EvaluateString(e.Simplify(expr))
+		if err != nil {
+			t.Fatalf("unexpected error evaluating the simplified %s: %s", expr, err)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("unexpected value of the simplified %s (-want +got):\n%s", expr, diff)
+		}
+	}
+}
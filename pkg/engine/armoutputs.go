@@ -95,6 +95,8 @@ func getMasterOutputs(cs *api.ContainerService) map[string]interface{} {
 
 	if !cs.Properties.OrchestratorProfile.IsPrivateCluster() {
 		masterFQDN = "[reference(concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))).dnsSettings.fqdn]"
+	} else if cs.Properties.OrchestratorProfile.IsFullyPrivateCluster() {
+		masterFQDN = "[variables('privateDNSZoneName')]"
 	}
 
 	outputs["masterFQDN"] = map[string]interface{}{
@@ -0,0 +1,422 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// ARMParameter is the declaration of a parameter of an ARM template
+type ARMParameter struct {
+	AllowedValues []interface{}         `json:"allowedValues,omitempty"`
+	DefaultValue  interface{}           `json:"defaultValue,omitempty"`
+	Metadata      *ARMParameterMetadata `json:"metadata,omitempty"`
+	MinLength     *int                  `json:"minLength,omitempty"`
+	MaxLength     *int                  `json:"maxLength,omitempty"`
+	Type          string                `json:"type"`
+}
+
+// ARMParameterMetadata holds the description of an ARM template parameter
+type ARMParameterMetadata struct {
+	Description string `json:"description"`
+}
+
+// newARMParameter returns the declaration of a parameter of type paramType,
+// without metadata if description is empty and without default value if defaultValue is nil
+func newARMParameter(paramType, description string, defaultValue interface{}) ARMParameter {
+	p := ARMParameter{
+		DefaultValue: defaultValue,
+		Type:         paramType,
+	}
+	if description != "" {
+		p.Metadata = &ARMParameterMetadata{Description: description}
+	}
+	return p
+}
+
+// newAllowedARMParameter returns the declaration of a parameter restricted to allowedValues
+func newAllowedARMParameter(paramType, description string, defaultValue interface{}, allowedValues ...interface{}) ARMParameter {
+	p := newARMParameter(paramType, description, defaultValue)
+	p.AllowedValues = allowedValues
+	return p
+}
+
+// addARMParameters adds the declarations of params to the declarations of dst,
+// replacing the declarations dst already has with the same names
+func addARMParameters(dst map[string]ARMParameter, params map[string]ARMParameter) {
+	for k, v := range params {
+		dst[k] = v
+	}
+}
+
+// getAllowedValues returns the values of an "allowedValues" JSON fragment such as the ones of helpers.GetKubernetesAllowedVMSKUs
+func getAllowedValues(fragment string) []interface{} {
+	var allowed struct {
+		AllowedValues []interface{} `json:"allowedValues"`
+	}
+	json.Unmarshal([]byte(fmt.Sprintf("{%s}", strings.TrimSuffix(strings.TrimSpace(fragment), ","))), &allowed)
+	return allowed.AllowedValues
+}
+
+// getMasterAllowedSizes returns the VM sizes allowed for the master and bootstrap VMs of a cluster
+func getMasterAllowedSizes(cs *api.ContainerService) []interface{} {
+	if cs.Properties.OrchestratorProfile.OrchestratorType == api.DCOS {
+		return getAllowedValues(helpers.GetDCOSMasterAllowedSizes())
+	}
+	return getAllowedValues(helpers.GetKubernetesAllowedVMSKUs())
+}
+
+// GetKubernetesParameters returns the parameter declarations of the ARM template of a Kubernetes cluster
+func GetKubernetesParameters(cs *api.ContainerService) map[string]ARMParameter {
+	params := map[string]ARMParameter{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		addARMParameters(params, getAgentPoolParameters(cs, profile))
+	}
+	if cs.Properties.HasWindows() {
+		addARMParameters(params, getWindowsParameters(cs))
+	}
+	addARMParameters(params, getMasterParameters(cs))
+	addARMParameters(params, getKubernetesClusterParameters(cs))
+	return params
+}
+
+// getDCOSParameters returns the parameter declarations of the ARM template of a DCOS cluster
+func getDCOSParameters(cs *api.ContainerService) map[string]ARMParameter {
+	params := map[string]ARMParameter{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		addARMParameters(params, getAgentPoolParameters(cs, profile))
+	}
+	if cs.Properties.HasWindows() {
+		params["dcosBinariesURL"] = newARMParameter("string", "The download url for dcos/mesos windows binaries.", nil)
+		params["dcosBinariesVersion"] = newARMParameter("string", "DCOS windows binaries version", nil)
+		addARMParameters(params, getWindowsParameters(cs))
+	}
+	addARMParameters(params, map[string]ARMParameter{
+		"dcosBootstrapURL":         newARMParameter("string", "The default mesosphere bootstrap package.", "https://dcosio.azureedge.net/dcos/stable/bootstrap/58fd0833ce81b6244fc73bf65b5deb43217b0bd7.bootstrap.tar.xz"),
+		"dcosWindowsBootstrapURL":  newARMParameter("string", "The default mesosphere bootstrap package location for windows.", "http://dcos-win.westus.cloudapp.azure.com/dcos-windows/stable/"),
+		"dcosRepositoryURL":        newARMParameter("string", "The repository URL", "https://dcosio.azureedge.net/dcos/stable"),
+		"dcosClusterPackageListID": newARMParameter("string", "The default cluster package list IDs.", "77282d8864a5bf36db345b54a0d1de3674a0e937"),
+		"dcosProviderPackageID":    newARMParameter("string", "The guid for provider dcos-provider package.", ""),
+	})
+	if isDCOSBootstrapCluster(cs) {
+		// the other parameters of the bootstrap node are shared with the masters
+		params["bootstrapStaticIP"] = newARMParameter("string", "Sets the static IP of the first bootstrap", nil)
+		bootstrapVMSize := newARMParameter("string", "The size of the Virtual Machine.", nil)
+		bootstrapVMSize.AllowedValues = getMasterAllowedSizes(cs)
+		params["bootstrapVMSize"] = bootstrapVMSize
+	}
+	addARMParameters(params, getMasterParameters(cs))
+	return params
+}
+
+// getSwarmParameters returns the parameter declarations of the ARM template of a Swarm or Swarm Mode cluster
+func getSwarmParameters(cs *api.ContainerService) map[string]ARMParameter {
+	params := map[string]ARMParameter{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		addARMParameters(params, getAgentPoolParameters(cs, profile))
+	}
+	if cs.Properties.HasWindows() {
+		addARMParameters(params, getWindowsParameters(cs))
+	}
+	addARMParameters(params, getMasterParameters(cs))
+	params["dockerEngineDownloadRepo"] = newARMParameter("string", "Docker engine download repo.", "")
+	params["dockerComposeDownloadURL"] = newARMParameter("string", "Docker compose download URL.", "")
+	return params
+}
+
+func getAgentPoolParameters(cs *api.ContainerService, profile *api.AgentPoolProfile) map[string]ARMParameter {
+	name := profile.Name
+	osType := string(profile.OSType)
+	params := map[string]ARMParameter{
+		name + "Count": newARMParameter("int", fmt.Sprintf("The number of vms in agent pool %s", name), profile.Count),
+		name + "VMSize": newAllowedARMParameter("string", "The size of the Virtual Machine.", profile.VMSize,
+			getAllowedValues(helpers.GetKubernetesAllowedVMSKUs())...),
+		name + "osImageName":          newARMParameter("string", fmt.Sprintf("Name of a %s OS image. Needs to be used in conjuction with osImageResourceGroup.", osType), ""),
+		name + "osImageResourceGroup": newARMParameter("string", fmt.Sprintf("Resource group of a %s OS image. Needs to be used in conjuction with osImageName.", osType), ""),
+		name + "osImageOffer":         newARMParameter("string", fmt.Sprintf("%s OS image type.", osType), "UbuntuServer"),
+		name + "osImagePublisher":     newARMParameter("string", "OS image publisher.", "Canonical"),
+		name + "osImageSKU":           newARMParameter("string", "OS image SKU.", "16.04-LTS"),
+		name + "osImageVersion":       newARMParameter("string", "OS image version.", "latest"),
+	}
+	if profile.IsAvailabilitySets() {
+		params[name+"Offset"] = newARMParameter("int", "offset to a particular vm within a VMAS agent pool", 0)
+	}
+	if profile.IsLowPriorityScaleSet() {
+		params[name+"ScaleSetPriority"] = newAllowedARMParameter("string", "The priority for the VM Scale Set. This value can be Low or Regular.",
+			profile.ScaleSetPriority, "Low", "Regular", "")
+		params[name+"ScaleSetEvictionPolicy"] = newAllowedARMParameter("string", "The Eviction Policy for a Low-priority VM Scale Set.",
+			profile.ScaleSetEvictionPolicy, "Delete", "Deallocate", "")
+	}
+	if profile.HasAvailabilityZones() {
+		params[name+"AvailabilityZones"] = newARMParameter("array", "Agent availability zones", nil)
+	}
+	if profile.IsCustomVNET() {
+		params[name+"VnetSubnetID"] = newARMParameter("string", fmt.Sprintf("Sets the vnet subnet of agent pool '%s'.", name), nil)
+	} else {
+		params[name+"Subnet"] = newARMParameter("string", fmt.Sprintf("Sets the subnet of agent pool '%s'.", name), profile.Subnet)
+	}
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		params[name+"EndpointDNSNamePrefix"] = newARMParameter("string", "Sets the Domain name label for the agent pool IP Address.  The concatenation of the domain name label and the regional DNS zone make up the fully qualified domain name associated with the public IP address.", nil)
+	}
+	if dcosConfig := cs.Properties.OrchestratorProfile.DcosConfig; dcosConfig != nil && dcosConfig.HasPrivateRegistry() {
+		params["registry"] = newARMParameter("string", "Private Container Registry", nil)
+		params["registryKey"] = newARMParameter("string", "base64 encoded key to the Private Container Registry", nil)
+	}
+	return params
+}
+
+func getWindowsParameters(cs *api.ContainerService) map[string]ARMParameter {
+	params := map[string]ARMParameter{
+		"windowsAdminUsername":  newARMParameter("string", "User name for the Windows Swarm Agent Virtual Machines (Password Only Supported).", nil),
+		"windowsAdminPassword":  newARMParameter("securestring", "Password for the Windows Swarm Agent Virtual Machines.", nil),
+		"agentWindowsVersion":   newARMParameter("string", "Version of the Windows Server OS image to use for the agent virtual machines.", "latest"),
+		"agentWindowsSourceUrl": newARMParameter("string", "The source of the generalized blob which will be used to create a custom windows image for the agent virtual machines.", ""),
+		"agentWindowsPublisher": newARMParameter("string", "The publisher of windows image for the agent virtual machines.", "MicrosoftWindowsServer"),
+		"agentWindowsOffer":     newARMParameter("string", "The offer of windows image for the agent virtual machines.", "WindowsServerSemiAnnual"),
+		"agentWindowsSku":       newARMParameter("string", "The SKU of windows image for the agent virtual machines.", "Datacenter-Core-1809-with-Containers-smalldisk"),
+		"windowsDockerVersion":  newARMParameter("string", "The version of Docker to be installed on Windows Nodes", "18.09.2"),
+	}
+	if cs.Properties.OrchestratorProfile.IsKubernetes() {
+		addARMParameters(params, map[string]ARMParameter{
+			"kubeBinariesSASURL":     newARMParameter("string", "The download url for kubernetes windows binaries package that is created by scripts/build-windows-k8s.sh", nil),
+			"windowsKubeBinariesURL": newARMParameter("string", "The download url for kubernetes windows binaries produce by Kubernetes. This contains only the node binaries (example: https://github.com/kubernetes/kubernetes/blob/master/CHANGELOG-1.11.md#node-binaries-1)", nil),
+			"kubeBinariesVersion":    newARMParameter("string", "Kubernetes windows binaries version", nil),
+			"kubeServiceCidr":        newARMParameter("string", "Kubernetes service address space", nil),
+			"windowsTelemetryGUID":   newARMParameter("string", "The GUID to set in windows agent to collect telemetry data.", nil),
+		})
+		if !cs.Properties.OrchestratorProfile.KubernetesConfig.RequiresDocker() {
+			params["windowsContainerdURL"] = newARMParameter("string", "The download url for the containerd binaries of windows nodes", nil)
+		}
+		if cs.Properties.WindowsProfile.HasDomainJoin() {
+			params["windowsDomainJoinPassword"] = newARMParameter("securestring", "Password of the account joining the windows nodes to the Active Directory domain.", nil)
+		}
+	}
+	return params
+}
+
+func getMasterParameters(cs *api.ContainerService) map[string]ARMParameter {
+	properties := cs.Properties
+	params := map[string]ARMParameter{
+		"linuxAdminUsername":          newARMParameter("string", "User name for the Linux Virtual Machines (SSH or Password).", nil),
+		"masterEndpointDNSNamePrefix": newARMParameter("string", "Sets the Domain name label for the master IP Address.  The concatenation of the domain name label and the regional DNS zone make up the fully qualified domain name associated with the public IP address.", nil),
+		"aksEngineVersion":            newARMParameter("string", "Contains details of the aks-engine version which was used to provision the cluster", nil),
+		"sshRSAPublicKey":             newARMParameter("string", "SSH public key used for auth to all Linux machines.  Not Required.  If not set, you must provide a password key.", nil),
+		"nameSuffix":                  newARMParameter("string", "A string hash of the master DNS name to uniquely identify the cluster.", properties.GetClusterID()),
+		"osImageName":                 newARMParameter("string", "Name of a Linux OS image. Needs to be used in conjuction with osImageResourceGroup.", ""),
+		"osImageResourceGroup":        newARMParameter("string", "Resource group of a Linux OS image. Needs to be used in conjuction with osImageName.", ""),
+		"osImageOffer":                newARMParameter("string", "Linux OS image type.", "UbuntuServer"),
+		"osImagePublisher":            newARMParameter("string", "OS image publisher.", "Canonical"),
+		"osImageSKU":                  newARMParameter("string", "OS image SKU.", "16.04-LTS"),
+		"osImageVersion":              newARMParameter("string", "OS image version.", "latest"),
+		"fqdnEndpointSuffix":          newARMParameter("string", "Endpoint of FQDN.", "cloudapp.azure.com"),
+		"targetEnvironment":           newARMParameter("string", "The azure deploy environment. Currently support: AzurePublicCloud, AzureChinaCloud", "AzurePublicCloud"),
+		"location":                    newARMParameter("string", "Sets the location for all resources in the cluster", cs.Location),
+	}
+	for _, extension := range properties.ExtensionProfiles {
+		params[extension.Name+"Parameters"] = newARMParameter("securestring", "Parameters for the extension", nil)
+	}
+
+	if properties.IsHostedMasterProfile() {
+		params["masterSubnet"] = newARMParameter("string", "Sets the subnet for the VMs in the cluster.", properties.HostedMasterProfile.Subnet)
+		params["kubernetesEndpoint"] = newARMParameter("string", "Sets the static IP of the first master", properties.HostedMasterProfile.FQDN)
+	} else {
+		masterProfile := properties.MasterProfile
+		if masterProfile.IsCustomVNET() {
+			params["masterVnetSubnetID"] = newARMParameter("string", "Sets the vnet subnet of the master.", nil)
+			if masterProfile.IsVirtualMachineScaleSets() {
+				params["agentVnetSubnetID"] = newARMParameter("string", "Sets the vnet subnet of the agent.", nil)
+			}
+			params["masterSubnet"] = newARMParameter("string", "Sets the subnet of the master node(s)", "")
+		} else {
+			params["masterSubnet"] = newARMParameter("string", "Sets the subnet of the master node(s).", masterProfile.Subnet)
+			params["agentSubnet"] = newARMParameter("string", "Sets the subnet of the agent node(s).", masterProfile.AgentSubnet)
+		}
+		params["masterSubnetIPv6"] = newARMParameter("string", "Sets the IPv6 subnet of the master node(s).", masterProfile.SubnetIPv6)
+		if masterProfile.HasAvailabilityZones() {
+			params["availabilityZones"] = newARMParameter("array", "Master availability zones", nil)
+		}
+		params["firstConsecutiveStaticIP"] = newARMParameter("string", "Sets the static IP of the first master", masterProfile.FirstConsecutiveStaticIP)
+		params["masterVMSize"] = newAllowedARMParameter("string", "The size of the Virtual Machine.", nil, getMasterAllowedSizes(cs)...)
+	}
+
+	if properties.LinuxProfile != nil {
+		for vIndex, vault := range properties.LinuxProfile.Secrets {
+			params[fmt.Sprintf("linuxKeyVaultID%d", vIndex)] = newARMParameter("string", fmt.Sprintf("KeyVaultId%d to install certificates from on linux machines.", vIndex), nil)
+			for cIndex := range vault.VaultCertificates {
+				params[fmt.Sprintf("linuxKeyVaultID%dCertificateURL%d", vIndex, cIndex)] = newARMParameter("string", fmt.Sprintf("CertificateURL%d to install from KeyVaultId%d on linux machines.", cIndex, vIndex), nil)
+			}
+		}
+	}
+	if properties.HasWindows() {
+		for vIndex, vault := range properties.WindowsProfile.Secrets {
+			params[fmt.Sprintf("windowsKeyVaultID%d", vIndex)] = newARMParameter("string", fmt.Sprintf("KeyVaultId%d to install certificates from on windows machines.", vIndex), nil)
+			for cIndex := range vault.VaultCertificates {
+				params[fmt.Sprintf("windowsKeyVaultID%dCertificateURL%d", vIndex, cIndex)] = newARMParameter("string", fmt.Sprintf("Url to retrieve Certificate%d from KeyVaultId%d to install on windows machines.", cIndex, vIndex), nil)
+				params[fmt.Sprintf("windowsKeyVaultID%dCertificateStore%d", vIndex, cIndex)] = newARMParameter("string", fmt.Sprintf("CertificateStore to install Certificate%d from KeyVaultId%d on windows machines.", cIndex, vIndex), nil)
+			}
+		}
+	}
+	return params
+}
+
+func getKubernetesClusterParameters(cs *api.ContainerService) map[string]ARMParameter {
+	properties := cs.Properties
+	orchProfile := properties.OrchestratorProfile
+	kubernetesConfig := orchProfile.KubernetesConfig
+	orchestratorNameLength := 3
+
+	params := map[string]ARMParameter{
+		"apiServerCertificate":  newARMParameter("string", "The base 64 server certificate used on the master", nil),
+		"apiServerPrivateKey":   newARMParameter("securestring", "The base 64 server private key used on the master.", nil),
+		"caCertificate":         newARMParameter("string", "The base 64 certificate authority certificate", nil),
+		"caPrivateKey":          newARMParameter("securestring", "The base 64 CA private key used on the master.", nil),
+		"clientCertificate":     newARMParameter("string", "The base 64 client certificate used to communicate with the master", nil),
+		"clientPrivateKey":      newARMParameter("securestring", "The base 64 client private key used to communicate with the master", nil),
+		"kubeConfigCertificate": newARMParameter("string", "The base 64 certificate used by cli to communicate with the master", nil),
+		"kubeConfigPrivateKey":  newARMParameter("securestring", "The base 64 private key used by cli to communicate with the master", nil),
+		"generatorCode":         newARMParameter("string", "The generator code used to identify the generator", nil),
+		"orchestratorName": {
+			Metadata:  &ARMParameterMetadata{Description: "The orchestrator name used to identify the orchestrator.  This must be no more than 3 digits in length, otherwise it will exceed Windows Naming"},
+			MinLength: &orchestratorNameLength,
+			MaxLength: &orchestratorNameLength,
+			Type:      "string",
+		},
+		"dockerBridgeCidr":                   newARMParameter("string", "Docker bridge network IP address and subnet", nil),
+		"kubeClusterCidr":                    newARMParameter("string", "Kubernetes cluster subnet", nil),
+		"kubeDNSServiceIP":                   newARMParameter("string", "Kubernetes DNS IP", nil),
+		"kubernetesKubeletClusterDomain":     newARMParameter("string", "--cluster-domain Kubelet config", nil),
+		"kubernetesHyperkubeSpec":            newARMParameter("string", "The container spec for hyperkube.", nil),
+		"privateAzureRegistryServer":         newARMParameter("string", "The private Azure registry server for hyperkube.", ""),
+		"kubernetesCcmImageSpec":             newARMParameter("string", "The container spec for cloud-controller-manager.", ""),
+		"kubernetesAddonManagerSpec":         newARMParameter("string", "The container spec for hyperkube.", nil),
+		"enableAggregatedAPIs":               newARMParameter("bool", "Enable aggregated API on master nodes", false),
+		"kubernetesDNSSidecarSpec":           newARMParameter("string", "The container spec for k8s-dns-sidecar-amd64.", nil),
+		"kubernetesACIConnectorEnabled":      newARMParameter("bool", "ACI Connector Status", nil),
+		"kubernetesClusterAutoscalerEnabled": newARMParameter("bool", "Cluster autoscaler status", nil),
+		"kubernetesPodInfraContainerSpec":    newARMParameter("string", "The container spec for pod infra.", nil),
+		"cloudproviderConfig": newARMParameter("object", "", map[string]interface{}{
+			"cloudProviderBackoff":         true,
+			"cloudProviderBackoffRetries":  10,
+			"cloudProviderBackoffJitter":   "0",
+			"cloudProviderBackoffDuration": 0,
+			"cloudProviderBackoffExponent": "0",
+			"cloudProviderRateLimit":       false,
+			"cloudProviderRateLimitQPS":    "0",
+			"cloudProviderRateLimitBucket": 0,
+		}),
+		"mobyVersion": newAllowedARMParameter("string", "The Azure Moby build version", "3.0.6",
+			"3.0.1", "3.0.2", "3.0.3", "3.0.4", "3.0.5", "3.0.6"),
+		"containerdVersion": newAllowedARMParameter("string", "The Azure Moby build version", "1.1.5",
+			"1.1.5", "1.1.6", "1.2.4"),
+		"networkPolicy": newAllowedARMParameter("string", "The network policy enforcement to use (calico|cilium); 'none' and 'azure' here for backwards compatibility", kubernetesConfig.NetworkPolicy,
+			"", "none", "azure", "calico", "cilium"),
+		"networkPlugin": newAllowedARMParameter("string", "The network plugin to use for Kubernetes (kubenet|azure|flannel|cilium)", kubernetesConfig.NetworkPlugin,
+			"kubenet", "azure", "flannel", "cilium"),
+		"containerRuntime": newAllowedARMParameter("string", "The container runtime to use (docker|kata-containers|containerd)", kubernetesConfig.ContainerRuntime,
+			"docker", "kata-containers", "containerd"),
+		"containerdDownloadURLBase": newARMParameter("string", "", "https://storage.googleapis.com/cri-containerd-release/"),
+		"cniPluginsURL":             newARMParameter("string", "", "https://acs-mirror.azureedge.net/cni/cni-plugins-amd64-latest.tgz"),
+		"vnetCniLinuxPluginsURL":    newARMParameter("string", "", "https://acs-mirror.azureedge.net/cni/azure-vnet-cni-linux-amd64-latest.tgz"),
+		"vnetCniWindowsPluginsURL":  newARMParameter("string", "", "https://acs-mirror.azureedge.net/cni/azure-vnet-cni-windows-amd64-latest.zip"),
+		"maxPods":                   newARMParameter("int", "This param has been deprecated.", 30),
+		"vnetCidr":                  newARMParameter("string", "Cluster vnet cidr", DefaultVNETCIDR),
+		"vnetCidrIPv6":              newARMParameter("string", "Cluster vnet cidr IPv6", DefaultVNETCIDRIPv6),
+		"gcHighThreshold":           newARMParameter("int", "High Threshold for Image Garbage collection on each node", 85),
+		"gcLowThreshold":            newARMParameter("int", "Low Threshold for Image Garbage collection on each node.", 80),
+		"masterOffset": newAllowedARMParameter("int", "The offset into the master pool where to start creating master VMs.  This value can be from 0 to 4, but must be less than masterCount.", 0,
+			0, 1, 2, 3, 4),
+		"etcdDiskSizeGB":      newARMParameter("string", "Size in GB to allocate for etcd volume", nil),
+		"etcdDownloadURLBase": newARMParameter("string", "etcd image base URL", nil),
+		"etcdVersion":         newARMParameter("string", "etcd version", nil),
+		"etcdEncryptionKey":   newARMParameter("string", "Encryption at rest key for etcd", nil),
+	}
+
+	if properties.HasAadProfile() {
+		params["aadTenantId"] = newARMParameter("string", "The AAD tenant ID to use for authentication. If not specified, will use the tenant of the deployment subscription.", "")
+		params["aadAdminGroupId"] = newARMParameter("string", "The AAD default Admin group Object ID used to create a cluster-admin RBAC role.", "")
+	}
+
+	if properties.IsHostedMasterProfile() {
+		params["kubernetesEndpoint"] = newARMParameter("string", "The Kubernetes API endpoint https://<kubernetesEndpoint>:443", nil)
+	} else {
+		params["etcdServerCertificate"] = newARMParameter("string", "The base 64 server certificate used on the master", nil)
+		params["etcdServerPrivateKey"] = newARMParameter("securestring", "The base 64 server private key used on the master.", nil)
+		params["etcdClientCertificate"] = newARMParameter("string", "The base 64 server certificate used on the master", nil)
+		params["etcdClientPrivateKey"] = newARMParameter("securestring", "The base 64 server private key used on the master.", nil)
+		// one peer certificate per master, for clusters of 1, 3 or 5 masters
+		peers := 1
+		if properties.MasterProfile.Count >= 5 {
+			peers = 5
+		} else if properties.MasterProfile.Count >= 3 {
+			peers = 3
+		}
+		for i := 0; i < peers; i++ {
+			params[fmt.Sprintf("etcdPeerCertificate%d", i)] = newARMParameter("string", "The base 64 server certificates used on the master", nil)
+			params[fmt.Sprintf("etcdPeerPrivateKey%d", i)] = newARMParameter("securestring", "The base 64 server private keys used on the master.", nil)
+		}
+	}
+
+	if orchProfile.NeedsExecHealthz() {
+		params["kubernetesExecHealthzSpec"] = newARMParameter("string", "The container spec for exechealthz-amd64.", nil)
+	}
+	if kubernetesConfig.IsAADPodIdentityEnabled() {
+		params["kubernetesAADPodIdentityEnabled"] = newARMParameter("bool", "AAD Pod Identity status", false)
+	}
+	if kubernetesConfig.IsClusterAutoscalerEnabled() {
+		params["kubernetesClusterAutoscalerAzureCloud"] = newARMParameter("string", "Name of the Azure cloud for the cluster autoscaler.", nil)
+		params["kubernetesClusterAutoscalerUseManagedIdentity"] = newARMParameter("string", "Managed identity for the cluster autoscaler addon", nil)
+	}
+	if common.IsKubernetesVersionGe(orchProfile.OrchestratorVersion, "1.12.0") {
+		params["kubernetesCoreDNSSpec"] = newARMParameter("string", "The container spec for coredns", nil)
+	} else {
+		params["kubernetesKubeDNSSpec"] = newARMParameter("string", "The container spec for kubedns-amd64.", nil)
+		params["kubernetesDNSMasqSpec"] = newARMParameter("string", "The container spec for kube-dnsmasq-amd64.", nil)
+	}
+	if !kubernetesConfig.UseManagedIdentity {
+		params["servicePrincipalClientId"] = newARMParameter("securestring", "Client ID (used by cloudprovider)", nil)
+		params["servicePrincipalClientSecret"] = newARMParameter("securestring", "The Service Principal Client Secret.", nil)
+	}
+
+	if kubernetesConfig.PrivateJumpboxProvision() {
+		params["jumpboxVMName"] = newARMParameter("string", "jumpbox VM Name", nil)
+		params["jumpboxVMSize"] = newAllowedARMParameter("string", "The size of the Virtual Machine. Required", nil, getMasterAllowedSizes(cs)...)
+		params["jumpboxOSDiskSizeGB"] = newARMParameter("int", "Size in GB to allocate to the private cluster jumpbox VM OS.", nil)
+		params["jumpboxPublicKey"] = newARMParameter("string", "SSH public key used for auth to the private cluster jumpbox", nil)
+		params["jumpboxUsername"] = newARMParameter("string", "Username for the private cluster jumpbox", nil)
+		params["jumpboxStorageProfile"] = newARMParameter("string", "Storage Profile for the private cluster jumpbox", nil)
+	}
+
+	if properties.LinuxProfile != nil {
+		if properties.LinuxProfile.HasSearchDomain() {
+			params["searchDomainName"] = newARMParameter("string", "Custom Search Domain name.", "")
+			params["searchDomainRealmUser"] = newARMParameter("string", "Windows server AD user name to join the Linux Machines with active directory and be able to change dns registries.", "")
+			params["searchDomainRealmPassword"] = newARMParameter("securestring", "Windows server AD user password to join the Linux Machines with active directory and be able to change dns registries.", "")
+		}
+		if properties.LinuxProfile.HasCustomNodesDNS() {
+			params["dnsServer"] = newARMParameter("string", "DNS Server IP", "")
+		}
+	}
+
+	if to.Bool(kubernetesConfig.EnableEncryptionWithExternalKms) {
+		if !kubernetesConfig.UseManagedIdentity {
+			params["servicePrincipalObjectId"] = newARMParameter("securestring", "Object ID (used by cloudprovider)", nil)
+		}
+		params["clusterKeyVaultSku"] = newAllowedARMParameter("string", "SKU for the key vault used by the cluster", "Standard", "Standard", "Premium")
+	}
+	if orchProfile.IsAzureCNI() {
+		params["AzureCNINetworkMonitorImageURL"] = newARMParameter("string", "Azure CNI networkmonitor Image URL", "")
+	}
+	if kubernetesConfig.IsAppGWIngressEnabled() {
+		params["appGwSubnet"] = newARMParameter("string", "Sets the subnet of the Application Gateway", nil)
+		params["appGwSku"] = newARMParameter("string", "Sets the subnet of the Application Gateway", nil)
+	}
+	return params
+}
@@ -36,7 +36,8 @@ func GenerateARMResources(cs *api.ContainerService) []interface{} {
 
 	if !cs.Properties.OrchestratorProfile.IsPrivateCluster() &&
 		!cs.Properties.AnyAgentHasLoadBalancerBackendAddressPoolIDs() &&
-		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku &&
+		cs.Properties.OrchestratorProfile.KubernetesConfig.IsLoadBalancerOutbound() {
 		isForMaster := false
 		publicIPAddress := CreatePublicIPAddress(isForMaster)
 		loadBalancer := CreateAgentLoadBalancer(cs.Properties, true)
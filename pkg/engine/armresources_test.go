@@ -1917,3 +1917,109 @@ func TestGenerateARMResourcesWithVMSSAgentPoolAndSLB(t *testing.T) {
 		t.Errorf("unexpected error while comparing ARM resources: %s", diff)
 	}
 }
+
+func TestGenerateARMResourcesOutboundType(t *testing.T) {
+	cases := []struct {
+		name                string
+		outboundType        string
+		routeTableID        string
+		vnetSubnetID        string
+		expectedAgentLBs    int
+		expectedNATGateways int
+		expectedPublicIPs   int
+		expectedBackendPool bool
+	}{
+		{
+			name:                "load balancer",
+			outboundType:        api.OutboundTypeLoadBalancer,
+			expectedAgentLBs:    1,
+			expectedPublicIPs:   2,
+			expectedBackendPool: true,
+		},
+		{
+			name:              "user-defined routing",
+			outboundType:      api.OutboundTypeUserDefinedRouting,
+			vnetSubnetID:      "/subscriptions/SUB_ID/resourceGroups/NETWORK_RG/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME",
+			routeTableID:      "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable",
+			expectedPublicIPs: 1,
+		},
+		{
+			name:                "NAT gateway",
+			outboundType:        api.OutboundTypeNATGateway,
+			expectedNATGateways: 1,
+			expectedPublicIPs:   2,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			cs := &api.ContainerService{
+				Properties: &api.Properties{
+					ServicePrincipalProfile: &api.ServicePrincipalProfile{
+						ClientID: "barClientID",
+						Secret:   "bazSecret",
+					},
+					MasterProfile: &api.MasterProfile{
+						Count:                    1,
+						DNSPrefix:                "blueorange",
+						VMSize:                   "Standard_D2_v2",
+						VnetSubnetID:             c.vnetSubnetID,
+						FirstConsecutiveStaticIP: "10.239.255.239",
+						RouteTableID:             c.routeTableID,
+					},
+					OrchestratorProfile: &api.OrchestratorProfile{
+						OrchestratorType:    api.Kubernetes,
+						OrchestratorVersion: "1.14.3",
+						KubernetesConfig: &api.KubernetesConfig{
+							NetworkPlugin:   "kubenet",
+							LoadBalancerSku: api.StandardLoadBalancerSku,
+							OutboundType:    c.outboundType,
+						},
+					},
+					AgentPoolProfiles: []*api.AgentPoolProfile{
+						{
+							Name:                "agentpool1",
+							Count:               2,
+							VMSize:              "Standard_D2_v2",
+							AvailabilityProfile: api.VirtualMachineScaleSets,
+							VnetSubnetID:        c.vnetSubnetID,
+						},
+					},
+					LinuxProfile: &api.LinuxProfile{},
+				},
+			}
+			cs.SetPropertiesDefaults(false, false)
+
+			var agentLBs, natGateways, publicIPs int
+			for _, resource := range GenerateARMResources(cs) {
+				switch r := resource.(type) {
+				case LoadBalancerARM:
+					if to.String(r.Name) == "[variables('agentLbName')]" {
+						agentLBs++
+					}
+				case NATGatewayARM:
+					natGateways++
+				case PublicIPAddressARM:
+					publicIPs++
+				case VirtualMachineScaleSetARM:
+					ipConfig := (*(*r.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations)[0].IPConfigurations)[0]
+					hasBackendPool := ipConfig.LoadBalancerBackendAddressPools != nil && len(*ipConfig.LoadBalancerBackendAddressPools) > 0
+					if hasBackendPool != c.expectedBackendPool {
+						t.Errorf("expected agent pool backend address pool to be %t, got %t", c.expectedBackendPool, hasBackendPool)
+					}
+				}
+			}
+
+			if agentLBs != c.expectedAgentLBs {
+				t.Errorf("expected %d agent load balancers, got %d", c.expectedAgentLBs, agentLBs)
+			}
+			if natGateways != c.expectedNATGateways {
+				t.Errorf("expected %d NAT gateways, got %d", c.expectedNATGateways, natGateways)
+			}
+			if publicIPs != c.expectedPublicIPs {
+				t.Errorf("expected %d public IP addresses, got %d", c.expectedPublicIPs, publicIPs)
+			}
+		})
+	}
+}
@@ -190,3 +190,27 @@ type DeploymentARM struct {
 	DeploymentARMResource
 	resources.DeploymentExtended
 }
+
+// PrivateDNSZoneARM embeds the ARMResource type in PrivateDNSZone.
+type PrivateDNSZoneARM struct {
+	ARMResource
+	PrivateDNSZone
+}
+
+// PrivateDNSZoneRecordSetARM embeds the ARMResource type in PrivateDNSZoneRecordSet.
+type PrivateDNSZoneRecordSetARM struct {
+	ARMResource
+	PrivateDNSZoneRecordSet
+}
+
+// PrivateDNSZoneVirtualNetworkLinkARM embeds the ARMResource type in PrivateDNSZoneVirtualNetworkLink.
+type PrivateDNSZoneVirtualNetworkLinkARM struct {
+	ARMResource
+	PrivateDNSZoneVirtualNetworkLink
+}
+
+// NATGatewayARM embeds the ARMResource type in NATGateway.
+type NATGatewayARM struct {
+	ARMResource
+	NATGateway
+}
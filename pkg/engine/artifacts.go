@@ -62,6 +62,12 @@ func kubernetesContainerAddonSettingsInit(p *api.Properties) map[string]kubernet
 			destinationFile: "aci-connector-deployment.yaml",
 			isEnabled:       k.IsAddonEnabled(ACIConnectorAddonName),
 		},
+		GMSAWebhookAddonName: {
+			sourceFile:      "kubernetesmasteraddons-gmsa-webhook-deployment.yaml",
+			base64Data:      k.GetAddonScript(GMSAWebhookAddonName),
+			destinationFile: "gmsa-webhook-deployment.yaml",
+			isEnabled:       k.IsAddonEnabled(GMSAWebhookAddonName),
+		},
 		ClusterAutoscalerAddonName: {
 			sourceFile:      "kubernetesmasteraddons-cluster-autoscaler-deployment.yaml",
 			base64Data:      k.GetAddonScript(ClusterAutoscalerAddonName),
@@ -0,0 +1,1184 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+)
+
+// isDCOSBootstrapCluster returns true if the DCOS cluster is installed from a bootstrap node
+func isDCOSBootstrapCluster(cs *api.ContainerService) bool {
+	dcosConfig := cs.Properties.OrchestratorProfile.DcosConfig
+	return dcosConfig != nil && dcosConfig.BootstrapProfile != nil
+}
+
+// getDCOSTemplate returns the ARM template of a DCOS cluster
+func getDCOSTemplate(cs *api.ContainerService) ARMTemplate {
+	return ARMTemplate{
+		Schema:         armTemplateSchema,
+		ContentVersion: armTemplateContentVersion,
+		Parameters:     getDCOSParameters(cs),
+		Variables:      getDCOSVariables(cs),
+		Resources:      getDCOSResources(cs),
+		Outputs:        getIaaSOutputs(cs),
+	}
+}
+
+// getDCOSVariables returns the variables of the ARM template of a DCOS cluster
+func getDCOSVariables(cs *api.ContainerService) map[string]interface{} {
+	dcosVars := map[string]interface{}{}
+	if !isDCOSBootstrapCluster(cs) {
+		dcosVars["dcosRepositoryURL"] = "[parameters('dcosRepositoryURL')]"
+		dcosVars["dcosClusterPackageListID"] = "[parameters('dcosClusterPackageListID')]"
+		dcosVars["dcosProviderPackageID"] = "[parameters('dcosProviderPackageID')]"
+	}
+	for i, profile := range cs.Properties.AgentPoolProfiles {
+		dcosVars[profile.Name+"Index"] = i
+		for k, v := range getDCOSAgentVars(cs, profile) {
+			dcosVars[k] = v
+		}
+		for k, v := range getIaaSAgentPoolIndexVariables(profile, i) {
+			dcosVars[k] = v
+		}
+	}
+	for k, v := range getDCOSMasterVars(cs) {
+		dcosVars[k] = v
+	}
+	return dcosVars
+}
+
+func getDCOSAgentVars(cs *api.ContainerService, profile *api.AgentPoolProfile) map[string]interface{} {
+	agentName := profile.Name
+	agentVars := map[string]interface{}{
+		agentName + "Count":   fmt.Sprintf("[parameters('%sCount')]", agentName),
+		agentName + "NSGID":   fmt.Sprintf("[resourceId('Microsoft.Network/networkSecurityGroups',variables('%sNSGName'))]", agentName),
+		agentName + "NSGName": fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-nsg-', variables('nameSuffix'))]", agentName),
+	}
+
+	if profile.IsWindows() {
+		preprovisionParameters := ""
+		if profile.PreprovisionExtension != nil {
+			preprovisionParameters = getDCOSWindowsAgentPreprovisionParameters(cs, profile)
+		}
+		agentVars["winResourceNamePrefix"] = "[substring(variables('nameSuffix'), 0, 5)]"
+		agentVars[agentName+"windowsAgentCustomAttributes"] = fmt.Sprintf("[concat(' -customAttrs ', variables('doubleSingleQuote'), '%s', variables('doubleSingleQuote') )]", getDCOSWindowsAgentCustomAttributes(profile))
+		if common.SliceIntIsNonEmpty(profile.Ports) {
+			agentVars[agentName+"VMNamePrefix"] = fmt.Sprintf("[concat('wp', variables('winResourceNamePrefix'), add(900,variables('%sIndex')))]", agentName)
+			agentVars[agentName+"windowsAgentCustomScriptArguments"] = fmt.Sprintf("[concat('$arguments = ', variables('singleQuote'), '-subnet ', variables('%[1]sSubnet'), ' -MasterCount ', variables('masterCount'), ' -firstMasterIP ', parameters('firstConsecutiveStaticIP'), ' -bootstrapUri ', '\"', variables('dcosWindowsBootstrapURL'), '\"', ' -isAgent $true -isPublic $true ',  variables('%[1]swindowsAgentCustomAttributes'), ' -preprovisionExtensionParams ', variables('doubleSingleQuote'), '%[2]s', variables('doubleSingleQuote'),  variables('singleQuote'), ' ; ')]", agentName, preprovisionParameters)
+		} else {
+			agentVars[agentName+"VMNamePrefix"] = fmt.Sprintf("[concat('w', variables('winResourceNamePrefix'), add(900,variables('%sIndex')))]", agentName)
+			agentVars[agentName+"windowsAgentCustomScriptArguments"] = fmt.Sprintf("[concat('$arguments = ', variables('singleQuote'), '-subnet ', variables('%[1]sSubnet'), ' -MasterCount ', variables('masterCount'), ' -firstMasterIP ', parameters('firstConsecutiveStaticIP'), ' -bootstrapUri ', '\"', variables('dcosWindowsBootstrapURL'), '\"', ' -isAgent $true -isPublic $false ',  variables('%[1]swindowsAgentCustomAttributes'), ' -preprovisionExtensionParams ', variables('doubleSingleQuote'), '%[2]s', variables('doubleSingleQuote'), variables('singleQuote'), ' ; ')]", agentName, preprovisionParameters)
+		}
+		agentVars[agentName+"windowsAgentCustomScript"] = fmt.Sprintf("[concat('powershell.exe -ExecutionPolicy Unrestricted -command \"', variables('%swindowsAgentCustomScriptArguments'), variables('windowsCustomScriptSuffix'), '\" > %%SYSTEMDRIVE%%\\AzureData\\dcosWindowsProvision.log 2>&1; exit $LASTEXITCODE')]", agentName)
+	} else {
+		agentVars[agentName+"VMNamePrefix"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-', variables('nameSuffix'), '-')]", agentName)
+	}
+
+	for k, v := range getIaaSAgentPoolVariables(profile, "Subnet") {
+		agentVars[k] = v
+	}
+	for k, v := range getDCOSRegistryVars(cs) {
+		agentVars[k] = v
+	}
+	return agentVars
+}
+
+func getDCOSRegistryVars(cs *api.ContainerService) map[string]interface{} {
+	dcosConfig := cs.Properties.OrchestratorProfile.DcosConfig
+	if dcosConfig != nil && dcosConfig.HasPrivateRegistry() {
+		return map[string]interface{}{
+			"registry":    "[tolower(parameters('registry'))]",
+			"registryKey": "[parameters('registryKey')]",
+		}
+	}
+	return map[string]interface{}{
+		"registry":    "",
+		"registryKey": "",
+	}
+}
+
+func getDCOSMasterVars(cs *api.ContainerService) map[string]interface{} {
+	properties := cs.Properties
+	masterProfile := properties.MasterProfile
+
+	masterVars := map[string]interface{}{
+		"adminUsername":         "[parameters('linuxAdminUsername')]",
+		"targetEnvironment":     "[parameters('targetEnvironment')]",
+		"maxVMsPerPool":         100,
+		"apiVersionDefault":     "2016-03-30",
+		"apiVersionLinkDefault": "2015-01-01",
+		"singleQuote":           "'",
+		"doubleSingleQuote":     "''",
+		"orchestratorVersion":   properties.OrchestratorProfile.OrchestratorVersion,
+
+		"masterAvailabilitySet":         "[concat(variables('orchestratorName'), '-master-availabilitySet-', variables('nameSuffix'))]",
+		"masterCount":                   masterProfile.Count,
+		"masterEndpointDNSNamePrefix":   "[tolower(parameters('masterEndpointDNSNamePrefix'))]",
+		"masterHttpSourceAddressPrefix": masterProfile.HTTPSourceAddressPrefix,
+		"masterLbBackendPoolName":       "[concat(variables('orchestratorName'), '-master-pool-', variables('nameSuffix'))]",
+		"masterLbID":                    "[resourceId('Microsoft.Network/loadBalancers',variables('masterLbName'))]",
+		"masterLbIPConfigID":            "[concat(variables('masterLbID'),'/frontendIPConfigurations/', variables('masterLbIPConfigName'))]",
+		"masterLbIPConfigName":          "[concat(variables('orchestratorName'), '-master-lbFrontEnd-', variables('nameSuffix'))]",
+		"masterLbName":                  "[concat(variables('orchestratorName'), '-master-lb-', variables('nameSuffix'))]",
+		"masterNSGID":                   "[resourceId('Microsoft.Network/networkSecurityGroups',variables('masterNSGName'))]",
+		"masterNSGName":                 "[concat(variables('orchestratorName'), '-master-nsg-', variables('nameSuffix'))]",
+		"masterPublicIPAddressName":     "[concat(variables('orchestratorName'), '-master-ip-', variables('masterEndpointDNSNamePrefix'), '-', variables('nameSuffix'))]",
+		"apiVersionStorage":             "2015-06-15",
+
+		"storageAccountBaseName":            "[uniqueString(concat(variables('masterEndpointDNSNamePrefix'),variables('location'),variables('orchestratorName')))]",
+		"masterStorageAccountExhibitorName": "[concat(variables('storageAccountBaseName'), 'exhb0')]",
+		"storageAccountType":                "Standard_LRS",
+
+		"masterVMNamePrefix": "[concat(variables('orchestratorName'), '-master-', variables('nameSuffix'), '-')]",
+		"masterVMSize":       "[parameters('masterVMSize')]",
+		"nameSuffix":         "[parameters('nameSuffix')]",
+		"oauthEnabled":       strconv.FormatBool(masterProfile.OAuthEnabled),
+		"orchestratorName":   "dcos",
+		"osImageOffer":       "[parameters('osImageOffer')]",
+		"osImagePublisher":   "[parameters('osImagePublisher')]",
+		"osImageSKU":         "[parameters('osImageSKU')]",
+		"osImageVersion":     "[parameters('osImageVersion')]",
+		"sshKeyPath":         "[concat('/home/', variables('adminUsername'), '/.ssh/authorized_keys')]",
+		"sshRSAPublicKey":    "[parameters('sshRSAPublicKey')]",
+
+		"masterSshInboundNatRuleIdPrefix": "[concat(variables('masterLbID'),'/inboundNatRules/SSH-',variables('masterVMNamePrefix'))]",
+	}
+
+	masterVMNics := []interface{}{}
+	for i := 0; i < 7; i++ {
+		masterVMNics = append(masterVMNics, fmt.Sprintf("[concat(variables('masterVMNamePrefix'), 'nic-%d')]", i))
+	}
+	masterVars["masterVMNic"] = masterVMNics
+
+	if properties.LinuxProfile.HasSecrets() {
+		masterVars["linuxProfileSecrets"] = getIaaSProfileSecrets(properties.LinuxProfile.Secrets, "linux", false)
+	}
+
+	if properties.HasWindows() {
+		masterVars["windowsAdminUsername"] = "[parameters('windowsAdminUsername')]"
+		masterVars["windowsAdminPassword"] = "[parameters('windowsAdminPassword')]"
+		masterVars["agentWindowsBackendPort"] = 3389
+		masterVars["agentWindowsPublisher"] = "[parameters('agentWindowsPublisher')]"
+		masterVars["agentWindowsOffer"] = "[parameters('agentWindowsOffer')]"
+		masterVars["agentWindowsSku"] = "[parameters('agentWindowsSku')]"
+		masterVars["agentWindowsVersion"] = "[parameters('agentWindowsVersion')]"
+		masterVars["dcosWindowsBootstrapURL"] = "[parameters('dcosWindowsBootstrapURL')]"
+		masterVars["windowsCustomScriptSuffix"] = " $inputFile = '%SYSTEMDRIVE%\\AzureData\\CustomData.bin' ; $outputFile = '%SYSTEMDRIVE%\\AzureData\\dcosWindowsProvision.ps1' ; $inputStream = New-Object System.IO.FileStream $inputFile, ([IO.FileMode]::Open), ([IO.FileAccess]::Read), ([IO.FileShare]::Read) ; $sr = New-Object System.IO.StreamReader(New-Object System.IO.Compression.GZipStream($inputStream, [System.IO.Compression.CompressionMode]::Decompress)) ; $sr.ReadToEnd() | Out-File($outputFile) ; Invoke-Expression('{0} {1}' -f $outputFile, $arguments) ; "
+		masterVars["windowsMasterCustomScriptArguments"] = "[concat('$arguments = ', variables('singleQuote'),'-MasterCount ', variables('masterCount'), ' -firstMasterIP ', parameters('firstConsecutiveStaticIP'), variables('singleQuote'), ' ; ')]"
+		masterVars["windowsMasterCustomScript"] = "[concat('powershell.exe -ExecutionPolicy Unrestricted -command \"', variables('windowsMasterCustomScriptArguments'), variables('windowsCustomScriptSuffix'), '\" > %SYSTEMDRIVE%\\AzureData\\dcosWindowsProvision.log 2>&1')]"
+	}
+
+	if properties.HasStorageAccountDisks() {
+		for k, v := range getIaaSStorageAccountsVariables() {
+			masterVars[k] = v
+		}
+	} else {
+		masterVars["storageAccountPrefixes"] = []interface{}{}
+	}
+	if properties.HasManagedDisks() {
+		masterVars["apiVersionStorageManagedDisks"] = "2016-04-30-preview"
+	}
+	if masterProfile.IsStorageAccount() {
+		masterVars["masterStorageAccountName"] = "[concat(variables('storageAccountBaseName'), 'mstr0')]"
+	}
+
+	for k, v := range getIaaSMasterVNETVariables(cs) {
+		masterVars[k] = v
+	}
+	for k, v := range getIaaSMasterAddressVariables() {
+		masterVars[k] = v
+	}
+	for k, v := range getIaaSLocationVariables() {
+		masterVars[k] = v
+	}
+
+	if isDCOSBootstrapCluster(cs) {
+		masterVars["masterLbInboundNatRules"] = getIaaSMasterLbInboundNatRules(false)
+		masterVars["dcosBootstrapURL"] = "[parameters('dcosBootstrapURL')]"
+		masterVars["bootstrapVMSize"] = "[parameters('bootstrapVMSize')]"
+		masterVars["bootstrapNSGID"] = "[resourceId('Microsoft.Network/networkSecurityGroups',variables('bootstrapNSGName'))]"
+		masterVars["bootstrapNSGName"] = "[concat('bootstrap-nsg-', variables('nameSuffix'))]"
+		masterVars["bootstrapVMName"] = "[concat('bootstrap-', variables('nameSuffix'))]"
+		masterVars["bootstrapStaticIP"] = "[parameters('bootstrapStaticIP')]"
+	} else {
+		if properties.OrchestratorProfile.IsDCOS19() {
+			masterVars["masterSshPort22InboundNatRuleIdPrefix"] = "[concat(variables('masterLbID'),'/inboundNatRules/SSHPort22-',variables('masterVMNamePrefix'))]"
+			masterVars["masterLbInboundNatRules"] = getIaaSMasterLbInboundNatRules(true)
+		} else {
+			delete(masterVars, "masterSshInboundNatRuleIdPrefix")
+		}
+		masterVars["dcosBootstrapURL"] = "[parameters('dcosBootstrapURL')]"
+	}
+
+	return masterVars
+}
+
+// getDCOSResources returns the resources of the ARM template of a DCOS cluster
+func getDCOSResources(cs *api.ContainerService) []interface{} {
+	resources := []interface{}{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		if profile.IsWindows() {
+			if profile.IsAvailabilitySets() {
+				resources = append(resources, getDCOSWindowsAgentResourcesVMAS(cs, profile)...)
+			} else {
+				resources = append(resources, getDCOSWindowsAgentResourcesVMSS(cs, profile)...)
+			}
+		} else {
+			if profile.IsAvailabilitySets() {
+				resources = append(resources, getDCOSAgentResourcesVMAS(cs, profile)...)
+			} else {
+				resources = append(resources, getDCOSAgentResourcesVMSS(cs, profile)...)
+			}
+		}
+	}
+	if isDCOSBootstrapCluster(cs) {
+		resources = append(resources, getDCOSBootstrapResources(cs)...)
+	}
+	resources = append(resources, getDCOSMasterResources(cs)...)
+	for _, extension := range CreateCustomExtensions(cs.Properties) {
+		resources = append(resources, extension)
+	}
+	return resources
+}
+
+func getDCOSAgentNSG(profile *api.AgentPoolProfile) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[variables('%sNSGName')]", profile.Name),
+		"properties": map[string]interface{}{
+			"securityRules": getIaaSSecurityRules(profile.Ports),
+		},
+		"type": "Microsoft.Network/networkSecurityGroups",
+	}
+}
+
+func getDCOSWindowsCustomImage(profile *api.AgentPoolProfile) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "Microsoft.Compute/images",
+		"apiVersion": "2017-12-01",
+		"name":       profile.Name + "CustomWindowsImage",
+		"location":   "[variables('location')]",
+		"properties": map[string]interface{}{
+			"storageProfile": map[string]interface{}{
+				"osDisk": map[string]interface{}{
+					"osType":             "Windows",
+					"osState":            "Generalized",
+					"blobUri":            "[parameters('agentWindowsSourceUrl')]",
+					"storageAccountType": "Standard_LRS",
+				},
+			},
+		},
+	}
+}
+
+func getDCOSAgentTags(creationSource string) map[string]interface{} {
+	return map[string]interface{}{
+		"creationSource":      creationSource,
+		"orchestratorName":    "dcos",
+		"orchestratorVersion": "[variables('orchestratorVersion')]",
+		"orchestratorNode":    "agent",
+	}
+}
+
+func getDCOSLinuxConfiguration(keyData string) map[string]interface{} {
+	return map[string]interface{}{
+		"disablePasswordAuthentication": true,
+		"ssh": map[string]interface{}{
+			"publicKeys": []interface{}{
+				map[string]interface{}{
+					"keyData": keyData,
+					"path":    "[variables('sshKeyPath')]",
+				},
+			},
+		},
+	}
+}
+
+func getDCOSStorageAPIVersion(managedDisks bool) string {
+	if managedDisks {
+		return "[variables('apiVersionStorageManagedDisks')]"
+	}
+	return "[variables('apiVersionDefault')]"
+}
+
+// getDCOSAgentResourcesVMAS returns the resources of a Linux agent pool of availability sets
+func getDCOSAgentResourcesVMAS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	resources := []interface{}{getDCOSAgentNSG(profile)}
+	resources = append(resources, getDCOSAgentNICVMAS(profile))
+	resources = append(resources, getDCOSAgentAvailabilitySetResources(profile)...)
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		resources = append(resources, getIaaSAgentPublicResources(profile, false)...)
+	}
+
+	agentName := profile.Name
+	dependencies := getIaaSAgentVMStorageAccountDependencies(profile)
+	if isDCOSBootstrapCluster(cs) {
+		// the text template rendered this dependency without its opening quote
+		dependencies = append(dependencies, "[concat('Microsoft.Compute/virtualMachines/', variables('bootstrapVMName'), /extensions/bootstrapready')]")
+	}
+	osProfile := map[string]interface{}{
+		"adminUsername":      "[variables('adminUsername')]",
+		"computername":       fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		"customData":         getCustomDataFromFragment(getDCOSAgentCustomData(cs, profile)),
+		"linuxConfiguration": getDCOSLinuxConfiguration("[parameters('sshRSAPublicKey')]"),
+	}
+	if cs.Properties.LinuxProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+	}
+	storageProfile := map[string]interface{}{
+		"imageReference": map[string]interface{}{
+			"offer":     "[variables('osImageOffer')]",
+			"publisher": "[variables('osImagePublisher')]",
+			"sku":       "[variables('osImageSKU')]",
+			"version":   "[variables('osImageVersion')]",
+		},
+		"osDisk": getIaaSAgentVMOSDisk(profile),
+	}
+	if dataDisks := getIaaSDataDisks(profile); dataDisks != nil {
+		storageProfile["dataDisks"] = dataDisks
+	}
+	vm := getDCOSAgentVMVMAS(profile, dependencies, osProfile, storageProfile)
+	return append(resources, vm)
+}
+
+// getDCOSWindowsAgentResourcesVMAS returns the resources of a Windows agent pool of availability sets
+func getDCOSWindowsAgentResourcesVMAS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	resources := []interface{}{getDCOSAgentNSG(profile)}
+	if cs.Properties.WindowsProfile.HasCustomImage() {
+		resources = append(resources, getDCOSWindowsCustomImage(profile))
+	}
+	resources = append(resources, getDCOSAgentNICVMAS(profile))
+	resources = append(resources, getDCOSAgentAvailabilitySetResources(profile)...)
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		resources = append(resources, getIaaSAgentPublicResources(profile, true)...)
+	}
+
+	agentName := profile.Name
+	osProfile := map[string]interface{}{
+		"computername":  fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		"adminUsername": "[variables('windowsAdminUsername')]",
+		"adminPassword": "[variables('windowsAdminPassword')]",
+		"customData":    getCustomDataFromFragment(getDCOSWindowsAgentCustomData(cs, profile)),
+	}
+	storageProfile := map[string]interface{}{
+		"imageReference": getDCOSWindowsImageReference(cs, profile, "[variables('agentWindowsSKU')]", "[variables('agentWindowsVersion')]"),
+		"osDisk":         getIaaSAgentVMOSDisk(profile),
+	}
+	if dataDisks := getIaaSDataDisks(profile); dataDisks != nil {
+		storageProfile["dataDisks"] = dataDisks
+	}
+	vm := getDCOSAgentVMVMAS(profile, getIaaSAgentVMStorageAccountDependencies(profile), osProfile, storageProfile)
+	cse := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": fmt.Sprintf("[sub(variables('%[1]sCount'), variables('%[1]sOffset'))]", agentName),
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": []interface{}{
+			fmt.Sprintf("[concat('Microsoft.Compute/virtualMachines/', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		},
+		"location": "[variables('location')]",
+		"name":     fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')), '/cse')]", agentName),
+		"properties": map[string]interface{}{
+			"publisher":               "Microsoft.Compute",
+			"type":                    "CustomScriptExtension",
+			"typeHandlerVersion":      "1.8",
+			"autoUpgradeMinorVersion": true,
+			"settings": map[string]interface{}{
+				"commandToExecute": fmt.Sprintf("[variables('%swindowsAgentCustomScript')]", agentName),
+			},
+		},
+		"type": "Microsoft.Compute/virtualMachines/extensions",
+	}
+	return append(resources, vm, cse)
+}
+
+func getDCOSWindowsImageReference(cs *api.ContainerService, profile *api.AgentPoolProfile, sku, version string) map[string]interface{} {
+	if cs.Properties.WindowsProfile.HasCustomImage() {
+		return map[string]interface{}{
+			"id": fmt.Sprintf("[resourceId('Microsoft.Compute/images','%sCustomWindowsImage')]", profile.Name),
+		}
+	}
+	return map[string]interface{}{
+		"offer":     "[variables('agentWindowsOffer')]",
+		"publisher": "[variables('agentWindowsPublisher')]",
+		"sku":       sku,
+		"version":   version,
+	}
+}
+
+func getDCOSAgentNICVMAS(profile *api.AgentPoolProfile) map[string]interface{} {
+	agentName := profile.Name
+	dependencies := []interface{}{}
+	nicProperties := map[string]interface{}{}
+	if profile.IsCustomVNET() {
+		dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Network/networkSecurityGroups/', variables('%sNSGName'))]", agentName))
+		nicProperties["networkSecurityGroup"] = map[string]interface{}{
+			"id": fmt.Sprintf("[resourceId('Microsoft.Network/networkSecurityGroups/', variables('%sNSGName'))]", agentName),
+		}
+	} else {
+		dependencies = append(dependencies, "[variables('vnetID')]")
+	}
+	ipConfigProperties := map[string]interface{}{
+		"privateIPAllocationMethod": "Dynamic",
+		"subnet": map[string]interface{}{
+			"id": fmt.Sprintf("[variables('%sVnetSubnetID')]", agentName),
+		},
+	}
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		dependencies = append(dependencies, fmt.Sprintf("[variables('%sLbID')]", agentName))
+		ipConfigProperties["loadBalancerBackendAddressPools"] = getIaaSAgentBackendPools(agentName)
+		if profile.IsWindows() {
+			ipConfigProperties["loadBalancerInboundNatPools"] = getIaaSAgentRDPNatPools(agentName)
+		}
+	}
+	nicProperties["ipConfigurations"] = []interface{}{
+		map[string]interface{}{
+			"name":       "ipConfigNode",
+			"properties": ipConfigProperties,
+		},
+	}
+	return map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": fmt.Sprintf("[sub(variables('%[1]sCount'), variables('%[1]sOffset'))]", agentName),
+			"name":  "loop",
+		},
+		"dependsOn":  dependencies,
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), 'nic-', copyIndex(variables('%[1]sOffset')))]", agentName),
+		"properties": nicProperties,
+		"type":       "Microsoft.Network/networkInterfaces",
+	}
+}
+
+func getDCOSAgentAvailabilitySetResources(profile *api.AgentPoolProfile) []interface{} {
+	if profile.IsManagedDisks() {
+		return []interface{}{getIaaSAvailabilitySet(profile.Name+"AvailabilitySet", true)}
+	} else if profile.IsStorageAccount() {
+		return append(getIaaSAgentStorageAccounts(profile, "loop"), getIaaSAvailabilitySet(profile.Name+"AvailabilitySet", false))
+	}
+	return nil
+}
+
+func getDCOSAgentVMVMAS(profile *api.AgentPoolProfile, dependencies []interface{}, osProfile, storageProfile map[string]interface{}) map[string]interface{} {
+	agentName := profile.Name
+	return map[string]interface{}{
+		"apiVersion": getDCOSStorageAPIVersion(profile.IsManagedDisks()),
+		"copy": map[string]interface{}{
+			"count": fmt.Sprintf("[sub(variables('%[1]sCount'), variables('%[1]sOffset'))]", agentName),
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": dependencies,
+		"tags":      getDCOSAgentTags(fmt.Sprintf("[concat('acsengine-', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName)),
+		"location":  "[variables('location')]",
+		"name":      fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		"properties": map[string]interface{}{
+			"availabilitySet": map[string]interface{}{
+				"id": fmt.Sprintf("[resourceId('Microsoft.Compute/availabilitySets',variables('%sAvailabilitySet'))]", agentName),
+			},
+			"hardwareProfile": map[string]interface{}{
+				"vmSize": fmt.Sprintf("[variables('%sVMSize')]", agentName),
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{
+						"id": fmt.Sprintf("[resourceId('Microsoft.Network/networkInterfaces',concat(variables('%[1]sVMNamePrefix'), 'nic-', copyIndex(variables('%[1]sOffset'))))]", agentName),
+					},
+				},
+			},
+			"osProfile":      osProfile,
+			"storageProfile": storageProfile,
+		},
+		"type": "Microsoft.Compute/virtualMachines",
+	}
+}
+
+// getDCOSAgentResourcesVMSS returns the resources of a Linux agent pool of scale sets
+func getDCOSAgentResourcesVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	resources := []interface{}{getDCOSAgentNSG(profile)}
+	resources = append(resources, getDCOSAgentVMSSResources(cs, profile)...)
+	return resources
+}
+
+// getDCOSWindowsAgentResourcesVMSS returns the resources of a Windows agent pool of scale sets
+func getDCOSWindowsAgentResourcesVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	resources := []interface{}{getDCOSAgentNSG(profile)}
+	if cs.Properties.WindowsProfile.HasCustomImage() {
+		resources = append(resources, getDCOSWindowsCustomImage(profile))
+	}
+	resources = append(resources, getDCOSAgentVMSSResources(cs, profile)...)
+	return resources
+}
+
+func getDCOSAgentVMSSResources(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	agentName := profile.Name
+	resources := []interface{}{}
+	if profile.IsStorageAccount() {
+		resources = append(resources, getIaaSAgentStorageAccounts(profile, "loop")...)
+	}
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		resources = append(resources, getIaaSAgentPublicResources(profile, false)...)
+	}
+
+	dependencies := []interface{}{}
+	nicProperties := map[string]interface{}{}
+	if profile.IsCustomVNET() {
+		dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Network/networkSecurityGroups/', variables('%sNSGName'))]", agentName))
+		nicProperties["networkSecurityGroup"] = map[string]interface{}{
+			"id": fmt.Sprintf("[resourceId('Microsoft.Network/networkSecurityGroups/', variables('%sNSGName'))]", agentName),
+		}
+	} else {
+		dependencies = append(dependencies, "[variables('vnetID')]")
+	}
+	osDisk := map[string]interface{}{
+		"caching":      "ReadOnly",
+		"createOption": "FromImage",
+	}
+	if profile.IsStorageAccount() {
+		vhdContainers := []interface{}{}
+		for i := 0; i < 5; i++ {
+			accountNameArgs := getIaaSAgentStorageAccountNameArgs(agentName, strconv.Itoa(i), agentName+"AccountName")
+			dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Storage/storageAccounts/',%s)]", accountNameArgs))
+			vhdContainers = append(vhdContainers, fmt.Sprintf("[concat(reference(concat('Microsoft.Storage/storageAccounts/',%s),variables('apiVersionStorage')).primaryEndpoints.blob,'osdisk')]", accountNameArgs))
+		}
+		osDisk["name"] = "vmssosdisk"
+		osDisk["vhdContainers"] = vhdContainers
+	}
+	if profile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = profile.OSDiskSizeGB
+	}
+	ipConfigProperties := map[string]interface{}{
+		"subnet": map[string]interface{}{
+			"id": fmt.Sprintf("[variables('%sVnetSubnetID')]", agentName),
+		},
+	}
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Network/loadBalancers/', variables('%sLbName'))]", agentName))
+		ipConfigProperties["loadBalancerBackendAddressPools"] = getIaaSAgentBackendPools(agentName)
+	}
+	nicProperties["ipConfigurations"] = []interface{}{
+		map[string]interface{}{
+			"name":       "nicipconfig",
+			"properties": ipConfigProperties,
+		},
+	}
+	nicProperties["primary"] = "true"
+
+	storageProfile := map[string]interface{}{
+		"osDisk": osDisk,
+	}
+	if dataDisks := getIaaSDataDisks(profile); dataDisks != nil {
+		storageProfile["dataDisks"] = dataDisks
+	}
+	vmProfile := map[string]interface{}{
+		"networkProfile": map[string]interface{}{
+			"networkInterfaceConfigurations": []interface{}{
+				map[string]interface{}{
+					"name":       "nic",
+					"properties": nicProperties,
+				},
+			},
+		},
+		"storageProfile": storageProfile,
+	}
+
+	nameSuffix := "vmss"
+	if profile.IsWindows() {
+		nameSuffix = "-vmss"
+		osProfile := map[string]interface{}{
+			"computerNamePrefix": "[concat(substring(variables('nameSuffix'), 0, 5), 'acs')]",
+			"adminUsername":      "[variables('windowsAdminUsername')]",
+			"adminPassword":      "[variables('windowsAdminPassword')]",
+			"customData":         getCustomDataFromFragment(getDCOSWindowsAgentCustomData(cs, profile)),
+		}
+		if cs.Properties.WindowsProfile.HasSecrets() {
+			osProfile["secrets"] = "[variables('windowsProfileSecrets')]"
+		}
+		vmProfile["osProfile"] = osProfile
+		storageProfile["imageReference"] = getDCOSWindowsImageReference(cs, profile, "[variables('agentWindowsSku')]", "latest")
+		vmProfile["extensionProfile"] = map[string]interface{}{
+			"extensions": []interface{}{
+				map[string]interface{}{
+					"name": "vmssCustomScriptExtension",
+					"properties": map[string]interface{}{
+						"publisher":               "Microsoft.Compute",
+						"type":                    "CustomScriptExtension",
+						"typeHandlerVersion":      "1.8",
+						"autoUpgradeMinorVersion": true,
+						"settings": map[string]interface{}{
+							"commandToExecute": fmt.Sprintf("[variables('%swindowsAgentCustomScript')]", agentName),
+						},
+					},
+				},
+			},
+		}
+	} else {
+		if isDCOSBootstrapCluster(cs) {
+			dependencies = append(dependencies, "[concat('Microsoft.Compute/virtualMachines/', variables('bootstrapVMName'), '/extensions/bootstrapready')]")
+		}
+		osProfile := map[string]interface{}{
+			"adminUsername":      "[variables('adminUsername')]",
+			"computerNamePrefix": fmt.Sprintf("[variables('%sVMNamePrefix')]", agentName),
+			"customData":         getCustomDataFromFragment(getDCOSAgentCustomData(cs, profile)),
+			"linuxConfiguration": getDCOSLinuxConfiguration("[parameters('sshRSAPublicKey')]"),
+		}
+		if cs.Properties.LinuxProfile.HasSecrets() {
+			osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+		}
+		vmProfile["osProfile"] = osProfile
+		storageProfile["imageReference"] = map[string]interface{}{
+			"offer":     "[variables('osImageOffer')]",
+			"publisher": "[variables('osImagePublisher')]",
+			"sku":       "[variables('osImageSKU')]",
+			"version":   "[variables('osImageVersion')]",
+		}
+	}
+
+	vmss := map[string]interface{}{
+		"apiVersion": getDCOSStorageAPIVersion(profile.IsManagedDisks()),
+		"dependsOn":  dependencies,
+		"tags":       getDCOSAgentTags(fmt.Sprintf("[concat('acsengine-', variables('%sVMNamePrefix'), '%s')]", agentName, nameSuffix)),
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[concat(variables('%sVMNamePrefix'), '%s')]", agentName, nameSuffix),
+		"properties": map[string]interface{}{
+			"overprovision": false,
+			"upgradePolicy": map[string]interface{}{
+				"mode": "Manual",
+			},
+			"virtualMachineProfile": vmProfile,
+		},
+		"sku": map[string]interface{}{
+			"capacity": fmt.Sprintf("[variables('%sCount')]", agentName),
+			"name":     fmt.Sprintf("[variables('%sVMSize')]", agentName),
+			"tier":     fmt.Sprintf("[variables('%sVMSizeTier')]", agentName),
+		},
+		"type": "Microsoft.Compute/virtualMachineScaleSets",
+	}
+	return append(resources, vmss)
+}
+
+func getDCOSSSHSecurityRule() map[string]interface{} {
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"priority":                 200,
+			"access":                   "Allow",
+			"direction":                "Inbound",
+			"destinationPortRange":     "22",
+			"sourcePortRange":          "*",
+			"destinationAddressPrefix": "*",
+			"protocol":                 "Tcp",
+			"description":              "Allow SSH",
+			"sourceAddressPrefix":      "*",
+		},
+		"name": "ssh",
+	}
+}
+
+// getDCOSMasterResources returns the resources of the masters of a DCOS cluster
+func getDCOSMasterResources(cs *api.ContainerService) []interface{} {
+	properties := cs.Properties
+	masterProfile := properties.MasterProfile
+	isDCOS19 := properties.OrchestratorProfile.IsDCOS19() && !isDCOSBootstrapCluster(cs)
+
+	resources := []interface{}{}
+	if masterProfile.IsManagedDisks() {
+		resources = append(resources, getIaaSAvailabilitySet("masterAvailabilitySet", true))
+	} else if masterProfile.IsStorageAccount() {
+		resources = append(resources, map[string]interface{}{
+			"apiVersion": "[variables('apiVersionStorage')]",
+			"dependsOn": []interface{}{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+			},
+			"location": "[variables('location')]",
+			"name":     "[variables('masterStorageAccountName')]",
+			"properties": map[string]interface{}{
+				"accountType": "[variables('vmSizesMap')[variables('masterVMSize')].storageAccountType]",
+			},
+			"type": "Microsoft.Storage/storageAccounts",
+		}, getIaaSAvailabilitySet("masterAvailabilitySet", false))
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionStorage')]",
+		"dependsOn": []interface{}{
+			"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+		},
+		"location": "[variables('location')]",
+		"name":     "[variables('masterStorageAccountExhibitorName')]",
+		"properties": map[string]interface{}{
+			"accountType": "Standard_LRS",
+		},
+		"type": "Microsoft.Storage/storageAccounts",
+	})
+	if !masterProfile.IsCustomVNET() {
+		resources = append(resources, getIaaSVirtualNetwork(cs, true))
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       "[variables('masterPublicIPAddressName')]",
+		"properties": map[string]interface{}{
+			"dnsSettings": map[string]interface{}{
+				"domainNameLabel": "[variables('masterEndpointDNSNamePrefix')]",
+			},
+			"publicIPAllocationMethod": "Dynamic",
+		},
+		"type": "Microsoft.Network/publicIPAddresses",
+	})
+
+	lbProperties := map[string]interface{}{
+		"backendAddressPools": []interface{}{
+			map[string]interface{}{
+				"name": "[variables('masterLbBackendPoolName')]",
+			},
+		},
+		"frontendIPConfigurations": []interface{}{
+			map[string]interface{}{
+				"name": "[variables('masterLbIPConfigName')]",
+				"properties": map[string]interface{}{
+					"publicIPAddress": map[string]interface{}{
+						"id": "[resourceId('Microsoft.Network/publicIPAddresses',variables('masterPublicIPAddressName'))]",
+					},
+				},
+			},
+		},
+	}
+	if masterProfile.OAuthEnabled {
+		lbRules := []interface{}{}
+		for _, port := range []int{443, 80} {
+			lbRules = append(lbRules, map[string]interface{}{
+				"name": fmt.Sprintf("LBRule%d", port),
+				"properties": map[string]interface{}{
+					"frontendIPConfiguration": map[string]interface{}{
+						"id": "[variables('masterLbIPConfigID')]",
+					},
+					"frontendPort":         port,
+					"backendPort":          port,
+					"enableFloatingIP":     false,
+					"idleTimeoutInMinutes": 4,
+					"protocol":             "Tcp",
+					"loadDistribution":     "Default",
+					"backendAddressPool": map[string]interface{}{
+						"id": "[concat(variables('masterLbID'), '/backendAddressPools/', variables('masterLbBackendPoolName'))]",
+					},
+					"probe": map[string]interface{}{
+						"id": "[concat(variables('masterLbID'),'/probes/dcosMasterProbe')]",
+					},
+				},
+			})
+		}
+		lbProperties["loadBalancingRules"] = lbRules
+		lbProperties["probes"] = []interface{}{
+			map[string]interface{}{
+				"name": "dcosMasterProbe",
+				"properties": map[string]interface{}{
+					"protocol":          "Http",
+					"port":              5050,
+					"requestPath":       "/health",
+					"intervalInSeconds": 5,
+					"numberOfProbes":    2,
+				},
+			},
+		}
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"dependsOn": []interface{}{
+			"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+		},
+		"location":   "[variables('location')]",
+		"name":       "[variables('masterLbName')]",
+		"properties": lbProperties,
+		"type":       "Microsoft.Network/loadBalancers",
+	})
+
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "masterLbLoopNode",
+		},
+		"dependsOn": []interface{}{
+			"[variables('masterLbID')]",
+		},
+		"location": "[variables('location')]",
+		"name":     "[concat(variables('masterLbName'), '/', 'SSH-', variables('masterVMNamePrefix'), copyIndex())]",
+		"properties": map[string]interface{}{
+			"backendPort":      22,
+			"enableFloatingIP": false,
+			"frontendIPConfiguration": map[string]interface{}{
+				"id": "[variables('masterLbIPConfigID')]",
+			},
+			"frontendPort": "[copyIndex(2200)]",
+			"protocol":     "Tcp",
+		},
+		"type": "Microsoft.Network/loadBalancers/inboundNatRules",
+	})
+
+	securityRules := []interface{}{}
+	if isDCOS19 {
+		resources = append(resources, map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"dependsOn": []interface{}{
+				"[variables('masterLbID')]",
+			},
+			"location": "[resourceGroup().location]",
+			"name":     "[concat(variables('masterLbName'), '/', 'SSHPort22-', variables('masterVMNamePrefix'), '0')]",
+			"properties": map[string]interface{}{
+				"backendPort":      2222,
+				"enableFloatingIP": false,
+				"frontendIPConfiguration": map[string]interface{}{
+					"id": "[variables('masterLbIPConfigID')]",
+				},
+				"frontendPort": "22",
+				"protocol":     "Tcp",
+			},
+			"type": "Microsoft.Network/loadBalancers/inboundNatRules",
+		})
+		securityRules = append(securityRules, map[string]interface{}{
+			"properties": map[string]interface{}{
+				"priority":                 201,
+				"access":                   "Allow",
+				"direction":                "Inbound",
+				"destinationPortRange":     "2222",
+				"sourcePortRange":          "*",
+				"destinationAddressPrefix": "*",
+				"protocol":                 "Tcp",
+				"description":              "Allow SSH",
+				"sourceAddressPrefix":      "*",
+			},
+			"name": "sshPort22",
+		})
+		if masterProfile.OAuthEnabled {
+			for i, rule := range []struct {
+				name string
+				port string
+			}{{"http", "80"}, {"https", "443"}} {
+				securityRules = append(securityRules, map[string]interface{}{
+					"name": rule.name,
+					"properties": map[string]interface{}{
+						"protocol":                 "Tcp",
+						"sourcePortRange":          "*",
+						"destinationPortRange":     rule.port,
+						"sourceAddressPrefix":      "[variables('masterHttpSourceAddressPrefix')]",
+						"destinationAddressPrefix": "*",
+						"access":                   "Allow",
+						"priority":                 202 + i,
+						"direction":                "Inbound",
+					},
+				})
+			}
+		}
+	}
+	securityRules = append(securityRules, getDCOSSSHSecurityRule())
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       "[variables('masterNSGName')]",
+		"properties": map[string]interface{}{
+			"securityRules": securityRules,
+		},
+		"type": "Microsoft.Network/networkSecurityGroups",
+	})
+
+	nicDependencies := []interface{}{"[variables('masterNSGID')]"}
+	if !masterProfile.IsCustomVNET() {
+		nicDependencies = append(nicDependencies, "[variables('vnetID')]")
+	}
+	nicDependencies = append(nicDependencies, "[variables('masterLbID')]")
+	if isDCOS19 {
+		nicDependencies = append(nicDependencies, "[concat(variables('masterLbID'),'/inboundNatRules/SSHPort22-',variables('masterVMNamePrefix'),0)]")
+	}
+	nicDependencies = append(nicDependencies, "[concat(variables('masterLbID'),'/inboundNatRules/SSH-',variables('masterVMNamePrefix'),copyIndex())]")
+	var inboundNatRules interface{} = "[variables('masterLbInboundNatRules')[copyIndex()]]"
+	if !isDCOS19 {
+		inboundNatRules = []interface{}{
+			map[string]interface{}{
+				"id": "[concat(variables('masterLbID'),'/inboundNatRules/SSH-',variables('masterVMNamePrefix'),copyIndex())]",
+			},
+		}
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "nicLoopNode",
+		},
+		"dependsOn": nicDependencies,
+		"location":  "[variables('location')]",
+		"name":      "[concat(variables('masterVMNamePrefix'), 'nic-', copyIndex())]",
+		"properties": map[string]interface{}{
+			"ipConfigurations": []interface{}{
+				map[string]interface{}{
+					"name": "ipConfigNode",
+					"properties": map[string]interface{}{
+						"loadBalancerBackendAddressPools": []interface{}{
+							map[string]interface{}{
+								"id": "[concat(variables('masterLbID'), '/backendAddressPools/', variables('masterLbBackendPoolName'))]",
+							},
+						},
+						"loadBalancerInboundNatRules": inboundNatRules,
+						"privateIPAddress":            "[concat(variables('masterFirstAddrPrefix'), copyIndex(int(variables('masterFirstAddrOctet4'))))]",
+						"privateIPAllocationMethod":   "Static",
+						"subnet": map[string]interface{}{
+							"id": "[variables('masterVnetSubnetID')]",
+						},
+					},
+				},
+			},
+			"networkSecurityGroup": map[string]interface{}{
+				"id": "[variables('masterNSGID')]",
+			},
+		},
+		"type": "Microsoft.Network/networkInterfaces",
+	})
+
+	vmDependencies := []interface{}{
+		"[concat('Microsoft.Network/networkInterfaces/', variables('masterVMNamePrefix'), 'nic-', copyIndex())]",
+		"[concat('Microsoft.Compute/availabilitySets/',variables('masterAvailabilitySet'))]",
+	}
+	if masterProfile.IsStorageAccount() {
+		vmDependencies = append(vmDependencies, "[variables('masterStorageAccountName')]")
+	}
+	vmDependencies = append(vmDependencies, "[variables('masterStorageAccountExhibitorName')]")
+	tags := map[string]interface{}{
+		"creationSource": "[concat('acsengine-', variables('masterVMNamePrefix'), copyIndex())]",
+	}
+	if isDCOSBootstrapCluster(cs) {
+		vmDependencies = append(vmDependencies, "[concat('Microsoft.Compute/virtualMachines/', variables('bootstrapVMName'), '/extensions/bootstrapready')]")
+		tags["orchestratorName"] = "dcos"
+		tags["orchestratorVersion"] = "[variables('orchestratorVersion')]"
+		tags["orchestratorNode"] = "master"
+	}
+	osProfile := map[string]interface{}{
+		"adminUsername":      "[variables('adminUsername')]",
+		"computername":       "[concat(variables('masterVMNamePrefix'), copyIndex())]",
+		"customData":         getCustomDataFromFragment(getDCOSMasterCustomData(cs)),
+		"linuxConfiguration": getDCOSLinuxConfiguration("[variables('sshRSAPublicKey')]"),
+	}
+	if properties.LinuxProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+	}
+	osDisk := map[string]interface{}{
+		"caching":      "ReadWrite",
+		"createOption": "FromImage",
+	}
+	if masterProfile.IsStorageAccount() {
+		osDisk["name"] = "[concat(variables('masterVMNamePrefix'), copyIndex(),'-osdisk')]"
+		osDisk["vhd"] = map[string]interface{}{
+			"uri": "[concat(reference(concat('Microsoft.Storage/storageAccounts/',variables('masterStorageAccountName')),variables('apiVersionStorage')).primaryEndpoints.blob,'vhds/',variables('masterVMNamePrefix'),copyIndex(),'-osdisk.vhd')]",
+		}
+	}
+	if masterProfile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = masterProfile.OSDiskSizeGB
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": getDCOSStorageAPIVersion(masterProfile.IsManagedDisks()),
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": vmDependencies,
+		"tags":      tags,
+		"location":  "[variables('location')]",
+		"name":      "[concat(variables('masterVMNamePrefix'), copyIndex())]",
+		"properties": map[string]interface{}{
+			"availabilitySet": map[string]interface{}{
+				"id": "[resourceId('Microsoft.Compute/availabilitySets',variables('masterAvailabilitySet'))]",
+			},
+			"hardwareProfile": map[string]interface{}{
+				"vmSize": "[variables('masterVMSize')]",
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{
+						"id": "[resourceId('Microsoft.Network/networkInterfaces',concat(variables('masterVMNamePrefix'), 'nic-', copyIndex()))]",
+					},
+				},
+			},
+			"osProfile": osProfile,
+			"storageProfile": map[string]interface{}{
+				"imageReference": map[string]interface{}{
+					"offer":     "[variables('osImageOffer')]",
+					"publisher": "[variables('osImagePublisher')]",
+					"sku":       "[variables('osImageSKU')]",
+					"version":   "[variables('osImageVersion')]",
+				},
+				"osDisk": osDisk,
+			},
+		},
+		"type": "Microsoft.Compute/virtualMachines",
+	})
+
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"dependsOn": []interface{}{
+			"[concat('Microsoft.Compute/virtualMachines/', variables('masterVMNamePrefix'), sub(variables('masterCount'), 1))]",
+		},
+		"location": "[variables('location')]",
+		"name":     "[concat(variables('masterVMNamePrefix'), sub(variables('masterCount'), 1), '/waitforleader')]",
+		"properties": map[string]interface{}{
+			"autoUpgradeMinorVersion": true,
+			"publisher":               "Microsoft.OSTCExtensions",
+			"settings": map[string]interface{}{
+				"commandToExecute": "sh -c 'until ping -c1 leader.mesos;do echo waiting for leader.mesos;sleep 15;done;echo leader.mesos up'",
+			},
+			"type":               "CustomScriptForLinux",
+			"typeHandlerVersion": "1.4",
+		},
+		"type": "Microsoft.Compute/virtualMachines/extensions",
+	})
+	return resources
+}
+
+// getDCOSBootstrapResources returns the resources of the bootstrap node of a DCOS cluster
+func getDCOSBootstrapResources(cs *api.ContainerService) []interface{} {
+	properties := cs.Properties
+	masterProfile := properties.MasterProfile
+
+	nsg := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       "[variables('bootstrapNSGName')]",
+		"properties": map[string]interface{}{
+			"securityRules": []interface{}{
+				getDCOSSSHSecurityRule(),
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"priority":                 201,
+						"access":                   "Allow",
+						"direction":                "Inbound",
+						"destinationPortRange":     "8086",
+						"sourcePortRange":          "*",
+						"destinationAddressPrefix": "*",
+						"protocol":                 "Tcp",
+						"description":              "Allow bootstrap service",
+						"sourceAddressPrefix":      "*",
+					},
+					"name": "Port8086",
+				},
+			},
+		},
+		"type": "Microsoft.Network/networkSecurityGroups",
+	}
+
+	nicDependencies := []interface{}{}
+	if !masterProfile.IsCustomVNET() {
+		nicDependencies = append(nicDependencies, "[variables('vnetID')]")
+	}
+	nicDependencies = append(nicDependencies, "[variables('bootstrapNSGID')]")
+	nic := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"dependsOn":  nicDependencies,
+		"location":   "[variables('location')]",
+		"name":       "[concat(variables('bootstrapVMName'), '-nic')]",
+		"properties": map[string]interface{}{
+			"ipConfigurations": []interface{}{
+				map[string]interface{}{
+					"name": "ipConfigNode",
+					"properties": map[string]interface{}{
+						"privateIPAddress":          "[variables('bootstrapStaticIP')]",
+						"privateIPAllocationMethod": "Static",
+						"subnet": map[string]interface{}{
+							"id": "[variables('masterVnetSubnetID')]",
+						},
+					},
+				},
+			},
+			"networkSecurityGroup": map[string]interface{}{
+				"id": "[variables('bootstrapNSGID')]",
+			},
+		},
+		"type": "Microsoft.Network/networkInterfaces",
+	}
+
+	vmDependencies := []interface{}{"[concat('Microsoft.Network/networkInterfaces/', variables('bootstrapVMName'), '-nic')]"}
+	if masterProfile.IsStorageAccount() {
+		vmDependencies = append(vmDependencies, "[variables('masterStorageAccountName')]")
+	}
+	vmDependencies = append(vmDependencies, "[variables('masterStorageAccountExhibitorName')]")
+	osProfile := map[string]interface{}{
+		"adminUsername":      "[variables('adminUsername')]",
+		"computername":       "[variables('bootstrapVMName')]",
+		"customData":         getCustomDataFromFragment(getDCOSBootstrapCustomData(properties)),
+		"linuxConfiguration": getDCOSLinuxConfiguration("[variables('sshRSAPublicKey')]"),
+	}
+	if properties.LinuxProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+	}
+	osDisk := map[string]interface{}{
+		"caching":      "ReadWrite",
+		"createOption": "FromImage",
+	}
+	if masterProfile.IsStorageAccount() {
+		osDisk["name"] = "[concat(variables('bootstrapVMName'), '-osdisk')]"
+		osDisk["vhd"] = map[string]interface{}{
+			// the text template rendered the suffix of the uri without its opening quote
+			"uri": "[concat(reference(concat('Microsoft.Storage/storageAccounts/',variables('masterStorageAccountName')),variables('apiVersionStorage')).primaryEndpoints.blob,'vhds/',variables('bootstrapVMName'),-osdisk.vhd')]",
+		}
+	}
+	if properties.OrchestratorProfile.DcosConfig.BootstrapProfile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = "60"
+	}
+	vm := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionStorageManagedDisks')]",
+		"dependsOn":  vmDependencies,
+		"tags": map[string]interface{}{
+			"creationSource":      "[concat('acsengine-', variables('bootstrapVMName'))]",
+			"orchestratorName":    "dcos",
+			"orchestratorVersion": "[variables('orchestratorVersion')]",
+			"orchestratorNode":    "bootstrap",
+		},
+		"location": "[variables('location')]",
+		"name":     "[variables('bootstrapVMName')]",
+		"properties": map[string]interface{}{
+			"hardwareProfile": map[string]interface{}{
+				"vmSize": "[variables('bootstrapVMSize')]",
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{
+						"id": "[resourceId('Microsoft.Network/networkInterfaces',concat(variables('bootstrapVMName'), '-nic'))]",
+					},
+				},
+			},
+			"osProfile": osProfile,
+			"storageProfile": map[string]interface{}{
+				"imageReference": map[string]interface{}{
+					"offer":     "[variables('osImageOffer')]",
+					"publisher": "[variables('osImagePublisher')]",
+					"sku":       "[variables('osImageSKU')]",
+					"version":   "[variables('osImageVersion')]",
+				},
+				"osDisk": osDisk,
+			},
+		},
+		"type": "Microsoft.Compute/virtualMachines",
+	}
+
+	bootstrapReady := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"dependsOn": []interface{}{
+			"[concat('Microsoft.Compute/virtualMachines/', variables('bootstrapVMName'))]",
+		},
+		"location": "[variables('location')]",
+		"name":     "[concat(variables('bootstrapVMName'), '/bootstrapready')]",
+		"properties": map[string]interface{}{
+			"autoUpgradeMinorVersion": true,
+			"publisher":               "Microsoft.OSTCExtensions",
+			"settings": map[string]interface{}{
+				"commandToExecute": "[concat('/bin/bash -c \"until curl -f http://', variables('bootstrapStaticIP'), ':8086/dcos_install.sh > /dev/null; do echo waiting for bootstrap node; sleep 15; done; echo bootstrap node up\"')]",
+			},
+			"type":               "CustomScriptForLinux",
+			"typeHandlerVersion": "1.4",
+		},
+		"type": "Microsoft.Compute/virtualMachines/extensions",
+	}
+	return []interface{}{nsg, nic, vm, bootstrapReady}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/google/go-cmp/cmp"
+	"github.com/leonelquinteros/gotext"
+)
+
+const (
+	examplesDir               = "../../examples"
+	deterministicGoldenFile   = "./testdata/deterministic/examples.sha256"
+	deterministicExamplesSeed = "examples"
+	// every deterministicRerunInterval-th example is generated a second time
+	deterministicRerunInterval = 8
+)
+
+// generateDeterministicArtifacts generates the artifacts of an api model the way generate --deterministic does,
+// and returns the SHA-256 digest of each artifact keyed by its path in the output directory
+func generateDeterministicArtifacts(t *testing.T, apiloader *api.Apiloader, templateGenerator *TemplateGenerator, entropy *helpers.Entropy, containerService *api.ContainerService, apiVersion string) (map[string]string, error) {
+	// the examples leave the DNS prefix to the user, and it names the generated FQDNs
+	if containerService.Properties.MasterProfile != nil && containerService.Properties.MasterProfile.DNSPrefix == "" {
+		containerService.Properties.MasterProfile.DNSPrefix = "deterministic"
+	}
+
+	certsGenerated, err := containerService.SetPropertiesDefaultsWithEntropy(false, false, entropy)
+	if err != nil {
+		return nil, err
+	}
+	template, parameters, err := templateGenerator.GenerateTemplateV2(containerService, DefaultGeneratorCode, TestAKSEngineVersion)
+	if err != nil {
+		return nil, err
+	}
+	if template, err = transform.PrettyPrintArmTemplate(template); err != nil {
+		return nil, err
+	}
+	if parameters, err = transform.BuildAzureParametersFile(parameters); err != nil {
+		return nil, err
+	}
+
+	outputDirectory, err := ioutil.TempDir("", "deterministic")
+	if err != nil {
+		t.Fatalf("unexpected error creating the output directory: %s", err)
+	}
+	defer os.RemoveAll(outputDirectory)
+	writer := &ArtifactWriter{Translator: apiloader.Translator}
+	if err = writer.WriteTLSArtifacts(containerService, apiVersion, template, parameters, outputDirectory, certsGenerated, false); err != nil {
+		t.Fatalf("unexpected error writing the artifacts: %s", err)
+	}
+
+	// the files of a directory, such as the kubeconfig of every location, are digested together
+	hashes := map[string]hash.Hash{}
+	err = filepath.Walk(outputDirectory, func(artifact string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(artifact)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(outputDirectory, artifact)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := rel
+		if i := strings.Index(rel, "/"); i >= 0 {
+			name = rel[:i+1]
+		}
+		if hashes[name] == nil {
+			hashes[name] = sha256.New()
+		}
+		fmt.Fprintf(hashes[name], "%s\x00%d\x00", rel, len(b))
+		hashes[name].Write(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error reading the artifacts: %s", err)
+	}
+	digests := map[string]string{}
+	for name, h := range hashes {
+		digests[name] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return digests, nil
+}
+
+// TestDeterministicExamples generates every api model under examples with deterministic entropy and checks
+// the artifact digests against the golden file testdata/deterministic/examples.sha256, so that any change to
+// the generated output shows up in review. A sample of the api models is generated twice to check that both
+// runs write byte-identical artifacts. Api models that generate rejects are only recorded as errors in the
+// golden file, so that their messages can change without touching it.
+// Generating every example takes minutes, so the test is skipped with -short.
+func TestDeterministicExamples(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the generation of every example in short mode")
+	}
+	locale := gotext.NewLocale(path.Join("..", "..", "translations"), "en_US")
+	i18n.Initialize(locale)
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: locale,
+		},
+	}
+	templateGenerator, err := InitializeTemplateGenerator(Context{Translator: apiloader.Translator})
+	if err != nil {
+		t.Fatalf("unexpected error initializing the template generator: %s", err)
+	}
+
+	var examples []string
+	err = filepath.Walk(examplesDir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(filename, ".json") && !isARMTemplate(t, filename) {
+			examples = append(examples, filename)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error listing %s: %s", examplesDir, err)
+	}
+
+	// all examples and runs share one entropy, so that each private key is only generated once
+	entropy := helpers.NewDeterministicEntropy([]byte(deterministicExamplesSeed))
+	var lines []string
+	for i, filename := range examples {
+		rel, err := filepath.Rel(examplesDir, filename)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		runCount := 1
+		if i%deterministicRerunInterval == 0 {
+			runCount = 2
+		}
+		var runs []map[string]string
+		for run := 0; run < runCount; run++ {
+			containerService, apiVersion, err := apiloader.LoadContainerServiceFromFile(filename, false, false, nil)
+			if err == nil && !isSelfContained(containerService) {
+				break
+			}
+			var digests map[string]string
+			if err == nil {
+				digests, err = generateDeterministicArtifacts(t, apiloader, templateGenerator, entropy, containerService, apiVersion)
+			}
+			if err != nil {
+				digests = map[string]string{"error": err.Error()}
+			}
+			runs = append(runs, digests)
+		}
+		if len(runs) == 0 {
+			continue
+		}
+		if len(runs) > 1 {
+			if diff := cmp.Diff(runs[0], runs[1]); diff != "" {
+				t.Errorf("generating %s twice wrote different artifacts (-first +second):\n%s", filename, diff)
+			}
+		}
+		if message, ok := runs[0]["error"]; ok {
+			t.Logf("generating %s failed: %s", rel, message)
+			lines = append(lines, fmt.Sprintf("error  %s", rel))
+			continue
+		}
+		for artifact, digest := range runs[0] {
+			lines = append(lines, fmt.Sprintf("%s  %s/%s", digest, rel, artifact))
+		}
+	}
+	sort.Strings(lines)
+	actual := strings.Join(lines, "\n") + "\n"
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(deterministicGoldenFile, []byte(actual), 0644); err != nil {
+			t.Fatalf("unexpected error writing %s: %s", deterministicGoldenFile, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(deterministicGoldenFile)
+	if err != nil {
+		t.Fatalf("unexpected error reading %s, run the tests with -update to create it: %s", deterministicGoldenFile, err)
+	}
+	if diff := cmp.Diff(strings.Split(string(expected), "\n"), strings.Split(actual, "\n")); diff != "" {
+		t.Errorf("generated artifacts differ from %s (-want +got):\n%s", deterministicGoldenFile, diff)
+	}
+}
+
+// isSelfContained returns false for the api models whose output depends on more than their content: Azure Stack
+// reads its endpoints from the metadata service of the stamp, extensions are downloaded from their repository and
+// custom files are read from the machine of the user
+func isSelfContained(containerService *api.ContainerService) bool {
+	p := containerService.Properties
+	if p.IsAzureStackCloud() || len(p.ExtensionProfiles) > 0 {
+		return false
+	}
+	return p.MasterProfile == nil || p.MasterProfile.CustomFiles == nil || len(*p.MasterProfile.CustomFiles) == 0
+}
+
+// isARMTemplate returns true for the ARM templates kept alongside the api models under examples
+func isARMTemplate(t *testing.T, filename string) bool {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error reading %s: %s", filename, err)
+	}
+	return strings.Contains(string(b), "deploymentTemplate.json#")
+}
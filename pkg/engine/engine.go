@@ -43,8 +43,103 @@ func init() {
 	keyvaultSecretPathRe = regexp.MustCompile(`^(/subscriptions/\S+/resourceGroups/\S+/providers/Microsoft.KeyVault/vaults/\S+)/secrets/([^/\s]+)(/(\S+))?$`)
 }
 
+// KubeConfigAuth is how the user of a kubeconfig generated for Azure AD authenticates
+type KubeConfigAuth string
+
+const (
+	// KubeConfigAuthProvider uses the azure auth provider built into kubectl
+	KubeConfigAuthProvider KubeConfigAuth = "auth-provider"
+	// KubeConfigExec uses kubelogin as an exec credential plugin, for kubectl versions without the azure auth provider
+	KubeConfigExec KubeConfigAuth = "exec"
+)
+
+// kubeloginCommand is the exec credential plugin that gets Azure AD tokens, https://github.com/Azure/kubelogin
+const kubeloginCommand = "kubelogin"
+
 // GenerateKubeConfig returns a JSON string representing the KubeConfig
 func GenerateKubeConfig(properties *api.Properties, location string) (string, error) {
+	if properties == nil {
+		return "", errors.New("Properties nil in GenerateKubeConfig")
+	}
+	if properties.CertificateProfile == nil {
+		return "", errors.New("CertificateProfile property may not be nil in GenerateKubeConfig")
+	}
+	if properties.AADProfile == nil {
+		return GenerateAdminKubeConfig(properties, location, properties.CertificateProfile.KubeConfigCertificate, properties.CertificateProfile.KubeConfigPrivateKey)
+	}
+	authInfo, err := getAADAuthInfo(properties, location, KubeConfigAuthProvider)
+	if err != nil {
+		return "", err
+	}
+	return generateKubeConfig(properties, location, "admin", authInfo)
+}
+
+// GenerateAdminKubeConfig returns a JSON string representing a KubeConfig that authenticates with the given
+// PEM encoded client certificate and private key
+func GenerateAdminKubeConfig(properties *api.Properties, location, certificate, privateKey string) (string, error) {
+	return GenerateCertificateKubeConfig(properties, location, "admin", certificate, privateKey)
+}
+
+// GenerateCertificateKubeConfig returns a JSON string representing a KubeConfig for user that authenticates with
+// the given PEM encoded client certificate and private key
+func GenerateCertificateKubeConfig(properties *api.Properties, location, user, certificate, privateKey string) (string, error) {
+	authInfo := fmt.Sprintf("{\"client-certificate-data\":\"%v\",\"client-key-data\":\"%v\"}",
+		base64.StdEncoding.EncodeToString([]byte(certificate)),
+		base64.StdEncoding.EncodeToString([]byte(privateKey)))
+	return generateKubeConfig(properties, location, user, authInfo)
+}
+
+// GenerateUserKubeConfig returns a JSON string representing a KubeConfig for the end users of a cluster, who
+// authenticate with the Azure AD applications of its AADProfile
+func GenerateUserKubeConfig(properties *api.Properties, location string, auth KubeConfigAuth) (string, error) {
+	authInfo, err := getAADAuthInfo(properties, location, auth)
+	if err != nil {
+		return "", err
+	}
+	return generateKubeConfig(properties, location, "user", authInfo)
+}
+
+// getAADAuthInfo returns the user of a KubeConfig that gets Azure AD tokens for the applications of properties.AADProfile
+func getAADAuthInfo(properties *api.Properties, location string, auth KubeConfigAuth) (string, error) {
+	if properties == nil || properties.AADProfile == nil {
+		return "", errors.New("AADProfile property may not be nil to authenticate with Azure AD")
+	}
+	tenantID := properties.AADProfile.TenantID
+	if len(tenantID) == 0 {
+		tenantID = "common"
+	}
+	environment := helpers.GetTargetEnv(location, properties.GetCustomCloudName())
+
+	switch auth {
+	case KubeConfigAuthProvider:
+		return fmt.Sprintf("{\"auth-provider\":{\"name\":\"azure\",\"config\":{\"environment\":\"%v\",\"tenant-id\":\"%v\",\"apiserver-id\":\"%v\",\"client-id\":\"%v\"}}}",
+			environment,
+			tenantID,
+			properties.AADProfile.ServerAppID,
+			properties.AADProfile.ClientAppID), nil
+	case KubeConfigExec:
+		b, err := json.Marshal(map[string]interface{}{
+			"exec": map[string]interface{}{
+				"apiVersion": "client.authentication.k8s.io/v1beta1",
+				"command":    kubeloginCommand,
+				"args": []string{
+					"get-token",
+					"--environment", environment,
+					"--server-id", properties.AADProfile.ServerAppID,
+					"--client-id", properties.AADProfile.ClientAppID,
+					"--tenant-id", tenantID,
+				},
+			},
+		})
+		return string(b), err
+	default:
+		return "", errors.Errorf("unsupported kubeconfig authentication %q", auth)
+	}
+}
+
+// generateKubeConfig returns a JSON string representing a KubeConfig for user, such as admin, that authenticates
+// with authInfo
+func generateKubeConfig(properties *api.Properties, location, user, authInfo string) (string, error) {
 	if properties == nil {
 		return "", errors.New("Properties nil in GenerateKubeConfig")
 	}
@@ -55,10 +150,14 @@ func GenerateKubeConfig(properties *api.Properties, location string) (string, er
 	if err != nil {
 		return "", errors.Wrapf(err, "error reading kube config template file %s", kubeConfigJSON)
 	}
-	kubeconfig := string(b)
+	// the user is named after the cluster, for example mycluster-admin
+	kubeconfig := strings.Replace(string(b), "{{WrapAsVariable \"resourceGroup\"}}-admin", "{{WrapAsVariable \"resourceGroup\"}}-"+user, -1)
 	// variable replacement
-	kubeconfig = strings.Replace(kubeconfig, "{{WrapAsVerbatim \"parameters('caCertificate')\"}}", base64.StdEncoding.EncodeToString([]byte(properties.CertificateProfile.CaCertificate)), -1)
-	if properties.OrchestratorProfile != nil &&
+	kubeconfig = strings.Replace(kubeconfig, "{{WrapAsVerbatim \"parameters('caCertificate')\"}}", base64.StdEncoding.EncodeToString([]byte(properties.CertificateProfile.GetCaCertificateBundle())), -1)
+	if privateFQDN := properties.GetPrivateAPIServerFQDN(); privateFQDN != "" {
+		// fully private cluster, use the API server record in the private DNS zone
+		kubeconfig = strings.Replace(kubeconfig, "{{WrapAsVerbatim \"reference(concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))).dnsSettings.fqdn\"}}", privateFQDN, -1)
+	} else if properties.OrchestratorProfile != nil &&
 		properties.OrchestratorProfile.KubernetesConfig != nil &&
 		properties.OrchestratorProfile.KubernetesConfig.PrivateCluster != nil &&
 		to.Bool(properties.OrchestratorProfile.KubernetesConfig.PrivateCluster.Enabled) {
@@ -79,23 +178,6 @@ func GenerateKubeConfig(properties *api.Properties, location string) (string, er
 	}
 	kubeconfig = strings.Replace(kubeconfig, "{{WrapAsVariable \"resourceGroup\"}}", properties.MasterProfile.DNSPrefix, -1)
 
-	var authInfo string
-	if properties.AADProfile == nil {
-		authInfo = fmt.Sprintf("{\"client-certificate-data\":\"%v\",\"client-key-data\":\"%v\"}",
-			base64.StdEncoding.EncodeToString([]byte(properties.CertificateProfile.KubeConfigCertificate)),
-			base64.StdEncoding.EncodeToString([]byte(properties.CertificateProfile.KubeConfigPrivateKey)))
-	} else {
-		tenantID := properties.AADProfile.TenantID
-		if len(tenantID) == 0 {
-			tenantID = "common"
-		}
-
-		authInfo = fmt.Sprintf("{\"auth-provider\":{\"name\":\"azure\",\"config\":{\"environment\":\"%v\",\"tenant-id\":\"%v\",\"apiserver-id\":\"%v\",\"client-id\":\"%v\"}}}",
-			helpers.GetTargetEnv(location, properties.GetCustomCloudName()),
-			tenantID,
-			properties.AADProfile.ServerAppID,
-			properties.AADProfile.ClientAppID)
-	}
 	kubeconfig = strings.Replace(kubeconfig, "{{authInfo}}", authInfo, -1)
 
 	return kubeconfig, nil
@@ -978,6 +1060,17 @@ func getSSHPublicKeysPowerShell(linuxProfile *api.LinuxProfile) string {
 	return str
 }
 
+// getWindowsPrePullImages returns the container images the Windows nodes pre-pull, as the items of a PowerShell array
+func getWindowsPrePullImages(windowsProfile *api.WindowsProfile) string {
+	images := []string{}
+	if windowsProfile != nil {
+		for _, image := range windowsProfile.PrePullImages {
+			images = append(images, `"`+image+`"`)
+		}
+	}
+	return strings.Join(images, ", ")
+}
+
 func getWindowsMasterSubnetARMParam(masterProfile *api.MasterProfile) string {
 	if masterProfile != nil && masterProfile.IsCustomVNET() {
 		return fmt.Sprintf("',parameters('vnetCidr'),'")
@@ -5,6 +5,7 @@ package engine
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -21,6 +22,7 @@ import (
 	"github.com/Azure/aks-engine/pkg/api/vlabs"
 	"github.com/Azure/aks-engine/pkg/engine/transform"
 	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/google/go-cmp/cmp"
 	"github.com/leonelquinteros/gotext"
 	"github.com/pkg/errors"
 )
@@ -613,6 +615,79 @@ func TestIsNSeriesSKU(t *testing.T) {
 	}
 }
 
+func TestFullyPrivateClusterTemplate(t *testing.T) {
+	locale := gotext.NewLocale(path.Join("..", "..", "translations"), "en_US")
+	i18n.Initialize(locale)
+
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: locale,
+		},
+	}
+
+	ctx := Context{
+		Translator: &i18n.Translator{
+			Locale: locale,
+		},
+	}
+
+	templateGenerator, err := InitializeTemplateGenerator(ctx)
+	if err != nil {
+		t.Fatalf("Failed to initialize template generator: %v", err)
+	}
+
+	containerService, _, err := apiloader.LoadContainerServiceFromFile("./testdata/vnet/kubernetesvnet-fullyprivate.json", true, false, nil)
+	if err != nil {
+		t.Fatalf("Failed to load container service from file: %v", err)
+	}
+	containerService.SetPropertiesDefaults(false, false)
+	armTemplate, _, err := templateGenerator.GenerateTemplateV2(containerService, DefaultGeneratorCode, TestAKSEngineVersion)
+	if err != nil {
+		t.Fatalf("Failed to generate arm template: %v", err)
+	}
+
+	var template struct {
+		Outputs   map[string]OutputElement `json:"outputs"`
+		Resources []struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"resources"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	err = json.Unmarshal([]byte(armTemplate), &template)
+	if err != nil {
+		t.Fatalf("couldn't unmarshall ARM template: %#v\n", err)
+	}
+
+	resourceTypes := map[string]bool{}
+	for _, resource := range template.Resources {
+		if strings.EqualFold(resource.Type, "Microsoft.Network/publicIPAddresses") {
+			t.Errorf("expected no public IP addresses in a fully private cluster, found %s", resource.Name)
+		}
+		resourceTypes[resource.Type] = true
+	}
+	for _, expected := range []string{
+		"Microsoft.Network/privateDnsZones",
+		"Microsoft.Network/privateDnsZones/A",
+		"Microsoft.Network/privateDnsZones/virtualNetworkLinks",
+		"Microsoft.Network/loadBalancers",
+	} {
+		if !resourceTypes[expected] {
+			t.Errorf("expected a resource of type %s in a fully private cluster", expected)
+		}
+	}
+	if resourceTypes["Microsoft.Network/routeTables"] {
+		t.Errorf("expected the existing route table to be used for user-defined routing")
+	}
+
+	if template.Variables["privateDNSZoneName"] != "masterdns1.privatecluster.internal" {
+		t.Errorf("unexpected privateDNSZoneName variable %v", template.Variables["privateDNSZoneName"])
+	}
+	if template.Outputs["masterFQDN"].Value != "[variables('privateDNSZoneName')]" {
+		t.Errorf("unexpected masterFQDN output %s", template.Outputs["masterFQDN"].Value)
+	}
+}
+
 func TestGenerateKubeConfig(t *testing.T) {
 	locale := gotext.NewLocale(path.Join("..", "..", "translations"), "en_US")
 	i18n.Initialize(locale)
@@ -670,6 +745,16 @@ func TestGenerateKubeConfig(t *testing.T) {
 		t.Errorf("Failed to call GenerateKubeConfig with simple Kubernetes config from file: %v", testData)
 	}
 
+	containerService.Properties.OrchestratorProfile.KubernetesConfig.PrivateCluster.FullyPrivate = to.BoolPtr(true)
+	containerService.Properties.OrchestratorProfile.KubernetesConfig.PrivateCluster.PrivateDNSZoneName = "k8s.contoso.internal"
+	kubeConfig, err = GenerateKubeConfig(containerService.Properties, "westus2")
+	if err != nil {
+		t.Errorf("Failed to call GenerateKubeConfig with simple Kubernetes config from file: %v", testData)
+	}
+	if !strings.Contains(kubeConfig, `"server": "https://k8s.contoso.internal"`) {
+		t.Errorf("expected kubeconfig of a fully private cluster to use the private API server FQDN, got %s", kubeConfig)
+	}
+
 	containerService.Properties.AADProfile = &api.AADProfile{
 		ClientAppID: "fooClientAppID",
 		TenantID:    "fooTenantID",
@@ -682,6 +767,118 @@ func TestGenerateKubeConfig(t *testing.T) {
 	}
 }
 
+func TestGenerateUserKubeConfig(t *testing.T) {
+	properties := &api.Properties{
+		MasterProfile: &api.MasterProfile{
+			DNSPrefix: "mycluster",
+		},
+		CertificateProfile: &api.CertificateProfile{
+			CaCertificate: "cacert",
+		},
+		AADProfile: &api.AADProfile{
+			ClientAppID: "fooClientAppID",
+			ServerAppID: "fooServerAppID",
+		},
+	}
+
+	cases := []struct {
+		auth     KubeConfigAuth
+		expected map[string]interface{}
+	}{
+		{
+			auth: KubeConfigAuthProvider,
+			expected: map[string]interface{}{
+				"auth-provider": map[string]interface{}{
+					"name": "azure",
+					"config": map[string]interface{}{
+						"environment":  "AzurePublicCloud",
+						"tenant-id":    "common",
+						"apiserver-id": "fooServerAppID",
+						"client-id":    "fooClientAppID",
+					},
+				},
+			},
+		},
+		{
+			auth: KubeConfigExec,
+			expected: map[string]interface{}{
+				"exec": map[string]interface{}{
+					"apiVersion": "client.authentication.k8s.io/v1beta1",
+					"command":    "kubelogin",
+					"args": []interface{}{
+						"get-token",
+						"--environment", "AzurePublicCloud",
+						"--server-id", "fooServerAppID",
+						"--client-id", "fooClientAppID",
+						"--tenant-id", "common",
+					},
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		kubeConfig, err := GenerateUserKubeConfig(properties, "westus2", c.auth)
+		if err != nil {
+			t.Fatalf("%s: unexpected error generating the user kubeconfig: %s", c.auth, err)
+		}
+		var config struct {
+			Users []struct {
+				Name string                 `json:"name"`
+				User map[string]interface{} `json:"user"`
+			} `json:"users"`
+		}
+		if err = json.Unmarshal([]byte(kubeConfig), &config); err != nil {
+			t.Fatalf("%s: unexpected error parsing the user kubeconfig: %s", c.auth, err)
+		}
+		if len(config.Users) != 1 || config.Users[0].Name != "mycluster-user" {
+			t.Fatalf("%s: expected a single mycluster-user user, got %v", c.auth, config.Users)
+		}
+		if diff := cmp.Diff(c.expected, config.Users[0].User); diff != "" {
+			t.Errorf("%s: unexpected user (-want +got):\n%s", c.auth, diff)
+		}
+	}
+
+	if _, err := GenerateUserKubeConfig(properties, "westus2", "password"); err == nil {
+		t.Errorf("expected an error generating a user kubeconfig with an unsupported authentication")
+	}
+	properties.AADProfile = nil
+	if _, err := GenerateUserKubeConfig(properties, "westus2", KubeConfigAuthProvider); err == nil {
+		t.Errorf("expected an error generating a user kubeconfig without an aadProfile")
+	}
+}
+
+func TestGenerateAdminKubeConfig(t *testing.T) {
+	properties := &api.Properties{
+		MasterProfile: &api.MasterProfile{
+			DNSPrefix: "mycluster",
+		},
+		CertificateProfile: &api.CertificateProfile{
+			CaCertificate: "cacert",
+		},
+	}
+	kubeConfig, err := GenerateAdminKubeConfig(properties, "westus2", "clientcert", "clientkey")
+	if err != nil {
+		t.Fatalf("unexpected error generating the admin kubeconfig: %s", err)
+	}
+	for _, expected := range []string{
+		`"name": "mycluster-admin"`,
+		`"client-certificate-data":"` + base64.StdEncoding.EncodeToString([]byte("clientcert")) + `"`,
+		`"client-key-data":"` + base64.StdEncoding.EncodeToString([]byte("clientkey")) + `"`,
+	} {
+		if !strings.Contains(kubeConfig, expected) {
+			t.Errorf("expected the admin kubeconfig to contain %s, got %s", expected, kubeConfig)
+		}
+	}
+
+	kubeConfig, err = GenerateCertificateKubeConfig(properties, "westus2", "alice", "clientcert", "clientkey")
+	if err != nil {
+		t.Fatalf("unexpected error generating the kubeconfig of alice: %s", err)
+	}
+	if !strings.Contains(kubeConfig, `"name": "mycluster-alice"`) || strings.Contains(kubeConfig, "mycluster-admin") {
+		t.Errorf("expected the kubeconfig of alice to name the user mycluster-alice, got %s", kubeConfig)
+	}
+}
+
 func TestValidateDistro(t *testing.T) {
 	// Test with Invalid Master Profile
 	cs := &api.ContainerService{
@@ -1601,6 +1798,40 @@ func TestGetSSHPublicKeysPowerShell(t *testing.T) {
 	}
 }
 
+func TestGetWindowsPrePullImages(t *testing.T) {
+	cases := []struct {
+		windowsProfile *api.WindowsProfile
+		expected       string
+	}{
+		{
+			expected: "",
+		},
+		{
+			windowsProfile: &api.WindowsProfile{},
+			expected:       "",
+		},
+		{
+			windowsProfile: &api.WindowsProfile{
+				PrePullImages: []string{"mcr.microsoft.com/windows/servercore:1809"},
+			},
+			expected: "\"mcr.microsoft.com/windows/servercore:1809\"",
+		},
+		{
+			windowsProfile: &api.WindowsProfile{
+				PrePullImages: []string{"mcr.microsoft.com/windows/servercore:1809", "mcr.microsoft.com/windows/nanoserver:1809"},
+			},
+			expected: "\"mcr.microsoft.com/windows/servercore:1809\", \"mcr.microsoft.com/windows/nanoserver:1809\"",
+		},
+	}
+
+	for _, c := range cases {
+		ret := getWindowsPrePullImages(c.windowsProfile)
+		if ret != c.expected {
+			t.Fatalf("expected getWindowsPrePullImages(%v) to return %s but instead got %s", c.windowsProfile, c.expected, ret)
+		}
+	}
+}
+
 func TestGetWindowsMasterSubnetARMParam(t *testing.T) {
 	cases := []struct {
 		m        *api.MasterProfile
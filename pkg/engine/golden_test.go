@@ -0,0 +1,149 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/v20160330"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/google/go-cmp/cmp"
+	"github.com/leonelquinteros/gotext"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files under testdata with the generated output")
+
+// goldenFilename returns the golden file holding the expected output of kind for an api model,
+// e.g. testdata/simple/dcos_expected_template.json for testdata/simple/dcos.json.
+func goldenFilename(apiModelFilename, kind string) string {
+	return strings.TrimSuffix(apiModelFilename, ".json") + "_expected_" + kind + ".json"
+}
+
+// loadGoldenContainerService loads an api model the same way TestExpected does, so that the
+// generated output only depends on the content of the model.
+func loadGoldenContainerService(t *testing.T, apiloader *api.Apiloader, filename string) *api.ContainerService {
+	containerService, version, err := apiloader.LoadContainerServiceFromFile(filename, true, false, nil)
+	if err != nil {
+		t.Fatalf("loading file %s got error: %s", filename, err)
+	}
+	if containerService.Properties.OrchestratorProfile.IsKubernetes() && version != vlabs.APIVersion && version != v20160330.APIVersion {
+		containerService.Properties.CertificateProfile = &api.CertificateProfile{}
+		addTestCertificateProfile(containerService.Properties.CertificateProfile)
+	}
+	if certsGenerated, _ := containerService.SetPropertiesDefaults(false, false); certsGenerated && !containerService.Properties.OrchestratorProfile.IsKubernetes() {
+		t.Fatalf("cert generation unexpected for %s", filename)
+	}
+	return containerService
+}
+
+// compareGolden compares the JSON document actual with the golden file of kind for an api model,
+// or rewrites the golden file when the test runs with -update.
+func compareGolden(t *testing.T, apiModelFilename, kind, actual string) {
+	filename := goldenFilename(apiModelFilename, kind)
+	if *updateGolden {
+		if err := ioutil.WriteFile(filename, []byte(actual+"\n"), 0644); err != nil {
+			t.Fatalf("unexpected error writing %s: %s", filename, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error reading %s, run the tests with -update to create it: %s", filename, err)
+	}
+	var expectedDoc, actualDoc interface{}
+	if err := json.Unmarshal(expected, &expectedDoc); err != nil {
+		t.Fatalf("unexpected error decoding %s: %s", filename, err)
+	}
+	if err := json.Unmarshal([]byte(actual), &actualDoc); err != nil {
+		t.Fatalf("unexpected error decoding the generated %s of %s: %s", kind, apiModelFilename, err)
+	}
+	if diff := cmp.Diff(expectedDoc, actualDoc); diff != "" {
+		t.Errorf("generated %s of %s differs from %s (-want +got):\n%s", kind, apiModelFilename, filename, diff)
+	}
+}
+
+// TestGoldenTemplates checks the generated ARM templates of the DCOS and Swarm api models, and
+// the generated parameter declarations of the Kubernetes api models under testdata against
+// their golden files. The output of the text templates under parts is checked against the same
+// golden files, so that the typed builders stay equivalent to them until they are removed.
+func TestGoldenTemplates(t *testing.T) {
+	locale := gotext.NewLocale(path.Join("..", "..", "translations"), "en_US")
+	i18n.Initialize(locale)
+	apiloader := &api.Apiloader{
+		Translator: &i18n.Translator{
+			Locale: locale,
+		},
+	}
+	templateGenerator, err := InitializeTemplateGenerator(Context{Translator: apiloader.Translator})
+	if err != nil {
+		t.Fatalf("unexpected error initializing the template generator: %s", err)
+	}
+
+	apiModelTestFiles := &[]APIModelTestFile{}
+	if err := IterateTestFilesDirectory(TestDataDir, apiModelTestFiles); err != nil {
+		t.Fatal(err)
+	}
+	for _, tuple := range *apiModelTestFiles {
+		containerService := loadGoldenContainerService(t, apiloader, tuple.APIModelFilename)
+		if containerService.Properties.OrchestratorProfile.IsKubernetes() {
+			compareGoldenParameters(t, tuple.APIModelFilename, GetKubernetesParameters(containerService))
+
+			if *updateGolden {
+				continue
+			}
+			legacyParameters, err := templateGenerator.getParameterDescMap(containerService)
+			if err != nil {
+				t.Errorf("unexpected error generating the parameters of %s from the text templates: %s", tuple.APIModelFilename, err)
+				continue
+			}
+			compareGoldenParameters(t, tuple.APIModelFilename, legacyParameters)
+			continue
+		}
+
+		template, _, err := templateGenerator.GenerateTemplateV2(containerService, DefaultGeneratorCode, TestAKSEngineVersion)
+		if err != nil {
+			t.Errorf("unexpected error generating the template of %s: %s", tuple.APIModelFilename, err)
+			continue
+		}
+		compareGoldenTemplate(t, tuple.APIModelFilename, template)
+
+		if *updateGolden {
+			continue
+		}
+		legacyTemplate, _, err := templateGenerator.generateTemplateFromParts(containerService, DefaultGeneratorCode, TestAKSEngineVersion)
+		if err != nil {
+			t.Errorf("unexpected error generating the template of %s from the text templates: %s", tuple.APIModelFilename, err)
+			continue
+		}
+		compareGoldenTemplate(t, tuple.APIModelFilename, legacyTemplate)
+	}
+}
+
+func compareGoldenParameters(t *testing.T, apiModelFilename string, parameters interface{}) {
+	b, err := json.Marshal(parameters)
+	if err != nil {
+		t.Fatalf("unexpected error encoding the parameters of %s: %s", apiModelFilename, err)
+	}
+	pretty, err := transform.PrettyPrintJSON(string(b))
+	if err != nil {
+		t.Fatalf("unexpected error formatting the parameters of %s: %s", apiModelFilename, err)
+	}
+	compareGolden(t, apiModelFilename, "parameters", pretty)
+}
+
+func compareGoldenTemplate(t *testing.T, apiModelFilename, template string) {
+	pretty, err := transform.PrettyPrintArmTemplate(template)
+	if err != nil {
+		t.Fatalf("unexpected error formatting the template of %s: %s", apiModelFilename, err)
+	}
+	compareGolden(t, apiModelFilename, "template", pretty)
+}
@@ -0,0 +1,541 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+)
+
+// This file holds the template builders shared by the DCOS and Swarm orchestrators.
+
+// iaasStorageAccountPrefixes are the prefixes of the names of the storage accounts holding the disks of the agents
+var iaasStorageAccountPrefixes = []interface{}{"0", "6", "c", "i", "o", "u", "1", "7", "d", "j", "p", "v", "2", "8", "e", "k", "q", "w", "3", "9", "f", "l", "r", "x", "4", "a", "g", "m", "s", "y", "5", "b", "h", "n", "t", "z"}
+
+// getIaaSAgentPoolIndexVariables returns the variables that depend on the position of an agent pool in the api model
+func getIaaSAgentPoolIndexVariables(profile *api.AgentPoolProfile, index int) map[string]interface{} {
+	agentName := profile.Name
+	agentVars := map[string]interface{}{}
+	if profile.IsStorageAccount() {
+		agentVars[agentName+"StorageAccountOffset"] = fmt.Sprintf("[mul(variables('maxStorageAccountsPerAgent'),%d)]", index)
+		agentVars[agentName+"AccountName"] = fmt.Sprintf("[concat(variables('storageAccountBaseName'), 'agnt%d')]", index)
+		if profile.HasDisks() {
+			agentVars[agentName+"DataAccountName"] = fmt.Sprintf("[concat(variables('storageAccountBaseName'), 'data%d')]", index)
+		}
+	}
+	return agentVars
+}
+
+// getIaaSAgentPoolVariables returns the sizing, storage, subnet and load balancer variables of an agent pool,
+// subnetSuffix being appended to the agent pool name in the name of its subnet
+func getIaaSAgentPoolVariables(profile *api.AgentPoolProfile, subnetSuffix string) map[string]interface{} {
+	agentName := profile.Name
+	agentVars := map[string]interface{}{
+		agentName + "VMSize":     fmt.Sprintf("[parameters('%sVMSize')]", agentName),
+		agentName + "VMSizeTier": fmt.Sprintf("[split(parameters('%sVMSize'),'_')[0]]", agentName),
+	}
+
+	if profile.IsAvailabilitySets() {
+		if profile.IsStorageAccount() {
+			agentVars[agentName+"StorageAccountsCount"] = fmt.Sprintf("[add(div(variables('%[1]sCount'), variables('maxVMsPerStorageAccount')), mod(add(mod(variables('%[1]sCount'), variables('maxVMsPerStorageAccount')),2), add(mod(variables('%[1]sCount'), variables('maxVMsPerStorageAccount')),1)))]", agentName)
+			agentVars[agentName+"StorageAccountOffset"] = fmt.Sprintf("[mul(variables('maxStorageAccountsPerAgent'),variables('%sIndex'))]", agentName)
+		}
+		agentVars[agentName+"AvailabilitySet"] = fmt.Sprintf("[concat('%s-availabilitySet-', variables('nameSuffix'))]", agentName)
+		agentVars[agentName+"Offset"] = fmt.Sprintf("[parameters('%sOffset')]", agentName)
+	} else if profile.IsStorageAccount() {
+		agentVars[agentName+"StorageAccountsCount"] = "[variables('maxStorageAccountsPerAgent')]"
+	}
+
+	if profile.IsCustomVNET() {
+		agentVars[agentName+"VnetSubnetID"] = fmt.Sprintf("[parameters('%sVnetSubnetID')]", agentName)
+	} else {
+		agentVars[agentName+"Subnet"] = fmt.Sprintf("[parameters('%sSubnet')]", agentName)
+		agentVars[agentName+"SubnetName"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-%s%s')]", agentName, subnetSuffix)
+		agentVars[agentName+"VnetSubnetID"] = fmt.Sprintf("[concat(variables('vnetID'),'/subnets/',variables('%sSubnetName'))]", agentName)
+	}
+
+	if common.SliceIntIsNonEmpty(profile.Ports) {
+		agentVars[agentName+"EndpointDNSNamePrefix"] = fmt.Sprintf("[tolower(parameters('%sEndpointDNSNamePrefix'))]", agentName)
+		agentVars[agentName+"IPAddressName"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-agent-ip-', variables('%sEndpointDNSNamePrefix'), '-', variables('nameSuffix'))]", agentName)
+		agentVars[agentName+"LbBackendPoolName"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-', variables('nameSuffix'))]", agentName)
+		agentVars[agentName+"LbID"] = fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers',variables('%sLbName'))]", agentName)
+		agentVars[agentName+"LbIPConfigID"] = fmt.Sprintf("[concat(variables('%[1]sLbID'),'/frontendIPConfigurations/', variables('%[1]sLbIPConfigName'))]", agentName)
+		agentVars[agentName+"LbIPConfigName"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-', variables('nameSuffix'))]", agentName)
+		agentVars[agentName+"LbName"] = fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-', variables('nameSuffix'))]", agentName)
+		if profile.IsWindows() {
+			agentVars[agentName+"WindowsRDPNatRangeStart"] = 3389
+			agentVars[agentName+"WindowsRDPEndRangeStop"] = fmt.Sprintf("[add(variables('%[1]sWindowsRDPNatRangeStart'), add(variables('%[1]sCount'),variables('%[1]sCount')))]", agentName)
+		}
+	}
+	return agentVars
+}
+
+// getIaaSStorageAccountsVariables returns the variables used to spread the VMs of a cluster over storage accounts
+func getIaaSStorageAccountsVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"maxVMsPerStorageAccount":      20,
+		"maxStorageAccountsPerAgent":   "[div(variables('maxVMsPerPool'),variables('maxVMsPerStorageAccount'))]",
+		"dataStorageAccountPrefixSeed": 97,
+		"storageAccountPrefixes":       iaasStorageAccountPrefixes,
+		"storageAccountPrefixesCount":  "[length(variables('storageAccountPrefixes'))]",
+		"vmSizesMap":                   getSizeMap()["vmSizesMap"],
+	}
+}
+
+// getIaaSMasterVNETVariables returns the subnet variables of the masters,
+// and the variables of the virtual network when the cluster does not use a custom one
+func getIaaSMasterVNETVariables(cs *api.ContainerService) map[string]interface{} {
+	if cs.Properties.MasterProfile.IsCustomVNET() {
+		return map[string]interface{}{
+			"masterVnetSubnetID": "[parameters('masterVnetSubnetID')]",
+		}
+	}
+	return map[string]interface{}{
+		"masterSubnet":       "[parameters('masterSubnet')]",
+		"masterSubnetName":   "[concat(variables('orchestratorName'), '-masterSubnet')]",
+		"vnetID":             "[resourceId('Microsoft.Network/virtualNetworks',variables('virtualNetworkName'))]",
+		"masterVnetSubnetID": "[concat(variables('vnetID'),'/subnets/',variables('masterSubnetName'))]",
+		"virtualNetworkName": "[concat(variables('orchestratorName'), '-vnet-', variables('nameSuffix'))]",
+	}
+}
+
+// getIaaSMasterAddressVariables returns the variables splitting the first consecutive static IP of the masters
+func getIaaSMasterAddressVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"masterFirstAddrOctets": "[split(parameters('firstConsecutiveStaticIP'),'.')]",
+		"masterFirstAddrOctet4": "[variables('masterFirstAddrOctets')[3]]",
+		"masterFirstAddrPrefix": "[concat(variables('masterFirstAddrOctets')[0],'.',variables('masterFirstAddrOctets')[1],'.',variables('masterFirstAddrOctets')[2],'.')]",
+	}
+}
+
+// getIaaSLocationVariables returns the variables defaulting the location of the cluster to the one of its resource group
+func getIaaSLocationVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"locations": []interface{}{
+			"[resourceGroup().location]",
+			"[parameters('location')]",
+		},
+		"location": "[variables('locations')[mod(add(2,length(parameters('location'))),add(1,length(parameters('location'))))]]",
+	}
+}
+
+// getIaaSMasterLbInboundNatRules returns the inbound NAT rules of each master NIC,
+// the first master also being reachable on port 22 when withPort22 is true
+func getIaaSMasterLbInboundNatRules(withPort22 bool) []interface{} {
+	rules := []interface{}{}
+	for i := 0; i < 5; i++ {
+		masterRules := []interface{}{
+			map[string]interface{}{
+				"id": fmt.Sprintf("[concat(variables('masterSshInboundNatRuleIdPrefix'),'%d')]", i),
+			},
+		}
+		if i == 0 && withPort22 {
+			masterRules = append(masterRules, map[string]interface{}{
+				"id": "[concat(variables('masterSshPort22InboundNatRuleIdPrefix'),'0')]",
+			})
+		}
+		rules = append(rules, masterRules)
+	}
+	return rules
+}
+
+// getIaaSProfileSecrets returns the vault secret groups of a linux or windows profile referencing
+// the key vault parameters prefixed by paramPrefix, each certificate also referencing its store when withStore is true
+func getIaaSProfileSecrets(secrets []api.KeyVaultSecrets, paramPrefix string, withStore bool) []interface{} {
+	secretGroups := []interface{}{}
+	for vIndex, vault := range secrets {
+		vaultCertificates := []interface{}{}
+		for cIndex := range vault.VaultCertificates {
+			vaultCertificate := map[string]interface{}{
+				"certificateUrl": fmt.Sprintf("[parameters('%sKeyVaultID%dCertificateURL%d')]", paramPrefix, vIndex, cIndex),
+			}
+			if withStore {
+				vaultCertificate["certificateStore"] = fmt.Sprintf("[parameters('%sKeyVaultID%dCertificateStore%d')]", paramPrefix, vIndex, cIndex)
+			}
+			vaultCertificates = append(vaultCertificates, vaultCertificate)
+		}
+		secretGroups = append(secretGroups, map[string]interface{}{
+			"sourceVault": map[string]interface{}{
+				"id": fmt.Sprintf("[parameters('%sKeyVaultID%d')]", paramPrefix, vIndex),
+			},
+			"vaultCertificates": vaultCertificates,
+		})
+	}
+	return secretGroups
+}
+
+// getIaaSOutputs returns the outputs of the ARM template of a DCOS or Swarm cluster
+func getIaaSOutputs(cs *api.ContainerService) map[string]interface{} {
+	outputs := map[string]interface{}{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		agentName := profile.Name
+		if common.SliceIntIsNonEmpty(profile.Ports) {
+			outputs[agentName+"FQDN"] = map[string]interface{}{
+				"type":  "string",
+				"value": fmt.Sprintf("[reference(concat('Microsoft.Network/publicIPAddresses/', variables('%sIPAddressName'))).dnsSettings.fqdn]", agentName),
+			}
+		}
+		if profile.IsAvailabilitySets() && profile.IsStorageAccount() {
+			outputs[agentName+"StorageAccountOffset"] = map[string]interface{}{
+				"type":  "int",
+				"value": fmt.Sprintf("[variables('%sStorageAccountOffset')]", agentName),
+			}
+			outputs[agentName+"StorageAccountCount"] = map[string]interface{}{
+				"type":  "int",
+				"value": fmt.Sprintf("[variables('%sStorageAccountsCount')]", agentName),
+			}
+			outputs[agentName+"SubnetName"] = map[string]interface{}{
+				"type":  "string",
+				"value": fmt.Sprintf("[variables('%sSubnetName')]", agentName),
+			}
+		}
+	}
+	for k, v := range getMasterOutputs(cs) {
+		outputs[k] = v
+	}
+	return outputs
+}
+
+// getIaaSLoadBalancingRules returns the load balancing rules of the public load balancer of an agent pool
+func getIaaSLoadBalancingRules(agentName string, ports []int) []interface{} {
+	rules := []interface{}{}
+	for _, port := range ports {
+		rules = append(rules, map[string]interface{}{
+			"name": fmt.Sprintf("LBRule%d", port),
+			"properties": map[string]interface{}{
+				"backendAddressPool": map[string]interface{}{
+					"id": fmt.Sprintf("[concat(variables('%[1]sLbID'), '/backendAddressPools/', variables('%[1]sLbBackendPoolName'))]", agentName),
+				},
+				"backendPort":      port,
+				"enableFloatingIP": false,
+				"frontendIPConfiguration": map[string]interface{}{
+					"id": fmt.Sprintf("[variables('%sLbIPConfigID')]", agentName),
+				},
+				"frontendPort":         port,
+				"idleTimeoutInMinutes": 5,
+				"loadDistribution":     "Default",
+				"probe": map[string]interface{}{
+					"id": fmt.Sprintf("[concat(variables('%sLbID'),'/probes/tcp%dProbe')]", agentName, port),
+				},
+				"protocol": "Tcp",
+			},
+		})
+	}
+	return rules
+}
+
+// getIaaSLoadBalancerProbes returns the probes of the public load balancer of an agent pool
+func getIaaSLoadBalancerProbes(ports []int) []interface{} {
+	probes := []interface{}{}
+	for _, port := range ports {
+		probes = append(probes, map[string]interface{}{
+			"name": fmt.Sprintf("tcp%dProbe", port),
+			"properties": map[string]interface{}{
+				"intervalInSeconds": 5,
+				"numberOfProbes":    2,
+				"port":              port,
+				"protocol":          "Tcp",
+			},
+		})
+	}
+	return probes
+}
+
+// getIaaSSecurityRules returns the security rules opening the ports of an agent pool to the Internet
+func getIaaSSecurityRules(ports []int) []interface{} {
+	// BaseLBPriority specifies the base lb priority.
+	BaseLBPriority := 200
+	rules := []interface{}{}
+	for index, port := range ports {
+		rules = append(rules, map[string]interface{}{
+			"name": fmt.Sprintf("Allow_%d", port),
+			"properties": map[string]interface{}{
+				"access":                   "Allow",
+				"description":              fmt.Sprintf("Allow traffic from the Internet to port %d", port),
+				"destinationAddressPrefix": "*",
+				"destinationPortRange":     fmt.Sprintf("%d", port),
+				"direction":                "Inbound",
+				"priority":                 BaseLBPriority + index,
+				"protocol":                 "*",
+				"sourceAddressPrefix":      "Internet",
+				"sourcePortRange":          "*",
+			},
+		})
+	}
+	return rules
+}
+
+// getIaaSDataDisks returns the data disks of the VMs of an agent pool, or nil if the agent pool has none
+func getIaaSDataDisks(profile *api.AgentPoolProfile) []interface{} {
+	if !profile.HasDisks() {
+		return nil
+	}
+	agentName := profile.Name
+	dataDisks := []interface{}{}
+	for i, diskSize := range profile.DiskSizesGB {
+		if profile.StorageProfile == api.StorageAccount {
+			dataDisks = append(dataDisks, map[string]interface{}{
+				"createOption": "Empty",
+				"diskSizeGB":   fmt.Sprintf("%d", diskSize),
+				"lun":          i,
+				"caching":      "ReadOnly",
+				"name":         fmt.Sprintf("[concat(variables('%sVMNamePrefix'), copyIndex(),'-datadisk%d')]", agentName, i),
+				"vhd": map[string]interface{}{
+					"uri": fmt.Sprintf("[concat('http://',variables('storageAccountPrefixes')[mod(add(add(div(copyIndex(),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('dataStorageAccountPrefixSeed')),variables('storageAccountPrefixesCount'))],variables('storageAccountPrefixes')[div(add(add(div(copyIndex(),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('dataStorageAccountPrefixSeed')),variables('storageAccountPrefixesCount'))],variables('%[1]sDataAccountName'),'.blob.core.windows.net/vhds/',variables('%[1]sVMNamePrefix'),copyIndex(), '--datadisk%[2]d.vhd')]", agentName, i),
+				},
+			})
+		} else if profile.StorageProfile == api.ManagedDisks {
+			dataDisks = append(dataDisks, map[string]interface{}{
+				"diskSizeGB":   fmt.Sprintf("%d", diskSize),
+				"lun":          i,
+				"caching":      "ReadOnly",
+				"createOption": "Empty",
+			})
+		}
+	}
+	return dataDisks
+}
+
+// getIaaSVirtualNetwork returns the virtual network of a cluster, the agent subnets being
+// associated with the network security groups of their agent pools when withNSG is true
+func getIaaSVirtualNetwork(cs *api.ContainerService, withNSG bool) map[string]interface{} {
+	properties := cs.Properties
+
+	// the agent subnets are not recorded as visited, so agent pools sharing a subnet repeat its address prefix
+	visitedSubnets := map[string]bool{properties.MasterProfile.Subnet: true}
+	addressPrefixes := []interface{}{"[variables('masterSubnet')]"}
+	for _, profile := range properties.AgentPoolProfiles {
+		if !visitedSubnets[profile.Subnet] {
+			addressPrefixes = append(addressPrefixes, fmt.Sprintf("[variables('%sSubnet')]", profile.Name))
+		}
+	}
+
+	subnets := []interface{}{
+		map[string]interface{}{
+			"name": "[variables('masterSubnetName')]",
+			"properties": map[string]interface{}{
+				"addressPrefix": "[variables('masterSubnet')]",
+			},
+		},
+	}
+	dependencies := []interface{}{}
+	for _, profile := range properties.AgentPoolProfiles {
+		subnetProperties := map[string]interface{}{
+			"addressPrefix": fmt.Sprintf("[variables('%sSubnet')]", profile.Name),
+		}
+		if withNSG {
+			subnetProperties["networkSecurityGroup"] = map[string]interface{}{
+				"id": fmt.Sprintf("[resourceId('Microsoft.Network/networkSecurityGroups', variables('%sNSGName'))]", profile.Name),
+			}
+		}
+		subnets = append(subnets, map[string]interface{}{
+			"name":       fmt.Sprintf("[variables('%sSubnetName')]", profile.Name),
+			"properties": subnetProperties,
+		})
+		dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Network/networkSecurityGroups/', variables('%sNSGName'))]", profile.Name))
+	}
+
+	vnet := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       "[variables('virtualNetworkName')]",
+		"properties": map[string]interface{}{
+			"addressSpace": map[string]interface{}{
+				"addressPrefixes": addressPrefixes,
+			},
+			"subnets": subnets,
+		},
+		"type": "Microsoft.Network/virtualNetworks",
+	}
+	if withNSG {
+		vnet["dependsOn"] = dependencies
+	}
+	return vnet
+}
+
+// getIaaSAgentStorageAccountNameArgs returns the arguments of the concat expression naming the storage account
+// of an agent pool at position index, the storage accounts of the data disks being named after accountNameVariable
+func getIaaSAgentStorageAccountNameArgs(agentName, index, accountNameVariable string) string {
+	return fmt.Sprintf("variables('storageAccountPrefixes')[mod(add(%[2]s,variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('storageAccountPrefixes')[div(add(%[2]s,variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('%[3]s')", agentName, index, accountNameVariable)
+}
+
+// getIaaSAgentStorageAccounts returns the storage accounts holding the OS disks, and the data disks if any, of an agent pool
+func getIaaSAgentStorageAccounts(profile *api.AgentPoolProfile, copyName string) []interface{} {
+	agentName := profile.Name
+	newStorageAccount := func(copyName, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "[variables('apiVersionStorage')]",
+			"copy": map[string]interface{}{
+				"count": fmt.Sprintf("[variables('%sStorageAccountsCount')]", agentName),
+				"name":  copyName,
+			},
+			"dependsOn": []interface{}{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+			},
+			"location": "[variables('location')]",
+			"name":     name,
+			"properties": map[string]interface{}{
+				"accountType": fmt.Sprintf("[variables('vmSizesMap')[variables('%sVMSize')].storageAccountType]", agentName),
+			},
+			"type": "Microsoft.Storage/storageAccounts",
+		}
+	}
+	storageAccounts := []interface{}{
+		newStorageAccount(copyName, fmt.Sprintf("[concat(%s)]", getIaaSAgentStorageAccountNameArgs(agentName, "copyIndex()", agentName+"AccountName"))),
+	}
+	if profile.IsAvailabilitySets() && profile.HasDisks() {
+		storageAccounts = append(storageAccounts, newStorageAccount("datadiskLoop", fmt.Sprintf("[concat(%s)]", getIaaSAgentStorageAccountNameArgs(agentName, "copyIndex(variables('dataStorageAccountPrefixSeed'))", agentName+"DataAccountName"))))
+	}
+	return storageAccounts
+}
+
+// getIaaSAvailabilitySet returns an availability set named after the variable nameVariable
+func getIaaSAvailabilitySet(nameVariable string, managedDisks bool) map[string]interface{} {
+	if managedDisks {
+		return map[string]interface{}{
+			"apiVersion": "[variables('apiVersionStorageManagedDisks')]",
+			"location":   "[variables('location')]",
+			"name":       fmt.Sprintf("[variables('%s')]", nameVariable),
+			"properties": map[string]interface{}{
+				"platformFaultDomainCount":  2,
+				"platformUpdateDomainCount": 3,
+				"managed":                   "true",
+			},
+			"type": "Microsoft.Compute/availabilitySets",
+		}
+	}
+	return map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[variables('%s')]", nameVariable),
+		"properties": map[string]interface{}{},
+		"type":       "Microsoft.Compute/availabilitySets",
+	}
+}
+
+// getIaaSAgentPublicResources returns the public IP address and the load balancer of a public agent pool,
+// the load balancer also forwarding RDP to the VMs when withRDPNatPool is true
+func getIaaSAgentPublicResources(profile *api.AgentPoolProfile, withRDPNatPool bool) []interface{} {
+	agentName := profile.Name
+	publicIP := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[variables('%sIPAddressName')]", agentName),
+		"properties": map[string]interface{}{
+			"dnsSettings": map[string]interface{}{
+				"domainNameLabel": fmt.Sprintf("[variables('%sEndpointDNSNamePrefix')]", agentName),
+			},
+			"publicIPAllocationMethod": "Dynamic",
+		},
+		"type": "Microsoft.Network/publicIPAddresses",
+	}
+	lbProperties := map[string]interface{}{
+		"backendAddressPools": []interface{}{
+			map[string]interface{}{
+				"name": fmt.Sprintf("[variables('%sLbBackendPoolName')]", agentName),
+			},
+		},
+		"frontendIPConfigurations": []interface{}{
+			map[string]interface{}{
+				"name": fmt.Sprintf("[variables('%sLbIPConfigName')]", agentName),
+				"properties": map[string]interface{}{
+					"publicIPAddress": map[string]interface{}{
+						"id": fmt.Sprintf("[resourceId('Microsoft.Network/publicIPAddresses',variables('%sIPAddressName'))]", agentName),
+					},
+				},
+			},
+		},
+		"loadBalancingRules": getIaaSLoadBalancingRules(agentName, profile.Ports),
+		"probes":             getIaaSLoadBalancerProbes(profile.Ports),
+	}
+	if withRDPNatPool {
+		lbProperties["inboundNatPools"] = []interface{}{
+			map[string]interface{}{
+				"name": fmt.Sprintf("[concat('RDP-', variables('%sVMNamePrefix'))]", agentName),
+				"properties": map[string]interface{}{
+					"frontendIPConfiguration": map[string]interface{}{
+						"id": fmt.Sprintf("[variables('%sLbIPConfigID')]", agentName),
+					},
+					"protocol":               "Tcp",
+					"frontendPortRangeStart": fmt.Sprintf("[variables('%sWindowsRDPNatRangeStart')]", agentName),
+					"frontendPortRangeEnd":   fmt.Sprintf("[variables('%sWindowsRDPEndRangeStop')]", agentName),
+					"backendPort":            "[variables('agentWindowsBackendPort')]",
+				},
+			},
+		}
+	} else {
+		lbProperties["inboundNatRules"] = []interface{}{}
+	}
+	lb := map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"dependsOn": []interface{}{
+			fmt.Sprintf("[concat('Microsoft.Network/publicIPAddresses/', variables('%sIPAddressName'))]", agentName),
+		},
+		"location":   "[variables('location')]",
+		"name":       fmt.Sprintf("[variables('%sLbName')]", agentName),
+		"properties": lbProperties,
+		"type":       "Microsoft.Network/loadBalancers",
+	}
+	return []interface{}{publicIP, lb}
+}
+
+// getIaaSAgentRDPNatPools returns the references of a NIC to the RDP NAT pool of the load balancer of a Windows agent pool
+func getIaaSAgentRDPNatPools(agentName string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"id": fmt.Sprintf("[concat(variables('%[1]sLbID'), '/inboundNatPools/', 'RDP-', variables('%[1]sVMNamePrefix'))]", agentName),
+		},
+	}
+}
+
+// getIaaSAgentBackendPools returns the references of a NIC of a VM in an availability set to the backend pool of the load balancer of its agent pool
+func getIaaSAgentBackendPools(agentName string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"id": fmt.Sprintf("[concat('/subscriptions/', subscription().subscriptionId,'/resourceGroups/', resourceGroup().name, '/providers/Microsoft.Network/loadBalancers/', variables('%[1]sLbName'), '/backendAddressPools/',variables('%[1]sLbBackendPoolName'))]", agentName),
+		},
+	}
+}
+
+// getIaaSAgentVMStorageAccountDependencies returns the dependencies of a VM in an availability set on the storage accounts of its disks
+func getIaaSAgentVMStorageAccountDependencies(profile *api.AgentPoolProfile) []interface{} {
+	agentName := profile.Name
+	dependencies := []interface{}{}
+	if profile.IsStorageAccount() {
+		dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Storage/storageAccounts/',variables('storageAccountPrefixes')[mod(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('storageAccountPrefixes')[div(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('%[1]sAccountName'))]", agentName))
+		if profile.HasDisks() {
+			dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Storage/storageAccounts/',variables('storageAccountPrefixes')[mod(add(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('dataStorageAccountPrefixSeed')),variables('storageAccountPrefixesCount'))],variables('storageAccountPrefixes')[div(add(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('dataStorageAccountPrefixSeed')),variables('storageAccountPrefixesCount'))],variables('%[1]sDataAccountName'))]", agentName))
+		}
+	}
+	return append(dependencies,
+		fmt.Sprintf("[concat('Microsoft.Network/networkInterfaces/', variables('%[1]sVMNamePrefix'), 'nic-', copyIndex(variables('%[1]sOffset')))]", agentName),
+		fmt.Sprintf("[concat('Microsoft.Compute/availabilitySets/', variables('%sAvailabilitySet'))]", agentName))
+}
+
+// getIaaSAgentVMOSDisk returns the OS disk of a VM in an availability set
+func getIaaSAgentVMOSDisk(profile *api.AgentPoolProfile) map[string]interface{} {
+	agentName := profile.Name
+	osDisk := map[string]interface{}{
+		"caching":      "ReadOnly",
+		"createOption": "FromImage",
+	}
+	if profile.IsStorageAccount() {
+		osDisk["name"] = fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')),'-osdisk')]", agentName)
+		osDisk["vhd"] = map[string]interface{}{
+			"uri": fmt.Sprintf("[concat(reference(concat('Microsoft.Storage/storageAccounts/',variables('storageAccountPrefixes')[mod(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('storageAccountPrefixes')[div(add(div(copyIndex(variables('%[1]sOffset')),variables('maxVMsPerStorageAccount')),variables('%[1]sStorageAccountOffset')),variables('storageAccountPrefixesCount'))],variables('%[1]sAccountName')),variables('apiVersionStorage')).primaryEndpoints.blob,'osdisk/', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')), '-osdisk.vhd')]", agentName),
+		}
+	}
+	if profile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = profile.OSDiskSizeGB
+	}
+	return osDisk
+}
+
+// getCustomDataFromFragment decodes the value of a `"customData": ...,` fragment rendered for the text templates
+func getCustomDataFromFragment(fragment string) string {
+	return getCustomDataFromJSON("{" + strings.TrimSuffix(strings.TrimSpace(fragment), ",") + "}")
+}
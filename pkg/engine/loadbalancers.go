@@ -328,7 +328,9 @@ func CreateAgentLoadBalancer(prop *api.Properties, isVMSS bool) LoadBalancerARM
 func CreateMasterInternalLoadBalancer(cs *api.ContainerService) LoadBalancerARM {
 	var dependencies []string
 	if cs.Properties.MasterProfile.IsCustomVNET() {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
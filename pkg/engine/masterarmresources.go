@@ -27,15 +27,26 @@ func createKubernetesMasterResourcesVMAS(cs *api.ContainerService) []interface{}
 		masterResources = append(masterResources, availabilitySet, storageAccount)
 	}
 
+	isNATGatewayOutbound := cs.Properties.OrchestratorProfile.KubernetesConfig.IsNATGatewayOutbound()
+	if isNATGatewayOutbound {
+		masterResources = append(masterResources, createNATGatewayPublicIPAddress(), createNATGateway(cs))
+	}
+
 	if !p.MasterProfile.IsCustomVNET() {
 		virtualNetwork := CreateVirtualNetwork(cs)
-		masterResources = append(masterResources, virtualNetwork)
+		if isNATGatewayOutbound {
+			masterResources = append(masterResources, associateNATGateway(virtualNetwork))
+		} else {
+			masterResources = append(masterResources, virtualNetwork)
+		}
 	}
 
-	masterNsg := CreateNetworkSecurityGroup(cs)
-	masterResources = append(masterResources, masterNsg)
+	if !cs.Properties.MasterProfile.IsCustomNSG() {
+		masterNsg := CreateNetworkSecurityGroup(cs)
+		masterResources = append(masterResources, masterNsg)
+	}
 
-	if cs.Properties.OrchestratorProfile.RequireRouteTable() {
+	if cs.Properties.OrchestratorProfile.RequireRouteTable() && !cs.Properties.MasterProfile.IsCustomRouteTable() {
 		masterResources = append(masterResources, createRouteTable())
 	}
 
@@ -68,10 +79,13 @@ func createKubernetesMasterResourcesVMAS(cs *api.ContainerService) []interface{}
 				jumpBoxStorage := createJumpboxStorageAccount()
 				masterResources = append(masterResources, jumpBoxStorage)
 			}
-			jumpboxNSG := createJumpboxNSG()
+			jumpboxNSG := createJumpboxNSG(cs)
 			jumpboxNIC := createJumpboxNetworkInterface(cs)
-			jumpboxPublicIP := createJumpboxPublicIPAddress()
-			masterResources = append(masterResources, jumpboxNSG, jumpboxNIC, jumpboxPublicIP)
+			masterResources = append(masterResources, jumpboxNSG, jumpboxNIC)
+			if !cs.Properties.OrchestratorProfile.IsFullyPrivateCluster() {
+				jumpboxPublicIP := createJumpboxPublicIPAddress()
+				masterResources = append(masterResources, jumpboxPublicIP)
+			}
 		}
 	}
 
@@ -80,6 +94,10 @@ func createKubernetesMasterResourcesVMAS(cs *api.ContainerService) []interface{}
 		masterResources = append(masterResources, internalLB)
 	}
 
+	if cs.Properties.OrchestratorProfile.IsFullyPrivateCluster() {
+		masterResources = append(masterResources, createPrivateDNSZone(), createPrivateDNSZoneAPIServerRecord(), createPrivateDNSZoneVirtualNetworkLink())
+	}
+
 	var isKMSEnabled bool
 	if kubernetesConfig != nil {
 		isKMSEnabled = to.Bool(kubernetesConfig.EnableEncryptionWithExternalKms)
@@ -137,15 +155,26 @@ func createKubernetesMasterResourcesVMSS(cs *api.ContainerService) []interface{}
 		masterResources = append(masterResources, createCosmosDBAccount())
 	}
 
-	masterNSG := CreateNetworkSecurityGroup(cs)
-	masterResources = append(masterResources, masterNSG)
+	if !cs.Properties.MasterProfile.IsCustomNSG() {
+		masterNSG := CreateNetworkSecurityGroup(cs)
+		masterResources = append(masterResources, masterNSG)
+	}
 
-	if cs.Properties.OrchestratorProfile.RequireRouteTable() {
+	if cs.Properties.OrchestratorProfile.RequireRouteTable() && !cs.Properties.MasterProfile.IsCustomRouteTable() {
 		masterResources = append(masterResources, createRouteTable())
 	}
+	isNATGatewayOutbound := cs.Properties.OrchestratorProfile.KubernetesConfig.IsNATGatewayOutbound()
+	if isNATGatewayOutbound {
+		masterResources = append(masterResources, createNATGatewayPublicIPAddress(), createNATGateway(cs))
+	}
+
 	if !cs.Properties.MasterProfile.IsCustomVNET() {
 		masterVNET := createVirtualNetworkVMSS(cs)
-		masterResources = append(masterResources, masterVNET)
+		if isNATGatewayOutbound {
+			masterResources = append(masterResources, associateNATGateway(masterVNET))
+		} else {
+			masterResources = append(masterResources, masterVNET)
+		}
 	}
 
 	if cs.Properties.MasterProfile.HasMultipleNodes() {
@@ -153,6 +182,10 @@ func createKubernetesMasterResourcesVMSS(cs *api.ContainerService) []interface{}
 		masterResources = append(masterResources, internalLb)
 	}
 
+	if cs.Properties.OrchestratorProfile.IsFullyPrivateCluster() {
+		masterResources = append(masterResources, createPrivateDNSZone(), createPrivateDNSZoneAPIServerRecord(), createPrivateDNSZoneVirtualNetworkLink())
+	}
+
 	if !cs.Properties.OrchestratorProfile.IsPrivateCluster() {
 		isForMaster := true
 		publicIPAddress := CreatePublicIPAddress(isForMaster)
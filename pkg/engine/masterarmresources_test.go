@@ -5,6 +5,7 @@ package engine
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/Azure/aks-engine/pkg/api"
@@ -851,3 +852,148 @@ func TestCreateKubernetesMasterResourcesVMSS(t *testing.T) {
 		t.Errorf("unexpected error while comparing ARM resources: %s", diff)
 	}
 }
+
+func TestCreateKubernetesMasterResourcesCustomRouteTableAndNSG(t *testing.T) {
+	cs := &api.ContainerService{
+		Properties: &api.Properties{
+			ServicePrincipalProfile: &api.ServicePrincipalProfile{
+				ClientID: "barClientID",
+				Secret:   "bazSecret",
+			},
+			MasterProfile: &api.MasterProfile{
+				Count:                    1,
+				DNSPrefix:                "blueorange",
+				VMSize:                   "Standard_D2_v2",
+				VnetSubnetID:             "/subscriptions/SUB_ID/resourceGroups/NETWORK_RG/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME",
+				AgentVnetSubnetID:        "/subscriptions/SUB_ID/resourceGroups/NETWORK_RG/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/AGENT_SUBNET_NAME",
+				FirstConsecutiveStaticIP: "10.239.255.239",
+			},
+			OrchestratorProfile: &api.OrchestratorProfile{
+				OrchestratorType: api.Kubernetes,
+				KubernetesConfig: &api.KubernetesConfig{
+					NetworkPlugin: "kubenet",
+				},
+			},
+			LinuxProfile: &api.LinuxProfile{},
+		},
+	}
+
+	cs.SetPropertiesDefaults(false, false)
+
+	countResources := func(resources []interface{}) (nsgs, routeTables int) {
+		for _, resource := range resources {
+			switch resource.(type) {
+			case NetworkSecurityGroupARM:
+				nsgs++
+			case RouteTableARM:
+				routeTables++
+			}
+		}
+		return nsgs, routeTables
+	}
+
+	nsgs, routeTables := countResources(createKubernetesMasterResourcesVMAS(cs))
+	if nsgs != 1 || routeTables != 1 {
+		t.Errorf("expected 1 network security group and 1 route table, got %d and %d", nsgs, routeTables)
+	}
+
+	cs.Properties.MasterProfile.RouteTableID = "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable"
+	cs.Properties.MasterProfile.NetworkSecurityGroupID = "/subscriptions/SUB_ID/resourceGroups/SECURITY_RG/providers/Microsoft.Network/networkSecurityGroups/hub-nsg"
+
+	nsgs, routeTables = countResources(createKubernetesMasterResourcesVMAS(cs))
+	if nsgs != 0 || routeTables != 0 {
+		t.Errorf("expected no network security group and no route table with existing ones, got %d and %d", nsgs, routeTables)
+	}
+
+	cs.Properties.MasterProfile.AvailabilityProfile = api.VirtualMachineScaleSets
+
+	nsgs, routeTables = countResources(createKubernetesMasterResourcesVMSS(cs))
+	if nsgs != 0 || routeTables != 0 {
+		t.Errorf("expected no network security group and no route table with existing ones, got %d and %d", nsgs, routeTables)
+	}
+}
+
+func TestCreateKubernetesMasterResourcesFullyPrivate(t *testing.T) {
+	cs := &api.ContainerService{
+		Properties: &api.Properties{
+			ServicePrincipalProfile: &api.ServicePrincipalProfile{
+				ClientID: "barClientID",
+				Secret:   "bazSecret",
+			},
+			MasterProfile: &api.MasterProfile{
+				Count:                    3,
+				DNSPrefix:                "blueorange",
+				VMSize:                   "Standard_D2_v2",
+				VnetSubnetID:             "/subscriptions/SUB_ID/resourceGroups/NETWORK_RG/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/SUBNET_NAME",
+				AgentVnetSubnetID:        "/subscriptions/SUB_ID/resourceGroups/NETWORK_RG/providers/Microsoft.Network/virtualNetworks/VNET_NAME/subnets/AGENT_SUBNET_NAME",
+				FirstConsecutiveStaticIP: "10.239.255.239",
+				RouteTableID:             "/subscriptions/SUB_ID/resourceGroups/HUB_RG/providers/Microsoft.Network/routeTables/hub-routetable",
+			},
+			OrchestratorProfile: &api.OrchestratorProfile{
+				OrchestratorType: api.Kubernetes,
+				KubernetesConfig: &api.KubernetesConfig{
+					NetworkPlugin: "kubenet",
+					PrivateCluster: &api.PrivateCluster{
+						Enabled:      to.BoolPtr(true),
+						FullyPrivate: to.BoolPtr(true),
+						JumpboxProfile: &api.PrivateJumpboxProfile{
+							Name:      "jumpbox",
+							VMSize:    "Standard_D2_v2",
+							PublicKey: "ssh-rsa PUBLICKEY azureuser@linuxvm",
+						},
+					},
+				},
+			},
+			LinuxProfile: &api.LinuxProfile{},
+		},
+	}
+
+	cs.SetPropertiesDefaults(false, false)
+
+	countResources := func(resources []interface{}) (publicIPs, privateDNSResources int) {
+		fullyPrivate := cs.Properties.OrchestratorProfile.IsFullyPrivateCluster()
+		for _, resource := range resources {
+			switch r := resource.(type) {
+			case PublicIPAddressARM:
+				publicIPs++
+			case PrivateDNSZoneARM, PrivateDNSZoneRecordSetARM, PrivateDNSZoneVirtualNetworkLinkARM:
+				privateDNSResources++
+			case NetworkInterfaceARM:
+				if !fullyPrivate {
+					continue
+				}
+				for _, ipConfig := range *r.IPConfigurations {
+					if ipConfig.PublicIPAddress != nil {
+						t.Errorf("expected no public IP address on network interface %s", to.String(r.Name))
+					}
+				}
+				for _, dependency := range r.DependsOn {
+					if strings.Contains(dependency, "publicIpAddresses") {
+						t.Errorf("expected no dependency on a public IP address for network interface %s", to.String(r.Name))
+					}
+				}
+			}
+		}
+		return publicIPs, privateDNSResources
+	}
+
+	publicIPs, privateDNSResources := countResources(createKubernetesMasterResourcesVMAS(cs))
+	if publicIPs != 0 || privateDNSResources != 3 {
+		t.Errorf("expected no public IP addresses and 3 private DNS resources, got %d and %d", publicIPs, privateDNSResources)
+	}
+
+	cs.Properties.MasterProfile.AvailabilityProfile = api.VirtualMachineScaleSets
+
+	publicIPs, privateDNSResources = countResources(createKubernetesMasterResourcesVMSS(cs))
+	if publicIPs != 0 || privateDNSResources != 3 {
+		t.Errorf("expected no public IP addresses and 3 private DNS resources, got %d and %d", publicIPs, privateDNSResources)
+	}
+
+	cs.Properties.OrchestratorProfile.KubernetesConfig.PrivateCluster.FullyPrivate = to.BoolPtr(false)
+	cs.Properties.MasterProfile.AvailabilityProfile = api.AvailabilitySet
+
+	publicIPs, privateDNSResources = countResources(createKubernetesMasterResourcesVMAS(cs))
+	if publicIPs != 1 || privateDNSResources != 0 {
+		t.Errorf("expected the jumpbox public IP address and no private DNS resources, got %d and %d", publicIPs, privateDNSResources)
+	}
+}
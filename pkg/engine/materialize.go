@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+// MaterializedTemplateFileName is the name of the file holding the materialized ARM template of a cluster.
+const MaterializedTemplateFileName = "azuredeploy.materialized.json"
+
+// MaterializeTemplate resolves the contents of azuredeploy.json and azuredeploy.parameters.json into the
+// flat list of resources they deploy, with concrete names and copy loops expanded, for the deployment
+// scope described by env. parameters may be either a deployment parameters file or its "parameters" object.
+// It returns a warning for every distinct expression that can only be resolved at deployment time,
+// which is left unchanged in the result. The output is stable for a given input so it can be diffed.
+func MaterializeTemplate(template, parameters []byte, env armeval.Environment) (materialized string, warnings []string, err error) {
+	var templateMap, parametersMap map[string]interface{}
+	if err = json.Unmarshal(template, &templateMap); err != nil {
+		return "", nil, errors.Wrap(err, "decoding template")
+	}
+	if err = json.Unmarshal(parameters, &parametersMap); err != nil {
+		return "", nil, errors.Wrap(err, "decoding template parameters")
+	}
+	if values, ok := parametersMap["parameters"].(map[string]interface{}); ok {
+		// a template parameter named "parameters" would have a value instead
+		if _, isParameter := values["value"]; !isParameter {
+			parametersMap = values
+		}
+	}
+
+	result, unresolved, err := armeval.Materialize(templateMap, parametersMap, env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, u := range unresolved {
+		warning := fmt.Sprintf("expression %s cannot be resolved offline: %s", abbreviate(u.Expression), u.Reason)
+		if !seen[warning] {
+			seen[warning] = true
+			warnings = append(warnings, warning)
+		}
+	}
+	sort.Strings(warnings)
+
+	b, err := helpers.JSONMarshalIndent(result, "", "  ", false)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(b), warnings, nil
+}
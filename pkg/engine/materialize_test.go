@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMaterializeTemplate(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", common.RationalizeReleaseAndVersion(api.Kubernetes, "", "", false, false), 3, 2, false)
+	cs.Location = "westus2"
+	if _, err := cs.SetPropertiesDefaults(false, false); err != nil {
+		t.Fatalf("unexpected error setting defaults: %s", err)
+	}
+	tg, err := InitializeTemplateGenerator(Context{})
+	if err != nil {
+		t.Fatalf("unexpected error initializing the template generator: %s", err)
+	}
+	template, parameters, err := tg.GenerateTemplateV2(cs, DefaultGeneratorCode, TestAKSEngineVersion)
+	if err != nil {
+		t.Fatalf("unexpected error generating the template: %s", err)
+	}
+	parametersFile, err := transform.BuildAzureParametersFile(parameters)
+	if err != nil {
+		t.Fatalf("unexpected error building the parameters file: %s", err)
+	}
+
+	env := armeval.Environment{
+		SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+		ResourceGroupName:     "myrg",
+		ResourceGroupLocation: "westus2",
+	}
+	materialized, warnings, err := MaterializeTemplate([]byte(template), []byte(parametersFile), env)
+	if err != nil {
+		t.Fatalf("unexpected error materializing the template: %s", err)
+	}
+	for _, s := range []string{"copyIndex(", "parameters(", "variables("} {
+		if strings.Contains(materialized, s) {
+			t.Errorf("expected the materialized template not to contain %s", s)
+		}
+	}
+	for i := 1; i < len(warnings); i++ {
+		if warnings[i-1] >= warnings[i] {
+			t.Errorf("expected sorted and distinct warnings, got %q before %q", warnings[i-1], warnings[i])
+		}
+	}
+
+	var result armeval.MaterializedTemplate
+	if err = json.Unmarshal([]byte(materialized), &result); err != nil {
+		t.Fatalf("unexpected error decoding the materialized template: %s", err)
+	}
+	var masters []string
+	for _, r := range result.Resources {
+		resource := r.(map[string]interface{})
+		if resource["type"] == "Microsoft.Compute/virtualMachines" && strings.Contains(resource["name"].(string), "master") {
+			masters = append(masters, resource["name"].(string))
+		}
+	}
+	prefix := "k8s-master-" + cs.Properties.GetClusterID() + "-"
+	if diff := cmp.Diff([]string{prefix + "0", prefix + "1", prefix + "2"}, masters); diff != "" {
+		t.Errorf("unexpected master VMs (-want +got):\n%s", diff)
+	}
+
+	// the parameters object returned by the generator is accepted as well, with the same result
+	again, _, err := MaterializeTemplate([]byte(template), []byte(parameters), env)
+	if err != nil {
+		t.Fatalf("unexpected error materializing the template: %s", err)
+	}
+	if again != materialized {
+		t.Errorf("expected the materialized template to be stable")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// NAT gateways were introduced after the network API version vendored from azure-sdk-for-go,
+// so the subset of the Microsoft.Network/natGateways schema used by aks-engine is modeled here.
+
+// NATGateway describes a Microsoft.Network/natGateways resource.
+type NATGateway struct {
+	Name                  *string        `json:"name,omitempty"`
+	Type                  *string        `json:"type,omitempty"`
+	Location              *string        `json:"location,omitempty"`
+	Sku                   *NATGatewaySku `json:"sku,omitempty"`
+	*NATGatewayProperties `json:"properties,omitempty"`
+}
+
+// MarshalJSON is the custom marshaler for NATGateway.
+func (n NATGateway) MarshalJSON() ([]byte, error) {
+	type Alias NATGateway
+	return json.Marshal(Alias(n))
+}
+
+// NATGatewaySku describes the SKU of a NAT gateway.
+type NATGatewaySku struct {
+	Name string `json:"name,omitempty"`
+}
+
+// NATGatewayProperties describes the properties of a NAT gateway.
+type NATGatewayProperties struct {
+	IdleTimeoutInMinutes *int32                 `json:"idleTimeoutInMinutes,omitempty"`
+	PublicIPAddresses    *[]network.SubResource `json:"publicIpAddresses,omitempty"`
+}
+
+// VirtualNetworkNATGatewayARM decorates a VirtualNetworkARM by associating the NAT gateway of the cluster
+// with its subnets, which the vendored network API version can't express.
+type VirtualNetworkNATGatewayARM struct {
+	VirtualNetworkARM
+	// SubnetNames are the names of the subnets associated with the NAT gateway.
+	SubnetNames []string `json:"-"`
+}
+
+// MarshalJSON is the custom marshaler for a VirtualNetworkNATGatewayARM.
+// It adds a "natGateway" reference to the properties of every subnet in SubnetNames.
+func (v VirtualNetworkNATGatewayARM) MarshalJSON() ([]byte, error) {
+	bytes, err := json.Marshal(v.VirtualNetworkARM)
+	if err != nil {
+		return nil, err
+	}
+
+	var vnet map[string]interface{}
+	if err = json.Unmarshal(bytes, &vnet); err != nil {
+		return nil, err
+	}
+	properties, ok := vnet["properties"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("virtual network has no properties")
+	}
+	subnets, _ := properties["subnets"].([]interface{})
+	for _, s := range subnets {
+		subnet, ok := s.(map[string]interface{})
+		if !ok || !isNATGatewaySubnet(subnet["name"], v.SubnetNames) {
+			continue
+		}
+		subnetProperties, ok := subnet["properties"].(map[string]interface{})
+		if !ok {
+			subnetProperties = map[string]interface{}{}
+			subnet["properties"] = subnetProperties
+		}
+		subnetProperties["natGateway"] = map[string]interface{}{
+			"id": "[variables('natGatewayID')]",
+		}
+	}
+
+	return json.Marshal(vnet)
+}
+
+func isNATGatewaySubnet(name interface{}, subnetNames []string) bool {
+	for _, subnetName := range subnetNames {
+		if name == subnetName {
+			return true
+		}
+	}
+	return false
+}
+
+func createNATGateway(cs *api.ContainerService) NATGatewayARM {
+	return NATGatewayARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionNATGateway')]",
+			DependsOn: []string{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('natGatewayPublicIPAddressName'))]",
+			},
+		},
+		NATGateway: NATGateway{
+			Location: to.StringPtr("[variables('location')]"),
+			Name:     to.StringPtr("[variables('natGatewayName')]"),
+			Type:     to.StringPtr("Microsoft.Network/natGateways"),
+			Sku: &NATGatewaySku{
+				Name: "Standard",
+			},
+			NATGatewayProperties: &NATGatewayProperties{
+				IdleTimeoutInMinutes: to.Int32Ptr(cs.Properties.OrchestratorProfile.KubernetesConfig.OutboundRuleIdleTimeoutInMinutes),
+				PublicIPAddresses: &[]network.SubResource{
+					{
+						ID: to.StringPtr("[resourceId('Microsoft.Network/publicIPAddresses', variables('natGatewayPublicIPAddressName'))]"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// associateNATGateway associates the NAT gateway of the cluster with every subnet of the virtual network,
+// except the application gateway subnet which doesn't support NAT gateways.
+func associateNATGateway(vnet VirtualNetworkARM) VirtualNetworkNATGatewayARM {
+	var subnetNames []string
+	if vnet.Subnets != nil {
+		for _, subnet := range *vnet.Subnets {
+			name := to.String(subnet.Name)
+			if name != "[variables('appGwSubnetName')]" {
+				subnetNames = append(subnetNames, name)
+			}
+		}
+	}
+	vnet.APIVersion = "[variables('apiVersionNATGateway')]"
+	vnet.DependsOn = append(vnet.DependsOn, "[concat('Microsoft.Network/natGateways/', variables('natGatewayName'))]")
+	return VirtualNetworkNATGatewayARM{
+		VirtualNetworkARM: vnet,
+		SubnetNames:       subnetNames,
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateNATGateway(t *testing.T) {
+	cs := &api.ContainerService{
+		Properties: &api.Properties{
+			OrchestratorProfile: &api.OrchestratorProfile{
+				KubernetesConfig: &api.KubernetesConfig{
+					OutboundType:                     api.OutboundTypeNATGateway,
+					OutboundRuleIdleTimeoutInMinutes: 30,
+				},
+			},
+		},
+	}
+
+	expected := NATGatewayARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionNATGateway')]",
+			DependsOn: []string{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('natGatewayPublicIPAddressName'))]",
+			},
+		},
+		NATGateway: NATGateway{
+			Location: to.StringPtr("[variables('location')]"),
+			Name:     to.StringPtr("[variables('natGatewayName')]"),
+			Type:     to.StringPtr("Microsoft.Network/natGateways"),
+			Sku: &NATGatewaySku{
+				Name: "Standard",
+			},
+			NATGatewayProperties: &NATGatewayProperties{
+				IdleTimeoutInMinutes: to.Int32Ptr(30),
+				PublicIPAddresses: &[]network.SubResource{
+					{
+						ID: to.StringPtr("[resourceId('Microsoft.Network/publicIPAddresses', variables('natGatewayPublicIPAddressName'))]"),
+					},
+				},
+			},
+		},
+	}
+
+	actual := createNATGateway(cs)
+
+	diff := cmp.Diff(actual, expected)
+	if diff != "" {
+		t.Errorf("unexpected diff while expecting equal structs: %s", diff)
+	}
+
+	bytes, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling NAT gateway: %s", err.Error())
+	}
+	expectedJSON := `{"apiVersion":"[variables('apiVersionNATGateway')]","dependsOn":["[concat('Microsoft.Network/publicIPAddresses/', variables('natGatewayPublicIPAddressName'))]"],"name":"[variables('natGatewayName')]","type":"Microsoft.Network/natGateways","location":"[variables('location')]","sku":{"name":"Standard"},"properties":{"idleTimeoutInMinutes":30,"publicIpAddresses":[{"id":"[resourceId('Microsoft.Network/publicIPAddresses', variables('natGatewayPublicIPAddressName'))]"}]}}`
+	if string(bytes) != expectedJSON {
+		t.Errorf("unexpected NAT gateway JSON:\n%s\nexpected:\n%s", string(bytes), expectedJSON)
+	}
+}
+
+func TestAssociateNATGateway(t *testing.T) {
+	vnet := VirtualNetworkARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionNetwork')]",
+			DependsOn: []string{
+				"[concat('Microsoft.Network/networkSecurityGroups/', variables('nsgName'))]",
+			},
+		},
+		VirtualNetwork: network.VirtualNetwork{
+			Name: to.StringPtr("[variables('virtualNetworkName')]"),
+			VirtualNetworkPropertiesFormat: &network.VirtualNetworkPropertiesFormat{
+				Subnets: &[]network.Subnet{
+					{
+						Name: to.StringPtr("[variables('subnetName')]"),
+						SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+							AddressPrefix: to.StringPtr("[parameters('masterSubnet')]"),
+						},
+					},
+					{
+						Name: to.StringPtr("[variables('appGwSubnetName')]"),
+						SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+							AddressPrefix: to.StringPtr("[parameters('appGwSubnet')]"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	actual := associateNATGateway(vnet)
+
+	if actual.APIVersion != "[variables('apiVersionNATGateway')]" {
+		t.Errorf("unexpected virtual network API version %s", actual.APIVersion)
+	}
+	expectedDependsOn := []string{
+		"[concat('Microsoft.Network/networkSecurityGroups/', variables('nsgName'))]",
+		"[concat('Microsoft.Network/natGateways/', variables('natGatewayName'))]",
+	}
+	if diff := cmp.Diff(actual.DependsOn, expectedDependsOn); diff != "" {
+		t.Errorf("unexpected diff in virtual network dependencies: %s", diff)
+	}
+
+	bytes, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling virtual network: %s", err.Error())
+	}
+	var marshaled struct {
+		Properties struct {
+			Subnets []struct {
+				Name       string `json:"name"`
+				Properties struct {
+					NATGateway *network.SubResource `json:"natGateway"`
+				} `json:"properties"`
+			} `json:"subnets"`
+		} `json:"properties"`
+	}
+	if err = json.Unmarshal(bytes, &marshaled); err != nil {
+		t.Fatalf("unexpected error unmarshaling virtual network: %s", err.Error())
+	}
+	if len(marshaled.Properties.Subnets) != 2 {
+		t.Fatalf("expected 2 subnets, got %d", len(marshaled.Properties.Subnets))
+	}
+	for _, subnet := range marshaled.Properties.Subnets {
+		isAppGwSubnet := subnet.Name == "[variables('appGwSubnetName')]"
+		if isAppGwSubnet && subnet.Properties.NATGateway != nil {
+			t.Errorf("expected no NAT gateway on the application gateway subnet")
+		}
+		if !isAppGwSubnet && (subnet.Properties.NATGateway == nil || to.String(subnet.Properties.NATGateway.ID) != "[variables('natGatewayID')]") {
+			t.Errorf("expected subnet %s to be associated with the NAT gateway", subnet.Name)
+		}
+	}
+}
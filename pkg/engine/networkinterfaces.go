@@ -15,7 +15,9 @@ func CreateNetworkInterfaces(cs *api.ContainerService) NetworkInterfaceARM {
 
 	var dependencies []string
 	if cs.Properties.MasterProfile != nil && cs.Properties.MasterProfile.IsCustomVNET() {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
@@ -138,7 +140,9 @@ func CreateNetworkInterfaces(cs *api.ContainerService) NetworkInterfaceARM {
 func createPrivateClusterNetworkInterface(cs *api.ContainerService) NetworkInterfaceARM {
 	var dependencies []string
 	if cs.Properties.MasterProfile.IsCustomVNET() {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
@@ -236,11 +240,15 @@ func createPrivateClusterNetworkInterface(cs *api.ContainerService) NetworkInter
 }
 
 func createJumpboxNetworkInterface(cs *api.ContainerService) NetworkInterfaceARM {
+	isFullyPrivate := cs.Properties.OrchestratorProfile.IsFullyPrivateCluster()
 	dependencies := []string{
-		"[concat('Microsoft.Network/publicIpAddresses/', variables('jumpboxPublicIpAddressName'))]",
 		"[concat('Microsoft.Network/networkSecurityGroups/', variables('jumpboxNetworkSecurityGroupName'))]",
 	}
 
+	if !isFullyPrivate {
+		dependencies = append([]string{"[concat('Microsoft.Network/publicIpAddresses/', variables('jumpboxPublicIpAddressName'))]"}, dependencies...)
+	}
+
 	if !cs.Properties.MasterProfile.IsCustomVNET() {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
@@ -260,9 +268,6 @@ func createJumpboxNetworkInterface(cs *api.ContainerService) NetworkInterfaceARM
 					},
 					Primary:                   to.BoolPtr(true),
 					PrivateIPAllocationMethod: network.Dynamic,
-					PublicIPAddress: &network.PublicIPAddress{
-						ID: to.StringPtr("[resourceId('Microsoft.Network/publicIpAddresses', variables('jumpboxPublicIpAddressName'))]"),
-					},
 				},
 			},
 		},
@@ -271,6 +276,12 @@ func createJumpboxNetworkInterface(cs *api.ContainerService) NetworkInterfaceARM
 		},
 	}
 
+	if !isFullyPrivate {
+		(*nicProperties.IPConfigurations)[0].PublicIPAddress = &network.PublicIPAddress{
+			ID: to.StringPtr("[resourceId('Microsoft.Network/publicIpAddresses', variables('jumpboxPublicIpAddressName'))]"),
+		}
+	}
+
 	networkInterface := network.Interface{
 		Location:                  to.StringPtr("[variables('location')]"),
 		Name:                      to.StringPtr("[variables('jumpboxNetworkInterfaceName')]"),
@@ -300,13 +311,16 @@ func createAgentVMASNetworkInterface(cs *api.ContainerService, profile *api.Agen
 	var dependencies []string
 
 	if isCustomVNet {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
 	if !cs.Properties.OrchestratorProfile.IsPrivateCluster() &&
 		profile.LoadBalancerBackendAddressPoolIDs == nil &&
-		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku &&
+		cs.Properties.OrchestratorProfile.KubernetesConfig.IsLoadBalancerOutbound() {
 		dependencies = append(dependencies, "[variables('agentLbID')]")
 	}
 
@@ -351,7 +365,8 @@ func createAgentVMASNetworkInterface(cs *api.ContainerService, profile *api.Agen
 				}
 			} else {
 				if !cs.Properties.OrchestratorProfile.IsPrivateCluster() &&
-					cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+					cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku &&
+					cs.Properties.OrchestratorProfile.KubernetesConfig.IsLoadBalancerOutbound() {
 					agentLbBackendAddressPools := network.BackendAddressPool{
 						ID: to.StringPtr("[concat(variables('agentLbID'), '/backendAddressPools/', variables('agentLbBackendPoolName'))]"),
 					}
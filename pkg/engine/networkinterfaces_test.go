@@ -162,6 +162,23 @@ func TestCreateNIC(t *testing.T) {
 		t.Errorf("unexpected diff while expecting equal structs: %s", diff)
 	}
 
+	// Test Master NIC with custom Vnet and an existing network security group
+	cs.Properties.MasterProfile.NetworkSecurityGroupID = "/subscriptions/fooSubscription/resourceGroups/fooRG/providers/Microsoft.Network/networkSecurityGroups/fooNSG"
+
+	expected.DependsOn = []string{
+		"[variables('masterLbName')]",
+	}
+
+	nic = CreateNetworkInterfaces(cs)
+
+	diff = cmp.Diff(nic, expected)
+
+	if diff != "" {
+		t.Errorf("unexpected diff while expecting equal structs: %s", diff)
+	}
+
+	cs.Properties.MasterProfile.NetworkSecurityGroupID = ""
+
 	// Test Master NIC with MultiMaster
 
 	cs.Properties.MasterProfile.Count = 3
@@ -49,6 +49,8 @@ func CreateNetworkSecurityGroup(cs *api.ContainerService) NetworkSecurityGroupAR
 		kubeTLSRule.SourceAddressPrefix = &source
 	}
 
+	restrictSSHSource(cs, &sshRule)
+
 	securityRules := []network.SecurityRule{
 		sshRule,
 		kubeTLSRule,
@@ -142,6 +144,12 @@ func CreateNetworkSecurityGroup(cs *api.ContainerService) NetworkSecurityGroupAR
 		securityRules = append(securityRules, allowVnetOutbound)
 	}
 
+	if cs.Properties.NetworkSecurityGroupProfile != nil {
+		for _, rule := range cs.Properties.NetworkSecurityGroupProfile.SecurityRules {
+			securityRules = append(securityRules, createCustomSecurityRule(rule))
+		}
+	}
+
 	nsg := network.SecurityGroup{
 		Location: to.StringPtr("[variables('location')]"),
 		Name:     to.StringPtr("[variables('nsgName')]"),
@@ -157,26 +165,30 @@ func CreateNetworkSecurityGroup(cs *api.ContainerService) NetworkSecurityGroupAR
 	}
 }
 
-func createJumpboxNSG() NetworkSecurityGroupARM {
+func createJumpboxNSG(cs *api.ContainerService) NetworkSecurityGroupARM {
 	armResource := ARMResource{
 		APIVersion: "[variables('apiVersionNetwork')]",
 	}
 
-	securityRules := []network.SecurityRule{
-		{
-			Name: to.StringPtr("default-allow-ssh"),
-			SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
-				Priority:                 to.Int32Ptr(1000),
-				Protocol:                 network.SecurityRuleProtocolTCP,
-				Access:                   network.SecurityRuleAccessAllow,
-				Direction:                network.SecurityRuleDirectionInbound,
-				SourceAddressPrefix:      to.StringPtr("*"),
-				SourcePortRange:          to.StringPtr("*"),
-				DestinationAddressPrefix: to.StringPtr("*"),
-				DestinationPortRange:     to.StringPtr("22"),
-			},
+	sshRule := network.SecurityRule{
+		Name: to.StringPtr("default-allow-ssh"),
+		SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+			Priority:                 to.Int32Ptr(1000),
+			Protocol:                 network.SecurityRuleProtocolTCP,
+			Access:                   network.SecurityRuleAccessAllow,
+			Direction:                network.SecurityRuleDirectionInbound,
+			SourceAddressPrefix:      to.StringPtr("*"),
+			SourcePortRange:          to.StringPtr("*"),
+			DestinationAddressPrefix: to.StringPtr("*"),
+			DestinationPortRange:     to.StringPtr("22"),
 		},
 	}
+
+	restrictSSHSource(cs, &sshRule)
+
+	securityRules := []network.SecurityRule{
+		sshRule,
+	}
 	nsg := network.SecurityGroup{
 		Location: to.StringPtr("[variables('location')]"),
 		Name:     to.StringPtr("[variables('jumpboxNetworkSecurityGroupName')]"),
@@ -209,3 +221,67 @@ func createHostedMasterNSG() NetworkSecurityGroupARM {
 		SecurityGroup: nsg,
 	}
 }
+
+// restrictSSHSource limits the source of an SSH rule to the address prefixes of the NetworkSecurityGroupProfile, if any
+func restrictSSHSource(cs *api.ContainerService, sshRule *network.SecurityRule) {
+	if cs.Properties.NetworkSecurityGroupProfile == nil || len(cs.Properties.NetworkSecurityGroupProfile.SSHSourceAddressPrefixes) == 0 {
+		return
+	}
+	prefixes := cs.Properties.NetworkSecurityGroupProfile.SSHSourceAddressPrefixes
+	if len(prefixes) == 1 {
+		sshRule.SourceAddressPrefix = to.StringPtr(prefixes[0])
+	} else {
+		sshRule.SourceAddressPrefix = nil
+		sshRule.SourceAddressPrefixes = &prefixes
+	}
+}
+
+func createCustomSecurityRule(rule api.SecurityRule) network.SecurityRule {
+	securityRule := network.SecurityRule{
+		Name: to.StringPtr(rule.Name),
+		SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+			Access:               network.SecurityRuleAccess(rule.Access),
+			Direction:            network.SecurityRuleDirection(rule.Direction),
+			Priority:             to.Int32Ptr(rule.Priority),
+			Protocol:             network.SecurityRuleProtocol(rule.Protocol),
+			SourcePortRange:      to.StringPtr("*"),
+			DestinationPortRange: to.StringPtr("*"),
+		},
+	}
+
+	if rule.Description != "" {
+		securityRule.Description = to.StringPtr(rule.Description)
+	}
+	if rule.SourcePortRange != "" {
+		securityRule.SourcePortRange = to.StringPtr(rule.SourcePortRange)
+	}
+	if rule.DestinationPortRange != "" {
+		securityRule.DestinationPortRange = to.StringPtr(rule.DestinationPortRange)
+	}
+
+	if len(rule.SourceAddressPrefixes) > 0 {
+		prefixes := rule.SourceAddressPrefixes
+		securityRule.SourceAddressPrefixes = &prefixes
+	} else if rule.SourceAddressPrefix != "" {
+		securityRule.SourceAddressPrefix = to.StringPtr(rule.SourceAddressPrefix)
+	} else {
+		securityRule.SourceAddressPrefix = to.StringPtr("*")
+	}
+
+	if len(rule.DestinationAddressPrefixes) > 0 {
+		prefixes := rule.DestinationAddressPrefixes
+		securityRule.DestinationAddressPrefixes = &prefixes
+	} else if rule.DestinationAddressPrefix != "" {
+		securityRule.DestinationAddressPrefix = to.StringPtr(rule.DestinationAddressPrefix)
+	} else {
+		securityRule.DestinationAddressPrefix = to.StringPtr("*")
+	}
+
+	return securityRule
+}
+
+// isCustomNSG returns true if the cluster references an existing network security group
+// instead of creating its own, in which case nothing in the template can depend on it.
+func isCustomNSG(cs *api.ContainerService) bool {
+	return cs.Properties.MasterProfile != nil && cs.Properties.MasterProfile.IsCustomNSG()
+}
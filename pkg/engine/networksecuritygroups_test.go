@@ -155,6 +155,87 @@ func TestCreateNetworkSecurityGroup(t *testing.T) {
 	if diff != "" {
 		t.Errorf("unexpected diff while comparing nsgs : %s", diff)
 	}
+
+	// Test Create NSG with restricted SSH sources and custom rules
+
+	cs.Properties.NetworkSecurityGroupProfile = &api.NetworkSecurityGroupProfile{
+		SSHSourceAddressPrefixes: []string{"10.100.0.0/16", "192.168.1.10"},
+		SecurityRules: []api.SecurityRule{
+			{
+				Name:                 "allow_prometheus",
+				Description:          "Allow prometheus scraping from the monitoring network",
+				Protocol:             "Tcp",
+				DestinationPortRange: "9100",
+				SourceAddressPrefix:  "10.100.0.0/16",
+				Access:               "Allow",
+				Priority:             200,
+				Direction:            "Inbound",
+			},
+			{
+				Name:                       "allow_outbound_registry",
+				Protocol:                   "*",
+				DestinationAddressPrefixes: []string{"52.0.0.0/8", "13.0.0.0/8"},
+				Access:                     "Allow",
+				Priority:                   130,
+				Direction:                  "Outbound",
+			},
+		},
+	}
+
+	actual = CreateNetworkSecurityGroup(cs)
+
+	sshRule := rules[0]
+	sshRule.SecurityRulePropertiesFormat = &network.SecurityRulePropertiesFormat{
+		Access:                   network.SecurityRuleAccessAllow,
+		Description:              to.StringPtr("Allow SSH traffic to master"),
+		DestinationAddressPrefix: to.StringPtr("*"),
+		DestinationPortRange:     to.StringPtr("22-22"),
+		Direction:                network.SecurityRuleDirectionInbound,
+		Priority:                 to.Int32Ptr(101),
+		Protocol:                 network.SecurityRuleProtocolTCP,
+		SourceAddressPrefixes:    &[]string{"10.100.0.0/16", "192.168.1.10"},
+		SourcePortRange:          to.StringPtr("*"),
+	}
+
+	prometheusRule := network.SecurityRule{
+		Name: to.StringPtr("allow_prometheus"),
+		SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+			Access:                   network.SecurityRuleAccessAllow,
+			Description:              to.StringPtr("Allow prometheus scraping from the monitoring network"),
+			DestinationAddressPrefix: to.StringPtr("*"),
+			DestinationPortRange:     to.StringPtr("9100"),
+			Direction:                network.SecurityRuleDirectionInbound,
+			Priority:                 to.Int32Ptr(200),
+			Protocol:                 network.SecurityRuleProtocolTCP,
+			SourceAddressPrefix:      to.StringPtr("10.100.0.0/16"),
+			SourcePortRange:          to.StringPtr("*"),
+		},
+	}
+
+	registryRule := network.SecurityRule{
+		Name: to.StringPtr("allow_outbound_registry"),
+		SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+			Access:                     network.SecurityRuleAccessAllow,
+			DestinationAddressPrefixes: &[]string{"52.0.0.0/8", "13.0.0.0/8"},
+			DestinationPortRange:       to.StringPtr("*"),
+			Direction:                  network.SecurityRuleDirectionOutbound,
+			Priority:                   to.Int32Ptr(130),
+			Protocol:                   network.SecurityRuleProtocolAsterisk,
+			SourceAddressPrefix:        to.StringPtr("*"),
+			SourcePortRange:            to.StringPtr("*"),
+		},
+	}
+
+	customRules := append([]network.SecurityRule{sshRule}, rules[1:]...)
+	customRules = append(customRules, prometheusRule, registryRule)
+
+	expected.SecurityRules = &customRules
+
+	diff = cmp.Diff(actual, expected)
+
+	if diff != "" {
+		t.Errorf("unexpected diff while comparing nsgs : %s", diff)
+	}
 }
 
 func TestCreateJumpboxNSG(t *testing.T) {
@@ -186,13 +267,33 @@ func TestCreateJumpboxNSG(t *testing.T) {
 		},
 	}
 
-	actual := createJumpboxNSG()
+	cs := &api.ContainerService{
+		Properties: &api.Properties{},
+	}
+
+	actual := createJumpboxNSG(cs)
 
 	diff := cmp.Diff(actual, expected)
 
 	if diff != "" {
 		t.Errorf("unexpected diff while comparing nsgs : %s", diff)
 	}
+
+	// Test Create jumpbox NSG with a single restricted SSH source
+
+	cs.Properties.NetworkSecurityGroupProfile = &api.NetworkSecurityGroupProfile{
+		SSHSourceAddressPrefixes: []string{"10.100.0.0/16"},
+	}
+
+	actual = createJumpboxNSG(cs)
+
+	(*expected.SecurityRules)[0].SourceAddressPrefix = to.StringPtr("10.100.0.0/16")
+
+	diff = cmp.Diff(actual, expected)
+
+	if diff != "" {
+		t.Errorf("unexpected diff while comparing nsgs : %s", diff)
+	}
 }
 
 func TestCreateHostedMasterNSG(t *testing.T) {
@@ -17,6 +17,9 @@ import (
 // ArtifactWriter represents the object that writes artifacts
 type ArtifactWriter struct {
 	Translator *i18n.Translator
+	// Secrets, when set, keeps the secrets of the api model in a secret provider: the api model is written
+	// with references to them, and the private keys are not written next to the certificates
+	Secrets *api.SecretStore
 }
 
 // WriteTLSArtifacts saves TLS certificates and keys to the server filesystem
@@ -34,10 +37,7 @@ func (w *ArtifactWriter) WriteTLSArtifacts(containerService *api.ContainerServic
 	var b []byte
 	var err error
 	if !parametersOnly {
-		apiloader := &api.Apiloader{
-			Translator: w.Translator,
-		}
-		b, err = apiloader.SerializeContainerService(containerService, apiVersion)
+		b, err = w.serializeContainerService(containerService, apiVersion)
 
 		if err != nil {
 			return err
@@ -60,6 +60,76 @@ func (w *ArtifactWriter) WriteTLSArtifacts(containerService *api.ContainerServic
 		return nil
 	}
 
+	return w.writeCertificateArtifacts(f, containerService, artifactsDir)
+}
+
+// WriteTerraformArtifacts saves the Terraform configuration of a cluster along with its api model,
+// TLS certificates and keys to the server filesystem
+func (w *ArtifactWriter) WriteTerraformArtifacts(containerService *api.ContainerService, apiVersion, config, artifactsDir string, certsGenerated bool) error {
+	if len(artifactsDir) == 0 {
+		artifactsDir = fmt.Sprintf("%s-%s", containerService.Properties.OrchestratorProfile.OrchestratorType, containerService.Properties.GetClusterID())
+		artifactsDir = path.Join("_output", artifactsDir)
+	}
+
+	f := &helpers.FileSaver{
+		Translator: w.Translator,
+	}
+
+	b, err := w.serializeContainerService(containerService, apiVersion)
+	if err != nil {
+		return err
+	}
+	if e := f.SaveFile(artifactsDir, "apimodel.json", b); e != nil {
+		return e
+	}
+	if e := f.SaveFileString(artifactsDir, TerraformConfigFileName, config); e != nil {
+		return e
+	}
+
+	if !certsGenerated {
+		return nil
+	}
+
+	return w.writeCertificateArtifacts(f, containerService, artifactsDir)
+}
+
+// serializeContainerService serializes the api model, with references in place of its secrets if w.Secrets is set
+func (w *ArtifactWriter) serializeContainerService(containerService *api.ContainerService, apiVersion string) ([]byte, error) {
+	apiloader := &api.Apiloader{
+		Translator: w.Translator,
+	}
+	if w.Secrets == nil {
+		return apiloader.SerializeContainerService(containerService, apiVersion)
+	}
+	if err := w.Secrets.Externalize(containerService); err != nil {
+		return nil, errors.Wrap(err, "storing secrets")
+	}
+	b, err := apiloader.SerializeContainerService(containerService, apiVersion)
+	// the kubeconfig and the rest of the command still need the secrets themselves
+	if e := w.Secrets.Resolve(containerService); e != nil && err == nil {
+		err = e
+	}
+	return b, err
+}
+
+// savePrivateKey saves a private key, unless the secrets of the api model are kept in a secret provider
+func (w *ArtifactWriter) savePrivateKey(f *helpers.FileSaver, dir, file, key string) error {
+	if w.Secrets != nil {
+		return nil
+	}
+	return f.SaveFileString(dir, file, key)
+}
+
+// WriteMaterializedTemplate saves the materialized ARM template of a cluster to the server filesystem
+func (w *ArtifactWriter) WriteMaterializedTemplate(template, artifactsDir string) error {
+	f := &helpers.FileSaver{
+		Translator: w.Translator,
+	}
+	return f.SaveFileString(artifactsDir, MaterializedTemplateFileName, template)
+}
+
+// writeCertificateArtifacts saves the kubeconfigs, TLS certificates and keys of a Kubernetes cluster
+func (w *ArtifactWriter) writeCertificateArtifacts(f *helpers.FileSaver, containerService *api.ContainerService, artifactsDir string) error {
 	properties := containerService.Properties
 	if properties.OrchestratorProfile.IsKubernetes() {
 		directory := path.Join(artifactsDir, "kubeconfig")
@@ -80,37 +150,37 @@ func (w *ArtifactWriter) WriteTLSArtifacts(containerService *api.ContainerServic
 			}
 		}
 
-		if e := f.SaveFileString(artifactsDir, "ca.key", properties.CertificateProfile.CaPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "ca.key", properties.CertificateProfile.CaPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "ca.crt", properties.CertificateProfile.CaCertificate); e != nil {
 			return e
 		}
-		if e := f.SaveFileString(artifactsDir, "apiserver.key", properties.CertificateProfile.APIServerPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "apiserver.key", properties.CertificateProfile.APIServerPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "apiserver.crt", properties.CertificateProfile.APIServerCertificate); e != nil {
 			return e
 		}
-		if e := f.SaveFileString(artifactsDir, "client.key", properties.CertificateProfile.ClientPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "client.key", properties.CertificateProfile.ClientPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "client.crt", properties.CertificateProfile.ClientCertificate); e != nil {
 			return e
 		}
-		if e := f.SaveFileString(artifactsDir, "kubectlClient.key", properties.CertificateProfile.KubeConfigPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "kubectlClient.key", properties.CertificateProfile.KubeConfigPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "kubectlClient.crt", properties.CertificateProfile.KubeConfigCertificate); e != nil {
 			return e
 		}
-		if e := f.SaveFileString(artifactsDir, "etcdserver.key", properties.CertificateProfile.EtcdServerPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "etcdserver.key", properties.CertificateProfile.EtcdServerPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "etcdserver.crt", properties.CertificateProfile.EtcdServerCertificate); e != nil {
 			return e
 		}
-		if e := f.SaveFileString(artifactsDir, "etcdclient.key", properties.CertificateProfile.EtcdClientPrivateKey); e != nil {
+		if e := w.savePrivateKey(f, artifactsDir, "etcdclient.key", properties.CertificateProfile.EtcdClientPrivateKey); e != nil {
 			return e
 		}
 		if e := f.SaveFileString(artifactsDir, "etcdclient.crt", properties.CertificateProfile.EtcdClientCertificate); e != nil {
@@ -121,7 +191,7 @@ func (w *ArtifactWriter) WriteTLSArtifacts(containerService *api.ContainerServic
 				return errors.New("missing etcd peer certificate/key pair")
 			}
 			k := "etcdpeer" + strconv.Itoa(i) + ".key"
-			if e := f.SaveFileString(artifactsDir, k, properties.CertificateProfile.EtcdPeerPrivateKeys[i]); e != nil {
+			if e := w.savePrivateKey(f, artifactsDir, k, properties.CertificateProfile.EtcdPeerPrivateKeys[i]); e != nil {
 				return e
 			}
 			c := "etcdpeer" + strconv.Itoa(i) + ".crt"
@@ -5,8 +5,10 @@ package engine
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/azure"
@@ -14,6 +16,7 @@ import (
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/helpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/secrets"
 )
 
 func TestWriteTLSArtifacts(t *testing.T) {
@@ -135,3 +138,75 @@ func TestWriteTLSArtifacts(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteTerraformArtifacts(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.7.12", 1, 2, true)
+	writer := &ArtifactWriter{
+		Translator: &i18n.Translator{
+			Locale: nil,
+		},
+	}
+	dir := "_testterraformoutputdir"
+	defer os.RemoveAll(dir)
+
+	err := writer.WriteTerraformArtifacts(cs, "vlabs", "fake config", dir, true)
+	if err != nil {
+		t.Fatalf("unexpected error trying to write Terraform artifacts: %s", err.Error())
+	}
+
+	expectedFiles := []string{"apimodel.json", TerraformConfigFileName, "ca.crt", "ca.key", "apiserver.crt", "apiserver.key", "client.crt", "client.key", "kubectlClient.crt", "kubectlClient.key"}
+	for _, f := range expectedFiles {
+		if _, err = os.Stat(dir + "/" + f); os.IsNotExist(err) {
+			t.Fatalf("expected file %s/%s to be generated by WriteTerraformArtifacts", dir, f)
+		}
+	}
+
+	for _, f := range []string{"azuredeploy.json", "azuredeploy.parameters.json"} {
+		if _, err = os.Stat(dir + "/" + f); !os.IsNotExist(err) {
+			t.Fatalf("expected file %s/%s not to be generated by WriteTerraformArtifacts", dir, f)
+		}
+	}
+}
+
+func TestWriteTLSArtifactsWithSecrets(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.7.12", 1, 2, true)
+	caPrivateKey := cs.Properties.CertificateProfile.CaPrivateKey
+	dir := "_testsecretsoutputdir"
+	defer os.RemoveAll(dir)
+	writer := &ArtifactWriter{
+		Translator: &i18n.Translator{
+			Locale: nil,
+		},
+		Secrets: api.NewSecretStore(&secrets.FileProvider{Dir: path.Join(dir, "secrets")}),
+	}
+
+	err := writer.WriteTLSArtifacts(cs, "vlabs", "fake template", "fake parameters", dir, true, false)
+	if err != nil {
+		t.Fatalf("unexpected error trying to write TLS artifacts: %s", err.Error())
+	}
+
+	for _, f := range []string{"apimodel.json", "ca.crt", "apiserver.crt", "kubeconfig/kubeconfig.eastus.json", "secrets/testmaster-caPrivateKey"} {
+		if _, err = os.Stat(dir + "/" + f); os.IsNotExist(err) {
+			t.Fatalf("expected file %s/%s to be generated by WriteTLSArtifacts", dir, f)
+		}
+	}
+	for _, f := range []string{"ca.key", "apiserver.key", "client.key", "kubectlClient.key", "etcdserver.key", "etcdclient.key", "etcdpeer0.key"} {
+		if _, err = os.Stat(dir + "/" + f); !os.IsNotExist(err) {
+			t.Fatalf("expected file %s/%s not to be generated by WriteTLSArtifacts with a secret provider", dir, f)
+		}
+	}
+
+	apimodel, err := ioutil.ReadFile(dir + "/apimodel.json")
+	if err != nil {
+		t.Fatalf("unexpected error reading the api model: %s", err)
+	}
+	if strings.Contains(string(apimodel), `"`+caPrivateKey+`"`) {
+		t.Errorf("expected the api model not to hold the CA private key")
+	}
+	if !strings.Contains(string(apimodel), "secrets/testmaster-caPrivateKey") {
+		t.Errorf("expected the api model to refer to the CA private key")
+	}
+	if cs.Properties.CertificateProfile.CaPrivateKey != caPrivateKey {
+		t.Errorf("expected the container service to keep its secrets after writing the artifacts")
+	}
+}
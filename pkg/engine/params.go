@@ -203,7 +203,12 @@ func getParameters(cs *api.ContainerService, generatorCode string, aksEngineVers
 
 		// Unless distro is defined, default distro is configured by defaults#setAgentProfileDefaults
 		//   Ignores Windows OS
-		if !(agentProfile.OSType == api.Windows) {
+		if agentProfile.OSType == api.Windows {
+			if imageRef := properties.GetWindowsImageRef(agentProfile); imageRef != nil {
+				addValue(parametersMap, fmt.Sprintf("%sosImageName", agentProfile.Name), imageRef.Name)
+				addValue(parametersMap, fmt.Sprintf("%sosImageResourceGroup", agentProfile.Name), imageRef.ResourceGroup)
+			}
+		} else {
 			if agentProfile.ImageRef != nil {
 				addValue(parametersMap, fmt.Sprintf("%sosImageName", agentProfile.Name), agentProfile.ImageRef.Name)
 				addValue(parametersMap, fmt.Sprintf("%sosImageResourceGroup", agentProfile.Name), agentProfile.ImageRef.ResourceGroup)
@@ -114,7 +114,7 @@ func assignKubernetesParameters(properties *api.Properties, parametersMap params
 			addValue(parametersMap, "etcdDownloadURLBase", cloudSpecConfig.KubernetesSpecConfig.EtcdDownloadURLBase)
 			addValue(parametersMap, "etcdVersion", kubernetesConfig.EtcdVersion)
 			addValue(parametersMap, "etcdDiskSizeGB", kubernetesConfig.EtcdDiskSizeGB)
-			addValue(parametersMap, "etcdEncryptionKey", kubernetesConfig.EtcdEncryptionKey)
+			addSecret(parametersMap, "etcdEncryptionKey", kubernetesConfig.EtcdEncryptionKey, false)
 			if kubernetesConfig.PrivateJumpboxProvision() {
 				addValue(parametersMap, "jumpboxVMName", kubernetesConfig.PrivateCluster.JumpboxProfile.Name)
 				addValue(parametersMap, "jumpboxVMSize", kubernetesConfig.PrivateCluster.JumpboxProfile.VMSize)
@@ -141,6 +141,16 @@ func assignKubernetesParameters(properties *api.Properties, parametersMap params
 				addValue(parametersMap, "kubeServiceCidr", kubernetesConfig.ServiceCIDR)
 				addValue(parametersMap, "kubeBinariesVersion", k8sVersion)
 				addValue(parametersMap, "windowsTelemetryGUID", cloudSpecConfig.KubernetesSpecConfig.WindowsTelemetryGUID)
+				if !kubernetesConfig.RequiresDocker() {
+					addValue(parametersMap, "windowsContainerdURL", kubernetesConfig.GetWindowsContainerdURL(cloudSpecConfig))
+				}
+				if properties.WindowsProfile.HasDomainJoin() {
+					keyVaultSecretRef := properties.WindowsProfile.DomainJoin.KeyvaultSecretRef
+					addKeyvaultReference(parametersMap, "windowsDomainJoinPassword",
+						keyVaultSecretRef.VaultID,
+						keyVaultSecretRef.SecretName,
+						keyVaultSecretRef.SecretVersion)
+				}
 			}
 		}
 
@@ -189,6 +199,8 @@ func assignKubernetesParameters(properties *api.Properties, parametersMap params
 		 - etcdServerPrivateKey
 		 - etcdPeerCertificates
 		 - etcdPeerPrivateKeys
+		 - etcdEncryptionKey
+		 - windowsAdminPassword
 
 		 To refer to a keyvault secret, the value of the parameter in the api model file should be formatted as:
 
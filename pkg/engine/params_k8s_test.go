@@ -9,6 +9,7 @@ import (
 
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/google/go-cmp/cmp"
 	"github.com/leonelquinteros/gotext"
 )
 
@@ -49,3 +50,125 @@ func TestAssignKubernetesParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignKubernetesParametersKeyVaultSecrets(t *testing.T) {
+	vaultID := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/vault"
+	cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, true)
+	cs.Location = "eastus"
+	cs.SetPropertiesDefaults(false, false)
+	cs.Properties.OrchestratorProfile.KubernetesConfig.EtcdEncryptionKey = vaultID + "/secrets/testcluster-etcdEncryptionKey"
+	cs.Properties.CertificateProfile.CaPrivateKey = vaultID + "/secrets/testcluster-caPrivateKey/0123456789abcdef"
+
+	parametersMap := paramsMap{}
+	assignKubernetesParameters(cs.Properties, parametersMap, cs.GetCloudSpecConfig(), DefaultGeneratorCode)
+
+	expected := map[string]*KeyVaultRef{
+		"etcdEncryptionKey": {
+			KeyVault:   KeyVaultID{ID: vaultID},
+			SecretName: "testcluster-etcdEncryptionKey",
+		},
+		"caPrivateKey": {
+			KeyVault:      KeyVaultID{ID: vaultID},
+			SecretName:    "testcluster-caPrivateKey",
+			SecretVersion: "0123456789abcdef",
+		},
+	}
+	for name, reference := range expected {
+		parameter, ok := parametersMap[name].(paramsMap)
+		if !ok {
+			t.Fatalf("expected parameter %s to be set", name)
+		}
+		if diff := cmp.Diff(reference, parameter["reference"]); diff != "" {
+			t.Errorf("expected parameter %s to refer to its Key Vault secret (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestAssignKubernetesParametersWindowsContainerd(t *testing.T) {
+	cases := []struct {
+		name             string
+		containerRuntime string
+		expectURL        bool
+	}{
+		{
+			name:             "docker",
+			containerRuntime: api.Docker,
+		},
+		{
+			name:             "containerd",
+			containerRuntime: api.Containerd,
+			expectURL:        true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, false)
+			cs.Location = "eastus"
+			cs.Properties.AgentPoolProfiles[0].OSType = api.Windows
+			cs.Properties.WindowsProfile = &api.WindowsProfile{
+				AdminUsername: "azureuser",
+				AdminPassword: "password",
+			}
+			cs.Properties.OrchestratorProfile.KubernetesConfig.ContainerRuntime = c.containerRuntime
+			cs.SetPropertiesDefaults(false, false)
+
+			parametersMap := paramsMap{}
+			cloudSpecConfig := cs.GetCloudSpecConfig()
+			assignKubernetesParameters(cs.Properties, parametersMap, cloudSpecConfig, DefaultGeneratorCode)
+
+			parameter, ok := parametersMap["windowsContainerdURL"].(paramsMap)
+			if ok != c.expectURL {
+				t.Fatalf("expected windowsContainerdURL to be set %t, got %t", c.expectURL, ok)
+			}
+			if ok && parameter["value"] != cloudSpecConfig.KubernetesSpecConfig.WindowsContainerdDownloadURL {
+				t.Errorf("expected windowsContainerdURL %s, got %v", cloudSpecConfig.KubernetesSpecConfig.WindowsContainerdDownloadURL, parameter["value"])
+			}
+			if _, declared := getWindowsParameters(cs)["windowsContainerdURL"]; declared != c.expectURL {
+				t.Errorf("expected windowsContainerdURL to be declared %t, got %t", c.expectURL, declared)
+			}
+		})
+	}
+}
+
+func TestAssignKubernetesParametersWindowsDomainJoin(t *testing.T) {
+	vaultID := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/vault"
+	cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, false)
+	cs.Location = "eastus"
+	cs.Properties.AgentPoolProfiles[0].OSType = api.Windows
+	cs.Properties.WindowsProfile = &api.WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "password",
+		DomainJoin: &api.WindowsDomainJoin{
+			Domain:   "corp.contoso.com",
+			UserName: "joiner",
+			KeyvaultSecretRef: &api.KeyvaultSecretRef{
+				VaultID:    vaultID,
+				SecretName: "domain-join",
+			},
+		},
+	}
+	cs.SetPropertiesDefaults(false, false)
+
+	parametersMap := paramsMap{}
+	assignKubernetesParameters(cs.Properties, parametersMap, cs.GetCloudSpecConfig(), DefaultGeneratorCode)
+
+	parameter, ok := parametersMap["windowsDomainJoinPassword"].(paramsMap)
+	if !ok {
+		t.Fatalf("expected parameter windowsDomainJoinPassword to be set")
+	}
+	if _, ok := parameter["value"]; ok {
+		t.Errorf("expected windowsDomainJoinPassword not to carry a plaintext value")
+	}
+	expected := &KeyVaultRef{
+		KeyVault:   KeyVaultID{ID: vaultID},
+		SecretName: "domain-join",
+	}
+	if diff := cmp.Diff(expected, parameter["reference"]); diff != "" {
+		t.Errorf("expected windowsDomainJoinPassword to refer to its Key Vault secret (-want +got):\n%s", diff)
+	}
+	if p, declared := getWindowsParameters(cs)["windowsDomainJoinPassword"]; !declared || p.Type != "securestring" {
+		t.Errorf("expected windowsDomainJoinPassword to be declared as a securestring, got %v", p)
+	}
+}
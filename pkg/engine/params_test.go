@@ -47,3 +47,37 @@ func TestAssignParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestGetParametersWindowsImageRef(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, false)
+	cs.Location = "eastus"
+	cs.Properties.AgentPoolProfiles[0].OSType = api.Windows
+	cs.Properties.WindowsProfile = &api.WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "password",
+		ImageRef: &api.ImageReference{
+			Name:           "windows",
+			ResourceGroup:  "images",
+			SubscriptionID: "00000000-0000-0000-0000-000000000000",
+			Gallery:        "gallery",
+			Version:        "1.0.0",
+		},
+	}
+	cs.SetPropertiesDefaults(false, false)
+
+	parametersMap := getParameters(cs, DefaultGeneratorCode, "testversion")
+
+	name := cs.Properties.AgentPoolProfiles[0].Name
+	for parameter, expected := range map[string]string{
+		name + "osImageName":          "windows",
+		name + "osImageResourceGroup": "images",
+	} {
+		value, ok := parametersMap[parameter].(paramsMap)
+		if !ok {
+			t.Fatalf("expected parameter %s to be set", parameter)
+		}
+		if value["value"] != expected {
+			t.Errorf("expected parameter %s to be %s, got %v", parameter, expected, value["value"])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2018-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// The Microsoft.Network/privateDnsZones resource provider has no client in the vendored azure-sdk-for-go,
+// so the subset of its schema used by fully private clusters is modeled here.
+
+// PrivateDNSZone describes a Microsoft.Network/privateDnsZones resource.
+type PrivateDNSZone struct {
+	Name     *string `json:"name,omitempty"`
+	Type     *string `json:"type,omitempty"`
+	Location *string `json:"location,omitempty"`
+}
+
+// MarshalJSON is the custom marshaler for PrivateDNSZone.
+func (z PrivateDNSZone) MarshalJSON() ([]byte, error) {
+	type Alias PrivateDNSZone
+	return json.Marshal(Alias(z))
+}
+
+// PrivateDNSZoneRecordSet describes a record set in a Microsoft.Network/privateDnsZones resource.
+type PrivateDNSZoneRecordSet struct {
+	Name                               *string `json:"name,omitempty"`
+	Type                               *string `json:"type,omitempty"`
+	*PrivateDNSZoneRecordSetProperties `json:"properties,omitempty"`
+}
+
+// MarshalJSON is the custom marshaler for PrivateDNSZoneRecordSet.
+func (r PrivateDNSZoneRecordSet) MarshalJSON() ([]byte, error) {
+	type Alias PrivateDNSZoneRecordSet
+	return json.Marshal(Alias(r))
+}
+
+// PrivateDNSZoneRecordSetProperties describes the properties of a private DNS zone record set.
+type PrivateDNSZoneRecordSetProperties struct {
+	TTL      *int64                   `json:"ttl,omitempty"`
+	ARecords *[]PrivateDNSZoneARecord `json:"aRecords,omitempty"`
+}
+
+// PrivateDNSZoneARecord describes an A record in a private DNS zone record set.
+type PrivateDNSZoneARecord struct {
+	IPv4Address *string `json:"ipv4Address,omitempty"`
+}
+
+// PrivateDNSZoneVirtualNetworkLink describes a Microsoft.Network/privateDnsZones/virtualNetworkLinks resource.
+type PrivateDNSZoneVirtualNetworkLink struct {
+	Name                                        *string `json:"name,omitempty"`
+	Type                                        *string `json:"type,omitempty"`
+	Location                                    *string `json:"location,omitempty"`
+	*PrivateDNSZoneVirtualNetworkLinkProperties `json:"properties,omitempty"`
+}
+
+// MarshalJSON is the custom marshaler for PrivateDNSZoneVirtualNetworkLink.
+func (l PrivateDNSZoneVirtualNetworkLink) MarshalJSON() ([]byte, error) {
+	type Alias PrivateDNSZoneVirtualNetworkLink
+	return json.Marshal(Alias(l))
+}
+
+// PrivateDNSZoneVirtualNetworkLinkProperties describes the properties of a private DNS zone virtual network link.
+type PrivateDNSZoneVirtualNetworkLinkProperties struct {
+	VirtualNetwork      *network.SubResource `json:"virtualNetwork,omitempty"`
+	RegistrationEnabled *bool                `json:"registrationEnabled,omitempty"`
+}
+
+func createPrivateDNSZone() PrivateDNSZoneARM {
+	return PrivateDNSZoneARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionPrivateDNS')]",
+		},
+		PrivateDNSZone: PrivateDNSZone{
+			Location: to.StringPtr("global"),
+			Name:     to.StringPtr("[variables('privateDNSZoneName')]"),
+			Type:     to.StringPtr("Microsoft.Network/privateDnsZones"),
+		},
+	}
+}
+
+// createPrivateDNSZoneAPIServerRecord creates the A record for the API server at the apex of the private DNS zone.
+func createPrivateDNSZoneAPIServerRecord() PrivateDNSZoneRecordSetARM {
+	return PrivateDNSZoneRecordSetARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionPrivateDNS')]",
+			DependsOn: []string{
+				"[concat('Microsoft.Network/privateDnsZones/', variables('privateDNSZoneName'))]",
+			},
+		},
+		PrivateDNSZoneRecordSet: PrivateDNSZoneRecordSet{
+			Name: to.StringPtr("[concat(variables('privateDNSZoneName'), '/@')]"),
+			Type: to.StringPtr("Microsoft.Network/privateDnsZones/A"),
+			PrivateDNSZoneRecordSetProperties: &PrivateDNSZoneRecordSetProperties{
+				TTL: to.Int64Ptr(300),
+				ARecords: &[]PrivateDNSZoneARecord{
+					{
+						IPv4Address: to.StringPtr("[variables('kubernetesAPIServerIP')]"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// createPrivateDNSZoneVirtualNetworkLink links the private DNS zone to the custom VNET of the cluster,
+// which a fully private cluster requires.
+func createPrivateDNSZoneVirtualNetworkLink() PrivateDNSZoneVirtualNetworkLinkARM {
+	return PrivateDNSZoneVirtualNetworkLinkARM{
+		ARMResource: ARMResource{
+			APIVersion: "[variables('apiVersionPrivateDNS')]",
+			DependsOn: []string{
+				"[concat('Microsoft.Network/privateDnsZones/', variables('privateDNSZoneName'))]",
+			},
+		},
+		PrivateDNSZoneVirtualNetworkLink: PrivateDNSZoneVirtualNetworkLink{
+			Location: to.StringPtr("global"),
+			Name:     to.StringPtr("[concat(variables('privateDNSZoneName'), '/', variables('virtualNetworkName'))]"),
+			Type:     to.StringPtr("Microsoft.Network/privateDnsZones/virtualNetworkLinks"),
+			PrivateDNSZoneVirtualNetworkLinkProperties: &PrivateDNSZoneVirtualNetworkLinkProperties{
+				VirtualNetwork: &network.SubResource{
+					ID: to.StringPtr("[resourceId(variables('virtualNetworkResourceGroupName'), 'Microsoft.Network/virtualNetworks', variables('virtualNetworkName'))]"),
+				},
+				RegistrationEnabled: to.BoolPtr(false),
+			},
+		},
+	}
+}
@@ -0,0 +1,877 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+)
+
+// getSwarmTemplate returns the ARM template of a Swarm or Swarm Mode cluster
+func getSwarmTemplate(cs *api.ContainerService) ARMTemplate {
+	return ARMTemplate{
+		Schema:         armTemplateSchema,
+		ContentVersion: armTemplateContentVersion,
+		Parameters:     getSwarmParameters(cs),
+		Variables:      getSwarmVariables(cs),
+		Resources:      getSwarmResources(cs),
+		Outputs:        getIaaSOutputs(cs),
+	}
+}
+
+// getMasterSwarmCustomData returns the customData fragment of the Swarm masters
+func getMasterSwarmCustomData(cs *api.ContainerService, swarmMode bool) string {
+	files := []string{swarmProvision}
+	if swarmMode {
+		files = []string{swarmModeProvision}
+	}
+	str := buildYamlFileWithWriteFiles(files)
+	if cs.Properties.MasterProfile.PreprovisionExtension != nil {
+		extensionStr := makeMasterExtensionScriptCommands(cs)
+		if swarmMode {
+			str += "runcmd:\n" + extensionStr + "\n\n"
+		} else {
+			str += "'runcmd:\n" + extensionStr + "\n\n'"
+		}
+	}
+	str = escapeSingleLine(str)
+	return fmt.Sprintf("\"customData\": \"[base64(concat('%s'))]\",", str)
+}
+
+// getAgentSwarmCustomData returns the customData fragment of the Linux agents of a Swarm agent pool
+func getAgentSwarmCustomData(profile *api.AgentPoolProfile, swarmMode bool) string {
+	files := []string{swarmProvision}
+	if swarmMode {
+		files = []string{swarmModeProvision}
+	}
+	str := buildYamlFileWithWriteFiles(files)
+	str = escapeSingleLine(str)
+	return fmt.Sprintf("\"customData\": \"[base64(concat('%s',variables('%sRunCmdFile'),variables('%sRunCmd')))]\",", str, profile.Name, profile.Name)
+}
+
+// getWinAgentSwarmCustomData returns the customData fragment of the Windows agents of a Swarm cluster
+func getWinAgentSwarmCustomData(swarmMode bool) string {
+	script := swarmWindowsProvision
+	if swarmMode {
+		script = swarmModeWindowsProvision
+	}
+	return fmt.Sprintf("\"customData\": \"%s\"", getBase64EncodedGzippedCustomScript(script))
+}
+
+// getConfigurationScriptRootURL returns the URL the cluster configuration scripts are downloaded from
+func getConfigurationScriptRootURL(cs *api.ContainerService) string {
+	linuxProfile := cs.Properties.LinuxProfile
+	if linuxProfile == nil || linuxProfile.ScriptRootURL == "" {
+		return DefaultConfigurationScriptRootURL
+	}
+	return linuxProfile.ScriptRootURL
+}
+
+// getSwarmVariables returns the variables of the ARM template of a Swarm or Swarm Mode cluster
+func getSwarmVariables(cs *api.ContainerService) map[string]interface{} {
+	swarmVars := map[string]interface{}{}
+	for i, profile := range cs.Properties.AgentPoolProfiles {
+		swarmVars[profile.Name+"Index"] = i
+		for k, v := range getSwarmAgentVars(cs, profile) {
+			swarmVars[k] = v
+		}
+		for k, v := range getIaaSAgentPoolIndexVariables(profile, i) {
+			swarmVars[k] = v
+		}
+	}
+	for k, v := range getSwarmMasterVars(cs) {
+		swarmVars[k] = v
+	}
+	return swarmVars
+}
+
+func getSwarmAgentVars(cs *api.ContainerService, profile *api.AgentPoolProfile) map[string]interface{} {
+	agentName := profile.Name
+	agentVars := map[string]interface{}{
+		agentName + "Count":        fmt.Sprintf("[parameters('%sCount')]", agentName),
+		agentName + "VMNamePrefix": fmt.Sprintf("[concat(variables('orchestratorName'), '-%s-', variables('nameSuffix'))]", agentName),
+	}
+	if !profile.IsRHEL() {
+		// the preprovision extension commands of the agents have never been rendered into their runcmd
+		agentVars[agentName+"RunCmd"] = "[concat('runcmd:\n  \n-  [ /bin/bash, /opt/azure/containers/install-cluster.sh ]\n\n')]"
+		agentVars[agentName+"RunCmdFile"] = "[concat(' -  content: |\n        #!/bin/bash\n        ','sudo mkdir -p /var/log/azure\n        ',variables('agentCustomScript'),'\n    path: /opt/azure/containers/install-cluster.sh\n    permissions: \"0744\"\n')]"
+	}
+	if cs.Properties.OrchestratorProfile.IsSwarmMode() {
+		imageConfig := cs.GetCloudSpecConfig().OSImageConfig[profile.Distro]
+		agentVars[agentName+"OSImageOffer"] = imageConfig.ImageOffer
+		agentVars[agentName+"OSImagePublisher"] = imageConfig.ImagePublisher
+		agentVars[agentName+"OSImageSKU"] = imageConfig.ImageSku
+		agentVars[agentName+"OSImageVersion"] = imageConfig.ImageVersion
+	} else {
+		agentVars[agentName+"OSImageOffer"] = "[variables('osImageOffer')]"
+		agentVars[agentName+"OSImagePublisher"] = "[variables('osImagePublisher')]"
+		agentVars[agentName+"OSImageSKU"] = "[variables('osImageSKU')]"
+		agentVars[agentName+"OSImageVersion"] = "[variables('osImageVersion')]"
+	}
+	for k, v := range getIaaSAgentPoolVariables(profile, "subnet") {
+		agentVars[k] = v
+	}
+	return agentVars
+}
+
+func getSwarmMasterVars(cs *api.ContainerService) map[string]interface{} {
+	properties := cs.Properties
+	masterProfile := properties.MasterProfile
+	swarmMode := properties.OrchestratorProfile.IsSwarmMode()
+
+	masterVars := map[string]interface{}{
+		"adminUsername":            "[parameters('linuxAdminUsername')]",
+		"maxVMsPerPool":            100,
+		"apiVersionDefault":        "2016-03-30",
+		"agentMaxVMs":              100,
+		"clusterInstallParameters": "[concat(variables('orchestratorVersion'), ' ',variables('dockerComposeVersion'), ' ',variables('masterCount'), ' ',variables('masterVMNamePrefix'), ' ',variables('masterFirstAddrOctet4'), ' ',variables('adminUsername'),' ',variables('postInstallScriptURI'),' ',variables('masterFirstAddrPrefix'),' ', parameters('dockerEngineDownloadRepo'), ' ', parameters('dockerComposeDownloadURL'))]",
+
+		"masterAvailabilitySet":       "[concat(variables('orchestratorName'), '-master-availabilitySet-', variables('nameSuffix'))]",
+		"masterCount":                 masterProfile.Count,
+		"masterEndpointDNSNamePrefix": "[tolower(parameters('masterEndpointDNSNamePrefix'))]",
+		"masterLbBackendPoolName":     "[concat(variables('orchestratorName'), '-master-pool-', variables('nameSuffix'))]",
+		"masterLbID":                  "[resourceId('Microsoft.Network/loadBalancers',variables('masterLbName'))]",
+		"masterLbIPConfigID":          "[concat(variables('masterLbID'),'/frontendIPConfigurations/', variables('masterLbIPConfigName'))]",
+		"masterLbIPConfigName":        "[concat(variables('orchestratorName'), '-master-lbFrontEnd-', variables('nameSuffix'))]",
+		"masterLbName":                "[concat(variables('orchestratorName'), '-master-lb-', variables('nameSuffix'))]",
+		"masterPublicIPAddressName":   "[concat(variables('orchestratorName'), '-master-ip-', variables('masterEndpointDNSNamePrefix'), '-', variables('nameSuffix'))]",
+
+		"masterVMNamePrefix": "[concat(variables('orchestratorName'), '-master-', variables('nameSuffix'), '-')]",
+		"masterVMSize":       "[parameters('masterVMSize')]",
+		"nameSuffix":         "[parameters('nameSuffix')]",
+
+		"masterSshInboundNatRuleIdPrefix":         "[concat(variables('masterLbID'),'/inboundNatRules/SSH-',variables('masterVMNamePrefix'))]",
+		"masterSshPort22InboundNatRuleNamePrefix": "[concat(variables('masterLbName'),'/SSHPort22-',variables('masterVMNamePrefix'))]",
+		"masterSshPort22InboundNatRuleIdPrefix":   "[concat(variables('masterLbID'),'/inboundNatRules/SSHPort22-',variables('masterVMNamePrefix'))]",
+		"masterLbInboundNatRules":                 getIaaSMasterLbInboundNatRules(true),
+
+		"postInstallScriptURI": "disabled",
+		"sshKeyPath":           "[concat('/home/', variables('adminUsername'), '/.ssh/authorized_keys')]",
+		"sshRSAPublicKey":      "[parameters('sshRSAPublicKey')]",
+	}
+
+	if swarmMode {
+		masterVars["configureClusterScriptFile"] = "configure-swarmmode-cluster.sh"
+		imageConfig := cs.GetCloudSpecConfig().OSImageConfig[masterProfile.Distro]
+		masterVars["orchestratorName"] = "swarmm"
+		masterVars["masterOSImageOffer"] = imageConfig.ImageOffer
+		masterVars["masterOSImagePublisher"] = imageConfig.ImagePublisher
+		masterVars["masterOSImageSKU"] = imageConfig.ImageSku
+		masterVars["masterOSImageVersion"] = imageConfig.ImageVersion
+		masterVars["orchestratorVersion"] = api.DockerCEVersion
+		masterVars["dockerComposeVersion"] = api.DockerCEDockerComposeVersion
+	} else {
+		masterVars["configureClusterScriptFile"] = "configure-swarm-cluster.sh"
+		masterVars["orchestratorName"] = "swarm"
+		masterVars["osImageOffer"] = "[parameters('osImageOffer')]"
+		masterVars["osImagePublisher"] = "[parameters('osImagePublisher')]"
+		masterVars["osImageSKU"] = "14.04.5-LTS"
+		masterVars["osImageVersion"] = "14.04.201706190"
+		masterVars["orchestratorVersion"] = api.SwarmVersion
+		masterVars["dockerComposeVersion"] = api.SwarmDockerComposeVersion
+	}
+
+	if masterProfile.IsRHEL() {
+		masterVars["agentCustomScript"] = "[concat('/usr/bin/nohup /bin/bash -c \"/bin/bash ',variables('configureClusterScriptFile'), ' ',variables('clusterInstallParameters'),' >> /var/log/azure/cluster-bootstrap.log 2>&1 &\" &')]"
+		masterVars["masterCustomScript"] = "[concat('/bin/bash -c \"/bin/bash ',variables('configureClusterScriptFile'), ' ',variables('clusterInstallParameters'),' >> /var/log/azure/cluster-bootstrap.log 2>&1\"')]"
+	} else {
+		masterVars["agentCustomScript"] = "[concat('/usr/bin/nohup /bin/bash -c \"/bin/bash /opt/azure/containers/',variables('configureClusterScriptFile'), ' ',variables('clusterInstallParameters'),' >> /var/log/azure/cluster-bootstrap.log 2>&1 &\" &')]"
+		masterVars["masterCustomScript"] = "[concat('/bin/bash -c \"/bin/bash /opt/azure/containers/',variables('configureClusterScriptFile'), ' ',variables('clusterInstallParameters'),' >> /var/log/azure/cluster-bootstrap.log 2>&1\"')]"
+	}
+
+	if properties.LinuxProfile.HasSecrets() {
+		masterVars["linuxProfileSecrets"] = getIaaSProfileSecrets(properties.LinuxProfile.Secrets, "linux", false)
+	}
+
+	for k, v := range getIaaSMasterVNETVariables(cs) {
+		masterVars[k] = v
+	}
+	for k, v := range getIaaSMasterAddressVariables() {
+		masterVars[k] = v
+	}
+	for k, v := range getIaaSLocationVariables() {
+		masterVars[k] = v
+	}
+
+	if properties.HasStorageAccountDisks() {
+		for k, v := range getIaaSStorageAccountsVariables() {
+			masterVars[k] = v
+		}
+		masterVars["apiVersionStorage"] = "2015-06-15"
+		masterVars["vmsPerStorageAccount"] = 20
+		masterVars["storageAccountBaseName"] = "[uniqueString(concat(variables('masterEndpointDNSNamePrefix'),variables('location')))]"
+	} else {
+		masterVars["storageAccountPrefixes"] = []interface{}{}
+		masterVars["storageAccountBaseName"] = ""
+	}
+	if properties.HasManagedDisks() {
+		masterVars["apiVersionStorageManagedDisks"] = "2016-04-30-preview"
+	}
+	if masterProfile.IsStorageAccount() {
+		masterVars["masterStorageAccountName"] = "[concat(variables('storageAccountBaseName'), '0')]"
+	}
+
+	if properties.HasWindows() {
+		masterVars["windowsAdminUsername"] = "[parameters('windowsAdminUsername')]"
+		masterVars["windowsAdminPassword"] = "[parameters('windowsAdminPassword')]"
+		masterVars["agentWindowsPublisher"] = "[parameters('agentWindowsPublisher')]"
+		masterVars["agentWindowsOffer"] = "[parameters('agentWindowsOffer')]"
+		masterVars["agentWindowsSku"] = "[parameters('agentWindowsSku')]"
+		masterVars["agentWindowsVersion"] = "[parameters('agentWindowsVersion')]"
+		masterVars["singleQuote"] = "'"
+		masterVars["windowsCustomScriptArguments"] = "[concat('$arguments = ', variables('singleQuote'),'-SwarmMasterIP ', variables('masterFirstAddrPrefix'), variables('masterFirstAddrOctet4'), variables('singleQuote'), ' ; ')]"
+		masterVars["windowsCustomScriptSuffix"] = " $inputFile = '%SYSTEMDRIVE%\\AzureData\\CustomData.bin' ; $outputFile = '%SYSTEMDRIVE%\\AzureData\\CustomDataSetupScript.ps1' ; $inputStream = New-Object System.IO.FileStream $inputFile, ([IO.FileMode]::Open), ([IO.FileAccess]::Read), ([IO.FileShare]::Read) ; $sr = New-Object System.IO.StreamReader(New-Object System.IO.Compression.GZipStream($inputStream, [System.IO.Compression.CompressionMode]::Decompress)) ; $sr.ReadToEnd() | Out-File($outputFile) ; Invoke-Expression('{0} {1}' -f $outputFile, $arguments) ; "
+		masterVars["windowsCustomScript"] = "[concat('powershell.exe -ExecutionPolicy Unrestricted -command \"', variables('windowsCustomScriptArguments'), variables('windowsCustomScriptSuffix'), '\" > %SYSTEMDRIVE%\\AzureData\\CustomDataSetupScript.log 2>&1')]"
+		masterVars["agentWindowsBackendPort"] = 3389
+		if properties.WindowsProfile.HasSecrets() {
+			// the Windows secrets have always been declared after the vaults of the Linux profile
+			var secrets []api.KeyVaultSecrets
+			if properties.LinuxProfile != nil {
+				secrets = properties.LinuxProfile.Secrets
+			}
+			masterVars["windowsProfileSecrets"] = getIaaSProfileSecrets(secrets, "windows", true)
+		}
+	}
+
+	return masterVars
+}
+
+// getSwarmResources returns the resources of the ARM template of a Swarm or Swarm Mode cluster
+func getSwarmResources(cs *api.ContainerService) []interface{} {
+	resources := []interface{}{}
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		if profile.IsAvailabilitySets() {
+			resources = append(resources, getSwarmAgentResourcesVMAS(cs, profile)...)
+		} else {
+			resources = append(resources, getSwarmAgentResourcesVMSS(cs, profile)...)
+		}
+	}
+	return append(resources, getSwarmMasterResources(cs)...)
+}
+
+func getSwarmLinuxConfiguration(keyData string) map[string]interface{} {
+	return map[string]interface{}{
+		"disablePasswordAuthentication": true,
+		"ssh": map[string]interface{}{
+			"publicKeys": []interface{}{
+				map[string]interface{}{
+					"keyData": keyData,
+					"path":    "[variables('sshKeyPath')]",
+				},
+			},
+		},
+	}
+}
+
+func getSwarmAgentLinuxOSProfile(cs *api.ContainerService, profile *api.AgentPoolProfile) map[string]interface{} {
+	osProfile := map[string]interface{}{
+		"adminUsername":      "[variables('adminUsername')]",
+		"linuxConfiguration": getSwarmLinuxConfiguration("[parameters('sshRSAPublicKey')]"),
+	}
+	if cs.Properties.OrchestratorProfile.IsSwarmMode() {
+		if !profile.IsRHEL() {
+			osProfile["customData"] = getCustomDataFromFragment(getAgentSwarmCustomData(profile, true))
+		}
+	} else {
+		osProfile["customData"] = getCustomDataFromFragment(getAgentSwarmCustomData(profile, false))
+	}
+	if cs.Properties.LinuxProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+	}
+	return osProfile
+}
+
+func getSwarmAgentWindowsOSProfile(cs *api.ContainerService) map[string]interface{} {
+	osProfile := map[string]interface{}{
+		"adminUsername": "[variables('windowsAdminUsername')]",
+		"adminPassword": "[variables('windowsAdminPassword')]",
+		"customData":    getCustomDataFromFragment(getWinAgentSwarmCustomData(cs.Properties.OrchestratorProfile.IsSwarmMode())),
+	}
+	if cs.Properties.WindowsProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('windowsProfileSecrets')]"
+	}
+	return osProfile
+}
+
+func getSwarmAgentImageReference(profile *api.AgentPoolProfile) map[string]interface{} {
+	if profile.IsWindows() {
+		return map[string]interface{}{
+			"publisher": "[variables('agentWindowsPublisher')]",
+			"offer":     "[variables('agentWindowsOffer')]",
+			"sku":       "[variables('agentWindowsSku')]",
+			"version":   "[variables('agentWindowsVersion')]",
+		}
+	}
+	return map[string]interface{}{
+		"offer":     fmt.Sprintf("[variables('%sOSImageOffer')]", profile.Name),
+		"publisher": fmt.Sprintf("[variables('%sOSImagePublisher')]", profile.Name),
+		"sku":       fmt.Sprintf("[variables('%sOSImageSKU')]", profile.Name),
+		"version":   fmt.Sprintf("[variables('%sOSImageVersion')]", profile.Name),
+	}
+}
+
+func getSwarmConfigureExtensionProperties(cs *api.ContainerService, commandVariable string) map[string]interface{} {
+	return map[string]interface{}{
+		"publisher": "Microsoft.Azure.Extensions",
+		"settings": map[string]interface{}{
+			"commandToExecute": fmt.Sprintf("[variables('%s')]", commandVariable),
+			"fileUris": []interface{}{
+				fmt.Sprintf("[concat('%s', variables('configureClusterScriptFile'))]", getConfigurationScriptRootURL(cs)),
+			},
+		},
+		"type":               "CustomScript",
+		"typeHandlerVersion": "2.0",
+	}
+}
+
+func getSwarmWindowsCustomScriptExtensionProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"publisher":               "Microsoft.Compute",
+		"type":                    "CustomScriptExtension",
+		"typeHandlerVersion":      "1.8",
+		"autoUpgradeMinorVersion": true,
+		"settings": map[string]interface{}{
+			"commandToExecute": "[variables('windowsCustomScript')]",
+		},
+	}
+}
+
+// getSwarmAgentResourcesVMAS returns the resources of a Swarm agent pool of availability sets
+func getSwarmAgentResourcesVMAS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	agentName := profile.Name
+	isPublic := common.SliceIntIsNonEmpty(profile.Ports)
+	count := fmt.Sprintf("[variables('%sCount')]", agentName)
+	if profile.IsWindows() {
+		count = fmt.Sprintf("[sub(variables('%[1]sCount'), variables('%[1]sOffset'))]", agentName)
+	}
+
+	nicDependencies := []interface{}{}
+	if !profile.IsCustomVNET() {
+		nicDependencies = append(nicDependencies, "[variables('vnetID')]")
+	}
+	ipConfigProperties := map[string]interface{}{
+		"privateIPAllocationMethod": "Dynamic",
+		"subnet": map[string]interface{}{
+			"id": fmt.Sprintf("[variables('%sVnetSubnetID')]", agentName),
+		},
+	}
+	if isPublic {
+		nicDependencies = append(nicDependencies, fmt.Sprintf("[variables('%sLbID')]", agentName))
+		ipConfigProperties["loadBalancerBackendAddressPools"] = getIaaSAgentBackendPools(agentName)
+		if profile.IsWindows() {
+			ipConfigProperties["loadBalancerInboundNatPools"] = getIaaSAgentRDPNatPools(agentName)
+		}
+	}
+	resources := []interface{}{
+		map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"copy": map[string]interface{}{
+				"count": count,
+				"name":  "loop",
+			},
+			"dependsOn": nicDependencies,
+			"location":  "[variables('location')]",
+			"name":      fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), 'nic-', copyIndex(variables('%[1]sOffset')))]", agentName),
+			"properties": map[string]interface{}{
+				"ipConfigurations": []interface{}{
+					map[string]interface{}{
+						"name":       "ipConfigNode",
+						"properties": ipConfigProperties,
+					},
+				},
+			},
+			"type": "Microsoft.Network/networkInterfaces",
+		},
+	}
+
+	if profile.IsManagedDisks() {
+		resources = append(resources, getIaaSAvailabilitySet(agentName+"AvailabilitySet", true))
+	} else if profile.IsStorageAccount() {
+		resources = append(resources, getIaaSAgentStorageAccounts(profile, "vmLoopNode")...)
+		resources = append(resources, getIaaSAvailabilitySet(agentName+"AvailabilitySet", false))
+	}
+	if isPublic {
+		resources = append(resources, getIaaSAgentPublicResources(profile, profile.IsWindows())...)
+	}
+
+	var osProfile map[string]interface{}
+	if profile.IsWindows() {
+		osProfile = getSwarmAgentWindowsOSProfile(cs)
+		osProfile["computername"] = fmt.Sprintf("[concat(substring(variables('nameSuffix'), 0, 5), 'acs', copyIndex(variables('%[1]sOffset')), add(900,variables('%[1]sIndex')))]", agentName)
+	} else {
+		osProfile = getSwarmAgentLinuxOSProfile(cs, profile)
+		osProfile["computername"] = fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName)
+	}
+	storageProfile := map[string]interface{}{
+		"imageReference": getSwarmAgentImageReference(profile),
+		"osDisk":         getIaaSAgentVMOSDisk(profile),
+	}
+	if dataDisks := getIaaSDataDisks(profile); dataDisks != nil {
+		storageProfile["dataDisks"] = dataDisks
+	}
+	apiVersion := "[variables('apiVersionDefault')]"
+	if profile.IsManagedDisks() {
+		apiVersion = "[variables('apiVersionStorageManagedDisks')]"
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": apiVersion,
+		"copy": map[string]interface{}{
+			"count": count,
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": getIaaSAgentVMStorageAccountDependencies(profile),
+		"tags": map[string]interface{}{
+			"creationSource": fmt.Sprintf("[concat('acsengine-', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		},
+		"location": "[variables('location')]",
+		"name":     fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+		"properties": map[string]interface{}{
+			"availabilitySet": map[string]interface{}{
+				"id": fmt.Sprintf("[resourceId('Microsoft.Compute/availabilitySets',variables('%sAvailabilitySet'))]", agentName),
+			},
+			"hardwareProfile": map[string]interface{}{
+				"vmSize": fmt.Sprintf("[variables('%sVMSize')]", agentName),
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{
+						"id": fmt.Sprintf("[resourceId('Microsoft.Network/networkInterfaces',concat(variables('%[1]sVMNamePrefix'), 'nic-', copyIndex(variables('%[1]sOffset'))))]", agentName),
+					},
+				},
+			},
+			"osProfile":      osProfile,
+			"storageProfile": storageProfile,
+		},
+		"type": "Microsoft.Compute/virtualMachines",
+	})
+
+	if profile.IsWindows() {
+		resources = append(resources, map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"copy": map[string]interface{}{
+				"count": count,
+				"name":  "vmLoopNode",
+			},
+			"dependsOn": []interface{}{
+				fmt.Sprintf("[concat('Microsoft.Compute/virtualMachines/', variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')))]", agentName),
+			},
+			"location":   "[variables('location')]",
+			"name":       fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')), '/cse')]", agentName),
+			"properties": getSwarmWindowsCustomScriptExtensionProperties(),
+			"type":       "Microsoft.Compute/virtualMachines/extensions",
+		})
+	} else if profile.IsRHEL() {
+		resources = append(resources, map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"copy": map[string]interface{}{
+				"count": count,
+				"name":  "vmLoopNode",
+			},
+			"dependsOn": []interface{}{
+				fmt.Sprintf("[concat('Microsoft.Compute/virtualMachines/', concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset'))))]", agentName),
+			},
+			"location":   "[variables('location')]",
+			"name":       fmt.Sprintf("[concat(variables('%[1]sVMNamePrefix'), copyIndex(variables('%[1]sOffset')), '/configureagent')]", agentName),
+			"properties": getSwarmConfigureExtensionProperties(cs, "agentCustomScript"),
+			"type":       "Microsoft.Compute/virtualMachines/extensions",
+		})
+	}
+	return resources
+}
+
+// getSwarmAgentResourcesVMSS returns the resources of a Swarm agent pool of scale sets
+func getSwarmAgentResourcesVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) []interface{} {
+	agentName := profile.Name
+	isPublic := common.SliceIntIsNonEmpty(profile.Ports)
+	resources := []interface{}{}
+	if profile.IsStorageAccount() {
+		resources = append(resources, getIaaSAgentStorageAccounts(profile, "vmLoopNode")...)
+	}
+	if isPublic {
+		publicResources := getIaaSAgentPublicResources(profile, profile.IsWindows())
+		if profile.IsWindows() {
+			lbProperties := publicResources[1].(map[string]interface{})["properties"].(map[string]interface{})
+			lbProperties["inboundNatRules"] = []interface{}{}
+		}
+		resources = append(resources, publicResources...)
+	}
+
+	dependencies := []interface{}{"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]"}
+	osDisk := map[string]interface{}{
+		"caching":      "ReadWrite",
+		"createOption": "FromImage",
+	}
+	if profile.IsStorageAccount() {
+		vhdContainers := []interface{}{}
+		for i := 0; i < 5; i++ {
+			accountNameArgs := getIaaSAgentStorageAccountNameArgs(agentName, strconv.Itoa(i), agentName+"AccountName")
+			dependencies = append(dependencies, fmt.Sprintf("[concat('Microsoft.Storage/storageAccounts/', %s)]", accountNameArgs))
+			if i == 0 {
+				vhdContainers = append(vhdContainers, fmt.Sprintf("[concat(reference(concat('Microsoft.Storage/storageAccounts/',%s), variables('apiVersionStorage') ).primaryEndpoints.blob, 'osdisk')]", accountNameArgs))
+			} else {
+				vhdContainers = append(vhdContainers, fmt.Sprintf("[concat(reference(concat('Microsoft.Storage/storageAccounts/',%s), variables('apiVersionStorage')).primaryEndpoints.blob, 'osdisk')]", accountNameArgs))
+			}
+		}
+		osDisk["name"] = "vmssosdisk"
+		osDisk["vhdContainers"] = vhdContainers
+	}
+	if profile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = profile.OSDiskSizeGB
+	}
+	if !profile.IsCustomVNET() {
+		dependencies = append(dependencies, "[variables('vnetID')]")
+	}
+	ipConfigProperties := map[string]interface{}{
+		"subnet": map[string]interface{}{
+			"id": fmt.Sprintf("[variables('%sVnetSubnetID')]", agentName),
+		},
+	}
+	if isPublic {
+		dependencies = append(dependencies, fmt.Sprintf("[variables('%sLbID')]", agentName))
+		ipConfigProperties["loadBalancerBackendAddressPools"] = []interface{}{
+			map[string]interface{}{
+				"id": fmt.Sprintf("[concat(variables('%[1]sLbID'), '/backendAddressPools/', variables('%[1]sLbBackendPoolName'))]", agentName),
+			},
+		}
+		if profile.IsWindows() {
+			ipConfigProperties["loadBalancerInboundNatPools"] = getIaaSAgentRDPNatPools(agentName)
+		}
+	}
+
+	storageProfile := map[string]interface{}{
+		"imageReference": getSwarmAgentImageReference(profile),
+		"osDisk":         osDisk,
+	}
+	vmProfile := map[string]interface{}{
+		"networkProfile": map[string]interface{}{
+			"networkInterfaceConfigurations": []interface{}{
+				map[string]interface{}{
+					"name": "nic",
+					"properties": map[string]interface{}{
+						"ipConfigurations": []interface{}{
+							map[string]interface{}{
+								"name":       "nicipconfig",
+								"properties": ipConfigProperties,
+							},
+						},
+						"primary": "true",
+					},
+				},
+			},
+		},
+		"storageProfile": storageProfile,
+	}
+	if profile.IsWindows() {
+		osProfile := getSwarmAgentWindowsOSProfile(cs)
+		osProfile["computerNamePrefix"] = "[concat(substring(variables('nameSuffix'), 0, 5), 'acs')]"
+		vmProfile["osProfile"] = osProfile
+		vmProfile["extensionProfile"] = map[string]interface{}{
+			"extensions": []interface{}{
+				map[string]interface{}{
+					"name":       "vmssCustomScriptExtension",
+					"properties": getSwarmWindowsCustomScriptExtensionProperties(),
+				},
+			},
+		}
+	} else {
+		osProfile := getSwarmAgentLinuxOSProfile(cs, profile)
+		osProfile["computerNamePrefix"] = fmt.Sprintf("[variables('%sVMNamePrefix')]", agentName)
+		vmProfile["osProfile"] = osProfile
+		if dataDisks := getIaaSDataDisks(profile); dataDisks != nil {
+			storageProfile["dataDisks"] = dataDisks
+		}
+		if profile.IsRHEL() {
+			vmProfile["extensionProfile"] = map[string]interface{}{
+				"extensions": []interface{}{
+					map[string]interface{}{
+						"name":       "configure" + agentName,
+						"properties": getSwarmConfigureExtensionProperties(cs, "agentCustomScript"),
+					},
+				},
+			}
+		}
+	}
+
+	apiVersion := "[variables('apiVersionDefault')]"
+	if profile.IsManagedDisks() {
+		apiVersion = "[variables('apiVersionStorageManagedDisks')]"
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": apiVersion,
+		"dependsOn":  dependencies,
+		"tags": map[string]interface{}{
+			"creationSource": fmt.Sprintf("[concat('acsengine-', variables('%sVMNamePrefix'), '-vmss')]", agentName),
+		},
+		"location": "[variables('location')]",
+		"name":     fmt.Sprintf("[concat(variables('%sVMNamePrefix'), '-vmss')]", agentName),
+		"properties": map[string]interface{}{
+			"upgradePolicy": map[string]interface{}{
+				"mode": "Automatic",
+			},
+			"virtualMachineProfile": vmProfile,
+		},
+		"sku": map[string]interface{}{
+			"capacity": fmt.Sprintf("[variables('%sCount')]", agentName),
+			"name":     fmt.Sprintf("[variables('%sVMSize')]", agentName),
+			"tier":     fmt.Sprintf("[variables('%sVMSizeTier')]", agentName),
+		},
+		"type": "Microsoft.Compute/virtualMachineScaleSets",
+	})
+	return resources
+}
+
+// getSwarmMasterResources returns the resources of the masters of a Swarm or Swarm Mode cluster
+func getSwarmMasterResources(cs *api.ContainerService) []interface{} {
+	properties := cs.Properties
+	masterProfile := properties.MasterProfile
+	swarmMode := properties.OrchestratorProfile.IsSwarmMode()
+
+	resources := []interface{}{}
+	if !masterProfile.IsCustomVNET() {
+		resources = append(resources, getIaaSVirtualNetwork(cs, false))
+	}
+	if masterProfile.IsManagedDisks() {
+		resources = append(resources, getIaaSAvailabilitySet("masterAvailabilitySet", true))
+	} else if masterProfile.IsStorageAccount() {
+		resources = append(resources, getIaaSAvailabilitySet("masterAvailabilitySet", false), map[string]interface{}{
+			"apiVersion": "[variables('apiVersionStorage')]",
+			"dependsOn": []interface{}{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+			},
+			"location": "[variables('location')]",
+			"name":     "[variables('masterStorageAccountName')]",
+			"properties": map[string]interface{}{
+				"accountType": "[variables('vmSizesMap')[variables('masterVMSize')].storageAccountType]",
+			},
+			"type": "Microsoft.Storage/storageAccounts",
+		})
+	}
+
+	resources = append(resources,
+		map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"location":   "[variables('location')]",
+			"name":       "[variables('masterPublicIPAddressName')]",
+			"properties": map[string]interface{}{
+				"dnsSettings": map[string]interface{}{
+					"domainNameLabel": "[variables('masterEndpointDNSNamePrefix')]",
+				},
+				"publicIPAllocationMethod": "Dynamic",
+			},
+			"type": "Microsoft.Network/publicIPAddresses",
+		},
+		map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"dependsOn": []interface{}{
+				"[concat('Microsoft.Network/publicIPAddresses/', variables('masterPublicIPAddressName'))]",
+			},
+			"location": "[variables('location')]",
+			"name":     "[variables('masterLbName')]",
+			"properties": map[string]interface{}{
+				"backendAddressPools": []interface{}{
+					map[string]interface{}{
+						"name": "[variables('masterLbBackendPoolName')]",
+					},
+				},
+				"frontendIPConfigurations": []interface{}{
+					map[string]interface{}{
+						"name": "[variables('masterLbIPConfigName')]",
+						"properties": map[string]interface{}{
+							"publicIPAddress": map[string]interface{}{
+								"id": "[resourceId('Microsoft.Network/publicIPAddresses',variables('masterPublicIPAddressName'))]",
+							},
+						},
+					},
+				},
+			},
+			"type": "Microsoft.Network/loadBalancers",
+		},
+		map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"copy": map[string]interface{}{
+				"count": "[variables('masterCount')]",
+				"name":  "masterLbLoopNode",
+			},
+			"dependsOn": []interface{}{
+				"[variables('masterLbID')]",
+			},
+			"location": "[variables('location')]",
+			"name":     "[concat(variables('masterLbName'), '/', 'SSH-', variables('masterVMNamePrefix'), copyIndex())]",
+			"properties": map[string]interface{}{
+				"backendPort":      22,
+				"enableFloatingIP": false,
+				"frontendIPConfiguration": map[string]interface{}{
+					"id": "[variables('masterLbIPConfigID')]",
+				},
+				"frontendPort": "[copyIndex(2200)]",
+				"protocol":     "Tcp",
+			},
+			"type": "Microsoft.Network/loadBalancers/inboundNatRules",
+		},
+		map[string]interface{}{
+			"apiVersion": "[variables('apiVersionDefault')]",
+			"dependsOn": []interface{}{
+				"[variables('masterLbID')]",
+			},
+			"location": "[variables('location')]",
+			"name":     "[concat(variables('masterSshPort22InboundNatRuleNamePrefix'), '0')]",
+			"properties": map[string]interface{}{
+				"backendPort":      2222,
+				"enableFloatingIP": false,
+				"frontendIPConfiguration": map[string]interface{}{
+					"id": "[variables('masterLbIPConfigID')]",
+				},
+				"frontendPort": "22",
+				"protocol":     "Tcp",
+			},
+			"type": "Microsoft.Network/loadBalancers/inboundNatRules",
+		})
+
+	nicDependencies := []interface{}{}
+	if !masterProfile.IsCustomVNET() {
+		nicDependencies = append(nicDependencies, "[variables('vnetID')]")
+	}
+	nicDependencies = append(nicDependencies,
+		"[variables('masterLbID')]",
+		"[concat(variables('masterSshPort22InboundNatRuleIdPrefix'),'0')]",
+		"[concat(variables('masterSshInboundNatRuleIdPrefix'),copyIndex())]")
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "nicLoopNode",
+		},
+		"dependsOn": nicDependencies,
+		"location":  "[variables('location')]",
+		"name":      "[concat(variables('masterVMNamePrefix'), 'nic-', copyIndex())]",
+		"properties": map[string]interface{}{
+			"ipConfigurations": []interface{}{
+				map[string]interface{}{
+					"name": "ipConfigNode",
+					"properties": map[string]interface{}{
+						"loadBalancerBackendAddressPools": []interface{}{
+							map[string]interface{}{
+								"id": "[concat(variables('masterLbID'), '/backendAddressPools/', variables('masterLbBackendPoolName'))]",
+							},
+						},
+						"loadBalancerInboundNatRules": "[variables('masterLbInboundNatRules')[copyIndex()]]",
+						"privateIPAddress":            "[concat(variables('masterFirstAddrPrefix'), copyIndex(int(variables('masterFirstAddrOctet4'))))]",
+						"privateIPAllocationMethod":   "Static",
+						"subnet": map[string]interface{}{
+							"id": "[variables('masterVnetSubnetID')]",
+						},
+					},
+				},
+			},
+		},
+		"type": "Microsoft.Network/networkInterfaces",
+	})
+
+	vmDependencies := []interface{}{
+		"[concat('Microsoft.Network/networkInterfaces/', variables('masterVMNamePrefix'), 'nic-', copyIndex())]",
+		"[concat('Microsoft.Compute/availabilitySets/',variables('masterAvailabilitySet'))]",
+	}
+	if masterProfile.IsStorageAccount() {
+		vmDependencies = append(vmDependencies, "[variables('masterStorageAccountName')]")
+	}
+	osProfile := map[string]interface{}{
+		"adminUsername":      "[variables('adminUsername')]",
+		"computername":       "[concat(variables('masterVMNamePrefix'), copyIndex())]",
+		"linuxConfiguration": getSwarmLinuxConfiguration("[variables('sshRSAPublicKey')]"),
+	}
+	if !swarmMode || !masterProfile.IsRHEL() {
+		osProfile["customData"] = getCustomDataFromFragment(getMasterSwarmCustomData(cs, swarmMode))
+	}
+	if properties.LinuxProfile.HasSecrets() {
+		osProfile["secrets"] = "[variables('linuxProfileSecrets')]"
+	}
+	imageReference := map[string]interface{}{
+		"offer":     "[variables('osImageOffer')]",
+		"publisher": "[variables('osImagePublisher')]",
+		"sku":       "[variables('osImageSKU')]",
+		"version":   "[variables('osImageVersion')]",
+	}
+	if swarmMode {
+		imageReference = map[string]interface{}{
+			"offer":     "[variables('masterOSImageOffer')]",
+			"publisher": "[variables('masterOSImagePublisher')]",
+			"sku":       "[variables('masterOSImageSKU')]",
+			"version":   "[variables('masterOSImageVersion')]",
+		}
+	}
+	osDisk := map[string]interface{}{
+		"caching":      "ReadWrite",
+		"createOption": "FromImage",
+	}
+	if masterProfile.IsStorageAccount() {
+		osDisk["name"] = "[concat(variables('masterVMNamePrefix'), copyIndex(),'-osdisk')]"
+		osDisk["vhd"] = map[string]interface{}{
+			"uri": "[concat(reference(concat('Microsoft.Storage/storageAccounts/', variables('masterStorageAccountName')), variables('apiVersionStorage')).primaryEndpoints.blob, 'vhds/', variables('masterVMNamePrefix'), copyIndex(), '-osdisk.vhd')]",
+		}
+	}
+	if masterProfile.OSDiskSizeGB != 0 {
+		osDisk["diskSizeGB"] = masterProfile.OSDiskSizeGB
+	}
+	apiVersion := "[variables('apiVersionDefault')]"
+	if masterProfile.IsManagedDisks() {
+		apiVersion = "[variables('apiVersionStorageManagedDisks')]"
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": apiVersion,
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": vmDependencies,
+		"tags": map[string]interface{}{
+			"creationSource": "[concat('acsengine-', variables('masterVMNamePrefix'), copyIndex())]",
+		},
+		"location": "[variables('location')]",
+		"name":     "[concat(variables('masterVMNamePrefix'), copyIndex())]",
+		"properties": map[string]interface{}{
+			"availabilitySet": map[string]interface{}{
+				"id": "[resourceId('Microsoft.Compute/availabilitySets',variables('masterAvailabilitySet'))]",
+			},
+			"hardwareProfile": map[string]interface{}{
+				"vmSize": "[variables('masterVMSize')]",
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{
+						"id": "[resourceId('Microsoft.Network/networkInterfaces',concat(variables('masterVMNamePrefix'), 'nic-', copyIndex()))]",
+					},
+				},
+			},
+			"osProfile": osProfile,
+			"storageProfile": map[string]interface{}{
+				"imageReference": imageReference,
+				"osDisk":         osDisk,
+			},
+		},
+		"type": "Microsoft.Compute/virtualMachines",
+	})
+
+	configureMaster := getSwarmConfigureExtensionProperties(cs, "masterCustomScript")
+	if !masterProfile.IsRHEL() {
+		configureMaster["settings"].(map[string]interface{})["fileUris"] = []interface{}{}
+	}
+	resources = append(resources, map[string]interface{}{
+		"apiVersion": "[variables('apiVersionDefault')]",
+		"copy": map[string]interface{}{
+			"count": "[variables('masterCount')]",
+			"name":  "vmLoopNode",
+		},
+		"dependsOn": []interface{}{
+			"[concat('Microsoft.Compute/virtualMachines/', concat(variables('masterVMNamePrefix'), copyIndex()))]",
+		},
+		"location":   "[variables('location')]",
+		"name":       "[concat(variables('masterVMNamePrefix'), copyIndex(), '/configuremaster')]",
+		"properties": configureMaster,
+		"type":       "Microsoft.Compute/virtualMachines/extensions",
+	})
+	return resources
+}
@@ -32,6 +32,11 @@ type ARMTemplate struct {
 	Outputs        interface{} `json:"outputs,omitempty"`
 }
 
+const (
+	armTemplateSchema         = "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#"
+	armTemplateContentVersion = "1.0.0.0"
+)
+
 // TemplateGenerator represents the object that performs the template generation.
 type TemplateGenerator struct {
 	Translator *i18n.Translator
@@ -50,13 +55,73 @@ func InitializeTemplateGenerator(ctx Context) (*TemplateGenerator, error) {
 	return t, nil
 }
 
-// GenerateTemplate generates the template from the API Model
+// GenerateTemplate generates the template of a DCOS or Swarm cluster from the API Model
 func (t *TemplateGenerator) GenerateTemplate(containerService *api.ContainerService, generatorCode string, aksEngineVersion string) (templateRaw string, parametersRaw string, err error) {
 	// named return values are used in order to set err in case of a panic
 	templateRaw = ""
 	parametersRaw = ""
 	err = nil
 
+	properties := containerService.Properties
+	// save the current orchestrator version and restore it after deploying.
+	// this allows us to deploy agents on the most recent patch without updating the orchestator version in the object
+	orchVersion := properties.OrchestratorProfile.OrchestratorVersion
+	defer func() {
+		properties.OrchestratorProfile.OrchestratorVersion = orchVersion
+	}()
+
+	// template generation may have panics in the called functions.  This catches those panics
+	// and ensures the panic is returned as an error
+	defer func() {
+		if r := recover(); r != nil {
+			s := debug.Stack()
+			err = errors.Errorf("%v - %s", r, s)
+
+			// invalidate the template and the parameters
+			templateRaw = ""
+			parametersRaw = ""
+		}
+	}()
+
+	if !validateDistro(containerService) {
+		return templateRaw, parametersRaw, errors.New("Invalid distro")
+	}
+
+	var armTemplate ARMTemplate
+	switch properties.OrchestratorProfile.OrchestratorType {
+	case api.DCOS:
+		armTemplate = getDCOSTemplate(containerService)
+	case api.Swarm, api.SwarmMode:
+		armTemplate = getSwarmTemplate(containerService)
+	default:
+		return templateRaw, parametersRaw, t.Translator.Errorf("orchestrator '%s' is unsupported", properties.OrchestratorProfile.OrchestratorType)
+	}
+
+	var templBytes []byte
+	if templBytes, err = json.Marshal(armTemplate); err != nil {
+		return templateRaw, parametersRaw, err
+	}
+	templateRaw = string(templBytes)
+
+	var parametersMap = getParameters(containerService, generatorCode, aksEngineVersion)
+
+	var parameterBytes []byte
+	if parameterBytes, err = helpers.JSONMarshal(parametersMap, false); err != nil {
+		return templateRaw, parametersRaw, err
+	}
+	parametersRaw = string(parameterBytes)
+
+	return templateRaw, parametersRaw, err
+}
+
+// generateTemplateFromParts generates the template of a DCOS or Swarm cluster by executing the text templates under parts.
+// It is only kept to check that GenerateTemplate renders the same templates, and will be removed with the text templates.
+func (t *TemplateGenerator) generateTemplateFromParts(containerService *api.ContainerService, generatorCode string, aksEngineVersion string) (templateRaw string, parametersRaw string, err error) {
+	// named return values are used in order to set err in case of a panic
+	templateRaw = ""
+	parametersRaw = ""
+	err = nil
+
 	var templ *template.Template
 
 	properties := containerService.Properties
@@ -433,20 +498,10 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 			return str
 		},
 		"GetMasterSwarmCustomData": func() string {
-			files := []string{swarmProvision}
-			str := buildYamlFileWithWriteFiles(files)
-			if cs.Properties.MasterProfile.PreprovisionExtension != nil {
-				extensionStr := makeMasterExtensionScriptCommands(cs)
-				str += "'runcmd:\n" + extensionStr + "\n\n'"
-			}
-			str = escapeSingleLine(str)
-			return fmt.Sprintf("\"customData\": \"[base64(concat('%s'))]\",", str)
+			return getMasterSwarmCustomData(cs, false)
 		},
 		"GetAgentSwarmCustomData": func(profile *api.AgentPoolProfile) string {
-			files := []string{swarmProvision}
-			str := buildYamlFileWithWriteFiles(files)
-			str = escapeSingleLine(str)
-			return fmt.Sprintf("\"customData\": \"[base64(concat('%s',variables('%sRunCmdFile'),variables('%sRunCmd')))]\",", str, profile.Name, profile.Name)
+			return getAgentSwarmCustomData(profile, false)
 		},
 		"GetSwarmAgentPreprovisionExtensionCommands": func(profile *api.AgentPoolProfile) string {
 			str := ""
@@ -460,12 +515,10 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 			return cs.Location
 		},
 		"GetWinAgentSwarmCustomData": func() string {
-			str := getBase64EncodedGzippedCustomScript(swarmWindowsProvision)
-			return fmt.Sprintf("\"customData\": \"%s\"", str)
+			return getWinAgentSwarmCustomData(false)
 		},
 		"GetWinAgentSwarmModeCustomData": func() string {
-			str := getBase64EncodedGzippedCustomScript(swarmModeWindowsProvision)
-			return fmt.Sprintf("\"customData\": \"%s\"", str)
+			return getWinAgentSwarmCustomData(true)
 		},
 		"GetKubernetesWindowsAgentFunctions": func() string {
 			// Collect all the parts into a zip
@@ -475,7 +528,8 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 				kubernetesWindowsKubeletFunctionsPS1,
 				kubernetesWindowsCniFunctionsPS1,
 				kubernetesWindowsAzureCniFunctionsPS1,
-				kubernetesWindowsOpenSSHFunctionPS1}
+				kubernetesWindowsOpenSSHFunctionPS1,
+				kubernetesWindowsContainerdFunctionsPS1}
 
 			// Create a buffer, new zip
 			buf := new(bytes.Buffer)
@@ -502,20 +556,10 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 			return base64.StdEncoding.EncodeToString(buf.Bytes())
 		},
 		"GetMasterSwarmModeCustomData": func() string {
-			files := []string{swarmModeProvision}
-			str := buildYamlFileWithWriteFiles(files)
-			if cs.Properties.MasterProfile.PreprovisionExtension != nil {
-				extensionStr := makeMasterExtensionScriptCommands(cs)
-				str += "runcmd:\n" + extensionStr + "\n\n"
-			}
-			str = escapeSingleLine(str)
-			return fmt.Sprintf("\"customData\": \"[base64(concat('%s'))]\",", str)
+			return getMasterSwarmCustomData(cs, true)
 		},
 		"GetAgentSwarmModeCustomData": func(profile *api.AgentPoolProfile) string {
-			files := []string{swarmModeProvision}
-			str := buildYamlFileWithWriteFiles(files)
-			str = escapeSingleLine(str)
-			return fmt.Sprintf("\"customData\": \"[base64(concat('%s',variables('%sRunCmdFile'),variables('%sRunCmd')))]\",", str, profile.Name, profile.Name)
+			return getAgentSwarmCustomData(profile, true)
 		},
 		"WrapAsVariable": func(s string) string {
 			return common.WrapAsARMVariable(s)
@@ -562,15 +606,24 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 		"HasWindowsCustomImage": func() bool {
 			return cs.Properties.WindowsProfile.HasCustomImage()
 		},
+		"NeedsContainerd": func() bool {
+			kubernetesConfig := cs.Properties.OrchestratorProfile.KubernetesConfig
+			return kubernetesConfig != nil && !kubernetesConfig.RequiresDocker()
+		},
+		"GetWindowsPrePullImages": func() string {
+			return getWindowsPrePullImages(cs.Properties.WindowsProfile)
+		},
+		"HasWindowsDomainJoin": func() bool {
+			return cs.Properties.WindowsProfile.HasDomainJoin()
+		},
+		"GetWindowsDomainJoin": func() *api.WindowsDomainJoin {
+			return cs.Properties.WindowsProfile.DomainJoin
+		},
 		"WindowsSSHEnabled": func() bool {
 			return cs.Properties.WindowsProfile.SSHEnabled
 		},
 		"GetConfigurationScriptRootURL": func() string {
-			linuxProfile := cs.Properties.LinuxProfile
-			if linuxProfile == nil || linuxProfile.ScriptRootURL == "" {
-				return DefaultConfigurationScriptRootURL
-			}
-			return linuxProfile.ScriptRootURL
+			return getConfigurationScriptRootURL(cs)
 		},
 		"GetMasterOSImageOffer": func() string {
 			cloudSpecConfig := cs.GetCloudSpecConfig()
@@ -647,9 +700,14 @@ func (t *TemplateGenerator) getTemplateFuncMap(cs *api.ContainerService) templat
 	}
 }
 
+// GenerateTemplateV2 generates the template and parameters of a cluster from the typed ARM resource builders.
+// DCOS and Swarm clusters are generated by GenerateTemplate.
 func (t *TemplateGenerator) GenerateTemplateV2(containerService *api.ContainerService, generatorCode string, acsengineVersion string) (templateRaw string, parametersRaw string, err error) {
+	if !containerService.Properties.OrchestratorProfile.IsKubernetes() {
+		return t.GenerateTemplate(containerService, generatorCode, acsengineVersion)
+	}
 
-	armParams, _ := t.getParameterDescMap(containerService)
+	armParams := GetKubernetesParameters(containerService)
 	armResources := GenerateARMResources(containerService)
 	armVariables, err := GetKubernetesVariables(containerService)
 	if err != nil {
@@ -658,8 +716,8 @@ func (t *TemplateGenerator) GenerateTemplateV2(containerService *api.ContainerSe
 	armOutputs := GetKubernetesOutputs(containerService)
 
 	armTemplate := ARMTemplate{
-		Schema:         "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
-		ContentVersion: "1.0.0.0",
+		Schema:         armTemplateSchema,
+		ContentVersion: armTemplateContentVersion,
 		Parameters:     armParams,
 		Variables:      armVariables,
 		Resources:      armResources,
@@ -683,6 +741,8 @@ func (t *TemplateGenerator) GenerateTemplateV2(containerService *api.ContainerSe
 	return templateRaw, parametersRaw, err
 }
 
+// getParameterDescMap returns the parameters of a Kubernetes template by executing the text templates under parts.
+// It is only kept to check that GetKubernetesParameters declares the same parameters, and will be removed with the text templates.
 func (t *TemplateGenerator) getParameterDescMap(containerService *api.ContainerService) (interface{}, error) {
 	var templ *template.Template
 	var paramsDescMap map[string]interface{}
@@ -134,6 +134,7 @@
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-blobfuse-flexvolume-installer.yaml
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-calico-daemonset.yaml
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-cluster-autoscaler-deployment.yaml
+// ../../parts/k8s/containeraddons/kubernetesmasteraddons-gmsa-webhook-deployment.yaml
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-heapster-deployment.yaml
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-keyvault-flexvolume-installer.yaml
 // ../../parts/k8s/containeraddons/kubernetesmasteraddons-kube-rescheduler-deployment.yaml
@@ -156,6 +157,7 @@
 // ../../parts/k8s/windowsazurecnifunc.ps1
 // ../../parts/k8s/windowscnifunc.ps1
 // ../../parts/k8s/windowsconfigfunc.ps1
+// ../../parts/k8s/windowscontainerdfunc.ps1
 // ../../parts/k8s/windowsinstallopensshfunc.ps1
 // ../../parts/k8s/windowskubeletfunc.ps1
 // ../../parts/masteroutputs.t
@@ -12478,9 +12480,11 @@ configureK8s() {
     "vmType": "${VM_TYPE}",
     "subnetName": "${SUBNET}",
     "securityGroupName": "${NETWORK_SECURITY_GROUP}",
+    "securityGroupResourceGroup": "${NETWORK_SECURITY_GROUP_RESOURCE_GROUP}",
     "vnetName": "${VIRTUAL_NETWORK}",
     "vnetResourceGroup": "${VIRTUAL_NETWORK_RESOURCE_GROUP}",
     "routeTableName": "${ROUTE_TABLE}",
+    "routeTableResourceGroup": "${ROUTE_TABLE_RESOURCE_GROUP}",
     "primaryAvailabilitySetName": "${PRIMARY_AVAILABILITY_SET}",
     "primaryScaleSetName": "${PRIMARY_SCALE_SET}",
     "cloudProviderBackoff": ${CLOUDPROVIDER_BACKOFF},
@@ -12495,6 +12499,7 @@ configureK8s() {
     "userAssignedIdentityID": "${USER_ASSIGNED_IDENTITY_ID}",
     "useInstanceMetadata": ${USE_INSTANCE_METADATA},
     "loadBalancerSku": "${LOAD_BALANCER_SKU}",
+    "outboundType": "${OUTBOUND_TYPE}",
     "excludeMasterFromStandardLB": ${EXCLUDE_MASTER_FROM_STANDARD_LB},
     "providerVaultName": "${KMS_PROVIDER_VAULT_NAME}",
     "maximumLoadBalancerRuleCount": ${MAXIMUM_LOADBALANCER_RULE_COUNT},
@@ -12731,6 +12736,30 @@ configACIConnectorAddon() {
     sed -i "s|<key>|$ACI_CONNECTOR_KEY|g" $ACI_CONNECTOR_ADDON_FILE
 }
 
+configGMSAWebhookAddon() {
+    # every master signs its own serving pair with the cluster CA, the gmsa-webhook-secret is EnsureExists so that
+    # the first pair the addon managers create is kept rather than overwritten by the other masters
+    GMSA_WEBHOOK_KEY_PATH=/etc/kubernetes/certs/gmsa-webhook.key
+    GMSA_WEBHOOK_CERT_PATH=/etc/kubernetes/certs/gmsa-webhook.crt
+    GMSA_WEBHOOK_CSR_PATH=$(mktemp)
+    GMSA_WEBHOOK_EXT_PATH=$(mktemp)
+    echo "subjectAltName=DNS:gmsa-webhook.kube-system.svc" > $GMSA_WEBHOOK_EXT_PATH
+    openssl genrsa -out $GMSA_WEBHOOK_KEY_PATH 2048
+    chmod 0600 $GMSA_WEBHOOK_KEY_PATH
+    openssl req -new -key $GMSA_WEBHOOK_KEY_PATH -out $GMSA_WEBHOOK_CSR_PATH -subj "/CN=gmsa-webhook.kube-system.svc"
+    openssl x509 -req -days 730 -in $GMSA_WEBHOOK_CSR_PATH -CA /etc/kubernetes/certs/ca.crt -CAkey /etc/kubernetes/certs/ca.key -CAcreateserial -extfile $GMSA_WEBHOOK_EXT_PATH -out $GMSA_WEBHOOK_CERT_PATH
+    rm -f $GMSA_WEBHOOK_CSR_PATH $GMSA_WEBHOOK_EXT_PATH
+    GMSA_WEBHOOK_KEY=$(base64 $GMSA_WEBHOOK_KEY_PATH -w0)
+    GMSA_WEBHOOK_CERT=$(base64 $GMSA_WEBHOOK_CERT_PATH -w0)
+    GMSA_WEBHOOK_CA_BUNDLE=$(base64 /etc/kubernetes/certs/ca.crt -w0)
+
+    GMSA_WEBHOOK_ADDON_FILE=/etc/kubernetes/addons/gmsa-webhook-deployment.yaml
+    wait_for_file 1200 1 $GMSA_WEBHOOK_ADDON_FILE || exit $ERR_FILE_WATCH_TIMEOUT
+    sed -i "s|<cert>|$GMSA_WEBHOOK_CERT|g" $GMSA_WEBHOOK_ADDON_FILE
+    sed -i "s|<key>|$GMSA_WEBHOOK_KEY|g" $GMSA_WEBHOOK_ADDON_FILE
+    sed -i "s|<caBundle>|$GMSA_WEBHOOK_CA_BUNDLE|g" $GMSA_WEBHOOK_ADDON_FILE
+}
+
 configAddons() {
     if [[ "${CLUSTER_AUTOSCALER_ADDON}" = True ]]; then
         configClusterAutoscalerAddon
@@ -12739,6 +12768,10 @@ configAddons() {
     if [[ "${ACI_CONNECTOR_ADDON}" = True ]]; then
         configACIConnectorAddon
     fi
+
+    if [[ "${GMSA_WEBHOOK_ADDON}" = true ]]; then
+        configGMSAWebhookAddon
+    fi
 }
 
 configGPUDrivers() {
@@ -21552,6 +21585,215 @@ func k8sContaineraddonsKubernetesmasteraddonsClusterAutoscalerDeploymentYaml() (
 	return a, nil
 }
 
+var _k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYaml = []byte(`apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: gmsacredentialspecs.windows.k8s.io
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+spec:
+  group: windows.k8s.io
+  version: v1alpha1
+  names:
+    kind: GMSACredentialSpec
+    plural: gmsacredentialspecs
+  scope: Cluster
+  validation:
+    openAPIV3Schema:
+      properties:
+        credspec:
+          description: GMSA Credential Spec
+          type: object
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: gmsa-webhook
+  namespace: kube-system
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: gmsa-webhook
+  labels:
+    app: gmsa-webhook
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+rules:
+- apiGroups:
+  - windows.k8s.io
+  resources:
+  - gmsacredentialspecs
+  verbs:
+  - get
+  - list
+  - watch
+- apiGroups:
+  - authorization.k8s.io
+  resources:
+  - localsubjectaccessreviews
+  verbs:
+  - create
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: gmsa-webhook
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: gmsa-webhook
+subjects:
+- kind: ServiceAccount
+  name: gmsa-webhook
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: gmsa-webhook-secret
+  namespace: kube-system
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: EnsureExists
+type: Opaque
+data:
+  cert.pem: <cert>
+  key.pem: <key>
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gmsa-webhook
+  namespace: kube-system
+  labels:
+    app: gmsa-webhook
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: gmsa-webhook
+  template:
+    metadata:
+      labels:
+        app: gmsa-webhook
+    spec:
+      serviceAccountName: gmsa-webhook
+      nodeSelector:
+        beta.kubernetes.io/os: linux
+      containers:
+      - name: gmsa-webhook
+        image: {{ContainerImage "gmsa-webhook"}}
+        imagePullPolicy: IfNotPresent
+        env:
+        - name: TLS_CRT
+          value: /tls/cert.pem
+        - name: TLS_KEY
+          value: /tls/key.pem
+        ports:
+        - containerPort: 443
+        resources:
+          requests:
+            cpu: {{ContainerCPUReqs "gmsa-webhook"}}
+            memory: {{ContainerMemReqs "gmsa-webhook"}}
+          limits:
+            cpu: {{ContainerCPULimits "gmsa-webhook"}}
+            memory: {{ContainerMemLimits "gmsa-webhook"}}
+        volumeMounts:
+        - name: tls
+          mountPath: "/tls"
+          readOnly: true
+      volumes:
+      - name: tls
+        secret:
+          secretName: gmsa-webhook-secret
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: gmsa-webhook
+  namespace: kube-system
+  labels:
+    app: gmsa-webhook
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+spec:
+  ports:
+  - port: 443
+    targetPort: 443
+  selector:
+    app: gmsa-webhook
+---
+apiVersion: admissionregistration.k8s.io/v1beta1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: gmsa-webhook
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+webhooks:
+- name: admission-webhook.windows-gmsa.sigs.k8s.io
+  clientConfig:
+    service:
+      name: gmsa-webhook
+      namespace: kube-system
+      path: "/validate"
+    caBundle: <caBundle>
+  rules:
+  - operations: ["CREATE", "UPDATE"]
+    apiGroups: [""]
+    apiVersions: ["*"]
+    resources: ["pods"]
+  failurePolicy: Fail
+---
+apiVersion: admissionregistration.k8s.io/v1beta1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: gmsa-webhook
+  labels:
+    kubernetes.io/cluster-service: "true"
+    addonmanager.kubernetes.io/mode: Reconcile
+webhooks:
+- name: admission-webhook.windows-gmsa.sigs.k8s.io
+  clientConfig:
+    service:
+      name: gmsa-webhook
+      namespace: kube-system
+      path: "/mutate"
+    caBundle: <caBundle>
+  rules:
+  - operations: ["CREATE"]
+    apiGroups: [""]
+    apiVersions: ["*"]
+    resources: ["pods"]
+  failurePolicy: Fail
+#EOF
+`)
+
+func k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYamlBytes() ([]byte, error) {
+	return _k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYaml, nil
+}
+
+func k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYaml() (*asset, error) {
+	bytes, err := k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "k8s/containeraddons/kubernetesmasteraddons-gmsa-webhook-deployment.yaml", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _k8sContaineraddonsKubernetesmasteraddonsHeapsterDeploymentYaml = []byte(`apiVersion: v1
 kind: ServiceAccount
 metadata:
@@ -23756,7 +23998,10 @@ param(
 
     [parameter(Mandatory=$true)]
     [ValidateNotNullOrEmpty()]
-    $TargetEnvironment
+    $TargetEnvironment,
+
+    [parameter()]
+    $DomainJoinPassword # base64
 )
 
 
@@ -23779,6 +24024,21 @@ $global:KubeBinariesVersion = "{{WrapAsParameter "kubeBinariesVersion"}}"
 ## Docker Version
 $global:DockerVersion = "{{WrapAsParameter "windowsDockerVersion"}}"
 
+## Container runtime
+$global:ContainerRuntime = "{{WrapAsParameter "containerRuntime"}}"
+{{if NeedsContainerd}}
+$global:ContainerdURL = "{{WrapAsParameter "windowsContainerdURL"}}"
+{{end}}
+$global:WindowsIsolation = "{{.GetWindowsIsolation}}"
+$global:PrePullImages = @({{GetWindowsPrePullImages}})
+{{with GetWindowsDomainJoin}}
+
+## Active Directory domain the node joins, the password is passed as a parameter
+$global:DomainName = "{{.Domain}}"
+$global:DomainOrganizationalUnit = "{{.OrganizationalUnit}}"
+$global:DomainUserName = "{{.UserName}}"
+{{end}}
+
 ## VM configuration passed by Azure
 $global:WindowsTelemetryGUID = "{{WrapAsParameter "windowsTelemetryGUID"}}"
 {{if eq GetIdentitySystem "adfs"}}
@@ -23792,8 +24052,10 @@ $global:VmType = "{{WrapAsVariable "vmType"}}"
 $global:SubnetName = "{{WrapAsVariable "subnetName"}}"
 $global:MasterSubnet = "{{GetWindowsMasterSubnetARMParam}}"
 $global:SecurityGroupName = "{{WrapAsVariable "nsgName"}}"
+$global:SecurityGroupResourceGroup = "{{WrapAsVariable "nsgResourceGroupName"}}"
 $global:VNetName = "{{WrapAsVariable "virtualNetworkName"}}"
 $global:RouteTableName = "{{WrapAsVariable "routeTableName"}}"
+$global:RouteTableResourceGroup = "{{WrapAsVariable "routeTableResourceGroupName"}}"
 $global:PrimaryAvailabilitySetName = "{{WrapAsVariable "primaryAvailabilitySetName"}}"
 $global:PrimaryScaleSetName = "{{WrapAsVariable "primaryScaleSetName"}}"
 
@@ -23812,6 +24074,7 @@ $global:UserAssignedClientID = "{{WrapAsVariable "userAssignedClientID"}}"
 $global:UseInstanceMetadata = "{{WrapAsVariable "useInstanceMetadata"}}"
 
 $global:LoadBalancerSku = "{{WrapAsVariable "loadBalancerSku"}}"
+$global:OutboundType = "{{WrapAsVariable "outboundType"}}"
 $global:ExcludeMasterFromStandardLB = "{{WrapAsVariable "excludeMasterFromStandardLB"}}"
 
 
@@ -23850,13 +24113,18 @@ Expand-Archive scripts.zip -DestinationPath "C:\\AzureData\\"
 . c:\AzureData\k8s\windowscnifunc.ps1
 . c:\AzureData\k8s\windowsazurecnifunc.ps1
 . c:\AzureData\k8s\windowsinstallopensshfunc.ps1
+. c:\AzureData\k8s\windowscontainerdfunc.ps1
 
 function
 Update-ServiceFailureActions()
 {
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerRuntime
+    )
     sc.exe failure "kubelet" actions= restart/60000/restart/60000/restart/60000 reset= 900
     sc.exe failure "kubeproxy" actions= restart/60000/restart/60000/restart/60000 reset= 900
-    sc.exe failure "docker" actions= restart/60000/restart/60000/restart/60000 reset= 900
+    sc.exe failure $ContainerRuntime actions= restart/60000/restart/60000/restart/60000 reset= 900
 }
 
 try
@@ -23880,8 +24148,18 @@ try
         Write-Log "Create required data directories as needed"
         Initialize-DataDirectories
 
+        {{if NeedsContainerd}}
+        Write-Log "Install containerd"
+        Install-Containerd -ContainerdURL $global:ContainerdURL
+
+        if ($global:WindowsIsolation -eq "hyperv") {
+            Write-Log "Enable Hyper-V to isolate the pods"
+            Enable-HyperV
+        }
+        {{else}}
         Write-Log "Install docker"
         Install-Docker -DockerVersion $global:DockerVersion
+        {{end}}
 
         Write-Log "Download kubelet binaries and unzip"
         Get-KubePackage -KubeBinariesSASURL $global:KubeBinariesPackageSASURL
@@ -23908,14 +24186,17 @@ try
             -VmType $global:VmType ` + "`" + `
             -SubnetName $global:SubnetName ` + "`" + `
             -SecurityGroupName $global:SecurityGroupName ` + "`" + `
+            -SecurityGroupResourceGroup $global:SecurityGroupResourceGroup ` + "`" + `
             -VNetName $global:VNetName ` + "`" + `
             -RouteTableName $global:RouteTableName ` + "`" + `
+            -RouteTableResourceGroup $global:RouteTableResourceGroup ` + "`" + `
             -PrimaryAvailabilitySetName $global:PrimaryAvailabilitySetName ` + "`" + `
             -PrimaryScaleSetName $global:PrimaryScaleSetName ` + "`" + `
             -UseManagedIdentityExtension $global:UseManagedIdentityExtension ` + "`" + `
             -UserAssignedClientID $global:UserAssignedClientID ` + "`" + `
             -UseInstanceMetadata $global:UseInstanceMetadata ` + "`" + `
             -LoadBalancerSku $global:LoadBalancerSku ` + "`" + `
+            -OutboundType $global:OutboundType ` + "`" + `
             -ExcludeMasterFromStandardLB $global:ExcludeMasterFromStandardLB ` + "`" + `
             -TargetEnvironment $TargetEnvironment
 
@@ -23938,8 +24219,10 @@ try
                          -AgentCertificate $global:AgentCertificate
 
 
+        {{if not NeedsContainerd}}
         Write-Log "Create the Pause Container kubletwin/pause"
         New-InfraContainer -KubeDir $global:KubeDir
+        {{end}}
 
         Write-Log "Configuring networking with NetworkPlugin:$global:NetworkPlugin"
 
@@ -23973,6 +24256,25 @@ try
             Get-HnsPsm1 -HNSModule $global:HNSModule
         }
 
+        {{if NeedsContainerd}}
+        Write-Log "Configure and start containerd with $global:WindowsIsolation isolation"
+        if ($global:NetworkPlugin -eq "azure") {
+            Set-ContainerdConfig -CNIBinDir $global:AzureCNIBinDir ` + "`" + `
+                                 -CNIConfDir $global:AzureCNIConfDir ` + "`" + `
+                                 -WindowsIsolation $global:WindowsIsolation
+        } else {
+            Set-ContainerdConfig -CNIBinDir $global:CNIPath ` + "`" + `
+                                 -CNIConfDir $global:CNIConfigPath ` + "`" + `
+                                 -WindowsIsolation $global:WindowsIsolation
+        }
+        Start-ContainerdService
+        {{end}}
+
+        if ($global:PrePullImages.Count -gt 0) {
+            Write-Log "Pre-pull container images"
+            Get-ContainerImages -ContainerRuntime $global:ContainerRuntime -Images $global:PrePullImages
+        }
+
         Write-Log "Write kubelet startfile with pod CIDR of $podCIDR"
         Install-KubernetesServices ` + "`" + `
             -KubeletConfigArgs $global:KubeletConfigArgs ` + "`" + `
@@ -23991,7 +24293,8 @@ try
             -KubeClusterCIDR $global:KubeClusterCIDR ` + "`" + `
             -KubeServiceCIDR $global:KubeServiceCIDR ` + "`" + `
             -HNSModule $global:HNSModule ` + "`" + `
-            -KubeletNodeLabels $global:KubeletNodeLabels
+            -KubeletNodeLabels $global:KubeletNodeLabels ` + "`" + `
+            -ContainerRuntime $global:ContainerRuntime
 
         # Install OpenSSH if SSH enabled
         $sshEnabled = [System.Convert]::ToBoolean("{{ WindowsSSHEnabled }}")
@@ -24010,7 +24313,15 @@ try
         PREPROVISION_EXTENSION
 
         Write-Log "Update service failure actions"
-        Update-ServiceFailureActions
+        Update-ServiceFailureActions -ContainerRuntime $global:ContainerRuntime
+
+        {{if HasWindowsDomainJoin}}
+        Write-Log "Join the Active Directory domain $global:DomainName"
+        Join-Domain -DomainName $global:DomainName ` + "`" + `
+                    -OrganizationalUnit $global:DomainOrganizationalUnit ` + "`" + `
+                    -UserName $global:DomainUserName ` + "`" + `
+                    -Password $([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($DomainJoinPassword)))
+        {{end}}
 
         Write-Log "Setup Complete, reboot computer"
         Restart-Computer
@@ -24719,6 +25030,67 @@ function Install-Docker
     }
 }
 
+function Get-ContainerImages
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerRuntime,
+        [Parameter(Mandatory=$true)][string[]]
+        $Images
+    )
+
+    foreach ($image in $Images) {
+        Write-Log "Pre-pulling container image $image"
+        for ($i = 0; $i -lt 5; $i++) {
+            if ($ContainerRuntime -eq "containerd") {
+                & ctr.exe -n k8s.io images pull $image
+            } else {
+                & docker pull $image
+            }
+            if ($LASTEXITCODE -eq 0) {
+                break
+            }
+            Start-Sleep -Seconds 10
+        }
+        if ($LASTEXITCODE -ne 0) {
+            # not fatal, the kubelet pulls the image when a pod needs it
+            Write-Log "Failed to pre-pull container image $image"
+        }
+    }
+}
+
+function Join-Domain
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $DomainName,
+        [Parameter(Mandatory=$false)][string]
+        $OrganizationalUnit,
+        [Parameter(Mandatory=$true)][string]
+        $UserName,
+        [Parameter(Mandatory=$true)][string]
+        $Password
+    )
+
+    # a plain account name is qualified with the domain, a UPN or down-level name is used as is
+    if ($UserName -notmatch "[\\@]") {
+        $UserName = "$DomainName\$UserName"
+    }
+    $securePassword = ConvertTo-SecureString -String $Password -AsPlainText -Force
+    $credential = New-Object System.Management.Automation.PSCredential($UserName, $securePassword)
+
+    $joinParams = @{
+        DomainName = $DomainName
+        Credential = $credential
+        Force = $true
+    }
+    if ($OrganizationalUnit) {
+        $joinParams.OUPath = $OrganizationalUnit
+    }
+    # the node is restarted at the end of the setup, which completes the join
+    Add-Computer @joinParams -ErrorAction Stop
+}
+
 # Pagefile adjustments
 function Adjust-PageFileSize()
 {
@@ -24741,6 +25113,127 @@ func k8sWindowsconfigfuncPs1() (*asset, error) {
 	return a, nil
 }
 
+var _k8sWindowscontainerdfuncPs1 = []byte(`$global:ContainerdInstallLocation = "$Env:ProgramFiles\containerd"
+
+function Install-Containerd
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $ContainerdURL
+    )
+
+    $tempdir = New-TemporaryDirectory
+    $containerdPackage = [Io.path]::Combine($tempdir, "containerd.tar.gz")
+    for ($i = 0; $i -le 10; $i++) {
+        DownloadFileOverHttp -Url $ContainerdURL -DestinationPath $containerdPackage
+        if ($?) {
+            break
+        }
+        else {
+            Write-Log $Error[0].Exception.Message
+        }
+    }
+
+    # using tar to minimize dependencies
+    tar -xzf $containerdPackage -C $tempdir
+
+    if (!(Test-Path $global:ContainerdInstallLocation)) {
+        mkdir $global:ContainerdInstallLocation
+    }
+    # the release packages keep the binaries under bin, look them up wherever they are
+    Get-ChildItem -Path $tempdir -Recurse -Include containerd.exe, containerd-shim-runhcs-v1.exe, ctr.exe | Copy-Item -Destination $global:ContainerdInstallLocation -Force
+
+    # remove temp folder created when unzipping
+    del $tempdir -Recurse
+
+    # the kubelet start script cleans up the containers with ctr.exe
+    $machinePath = [System.Environment]::GetEnvironmentVariable("Path", [System.EnvironmentVariableTarget]::Machine)
+    if (-not $machinePath.Contains($global:ContainerdInstallLocation)) {
+        [System.Environment]::SetEnvironmentVariable("Path", "$machinePath;$global:ContainerdInstallLocation", [System.EnvironmentVariableTarget]::Machine)
+    }
+    $env:Path += ";$global:ContainerdInstallLocation"
+}
+
+function Set-ContainerdConfig
+{
+    Param(
+        [Parameter(Mandatory=$true)][string]
+        $CNIBinDir,
+        [Parameter(Mandatory=$true)][string]
+        $CNIConfDir,
+        [Parameter(Mandatory=$true)][ValidateSet("process", "hyperv")][string]
+        $WindowsIsolation,
+        # multi-arch image running on all the Windows Server versions with process isolation
+        [string]
+        $PauseImage = "mcr.microsoft.com/oss/kubernetes/pause:1.4.0"
+    )
+
+    # Both runtime handlers are declared so that RuntimeClasses can pick either isolation, the pods
+    # of the agent pool not asking for one get the isolation of the pool.
+    $defaultRuntime = "runhcs-wcow-process"
+    if ($WindowsIsolation -eq "hyperv") {
+        $defaultRuntime = "runhcs-wcow-hypervisor"
+    }
+
+    # backslashes are escaped in TOML strings
+    $config = @"
+version = 2
+root = "C:\\ProgramData\\containerd\\root"
+state = "C:\\ProgramData\\containerd\\state"
+
+[plugins]
+  [plugins."io.containerd.grpc.v1.cri"]
+    sandbox_image = "$PauseImage"
+    [plugins."io.containerd.grpc.v1.cri".containerd]
+      snapshotter = "windows"
+      default_runtime_name = "$defaultRuntime"
+      [plugins."io.containerd.grpc.v1.cri".containerd.runtimes]
+        [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-process]
+          runtime_type = "io.containerd.runhcs.v1"
+        [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-hypervisor]
+          runtime_type = "io.containerd.runhcs.v1"
+          [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runhcs-wcow-hypervisor.options]
+            SandboxIsolation = 1
+    [plugins."io.containerd.grpc.v1.cri".cni]
+      bin_dir = "$($CNIBinDir.Replace("\", "\\"))"
+      conf_dir = "$($CNIConfDir.Replace("\", "\\"))"
+"@
+
+    $configFile = [Io.path]::Combine($global:ContainerdInstallLocation, "config.toml")
+    $config | Out-File -encoding ASCII -filepath $configFile
+}
+
+function Start-ContainerdService
+{
+    $configFile = [Io.path]::Combine($global:ContainerdInstallLocation, "config.toml")
+    & "$global:ContainerdInstallLocation\containerd.exe" --register-service --config $configFile
+    Set-Service -Name containerd -StartupType Automatic
+    Start-Service containerd
+}
+
+function Enable-HyperV
+{
+    # pods isolated with Hyper-V run in utility VMs, the VM size of the agent pool must support nested
+    # virtualization. The feature is enabled by the reboot ending the provisioning.
+    Install-WindowsFeature -Name Hyper-V
+}
+`)
+
+func k8sWindowscontainerdfuncPs1Bytes() ([]byte, error) {
+	return _k8sWindowscontainerdfuncPs1, nil
+}
+
+func k8sWindowscontainerdfuncPs1() (*asset, error) {
+	bytes, err := k8sWindowscontainerdfuncPs1Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "k8s/windowscontainerdfunc.ps1", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _k8sWindowsinstallopensshfuncPs1 = []byte(`function
 Install-OpenSSH {
     Param(
@@ -24834,9 +25327,13 @@ Write-AzureConfig {
         [Parameter(Mandatory = $true)][string]
         $SecurityGroupName,
         [Parameter(Mandatory = $true)][string]
+        $SecurityGroupResourceGroup,
+        [Parameter(Mandatory = $true)][string]
         $VNetName,
         [Parameter(Mandatory = $true)][string]
         $RouteTableName,
+        [Parameter(Mandatory = $true)][string]
+        $RouteTableResourceGroup,
         [Parameter(Mandatory = $false)][string] # Need one of these configured
         $PrimaryAvailabilitySetName,
         [Parameter(Mandatory = $false)][string] # Need one of these configured
@@ -24850,6 +25347,8 @@ Write-AzureConfig {
         [Parameter(Mandatory = $true)][string]
         $LoadBalancerSku,
         [Parameter(Mandatory = $true)][string]
+        $OutboundType,
+        [Parameter(Mandatory = $true)][string]
         $ExcludeMasterFromStandardLB,
         [Parameter(Mandatory = $true)][string]
         $KubeDir,
@@ -24875,14 +25374,17 @@ Write-AzureConfig {
     "vmType": "$VmType",
     "subnetName": "$SubnetName",
     "securityGroupName": "$SecurityGroupName",
+    "securityGroupResourceGroup": "$SecurityGroupResourceGroup",
     "vnetName": "$VNetName",
     "routeTableName": "$RouteTableName",
+    "routeTableResourceGroup": "$RouteTableResourceGroup",
     "primaryAvailabilitySetName": "$PrimaryAvailabilitySetName",
     "primaryScaleSetName": "$PrimaryScaleSetName",
     "useManagedIdentityExtension": $UseManagedIdentityExtension,
     "userAssignedIdentityID": $UserAssignedClientID,
     "useInstanceMetadata": $UseInstanceMetadata,
     "loadBalancerSku": "$LoadBalancerSku",
+    "outboundType": "$OutboundType",
     "excludeMasterFromStandardLB": $ExcludeMasterFromStandardLB
 }
 "@
@@ -25051,7 +25553,10 @@ New-NSSMService {
         $KubeletStartFile,
         [string]
         [Parameter(Mandatory = $true)]
-        $KubeProxyStartFile
+        $KubeProxyStartFile,
+        # the service of the container runtime is named after it
+        [string]
+        $ContainerRuntime = "docker"
     )
 
     # setup kubelet
@@ -25060,7 +25565,7 @@ New-NSSMService {
     & "$KubeDir\nssm.exe" set Kubelet AppParameters $KubeletStartFile
     & "$KubeDir\nssm.exe" set Kubelet DisplayName Kubelet
     & "$KubeDir\nssm.exe" set Kubelet AppRestartDelay 5000
-    & "$KubeDir\nssm.exe" set Kubelet DependOnService docker
+    & "$KubeDir\nssm.exe" set Kubelet DependOnService $ContainerRuntime
     & "$KubeDir\nssm.exe" set Kubelet Description Kubelet
     & "$KubeDir\nssm.exe" set Kubelet Start SERVICE_AUTO_START
     & "$KubeDir\nssm.exe" set Kubelet ObjectName LocalSystem
@@ -25131,7 +25636,9 @@ Install-KubernetesServices {
         [Parameter(Mandatory = $true)][string]
         $HNSModule,
         [Parameter(Mandatory = $true)][string]
-        $KubeletNodeLabels
+        $KubeletNodeLabels,
+        [string]
+        $ContainerRuntime = "docker"
     )
 
     # Calculate some local paths
@@ -25169,6 +25676,19 @@ Install-KubernetesServices {
         throw "Unknown network type $NetworkPlugin, can't configure kubelet"
     }
 
+    # containerd gets the CNI plugins from its own configuration, written by Set-ContainerdConfig
+    if ($ContainerRuntime -eq "containerd") {
+        $KubeletArgList += @("--container-runtime=remote", "--runtime-request-timeout=15m", "--container-runtime-endpoint=npipe:////./pipe/containerd-containerd")
+    }
+
+    # Containers left over by a restart are removed with the CLI of the container runtime
+    if ($ContainerRuntime -eq "containerd") {
+        $CleanupContainers = "ctr.exe -n k8s.io containers ls -q | foreach {ctr.exe -n k8s.io tasks rm -f ` + "`" + `$_; ctr.exe -n k8s.io containers rm ` + "`" + `$_}"
+    }
+    else {
+        $CleanupContainers = "docker ps -q | foreach {docker rm ` + "`" + `$_ -f}"
+    }
+
     # Used in WinCNI version of kubeletstart.ps1
     $KubeletArgListStr = ""
     $KubeletArgList | Foreach-Object {
@@ -25230,7 +25750,7 @@ if (!` + "`" + `$hnsNetwork)
 if (` + "`" + `$hnsNetwork)
 {
     # Cleanup all containers
-    docker ps -q | foreach {docker rm ` + "`" + `$_ -f}
+    $CleanupContainers
 
     Write-Host "Cleaning up old HNS network found"
     Remove-HnsNetwork ` + "`" + `$hnsNetwork
@@ -25376,7 +25896,7 @@ try
     {
         # Kubelet has been restarted with existing network.
         # Cleanup all containers
-        docker ps -q | foreach {docker rm ` + "`" + `$_ -f}
+        $CleanupContainers
         # cleanup network
         Write-Host "Cleaning up old HNS network found"
         Remove-HnsNetwork ` + "`" + `$hnsNetwork
@@ -25432,7 +25952,8 @@ $KubeDir\kube-proxy.exe --v=3 --proxy-mode=kernelspace --hostname-override=$env:
 
     New-NSSMService -KubeDir $KubeDir ` + "`" + `
         -KubeletStartFile $KubeletStartFile ` + "`" + `
-        -KubeProxyStartFile $KubeProxyStartFile
+        -KubeProxyStartFile $KubeProxyStartFile ` + "`" + `
+        -ContainerRuntime $ContainerRuntime
 }
 `)
 
@@ -28716,6 +29237,22 @@ var _windowsparamsT = []byte(` {{if IsKubernetes}}
       },
       "type": "string"
     },
+  {{if NeedsContainerd}}
+    "windowsContainerdURL": {
+      "metadata": {
+        "description": "The download url for the containerd binaries of windows nodes"
+      },
+      "type": "string"
+    },
+  {{end}}
+  {{if HasWindowsDomainJoin}}
+    "windowsDomainJoinPassword": {
+      "metadata": {
+        "description": "Password of the account joining the windows nodes to the Active Directory domain."
+      },
+      "type": "securestring"
+    },
+  {{end}}
  {{end}}
     "windowsAdminUsername": {
       "type": "string",
@@ -28974,6 +29511,7 @@ var _bindata = map[string]func() (*asset, error){
 	"k8s/containeraddons/kubernetesmasteraddons-blobfuse-flexvolume-installer.yaml":        k8sContaineraddonsKubernetesmasteraddonsBlobfuseFlexvolumeInstallerYaml,
 	"k8s/containeraddons/kubernetesmasteraddons-calico-daemonset.yaml":                     k8sContaineraddonsKubernetesmasteraddonsCalicoDaemonsetYaml,
 	"k8s/containeraddons/kubernetesmasteraddons-cluster-autoscaler-deployment.yaml":        k8sContaineraddonsKubernetesmasteraddonsClusterAutoscalerDeploymentYaml,
+	"k8s/containeraddons/kubernetesmasteraddons-gmsa-webhook-deployment.yaml":              k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYaml,
 	"k8s/containeraddons/kubernetesmasteraddons-heapster-deployment.yaml":                  k8sContaineraddonsKubernetesmasteraddonsHeapsterDeploymentYaml,
 	"k8s/containeraddons/kubernetesmasteraddons-keyvault-flexvolume-installer.yaml":        k8sContaineraddonsKubernetesmasteraddonsKeyvaultFlexvolumeInstallerYaml,
 	"k8s/containeraddons/kubernetesmasteraddons-kube-rescheduler-deployment.yaml":          k8sContaineraddonsKubernetesmasteraddonsKubeReschedulerDeploymentYaml,
@@ -28996,6 +29534,7 @@ var _bindata = map[string]func() (*asset, error){
 	"k8s/windowsazurecnifunc.ps1":                                        k8sWindowsazurecnifuncPs1,
 	"k8s/windowscnifunc.ps1":                                             k8sWindowscnifuncPs1,
 	"k8s/windowsconfigfunc.ps1":                                          k8sWindowsconfigfuncPs1,
+	"k8s/windowscontainerdfunc.ps1":                                      k8sWindowscontainerdfuncPs1,
 	"k8s/windowsinstallopensshfunc.ps1":                                  k8sWindowsinstallopensshfuncPs1,
 	"k8s/windowskubeletfunc.ps1":                                         k8sWindowskubeletfuncPs1,
 	"masteroutputs.t":                                                    masteroutputsT,
@@ -29020,11 +29559,13 @@ var _bindata = map[string]func() (*asset, error){
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"}
 // AssetDir("data/img") would return []string{"a.png", "b.png"}
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error
@@ -29225,6 +29766,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 			"kubernetesmasteraddons-blobfuse-flexvolume-installer.yaml":   {k8sContaineraddonsKubernetesmasteraddonsBlobfuseFlexvolumeInstallerYaml, map[string]*bintree{}},
 			"kubernetesmasteraddons-calico-daemonset.yaml":                {k8sContaineraddonsKubernetesmasteraddonsCalicoDaemonsetYaml, map[string]*bintree{}},
 			"kubernetesmasteraddons-cluster-autoscaler-deployment.yaml":   {k8sContaineraddonsKubernetesmasteraddonsClusterAutoscalerDeploymentYaml, map[string]*bintree{}},
+			"kubernetesmasteraddons-gmsa-webhook-deployment.yaml":         {k8sContaineraddonsKubernetesmasteraddonsGmsaWebhookDeploymentYaml, map[string]*bintree{}},
 			"kubernetesmasteraddons-heapster-deployment.yaml":             {k8sContaineraddonsKubernetesmasteraddonsHeapsterDeploymentYaml, map[string]*bintree{}},
 			"kubernetesmasteraddons-keyvault-flexvolume-installer.yaml":   {k8sContaineraddonsKubernetesmasteraddonsKeyvaultFlexvolumeInstallerYaml, map[string]*bintree{}},
 			"kubernetesmasteraddons-kube-rescheduler-deployment.yaml":     {k8sContaineraddonsKubernetesmasteraddonsKubeReschedulerDeploymentYaml, map[string]*bintree{}},
@@ -29250,6 +29792,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 		"windowsazurecnifunc.ps1":       {k8sWindowsazurecnifuncPs1, map[string]*bintree{}},
 		"windowscnifunc.ps1":            {k8sWindowscnifuncPs1, map[string]*bintree{}},
 		"windowsconfigfunc.ps1":         {k8sWindowsconfigfuncPs1, map[string]*bintree{}},
+		"windowscontainerdfunc.ps1":     {k8sWindowscontainerdfuncPs1, map[string]*bintree{}},
 		"windowsinstallopensshfunc.ps1": {k8sWindowsinstallopensshfuncPs1, map[string]*bintree{}},
 		"windowskubeletfunc.ps1":        {k8sWindowskubeletfuncPs1, map[string]*bintree{}},
 	}},
@@ -0,0 +1,643 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TerraformConfigFileName is the name of the Terraform configuration written by generate --output-format terraform
+	TerraformConfigFileName = "main.tf.json"
+	// terraformAzureRMProviderVersion is the version constraint of the azurerm provider targeted by the Terraform backend
+	terraformAzureRMProviderVersion = "~> 2.40"
+	// terraformTemplateDeploymentType wraps ARM resources that have no azurerm_* equivalent mapping
+	terraformTemplateDeploymentType = "azurerm_resource_group_template_deployment"
+)
+
+// terraformRefPrefix marks a placeholder for an attribute of another resource, which is
+// rewritten into a Terraform reference once every resource has been mapped.
+const terraformRefPrefix = "\x00terraform-ref\x00"
+
+var (
+	terraformInvalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+	terraformRefPattern       = regexp.MustCompile("\x00terraform-ref\x00([^\x00]*)\x00([^\x00]*)\x00")
+)
+
+// TerraformConfig is a Terraform configuration in the JSON configuration syntax.
+type TerraformConfig struct {
+	Terraform map[string]interface{}            `json:"terraform"`
+	Provider  map[string]interface{}            `json:"provider"`
+	Resource  map[string]map[string]interface{} `json:"resource,omitempty"`
+	Output    map[string]interface{}            `json:"output,omitempty"`
+}
+
+// GenerateTerraformConfig generates a Terraform configuration that deploys the same resources as the template
+// of GenerateTemplateV2. The typed ARM resources are mapped to native azurerm_* resources, with ARM variables,
+// parameters and copy loops resolved to concrete values for the deployment scope described by env.
+// It returns the configuration and warnings about the parts of the template that could not be mapped faithfully.
+func (t *TemplateGenerator) GenerateTerraformConfig(containerService *api.ContainerService, generatorCode string, aksEngineVersion string, env armeval.Environment) (configRaw string, warnings []string, err error) {
+	if !containerService.Properties.OrchestratorProfile.IsKubernetes() {
+		return "", nil, errors.Errorf("Terraform configurations can only be generated for %s, not %s", api.Kubernetes, containerService.Properties.OrchestratorProfile.OrchestratorType)
+	}
+	armParams := GetKubernetesParameters(containerService)
+	armVariables, err := GetKubernetesVariables(containerService)
+	if err != nil {
+		return "", nil, err
+	}
+	armTemplate := ARMTemplate{
+		Parameters: armParams,
+		Variables:  armVariables,
+		Outputs:    GetKubernetesOutputs(containerService),
+	}
+
+	var template, parameterValues map[string]interface{}
+	if err = decodeARMValue(armTemplate, &template); err != nil {
+		return "", nil, errors.Wrap(err, "decoding template")
+	}
+	if err = decodeARMValue(getParameters(containerService, generatorCode, aksEngineVersion), &parameterValues); err != nil {
+		return "", nil, errors.Wrap(err, "decoding template parameters")
+	}
+	evaluator, err := armeval.NewEvaluator(template, parameterValues, env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	g := newTerraformGenerator(env, evaluator)
+	for _, resource := range GenerateARMResources(containerService) {
+		if err = g.addARMResource(resource); err != nil {
+			return "", nil, err
+		}
+	}
+	if outputs, ok := template["outputs"].(map[string]interface{}); ok {
+		if err = g.addOutputs(outputs); err != nil {
+			return "", nil, err
+		}
+	}
+
+	config := g.finalize()
+	b, err := helpers.JSONMarshalIndent(config, "", "  ", false)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(b), g.warnings, nil
+}
+
+// terraformGenerator accumulates the Terraform resources mapped from ARM resources.
+type terraformGenerator struct {
+	env       armeval.Environment
+	evaluator *armeval.Evaluator
+	config    *TerraformConfig
+	// addresses maps lowercase ARM resource IDs, including sub-resources such as subnets, to Terraform addresses.
+	addresses map[string]string
+	// groups maps lowercase ARM resource IDs to all the Terraform addresses a resource was mapped to.
+	groups map[string][]string
+	// names maps lowercase ARM resource names to their resource IDs, for dependencies given by name only.
+	names map[string][]string
+	// dependencies holds the ARM dependencies of each Terraform address.
+	dependencies map[string][]string
+	// sizeMap holds the default storage account types of VM sizes.
+	sizeMap  map[string]interface{}
+	warnings []string
+}
+
+// terraformSource is an ARM resource, or one iteration of a copy loop, with its expressions evaluated.
+type terraformSource struct {
+	raw       map[string]interface{}
+	name      string
+	armType   string
+	id        string
+	dependsOn []string
+}
+
+func newTerraformGenerator(env armeval.Environment, evaluator *armeval.Evaluator) *terraformGenerator {
+	g := &terraformGenerator{
+		env:       env,
+		evaluator: evaluator,
+		config: &TerraformConfig{
+			Terraform: map[string]interface{}{
+				"required_providers": map[string]interface{}{
+					"azurerm": map[string]interface{}{
+						"source":  "hashicorp/azurerm",
+						"version": terraformAzureRMProviderVersion,
+					},
+				},
+			},
+			Provider: map[string]interface{}{
+				"azurerm": map[string]interface{}{
+					"features":        map[string]interface{}{},
+					"subscription_id": env.SubscriptionID,
+					"tenant_id":       env.TenantID,
+				},
+			},
+			Resource: map[string]map[string]interface{}{},
+			Output:   map[string]interface{}{},
+		},
+		addresses:    map[string]string{},
+		groups:       map[string][]string{},
+		names:        map[string][]string{},
+		dependencies: map[string][]string{},
+	}
+	evaluator.SetReferenceResolver(g.resolveReference)
+	return g
+}
+
+// decodeARMValue converts v to the type of out through its ARM JSON representation,
+// so that custom marshalers of the typed ARM resources apply.
+func decodeARMValue(v interface{}, out interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func (g *terraformGenerator) warnf(format string, args ...interface{}) {
+	g.warnings = append(g.warnings, fmt.Sprintf(format, args...))
+}
+
+// addARMResource evaluates an ARM resource, expanding its copy loop, and maps every instance to Terraform.
+func (g *terraformGenerator) addARMResource(resource interface{}) error {
+	var raw map[string]interface{}
+	if err := decodeARMValue(resource, &raw); err != nil {
+		return errors.Wrap(err, "decoding ARM resource")
+	}
+	armType, _ := raw["type"].(string)
+	if armType == "" {
+		return nil
+	}
+
+	count, loop := 1, ""
+	if c, ok := raw["copy"].(map[string]interface{}); ok {
+		loop, _ = c["name"].(string)
+		n, err := g.evaluator.Evaluate(c["count"])
+		if err != nil {
+			return errors.Wrapf(err, "evaluating the copy count of %s %v", armType, raw["name"])
+		}
+		i, ok := n.(int64)
+		if !ok {
+			return errors.Errorf("the copy count of %s %v must be an integer, got %v", armType, raw["name"], n)
+		}
+		count = int(i)
+	}
+	delete(raw, "copy")
+
+	for i := 0; i < count; i++ {
+		e := g.evaluator
+		if loop != "" {
+			e = e.WithCopyIndex(loop, i)
+		}
+		evaluated, unresolved, err := e.EvaluatePartial(raw)
+		if err != nil {
+			return errors.Wrapf(err, "evaluating %s %v", armType, raw["name"])
+		}
+		src := &terraformSource{raw: evaluated.(map[string]interface{}), armType: armType}
+		src.name, _ = src.raw["name"].(string)
+		if deps, ok := src.raw["dependsOn"].([]interface{}); ok {
+			for _, d := range deps {
+				if s, ok := d.(string); ok && !armeval.IsExpression(s) {
+					src.dependsOn = append(src.dependsOn, s)
+				}
+			}
+		}
+		if !armeval.IsExpression(src.name) {
+			src.id = g.resourceID(armType, src.name)
+		}
+		for _, u := range unresolved {
+			if !g.isHandledUnresolvable(resource, u) {
+				g.warnf("%s %s: expression %s cannot be resolved offline: %s", armType, src.name, abbreviate(u.Expression), u.Reason)
+			}
+		}
+		if err := g.mapResource(resource, src); err != nil {
+			return errors.Wrapf(err, "mapping %s %s to Terraform", armType, src.name)
+		}
+	}
+	return nil
+}
+
+// isHandledUnresolvable returns true for unresolvable expressions that the Terraform mapping doesn't need,
+// such as the generated names of role assignments, which Terraform generates itself.
+func (g *terraformGenerator) isHandledUnresolvable(resource interface{}, u *armeval.UnresolvableError) bool {
+	switch resource.(type) {
+	case RoleAssignmentARM, SystemRoleAssignmentARM:
+		return strings.Contains(u.Expression, "guid(")
+	}
+	return false
+}
+
+// resourceID returns the ID of a resource of the target resource group, or "" if it has no regular ID.
+func (g *terraformGenerator) resourceID(armType, name string) string {
+	id, err := armeval.ResourceID(g.env.SubscriptionID, g.env.ResourceGroupName, armType, strings.Split(name, "/")...)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// qualifyResourceID converts a "type/name" pair, as found in dependsOn and reference(), into a resource ID.
+// Bare names are returned unchanged.
+func (g *terraformGenerator) qualifyResourceID(s string) string {
+	if strings.HasPrefix(s, "/subscriptions/") || !strings.Contains(s, "/") {
+		return s
+	}
+	segments := strings.Split(strings.Trim(s, "/"), "/")
+	if len(segments) < 3 || len(segments)%2 == 0 {
+		return s
+	}
+	var types, names []string
+	types = append(types, segments[0])
+	for i := 1; i < len(segments); i += 2 {
+		types = append(types, segments[i])
+		names = append(names, segments[i+1])
+	}
+	if id := g.resourceID(strings.Join(types, "/"), strings.Join(names, "/")); id != "" {
+		return id
+	}
+	return s
+}
+
+// lookupID returns the registered resource ID matching a resource ID, "type/name" pair or bare name.
+func (g *terraformGenerator) lookupID(s string) (string, bool) {
+	id := strings.ToLower(g.qualifyResourceID(s))
+	if _, ok := g.groups[id]; ok {
+		return id, true
+	}
+	if _, ok := g.addresses[id]; ok {
+		return id, true
+	}
+	if ids := g.names[strings.ToLower(s)]; len(ids) == 1 {
+		return ids[0], true
+	}
+	return "", false
+}
+
+// terraformRef returns a placeholder for the attribute of the resource with the given ARM ID.
+func terraformRef(id, attribute string) string {
+	return terraformRefPrefix + id + "\x00" + attribute + "\x00"
+}
+
+// ref returns a placeholder for the ID of an ARM resource. It becomes a Terraform reference if the resource
+// is part of the configuration, and the concrete resource ID otherwise.
+func ref(id string) string {
+	if id == "" || armeval.IsExpression(id) {
+		return id
+	}
+	return terraformRef(id, "id")
+}
+
+// resolveReference converts a reference() to another resource of the template into a Terraform reference.
+func (g *terraformGenerator) resolveReference(r armeval.Reference) (interface{}, error) {
+	path := strings.Join(r.Path, ".")
+	var attribute string
+	switch strings.ToLower(path) {
+	case "identity.principalid":
+		attribute = "identity[0].principal_id"
+	case "principalid":
+		attribute = "principal_id"
+	case "clientid":
+		attribute = "client_id"
+	case "dnssettings.fqdn":
+		attribute = "fqdn"
+	case "ipaddress":
+		attribute = "ip_address"
+	default:
+		return nil, &armeval.UnresolvableError{Reason: fmt.Sprintf("property %s of reference(%s) has no Terraform equivalent", path, r.ResourceID)}
+	}
+	return terraformRef(g.qualifyResourceID(r.ResourceID), attribute), nil
+}
+
+// localName returns a unique Terraform resource name of tfType derived from name.
+func (g *terraformGenerator) localName(tfType, name string) string {
+	if name == "" || armeval.IsExpression(name) {
+		name = strings.TrimPrefix(tfType, "azurerm_")
+	}
+	local := terraformInvalidNameChars.ReplaceAllString(name, "_")
+	if c := local[0]; !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+		local = "_" + local
+	}
+	resources := g.config.Resource[tfType]
+	if _, taken := resources[local]; !taken {
+		return local
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", local, i)
+		if _, taken := resources[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// addResource adds a Terraform resource mapped from src and returns its address. armID is the ID of the
+// ARM resource or sub-resource the Terraform resource manages, if any.
+func (g *terraformGenerator) addResource(src *terraformSource, tfType, name, armID string, block map[string]interface{}) string {
+	local := g.localName(tfType, name)
+	if g.config.Resource[tfType] == nil {
+		g.config.Resource[tfType] = map[string]interface{}{}
+	}
+	g.config.Resource[tfType][local] = block
+	address := tfType + "." + local
+
+	if armID != "" {
+		g.addresses[strings.ToLower(armID)] = address
+	}
+	if src.id != "" {
+		key := strings.ToLower(src.id)
+		if len(g.groups[key]) == 0 {
+			g.names[strings.ToLower(src.name)] = append(g.names[strings.ToLower(src.name)], key)
+		}
+		g.groups[key] = append(g.groups[key], address)
+	}
+	g.dependencies[address] = src.dependsOn
+	return address
+}
+
+// mapResource dispatches a typed ARM resource to its Terraform mapping.
+func (g *terraformGenerator) mapResource(resource interface{}, src *terraformSource) error {
+	switch resource.(type) {
+	case VirtualNetworkARM, VirtualNetworkNATGatewayARM:
+		return g.mapVirtualNetwork(src)
+	case NetworkSecurityGroupARM:
+		return g.mapNetworkSecurityGroup(src)
+	case RouteTableARM:
+		return g.mapRouteTable(src)
+	case PublicIPAddressARM:
+		return g.mapPublicIPAddress(src)
+	case LoadBalancerARM:
+		return g.mapLoadBalancer(src)
+	case NetworkInterfaceARM:
+		return g.mapNetworkInterface(src)
+	case NATGatewayARM:
+		return g.mapNATGateway(src)
+	case PrivateDNSZoneARM:
+		return g.mapPrivateDNSZone(src)
+	case PrivateDNSZoneRecordSetARM:
+		return g.mapPrivateDNSZoneRecordSet(src)
+	case PrivateDNSZoneVirtualNetworkLinkARM:
+		return g.mapPrivateDNSZoneVirtualNetworkLink(src)
+	case AvailabilitySetARM:
+		return g.mapAvailabilitySet(src)
+	case VirtualMachineARM:
+		return g.mapVirtualMachine(src)
+	case VirtualMachineExtensionARM:
+		return g.mapVirtualMachineExtension(src)
+	case VirtualMachineScaleSetARM:
+		return g.mapVirtualMachineScaleSet(src)
+	case StorageAccountARM:
+		return g.mapStorageAccount(src)
+	case UserAssignedIdentitiesARM:
+		return g.mapUserAssignedIdentity(src)
+	case RoleAssignmentARM, SystemRoleAssignmentARM:
+		return g.mapRoleAssignment(src)
+	default:
+		return g.mapTemplateDeployment(src)
+	}
+}
+
+// mapTemplateDeployment wraps an ARM resource without an azurerm_* mapping in a template deployment of its own.
+func (g *terraformGenerator) mapTemplateDeployment(src *terraformSource) error {
+	resource := map[string]interface{}{}
+	for k, v := range src.raw {
+		if k != "dependsOn" {
+			resource[k] = v
+		}
+	}
+	template := map[string]interface{}{
+		"$schema":        "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+		"contentVersion": "1.0.0.0",
+		"resources":      []interface{}{resource},
+	}
+	content, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	g.warnf("%s %s has no azurerm_* mapping and is deployed with %s", src.armType, src.name, terraformTemplateDeploymentType)
+	g.addResource(src, terraformTemplateDeploymentType, src.name, src.id, map[string]interface{}{
+		"name":                src.name,
+		"resource_group_name": g.env.ResourceGroupName,
+		"deployment_mode":     "Incremental",
+		"template_content":    string(content),
+	})
+	return nil
+}
+
+// addOutputs maps the ARM template outputs to Terraform outputs.
+func (g *terraformGenerator) addOutputs(outputs map[string]interface{}) error {
+	for name, o := range outputs {
+		output, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, unresolved, err := g.evaluator.EvaluatePartial(output["value"])
+		if err != nil {
+			return errors.Wrapf(err, "evaluating output %s", name)
+		}
+		if len(unresolved) > 0 {
+			g.warnf("output %s is omitted: %s", name, unresolved[0].Error())
+			continue
+		}
+		g.config.Output[name] = map[string]interface{}{"value": value}
+	}
+	return nil
+}
+
+// finalize resolves placeholders and dependencies into Terraform references and returns the configuration.
+func (g *terraformGenerator) finalize() *TerraformConfig {
+	for tfType, resources := range g.config.Resource {
+		for local, block := range resources {
+			address := tfType + "." + local
+			resolved := g.resolvePlaceholders(address, block).(map[string]interface{})
+			if deps := g.resolveDependencies(address); len(deps) > 0 {
+				resolved["depends_on"] = deps
+			}
+			resources[local] = resolved
+		}
+	}
+	for name, output := range g.config.Output {
+		g.config.Output[name] = g.resolvePlaceholders("output."+name, output)
+	}
+	if len(g.config.Output) == 0 {
+		g.config.Output = nil
+	}
+	sort.Strings(g.warnings)
+	return g.config
+}
+
+// resolvePlaceholders rewrites placeholders into Terraform references and escapes Terraform template
+// sequences in every other string, since strings of the JSON syntax are templates.
+func (g *terraformGenerator) resolvePlaceholders(address string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		var sb strings.Builder
+		last := 0
+		for _, m := range terraformRefPattern.FindAllStringSubmatchIndex(t, -1) {
+			sb.WriteString(escapeTerraformTemplate(t[last:m[0]]))
+			sb.WriteString(g.resolveRef(address, t[m[2]:m[3]], t[m[4]:m[5]]))
+			last = m[1]
+		}
+		sb.WriteString(escapeTerraformTemplate(t[last:]))
+		return sb.String()
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = g.resolvePlaceholders(address, item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = g.resolvePlaceholders(address, item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// resolveRef returns the Terraform interpolation of the attribute of the resource with the given ARM ID.
+// Resources outside of the configuration are referenced by their concrete ID, and other attributes of
+// them can't be resolved.
+func (g *terraformGenerator) resolveRef(address, id, attribute string) string {
+	if target, ok := g.addresses[strings.ToLower(id)]; ok {
+		return fmt.Sprintf("${%s.%s}", target, attribute)
+	}
+	if key, ok := g.lookupID(id); ok {
+		if targets := g.groups[key]; len(targets) > 0 {
+			return fmt.Sprintf("${%s.%s}", targets[0], attribute)
+		}
+	}
+	if attribute == "id" {
+		return escapeTerraformTemplate(id)
+	}
+	g.warnf("%s: %s of %s cannot be resolved", address, attribute, id)
+	return ""
+}
+
+// resolveDependencies returns the Terraform addresses of the ARM dependencies of address.
+func (g *terraformGenerator) resolveDependencies(address string) []string {
+	seen := map[string]bool{address: true}
+	var deps []string
+	for _, d := range g.dependencies[address] {
+		key, ok := g.lookupID(d)
+		if !ok {
+			continue
+		}
+		targets := g.groups[key]
+		if len(targets) == 0 {
+			targets = []string{g.addresses[key]}
+		}
+		for _, target := range targets {
+			if !seen[target] {
+				seen[target] = true
+				deps = append(deps, target)
+			}
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// abbreviate shortens long expressions, such as the command lines of extensions, to a single line for warnings.
+func abbreviate(s string) string {
+	const max = 120
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// escapeTerraformTemplate escapes the "${" and "%{" template sequences of a literal string.
+func escapeTerraformTemplate(s string) string {
+	s = strings.Replace(s, "${", "$${", -1)
+	return strings.Replace(s, "%{", "%%{", -1)
+}
+
+// armValue returns the value at path in an evaluated ARM resource, or nil if there is none.
+func armValue(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// armString returns the string at path in an evaluated ARM resource, or "" if there is none.
+func armString(v interface{}, path ...string) string {
+	s, _ := armValue(v, path...).(string)
+	return s
+}
+
+// armSlice returns the array at path in an evaluated ARM resource.
+func armSlice(v interface{}, path ...string) []interface{} {
+	s, _ := armValue(v, path...).([]interface{})
+	return s
+}
+
+// armObject returns the object at path in an evaluated ARM resource.
+func armObject(v interface{}, path ...string) map[string]interface{} {
+	m, _ := armValue(v, path...).(map[string]interface{})
+	return m
+}
+
+// armLastSegment returns the last segment of a resource ID or name, which is the name of a child resource.
+func armLastSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// setAttributes copies the non-empty values at the given paths of v into the block of a Terraform resource.
+func setAttributes(block map[string]interface{}, v interface{}, attributes map[string][]string) {
+	for attribute, path := range attributes {
+		switch value := armValue(v, path...).(type) {
+		case nil:
+		case string:
+			if value != "" {
+				block[attribute] = value
+			}
+		case []interface{}:
+			if len(value) > 0 {
+				block[attribute] = value
+			}
+		default:
+			block[attribute] = value
+		}
+	}
+}
+
+// baseBlock returns the attributes shared by the top-level Terraform resources mapped from src.
+func (g *terraformGenerator) baseBlock(src *terraformSource) map[string]interface{} {
+	block := map[string]interface{}{
+		"name":                src.name,
+		"resource_group_name": g.env.ResourceGroupName,
+	}
+	if location := armString(src.raw, "location"); location != "" {
+		block["location"] = location
+	}
+	if tags := armObject(src.raw, "tags"); len(tags) > 0 {
+		block["tags"] = tags
+	}
+	return block
+}
+
+// refs returns the placeholders of the IDs of a list of ARM sub-resource references.
+func refs(subResources []interface{}) []interface{} {
+	var ids []interface{}
+	for _, s := range subResources {
+		if id := armString(s, "id"); id != "" {
+			ids = append(ids, ref(id))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,240 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/common"
+	"github.com/Azure/aks-engine/pkg/engine/armeval"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testTerraformEnvironment = armeval.Environment{
+	SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+	TenantID:              "11111111-1111-1111-1111-111111111111",
+	ResourceGroupName:     "myrg",
+	ResourceGroupLocation: "westus2",
+}
+
+func TestGenerateTerraformConfig(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", common.RationalizeReleaseAndVersion(api.Kubernetes, "", "", false, false), 1, 2, false)
+	cs.Location = "westus2"
+	cs.Properties.OrchestratorProfile.KubernetesConfig.UseManagedIdentity = true
+	cs.Properties.OrchestratorProfile.KubernetesConfig.UserAssignedID = "myid"
+	for _, profile := range cs.Properties.AgentPoolProfiles {
+		profile.StorageProfile = api.ManagedDisks
+	}
+	if _, err := cs.SetPropertiesDefaults(false, false); err != nil {
+		t.Fatalf("unexpected error setting defaults: %s", err)
+	}
+
+	tg, err := InitializeTemplateGenerator(Context{})
+	if err != nil {
+		t.Fatalf("unexpected error initializing the template generator: %s", err)
+	}
+	configRaw, _, err := tg.GenerateTerraformConfig(cs, DefaultGeneratorCode, TestAKSEngineVersion, testTerraformEnvironment)
+	if err != nil {
+		t.Fatalf("unexpected error generating the Terraform configuration: %s", err)
+	}
+	if strings.Contains(configRaw, `\u0000`) {
+		t.Errorf("expected every placeholder to be resolved")
+	}
+
+	var config TerraformConfig
+	if err = json.Unmarshal([]byte(configRaw), &config); err != nil {
+		t.Fatalf("unexpected error decoding the Terraform configuration: %s", err)
+	}
+
+	for _, tfType := range []string{"azurerm_virtual_network", "azurerm_subnet", "azurerm_network_security_group", "azurerm_public_ip", "azurerm_lb", "azurerm_lb_rule",
+		"azurerm_network_interface", "azurerm_linux_virtual_machine", "azurerm_virtual_machine_extension", "azurerm_user_assigned_identity", "azurerm_role_assignment"} {
+		if len(config.Resource[tfType]) == 0 {
+			t.Errorf("expected the configuration to have %s resources", tfType)
+		}
+	}
+	if deployments, ok := config.Resource[terraformTemplateDeploymentType]; ok {
+		t.Errorf("expected every resource to be mapped to a native azurerm_* resource, got %v", deployments)
+	}
+
+	for name, r := range config.Resource["azurerm_linux_virtual_machine"] {
+		vm := r.(map[string]interface{})
+		if vm["resource_group_name"] != "myrg" || vm["location"] != "westus2" {
+			t.Errorf("expected VM %s to be deployed in myrg in westus2, got %v in %v", name, vm["resource_group_name"], vm["location"])
+		}
+		for _, id := range vm["network_interface_ids"].([]interface{}) {
+			if !strings.HasPrefix(id.(string), "${azurerm_network_interface.") {
+				t.Errorf("expected VM %s to reference its NIC, got %s", name, id)
+			}
+		}
+		if identity := armValue(vm, "identity", "identity_ids").([]interface{}); identity[0] != "${azurerm_user_assigned_identity.myid.id}" {
+			t.Errorf("expected VM %s to reference the user assigned identity, got %v", name, identity)
+		}
+	}
+
+	for _, r := range config.Resource["azurerm_role_assignment"] {
+		assignment := r.(map[string]interface{})
+		if assignment["principal_id"] != "${azurerm_user_assigned_identity.myid.principal_id}" {
+			t.Errorf("expected the role assignment to reference the principal of the user assigned identity, got %v", assignment["principal_id"])
+		}
+		if assignment["scope"] != "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myrg" {
+			t.Errorf("expected the role assignment to be scoped to the resource group, got %v", assignment["scope"])
+		}
+	}
+
+	for _, r := range config.Resource["azurerm_virtual_machine_extension"] {
+		extension := r.(map[string]interface{})
+		settings, _ := extension["protected_settings"].(string)
+		if strings.Contains(settings, "[concat(") {
+			t.Errorf("expected the settings of extension %v to be evaluated", extension["name"])
+		}
+		if strings.Contains(settings, "${@}") && !strings.Contains(settings, "$${@}") {
+			t.Errorf("expected the template sequences in the settings of extension %v to be escaped", extension["name"])
+		}
+	}
+}
+
+func TestGenerateTerraformConfigNotKubernetes(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "", 1, 2, false)
+	cs.Properties.OrchestratorProfile = &api.OrchestratorProfile{OrchestratorType: api.DCOS}
+
+	tg, err := InitializeTemplateGenerator(Context{})
+	if err != nil {
+		t.Fatalf("unexpected error initializing the template generator: %s", err)
+	}
+	_, _, err = tg.GenerateTerraformConfig(cs, DefaultGeneratorCode, TestAKSEngineVersion, testTerraformEnvironment)
+	if err == nil || err.Error() != "Terraform configurations can only be generated for Kubernetes, not DCOS" {
+		t.Errorf("expected an error generating a Terraform configuration for DCOS, got %v", err)
+	}
+}
+
+func TestTerraformResolvePlaceholders(t *testing.T) {
+	g := newTerraformGenerator(testTerraformEnvironment, &armeval.Evaluator{})
+	src := &terraformSource{
+		name: "vnet",
+		id:   "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/myrg/providers/Microsoft.Network/virtualNetworks/vnet",
+	}
+	g.addResource(src, "azurerm_virtual_network", "vnet", src.id, map[string]interface{}{})
+	g.addResource(src, "azurerm_subnet", "subnet", src.id+"/subnets/subnet", map[string]interface{}{})
+
+	otherID := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other/providers/Microsoft.Network/virtualNetworks/other"
+	cases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"resource", ref(src.id), "${azurerm_virtual_network.vnet.id}"},
+		{"sub-resource", ref(src.id + "/subnets/subnet"), "${azurerm_subnet.subnet.id}"},
+		{"case-insensitive ID", ref(strings.ToUpper(src.id)), "${azurerm_virtual_network.vnet.id}"},
+		{"type and name", terraformRef("Microsoft.Network/virtualNetworks/vnet", "name"), "${azurerm_virtual_network.vnet.name}"},
+		{"embedded", "ID=" + terraformRef(src.id, "name") + ";", "ID=${azurerm_virtual_network.vnet.name};"},
+		{"outside of the configuration", ref(otherID), otherID},
+		{"unknown attribute", terraformRef(otherID, "name"), ""},
+		{"template sequences", "${a} %{b}", "$${a} %%{b}"},
+	}
+	for _, c := range cases {
+		if actual := g.resolvePlaceholders("test", c.value); actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, actual)
+		}
+	}
+	if len(g.warnings) != 1 {
+		t.Errorf("expected a warning for the unresolvable attribute, got %v", g.warnings)
+	}
+}
+
+func TestTerraformDependencies(t *testing.T) {
+	g := newTerraformGenerator(testTerraformEnvironment, &armeval.Evaluator{})
+	nsg := &terraformSource{name: "nsg", id: g.resourceID("Microsoft.Network/networkSecurityGroups", "nsg")}
+	g.addResource(nsg, "azurerm_network_security_group", nsg.name, nsg.id, map[string]interface{}{})
+	vnet := &terraformSource{
+		name:      "vnet",
+		id:        g.resourceID("Microsoft.Network/virtualNetworks", "vnet"),
+		dependsOn: []string{"Microsoft.Network/networkSecurityGroups/nsg"},
+	}
+	g.addResource(vnet, "azurerm_virtual_network", vnet.name, vnet.id, map[string]interface{}{})
+	g.addResource(vnet, "azurerm_subnet", "subnet", vnet.id+"/subnets/subnet", map[string]interface{}{})
+	vm := &terraformSource{
+		name:      "vm",
+		id:        g.resourceID("Microsoft.Compute/virtualMachines", "vm"),
+		dependsOn: []string{vnet.id, "nsg", "Microsoft.Compute/availabilitySets/missing"},
+	}
+	g.addResource(vm, "azurerm_linux_virtual_machine", vm.name, vm.id, map[string]interface{}{})
+
+	expected := []string{"azurerm_network_security_group.nsg", "azurerm_subnet.subnet", "azurerm_virtual_network.vnet"}
+	if diff := cmp.Diff(expected, g.resolveDependencies("azurerm_linux_virtual_machine.vm")); diff != "" {
+		t.Errorf("unexpected dependencies (-want +got):\n%s", diff)
+	}
+}
+
+func TestTerraformLocalName(t *testing.T) {
+	g := newTerraformGenerator(testTerraformEnvironment, &armeval.Evaluator{})
+	src := &terraformSource{}
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"k8s-master-0", "k8s-master-0"},
+		{"k8s-master-0", "k8s-master-0_2"},
+		{"4132k8s000", "_4132k8s000"},
+		{"zone.private/@", "zone_private__"},
+		{"[guid(resourceGroup().id)]", "virtual_machine"},
+		{"", "virtual_machine_2"},
+	}
+	for _, c := range cases {
+		actual := g.localName("azurerm_virtual_machine", c.name)
+		if actual != c.expected {
+			t.Errorf("expected the local name of %q to be %q, got %q", c.name, c.expected, actual)
+		}
+		g.addResource(src, "azurerm_virtual_machine", c.name, "", map[string]interface{}{})
+	}
+}
+
+func TestTerraformTemplateDeploymentFallback(t *testing.T) {
+	e, err := armeval.NewEvaluator(map[string]interface{}{}, nil, testTerraformEnvironment)
+	if err != nil {
+		t.Fatalf("unexpected error creating the evaluator: %s", err)
+	}
+	g := newTerraformGenerator(testTerraformEnvironment, e)
+	image := ImageARM{
+		ARMResource: ARMResource{APIVersion: "2018-10-01"},
+	}
+	image.Name = to.StringPtr("[concat('image-', resourceGroup().name)]")
+	image.Type = to.StringPtr("Microsoft.Compute/images")
+	image.Location = to.StringPtr("[resourceGroup().location]")
+	if err = g.addARMResource(image); err != nil {
+		t.Fatalf("unexpected error mapping an image: %s", err)
+	}
+
+	deployment, ok := g.config.Resource[terraformTemplateDeploymentType]["image-myrg"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected the image to be deployed with %s, got %v", terraformTemplateDeploymentType, g.config.Resource)
+	}
+	var template map[string]interface{}
+	if err = json.Unmarshal([]byte(deployment["template_content"].(string)), &template); err != nil {
+		t.Fatalf("unexpected error decoding the template content: %s", err)
+	}
+	if location := armString(template["resources"].([]interface{})[0], "location"); location != "westus2" {
+		t.Errorf("expected the template content to be evaluated, got location %q", location)
+	}
+	if len(g.warnings) != 1 {
+		t.Errorf("expected a warning about the fallback, got %v", g.warnings)
+	}
+}
+
+func TestTerraformAttributeName(t *testing.T) {
+	cases := map[string]string{
+		"priority":                 "priority",
+		"sourceAddressPrefixes":    "source_address_prefixes",
+		"destinationPortRange":     "destination_port_range",
+		"enableAcceleratedNetwork": "enable_accelerated_network",
+	}
+	for property, expected := range cases {
+		if actual := terraformAttributeName(property); actual != expected {
+			t.Errorf("expected the attribute name of %s to be %s, got %s", property, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Terraform mappings of the Microsoft.Compute, Microsoft.Storage, Microsoft.ManagedIdentity
+// and Microsoft.Authorization resources of the template.
+
+func (g *terraformGenerator) mapAvailabilitySet(src *terraformSource) error {
+	availabilitySet := g.baseBlock(src)
+	setAttributes(availabilitySet, src.raw, map[string][]string{
+		"platform_fault_domain_count":  {"properties", "platformFaultDomainCount"},
+		"platform_update_domain_count": {"properties", "platformUpdateDomainCount"},
+	})
+	if id := armString(src.raw, "properties", "proximityPlacementGroup", "id"); id != "" {
+		availabilitySet["proximity_placement_group_id"] = ref(id)
+	}
+	availabilitySet["managed"] = strings.EqualFold(armString(src.raw, "sku", "name"), "Aligned")
+	g.addResource(src, "azurerm_availability_set", src.name, src.id, availabilitySet)
+	return nil
+}
+
+func (g *terraformGenerator) mapVirtualMachine(src *terraformSource) error {
+	if armValue(src.raw, "properties", "storageProfile", "osDisk", "vhd") != nil {
+		// VMs with unmanaged disks can't be created by the azurerm_*_virtual_machine resources
+		return g.mapTemplateDeployment(src)
+	}
+
+	vm := g.baseBlock(src)
+	properties := armObject(src.raw, "properties")
+	vmSize := armString(properties, "hardwareProfile", "vmSize")
+	vm["size"] = vmSize
+	setAttributes(vm, properties, map[string][]string{
+		"admin_username":  {"osProfile", "adminUsername"},
+		"computer_name":   {"osProfile", "computerName"},
+		"custom_data":     {"osProfile", "customData"},
+		"priority":        {"priority"},
+		"eviction_policy": {"evictionPolicy"},
+		"license_type":    {"licenseType"},
+	})
+	if zones := armSlice(src.raw, "zones"); len(zones) > 0 {
+		vm["zone"] = zones[0]
+	}
+	if id := armString(properties, "availabilitySet", "id"); id != "" {
+		vm["availability_set_id"] = ref(id)
+	}
+	vm["network_interface_ids"] = refs(armSlice(properties, "networkProfile", "networkInterfaces"))
+	g.setImage(vm, armObject(properties, "storageProfile", "imageReference"))
+	g.setPlan(vm, src.raw)
+	g.setIdentity(vm, src.raw)
+
+	osDisk := map[string]interface{}{
+		"storage_account_type": g.storageAccountType(armObject(properties, "storageProfile", "osDisk"), vmSize),
+	}
+	setAttributes(osDisk, properties, map[string][]string{
+		"name":         {"storageProfile", "osDisk", "name"},
+		"caching":      {"storageProfile", "osDisk", "caching"},
+		"disk_size_gb": {"storageProfile", "osDisk", "diskSizeGB"},
+	})
+	if _, ok := osDisk["caching"]; !ok {
+		osDisk["caching"] = "ReadWrite"
+	}
+	vm["os_disk"] = osDisk
+
+	tfType := "azurerm_linux_virtual_machine"
+	if windows := armObject(properties, "osProfile", "windowsConfiguration"); windows != nil {
+		tfType = "azurerm_windows_virtual_machine"
+		setAttributes(vm, properties, map[string][]string{
+			"admin_password":           {"osProfile", "adminPassword"},
+			"enable_automatic_updates": {"osProfile", "windowsConfiguration", "enableAutomaticUpdates"},
+			"timezone":                 {"osProfile", "windowsConfiguration", "timeZone"},
+		})
+	} else {
+		g.setLinuxConfiguration(vm, src, armObject(properties, "osProfile"))
+	}
+	g.addResource(src, tfType, src.name, src.id, vm)
+
+	for _, d := range armSlice(properties, "storageProfile", "dataDisks") {
+		name := armString(d, "name")
+		diskID := g.resourceID("Microsoft.Compute/disks", name)
+		disk := map[string]interface{}{
+			"name":                 name,
+			"resource_group_name":  g.env.ResourceGroupName,
+			"storage_account_type": g.storageAccountType(d, vmSize),
+			"create_option":        armString(d, "createOption"),
+		}
+		if location, ok := vm["location"]; ok {
+			disk["location"] = location
+		}
+		if zone, ok := vm["zone"]; ok {
+			disk["zones"] = []interface{}{zone}
+		}
+		setAttributes(disk, d, map[string][]string{
+			"disk_size_gb": {"diskSizeGB"},
+		})
+		g.addResource(src, "azurerm_managed_disk", name, diskID, disk)
+
+		attachment := map[string]interface{}{
+			"managed_disk_id":    ref(diskID),
+			"virtual_machine_id": ref(src.id),
+			"lun":                armValue(d, "lun"),
+			"caching":            "None",
+		}
+		setAttributes(attachment, d, map[string][]string{
+			"caching": {"caching"},
+		})
+		g.addResource(src, "azurerm_virtual_machine_data_disk_attachment", name, "", attachment)
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapVirtualMachineExtension(src *terraformSource) error {
+	vmName := strings.SplitN(src.name, "/", 2)[0]
+	extension := map[string]interface{}{
+		"name":               armLastSegment(src.name),
+		"virtual_machine_id": ref(g.resourceID("Microsoft.Compute/virtualMachines", vmName)),
+	}
+	if err := g.setExtension(extension, src.raw); err != nil {
+		return err
+	}
+	if tags := armObject(src.raw, "tags"); len(tags) > 0 {
+		extension["tags"] = tags
+	}
+	g.addResource(src, "azurerm_virtual_machine_extension", src.name, src.id, extension)
+	return nil
+}
+
+func (g *terraformGenerator) mapVirtualMachineScaleSet(src *terraformSource) error {
+	vmss := g.baseBlock(src)
+	profile := armObject(src.raw, "properties", "virtualMachineProfile")
+	vmSize := armString(src.raw, "sku", "name")
+	vmss["sku"] = vmSize
+	setAttributes(vmss, src.raw, map[string][]string{
+		"instances":                   {"sku", "capacity"},
+		"zones":                       {"zones"},
+		"overprovision":               {"properties", "overprovision"},
+		"single_placement_group":      {"properties", "singlePlacementGroup"},
+		"platform_fault_domain_count": {"properties", "platformFaultDomainCount"},
+		"upgrade_mode":                {"properties", "upgradePolicy", "mode"},
+	})
+	setAttributes(vmss, profile, map[string][]string{
+		"admin_username":       {"osProfile", "adminUsername"},
+		"computer_name_prefix": {"osProfile", "computerNamePrefix"},
+		"custom_data":          {"osProfile", "customData"},
+		"priority":             {"priority"},
+		"eviction_policy":      {"evictionPolicy"},
+		"max_bid_price":        {"billingProfile", "maxPrice"},
+		"license_type":         {"licenseType"},
+	})
+	g.setImage(vmss, armObject(profile, "storageProfile", "imageReference"))
+	g.setPlan(vmss, src.raw)
+	g.setIdentity(vmss, src.raw)
+
+	osDisk := map[string]interface{}{
+		"storage_account_type": g.storageAccountType(armObject(profile, "storageProfile", "osDisk"), vmSize),
+		"caching":              "ReadWrite",
+	}
+	setAttributes(osDisk, profile, map[string][]string{
+		"caching":      {"storageProfile", "osDisk", "caching"},
+		"disk_size_gb": {"storageProfile", "osDisk", "diskSizeGB"},
+	})
+	vmss["os_disk"] = osDisk
+
+	var dataDisks []interface{}
+	for _, d := range armSlice(profile, "storageProfile", "dataDisks") {
+		dataDisk := map[string]interface{}{
+			"lun":                  armValue(d, "lun"),
+			"caching":              "None",
+			"storage_account_type": g.storageAccountType(d, vmSize),
+		}
+		setAttributes(dataDisk, d, map[string][]string{
+			"caching":      {"caching"},
+			"disk_size_gb": {"diskSizeGB"},
+		})
+		dataDisks = append(dataDisks, dataDisk)
+	}
+	if len(dataDisks) > 0 {
+		vmss["data_disk"] = dataDisks
+	}
+
+	var nics []interface{}
+	for _, n := range armSlice(profile, "networkProfile", "networkInterfaceConfigurations") {
+		nic := map[string]interface{}{"name": armString(n, "name")}
+		setAttributes(nic, n, map[string][]string{
+			"primary":                       {"properties", "primary"},
+			"enable_accelerated_networking": {"properties", "enableAcceleratedNetworking"},
+			"enable_ip_forwarding":          {"properties", "enableIPForwarding"},
+		})
+		if id := armString(n, "properties", "networkSecurityGroup", "id"); id != "" {
+			nic["network_security_group_id"] = ref(id)
+		}
+		var ipConfigurations []interface{}
+		for _, c := range armSlice(n, "properties", "ipConfigurations") {
+			ipConfiguration := map[string]interface{}{"name": armString(c, "name")}
+			setAttributes(ipConfiguration, c, map[string][]string{
+				"primary": {"properties", "primary"},
+				"version": {"properties", "privateIPAddressVersion"},
+			})
+			if id := armString(c, "properties", "subnet", "id"); id != "" {
+				ipConfiguration["subnet_id"] = ref(id)
+			}
+			if ids := refs(armSlice(c, "properties", "loadBalancerBackendAddressPools")); len(ids) > 0 {
+				ipConfiguration["load_balancer_backend_address_pool_ids"] = ids
+			}
+			if ids := refs(armSlice(c, "properties", "loadBalancerInboundNatPools")); len(ids) > 0 {
+				ipConfiguration["load_balancer_inbound_nat_rules_ids"] = ids
+			}
+			if ids := refs(armSlice(c, "properties", "applicationGatewayBackendAddressPools")); len(ids) > 0 {
+				ipConfiguration["application_gateway_backend_address_pool_ids"] = ids
+			}
+			ipConfigurations = append(ipConfigurations, ipConfiguration)
+		}
+		nic["ip_configuration"] = ipConfigurations
+		nics = append(nics, nic)
+	}
+	vmss["network_interface"] = nics
+
+	tfType := "azurerm_linux_virtual_machine_scale_set"
+	if windows := armObject(profile, "osProfile", "windowsConfiguration"); windows != nil {
+		tfType = "azurerm_windows_virtual_machine_scale_set"
+		setAttributes(vmss, profile, map[string][]string{
+			"admin_password":           {"osProfile", "adminPassword"},
+			"enable_automatic_updates": {"osProfile", "windowsConfiguration", "enableAutomaticUpdates"},
+			"timezone":                 {"osProfile", "windowsConfiguration", "timeZone"},
+		})
+	} else {
+		g.setLinuxConfiguration(vmss, src, armObject(profile, "osProfile"))
+	}
+	g.addResource(src, tfType, src.name, src.id, vmss)
+
+	for _, e := range armSlice(profile, "extensionProfile", "extensions") {
+		extension := map[string]interface{}{
+			"name":                         armString(e, "name"),
+			"virtual_machine_scale_set_id": ref(src.id),
+		}
+		if err := g.setExtension(extension, e); err != nil {
+			return err
+		}
+		g.addResource(src, "azurerm_virtual_machine_scale_set_extension", src.name+"_"+armString(e, "name"), "", extension)
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapStorageAccount(src *terraformSource) error {
+	account := g.baseBlock(src)
+	sku := strings.SplitN(armString(src.raw, "sku", "name"), "_", 2)
+	if len(sku) != 2 {
+		return g.mapTemplateDeployment(src)
+	}
+	account["account_tier"] = sku[0]
+	account["account_replication_type"] = sku[1]
+	setAttributes(account, src.raw, map[string][]string{
+		"account_kind": {"kind"},
+	})
+	g.addResource(src, "azurerm_storage_account", src.name, src.id, account)
+	return nil
+}
+
+func (g *terraformGenerator) mapUserAssignedIdentity(src *terraformSource) error {
+	g.addResource(src, "azurerm_user_assigned_identity", src.name, src.id, g.baseBlock(src))
+	return nil
+}
+
+// mapRoleAssignment maps role assignments at the resource group scope and at the scope of a resource,
+// i.e. of the <resource type>/providers/roleAssignments type. Terraform generates their names.
+func (g *terraformGenerator) mapRoleAssignment(src *terraformSource) error {
+	scope := armString(src.raw, "properties", "scope")
+	if scope == "" {
+		if strings.Contains(strings.ToLower(src.armType), "/providers/") {
+			g.warnf("%s %s has no scope and is skipped", src.armType, src.name)
+			return nil
+		}
+		scope = g.resourceGroupID()
+	}
+	assignment := map[string]interface{}{
+		"scope":              ref(scope),
+		"role_definition_id": armString(src.raw, "properties", "roleDefinitionId"),
+		"principal_id":       armValue(src.raw, "properties", "principalId"),
+	}
+	g.addResource(src, "azurerm_role_assignment", src.name, "", assignment)
+	return nil
+}
+
+func (g *terraformGenerator) resourceGroupID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", g.env.SubscriptionID, g.env.ResourceGroupName)
+}
+
+// setLinuxConfiguration sets the SSH configuration of a Linux VM or scale set. Terraform only supports
+// SSH public keys in the home directory of the admin user.
+func (g *terraformGenerator) setLinuxConfiguration(block map[string]interface{}, src *terraformSource, osProfile map[string]interface{}) {
+	username := armString(osProfile, "adminUsername")
+	setAttributes(block, osProfile, map[string][]string{
+		"disable_password_authentication": {"linuxConfiguration", "disablePasswordAuthentication"},
+		"admin_password":                  {"adminPassword"},
+	})
+	var keys []interface{}
+	for _, k := range armSlice(osProfile, "linuxConfiguration", "ssh", "publicKeys") {
+		if path := armString(k, "path"); path != fmt.Sprintf("/home/%s/.ssh/authorized_keys", username) {
+			g.warnf("%s %s: the SSH public key is installed in the home directory of %s instead of %s", src.armType, src.name, username, path)
+		}
+		keys = append(keys, map[string]interface{}{
+			"username":   username,
+			"public_key": armValue(k, "keyData"),
+		})
+	}
+	if len(keys) > 0 {
+		block["admin_ssh_key"] = keys
+	}
+}
+
+// setImage sets the source image of a VM or scale set, either a marketplace image or an image resource.
+func (g *terraformGenerator) setImage(block map[string]interface{}, imageReference map[string]interface{}) {
+	if id := armString(imageReference, "id"); id != "" {
+		block["source_image_id"] = ref(id)
+		return
+	}
+	image := map[string]interface{}{}
+	setAttributes(image, imageReference, map[string][]string{
+		"publisher": {"publisher"},
+		"offer":     {"offer"},
+		"sku":       {"sku"},
+		"version":   {"version"},
+	})
+	if len(image) > 0 {
+		block["source_image_reference"] = image
+	}
+}
+
+// setPlan sets the marketplace purchase plan of a VM or scale set.
+func (g *terraformGenerator) setPlan(block map[string]interface{}, raw map[string]interface{}) {
+	plan := map[string]interface{}{}
+	setAttributes(plan, raw, map[string][]string{
+		"name":      {"plan", "name"},
+		"publisher": {"plan", "publisher"},
+		"product":   {"plan", "product"},
+	})
+	if len(plan) > 0 {
+		block["plan"] = plan
+	}
+}
+
+// setIdentity sets the managed identities of a VM or scale set.
+func (g *terraformGenerator) setIdentity(block map[string]interface{}, raw map[string]interface{}) {
+	identityType := armString(raw, "identity", "type")
+	if identityType == "" || strings.EqualFold(identityType, "None") {
+		return
+	}
+	identity := map[string]interface{}{"type": strings.Replace(identityType, ",", ", ", -1)}
+	var ids []string
+	for id := range armObject(raw, "identity", "userAssignedIdentities") {
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		var identityIDs []interface{}
+		for _, id := range ids {
+			identityIDs = append(identityIDs, ref(id))
+		}
+		identity["identity_ids"] = identityIDs
+	}
+	block["identity"] = identity
+}
+
+// setExtension sets the attributes of a VM or scale set extension. Terraform takes the settings
+// of extensions as JSON documents.
+func (g *terraformGenerator) setExtension(block map[string]interface{}, raw interface{}) error {
+	setAttributes(block, raw, map[string][]string{
+		"publisher":                  {"properties", "publisher"},
+		"type":                       {"properties", "type"},
+		"type_handler_version":       {"properties", "typeHandlerVersion"},
+		"auto_upgrade_minor_version": {"properties", "autoUpgradeMinorVersion"},
+	})
+	for attribute, property := range map[string]string{"settings": "settings", "protected_settings": "protectedSettings"} {
+		settings := armObject(raw, "properties", property)
+		if len(settings) == 0 {
+			continue
+		}
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(settings); err != nil {
+			return err
+		}
+		// the encoder escapes the NUL delimiters of placeholders, which must stay resolvable
+		block[attribute] = strings.Replace(strings.TrimSpace(b.String()), `\u0000`, "\x00", -1)
+	}
+	return nil
+}
+
+// storageAccountType returns the storage account type of a managed disk, which defaults to
+// the premium storage if the VM size supports it.
+func (g *terraformGenerator) storageAccountType(disk interface{}, vmSize string) string {
+	if t := armString(disk, "managedDisk", "storageAccountType"); t != "" {
+		return t
+	}
+	if g.sizeMap == nil {
+		g.sizeMap = getSizeMap()
+	}
+	if t := armString(g.sizeMap, "vmSizesMap", vmSize, "storageAccountType"); t != "" {
+		return t
+	}
+	return "Standard_LRS"
+}
@@ -0,0 +1,357 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package engine
+
+import (
+	"strings"
+)
+
+// Terraform mappings of the Microsoft.Network resources of the template.
+
+func (g *terraformGenerator) mapVirtualNetwork(src *terraformSource) error {
+	vnet := g.baseBlock(src)
+	setAttributes(vnet, src.raw, map[string][]string{
+		"address_space": {"properties", "addressSpace", "addressPrefixes"},
+		"dns_servers":   {"properties", "dhcpOptions", "dnsServers"},
+	})
+	g.addResource(src, "azurerm_virtual_network", src.name, src.id, vnet)
+
+	for _, s := range armSlice(src.raw, "properties", "subnets") {
+		name := armString(s, "name")
+		subnetID := ""
+		if src.id != "" {
+			subnetID = src.id + "/subnets/" + name
+		}
+		subnet := map[string]interface{}{
+			"name":                 name,
+			"resource_group_name":  g.env.ResourceGroupName,
+			"virtual_network_name": terraformRef(src.id, "name"),
+		}
+		if prefix := armString(s, "properties", "addressPrefix"); prefix != "" {
+			subnet["address_prefixes"] = []interface{}{prefix}
+		}
+		setAttributes(subnet, s, map[string][]string{
+			"address_prefixes":  {"properties", "addressPrefixes"},
+			"service_endpoints": {"properties", "serviceEndpoints"},
+		})
+		g.addResource(src, "azurerm_subnet", name, subnetID, subnet)
+
+		associations := []struct {
+			tfType, attribute string
+			path              []string
+		}{
+			{"azurerm_subnet_network_security_group_association", "network_security_group_id", []string{"properties", "networkSecurityGroup", "id"}},
+			{"azurerm_subnet_route_table_association", "route_table_id", []string{"properties", "routeTable", "id"}},
+			{"azurerm_subnet_nat_gateway_association", "nat_gateway_id", []string{"properties", "natGateway", "id"}},
+		}
+		for _, a := range associations {
+			if id := armString(s, a.path...); id != "" {
+				g.addResource(src, a.tfType, name, "", map[string]interface{}{
+					"subnet_id": ref(subnetID),
+					a.attribute: ref(id),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapNetworkSecurityGroup(src *terraformSource) error {
+	nsg := g.baseBlock(src)
+	var rules []interface{}
+	for _, r := range armSlice(src.raw, "properties", "securityRules") {
+		rule := map[string]interface{}{"name": armString(r, "name")}
+		for key, value := range armObject(r, "properties") {
+			if value != nil && key != "provisioningState" {
+				rule[terraformAttributeName(key)] = value
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		nsg["security_rule"] = rules
+	}
+	g.addResource(src, "azurerm_network_security_group", src.name, src.id, nsg)
+	return nil
+}
+
+func (g *terraformGenerator) mapRouteTable(src *terraformSource) error {
+	rt := g.baseBlock(src)
+	setAttributes(rt, src.raw, map[string][]string{
+		"disable_bgp_route_propagation": {"properties", "disableBgpRoutePropagation"},
+	})
+	var routes []interface{}
+	for _, r := range armSlice(src.raw, "properties", "routes") {
+		route := map[string]interface{}{"name": armString(r, "name")}
+		setAttributes(route, r, map[string][]string{
+			"address_prefix":         {"properties", "addressPrefix"},
+			"next_hop_type":          {"properties", "nextHopType"},
+			"next_hop_in_ip_address": {"properties", "nextHopIpAddress"},
+		})
+		routes = append(routes, route)
+	}
+	if len(routes) > 0 {
+		rt["route"] = routes
+	}
+	g.addResource(src, "azurerm_route_table", src.name, src.id, rt)
+	return nil
+}
+
+func (g *terraformGenerator) mapPublicIPAddress(src *terraformSource) error {
+	pip := g.baseBlock(src)
+	setAttributes(pip, src.raw, map[string][]string{
+		"allocation_method":       {"properties", "publicIPAllocationMethod"},
+		"domain_name_label":       {"properties", "dnsSettings", "domainNameLabel"},
+		"idle_timeout_in_minutes": {"properties", "idleTimeoutInMinutes"},
+		"ip_version":              {"properties", "publicIPAddressVersion"},
+		"sku":                     {"sku", "name"},
+		"zones":                   {"zones"},
+	})
+	g.addResource(src, "azurerm_public_ip", src.name, src.id, pip)
+	return nil
+}
+
+func (g *terraformGenerator) mapLoadBalancer(src *terraformSource) error {
+	lb := g.baseBlock(src)
+	setAttributes(lb, src.raw, map[string][]string{
+		"sku": {"sku", "name"},
+	})
+	var frontends []interface{}
+	for _, f := range armSlice(src.raw, "properties", "frontendIPConfigurations") {
+		frontend := map[string]interface{}{"name": armString(f, "name")}
+		setAttributes(frontend, f, map[string][]string{
+			"private_ip_address":            {"properties", "privateIPAddress"},
+			"private_ip_address_allocation": {"properties", "privateIPAllocationMethod"},
+			"zones":                         {"zones"},
+		})
+		if id := armString(f, "properties", "publicIPAddress", "id"); id != "" {
+			frontend["public_ip_address_id"] = ref(id)
+		}
+		if id := armString(f, "properties", "subnet", "id"); id != "" {
+			frontend["subnet_id"] = ref(id)
+		}
+		frontends = append(frontends, frontend)
+	}
+	if len(frontends) > 0 {
+		lb["frontend_ip_configuration"] = frontends
+	}
+	g.addResource(src, "azurerm_lb", src.name, src.id, lb)
+
+	// the child resources of a load balancer are separate Terraform resources
+	child := func(tfType, collection string, item interface{}, attributes map[string][]string) map[string]interface{} {
+		name := armString(item, "name")
+		block := map[string]interface{}{
+			"name":                name,
+			"resource_group_name": g.env.ResourceGroupName,
+			"loadbalancer_id":     ref(src.id),
+		}
+		setAttributes(block, item, attributes)
+		if id := armString(item, "properties", "frontendIPConfiguration", "id"); id != "" {
+			block["frontend_ip_configuration_name"] = armLastSegment(id)
+		}
+		if id := armString(item, "properties", "backendAddressPool", "id"); id != "" {
+			block["backend_address_pool_id"] = ref(id)
+		}
+		if id := armString(item, "properties", "probe", "id"); id != "" {
+			block["probe_id"] = ref(id)
+		}
+		childID := ""
+		if src.id != "" {
+			childID = src.id + "/" + collection + "/" + name
+		}
+		g.addResource(src, tfType, src.name+"_"+name, childID, block)
+		return block
+	}
+
+	for _, p := range armSlice(src.raw, "properties", "backendAddressPools") {
+		child("azurerm_lb_backend_address_pool", "backendAddressPools", p, nil)
+	}
+	for _, p := range armSlice(src.raw, "properties", "probes") {
+		child("azurerm_lb_probe", "probes", p, map[string][]string{
+			"protocol":            {"properties", "protocol"},
+			"port":                {"properties", "port"},
+			"interval_in_seconds": {"properties", "intervalInSeconds"},
+			"number_of_probes":    {"properties", "numberOfProbes"},
+			"request_path":        {"properties", "requestPath"},
+		})
+	}
+	for _, r := range armSlice(src.raw, "properties", "loadBalancingRules") {
+		child("azurerm_lb_rule", "loadBalancingRules", r, map[string][]string{
+			"protocol":                {"properties", "protocol"},
+			"frontend_port":           {"properties", "frontendPort"},
+			"backend_port":            {"properties", "backendPort"},
+			"enable_floating_ip":      {"properties", "enableFloatingIP"},
+			"enable_tcp_reset":        {"properties", "enableTcpReset"},
+			"idle_timeout_in_minutes": {"properties", "idleTimeoutInMinutes"},
+			"load_distribution":       {"properties", "loadDistribution"},
+			"disable_outbound_snat":   {"properties", "disableOutboundSnat"},
+		})
+	}
+	for _, r := range armSlice(src.raw, "properties", "inboundNatRules") {
+		child("azurerm_lb_nat_rule", "inboundNatRules", r, map[string][]string{
+			"protocol":           {"properties", "protocol"},
+			"frontend_port":      {"properties", "frontendPort"},
+			"backend_port":       {"properties", "backendPort"},
+			"enable_floating_ip": {"properties", "enableFloatingIP"},
+		})
+	}
+	for _, p := range armSlice(src.raw, "properties", "inboundNatPools") {
+		child("azurerm_lb_nat_pool", "inboundNatPools", p, map[string][]string{
+			"protocol":            {"properties", "protocol"},
+			"frontend_port_start": {"properties", "frontendPortRangeStart"},
+			"frontend_port_end":   {"properties", "frontendPortRangeEnd"},
+			"backend_port":        {"properties", "backendPort"},
+		})
+	}
+	for _, r := range armSlice(src.raw, "properties", "outboundRules") {
+		rule := child("azurerm_lb_outbound_rule", "outboundRules", r, map[string][]string{
+			"protocol":                 {"properties", "protocol"},
+			"allocated_outbound_ports": {"properties", "allocatedOutboundPorts"},
+			"idle_timeout_in_minutes":  {"properties", "idleTimeoutInMinutes"},
+			"enable_tcp_reset":         {"properties", "enableTcpReset"},
+		})
+		var frontends []interface{}
+		for _, f := range armSlice(r, "properties", "frontendIPConfigurations") {
+			frontends = append(frontends, map[string]interface{}{"name": armLastSegment(armString(f, "id"))})
+		}
+		rule["frontend_ip_configuration"] = frontends
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapNetworkInterface(src *terraformSource) error {
+	nic := g.baseBlock(src)
+	setAttributes(nic, src.raw, map[string][]string{
+		"enable_accelerated_networking": {"properties", "enableAcceleratedNetworking"},
+		"enable_ip_forwarding":          {"properties", "enableIPForwarding"},
+		"dns_servers":                   {"properties", "dnsSettings", "dnsServers"},
+	})
+
+	type association struct {
+		tfType, attribute, ipConfiguration, id string
+	}
+	var ipConfigurations []interface{}
+	var associations []association
+	for _, c := range armSlice(src.raw, "properties", "ipConfigurations") {
+		name := armString(c, "name")
+		ipConfiguration := map[string]interface{}{"name": name}
+		setAttributes(ipConfiguration, c, map[string][]string{
+			"primary":                       {"properties", "primary"},
+			"private_ip_address":            {"properties", "privateIPAddress"},
+			"private_ip_address_allocation": {"properties", "privateIPAllocationMethod"},
+			"private_ip_address_version":    {"properties", "privateIPAddressVersion"},
+		})
+		if id := armString(c, "properties", "subnet", "id"); id != "" {
+			ipConfiguration["subnet_id"] = ref(id)
+		}
+		if id := armString(c, "properties", "publicIPAddress", "id"); id != "" {
+			ipConfiguration["public_ip_address_id"] = ref(id)
+		}
+		ipConfigurations = append(ipConfigurations, ipConfiguration)
+
+		for _, p := range armSlice(c, "properties", "loadBalancerBackendAddressPools") {
+			associations = append(associations, association{"azurerm_network_interface_backend_address_pool_association", "backend_address_pool_id", name, armString(p, "id")})
+		}
+		for _, r := range armSlice(c, "properties", "loadBalancerInboundNatRules") {
+			associations = append(associations, association{"azurerm_network_interface_nat_rule_association", "nat_rule_id", name, armString(r, "id")})
+		}
+		for _, p := range armSlice(c, "properties", "applicationGatewayBackendAddressPools") {
+			associations = append(associations, association{"azurerm_network_interface_application_gateway_backend_address_pool_association", "backend_address_pool_id", name, armString(p, "id")})
+		}
+	}
+	if len(ipConfigurations) > 0 {
+		nic["ip_configuration"] = ipConfigurations
+	}
+	g.addResource(src, "azurerm_network_interface", src.name, src.id, nic)
+
+	for _, a := range associations {
+		g.addResource(src, a.tfType, src.name+"_"+a.ipConfiguration, "", map[string]interface{}{
+			"network_interface_id":  ref(src.id),
+			"ip_configuration_name": a.ipConfiguration,
+			a.attribute:             ref(a.id),
+		})
+	}
+	if id := armString(src.raw, "properties", "networkSecurityGroup", "id"); id != "" {
+		g.addResource(src, "azurerm_network_interface_security_group_association", src.name, "", map[string]interface{}{
+			"network_interface_id":      ref(src.id),
+			"network_security_group_id": ref(id),
+		})
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapNATGateway(src *terraformSource) error {
+	natGateway := g.baseBlock(src)
+	setAttributes(natGateway, src.raw, map[string][]string{
+		"sku_name":                {"sku", "name"},
+		"idle_timeout_in_minutes": {"properties", "idleTimeoutInMinutes"},
+		"zones":                   {"zones"},
+	})
+	g.addResource(src, "azurerm_nat_gateway", src.name, src.id, natGateway)
+	for _, id := range refs(armSlice(src.raw, "properties", "publicIpAddresses")) {
+		g.addResource(src, "azurerm_nat_gateway_public_ip_association", src.name, "", map[string]interface{}{
+			"nat_gateway_id":       ref(src.id),
+			"public_ip_address_id": id,
+		})
+	}
+	return nil
+}
+
+func (g *terraformGenerator) mapPrivateDNSZone(src *terraformSource) error {
+	g.addResource(src, "azurerm_private_dns_zone", src.name, src.id, map[string]interface{}{
+		"name":                src.name,
+		"resource_group_name": g.env.ResourceGroupName,
+	})
+	return nil
+}
+
+func (g *terraformGenerator) mapPrivateDNSZoneRecordSet(src *terraformSource) error {
+	zone := strings.SplitN(src.name, "/", 2)[0]
+	var records []interface{}
+	for _, r := range armSlice(src.raw, "properties", "aRecords") {
+		records = append(records, armValue(r, "ipv4Address"))
+	}
+	record := map[string]interface{}{
+		"name":                armLastSegment(src.name),
+		"zone_name":           terraformRef(g.resourceID("Microsoft.Network/privateDnsZones", zone), "name"),
+		"resource_group_name": g.env.ResourceGroupName,
+		"records":             records,
+	}
+	setAttributes(record, src.raw, map[string][]string{
+		"ttl": {"properties", "ttl"},
+	})
+	g.addResource(src, "azurerm_private_dns_a_record", src.name, src.id, record)
+	return nil
+}
+
+func (g *terraformGenerator) mapPrivateDNSZoneVirtualNetworkLink(src *terraformSource) error {
+	zone := strings.SplitN(src.name, "/", 2)[0]
+	link := map[string]interface{}{
+		"name":                  armLastSegment(src.name),
+		"private_dns_zone_name": terraformRef(g.resourceID("Microsoft.Network/privateDnsZones", zone), "name"),
+		"resource_group_name":   g.env.ResourceGroupName,
+		"virtual_network_id":    ref(armString(src.raw, "properties", "virtualNetwork", "id")),
+	}
+	setAttributes(link, src.raw, map[string][]string{
+		"registration_enabled": {"properties", "registrationEnabled"},
+	})
+	g.addResource(src, "azurerm_private_dns_zone_virtual_network_link", src.name, src.id, link)
+	return nil
+}
+
+// terraformAttributeName converts the camel case name of an ARM property to a Terraform attribute name,
+// e.g. sourceAddressPrefixes to source_address_prefixes.
+func terraformAttributeName(property string) string {
+	var sb strings.Builder
+	for i, c := range property {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 && !(property[i-1] >= 'A' && property[i-1] <= 'Z') {
+				sb.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// namedArrayKeys are the apimodel arrays whose elements are matched by their "name" property
+// when an overlay is merged, instead of replacing the whole array
+var namedArrayKeys = map[string]bool{
+	"agentPoolProfiles": true,
+	"addons":            true,
+	"containers":        true,
+}
+
+// MergeAPIModelOverlays takes the path to a base ApiModel JSON file and an ordered list of overlay files,
+// merges each overlay on top of the base and writes the effective api model to another temp file.
+//
+// Objects are merged recursively, scalars and plain arrays are overridden by the overlay, and the
+// agentPoolProfiles, addons and addon containers arrays are merged element by element, matched by name.
+// Elements that don't exist in the base are appended in overlay order.
+func MergeAPIModelOverlays(apiModelPath string, overlayPaths []string) (string, error) {
+	merged, err := loadAPIModelJSON(apiModelPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, overlayPath := range overlayPaths {
+		log.Debugln(fmt.Sprintf("merging api model overlay %s", overlayPath))
+		overlay, err := loadAPIModelJSON(overlayPath)
+		if err != nil {
+			return "", err
+		}
+		if merged, err = mergeAPIModelValues(merged, overlay, ""); err != nil {
+			return "", errors.Wrapf(err, "error merging api model overlay %s", overlayPath)
+		}
+	}
+
+	content, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	// generate a new file
+	tmpFile, err := ioutil.TempFile("", "mergedApiModel")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	if _, err = tmpFile.Write(content); err != nil {
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+func loadAPIModelJSON(path string) (interface{}, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep numbers as json.Number so large integers survive the round trip untouched
+	decoder := json.NewDecoder(bytes.NewReader(fileContent))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", path)
+	}
+	return value, nil
+}
+
+func mergeAPIModelValues(base, overlay interface{}, key string) (interface{}, error) {
+	switch overlayValue := overlay.(type) {
+	case map[string]interface{}:
+		baseMap, ok := base.(map[string]interface{})
+		if !ok {
+			return overlayValue, nil
+		}
+		for k, v := range overlayValue {
+			mergedValue, err := mergeAPIModelValues(baseMap[k], v, k)
+			if err != nil {
+				return nil, err
+			}
+			baseMap[k] = mergedValue
+		}
+		return baseMap, nil
+	case []interface{}:
+		baseArray, ok := base.([]interface{})
+		if !ok || !namedArrayKeys[key] {
+			return overlayValue, nil
+		}
+		return mergeNamedArrays(baseArray, overlayValue, key)
+	default:
+		return overlayValue, nil
+	}
+}
+
+func mergeNamedArrays(base, overlay []interface{}, key string) ([]interface{}, error) {
+	indexByName := map[string]int{}
+	for i, element := range base {
+		name, err := elementName(element, key)
+		if err != nil {
+			return nil, err
+		}
+		indexByName[name] = i
+	}
+
+	for _, element := range overlay {
+		name, err := elementName(element, key)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := indexByName[name]; ok {
+			mergedElement, err := mergeAPIModelValues(base[i], element, "")
+			if err != nil {
+				return nil, err
+			}
+			base[i] = mergedElement
+		} else {
+			indexByName[name] = len(base)
+			base = append(base, element)
+		}
+	}
+
+	return base, nil
+}
+
+func elementName(element interface{}, key string) (string, error) {
+	if m, ok := element.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok && name != "" {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf("every element of %s must have a name to be merged", key)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package transform
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/Jeffail/gabs"
+	. "github.com/onsi/gomega"
+)
+
+func TestMergeAPIModelOverlays(t *testing.T) {
+	RegisterTestingT(t)
+
+	tmpFile, err := MergeAPIModelOverlays("transformtestfiles/overlays/base.json", []string{
+		"transformtestfiles/overlays/prod.json",
+		"transformtestfiles/overlays/prod-westus2.json",
+	})
+	Expect(err).To(BeNil())
+
+	jsonFileContent, err := ioutil.ReadFile(tmpFile)
+	Expect(err).To(BeNil())
+
+	jsonAPIModel, err := gabs.ParseJSON(jsonFileContent)
+	Expect(err).To(BeNil())
+
+	// scalars are overridden in overlay order, untouched values are kept
+	Expect(jsonAPIModel.Path("apiVersion").Data()).To(Equal("vlabs"))
+	Expect(jsonAPIModel.Path("location").Data()).To(Equal("westus2"))
+	Expect(jsonAPIModel.Path("properties.masterProfile.count").Data()).To(Equal(float64(5)))
+	Expect(jsonAPIModel.Path("properties.masterProfile.dnsPrefix").Data()).To(Equal("prod-westus2"))
+	Expect(jsonAPIModel.Path("properties.masterProfile.vmSize").Data()).To(Equal("Standard_D2_v2"))
+	Expect(jsonAPIModel.Path("properties.linuxProfile.adminUsername").Data()).To(Equal("azureuser"))
+	Expect(jsonAPIModel.Path("properties.orchestratorProfile.kubernetesConfig.networkPlugin").Data()).To(Equal("azure"))
+
+	// agent pools are matched by name, new pools are appended
+	pools, err := jsonAPIModel.Path("properties.agentPoolProfiles").Children()
+	Expect(err).To(BeNil())
+	Expect(pools).To(HaveLen(3))
+	Expect(pools[0].Path("name").Data()).To(Equal("agentpool1"))
+	Expect(pools[0].Path("count").Data()).To(Equal(float64(3)))
+	Expect(pools[1].Path("name").Data()).To(Equal("agentpool2"))
+	Expect(pools[1].Path("count").Data()).To(Equal(float64(20)))
+	Expect(pools[1].Path("vmSize").Data()).To(Equal("Standard_D4_v2"))
+	Expect(pools[1].Path("availabilityProfile").Data()).To(Equal("AvailabilitySet"))
+	Expect(pools[1].Path("customNodeLabels.tier").Data()).To(Equal("backend"))
+	Expect(pools[1].Path("customNodeLabels.environment").Data()).To(Equal("prod"))
+	Expect(pools[2].Path("name").Data()).To(Equal("agentpool3"))
+	Expect(pools[2].Path("count").Data()).To(Equal(float64(2)))
+
+	// addons and their containers are matched by name
+	addons, err := jsonAPIModel.Path("properties.orchestratorProfile.kubernetesConfig.addons").Children()
+	Expect(err).To(BeNil())
+	Expect(addons).To(HaveLen(3))
+	Expect(addons[0].Path("name").Data()).To(Equal("tiller"))
+	Expect(addons[0].Path("enabled").Data()).To(Equal(true))
+	containers, err := addons[0].Path("containers").Children()
+	Expect(err).To(BeNil())
+	Expect(containers).To(HaveLen(1))
+	Expect(containers[0].Path("cpuRequests").Data()).To(Equal("500m"))
+	Expect(containers[0].Path("memoryRequests").Data()).To(Equal("128Mi"))
+	Expect(addons[1].Path("name").Data()).To(Equal("kubernetes-dashboard"))
+	Expect(addons[2].Path("name").Data()).To(Equal("cluster-autoscaler"))
+
+	// other arrays are replaced wholesale
+	publicKeys, err := jsonAPIModel.Path("properties.linuxProfile.ssh.publicKeys").Children()
+	Expect(err).To(BeNil())
+	Expect(publicKeys).To(HaveLen(1))
+	Expect(publicKeys[0].Path("keyData").Data()).To(Equal("ssh-rsa PRODKEY azureuser@linuxvm"))
+}
+
+func TestMergeAPIModelOverlaysErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := MergeAPIModelOverlays("transformtestfiles/overlays/base.json", []string{"transformtestfiles/overlays/unnamed-pool.json"})
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("every element of agentPoolProfiles must have a name"))
+
+	_, err = MergeAPIModelOverlays("transformtestfiles/overlays/base.json", []string{"transformtestfiles/overlays/does-not-exist.json"})
+	Expect(err).NotTo(BeNil())
+
+	_, err = MergeAPIModelOverlays("transformtestfiles/overlays/does-not-exist.json", nil)
+	Expect(err).NotTo(BeNil())
+}
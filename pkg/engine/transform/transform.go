@@ -188,9 +188,10 @@ func (t *Transformer) NormalizeForK8sVMASScalingUp(logger *logrus.Entry, templat
 
 	indexesToRemove := []int{}
 	if nsgIndex == -1 {
-		err := t.Translator.Errorf("Found no resources with type %s in the template. There should have been 1", nsgResourceType)
-		logger.Errorf(err.Error())
-		return err
+		// clusters with a custom network security group do not declare one in the template
+		logger.Debugf("Found no resources with type %s in the template.", nsgResourceType)
+	} else {
+		indexesToRemove = append(indexesToRemove, nsgIndex)
 	}
 
 	if rtIndex == -1 {
@@ -207,7 +208,6 @@ func (t *Transformer) NormalizeForK8sVMASScalingUp(logger *logrus.Entry, templat
 		indexesToRemove = append(indexesToRemove, vmasIndexes...)
 	}
 
-	indexesToRemove = append(indexesToRemove, nsgIndex)
 	templateMap[resourcesFieldName] = removeIndexesFromArray(resources, indexesToRemove)
 
 	return nil
@@ -49,6 +49,23 @@ func TestNormalizeForK8sVMASScalingUpWithVnet(t *testing.T) {
 	ValidateTemplate(templateMap, expectedFileContents, "TestNormalizeForK8sVMASScalingUpWithVnet")
 }
 
+func TestNormalizeForK8sVMASScalingUpWithCustomNSG(t *testing.T) {
+	RegisterTestingT(t)
+	logger := logrus.New().WithField("testName", "TestNormalizeForK8sVMASScalingUpWithCustomNSG")
+	fileContents, e := ioutil.ReadFile("./transformtestfiles/k8s_vnet_custom_nsg_template.json")
+	Expect(e).To(BeNil())
+	expectedFileContents, e := ioutil.ReadFile("./transformtestfiles/k8s_vnet_custom_nsg_scale_template.json")
+	Expect(e).To(BeNil())
+	templateJSON := string(fileContents)
+	var template interface{}
+	json.Unmarshal([]byte(templateJSON), &template)
+	templateMap := template.(map[string]interface{})
+	transformer := Transformer{}
+	e = transformer.NormalizeForK8sVMASScalingUp(logger, templateMap)
+	Expect(e).To(BeNil())
+	ValidateTemplate(templateMap, expectedFileContents, "TestNormalizeForK8sVMASScalingUpWithCustomNSG")
+}
+
 func TestNormalizeResourcesForK8sMasterUpgrade(t *testing.T) {
 	RegisterTestingT(t)
 	logger := logrus.New().WithField("testName", "TestNormalizeResourcesForK8sMasterUpgrade")
@@ -59,6 +59,7 @@ type KubernetesSpecConfig struct {
 	VnetCNILinuxPluginsDownloadURL   string
 	VnetCNIWindowsPluginsDownloadURL string
 	ContainerdDownloadURLBase        string
+	WindowsContainerdDownloadURL     string
 }
 
 //AzureEndpointConfig describes an Azure endpoint
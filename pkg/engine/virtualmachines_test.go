@@ -678,3 +678,67 @@ func TestCreateVMsWithCustomOS(t *testing.T) {
 		t.Error("expected custom OS to have image ref")
 	}
 }
+
+func TestCreateWindowsAgentAvailabilitySetVMWithImageRef(t *testing.T) {
+	cases := []struct {
+		name             string
+		poolImage        *api.ImageReference
+		windowsImage     *api.ImageReference
+		expectedImageRef *compute.ImageReference
+	}{
+		{
+			name: "marketplace image",
+			expectedImageRef: &compute.ImageReference{
+				Offer:     to.StringPtr("[parameters('agentWindowsOffer')]"),
+				Publisher: to.StringPtr("[parameters('agentWindowsPublisher')]"),
+				Sku:       to.StringPtr("[parameters('agentWindowsSku')]"),
+				Version:   to.StringPtr("[parameters('agentWindowsVersion')]"),
+			},
+		},
+		{
+			name: "managed image of the agent pool",
+			poolImage: &api.ImageReference{
+				Name:          "windows",
+				ResourceGroup: "images",
+			},
+			expectedImageRef: &compute.ImageReference{
+				ID: to.StringPtr("[resourceId(variables('agentpool1osImageResourceGroup'), 'Microsoft.Compute/images', variables('agentpool1osImageName'))]"),
+			},
+		},
+		{
+			name: "Shared Image Gallery image of the windows profile",
+			windowsImage: &api.ImageReference{
+				Name:           "windows",
+				ResourceGroup:  "images",
+				SubscriptionID: "00000000-0000-0000-0000-000000000000",
+				Gallery:        "gallery",
+				Version:        "1.0.0",
+			},
+			expectedImageRef: &compute.ImageReference{
+				ID: to.StringPtr("[concat('/subscriptions/', '00000000-0000-0000-0000-000000000000', '/resourceGroups/', variables('agentpool1osImageResourceGroup'), '/providers/Microsoft.Compute/galleries/', 'gallery', '/images/', variables('agentpool1osImageName'), '/versions/', '1.0.0')]"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, false)
+			profile := cs.Properties.AgentPoolProfiles[0]
+			profile.OSType = api.Windows
+			profile.AvailabilityProfile = api.AvailabilitySet
+			profile.ImageRef = c.poolImage
+			cs.Properties.WindowsProfile = &api.WindowsProfile{
+				AdminUsername: "azureuser",
+				AdminPassword: "password",
+				ImageRef:      c.windowsImage,
+			}
+
+			vm := createAgentAvailabilitySetVM(cs, profile)
+
+			if diff := cmp.Diff(c.expectedImageRef, vm.StorageProfile.ImageReference); diff != "" {
+				t.Errorf("unexpected image reference (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
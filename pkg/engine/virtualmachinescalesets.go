@@ -35,7 +35,9 @@ func CreateMasterVMSS(cs *api.ContainerService) VirtualMachineScaleSetARM {
 	var dependencies []string
 
 	if isCustomVnet {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
@@ -347,14 +349,17 @@ func CreateAgentVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) Vi
 	var dependencies []string
 
 	if profile.IsCustomVNET() {
-		dependencies = append(dependencies, "[variables('nsgID')]")
+		if !isCustomNSG(cs) {
+			dependencies = append(dependencies, "[variables('nsgID')]")
+		}
 	} else {
 		dependencies = append(dependencies, "[variables('vnetID')]")
 	}
 
 	if !cs.Properties.OrchestratorProfile.IsPrivateCluster() &&
 		profile.LoadBalancerBackendAddressPoolIDs == nil &&
-		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+		cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku &&
+		cs.Properties.OrchestratorProfile.KubernetesConfig.IsLoadBalancerOutbound() {
 		dependencies = append(dependencies, "[variables('agentLbID')]")
 	}
 
@@ -480,7 +485,8 @@ func CreateAgentVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) Vi
 				}
 			} else {
 				if !cs.Properties.OrchestratorProfile.IsPrivateCluster() &&
-					cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku {
+					cs.Properties.OrchestratorProfile.KubernetesConfig.LoadBalancerSku == api.StandardLoadBalancerSku &&
+					cs.Properties.OrchestratorProfile.KubernetesConfig.IsLoadBalancerOutbound() {
 					agentLbBackendAddressPools := compute.SubResource{
 						ID: to.StringPtr("[concat(variables('agentLbID'), '/backendAddressPools/', variables('agentLbBackendPoolName'))]"),
 					}
@@ -626,11 +632,23 @@ func CreateAgentVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) Vi
 	vmssStorageProfile := compute.VirtualMachineScaleSetStorageProfile{}
 
 	if profile.IsWindows() {
-		vmssStorageProfile.ImageReference = &compute.ImageReference{
-			Offer:     to.StringPtr("[parameters('agentWindowsOffer')]"),
-			Publisher: to.StringPtr("[parameters('agentWindowsPublisher')]"),
-			Sku:       to.StringPtr("[parameters('agentWindowsSku')]"),
-			Version:   to.StringPtr("[parameters('agentWindowsVersion')]"),
+		if imageRef := cs.Properties.GetWindowsImageRef(profile); imageRef != nil {
+			if imageRef.IsGalleryImage() {
+				vmssStorageProfile.ImageReference = &compute.ImageReference{
+					ID: to.StringPtr(fmt.Sprintf("[concat('/subscriptions/', '%s', '/resourceGroups/', variables('%sosImageResourceGroup'), '/providers/Microsoft.Compute/galleries/', '%s', '/images/', variables('%sosImageName'), '/versions/', '%s')]", imageRef.SubscriptionID, profile.Name, imageRef.Gallery, profile.Name, imageRef.Version)),
+				}
+			} else {
+				vmssStorageProfile.ImageReference = &compute.ImageReference{
+					ID: to.StringPtr(fmt.Sprintf("[resourceId(variables('%[1]sosImageResourceGroup'), 'Microsoft.Compute/images', variables('%[1]sosImageName'))]", profile.Name)),
+				}
+			}
+		} else {
+			vmssStorageProfile.ImageReference = &compute.ImageReference{
+				Offer:     to.StringPtr("[parameters('agentWindowsOffer')]"),
+				Publisher: to.StringPtr("[parameters('agentWindowsPublisher')]"),
+				Sku:       to.StringPtr("[parameters('agentWindowsSku')]"),
+				Version:   to.StringPtr("[parameters('agentWindowsVersion')]"),
+			}
 		}
 		vmssStorageProfile.DataDisks = getVMSSDataDisks(profile)
 	} else {
@@ -708,7 +726,7 @@ func CreateAgentVMSS(cs *api.ContainerService, profile *api.AgentPoolProfile) Vi
 				AutoUpgradeMinorVersion: to.BoolPtr(true),
 				Settings:                map[string]interface{}{},
 				ProtectedSettings: map[string]interface{}{
-					"commandToExecute": "[concat('powershell.exe -ExecutionPolicy Unrestricted -command \"', '$arguments = ', variables('singleQuote'),'-MasterIP ',variables('kubernetesAPIServerIP'),' -KubeDnsServiceIp ',parameters('kubeDnsServiceIp'),' -MasterFQDNPrefix ',variables('masterFqdnPrefix'),' -Location ',variables('location'),' -TargetEnvironment ',parameters('targetEnvironment'),' -AgentKey ',parameters('clientPrivateKey'),' -AADClientId ',variables('servicePrincipalClientId'),' -AADClientSecret ',variables('singleQuote'),variables('singleQuote'),base64(variables('servicePrincipalClientSecret')),variables('singleQuote'),variables('singleQuote'),' -NetworkAPIVersion ',variables('apiVersionNetwork'),' ',variables('singleQuote'), ' ; ', variables('windowsCustomScriptSuffix'), '\" > %SYSTEMDRIVE%\\AzureData\\CustomDataSetupScript.log 2>&1')]",
+					"commandToExecute": getWindowsCustomScriptCommand(cs.Properties.WindowsProfile),
 				},
 			},
 		}
@@ -1108,3 +1108,30 @@ func TestCreateCustomOSVMSS(t *testing.T) {
 		t.Errorf("unexpected diff while expecting equal agent VMSS structs: %s", diff)
 	}
 }
+
+func TestCreateWindowsAgentVMSSWithImageRef(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.14.3", 1, 1, false)
+	profile := cs.Properties.AgentPoolProfiles[0]
+	profile.OSType = api.Windows
+	profile.AvailabilityProfile = api.VirtualMachineScaleSets
+	profile.ImageRef = &api.ImageReference{
+		Name:           "windows",
+		ResourceGroup:  "images",
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		Gallery:        "gallery",
+		Version:        "1.0.0",
+	}
+	cs.Properties.WindowsProfile = &api.WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "password",
+	}
+
+	vmss := CreateAgentVMSS(cs, profile)
+
+	expected := &compute.ImageReference{
+		ID: to.StringPtr("[concat('/subscriptions/', '00000000-0000-0000-0000-000000000000', '/resourceGroups/', variables('agentpool1osImageResourceGroup'), '/providers/Microsoft.Compute/galleries/', 'gallery', '/images/', variables('agentpool1osImageName'), '/versions/', '1.0.0')]"),
+	}
+	if diff := cmp.Diff(expected, vmss.VirtualMachineProfile.StorageProfile.ImageReference); diff != "" {
+		t.Errorf("unexpected image reference (-want +got):\n%s", diff)
+	}
+}
@@ -4,6 +4,7 @@
 package engine
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/Azure/aks-engine/pkg/api"
@@ -416,6 +417,30 @@ func TestCreateAgentVMASCustomScriptExtension(t *testing.T) {
 	}
 }
 
+func TestGetWindowsCustomScriptCommand(t *testing.T) {
+	windowsProfile := &api.WindowsProfile{
+		AdminUsername: "azureuser",
+		AdminPassword: "password",
+	}
+	command := getWindowsCustomScriptCommand(windowsProfile)
+	if strings.Contains(command, "DomainJoinPassword") {
+		t.Errorf("expected no domain join password without domain join, got %s", command)
+	}
+
+	windowsProfile.DomainJoin = &api.WindowsDomainJoin{
+		Domain:   "corp.contoso.com",
+		UserName: "joiner",
+		KeyvaultSecretRef: &api.KeyvaultSecretRef{
+			VaultID:    "/subscriptions/SUB-ID/resourceGroups/RG-NAME/providers/Microsoft.KeyVault/vaults/KV-NAME",
+			SecretName: "domain-join",
+		},
+	}
+	expected := "' -DomainJoinPassword ',variables('singleQuote'),variables('singleQuote'),base64(parameters('windowsDomainJoinPassword')),variables('singleQuote'),variables('singleQuote'),' -NetworkAPIVersion '"
+	if command = getWindowsCustomScriptCommand(windowsProfile); !strings.Contains(command, expected) {
+		t.Errorf("expected the domain join password to be passed from its secure parameter, got %s", command)
+	}
+}
+
 func TestCreateCustomExtensions(t *testing.T) {
 	properties := &api.Properties{
 		OrchestratorProfile: &api.OrchestratorProfile{
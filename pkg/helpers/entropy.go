@@ -0,0 +1,118 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeterministicEpoch is the time deterministic entropy reports as now, so the validity
+// period of generated certificates does not depend on when they were generated
+var DeterministicEpoch = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Entropy is the source of randomness and time used to create generated secrets such as
+// certificates, private keys and encryption keys. A nil *Entropy uses crypto/rand and the
+// current time.
+type Entropy struct {
+	seed []byte
+
+	// keys memoizes the parsed test keys by size
+	mu   sync.Mutex
+	keys map[int]*rsa.PrivateKey
+}
+
+// NewDeterministicEntropy returns an Entropy whose output depends only on seed, so that
+// secrets generated from it are byte-identical across runs. It is for tests only: its RSA
+// keys are the test keys published with aks-engine, see GenerateRSAKey.
+func NewDeterministicEntropy(seed []byte) *Entropy {
+	sum := sha256.Sum256(seed)
+	return &Entropy{seed: sum[:], keys: map[int]*rsa.PrivateKey{}}
+}
+
+// IsDeterministic returns true if the generated secrets depend only on the seed
+func (e *Entropy) IsDeterministic() bool {
+	return e != nil
+}
+
+// Now returns the current time, or DeterministicEpoch if e is deterministic
+func (e *Entropy) Now() time.Time {
+	if e.IsDeterministic() {
+		return DeterministicEpoch
+	}
+	return time.Now()
+}
+
+// Reader returns the stream of random bytes for the secret identified by name. Every name
+// draws from its own stream, so secrets generated concurrently or in a different order
+// stay the same.
+func (e *Entropy) Reader(name string) io.Reader {
+	if !e.IsDeterministic() {
+		return rand.Reader
+	}
+	return &entropyStream{seed: e.seed, name: name}
+}
+
+// GenerateRSAKey generates an RSA private key of the given size for the secret identified by name. Deterministic
+// entropy returns the published test key of that size for every name instead, as rsa.GenerateKey does not
+// generate the same key twice from the same random bytes.
+func (e *Entropy) GenerateRSAKey(name string, bits int) (*rsa.PrivateKey, error) {
+	if !e.IsDeterministic() {
+		return rsa.GenerateKey(rand.Reader, bits)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if key, ok := e.keys[bits]; ok {
+		return key, nil
+	}
+	keyPem, ok := testRSAKeys[bits]
+	if !ok {
+		return nil, errors.Errorf("generating key %s: there is no %d bit test key for deterministic entropy", name, bits)
+	}
+	block, _ := pem.Decode([]byte(keyPem))
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing the %d bit test key", bits)
+	}
+	e.keys[bits] = key
+	return key, nil
+}
+
+// entropyStream is HMAC-SHA256 in counter mode, keyed by the seed and labelled by the secret name
+type entropyStream struct {
+	seed    []byte
+	name    string
+	counter uint64
+	buf     []byte
+}
+
+func (s *entropyStream) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.buf) == 0 {
+			mac := hmac.New(sha256.New, s.seed)
+			mac.Write([]byte(s.name))
+			// a zero byte separates the name from the block counter
+			var counter [9]byte
+			binary.BigEndian.PutUint64(counter[1:], s.counter)
+			mac.Write(counter[:])
+			s.buf = mac.Sum(nil)
+			s.counter++
+		}
+		copied := copy(p[n:], s.buf)
+		s.buf = s.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"io"
+	"testing"
+	"time"
+)
+
+func readEntropy(t *testing.T, r io.Reader, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatalf("unexpected error reading entropy: %s", err)
+	}
+	return b
+}
+
+func TestEntropyReader(t *testing.T) {
+	var random *Entropy
+	if random.IsDeterministic() {
+		t.Errorf("expected a nil Entropy not to be deterministic")
+	}
+	if random.Reader("ca") != rand.Reader {
+		t.Errorf("expected a nil Entropy to read from crypto/rand")
+	}
+
+	entropy := NewDeterministicEntropy([]byte("seed"))
+	if !entropy.IsDeterministic() {
+		t.Errorf("expected entropy from NewDeterministicEntropy to be deterministic")
+	}
+
+	// reading in several chunks gives the same bytes as reading at once
+	r := entropy.Reader("ca")
+	chunked := append(readEntropy(t, r, 7), readEntropy(t, r, 100)...)
+	whole := readEntropy(t, NewDeterministicEntropy([]byte("seed")).Reader("ca"), 107)
+	if !bytes.Equal(chunked, whole) {
+		t.Errorf("expected the same stream for the same seed and name")
+	}
+
+	cases := []struct {
+		name   string
+		reader io.Reader
+	}{
+		{
+			name:   "different name",
+			reader: entropy.Reader("apiserver"),
+		},
+		{
+			name:   "different seed",
+			reader: NewDeterministicEntropy([]byte("other seed")).Reader("ca"),
+		},
+	}
+	for _, c := range cases {
+		if bytes.Equal(readEntropy(t, c.reader, 107), whole) {
+			t.Errorf("%s: expected a different stream", c.name)
+		}
+	}
+}
+
+func TestEntropyNow(t *testing.T) {
+	var random *Entropy
+	if now := random.Now(); time.Since(now) > time.Minute {
+		t.Errorf("expected a nil Entropy to return the current time, got %s", now)
+	}
+	if now := NewDeterministicEntropy([]byte("seed")).Now(); !now.Equal(DeterministicEpoch) {
+		t.Errorf("expected deterministic entropy to return %s, got %s", DeterministicEpoch, now)
+	}
+}
+
+func TestEntropyGenerateRSAKey(t *testing.T) {
+	entropy := NewDeterministicEntropy([]byte("seed"))
+	key, err := entropy.GenerateRSAKey("ca/key", 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+	if err = key.Validate(); err != nil {
+		t.Fatalf("expected a valid key, got %s", err)
+	}
+	if key.N.BitLen() != 2048 {
+		t.Errorf("expected a 2048 bit key, got %d bits", key.N.BitLen())
+	}
+
+	again, err := NewDeterministicEntropy([]byte("seed")).GenerateRSAKey("ca/key", 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+	if !bytes.Equal(x509.MarshalPKCS1PrivateKey(key), x509.MarshalPKCS1PrivateKey(again)) {
+		t.Errorf("expected the same key for the same seed and name")
+	}
+
+	// every name gets the published test key of its size
+	other, err := NewDeterministicEntropy([]byte("other seed")).GenerateRSAKey("apiserver/key", 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %s", err)
+	}
+	if !bytes.Equal(x509.MarshalPKCS1PrivateKey(key), x509.MarshalPKCS1PrivateKey(other)) {
+		t.Errorf("expected the 2048 bit test key for every seed and name")
+	}
+
+	if _, err = entropy.GenerateRSAKey("ca/key", 1024); err == nil {
+		t.Errorf("expected an error generating a key of a size without a test key")
+	}
+}
@@ -44,7 +44,8 @@ func JSONMarshalIndent(content interface{}, prefix, indent string, escape bool)
 	return bufIndent.Bytes(), nil
 }
 
-// JSONMarshal marshals JSON w/ optional SetEscapeHTML
+// JSONMarshal marshals JSON w/ optional SetEscapeHTML. Map keys, including those of nested maps, are
+// written in sorted order, so that the same content always marshals to the same bytes.
 func JSONMarshal(content interface{}, escape bool) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -103,6 +104,20 @@ func CreateSSH(rg io.Reader, s *i18n.Translator) (privateKey *rsa.PrivateKey, pu
 		return nil, "", s.Errorf("failed to generate private key for ssh: %q", err)
 	}
 
+	return sshKeyPair(privateKey, s)
+}
+
+// CreateSSHWithEntropy is CreateSSH drawing the private key from entropy, a nil entropy generates it from crypto/rand
+func CreateSSHWithEntropy(entropy *Entropy, s *i18n.Translator) (privateKey *rsa.PrivateKey, publicKeyString string, err error) {
+	privateKey, err = entropy.GenerateRSAKey("ssh", SSHKeySize)
+	if err != nil {
+		return nil, "", s.Errorf("failed to generate private key for ssh: %q", err)
+	}
+
+	return sshKeyPair(privateKey, s)
+}
+
+func sshKeyPair(privateKey *rsa.PrivateKey, s *i18n.Translator) (*rsa.PrivateKey, string, error) {
 	publicKey := privateKey.PublicKey
 	sshPublicKey, err := ssh.NewPublicKey(&publicKey)
 	if err != nil {
@@ -40,6 +40,28 @@ func TestJSONMarshal(t *testing.T) {
 	}
 }
 
+func TestJSONMarshalSortsMaps(t *testing.T) {
+	input := map[string]interface{}{
+		"variables": map[string]interface{}{
+			"zone":    1,
+			"agent":   "pool",
+			"masters": map[int]string{10: "ten", 2: "two"},
+		},
+		"parameters": map[string]string{"b": "2", "a": "1"},
+		"$schema":    "schema",
+	}
+	expected := `{"$schema":"schema","parameters":{"a":"1","b":"2"},"variables":{"agent":"pool","masters":{"10":"ten","2":"two"},"zone":1}}` + "\n"
+	for i := 0; i < 10; i++ {
+		result, err := JSONMarshal(input, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(result) != expected {
+			t.Fatalf("JSONMarshal returned unexpected result: expected %s but got %s", expected, string(result))
+		}
+	}
+}
+
 func TestNormalizeAzureRegion(t *testing.T) {
 	cases := []struct {
 		input          string
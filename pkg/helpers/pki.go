@@ -5,17 +5,20 @@ package helpers
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
-	"errors"
 	"fmt"
 	"math/big"
 	"net"
 	"time"
 
+	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
 	log "github.com/sirupsen/logrus"
@@ -26,17 +29,119 @@ const (
 	ValidityDuration = time.Hour * 24 * 365 * 30
 	// PkiKeySize is the size in bytes of the PKI key
 	PkiKeySize = 4096
+	// PkiECDSAKeySize is the size in bits of the curve of ECDSA PKI keys
+	PkiECDSAKeySize = 256
+)
+
+const (
+	// PkiKeyAlgorithmRSA generates RSA PKI keys
+	PkiKeyAlgorithmRSA = "RSA"
+	// PkiKeyAlgorithmECDSA generates ECDSA PKI keys
+	PkiKeyAlgorithmECDSA = "ECDSA"
 )
 
 // PkiKeyCertPair represents an PKI public and private cert pair
 type PkiKeyCertPair struct {
 	CertificatePem string
 	PrivateKeyPem  string
+	// CertificateChainPem holds the PEM encoded certificates that chain an intermediate CA certificate to a root CA,
+	// the issuer of CertificatePem first
+	CertificateChainPem string
+}
+
+// issuedChain returns the PEM encoded certificates to append to the certificates the CA of p issues, so that they
+// chain to a root CA. It is empty unless the CA is an intermediate with a chain.
+func (p *PkiKeyCertPair) issuedChain() (string, error) {
+	if p.CertificateChainPem == "" {
+		return "", nil
+	}
+	chain, err := pemToCertificates(p.CertificatePem + "\n" + p.CertificateChainPem)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	for _, certificate := range chain {
+		b.Write(certificateToPem(certificate.Raw))
+	}
+	return b.String(), nil
+}
+
+// PkiOptions configures the keys and certificates of the PKI. The zero value, like a nil
+// *PkiOptions, creates PkiKeySize bit RSA keys and certificates valid for ValidityDuration.
+type PkiOptions struct {
+	// KeyAlgorithm is either PkiKeyAlgorithmRSA or PkiKeyAlgorithmECDSA
+	KeyAlgorithm string
+	// KeySize is the size in bits of RSA keys, or of the curve of ECDSA keys
+	KeySize int
+	// CAValidity is how long CA certificates are valid
+	CAValidity time.Duration
+	// LeafValidity is how long the certificates signed by the CA are valid
+	LeafValidity time.Duration
+	// ExtraSubjectAltNames are the DNS names and IP addresses added to the certificates of a component,
+	// either apiserver, etcdserver, etcdclient or etcdpeer
+	ExtraSubjectAltNames map[string][]string
+}
+
+// PkiComponents are the components of the PKI that accept extra subject alternative names
+var PkiComponents = [...]string{"apiserver", "etcdserver", "etcdclient", "etcdpeer"}
+
+func (o *PkiOptions) keyAlgorithm() string {
+	if o == nil || o.KeyAlgorithm == "" {
+		return PkiKeyAlgorithmRSA
+	}
+	return o.KeyAlgorithm
+}
+
+func (o *PkiOptions) keySize() int {
+	if o != nil && o.KeySize != 0 {
+		return o.KeySize
+	}
+	if o.keyAlgorithm() == PkiKeyAlgorithmECDSA {
+		return PkiECDSAKeySize
+	}
+	return PkiKeySize
+}
+
+func (o *PkiOptions) caValidity() time.Duration {
+	if o == nil || o.CAValidity == 0 {
+		return ValidityDuration
+	}
+	return o.CAValidity
+}
+
+func (o *PkiOptions) leafValidity() time.Duration {
+	if o == nil || o.LeafValidity == 0 {
+		return ValidityDuration
+	}
+	return o.LeafValidity
+}
+
+// extraSubjectAltNames returns the extra DNS names and IP addresses of component
+func (o *PkiOptions) extraSubjectAltNames(component string) ([]string, []net.IP) {
+	if o == nil {
+		return nil, nil
+	}
+	var fqdns []string
+	var ips []net.IP
+	for _, name := range o.ExtraSubjectAltNames[component] {
+		if ip := net.ParseIP(name); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			fqdns = append(fqdns, name)
+		}
+	}
+	return fqdns, ips
+}
+
+// CreatePkiKeyCertPair generates a pair of PKI certificate and private key, drawing randomness from entropy
+func CreatePkiKeyCertPair(commonName string, entropy *Entropy) (*PkiKeyCertPair, error) {
+	return CreatePkiKeyCertPairWithOptions(commonName, entropy, nil)
 }
 
-// CreatePkiKeyCertPair generates a pair of PKI certificate and private key
-func CreatePkiKeyCertPair(commonName string) (*PkiKeyCertPair, error) {
-	caCertificate, caPrivateKey, err := createCertificate(commonName, nil, nil, false, false, nil, nil, nil)
+// CreatePkiKeyCertPairWithOptions generates a pair of CA certificate and private key with the key algorithm,
+// key size and CA validity of options, drawing randomness from entropy
+func CreatePkiKeyCertPairWithOptions(commonName string, entropy *Entropy, options *PkiOptions) (*PkiKeyCertPair, error) {
+	caCertificate, caPrivateKey, err := createCertificate(entropy, commonName, commonName, nil, nil, false, false, nil, nil, nil, options.caValidity(), options)
 	if err != nil {
 		return nil, err
 	}
@@ -44,8 +149,9 @@ func CreatePkiKeyCertPair(commonName string) (*PkiKeyCertPair, error) {
 	return caPair, nil
 }
 
-// CreatePki creates PKI certificates
-func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caPair *PkiKeyCertPair, masterCount int) (*PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, []*PkiKeyCertPair, error) {
+// CreatePki creates PKI certificates as configured by options, drawing randomness from entropy. When caPair is an
+// intermediate CA with a chain, the certificates are followed by the CA certificate and its chain.
+func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caPair *PkiKeyCertPair, masterCount int, entropy *Entropy, options *PkiOptions) (*PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, *PkiKeyCertPair, []*PkiKeyCertPair, error) {
 	start := time.Now()
 	defer func(s time.Time) {
 		log.Debugf("pki: PKI asset creation took %s", time.Since(s))
@@ -58,19 +164,26 @@ func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caP
 	extraFQDNs = append(extraFQDNs, fmt.Sprintf("kubernetes.kube-system.svc"))
 	extraFQDNs = append(extraFQDNs, fmt.Sprintf("kubernetes.kube-system.svc.%s", clusterDomain))
 
+	// subjectAltNames returns the DNS names and IP addresses of the certificates of component
+	subjectAltNames := func(component string, fqdns []string, ips []net.IP) ([]string, []net.IP) {
+		extraComponentFQDNs, extraComponentIPs := options.extraSubjectAltNames(component)
+		return append(append([]string{}, fqdns...), extraComponentFQDNs...), append(append([]net.IP{}, ips...), extraComponentIPs...)
+	}
+	validity := options.leafValidity()
+
 	var (
 		caCertificate         *x509.Certificate
-		caPrivateKey          *rsa.PrivateKey
+		caPrivateKey          crypto.Signer
 		apiServerCertificate  *x509.Certificate
-		apiServerPrivateKey   *rsa.PrivateKey
+		apiServerPrivateKey   crypto.Signer
 		clientCertificate     *x509.Certificate
-		clientPrivateKey      *rsa.PrivateKey
+		clientPrivateKey      crypto.Signer
 		kubeConfigCertificate *x509.Certificate
-		kubeConfigPrivateKey  *rsa.PrivateKey
+		kubeConfigPrivateKey  crypto.Signer
 		etcdServerCertificate *x509.Certificate
-		etcdServerPrivateKey  *rsa.PrivateKey
+		etcdServerPrivateKey  crypto.Signer
 		etcdClientCertificate *x509.Certificate
-		etcdClientPrivateKey  *rsa.PrivateKey
+		etcdClientPrivateKey  crypto.Signer
 		etcdPeerCertPairs     []*PkiKeyCertPair
 	)
 	var group errgroup.Group
@@ -84,33 +197,40 @@ func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caP
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, err
 	}
+	chain, err := caPair.issuedChain()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
 
 	group.Go(func() (err error) {
-		apiServerCertificate, apiServerPrivateKey, err = createCertificate("apiserver", caCertificate, caPrivateKey, false, true, extraFQDNs, extraIPs, nil)
+		fqdns, ips := subjectAltNames("apiserver", extraFQDNs, extraIPs)
+		apiServerCertificate, apiServerPrivateKey, err = createCertificate(entropy, "apiserver", "apiserver", caCertificate, caPrivateKey, false, true, fqdns, ips, nil, validity, options)
 		return err
 	})
 
 	group.Go(func() (err error) {
 		organization := make([]string, 1)
 		organization[0] = "system:masters"
-		clientCertificate, clientPrivateKey, err = createCertificate("client", caCertificate, caPrivateKey, false, false, nil, nil, organization)
+		clientCertificate, clientPrivateKey, err = createCertificate(entropy, "client", "client", caCertificate, caPrivateKey, false, false, nil, nil, organization, validity, options)
 		return err
 	})
 
 	group.Go(func() (err error) {
 		organization := make([]string, 1)
 		organization[0] = "system:masters"
-		kubeConfigCertificate, kubeConfigPrivateKey, err = createCertificate("client", caCertificate, caPrivateKey, false, false, nil, nil, organization)
+		kubeConfigCertificate, kubeConfigPrivateKey, err = createCertificate(entropy, "kubeconfig", "client", caCertificate, caPrivateKey, false, false, nil, nil, organization, validity, options)
 		return err
 	})
 
 	group.Go(func() (err error) {
-		etcdServerCertificate, etcdServerPrivateKey, err = createCertificate("etcdserver", caCertificate, caPrivateKey, true, true, nil, extraIPs, nil)
+		fqdns, ips := subjectAltNames("etcdserver", nil, extraIPs)
+		etcdServerCertificate, etcdServerPrivateKey, err = createCertificate(entropy, "etcdserver", "etcdserver", caCertificate, caPrivateKey, true, true, fqdns, ips, nil, validity, options)
 		return err
 	})
 
 	group.Go(func() (err error) {
-		etcdClientCertificate, etcdClientPrivateKey, err = createCertificate("etcdclient", caCertificate, caPrivateKey, true, false, nil, extraIPs, nil)
+		fqdns, ips := subjectAltNames("etcdclient", nil, extraIPs)
+		etcdClientCertificate, etcdClientPrivateKey, err = createCertificate(entropy, "etcdclient", "etcdclient", caCertificate, caPrivateKey, true, false, fqdns, ips, nil, validity, options)
 		return err
 	})
 
@@ -118,9 +238,13 @@ func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caP
 	for i := 0; i < masterCount; i++ {
 		i := i
 		group.Go(func() (err error) {
-			etcdPeerCertificate, etcdPeerPrivateKey, err := createCertificate("etcdpeer", caCertificate, caPrivateKey, true, false, nil, extraIPs, nil)
-			etcdPeerCertPairs[i] = &PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdPeerCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(etcdPeerPrivateKey))}
-			return err
+			fqdns, ips := subjectAltNames("etcdpeer", nil, extraIPs)
+			etcdPeerCertificate, etcdPeerPrivateKey, err := createCertificate(entropy, fmt.Sprintf("etcdpeer%d", i), "etcdpeer", caCertificate, caPrivateKey, true, false, fqdns, ips, nil, validity, options)
+			if err != nil {
+				return err
+			}
+			etcdPeerCertPairs[i] = &PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdPeerCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(etcdPeerPrivateKey))}
+			return nil
 		})
 	}
 
@@ -128,30 +252,57 @@ func CreatePki(extraFQDNs []string, extraIPs []net.IP, clusterDomain string, caP
 		return nil, nil, nil, nil, nil, nil, err
 	}
 
-	return &PkiKeyCertPair{CertificatePem: string(certificateToPem(apiServerCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(apiServerPrivateKey))},
-		&PkiKeyCertPair{CertificatePem: string(certificateToPem(clientCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(clientPrivateKey))},
-		&PkiKeyCertPair{CertificatePem: string(certificateToPem(kubeConfigCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(kubeConfigPrivateKey))},
-		&PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdServerCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(etcdServerPrivateKey))},
-		&PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdClientCertificate.Raw)), PrivateKeyPem: string(privateKeyToPem(etcdClientPrivateKey))},
+	return &PkiKeyCertPair{CertificatePem: string(certificateToPem(apiServerCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(apiServerPrivateKey))},
+		&PkiKeyCertPair{CertificatePem: string(certificateToPem(clientCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(clientPrivateKey))},
+		&PkiKeyCertPair{CertificatePem: string(certificateToPem(kubeConfigCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(kubeConfigPrivateKey))},
+		&PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdServerCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(etcdServerPrivateKey))},
+		&PkiKeyCertPair{CertificatePem: string(certificateToPem(etcdClientCertificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(etcdClientPrivateKey))},
 		etcdPeerCertPairs,
 		nil
 }
 
-func createCertificate(commonName string, caCertificate *x509.Certificate, caPrivateKey *rsa.PrivateKey, isEtcd bool, isServer bool, extraFQDNs []string, extraIPs []net.IP, organization []string) (*x509.Certificate, *rsa.PrivateKey, error) {
+// CreateClientCertificate signs a client certificate for commonName and organization with the CA of caPair,
+// valid for validity from now, with a key of the algorithm and size of options, drawing randomness from entropy
+func CreateClientCertificate(caPair *PkiKeyCertPair, commonName string, organization []string, validity time.Duration, entropy *Entropy, options *PkiOptions) (*PkiKeyCertPair, error) {
+	caCertificate, err := pemToCertificate(caPair.CertificatePem)
+	if err != nil {
+		return nil, err
+	}
+	caPrivateKey, err := pemToKey(caPair.PrivateKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := caPair.issuedChain()
+	if err != nil {
+		return nil, err
+	}
+	certificate, privateKey, err := createCertificate(entropy, commonName, commonName, caCertificate, caPrivateKey, false, false, nil, nil, organization, validity, options)
+	if err != nil {
+		return nil, err
+	}
+	return &PkiKeyCertPair{CertificatePem: string(certificateToPem(certificate.Raw)) + chain, PrivateKeyPem: string(privateKeyToPem(privateKey))}, nil
+}
+
+// createCertificate creates a certificate and its private key, reading randomness from the entropy streams for name
+func createCertificate(entropy *Entropy, name string, commonName string, caCertificate *x509.Certificate, caPrivateKey crypto.Signer, isEtcd bool, isServer bool, extraFQDNs []string, extraIPs []net.IP, organization []string, validity time.Duration, options *PkiOptions) (*x509.Certificate, crypto.Signer, error) {
 	var err error
 
 	isCA := (caCertificate == nil)
 
-	now := time.Now()
+	now := entropy.Now()
 
 	template := x509.Certificate{
 		Subject:   pkix.Name{CommonName: commonName},
 		NotBefore: now,
-		NotAfter:  now.Add(ValidityDuration),
+		NotAfter:  now.Add(validity),
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
 		BasicConstraintsValid: true,
 	}
+	// ECDSA keys can only sign, RSA keys can also encipher the keys of TLS key exchanges
+	if options.keyAlgorithm() == PkiKeyAlgorithmRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
 
 	if organization != nil {
 		template.Subject.Organization = organization
@@ -172,6 +323,7 @@ func createCertificate(commonName string, caCertificate *x509.Certificate, caPri
 			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
 			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
 		}
+		template.DNSNames = extraFQDNs
 	} else if isServer {
 		template.DNSNames = extraFQDNs
 		template.IPAddresses = extraIPs
@@ -181,14 +333,18 @@ func createCertificate(commonName string, caCertificate *x509.Certificate, caPri
 	}
 
 	snMax := new(big.Int).Lsh(big.NewInt(1), 128)
-	template.SerialNumber, err = rand.Int(rand.Reader, snMax)
+	random := entropy.Reader(name + "/certificate")
+	template.SerialNumber, err = rand.Int(random, snMax)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	privateKey, _ := rsa.GenerateKey(rand.Reader, PkiKeySize)
+	privateKey, err := createPrivateKey(entropy, name+"/key", options)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	var privateKeyToUse *rsa.PrivateKey
+	var privateKeyToUse crypto.Signer
 	var certificateToUse *x509.Certificate
 	if !isCA {
 		privateKeyToUse = caPrivateKey
@@ -197,8 +353,11 @@ func createCertificate(commonName string, caCertificate *x509.Certificate, caPri
 		privateKeyToUse = privateKey
 		certificateToUse = &template
 	}
+	if _, ok := privateKeyToUse.(*ecdsa.PrivateKey); ok && entropy.IsDeterministic() {
+		return nil, nil, errors.Errorf("cannot sign certificate %s deterministically with an ECDSA key, ECDSA signatures are randomized", name)
+	}
 
-	certDerBytes, err := x509.CreateCertificate(rand.Reader, &template, certificateToUse, &privateKey.PublicKey, privateKeyToUse)
+	certDerBytes, err := x509.CreateCertificate(random, &template, certificateToUse, privateKey.Public(), privateKeyToUse)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -211,6 +370,30 @@ func createCertificate(commonName string, caCertificate *x509.Certificate, caPri
 	return certificate, privateKey, nil
 }
 
+// createPrivateKey generates a private key of the algorithm and size of options for the secret identified by name
+func createPrivateKey(entropy *Entropy, name string, options *PkiOptions) (crypto.Signer, error) {
+	switch options.keyAlgorithm() {
+	case PkiKeyAlgorithmRSA:
+		return entropy.GenerateRSAKey(name, options.keySize())
+	case PkiKeyAlgorithmECDSA:
+		var curve elliptic.Curve
+		switch options.keySize() {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			return nil, errors.Errorf("unsupported ECDSA key size %d, must be 256 or 384", options.keySize())
+		}
+		if entropy.IsDeterministic() {
+			return nil, errors.Errorf("cannot generate the ECDSA key %s deterministically", name)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	default:
+		return nil, errors.Errorf("unsupported key algorithm %q, must be %s or %s", options.keyAlgorithm(), PkiKeyAlgorithmRSA, PkiKeyAlgorithmECDSA)
+	}
+}
+
 func certificateToPem(derBytes []byte) []byte {
 	pemBlock := &pem.Block{
 		Type:  "CERTIFICATE",
@@ -222,10 +405,26 @@ func certificateToPem(derBytes []byte) []byte {
 	return pemBuffer.Bytes()
 }
 
-func privateKeyToPem(privateKey *rsa.PrivateKey) []byte {
-	pemBlock := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+// privateKeyToPem encodes RSA keys in PKCS #1 and ECDSA keys in SEC 1 form, the forms openssl writes them in
+func privateKeyToPem(privateKey crypto.Signer) []byte {
+	var pemBlock *pem.Block
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		pemBlock = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil
+		}
+		pemBlock = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		return nil
 	}
 	pemBuffer := bytes.Buffer{}
 	pem.Encode(&pemBuffer, pemBlock)
@@ -233,6 +432,72 @@ func privateKeyToPem(privateKey *rsa.PrivateKey) []byte {
 	return pemBuffer.Bytes()
 }
 
+// ValidateCAKeyCertPair checks that the private key of caPair matches its certificate, that the certificate is a
+// CA valid at now that can sign certificates, and that its CertificateChainPem, if any, chains it to a root CA
+// whose path length constraints allow it
+func ValidateCAKeyCertPair(caPair *PkiKeyCertPair, now time.Time) error {
+	caCertificate, err := pemToCertificate(caPair.CertificatePem)
+	if err != nil {
+		return errors.Wrap(err, "parsing the CA certificate")
+	}
+	caPrivateKey, err := pemToKey(caPair.PrivateKeyPem)
+	if err != nil {
+		return errors.Wrap(err, "parsing the CA private key")
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(caPrivateKey.Public())
+	if err != nil {
+		return errors.Wrap(err, "marshalling the public key of the CA private key")
+	}
+	certificatePublicKey, err := x509.MarshalPKIXPublicKey(caCertificate.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, "marshalling the public key of the CA certificate")
+	}
+	if !bytes.Equal(publicKey, certificatePublicKey) {
+		return errors.Errorf("the CA private key does not match the public key of the CA certificate %s", caCertificate.Subject)
+	}
+	if err = validateCACertificate(caCertificate, now); err != nil {
+		return err
+	}
+	if caCertificate.KeyUsage != 0 && caCertificate.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.Errorf("the key usage of the CA certificate %s does not allow signing certificates", caCertificate.Subject)
+	}
+	if caPair.CertificateChainPem == "" {
+		return nil
+	}
+
+	chain, err := pemToCertificates(caPair.CertificateChainPem)
+	if err != nil {
+		return errors.Wrap(err, "parsing the CA certificate chain")
+	}
+	issued := caCertificate
+	for i, issuer := range chain {
+		if err = validateCACertificate(issuer, now); err != nil {
+			return errors.Wrap(err, "validating the CA certificate chain")
+		}
+		if err = issued.CheckSignatureFrom(issuer); err != nil {
+			return errors.Wrapf(err, "%s in the CA certificate chain is not the issuer of %s", issuer.Subject, issued.Subject)
+		}
+		// the path length constraint counts the intermediate CAs below issuer, here the CA and the i certificates
+		// of the chain before issuer
+		if issuer.MaxPathLen >= 0 && issuer.MaxPathLen < i+1 {
+			return errors.Errorf("the path length constraint %d of %s in the CA certificate chain does not allow it to sign %d intermediate CAs", issuer.MaxPathLen, issuer.Subject, i+1)
+		}
+		issued = issuer
+	}
+	return nil
+}
+
+// validateCACertificate checks that certificate is a CA certificate valid at now
+func validateCACertificate(certificate *x509.Certificate, now time.Time) error {
+	if !certificate.BasicConstraintsValid || !certificate.IsCA {
+		return errors.Errorf("%s is not a CA certificate", certificate.Subject)
+	}
+	if now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+		return errors.Errorf("%s is only valid from %s to %s", certificate.Subject, certificate.NotBefore.Format(time.RFC3339), certificate.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
 func pemToCertificate(raw string) (*x509.Certificate, error) {
 	cpb, _ := pem.Decode([]byte(raw))
 	if cpb == nil {
@@ -241,10 +506,53 @@ func pemToCertificate(raw string) (*x509.Certificate, error) {
 	return x509.ParseCertificate(cpb.Bytes)
 }
 
-func pemToKey(raw string) (*rsa.PrivateKey, error) {
+// pemToCertificates parses all the certificates of raw, in order
+func pemToCertificates(raw string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(raw)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, errors.Errorf("unexpected PEM block type %q in a certificate chain", block.Type)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("The raw pem is not a valid PEM formatted block")
+	}
+	return certificates, nil
+}
+
+// pemToKey parses PKCS #1 RSA, SEC 1 ECDSA and PKCS #8 private keys
+func pemToKey(raw string) (crypto.Signer, error) {
 	kpb, _ := pem.Decode([]byte(raw))
 	if kpb == nil {
 		return nil, errors.New("The raw pem is not a valid PEM formatted block")
 	}
-	return x509.ParsePKCS1PrivateKey(kpb.Bytes)
+	switch kpb.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(kpb.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(kpb.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(kpb.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf("unsupported PKCS #8 private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, errors.Errorf("unsupported private key PEM block type %q", kpb.Type)
+	}
 }
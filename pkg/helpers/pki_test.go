@@ -4,11 +4,21 @@
 package helpers
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
+	"math/big"
 	"net"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestCreateCertificateWithOrganisation(t *testing.T) {
@@ -17,11 +27,11 @@ func TestCreateCertificateWithOrganisation(t *testing.T) {
 
 	var (
 		caCertificate   *x509.Certificate
-		caPrivateKey    *rsa.PrivateKey
+		caPrivateKey    crypto.Signer
 		testCertificate *x509.Certificate
 	)
 
-	caCertificate, caPrivateKey, err = createCertificate("ca", nil, nil, false, false, nil, nil, nil)
+	caCertificate, caPrivateKey, err = createCertificate(nil, "ca", "ca", nil, nil, false, false, nil, nil, nil, ValidityDuration, nil)
 	if err != nil {
 		t.Fatalf("failed to generate certificate: %s", err)
 	}
@@ -38,7 +48,7 @@ func TestCreateCertificateWithOrganisation(t *testing.T) {
 
 	organization := make([]string, 1)
 	organization[0] = "system:masters"
-	testCertificate, _, err = createCertificate("client", caCertificate, caPrivateKey, false, false, nil, nil, organization)
+	testCertificate, _, err = createCertificate(nil, "client", "client", caCertificate, caPrivateKey, false, false, nil, nil, organization, ValidityDuration, nil)
 	if err != nil {
 		t.Fatalf("failed to generate certificate: %s", err)
 	}
@@ -56,11 +66,11 @@ func TestCreateCertificateWithoutOrganisation(t *testing.T) {
 
 	var (
 		caCertificate   *x509.Certificate
-		caPrivateKey    *rsa.PrivateKey
+		caPrivateKey    crypto.Signer
 		testCertificate *x509.Certificate
 	)
 
-	caCertificate, caPrivateKey, err = createCertificate("ca", nil, nil, false, false, nil, nil, nil)
+	caCertificate, caPrivateKey, err = createCertificate(nil, "ca", "ca", nil, nil, false, false, nil, nil, nil, ValidityDuration, nil)
 	if err != nil {
 		t.Fatalf("failed to generate certificate: %s", err)
 	}
@@ -75,7 +85,7 @@ func TestCreateCertificateWithoutOrganisation(t *testing.T) {
 		t.Fatalf("failed to generate certificate: %s", err)
 	}
 
-	testCertificate, _, err = createCertificate("client", caCertificate, caPrivateKey, false, false, nil, nil, nil)
+	testCertificate, _, err = createCertificate(nil, "client", "client", caCertificate, caPrivateKey, false, false, nil, nil, nil, ValidityDuration, nil)
 	if err != nil {
 		t.Fatalf("failed to generate certificate: %s", err)
 	}
@@ -92,7 +102,7 @@ func TestSubjectAltNameInCert(t *testing.T) {
 	roots := x509.NewCertPool()
 
 	// Prepare CA and add it to certificate store.
-	caCertificate, caPrivateKey, err := createCertificate("ca", nil, nil, false, false, nil, nil, nil)
+	caCertificate, caPrivateKey, err := createCertificate(nil, "ca", "ca", nil, nil, false, false, nil, nil, nil, ValidityDuration, nil)
 	if err != nil {
 		t.Fatalf("failed to generate CA certificates: %s.", err)
 	}
@@ -159,7 +169,7 @@ func TestSubjectAltNameInCert(t *testing.T) {
 	masterExtraFQDNs := append(formattedDNSPrefixes, SubjectAltNames...)
 	expectedDNSNames := []string{"santest.australiaeast.cloudapp.azure.com", "santest.australiasoutheast.cloudapp.azure.com", "santest.brazilsouth.cloudapp.azure.com", "santest.canadacentral.cloudapp.azure.com", "santest.canadaeast.cloudapp.azure.com", "santest.centralindia.cloudapp.azure.com", "santest.centralus.cloudapp.azure.com", "santest.centraluseuap.cloudapp.azure.com", "santest.chinaeast.cloudapp.chinacloudapi.cn", "santest.chinaeast2.cloudapp.chinacloudapi.cn", "santest.chinanorth.cloudapp.chinacloudapi.cn", "santest.chinanorth2.cloudapp.chinacloudapi.cn", "santest.eastasia.cloudapp.azure.com", "santest.eastus.cloudapp.azure.com", "santest.eastus2.cloudapp.azure.com", "santest.eastus2euap.cloudapp.azure.com", "santest.japaneast.cloudapp.azure.com", "santest.japanwest.cloudapp.azure.com", "santest.koreacentral.cloudapp.azure.com", "santest.koreasouth.cloudapp.azure.com", "santest.northcentralus.cloudapp.azure.com", "santest.northeurope.cloudapp.azure.com", "santest.southcentralus.cloudapp.azure.com", "santest.southeastasia.cloudapp.azure.com", "santest.southindia.cloudapp.azure.com", "santest.uksouth.cloudapp.azure.com", "santest.ukwest.cloudapp.azure.com", "santest.westcentralus.cloudapp.azure.com", "santest.westeurope.cloudapp.azure.com", "santest.westindia.cloudapp.azure.com", "santest.westus.cloudapp.azure.com", "santest.westus2.cloudapp.azure.com", "santest.chinaeast.cloudapp.chinacloudapi.cn", "santest.chinanorth.cloudapp.chinacloudapi.cn", "santest.germanycentral.cloudapp.microsoftazure.de", "santest.germanynortheast.cloudapp.microsoftazure.de", "santest.usgovvirginia.cloudapp.usgovcloudapi.net", "santest.usgoviowa.cloudapp.usgovcloudapi.net", "santest.usgovarizona.cloudapp.usgovcloudapi.net", "santest.usgovtexas.cloudapp.usgovcloudapi.net", "santest.francecentral.cloudapp.azure.com", "santest.mydomain.com", "santest2.testdomain.net", "kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc.cluster.local", "kubernetes.kube-system", "kubernetes.kube-system.svc", "kubernetes.kube-system.svc.cluster.local"}
 
-	apiServerPair, _, _, _, _, _, err := CreatePki(masterExtraFQDNs, extraIPs, "cluster.local", caPair, 1)
+	apiServerPair, _, _, _, _, _, err := CreatePki(masterExtraFQDNs, extraIPs, "cluster.local", caPair, 1, nil, nil)
 
 	if err != nil {
 		t.Fatalf("failed to generate certificates: %s.", err)
@@ -196,7 +206,7 @@ func TestSubjectAltNameInCert(t *testing.T) {
 	masterExtraFQDNs = append(formattedDNSPrefixes, SubjectAltNames...)
 	expectedDNSNames = []string{"santest.australiaeast.cloudapp.azure.com", "santest.australiasoutheast.cloudapp.azure.com", "santest.brazilsouth.cloudapp.azure.com", "santest.canadacentral.cloudapp.azure.com", "santest.canadaeast.cloudapp.azure.com", "santest.centralindia.cloudapp.azure.com", "santest.centralus.cloudapp.azure.com", "santest.centraluseuap.cloudapp.azure.com", "santest.chinaeast.cloudapp.chinacloudapi.cn", "santest.chinaeast2.cloudapp.chinacloudapi.cn", "santest.chinanorth.cloudapp.chinacloudapi.cn", "santest.chinanorth2.cloudapp.chinacloudapi.cn", "santest.eastasia.cloudapp.azure.com", "santest.eastus.cloudapp.azure.com", "santest.eastus2.cloudapp.azure.com", "santest.eastus2euap.cloudapp.azure.com", "santest.japaneast.cloudapp.azure.com", "santest.japanwest.cloudapp.azure.com", "santest.koreacentral.cloudapp.azure.com", "santest.koreasouth.cloudapp.azure.com", "santest.northcentralus.cloudapp.azure.com", "santest.northeurope.cloudapp.azure.com", "santest.southcentralus.cloudapp.azure.com", "santest.southeastasia.cloudapp.azure.com", "santest.southindia.cloudapp.azure.com", "santest.uksouth.cloudapp.azure.com", "santest.ukwest.cloudapp.azure.com", "santest.westcentralus.cloudapp.azure.com", "santest.westeurope.cloudapp.azure.com", "santest.westindia.cloudapp.azure.com", "santest.westus.cloudapp.azure.com", "santest.westus2.cloudapp.azure.com", "santest.chinaeast.cloudapp.chinacloudapi.cn", "santest.chinanorth.cloudapp.chinacloudapi.cn", "santest.germanycentral.cloudapp.microsoftazure.de", "santest.germanynortheast.cloudapp.microsoftazure.de", "santest.usgovvirginia.cloudapp.usgovcloudapi.net", "santest.usgoviowa.cloudapp.usgovcloudapi.net", "santest.usgovarizona.cloudapp.usgovcloudapi.net", "santest.usgovtexas.cloudapp.usgovcloudapi.net", "santest.francecentral.cloudapp.azure.com", "kubernetes", "kubernetes.default", "kubernetes.default.svc", "kubernetes.default.svc.cluster.local", "kubernetes.kube-system", "kubernetes.kube-system.svc", "kubernetes.kube-system.svc.cluster.local"}
 
-	apiServerPair, _, _, _, _, _, err = CreatePki(masterExtraFQDNs, extraIPs, "cluster.local", caPair, 1)
+	apiServerPair, _, _, _, _, _, err = CreatePki(masterExtraFQDNs, extraIPs, "cluster.local", caPair, 1, nil, nil)
 
 	if err != nil {
 		t.Fatalf("failed to generate certificates: %s.", err)
@@ -230,8 +240,406 @@ func TestSubjectAltNameInCert(t *testing.T) {
 
 func TestCreatePkiKeyCertPair(t *testing.T) {
 	subject := "foosubject"
-	_, err := CreatePkiKeyCertPair(subject)
+	_, err := CreatePkiKeyCertPair(subject, nil)
 	if err != nil {
 		t.Errorf("unexpected error thrown while executing CreatePkiKeyCertPair : %s", err.Error())
 	}
 }
+
+func TestCreatePkiDeterministic(t *testing.T) {
+	create := func() (*PkiKeyCertPair, []*PkiKeyCertPair) {
+		entropy := NewDeterministicEntropy([]byte("seed"))
+		caPair, err := CreatePkiKeyCertPair("ca", entropy)
+		if err != nil {
+			t.Fatalf("unexpected error creating CA: %s", err)
+		}
+		apiServerPair, clientPair, kubeConfigPair, etcdServerPair, etcdClientPair, etcdPeerPairs, err := CreatePki([]string{"santest.mydomain.com"}, []net.IP{net.ParseIP("10.240.255.5")}, "cluster.local", caPair, 2, entropy, nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating PKI: %s", err)
+		}
+		return caPair, append([]*PkiKeyCertPair{apiServerPair, clientPair, kubeConfigPair, etcdServerPair, etcdClientPair}, etcdPeerPairs...)
+	}
+
+	caPair, pairs := create()
+	caPairAgain, pairsAgain := create()
+	if diff := cmp.Diff(caPair, caPairAgain); diff != "" {
+		t.Errorf("expected the same CA for the same seed (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(pairs, pairsAgain); diff != "" {
+		t.Errorf("expected the same certificates for the same seed (-want +got):\n%s", diff)
+	}
+
+	// the client and kubeconfig pairs share a common name and the test key but not a certificate
+	if pairs[1].CertificatePem == pairs[2].CertificatePem {
+		t.Errorf("expected the client and kubeconfig pairs to have different certificates")
+	}
+	// so do the etcd peer pairs of different masters
+	if pairs[5].CertificatePem == pairs[6].CertificatePem {
+		t.Errorf("expected the etcd peer pairs to have different certificates")
+	}
+
+	cert, err := pemToCertificate(pairs[0].CertificatePem)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate: %s", err)
+	}
+	if !cert.NotBefore.Equal(DeterministicEpoch) {
+		t.Errorf("expected the certificate to be valid from %s, got %s", DeterministicEpoch, cert.NotBefore)
+	}
+}
+
+func TestCreateClientCertificate(t *testing.T) {
+	caPair, err := CreatePkiKeyCertPair("ca", nil)
+	if err != nil {
+		t.Fatalf("failed to generate the CA: %s", err)
+	}
+	clientPair, err := CreateClientCertificate(caPair, "alice", []string{"devs"}, 8*time.Hour, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to generate the client certificate: %s", err)
+	}
+
+	certificate, err := pemToCertificate(clientPair.CertificatePem)
+	if err != nil {
+		t.Fatalf("failed to parse the client certificate: %s", err)
+	}
+	if certificate.Subject.CommonName != "alice" || !cmp.Equal(certificate.Subject.Organization, []string{"devs"}) {
+		t.Errorf("unexpected client certificate subject %v", certificate.Subject)
+	}
+	if validity := certificate.NotAfter.Sub(certificate.NotBefore); validity != 8*time.Hour {
+		t.Errorf("expected the client certificate to be valid for 8h, got %s", validity)
+	}
+	if !cmp.Equal(certificate.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("expected a client authentication certificate, got extended key usages %v", certificate.ExtKeyUsage)
+	}
+
+	caCertificate, err := pemToCertificate(caPair.CertificatePem)
+	if err != nil {
+		t.Fatalf("failed to parse the CA: %s", err)
+	}
+	if err = certificate.CheckSignatureFrom(caCertificate); err != nil {
+		t.Errorf("expected the client certificate to be signed by the CA: %s", err)
+	}
+
+	if _, err = CreateClientCertificate(&PkiKeyCertPair{CertificatePem: caPair.CertificatePem}, "alice", nil, time.Hour, nil, nil); err == nil {
+		t.Errorf("expected an error signing a client certificate without the CA private key")
+	}
+}
+
+func TestCreatePkiWithOptions(t *testing.T) {
+	options := &PkiOptions{
+		KeyAlgorithm: PkiKeyAlgorithmECDSA,
+		KeySize:      256,
+		CAValidity:   2 * 365 * 24 * time.Hour,
+		LeafValidity: 365 * 24 * time.Hour,
+		ExtraSubjectAltNames: map[string][]string{
+			"apiserver": {"api.mydomain.com", "10.0.0.100"},
+			"etcdpeer":  {"etcd.mydomain.com"},
+		},
+	}
+	caPair, err := CreatePkiKeyCertPairWithOptions("ca", nil, options)
+	if err != nil {
+		t.Fatalf("unexpected error creating the CA: %s", err)
+	}
+	apiServerPair, clientPair, _, _, _, etcdPeerPairs, err := CreatePki([]string{"santest.mydomain.com"}, []net.IP{net.ParseIP("10.240.255.5")}, "cluster.local", caPair, 1, nil, options)
+	if err != nil {
+		t.Fatalf("unexpected error creating the PKI: %s", err)
+	}
+
+	caCertificate, err := pemToCertificate(caPair.CertificatePem)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the CA: %s", err)
+	}
+	if validity := caCertificate.NotAfter.Sub(caCertificate.NotBefore); validity != options.CAValidity {
+		t.Errorf("expected the CA to be valid for %s, got %s", options.CAValidity, validity)
+	}
+
+	for name, pair := range map[string]*PkiKeyCertPair{"ca": caPair, "apiserver": apiServerPair, "client": clientPair, "etcdpeer": etcdPeerPairs[0]} {
+		certificate, err := pemToCertificate(pair.CertificatePem)
+		if err != nil {
+			t.Fatalf("unexpected error parsing the %s certificate: %s", name, err)
+		}
+		if certificate.PublicKeyAlgorithm != x509.ECDSA || certificate.SignatureAlgorithm != x509.ECDSAWithSHA256 {
+			t.Errorf("expected the %s certificate to have an ECDSA key and signature, got %s and %s", name, certificate.PublicKeyAlgorithm, certificate.SignatureAlgorithm)
+		}
+		if certificate.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+			t.Errorf("expected the ECDSA %s certificate not to allow key encipherment", name)
+		}
+		privateKey, err := pemToKey(pair.PrivateKeyPem)
+		if err != nil {
+			t.Fatalf("unexpected error parsing the %s private key: %s", name, err)
+		}
+		if _, ok := privateKey.(*ecdsa.PrivateKey); !ok {
+			t.Errorf("expected an ECDSA %s private key, got %T", name, privateKey)
+		}
+		if name == "ca" {
+			continue
+		}
+		if err = certificate.CheckSignatureFrom(caCertificate); err != nil {
+			t.Errorf("expected the %s certificate to be signed by the CA: %s", name, err)
+		}
+		if validity := certificate.NotAfter.Sub(certificate.NotBefore); validity != options.LeafValidity {
+			t.Errorf("expected the %s certificate to be valid for %s, got %s", name, options.LeafValidity, validity)
+		}
+	}
+
+	apiServerCertificate, _ := pemToCertificate(apiServerPair.CertificatePem)
+	if err = apiServerCertificate.VerifyHostname("api.mydomain.com"); err != nil {
+		t.Errorf("expected the extra DNS name in the apiserver certificate: %s", err)
+	}
+	if err = apiServerCertificate.VerifyHostname("10.0.0.100"); err != nil {
+		t.Errorf("expected the extra IP address in the apiserver certificate: %s", err)
+	}
+	if err = apiServerCertificate.VerifyHostname("santest.mydomain.com"); err != nil {
+		t.Errorf("expected the master FQDN to remain in the apiserver certificate: %s", err)
+	}
+	etcdPeerCertificate, _ := pemToCertificate(etcdPeerPairs[0].CertificatePem)
+	if !cmp.Equal(etcdPeerCertificate.DNSNames, []string{"etcd.mydomain.com"}) {
+		t.Errorf("expected the extra DNS name in the etcd peer certificate, got %v", etcdPeerCertificate.DNSNames)
+	}
+	if err = etcdPeerCertificate.VerifyHostname("10.240.255.5"); err != nil {
+		t.Errorf("expected the master IP address to remain in the etcd peer certificate: %s", err)
+	}
+}
+
+func TestCreatePrivateKey(t *testing.T) {
+	cases := []struct {
+		name      string
+		options   *PkiOptions
+		entropy   *Entropy
+		expected  func(crypto.Signer) bool
+		expectErr bool
+	}{
+		{
+			name:     "defaults",
+			expected: func(k crypto.Signer) bool { key, ok := k.(*rsa.PrivateKey); return ok && key.N.BitLen() == PkiKeySize },
+		},
+		{
+			name:     "RSA-3072",
+			options:  &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmRSA, KeySize: 3072},
+			expected: func(k crypto.Signer) bool { key, ok := k.(*rsa.PrivateKey); return ok && key.N.BitLen() == 3072 },
+		},
+		{
+			name:    "ECDSA defaults to P-256",
+			options: &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmECDSA},
+			expected: func(k crypto.Signer) bool {
+				key, ok := k.(*ecdsa.PrivateKey)
+				return ok && key.Curve == elliptic.P256()
+			},
+		},
+		{
+			name:    "ECDSA P-384",
+			options: &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmECDSA, KeySize: 384},
+			expected: func(k crypto.Signer) bool {
+				key, ok := k.(*ecdsa.PrivateKey)
+				return ok && key.Curve == elliptic.P384()
+			},
+		},
+		{
+			name:      "unsupported curve",
+			options:   &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmECDSA, KeySize: 521},
+			expectErr: true,
+		},
+		{
+			name:      "unsupported algorithm",
+			options:   &PkiOptions{KeyAlgorithm: "DSA"},
+			expectErr: true,
+		},
+		{
+			name:      "deterministic ECDSA",
+			options:   &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmECDSA},
+			entropy:   NewDeterministicEntropy([]byte("seed")),
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		key, err := createPrivateKey(c.entropy, "key", c.options)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error creating the private key", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error creating the private key: %s", c.name, err)
+			continue
+		}
+		if !c.expected(key) {
+			t.Errorf("%s: unexpected private key %T", c.name, key)
+		}
+	}
+}
+
+func TestPemToKey(t *testing.T) {
+	rsaKey, err := createPrivateKey(nil, "rsa", &PkiOptions{KeySize: 2048})
+	if err != nil {
+		t.Fatalf("unexpected error creating the RSA key: %s", err)
+	}
+	ecdsaKey, err := createPrivateKey(nil, "ecdsa", &PkiOptions{KeyAlgorithm: PkiKeyAlgorithmECDSA})
+	if err != nil {
+		t.Fatalf("unexpected error creating the ECDSA key: %s", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatalf("unexpected error marshalling the PKCS #8 key: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		pem       string
+		expected  crypto.Signer
+		expectErr bool
+	}{
+		{"RSA", string(privateKeyToPem(rsaKey)), rsaKey, false},
+		{"ECDSA", string(privateKeyToPem(ecdsaKey)), ecdsaKey, false},
+		{"PKCS #8", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})), ecdsaKey, false},
+		{"unsupported block", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pkcs8})), nil, true},
+		{"not PEM", "key", nil, true},
+	}
+	for _, c := range cases {
+		key, err := pemToKey(c.pem)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error parsing the private key", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error parsing the private key: %s", c.name, err)
+			continue
+		}
+		expected, err := x509.MarshalPKIXPublicKey(c.expected.Public())
+		if err != nil {
+			t.Fatalf("%s: unexpected error marshalling the public key: %s", c.name, err)
+		}
+		actual, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatalf("%s: unexpected error marshalling the parsed public key: %s", c.name, err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s: expected the parsed private key to match", c.name)
+		}
+	}
+}
+
+// createTestCA creates a CA certificate for commonName signed by issuer, or self-signed if issuer is nil, with the
+// given path length constraint, -1 for none
+func createTestCA(t *testing.T, commonName string, issuer *PkiKeyCertPair, maxPathLen int, notAfter time.Time) *PkiKeyCertPair {
+	privateKey, err := createPrivateKey(nil, commonName, &PkiOptions{KeySize: 2048})
+	if err != nil {
+		t.Fatalf("failed to generate the %s key: %s", commonName, err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}
+	parent, signer := template, privateKey
+	var chain string
+	if issuer != nil {
+		if parent, err = pemToCertificate(issuer.CertificatePem); err != nil {
+			t.Fatalf("failed to parse the issuer of %s: %s", commonName, err)
+		}
+		if signer, err = pemToKey(issuer.PrivateKeyPem); err != nil {
+			t.Fatalf("failed to parse the issuer key of %s: %s", commonName, err)
+		}
+		chain = issuer.CertificatePem + issuer.CertificateChainPem
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, privateKey.Public(), signer)
+	if err != nil {
+		t.Fatalf("failed to create the %s certificate: %s", commonName, err)
+	}
+	return &PkiKeyCertPair{CertificatePem: string(certificateToPem(der)), PrivateKeyPem: string(privateKeyToPem(privateKey)), CertificateChainPem: chain}
+}
+
+func TestValidateCAKeyCertPair(t *testing.T) {
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := createTestCA(t, "root", nil, 2, notAfter)
+	intermediate := createTestCA(t, "intermediate", root, 1, notAfter)
+	issuing := createTestCA(t, "issuing", intermediate, 0, notAfter)
+	shallowRoot := createTestCA(t, "shallow root", nil, 1, notAfter)
+	unconstrained := createTestCA(t, "unconstrained", shallowRoot, -1, notAfter)
+	constrainedRoot := createTestCA(t, "constrained root", nil, 0, notAfter)
+	expiredRoot := createTestCA(t, "expired root", nil, -1, time.Now().Add(-time.Minute))
+	other := createTestCA(t, "other", nil, -1, notAfter)
+
+	leaf, err := CreateClientCertificate(root, "leaf", nil, time.Hour, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create the leaf certificate: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		caPair    *PkiKeyCertPair
+		expectErr bool
+	}{
+		{"root", root, false},
+		{"intermediate", intermediate, false},
+		{"intermediate issued by an intermediate", issuing, false},
+		{"intermediate without its chain", &PkiKeyCertPair{CertificatePem: intermediate.CertificatePem, PrivateKeyPem: intermediate.PrivateKeyPem}, false},
+		{"mismatched key", &PkiKeyCertPair{CertificatePem: root.CertificatePem, PrivateKeyPem: other.PrivateKeyPem}, true},
+		{"not a CA", &PkiKeyCertPair{CertificatePem: leaf.CertificatePem, PrivateKeyPem: leaf.PrivateKeyPem}, true},
+		{"expired", expiredRoot, true},
+		{"chain of the wrong issuer", &PkiKeyCertPair{CertificatePem: intermediate.CertificatePem, PrivateKeyPem: intermediate.PrivateKeyPem, CertificateChainPem: other.CertificatePem}, true},
+		{"chain out of order", &PkiKeyCertPair{CertificatePem: issuing.CertificatePem, PrivateKeyPem: issuing.PrivateKeyPem, CertificateChainPem: root.CertificatePem + intermediate.CertificatePem}, true},
+		{"path length of the issuer exceeded", createTestCA(t, "below issuing", issuing, -1, notAfter), true},
+		{"path length of the root exceeded", createTestCA(t, "intermediate of a constrained root", constrainedRoot, -1, notAfter), true},
+		{"path length of the root exceeded further up the chain", createTestCA(t, "too deep", unconstrained, -1, notAfter), true},
+		{"chain that is not PEM", &PkiKeyCertPair{CertificatePem: intermediate.CertificatePem, PrivateKeyPem: intermediate.PrivateKeyPem, CertificateChainPem: "root"}, true},
+	}
+	for _, c := range cases {
+		err := ValidateCAKeyCertPair(c.caPair, time.Now())
+		if c.expectErr && err == nil {
+			t.Errorf("%s: expected an error validating the CA", c.name)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("%s: unexpected error validating the CA: %s", c.name, err)
+		}
+	}
+}
+
+func TestCreatePkiWithIntermediateCA(t *testing.T) {
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := createTestCA(t, "root", nil, -1, notAfter)
+	intermediate := createTestCA(t, "intermediate", root, 0, notAfter)
+
+	apiServerPair, clientPair, kubeConfigPair, etcdServerPair, etcdClientPair, etcdPeerPairs, err := CreatePki([]string{"santest.mydomain.com"}, []net.IP{net.ParseIP("10.240.255.5")}, "cluster.local", intermediate, 1, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating the PKI: %s", err)
+	}
+	adminPair, err := CreateClientCertificate(intermediate, "admin", []string{"system:masters"}, time.Hour, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating the client certificate: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(root.CertificatePem))
+	pairs := map[string]*PkiKeyCertPair{
+		"apiserver":  apiServerPair,
+		"client":     clientPair,
+		"kubeconfig": kubeConfigPair,
+		"etcdserver": etcdServerPair,
+		"etcdclient": etcdClientPair,
+		"etcdpeer":   etcdPeerPairs[0],
+		"admin":      adminPair,
+	}
+	for name, pair := range pairs {
+		chain, err := pemToCertificates(pair.CertificatePem)
+		if err != nil {
+			t.Fatalf("unexpected error parsing the %s certificate chain: %s", name, err)
+		}
+		if len(chain) != 3 || chain[1].Subject.CommonName != "intermediate" || chain[2].Subject.CommonName != "root" {
+			t.Errorf("expected the %s certificate to be followed by the intermediate and root CAs, got %d certificates", name, len(chain))
+			continue
+		}
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(chain[1])
+		if _, err = chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+			t.Errorf("expected the %s certificate to chain to the root CA: %s", name, err)
+		}
+	}
+}
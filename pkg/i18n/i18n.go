@@ -37,6 +37,21 @@ func loadSystemLanguage() string {
 func LoadTranslations() (*gotext.Locale, error) {
 	lang := loadSystemLanguage()
 	SetLanguage(lang)
+	return LoadLanguage(lang)
+}
+
+// IsSupportedLanguage returns whether there are translations for language, such as de_DE.
+func IsSupportedLanguage(language string) bool {
+	return supportedTranslations[language]
+}
+
+// LoadLanguage loads the translation files of language, or of the default language if it is not
+// supported, without changing the program's current locale. Servers use it to translate each
+// request in the language of its client.
+func LoadLanguage(lang string) (*gotext.Locale, error) {
+	if !IsSupportedLanguage(lang) {
+		lang = defaultLanguage
+	}
 
 	dir := path.Join(defaultLocalDir, lang, defaultMessageDir)
 	translationFiles := []string{
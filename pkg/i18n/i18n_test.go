@@ -120,3 +120,22 @@ func TestTranslationsError(t *testing.T) {
 	e = translator.NErrorf("There is %d error in the api model", "There are %d errors in the api model", 3, 3)
 	Expect(e.Error()).Should(Equal("There are 3 errors in the api model"))
 }
+
+func TestLoadLanguage(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(IsSupportedLanguage("de_DE")).Should(BeTrue())
+	Expect(IsSupportedLanguage("de-DE")).Should(BeFalse())
+
+	SetLanguage("en_US")
+	// The unit test has only en_US translation files, unsupported languages fall back to them
+	l, err := LoadLanguage("ll_CC")
+	Expect(err).Should(BeNil())
+	Expect(l).ShouldNot(BeNil())
+
+	translator := &Translator{
+		Locale: l,
+	}
+	Expect(translator.T("Hello %s", "World")).Should(Equal("Hello World"))
+	Expect(GetLanguage()).Should(Equal("en_US"))
+}
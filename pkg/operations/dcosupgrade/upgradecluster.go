@@ -7,6 +7,7 @@ import (
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/pkg/armhelpers"
 	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
 	"github.com/gofrs/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -29,6 +30,8 @@ type UpgradeCluster struct {
 	Logger     *logrus.Entry
 	ClusterTopology
 	Client armhelpers.AKSEngineClient
+	// HostKeys verifies the host keys of the nodes upgraded over SSH
+	HostKeys ssh.HostKeyVerifier
 }
 
 // UpgradeCluster runs the workflow to upgrade a DCOS cluster.
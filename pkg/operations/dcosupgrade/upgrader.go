@@ -4,11 +4,11 @@
 package dcosupgrade
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
 
-	"github.com/Azure/aks-engine/pkg/operations"
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
 	"github.com/pkg/errors"
 )
 
@@ -33,23 +33,23 @@ if [ ! -e /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url ]; then
   cd /opt/azure/dcos/upgrade/NEW_VERSION/
   curl -s -O https://dcos-mirror.azureedge.net/dcos-NEW_DASHED_VERSION/dcos_generate_config.sh
   bash dcos_generate_config.sh --generate-node-upgrade-script CURR_VERSION | tee /opt/azure/dcos/upgrade/NEW_VERSION/log
-  process=\$(docker ps -f ancestor=nginx -q)
-  if [ ! -z "\$process" ]; then
-    echo "Stopping nginx service \$process"
-    docker kill \$process
+  process=$(docker ps -f ancestor=nginx -q)
+  if [ ! -z "$process" ]; then
+    echo "Stopping nginx service $process"
+    docker kill $process
   fi
-  echo "Starting nginx service \$process"
-  docker run -d -p 8086:80 -v \$PWD/genconf/serve:/usr/share/nginx/html:ro nginx
+  echo "Starting nginx service $process"
+  docker run -d -p 8086:80 -v $PWD/genconf/serve:/usr/share/nginx/html:ro nginx
   docker ps
-  grep 'Node upgrade script URL' /opt/azure/dcos/upgrade/NEW_VERSION/log | awk -F ': ' '{print \$2}' | cat > /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url
+  grep 'Node upgrade script URL' /opt/azure/dcos/upgrade/NEW_VERSION/log | awk -F ': ' '{print $2}' | cat > /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url
 fi
-upgrade_url=\$(cat /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url)
-if [ -z \${upgrade_url} ]; then
+upgrade_url=$(cat /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url)
+if [ -z ${upgrade_url} ]; then
   rm -f /opt/azure/dcos/upgrade/NEW_VERSION/upgrade_url
   echo "Failed to set up bootstrap node. Please try again"
   exit 1
 else
-  echo "Setting up bootstrap node completed. Node upgrade script URL \${upgrade_url}"
+  echo "Setting up bootstrap node completed. Node upgrade script URL ${upgrade_url}"
 fi
 `
 
@@ -78,25 +78,24 @@ func (uc *UpgradeCluster) runUpgrade() error {
 	bootstrapIP := cs.Properties.OrchestratorProfile.DcosConfig.BootstrapProfile.StaticIP
 	uc.Logger.Infof("masterDNS:%s masterCount:%d bootstrapIP:%s", masterDNS, masterCount, bootstrapIP)
 
-	// copy SSH key to master
-	uc.Logger.Infof("Copy SSH key to master")
-	out, err := operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, fmt.Sprintf("cat << END > .ssh/id_rsa_cluster\n%s\nEND\n", string(uc.SSHKey)))
+	// the masters are reached through the NAT rules of the master load balancer, the other nodes through the first master
+	pool, err := ssh.NewPool(ssh.Config{
+		User:        "azureuser",
+		PrivateKeys: [][]byte{uc.SSHKey},
+		HostKeys:    uc.HostKeys,
+	})
 	if err != nil {
-		uc.Logger.Errorf(out)
-		return err
-	}
-	// set SSH key permissions
-	out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, "chmod 600 .ssh/id_rsa_cluster")
-	if err != nil {
-		uc.Logger.Errorf(out)
 		return err
 	}
+	defer pool.Close()
+	master := ssh.Endpoint{Host: masterDNS, Port: 2200}
+
 	// upgrade bootstrap node
 	bootstrapScript := strings.Replace(bootstrapUpgradeScript, "CURR_VERSION", uc.CurrentDcosVersion, -1)
 	bootstrapScript = strings.Replace(bootstrapScript, "NEW_VERSION", newVersion, -1)
 	bootstrapScript = strings.Replace(bootstrapScript, "NEW_DASHED_VERSION", dashedVersion, -1)
 
-	upgradeScriptURL, err := uc.upgradeBootstrapNode(masterDNS, bootstrapIP, bootstrapScript)
+	upgradeScriptURL, err := uc.upgradeBootstrapNode(pool, master, bootstrapIP, bootstrapScript)
 	if err != nil {
 		return err
 	}
@@ -105,14 +104,14 @@ func (uc *UpgradeCluster) runUpgrade() error {
 	nodeScript = strings.Replace(nodeScript, "UPGRADE_SCRIPT_URL", upgradeScriptURL, -1)
 
 	// upgrade master nodes
-	if err = uc.upgradeMasterNodes(masterDNS, masterCount, nodeScript); err != nil {
+	if err = uc.upgradeMasterNodes(pool, masterDNS, masterCount, nodeScript); err != nil {
 		return err
 	}
 
 	// get the node list
-	out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, "curl -s http://localhost:1050/system/health/v1/nodes")
+	out, err := pool.Run(context.Background(), master, "curl -s http://localhost:1050/system/health/v1/nodes")
 	if err != nil {
-		uc.Logger.Errorf(out)
+		uc.Logger.Error(out)
 		return err
 	}
 	uc.Logger.Info(out)
@@ -121,37 +120,25 @@ func (uc *UpgradeCluster) runUpgrade() error {
 		return err
 	}
 	// upgrade agent nodes
-	return uc.upgradeAgentNodes(masterDNS, nodes)
+	return uc.upgradeAgentNodes(pool, master, nodes, nodeScript)
 }
 
-func (uc *UpgradeCluster) upgradeBootstrapNode(masterDNS, bootstrapIP, bootstrapScript string) (string, error) {
-	// copy bootstrap script to master
-	uc.Logger.Infof("Copy bootstrap script to master")
-	out, err := operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, fmt.Sprintf("cat << END > bootstrap_upgrade.sh\n%s\nEND\n", bootstrapScript))
-	if err != nil {
-		uc.Logger.Errorf(out)
-		return "", err
-	}
-	// set script permissions
-	out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, "chmod 755 ./bootstrap_upgrade.sh")
+func (uc *UpgradeCluster) upgradeBootstrapNode(pool *ssh.Pool, master ssh.Endpoint, bootstrapIP, bootstrapScript string) (string, error) {
+	ctx := context.Background()
+	bootstrap, err := pool.ClientVia(ssh.Endpoint{Host: bootstrapIP}, master)
 	if err != nil {
-		uc.Logger.Errorf(out)
 		return "", err
 	}
 	// copy bootstrap script to the bootstrap node
 	uc.Logger.Infof("Copy bootstrap script to the bootstrap node")
-	cmd := fmt.Sprintf("scp -i .ssh/id_rsa_cluster -o ConnectTimeout=30 -o StrictHostKeyChecking=no bootstrap_upgrade.sh %s:", bootstrapIP)
-	out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, cmd)
-	if err != nil {
-		uc.Logger.Errorf(out)
+	if err = bootstrap.WriteFile(ctx, "bootstrap_upgrade.sh", []byte(bootstrapScript), 0755); err != nil {
 		return "", err
 	}
 	// run bootstrap script
 	uc.Logger.Infof("Run bootstrap upgrade script")
-	cmd = fmt.Sprintf("ssh -i .ssh/id_rsa_cluster -o ConnectTimeout=30 -o StrictHostKeyChecking=no %s sudo ./bootstrap_upgrade.sh", bootstrapIP)
-	out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, cmd)
+	out, err := bootstrap.Run(ctx, "sudo ./bootstrap_upgrade.sh")
 	if err != nil {
-		uc.Logger.Errorf(out)
+		uc.Logger.Error(out)
 		return "", err
 	}
 	uc.Logger.Info(out)
@@ -171,77 +158,60 @@ func (uc *UpgradeCluster) upgradeBootstrapNode(masterDNS, bootstrapIP, bootstrap
 	return url, nil
 }
 
-func (uc *UpgradeCluster) upgradeMasterNodes(masterDNS string, masterCount int, nodeScript string) error {
-	// run master upgrade script
-	catCmd := fmt.Sprintf("cat << END > node_upgrade.sh\n%s\nEND\n", nodeScript)
+func (uc *UpgradeCluster) upgradeMasterNodes(pool *ssh.Pool, masterDNS string, masterCount int, nodeScript string) error {
 	for i := 0; i < masterCount; i++ {
 		uc.Logger.Infof("Upgrading master node #%d", i+1)
-		// check current version
-		out, err := operations.RemoteRun("azureuser", masterDNS, 2200+i, uc.SSHKey, "grep version /opt/mesosphere/etc/dcos-version.json | cut -d '\"' -f 4")
+		master, err := pool.ClientVia(ssh.Endpoint{Host: masterDNS, Port: 2200 + i})
 		if err != nil {
-			uc.Logger.Errorf(out)
 			return err
 		}
-		if strings.TrimSpace(out) == uc.ClusterTopology.DataModel.Properties.OrchestratorProfile.OrchestratorVersion {
-			uc.Logger.Infof("Master node is up-to-date. Skipping upgrade")
-			continue
-		}
-		// copy script to the node
-		out, err = operations.RemoteRun("azureuser", masterDNS, 2200+i, uc.SSHKey, catCmd)
-		if err != nil {
-			uc.Logger.Errorf(out)
+		if err = uc.upgradeNode(master, nodeScript); err != nil {
 			return err
 		}
-		// set script permissions
-		out, err = operations.RemoteRun("azureuser", masterDNS, 2200+i, uc.SSHKey, "chmod 755 ./node_upgrade.sh")
-		if err != nil {
-			uc.Logger.Errorf(out)
-			return err
-		}
-		// run the script
-		out, err = operations.RemoteRun("azureuser", masterDNS, 2200+i, uc.SSHKey, "sudo ./node_upgrade.sh")
-		if err != nil {
-			uc.Logger.Errorf(out)
-			return err
-		}
-		uc.Logger.Info(out)
 	}
 	return nil
 }
 
-func (uc *UpgradeCluster) upgradeAgentNodes(masterDNS string, nodes *healthReport) error {
+func (uc *UpgradeCluster) upgradeAgentNodes(pool *ssh.Pool, master ssh.Endpoint, nodes *healthReport, nodeScript string) error {
 	for _, node := range nodes.Nodes {
 		if node.Role == "master" {
 			continue
 		}
 		uc.Logger.Infof("Upgrading %s %s", node.Role, node.IP)
-
-		// check current version
-		cmd := fmt.Sprintf("ssh -i .ssh/id_rsa_cluster -o ConnectTimeout=30 -o StrictHostKeyChecking=no %s grep version /opt/mesosphere/etc/dcos-version.json | cut -d '\"' -f 4", node.IP)
-		out, err := operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, cmd)
+		agent, err := pool.ClientVia(ssh.Endpoint{Host: node.IP}, master)
 		if err != nil {
-			uc.Logger.Errorf(out)
 			return err
 		}
-		if strings.TrimSpace(out) == uc.ClusterTopology.DataModel.Properties.OrchestratorProfile.OrchestratorVersion {
-			uc.Logger.Infof("Agent node is up-to-date. Skipping upgrade")
-			continue
-		}
-		// copy script to the node
-		cmd = fmt.Sprintf("scp -i .ssh/id_rsa_cluster -o ConnectTimeout=30 -o StrictHostKeyChecking=no node_upgrade.sh %s:", node.IP)
-		out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, cmd)
-		if err != nil {
-			uc.Logger.Errorf(out)
-			return err
-		}
-		// run the script
-		cmd = fmt.Sprintf("ssh -i .ssh/id_rsa_cluster -o ConnectTimeout=30 -o StrictHostKeyChecking=no %s sudo ./node_upgrade.sh", node.IP)
-		out, err = operations.RemoteRun("azureuser", masterDNS, 2200, uc.SSHKey, cmd)
-		if err != nil {
-			uc.Logger.Errorf(out)
+		if err = uc.upgradeNode(agent, nodeScript); err != nil {
 			return err
 		}
-		uc.Logger.Info(out)
 	}
 	return nil
 }
+
+// upgradeNode runs the node upgrade script on a node that is not up-to-date
+func (uc *UpgradeCluster) upgradeNode(node *ssh.Client, nodeScript string) error {
+	ctx := context.Background()
+	// check current version
+	out, err := node.Run(ctx, "grep version /opt/mesosphere/etc/dcos-version.json | cut -d '\"' -f 4")
+	if err != nil {
+		uc.Logger.Error(out)
+		return err
+	}
+	if strings.TrimSpace(out) == uc.ClusterTopology.DataModel.Properties.OrchestratorProfile.OrchestratorVersion {
+		uc.Logger.Infof("Node is up-to-date. Skipping upgrade")
+		return nil
+	}
+	// copy script to the node
+	if err = node.WriteFile(ctx, "node_upgrade.sh", []byte(nodeScript), 0755); err != nil {
+		return err
+	}
+	// run the script
+	out, err = node.Run(ctx, "sudo ./node_upgrade.sh")
+	if err != nil {
+		uc.Logger.Error(out)
+		return err
+	}
+	uc.Logger.Info(out)
+	return nil
+}
@@ -2,5 +2,6 @@
 // Licensed under the MIT license.
 
 // Package operations provides methods to perform kubernetes-specific IaaS operations like cordon & drain, cluster upgrades,
-// remote SSH, and scaling of agentpools.
+// and scaling of agentpools. Package ssh runs commands on the cluster nodes, and package nodeexec runs them on many nodes
+// at once.
 package operations
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"sort"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+)
+
+// UpgradePlan lists the nodes an upgrade of a cluster would replace, and those already at the target version
+type UpgradePlan struct {
+	TargetVersion string            `json:"targetVersion"`
+	Masters       PoolUpgradePlan   `json:"masters"`
+	AgentPools    []PoolUpgradePlan `json:"agentPools,omitempty"`
+}
+
+// PoolUpgradePlan lists the nodes of a pool, or of a scale set, to upgrade and those already upgraded.
+// The nodes of scale sets already at the target version are not listed
+type PoolUpgradePlan struct {
+	Name      string   `json:"name"`
+	ScaleSet  bool     `json:"scaleSet,omitempty"`
+	ToUpgrade []string `json:"toUpgrade,omitempty"`
+	Upgraded  []string `json:"upgraded,omitempty"`
+}
+
+// PlanUpgrade queries ARM, and the Kubernetes API when a kubeconfig is given, for the nodes of the cluster
+// the upgrade to the version of DataModel would replace, without upgrading anything.
+func (uc *UpgradeCluster) PlanUpgrade(kubeConfig string) (*UpgradePlan, error) {
+	uc.resetTopology()
+	var kubeClient armhelpers.KubernetesClient
+	if kubeConfig != "" {
+		kubeClient = uc.getKubernetesClient(uc.Client, kubeConfig)
+	}
+
+	if err := uc.getClusterNodeStatus(kubeClient, uc.ResourceGroup); err != nil {
+		return nil, uc.Translator.Errorf("Error while querying ARM for resources: %+v", err)
+	}
+
+	plan := &UpgradePlan{
+		TargetVersion: uc.DataModel.Properties.OrchestratorProfile.OrchestratorVersion,
+		Masters: PoolUpgradePlan{
+			Name:      MasterPoolName,
+			ToUpgrade: vmNames(*uc.MasterVMs),
+			Upgraded:  vmNames(*uc.UpgradedMasterVMs),
+		},
+	}
+	for _, pool := range uc.AgentPools {
+		plan.AgentPools = append(plan.AgentPools, PoolUpgradePlan{
+			Name:      *pool.Name,
+			ToUpgrade: vmNames(*pool.AgentVMs),
+			Upgraded:  vmNames(*pool.UpgradedAgentVMs),
+		})
+	}
+	for _, scaleSet := range uc.AgentPoolScaleSetsToUpgrade {
+		pool := PoolUpgradePlan{Name: scaleSet.Name, ScaleSet: true}
+		for _, vm := range scaleSet.VMsToUpgrade {
+			pool.ToUpgrade = append(pool.ToUpgrade, vm.Name)
+		}
+		plan.AgentPools = append(plan.AgentPools, pool)
+	}
+	sort.Slice(plan.AgentPools, func(i, j int) bool {
+		return plan.AgentPools[i].Name < plan.AgentPools[j].Name
+	})
+	return plan, nil
+}
+
+func vmNames(vms []compute.VirtualMachine) []string {
+	var names []string
+	for _, vm := range vms {
+		names = append(names, *vm.Name)
+	}
+	sort.Strings(names)
+	return names
+}
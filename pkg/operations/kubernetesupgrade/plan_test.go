@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package kubernetesupgrade
+
+import (
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Planning the upgrade of a Kubernetes cluster", func() {
+	var (
+		uc         UpgradeCluster
+		mockClient armhelpers.MockAKSEngineClient
+	)
+
+	BeforeEach(func() {
+		mockClient = armhelpers.MockAKSEngineClient{MockKubernetesClient: &armhelpers.MockKubernetesClient{}}
+		uc = UpgradeCluster{
+			Translator: &i18n.Translator{},
+			Logger:     log.NewEntry(log.New()),
+			Client:     &mockClient,
+		}
+		uc.ResourceGroup = "TestRg"
+		uc.DataModel = api.CreateMockContainerService("testcluster", "1.14.5", 3, 2, false)
+		uc.NameSuffix = "12345678"
+		uc.AgentPoolsToUpgrade = map[string]bool{MasterPoolName: true, "agentpool1": true}
+	})
+
+	It("Should list the nodes to upgrade and those already upgraded", func() {
+		mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+			return []compute.VirtualMachine{
+				mockClient.MakeFakeVirtualMachine("k8s-master-12345678-1", "Kubernetes:1.14.5"),
+				mockClient.MakeFakeVirtualMachine("k8s-master-12345678-0", "Kubernetes:1.14.4"),
+				mockClient.MakeFakeVirtualMachine("k8s-master-12345678-2", "Kubernetes:1.14.4"),
+				mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:1.14.4"),
+				mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-1", "Kubernetes:1.14.5"),
+				mockClient.MakeFakeVirtualMachine("k8s-agentpool1-87654321-0", "Kubernetes:1.14.4"),
+			}
+		}
+		mockClient.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
+			name, location, capacity := "k8s-vmsspool-12345678-vmss", "eastus", int64(2)
+			return []compute.VirtualMachineScaleSet{
+				{
+					Name:                             &name,
+					Sku:                              &compute.Sku{Capacity: &capacity},
+					Location:                         &location,
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{},
+				},
+			}
+		}
+		mockClient.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+			return []compute.VirtualMachineScaleSetVM{
+				mockClient.MakeFakeVirtualMachineScaleSetVMWithGivenName("Kubernetes:1.14.4", "k8s-vmsspool-12345678-vmss000000"),
+				mockClient.MakeFakeVirtualMachineScaleSetVMWithGivenName("Kubernetes:1.14.5", "k8s-vmsspool-12345678-vmss000001"),
+			}
+		}
+
+		plan, err := uc.PlanUpgrade("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*plan).To(Equal(UpgradePlan{
+			TargetVersion: "1.14.5",
+			Masters: PoolUpgradePlan{
+				Name:      MasterPoolName,
+				ToUpgrade: []string{"k8s-master-12345678-0", "k8s-master-12345678-2"},
+				Upgraded:  []string{"k8s-master-12345678-1"},
+			},
+			AgentPools: []PoolUpgradePlan{
+				{
+					Name:      "agentpool1",
+					ToUpgrade: []string{"k8s-agentpool1-12345678-0"},
+					Upgraded:  []string{"k8s-agentpool1-12345678-1"},
+				},
+				{
+					Name:      "k8s-vmsspool-12345678-vmss",
+					ScaleSet:  true,
+					ToUpgrade: []string{"k8s-vmsspool-12345678-vmss000000"},
+				},
+			},
+		}))
+	})
+
+	It("Should return an error when failing to list VMs", func() {
+		mockClient.FailListVirtualMachines = true
+		_, err := uc.PlanUpgrade("")
+		Expect(err).To(MatchError("Error while querying ARM for resources: ListVirtualMachines failed"))
+	})
+})
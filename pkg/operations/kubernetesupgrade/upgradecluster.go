@@ -85,19 +85,8 @@ const MasterPoolName = "master"
 
 // UpgradeCluster runs the workflow to upgrade a Kubernetes cluster.
 func (uc *UpgradeCluster) UpgradeCluster(az armhelpers.AKSEngineClient, kubeConfig string, aksEngineVersion string) error {
-	uc.MasterVMs = &[]compute.VirtualMachine{}
-	uc.UpgradedMasterVMs = &[]compute.VirtualMachine{}
-	uc.AgentPools = make(map[string]*AgentPoolTopology)
-
-	var kubeClient armhelpers.KubernetesClient
-	if az != nil {
-		timeout := time.Duration(60) * time.Minute
-		k, err := az.GetKubernetesClient("", kubeConfig, interval, timeout)
-		if err != nil {
-			uc.Logger.Warnf("Failed to get a Kubernetes client: %v", err)
-		}
-		kubeClient = k
-	}
+	uc.resetTopology()
+	kubeClient := uc.getKubernetesClient(az, kubeConfig)
 
 	if err := uc.getClusterNodeStatus(kubeClient, uc.ResourceGroup); err != nil {
 		return uc.Translator.Errorf("Error while querying ARM for resources: %+v", err)
@@ -165,6 +154,27 @@ func (uc *UpgradeCluster) SetClusterAutoscalerReplicaCount(kubeClient armhelpers
 	return count, nil
 }
 
+func (uc *UpgradeCluster) resetTopology() {
+	uc.MasterVMs = &[]compute.VirtualMachine{}
+	uc.UpgradedMasterVMs = &[]compute.VirtualMachine{}
+	uc.AgentPools = make(map[string]*AgentPoolTopology)
+}
+
+// getKubernetesClient returns a client of the Kubernetes API of the cluster, or nil if there is none,
+// in which case node versions are only read from the VM tags
+func (uc *UpgradeCluster) getKubernetesClient(az armhelpers.AKSEngineClient, kubeConfig string) armhelpers.KubernetesClient {
+	if az == nil {
+		return nil
+	}
+	timeout := time.Duration(60) * time.Minute
+	kubeClient, err := az.GetKubernetesClient("", kubeConfig, interval, timeout)
+	if err != nil {
+		uc.Logger.Warnf("Failed to get a Kubernetes client: %v", err)
+		return nil
+	}
+	return kubeClient
+}
+
 func (uc *UpgradeCluster) getUpgradeWorkflow(kubeConfig string, aksEngineVersion string) UpgradeWorkFlow {
 	if uc.UpgradeWorkFlow != nil {
 		return uc.UpgradeWorkFlow
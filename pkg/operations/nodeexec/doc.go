@@ -0,0 +1,7 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package nodeexec runs commands, scripts and file copies on a selected set of cluster nodes in parallel. Nodes are
+// selected by pool, role, operating system or labels, and an Executor runs a task on each of them with bounded
+// concurrency and retries, reporting the result of every node and the failures together.
+package nodeexec
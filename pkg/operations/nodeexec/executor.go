@@ -0,0 +1,164 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultConcurrency is the number of nodes an Executor runs a task on at once by default
+const DefaultConcurrency = 10
+
+// Task is run on a node and returns its output
+type Task func(ctx context.Context, node Node) (string, error)
+
+// RetryPolicy is how many times a task is attempted on a node, and how long to wait between attempts
+type RetryPolicy struct {
+	// Attempts is the number of times a task is attempted, once if 0
+	Attempts int
+	// Backoff is the wait before the second attempt, doubled before each attempt after it
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts, if not 0
+	MaxBackoff time.Duration
+	// Retryable returns whether an error is worth retrying, any error is if nil
+	Retryable func(error) bool
+}
+
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := r.Backoff
+	for i := 1; i < attempt && (r.MaxBackoff == 0 || backoff < r.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return backoff
+}
+
+// Executor runs tasks on nodes in parallel
+type Executor struct {
+	// Concurrency is the number of nodes a task runs on at once, DefaultConcurrency if 0
+	Concurrency int
+	// Retry is the retry policy of the task on each node
+	Retry RetryPolicy
+	// FailFast stops starting the task on more nodes once it failed on one
+	FailFast bool
+}
+
+// Result is the result of a task on a node
+type Result struct {
+	Node Node
+	// Output is the output of the last attempt
+	Output string
+	// Err is the error of the last attempt
+	Err error
+	// Attempts is the number of times the task was attempted, 0 if it was not started
+	Attempts int
+	// Duration is the time spent on the node, including the waits between attempts
+	Duration time.Duration
+}
+
+// Results are the results of a task on each node, in the order of the nodes
+type Results []Result
+
+// Failed returns the results of the nodes the task failed on
+func (r Results) Failed() Results {
+	var failed Results
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an *Error of the nodes the task failed on, or nil if it succeeded on every node
+func (r Results) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return &Error{Failed: failed, Total: len(r)}
+}
+
+// Error is the error of a task that failed on some nodes
+type Error struct {
+	// Failed are the results of the nodes the task failed on
+	Failed Results
+	// Total is the number of nodes the task was run on
+	Total int
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Failed))
+	for _, result := range e.Failed {
+		messages = append(messages, fmt.Sprintf("%s: %s", result.Node.Name, result.Err))
+	}
+	return fmt.Sprintf("failed on %d of %d nodes: %s", len(e.Failed), e.Total, strings.Join(messages, "; "))
+}
+
+// Run runs task on each of the nodes and returns their results. The task is not started on more nodes once ctx is
+// done, or once it failed on a node when failing fast, and the nodes it was not started on have an error
+func (e *Executor) Run(ctx context.Context, nodes []Node, task Task) Results {
+	concurrency := e.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(Results, len(nodes))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		results[i].Node = node
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			results[i].Err = errors.Wrap(ctx.Err(), "not started")
+			continue
+		}
+		wg.Add(1)
+		go func(result *Result) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			e.run(ctx, task, result)
+			if result.Err != nil && e.FailFast {
+				cancel()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// run runs task on the node of result until it succeeds or is out of attempts
+func (e *Executor) run(ctx context.Context, task Task, result *Result) {
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+	attempts := e.Retry.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	for result.Attempts < attempts {
+		result.Attempts++
+		result.Output, result.Err = task(ctx, result.Node)
+		if result.Err == nil || result.Attempts == attempts || (e.Retry.Retryable != nil && !e.Retry.Retryable(result.Err)) {
+			return
+		}
+		select {
+		case <-time.After(e.Retry.backoff(result.Attempts)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func testNodes(names ...string) []Node {
+	nodes := make([]Node, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, Node{Name: name})
+	}
+	return nodes
+}
+
+func TestExecutorRun(t *testing.T) {
+	var running, maxRunning int32
+	task := func(ctx context.Context, node Node) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasPrefix(node.Name, "bad") {
+			return "oops", errors.New("exit status 1")
+		}
+		return "hello from " + node.Name, nil
+	}
+
+	e := &Executor{Concurrency: 2}
+	nodes := testNodes("node-0", "bad-1", "node-2", "bad-3", "node-4")
+	results := e.Run(context.Background(), nodes, task)
+	if max := atomic.LoadInt32(&maxRunning); max != 2 {
+		t.Errorf("expected the task to run on 2 nodes at once, ran on %d", max)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Node.Name != nodes[i].Name {
+			t.Errorf("expected the results in the order of the nodes, got %s at %d", result.Node.Name, i)
+		}
+		if result.Attempts != 1 {
+			t.Errorf("%s: expected 1 attempt, got %d", result.Node.Name, result.Attempts)
+		}
+	}
+	if results[2].Output != "hello from node-2" || results[2].Err != nil {
+		t.Errorf("unexpected result of node-2: %+v", results[2])
+	}
+	if failed := results.Failed(); len(failed) != 2 || failed[0].Node.Name != "bad-1" || failed[1].Node.Name != "bad-3" {
+		t.Errorf("unexpected failed results: %+v", failed)
+	}
+	err := results.Err()
+	expected := "failed on 2 of 5 nodes: bad-1: exit status 1; bad-3: exit status 1"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+	if _, ok := err.(*Error); !ok {
+		t.Errorf("expected an *Error, got %T", err)
+	}
+
+	results = e.Run(context.Background(), testNodes("node-0", "node-1"), task)
+	if err = results.Err(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestExecutorRetry(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	task := func(ctx context.Context, node Node) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[node.Name]++
+		switch {
+		case node.Name == "flaky" && attempts[node.Name] < 3:
+			return "", errors.New("connection reset")
+		case node.Name == "broken":
+			return "", errors.New("connection reset")
+		case node.Name == "fatal":
+			return "", errors.New("permission denied")
+		}
+		return "ok", nil
+	}
+
+	e := &Executor{
+		Retry: RetryPolicy{
+			Attempts:   3,
+			Backoff:    time.Millisecond,
+			MaxBackoff: 2 * time.Millisecond,
+			Retryable: func(err error) bool {
+				return !strings.Contains(err.Error(), "permission denied")
+			},
+		},
+	}
+	results := e.Run(context.Background(), testNodes("steady", "flaky", "broken", "fatal"), task)
+	expected := []struct {
+		attempts int
+		failed   bool
+	}{
+		{1, false},
+		{3, false},
+		{3, true},
+		{1, true},
+	}
+	for i, result := range results {
+		if result.Attempts != expected[i].attempts || (result.Err != nil) != expected[i].failed {
+			t.Errorf("%s: expected %d attempts and failed %t, got %d attempts and error %v", result.Node.Name, expected[i].attempts, expected[i].failed, result.Attempts, result.Err)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	r := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if backoff := r.backoff(attempt + 1); backoff != expected {
+			t.Errorf("expected a backoff of %s after attempt %d, got %s", expected, attempt+1, backoff)
+		}
+	}
+}
+
+func TestExecutorFailFast(t *testing.T) {
+	var started int32
+	task := func(ctx context.Context, node Node) (string, error) {
+		atomic.AddInt32(&started, 1)
+		if node.Name == "bad" {
+			return "", errors.New("exit status 1")
+		}
+		return "ok", nil
+	}
+	e := &Executor{Concurrency: 1, FailFast: true}
+	results := e.Run(context.Background(), testNodes("good", "bad", "skipped-0", "skipped-1"), task)
+	if started != 2 {
+		t.Errorf("expected the task to start on 2 nodes, started on %d", started)
+	}
+	for _, result := range results[2:] {
+		if result.Attempts != 0 || result.Err == nil || !strings.Contains(result.Err.Error(), "not started") {
+			t.Errorf("%s: expected the task not to be started, got %+v", result.Node.Name, result)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = (&Executor{}).Run(ctx, testNodes("node-0"), task)
+	if results[0].Attempts != 0 || errors.Cause(results[0].Err) != context.Canceled {
+		t.Errorf("expected the task not to be started once the context is done, got %+v", results[0])
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/armhelpers/utils"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// RoleMaster is the role of the master nodes
+	RoleMaster = "master"
+	// RoleAgent is the role of the agent nodes
+	RoleAgent = "agent"
+	// OSLinux is the operating system of the Linux nodes
+	OSLinux = "linux"
+	// OSWindows is the operating system of the Windows nodes
+	OSWindows = "windows"
+)
+
+// labels set on the nodes by aks-engine and by the kubelet
+const (
+	roleLabel       = "kubernetes.azure.com/role"
+	legacyRoleLabel = "kubernetes.io/role"
+	poolLabel       = "agentpool"
+	osLabel         = "kubernetes.io/os"
+	betaOSLabel     = "beta.kubernetes.io/os"
+)
+
+// Node is a cluster node a task runs on
+type Node struct {
+	// Name is the name of the node, which is also its host name
+	Name string
+	// Role is RoleMaster or RoleAgent
+	Role string
+	// OS is OSLinux or OSWindows
+	OS string
+	// Pool is the name of the agent pool of an agent node
+	Pool string
+	// Labels are the Kubernetes labels of the node
+	Labels map[string]string
+	// User is the user to log in to the node as over SSH, the user of the ssh.Pool if empty
+	User string
+}
+
+// FromKubernetesNodes returns the nodes of the Kubernetes nodes, with their role, operating system and pool read from
+// their labels. Nodes without a role label are masters if named like one
+func FromKubernetesNodes(kubernetesNodes []v1.Node) []Node {
+	nodes := make([]Node, 0, len(kubernetesNodes))
+	for _, kubernetesNode := range kubernetesNodes {
+		l := kubernetesNode.Labels
+		node := Node{
+			Name:   kubernetesNode.Name,
+			Role:   firstLabel(l, roleLabel, legacyRoleLabel),
+			OS:     firstLabel(l, osLabel, betaOSLabel),
+			Pool:   l[poolLabel],
+			Labels: l,
+		}
+		if node.Role == "" {
+			node.Role = RoleAgent
+			if strings.Contains(node.Name, "master") {
+				node.Role = RoleMaster
+			}
+		}
+		if node.OS == "" {
+			node.OS = OSLinux
+			if kubernetesNode.Status.NodeInfo.OperatingSystem != "" {
+				node.OS = kubernetesNode.Status.NodeInfo.OperatingSystem
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// tags set on the VMs and scale sets by aks-engine
+const (
+	poolNameTag           = "poolName"
+	resourceNameSuffixTag = "resourceNameSuffix"
+	masterPoolName        = "master"
+)
+
+// FromAzure returns the nodes of the cluster of cs deployed in resourceGroup, listing its VMs and the instances of its
+// scale sets, with the admin user of their operating system. VMs and scale sets are told apart from those of other
+// clusters in the resource group by the cluster ID aks-engine tags them with
+func FromAzure(ctx context.Context, client armhelpers.AKSEngineClient, resourceGroup string, cs *api.ContainerService) ([]Node, error) {
+	p := cs.Properties
+	if p.MasterProfile == nil {
+		return nil, errors.New("the api model has no masterProfile")
+	}
+	linuxUser, windowsUser := adminUsers(p)
+	clusterID := p.GetClusterID()
+	inCluster := func(tags map[string]*string) (pool string, ok bool) {
+		if tags[poolNameTag] == nil || tags[resourceNameSuffixTag] == nil {
+			return "", false
+		}
+		suffix := *tags[resourceNameSuffixTag]
+		// Windows VMs are tagged with the prefix of their names, the first 4 characters of the cluster ID
+		return *tags[poolNameTag], suffix == clusterID || len(clusterID) >= 4 && suffix == clusterID[:4]
+	}
+	newNode := func(name, pool string, windows bool) Node {
+		node := Node{Name: name, Role: RoleAgent, OS: OSLinux, Pool: pool, User: linuxUser}
+		if pool == masterPoolName {
+			node.Role, node.Pool = RoleMaster, ""
+		}
+		if windows {
+			node.OS, node.User = OSWindows, windowsUser
+		}
+		return node
+	}
+
+	var nodes []Node
+	for page, err := client.ListVirtualMachines(ctx, resourceGroup); page.NotDone(); err = page.Next() {
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing the VMs of resource group %s", resourceGroup)
+		}
+		for _, vm := range page.Values() {
+			pool, ok := inCluster(vm.Tags)
+			if !ok {
+				continue
+			}
+			name := *vm.Name
+			if vm.VirtualMachineProperties != nil && vm.OsProfile != nil && vm.OsProfile.ComputerName != nil {
+				name = *vm.OsProfile.ComputerName
+			}
+			windows := vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.OsDisk != nil && vm.StorageProfile.OsDisk.OsType == compute.Windows
+			nodes = append(nodes, newNode(name, pool, windows))
+		}
+	}
+	for page, err := client.ListVirtualMachineScaleSets(ctx, resourceGroup); page.NotDone(); err = page.NextWithContext(ctx) {
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing the scale sets of resource group %s", resourceGroup)
+		}
+		for _, scaleSet := range page.Values() {
+			pool, ok := inCluster(scaleSet.Tags)
+			if !ok {
+				continue
+			}
+			windows := scaleSet.VirtualMachineScaleSetProperties != nil && scaleSet.VirtualMachineProfile != nil &&
+				scaleSet.VirtualMachineProfile.OsProfile != nil && scaleSet.VirtualMachineProfile.OsProfile.WindowsConfiguration != nil
+			for instances, err := client.ListVirtualMachineScaleSetVMs(ctx, resourceGroup, *scaleSet.Name); instances.NotDone(); err = instances.NextWithContext(ctx) {
+				if err != nil {
+					return nil, errors.Wrapf(err, "listing the instances of scale set %s", *scaleSet.Name)
+				}
+				for _, instance := range instances.Values() {
+					if instance.VirtualMachineScaleSetVMProperties == nil || instance.OsProfile == nil || instance.OsProfile.ComputerName == nil {
+						continue
+					}
+					nodes = append(nodes, newNode(*instance.OsProfile.ComputerName, pool, windows))
+				}
+			}
+		}
+	}
+	// masters first, then the agents by pool, as FromContainerService orders them
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Role != nodes[j].Role {
+			return nodes[i].Role == RoleMaster
+		}
+		if nodes[i].Pool != nodes[j].Pool {
+			return nodes[i].Pool < nodes[j].Pool
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes, nil
+}
+
+// FromContainerService returns the nodes of a new cluster as named by its api model, with the admin user of their
+// operating system. Scale set instances are assumed to be numbered from 0 to the count of their pool, the nodes of a
+// cluster that has been scaled can differ, FromAzure lists them
+func FromContainerService(cs *api.ContainerService) ([]Node, error) {
+	p := cs.Properties
+	if p.MasterProfile == nil {
+		return nil, errors.New("the api model has no masterProfile")
+	}
+	linuxUser, windowsUser := adminUsers(p)
+
+	var nodes []Node
+	for i := 0; i < p.MasterProfile.Count; i++ {
+		name := p.GetMasterVMPrefix() + strconv.Itoa(i)
+		if p.MasterProfile.IsVirtualMachineScaleSets() {
+			name = scaleSetInstanceName(p.GetMasterVMPrefix()+"vmss", i)
+		}
+		nodes = append(nodes, Node{Name: name, Role: RoleMaster, OS: OSLinux, User: linuxUser})
+	}
+	for _, pool := range p.AgentPoolProfiles {
+		node := Node{Role: RoleAgent, OS: OSLinux, Pool: pool.Name, User: linuxUser}
+		if pool.IsWindows() {
+			node.OS, node.User = OSWindows, windowsUser
+		}
+		for i := 0; i < pool.Count; i++ {
+			if pool.IsVirtualMachineScaleSets() {
+				node.Name = scaleSetInstanceName(p.GetAgentVMPrefix(pool, p.GetAgentPoolIndexByName(pool.Name)), i)
+			} else {
+				name, err := utils.GetK8sVMName(p, pool, i)
+				if err != nil {
+					return nil, errors.Wrapf(err, "naming the nodes of agent pool %s", pool.Name)
+				}
+				node.Name = name
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// adminUsers returns the admin users of the Linux and of the Windows nodes of p
+func adminUsers(p *api.Properties) (linuxUser, windowsUser string) {
+	if p.LinuxProfile != nil {
+		linuxUser = p.LinuxProfile.AdminUsername
+	}
+	if p.WindowsProfile != nil {
+		windowsUser = p.WindowsProfile.AdminUsername
+	}
+	return linuxUser, windowsUser
+}
+
+// scaleSetInstanceName returns the computer name of the instance of a scale set, its prefix and its index in base 36 on
+// 6 digits
+func scaleSetInstanceName(prefix string, index int) string {
+	return prefix + fmt.Sprintf("%06s", strconv.FormatInt(int64(index), 36))
+}
+
+func firstLabel(l map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := l[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Selector selects nodes. A node is selected if it is in one of the Pools, has one of the Roles and one of the OS, has
+// a name matching one of the Names glob patterns, and matches Labels. Empty fields select every node
+type Selector struct {
+	Pools  []string
+	Roles  []string
+	OS     []string
+	Names  []string
+	Labels labels.Selector
+}
+
+// Validate returns an error if one of the Names patterns is malformed
+func (s Selector) Validate() error {
+	for _, pattern := range s.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Errorf("invalid node name pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector parses selector, a label selector in the form of kubectl --selector such as
+// "agentpool=pool1,kubernetes.io/os!=windows"
+func ParseLabelSelector(selector string) (labels.Selector, error) {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing the label selector %q", selector)
+	}
+	return s, nil
+}
+
+// Matches returns whether the selector selects node
+func (s Selector) Matches(node Node) bool {
+	if !matchesAny(s.Pools, node.Pool) || !matchesAny(s.Roles, node.Role) || !matchesAny(s.OS, node.OS) || !matchesName(s.Names, node.Name) {
+		return false
+	}
+	return s.Labels == nil || s.Labels.Matches(labels.Set(node.Labels))
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesName(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the nodes the selector selects, in order
+func (s Selector) Select(nodes []Node) []Node {
+	var selected []Node
+	for _, node := range nodes {
+		if s.Matches(node) {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,227 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func kubernetesNode(name string, labels map[string]string, operatingSystem string) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{OperatingSystem: operatingSystem}},
+	}
+}
+
+func TestFromKubernetesNodes(t *testing.T) {
+	masterLabels := map[string]string{"kubernetes.azure.com/role": "master", "kubernetes.io/os": "linux"}
+	linuxLabels := map[string]string{"kubernetes.io/role": "agent", "beta.kubernetes.io/os": "linux", "agentpool": "linuxpool"}
+	windowsLabels := map[string]string{"kubernetes.azure.com/role": "agent", "kubernetes.io/os": "windows", "agentpool": "winpool"}
+	nodes := FromKubernetesNodes([]v1.Node{
+		kubernetesNode("k8s-master-1234-0", masterLabels, ""),
+		kubernetesNode("k8s-linuxpool-1234-0", linuxLabels, ""),
+		kubernetesNode("1234k8s000", windowsLabels, ""),
+		kubernetesNode("k8s-master-1234-1", nil, ""),
+		kubernetesNode("1234k8s001", nil, "windows"),
+	})
+	expected := []Node{
+		{Name: "k8s-master-1234-0", Role: RoleMaster, OS: OSLinux, Labels: masterLabels},
+		{Name: "k8s-linuxpool-1234-0", Role: RoleAgent, OS: OSLinux, Pool: "linuxpool", Labels: linuxLabels},
+		{Name: "1234k8s000", Role: RoleAgent, OS: OSWindows, Pool: "winpool", Labels: windowsLabels},
+		{Name: "k8s-master-1234-1", Role: RoleMaster, OS: OSLinux},
+		{Name: "1234k8s001", Role: RoleAgent, OS: OSWindows},
+	}
+	if diff := cmp.Diff(expected, nodes); diff != "" {
+		t.Errorf("unexpected nodes (-want +got):\n%s", diff)
+	}
+}
+
+func TestFromContainerService(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.14.5", 2, 2, false)
+	cs.Properties.WindowsProfile = &api.WindowsProfile{AdminUsername: "azureadmin"}
+	cs.Properties.AgentPoolProfiles = append(cs.Properties.AgentPoolProfiles,
+		&api.AgentPoolProfile{Name: "vmsspool", Count: 2, OSType: api.Linux, AvailabilityProfile: api.VirtualMachineScaleSets},
+		&api.AgentPoolProfile{Name: "winpool", Count: 1, OSType: api.Windows, AvailabilityProfile: api.AvailabilitySet},
+	)
+	id := cs.Properties.GetClusterID()
+	nodes, err := FromContainerService(cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []Node{
+		{Name: "k8s-master-" + id + "-0", Role: RoleMaster, OS: OSLinux, User: "azureuser"},
+		{Name: "k8s-master-" + id + "-1", Role: RoleMaster, OS: OSLinux, User: "azureuser"},
+		{Name: "k8s-agentpool1-" + id + "-0", Role: RoleAgent, OS: OSLinux, Pool: "agentpool1", User: "azureuser"},
+		{Name: "k8s-agentpool1-" + id + "-1", Role: RoleAgent, OS: OSLinux, Pool: "agentpool1", User: "azureuser"},
+		{Name: "k8s-vmsspool-" + id + "-vmss000000", Role: RoleAgent, OS: OSLinux, Pool: "vmsspool", User: "azureuser"},
+		{Name: "k8s-vmsspool-" + id + "-vmss000001", Role: RoleAgent, OS: OSLinux, Pool: "vmsspool", User: "azureuser"},
+		{Name: id[:4] + "k8s020", Role: RoleAgent, OS: OSWindows, Pool: "winpool", User: "azureadmin"},
+	}
+	if diff := cmp.Diff(expected, nodes); diff != "" {
+		t.Errorf("unexpected nodes (-want +got):\n%s", diff)
+	}
+
+	cs.Properties.MasterProfile.AvailabilityProfile = api.VirtualMachineScaleSets
+	if nodes, err = FromContainerService(cs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if nodes[1].Name != "k8s-master-"+id+"-vmss000001" {
+		t.Errorf("unexpected name of the second master of a scale set: %s", nodes[1].Name)
+	}
+	if name := scaleSetInstanceName("k8s-pool-1234-vmss", 46); name != "k8s-pool-1234-vmss00001a" {
+		t.Errorf("expected instance 46 to be named in base 36, got %s", name)
+	}
+
+	cs.Properties.MasterProfile = nil
+	if _, err = FromContainerService(cs); err == nil {
+		t.Error("expected an error without a masterProfile")
+	}
+}
+
+func TestFromAzure(t *testing.T) {
+	cs := api.CreateMockContainerService("testcluster", "1.14.5", 1, 1, false)
+	cs.Properties.WindowsProfile = &api.WindowsProfile{AdminUsername: "azureadmin"}
+	id := cs.Properties.GetClusterID()
+	tags := func(pool, suffix string) map[string]*string {
+		return map[string]*string{"poolName": to.StringPtr(pool), "resourceNameSuffix": to.StringPtr(suffix)}
+	}
+	vm := func(name, pool, suffix string, osType compute.OperatingSystemTypes) compute.VirtualMachine {
+		return compute.VirtualMachine{
+			Name: to.StringPtr(name),
+			Tags: tags(pool, suffix),
+			VirtualMachineProperties: &compute.VirtualMachineProperties{
+				OsProfile:      &compute.OSProfile{ComputerName: to.StringPtr(name)},
+				StorageProfile: &compute.StorageProfile{OsDisk: &compute.OSDisk{OsType: osType}},
+			},
+		}
+	}
+
+	client := &armhelpers.MockAKSEngineClient{}
+	client.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+		return []compute.VirtualMachine{
+			// scaled down from 3 VMs, the second one is gone
+			vm("k8s-agentpool1-"+id+"-2", "agentpool1", id, compute.Linux),
+			vm("k8s-agentpool1-"+id+"-0", "agentpool1", id, compute.Linux),
+			vm("k8s-master-"+id+"-0", "master", id, compute.Linux),
+			vm(id[:4]+"k8s020", "winpool", id[:4], compute.Windows),
+			vm("k8s-agentpool1-87654321-0", "agentpool1", "87654321", compute.Linux),
+			{Name: to.StringPtr("jumpbox")},
+		}
+	}
+	client.FakeListVirtualMachineScaleSetsResult = func() []compute.VirtualMachineScaleSet {
+		return []compute.VirtualMachineScaleSet{
+			{Name: to.StringPtr("k8s-vmsspool-" + id + "-vmss"), Tags: tags("vmsspool", id)},
+		}
+	}
+	client.FakeListVirtualMachineScaleSetVMsResult = func() []compute.VirtualMachineScaleSetVM {
+		return []compute.VirtualMachineScaleSetVM{
+			client.MakeFakeVirtualMachineScaleSetVMWithGivenName("Kubernetes:1.14.5", "k8s-vmsspool-"+id+"-vmss00000c"),
+		}
+	}
+
+	nodes, err := FromAzure(context.Background(), client, "rg", cs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []Node{
+		{Name: "k8s-master-" + id + "-0", Role: RoleMaster, OS: OSLinux, User: "azureuser"},
+		{Name: "k8s-agentpool1-" + id + "-0", Role: RoleAgent, OS: OSLinux, Pool: "agentpool1", User: "azureuser"},
+		{Name: "k8s-agentpool1-" + id + "-2", Role: RoleAgent, OS: OSLinux, Pool: "agentpool1", User: "azureuser"},
+		{Name: "k8s-vmsspool-" + id + "-vmss00000c", Role: RoleAgent, OS: OSLinux, Pool: "vmsspool", User: "azureuser"},
+		{Name: id[:4] + "k8s020", Role: RoleAgent, OS: OSWindows, Pool: "winpool", User: "azureadmin"},
+	}
+	if diff := cmp.Diff(expected, nodes); diff != "" {
+		t.Errorf("unexpected nodes (-want +got):\n%s", diff)
+	}
+
+	client.FailListVirtualMachines = true
+	if _, err = FromAzure(context.Background(), client, "rg", cs); err == nil || err.Error() != "listing the VMs of resource group rg: ListVirtualMachines failed" {
+		t.Errorf("expected the error listing the VMs, got %v", err)
+	}
+}
+
+func TestSelector(t *testing.T) {
+	nodes := []Node{
+		{Name: "k8s-master-1234-0", Role: RoleMaster, OS: OSLinux, Labels: map[string]string{"kubernetes.io/os": "linux"}},
+		{Name: "k8s-pool1-1234-0", Role: RoleAgent, OS: OSLinux, Pool: "pool1", Labels: map[string]string{"agentpool": "pool1", "gpu": "true"}},
+		{Name: "k8s-pool2-1234-0", Role: RoleAgent, OS: OSLinux, Pool: "pool2", Labels: map[string]string{"agentpool": "pool2"}},
+		{Name: "1234k8s000", Role: RoleAgent, OS: OSWindows, Pool: "winpool", Labels: map[string]string{"agentpool": "winpool"}},
+	}
+	cases := []struct {
+		name     string
+		selector Selector
+		labels   string
+		expected []string
+	}{
+		{
+			name:     "everything",
+			expected: []string{"k8s-master-1234-0", "k8s-pool1-1234-0", "k8s-pool2-1234-0", "1234k8s000"},
+		},
+		{
+			name:     "by role",
+			selector: Selector{Roles: []string{RoleMaster}},
+			expected: []string{"k8s-master-1234-0"},
+		},
+		{
+			name:     "by pool",
+			selector: Selector{Pools: []string{"pool2", "WinPool"}},
+			expected: []string{"k8s-pool2-1234-0", "1234k8s000"},
+		},
+		{
+			name:     "by os",
+			selector: Selector{Roles: []string{RoleAgent}, OS: []string{OSLinux}},
+			expected: []string{"k8s-pool1-1234-0", "k8s-pool2-1234-0"},
+		},
+		{
+			name:     "by name",
+			selector: Selector{Names: []string{"k8s-pool?-*", "k8s-master-1234-0"}},
+			expected: []string{"k8s-master-1234-0", "k8s-pool1-1234-0", "k8s-pool2-1234-0"},
+		},
+		{
+			name:     "by label",
+			labels:   "gpu=true",
+			expected: []string{"k8s-pool1-1234-0"},
+		},
+		{
+			name:     "by label and pool",
+			selector: Selector{Pools: []string{"pool1"}},
+			labels:   "agentpool!=pool1",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if c.labels != "" {
+				s, err := ParseLabelSelector(c.labels)
+				if err != nil {
+					t.Fatalf("unexpected error parsing %q: %s", c.labels, err)
+				}
+				c.selector.Labels = s
+			}
+			var names []string
+			for _, node := range c.selector.Select(nodes) {
+				names = append(names, node.Name)
+			}
+			if diff := cmp.Diff(c.expected, names); diff != "" {
+				t.Errorf("unexpected nodes selected (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if err := (Selector{Names: []string{"k8s-[master"}}).Validate(); err == nil {
+		t.Error("expected an error validating a malformed node name pattern")
+	}
+	if _, err := ParseLabelSelector("agentpool in (pool1"); err == nil {
+		t.Error("expected an error parsing an invalid label selector")
+	}
+}
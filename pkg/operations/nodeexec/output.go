@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Output interleaves the output of tasks running on several nodes at once, line by line, each line prefixed with the
+// name of its node. It is safe for concurrent use
+type Output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewOutput returns an Output writing to w
+func NewOutput(w io.Writer) *Output {
+	return &Output{w: w}
+}
+
+// Writer returns a writer of the output of a node, which writes each complete line to the Output after prefix
+func (o *Output) Writer(prefix string) *PrefixWriter {
+	return &PrefixWriter{output: o, prefix: []byte(prefix)}
+}
+
+func (o *Output) write(b []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := o.w.Write(b)
+	return err
+}
+
+// PrefixWriter writes the lines written to it to an Output, see Output.Writer. Flush writes the last line if it is
+// not terminated
+type PrefixWriter struct {
+	output *Output
+	prefix []byte
+	line   []byte
+}
+
+// Write buffers p and writes the lines it completes
+func (w *PrefixWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.line[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.line = w.line[i+1:]
+	}
+}
+
+// Flush writes the unterminated last line, if any, terminating it
+func (w *PrefixWriter) Flush() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	line := append(w.line, '\n')
+	w.line = nil
+	return w.writeLine(line)
+}
+
+func (w *PrefixWriter) writeLine(line []byte) error {
+	b := make([]byte, 0, len(w.prefix)+len(line))
+	b = append(append(b, w.prefix...), line...)
+	return w.output.write(b)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	var b bytes.Buffer
+	output := NewOutput(&b)
+	node0 := output.Writer("node-0: ")
+	node1 := output.Writer("node-1: ")
+
+	node0.Write([]byte("hello "))
+	node1.Write([]byte("first\nsecond\nthi"))
+	node0.Write([]byte("world\n"))
+	node1.Write([]byte("rd"))
+	if err := node1.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %s", err)
+	}
+	if err := node0.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %s", err)
+	}
+
+	expected := "node-1: first\nnode-1: second\nnode-0: hello world\nnode-1: third\n"
+	if b.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, b.String())
+	}
+}
+
+func TestOutputConcurrentWriters(t *testing.T) {
+	var b bytes.Buffer
+	output := NewOutput(&b)
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"a: ", "b: ", "c: "} {
+		wg.Add(1)
+		go func(w *PrefixWriter) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				w.Write([]byte("line\n"))
+			}
+		}(output.Writer(prefix))
+	}
+	wg.Wait()
+	for _, line := range bytes.Split(bytes.TrimSuffix(b.Bytes(), []byte("\n")), []byte("\n")) {
+		if s := string(line); s != "a: line" && s != "b: line" && s != "c: line" {
+			t.Fatalf("unexpected interleaved line %q", s)
+		}
+	}
+}
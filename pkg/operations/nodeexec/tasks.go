@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
+	"github.com/pkg/errors"
+)
+
+// Command returns a task that runs command on the nodes over SSH, as root if sudo
+func Command(pool *ssh.Pool, command string, sudo bool) Task {
+	return func(ctx context.Context, node Node) (string, error) {
+		client, err := sshClient(pool, node, sudo)
+		if err != nil {
+			return "", err
+		}
+		out, err := client.CombinedOutput(ctx, command)
+		return string(out), err
+	}
+}
+
+// Script returns a task that runs script with bash on the nodes over SSH, as root if sudo, with args as its arguments.
+// The script is passed on the standard input of bash, it should not read its standard input
+func Script(pool *ssh.Pool, script []byte, args []string, sudo bool) Task {
+	command := "bash -s"
+	if len(args) > 0 {
+		quoted := make([]string, 0, len(args))
+		for _, arg := range args {
+			quoted = append(quoted, helpers.ShellQuote(arg))
+		}
+		command += " -- " + strings.Join(quoted, " ")
+	}
+	return func(ctx context.Context, node Node) (string, error) {
+		client, err := sshClient(pool, node, sudo)
+		if err != nil {
+			return "", err
+		}
+		var stdout, stderr bytes.Buffer
+		err = client.Stream(ctx, command, bytes.NewReader(script), &stdout, &stderr)
+		return stdout.String() + stderr.String(), err
+	}
+}
+
+// CopyFile returns a task that writes data to path on the nodes over SSH, with the mode permissions, as root if sudo
+func CopyFile(pool *ssh.Pool, data []byte, path string, mode os.FileMode, sudo bool) Task {
+	return func(ctx context.Context, node Node) (string, error) {
+		client, err := sshClient(pool, node, sudo)
+		if err != nil {
+			return "", err
+		}
+		return "", client.WriteFile(ctx, path, data, mode)
+	}
+}
+
+// FetchFiles returns a task that copies paths on the nodes over SSH to dir/<node>/, as root if sudo, such as to collect
+// their logs. The copy continues past the paths missing on a node, the error reports all of those that failed
+func FetchFiles(pool *ssh.Pool, paths []string, dir string, sudo bool) Task {
+	return func(ctx context.Context, node Node) (string, error) {
+		client, err := sshClient(pool, node, sudo)
+		if err != nil {
+			return "", err
+		}
+		nodeDir := filepath.Join(dir, node.Name)
+		if err = os.MkdirAll(nodeDir, 0755); err != nil {
+			return "", errors.Wrapf(err, "creating %s", nodeDir)
+		}
+		var failed []string
+		for _, path := range paths {
+			data, err := client.ReadFile(ctx, path)
+			if err == nil {
+				err = ioutil.WriteFile(filepath.Join(nodeDir, filepath.Base(path)), data, 0644)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return "", err
+				}
+				failed = append(failed, err.Error())
+			}
+		}
+		copied := fmt.Sprintf("copied %d of %d files to %s", len(paths)-len(failed), len(paths), nodeDir)
+		if len(failed) > 0 {
+			return copied, errors.New(strings.Join(failed, "; "))
+		}
+		return copied, nil
+	}
+}
+
+func sshClient(pool *ssh.Pool, node Node, sudo bool) (*ssh.Client, error) {
+	client, err := pool.Client(ssh.Endpoint{User: node.User, Host: node.Name})
+	if err != nil {
+		return nil, err
+	}
+	if sudo {
+		return client.Sudo(), nil
+	}
+	return client, nil
+}
+
+// PowerShellCommand returns the command that runs script with PowerShell on a Windows node over SSH, whatever the
+// default shell of its SSH server. The script is passed encoded, it needs no quoting
+func PowerShellCommand(script string) string {
+	utf16le := make([]byte, 0, 2*len(script))
+	for _, r := range utf16.Encode([]rune(script)) {
+		utf16le = append(utf16le, byte(r), byte(r>>8))
+	}
+	return "powershell.exe -NoLogo -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(utf16le)
+}
@@ -0,0 +1,16 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package nodeexec
+
+import (
+	"testing"
+)
+
+func TestPowerShellCommand(t *testing.T) {
+	// the UTF-16LE of the script, as powershell -EncodedCommand expects, in base64
+	expected := "powershell.exe -NoLogo -NoProfile -NonInteractive -EncodedCommand RwBlAHQALQBTAGUAcgB2AGkAYwBlACAAawB1AGIAZQBsAGUAdAAgAHwAIAAnAG8AawAnAA=="
+	if command := PowerShellCommand("Get-Service kubelet | 'ok'"); command != expected {
+		t.Errorf("expected %s, got %s", expected, command)
+	}
+}
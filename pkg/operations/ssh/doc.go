@@ -0,0 +1,7 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package ssh runs commands and copies files on cluster nodes over SSH. It verifies host keys against known_hosts files
+// or pinned keys, authenticates with private keys or an ssh-agent, reaches the nodes through chains of jump hosts, such
+// as the master load balancer or the jumpbox of a private cluster, and reuses connections across commands.
+package ssh
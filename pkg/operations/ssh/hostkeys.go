@@ -0,0 +1,341 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// HostKeyVerifier verifies the host keys of the hosts a Pool connects to
+type HostKeyVerifier interface {
+	// VerifyHostKey is the ssh.HostKeyCallback of the connections, hostname is the host:port dialed
+	VerifyHostKey(hostname string, remote net.Addr, key gossh.PublicKey) error
+	// HostKeyAlgorithms returns the algorithms of the keys known for hostname, for the host to present one of them, or
+	// nil to accept any algorithm
+	HostKeyAlgorithms(hostname string) []string
+}
+
+type insecureIgnoreHostKey struct{}
+
+// InsecureIgnoreHostKey returns a HostKeyVerifier that accepts any host key. It should only be used for throwaway
+// hosts, such as the clusters of end to end tests
+func InsecureIgnoreHostKey() HostKeyVerifier {
+	return insecureIgnoreHostKey{}
+}
+
+func (insecureIgnoreHostKey) VerifyHostKey(string, net.Addr, gossh.PublicKey) error {
+	return nil
+}
+
+func (insecureIgnoreHostKey) HostKeyAlgorithms(string) []string {
+	return nil
+}
+
+// PinnedHostKeys is a HostKeyVerifier that accepts the keys pinned for each host, keyed by host:port or by host for any
+// port. Hosts without pinned keys are refused
+type PinnedHostKeys map[string][]gossh.PublicKey
+
+// ParsePinnedHostKeys parses pins, the host keys in the authorized_keys format keyed by host:port or host
+func ParsePinnedHostKeys(pins map[string][]string) (PinnedHostKeys, error) {
+	keys := PinnedHostKeys{}
+	for host, authorizedKeys := range pins {
+		for _, authorizedKey := range authorizedKeys {
+			key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(authorizedKey))
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing the host key pinned for %s", host)
+			}
+			keys[host] = append(keys[host], key)
+		}
+	}
+	return keys, nil
+}
+
+func (p PinnedHostKeys) keys(hostname string) []gossh.PublicKey {
+	if keys, ok := p[hostname]; ok {
+		return keys
+	}
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		return p[host]
+	}
+	return nil
+}
+
+// VerifyHostKey accepts key if it is pinned for hostname
+func (p PinnedHostKeys) VerifyHostKey(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	keys := p.keys(hostname)
+	if len(keys) == 0 {
+		return errors.Errorf("no host key is pinned for %s, which presented %s %s", hostname, key.Type(), gossh.FingerprintSHA256(key))
+	}
+	for _, k := range keys {
+		if keysEqual(k, key) {
+			return nil
+		}
+	}
+	return errors.Errorf("the host key of %s, %s %s, is not one of the keys pinned for it", hostname, key.Type(), gossh.FingerprintSHA256(key))
+}
+
+// HostKeyAlgorithms returns the algorithms of the keys pinned for hostname
+func (p PinnedHostKeys) HostKeyAlgorithms(hostname string) []string {
+	return keyAlgorithms(p.keys(hostname))
+}
+
+type knownHostsEntry struct {
+	revoked  bool
+	patterns []string
+	key      gossh.PublicKey
+}
+
+// KnownHosts is a HostKeyVerifier of the hosts in a known_hosts file in the format of OpenSSH. Hosts are matched by
+// name, hashed name and wildcard pattern, and @revoked keys are refused. @cert-authority entries are ignored. Unknown
+// hosts are refused, or trusted on first use and added to the file, hashed, when accepting new hosts, like the accept-new
+// StrictHostKeyChecking of OpenSSH. A host known with a different key is always refused. It is safe for concurrent
+// use
+type KnownHosts struct {
+	path      string
+	acceptNew bool
+
+	mu      sync.Mutex
+	entries []knownHostsEntry
+}
+
+// LoadKnownHosts reads the known_hosts file at path, a missing file has no known hosts yet. An empty path keeps the
+// hosts accepted in memory, to verify that a host presents the same key on every connection
+func LoadKnownHosts(path string, acceptNew bool) (*KnownHosts, error) {
+	k := &KnownHosts{path: path, acceptNew: acceptNew}
+	if path == "" {
+		return k, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return k, nil
+		}
+		return nil, errors.Wrap(err, "reading the known hosts file")
+	}
+	if k.entries, err = parseKnownHosts(b); err != nil {
+		return nil, errors.Wrapf(err, "parsing the known hosts file %s", path)
+	}
+	return k, nil
+}
+
+func parseKnownHosts(b []byte) ([]knownHostsEntry, error) {
+	var entries []knownHostsEntry
+	for {
+		marker, patterns, key, _, rest, err := gossh.ParseKnownHosts(b)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		switch marker {
+		case "":
+			entries = append(entries, knownHostsEntry{patterns: patterns, key: key})
+		case "revoked":
+			entries = append(entries, knownHostsEntry{revoked: true, patterns: patterns, key: key})
+		}
+	}
+}
+
+// VerifyHostKey accepts key if it is the known key of hostname, or if hostname is unknown and new hosts are accepted
+func (k *KnownHosts) VerifyHostKey(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	host := knownHostsName(hostname)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var known []gossh.PublicKey
+	for _, entry := range k.entries {
+		if !entry.matches(host) {
+			continue
+		}
+		if entry.revoked {
+			if keysEqual(entry.key, key) {
+				return errors.Errorf("the host key of %s, %s %s, is revoked", hostname, key.Type(), gossh.FingerprintSHA256(key))
+			}
+			continue
+		}
+		known = append(known, entry.key)
+	}
+	for _, knownKey := range known {
+		if keysEqual(knownKey, key) {
+			return nil
+		}
+	}
+	if len(known) > 0 {
+		return errors.Errorf("the host key of %s, %s %s, does not match its known host key, it may be impersonated or have been reinstalled", hostname, key.Type(), gossh.FingerprintSHA256(key))
+	}
+	if !k.acceptNew {
+		return errors.Errorf("%s is not a known host, it presented %s %s", hostname, key.Type(), gossh.FingerprintSHA256(key))
+	}
+	return k.add(host, key)
+}
+
+// HostKeyAlgorithms returns the algorithms of the keys known for hostname
+func (k *KnownHosts) HostKeyAlgorithms(hostname string) []string {
+	host := knownHostsName(hostname)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var known []gossh.PublicKey
+	for _, entry := range k.entries {
+		if !entry.revoked && entry.matches(host) {
+			known = append(known, entry.key)
+		}
+	}
+	return keyAlgorithms(known)
+}
+
+// add adds the key of host to the known hosts, with the lock held
+func (k *KnownHosts) add(host string, key gossh.PublicKey) error {
+	if k.path != "" {
+		if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+			return errors.Wrap(err, "creating the directory of the known hosts file")
+		}
+		f, err := os.OpenFile(k.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return errors.Wrap(err, "opening the known hosts file")
+		}
+		hashed, err := hashKnownHost(host)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err = fmt.Fprintf(f, "%s %s\n", hashed, bytes.TrimSpace(gossh.MarshalAuthorizedKey(key))); err != nil {
+			f.Close()
+			return errors.Wrap(err, "adding to the known hosts file")
+		}
+		if err = f.Close(); err != nil {
+			return errors.Wrap(err, "adding to the known hosts file")
+		}
+	}
+	k.entries = append(k.entries, knownHostsEntry{patterns: []string{host}, key: key})
+	return nil
+}
+
+// matches returns whether host, in the known_hosts form, matches one of the patterns of the entry and none of its
+// negated patterns
+func (e *knownHostsEntry) matches(host string) bool {
+	matched := false
+	for _, pattern := range e.patterns {
+		switch {
+		case strings.HasPrefix(pattern, "|1|"):
+			if hashedHostMatches(pattern, host) {
+				matched = true
+			}
+		case strings.HasPrefix(pattern, "!"):
+			if wildcardMatch(pattern[1:], host) {
+				return false
+			}
+		default:
+			if wildcardMatch(pattern, host) {
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// knownHostsName returns the name of hostname, a host:port, in a known_hosts file: the host on the default port and
+// [host]:port on others
+func knownHostsName(hostname string) string {
+	host, port, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return hostname
+	}
+	if port == strconv.Itoa(DefaultPort) {
+		return host
+	}
+	return "[" + host + "]:" + port
+}
+
+// hashedHostMatches returns whether host is the name hashed in pattern, |1|salt|hash with the HMAC-SHA1 of the name
+func hashedHostMatches(pattern, host string) bool {
+	parts := strings.Split(pattern[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(hashHost(salt, host), hash)
+}
+
+func hashHost(salt []byte, host string) []byte {
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return mac.Sum(nil)
+}
+
+// hashKnownHost returns host, in the known_hosts form, hashed like OpenSSH does with HashKnownHosts
+func hashKnownHost(host string) (string, error) {
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Wrap(err, "generating the salt of the hashed host")
+	}
+	hash := hashHost(salt, host)
+	return "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(hash), nil
+}
+
+// wildcardMatch returns whether s matches pattern, in which * matches any run of characters and ? any one character
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func keysEqual(a, b gossh.PublicKey) bool {
+	return a.Type() == b.Type() && bytes.Equal(a.Marshal(), b.Marshal())
+}
+
+func keyAlgorithms(keys []gossh.PublicKey) []string {
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !seen[key.Type()] {
+			seen[key.Type()] = true
+			algorithms = append(algorithms, key.Type())
+		}
+	}
+	return algorithms
+}
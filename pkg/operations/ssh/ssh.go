@@ -0,0 +1,454 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	// DefaultPort is the port of the SSH servers of the cluster nodes
+	DefaultPort = 22
+	// DefaultDialTimeout bounds connecting to a host, including the SSH handshake
+	DefaultDialTimeout = 30 * time.Second
+)
+
+// Endpoint is an SSH server, and the user to log in to it as if not the user of the Config
+type Endpoint struct {
+	User string
+	Host string
+	Port int
+}
+
+// ParseEndpoint parses an endpoint in the [user@]host[:port] form, on DefaultPort if the port is omitted
+func ParseEndpoint(s string) (Endpoint, error) {
+	var e Endpoint
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		e.User, s = s[:i], s[i+1:]
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// no port
+		host, port = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), strconv.Itoa(DefaultPort)
+	}
+	if host == "" {
+		return Endpoint{}, errors.Errorf("%q has no host", s)
+	}
+	if e.Port, err = strconv.Atoi(port); err != nil || e.Port <= 0 || e.Port > 65535 {
+		return Endpoint{}, errors.Errorf("%q has an invalid port", s)
+	}
+	e.Host = host
+	return e, nil
+}
+
+// String returns the address of the endpoint, host:port
+func (e Endpoint) String() string {
+	port := e.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(e.Host, strconv.Itoa(port))
+}
+
+// Config configures how a Pool connects to hosts
+type Config struct {
+	// User is the user to log in as
+	User string
+	// PrivateKeys are the PEM encoded private keys to authenticate with
+	PrivateKeys [][]byte
+	// UseAgent authenticates with the keys of the ssh-agent listening on $SSH_AUTH_SOCK too
+	UseAgent bool
+	// HostKeys verifies the host keys of the jump hosts and of the hosts, see KnownHosts and PinnedHostKeys
+	HostKeys HostKeyVerifier
+	// JumpHosts are hopped through in order to reach the hosts, such as the master load balancer
+	JumpHosts []Endpoint
+	// DialTimeout bounds connecting to each host, DefaultDialTimeout if 0
+	DialTimeout time.Duration
+	// CommandTimeout bounds each command and file copy, unless the context has a closer deadline. 0 does not bound them
+	CommandTimeout time.Duration
+}
+
+// connection is a connection of the pool, ready once dialed
+type connection struct {
+	ready  chan struct{}
+	client *gossh.Client
+	err    error
+}
+
+// Pool connects to hosts over SSH and keeps the connections, to the hosts and to the jump hosts on the way to them,
+// for the following commands. It is safe for concurrent use
+type Pool struct {
+	config Config
+	auth   []gossh.AuthMethod
+	agent  net.Conn
+
+	mu          sync.Mutex
+	connections map[string]*connection
+	closed      bool
+}
+
+// NewPool returns a pool of connections configured by config
+func NewPool(config Config) (*Pool, error) {
+	if config.HostKeys == nil {
+		return nil, errors.New("a host key verifier is required, use InsecureIgnoreHostKey to skip verifying host keys")
+	}
+	p := &Pool{config: config, connections: map[string]*connection{}}
+	var signers []gossh.Signer
+	for i, privateKey := range config.PrivateKeys {
+		signer, err := gossh.ParsePrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing private key %d", i)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		p.auth = append(p.auth, gossh.PublicKeys(signers...))
+	}
+	if config.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, errors.New("no ssh-agent to authenticate with, SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, errors.Wrap(err, "connecting to the ssh-agent")
+		}
+		p.agent = conn
+		p.auth = append(p.auth, gossh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if len(p.auth) == 0 {
+		return nil, errors.New("no private key or ssh-agent to authenticate with")
+	}
+	return p, nil
+}
+
+// Client returns a client of host, connected through the jump hosts of the Config. The connections are reused by the
+// following clients of host, and of the hosts behind the same jump hosts. The last jump host is itself reached directly
+func (p *Pool) Client(host Endpoint) (*Client, error) {
+	return p.ClientVia(host, p.config.JumpHosts...)
+}
+
+// ClientVia returns a client of host, connected through jumpHosts rather than the jump hosts of the Config
+func (p *Pool) ClientVia(host Endpoint, jumpHosts ...Endpoint) (*Client, error) {
+	route := append([]Endpoint{}, jumpHosts...)
+	if len(route) == 0 || route[len(route)-1] != host {
+		route = append(route, host)
+	}
+	client, err := p.connect(route)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{host: host, client: client, commandTimeout: p.config.CommandTimeout}, nil
+}
+
+// Run runs command on host and returns its standard output, see Client.Run
+func (p *Pool) Run(ctx context.Context, host Endpoint, command string) (string, error) {
+	client, err := p.Client(host)
+	if err != nil {
+		return "", err
+	}
+	return client.Run(ctx, command)
+}
+
+// Close closes the connections of the pool
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	connections := p.connections
+	p.connections = map[string]*connection{}
+	p.mu.Unlock()
+
+	for _, c := range connections {
+		<-c.ready
+		if c.client != nil {
+			c.client.Close()
+		}
+	}
+	if p.agent != nil {
+		return p.agent.Close()
+	}
+	return nil
+}
+
+// connect returns the connection to the last host of route, dialing it through the connection to the hosts before it
+// if not connected yet
+func (p *Pool) connect(route []Endpoint) (*gossh.Client, error) {
+	key := routeKey(route)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("the connection pool is closed")
+	}
+	if c, ok := p.connections[key]; ok {
+		p.mu.Unlock()
+		<-c.ready
+		return c.client, c.err
+	}
+	c := &connection{ready: make(chan struct{})}
+	p.connections[key] = c
+	p.mu.Unlock()
+
+	c.client, c.err = p.dial(route)
+	close(c.ready)
+	if c.err != nil {
+		p.forget(key, c)
+		return nil, c.err
+	}
+	go func() {
+		// drop the connection once it is lost, for the next client to dial again
+		c.client.Wait()
+		p.forget(key, c)
+	}()
+	return c.client, nil
+}
+
+func (p *Pool) forget(key string, c *connection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connections[key] == c {
+		delete(p.connections, key)
+	}
+}
+
+func (p *Pool) dial(route []Endpoint) (*gossh.Client, error) {
+	host := route[len(route)-1]
+	timeout := p.config.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	var conn net.Conn
+	var err error
+	if len(route) == 1 {
+		if conn, err = net.DialTimeout("tcp", host.String(), timeout); err != nil {
+			return nil, errors.Wrapf(err, "dialing %s", host)
+		}
+	} else {
+		via, err := p.connect(route[:len(route)-1])
+		if err != nil {
+			return nil, err
+		}
+		if conn, err = dialVia(via, host, timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	user := host.User
+	if user == "" {
+		user = p.config.User
+	}
+	config := &gossh.ClientConfig{
+		User:              user,
+		Auth:              p.auth,
+		HostKeyCallback:   p.config.HostKeys.VerifyHostKey,
+		HostKeyAlgorithms: p.config.HostKeys.HostKeyAlgorithms(host.String()),
+		Timeout:           timeout,
+	}
+	type result struct {
+		client *gossh.Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, chans, reqs, err := gossh.NewClientConn(conn, host.String(), config)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{client: gossh.NewClient(c, chans, reqs)}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			conn.Close()
+			return nil, errors.Wrapf(r.err, "connecting to %s", host)
+		}
+		return r.client, nil
+	case <-time.After(timeout):
+		conn.Close()
+		return nil, errors.Errorf("connecting to %s: timed out after %s", host, timeout)
+	}
+}
+
+// dialVia dials host through the connection to a jump host
+func dialVia(via *gossh.Client, host Endpoint, timeout time.Duration) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := via.Dial("tcp", host.String())
+		done <- result{conn, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, errors.Wrapf(r.err, "dialing %s through %s", host, via.RemoteAddr())
+		}
+		return r.conn, nil
+	case <-time.After(timeout):
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, errors.Errorf("dialing %s through %s: timed out after %s", host, via.RemoteAddr(), timeout)
+	}
+}
+
+func routeKey(route []Endpoint) string {
+	hops := make([]string, len(route))
+	for i, host := range route {
+		hops[i] = host.User + "@" + host.String()
+	}
+	return strings.Join(hops, " > ")
+}
+
+// Client runs commands and copies files on a host
+type Client struct {
+	host           Endpoint
+	client         *gossh.Client
+	commandTimeout time.Duration
+	sudo           bool
+}
+
+// Host returns the host of the client
+func (c *Client) Host() Endpoint {
+	return c.host
+}
+
+// Sudo returns a client of the same host that runs commands, and copies files, as root through sudo
+func (c *Client) Sudo() *Client {
+	sudo := *c
+	sudo.sudo = true
+	return &sudo
+}
+
+// Stream runs command with stdin as its standard input, if not nil, writing its standard output and standard error to
+// stdout and stderr as it runs. The error of a command that exits with a non-zero status has an *ssh.ExitError cause
+func (c *Client) Stream(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.commandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.commandTimeout)
+		defer cancel()
+	}
+	session, err := c.client.NewSession()
+	if err != nil {
+		return errors.Wrapf(err, "opening a session on %s", c.host)
+	}
+	defer session.Close()
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if c.sudo {
+		command = "sudo -n sh -c " + helpers.ShellQuote(command)
+	}
+	if err = session.Start(command); err != nil {
+		return errors.Wrapf(err, "starting the command on %s", c.host)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			return errors.Wrapf(err, "running the command on %s", c.host)
+		}
+		return nil
+	case <-ctx.Done():
+		session.Signal(gossh.SIGKILL)
+		session.Close()
+		// the output is copied until the session is closed
+		<-done
+		return errors.Wrapf(ctx.Err(), "running the command on %s", c.host)
+	}
+}
+
+// Run runs command and returns its standard output. The error of a failing command has its standard error
+func (c *Client) Run(ctx context.Context, command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.Stream(ctx, command, nil, &stdout, &stderr); err != nil {
+		return stdout.String(), withStderr(err, stderr)
+	}
+	return stdout.String(), nil
+}
+
+// CombinedOutput runs command and returns its standard output and standard error
+func (c *Client) CombinedOutput(ctx context.Context, command string) ([]byte, error) {
+	var output bytes.Buffer
+	w := &lockedWriter{w: &output}
+	err := c.Stream(ctx, command, nil, w, w)
+	return output.Bytes(), err
+}
+
+// lockedWriter serializes the writes of the standard output and standard error of a command to the same writer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// Upload copies r to path on the host, a Linux host, with the mode permissions. The file is replaced once completely
+// copied
+func (c *Client) Upload(ctx context.Context, r io.Reader, path string, mode os.FileMode) error {
+	command := fmt.Sprintf(`t=$(mktemp %s) && { cat > "$t" && chmod %04o "$t" && mv -f "$t" %s || { rm -f "$t"; exit 1; }; }`,
+		helpers.ShellQuote(path+".XXXXXX"), mode.Perm(), helpers.ShellQuote(path))
+	var stderr bytes.Buffer
+	if err := c.Stream(ctx, command, r, ioutil.Discard, &stderr); err != nil {
+		return errors.Wrapf(withStderr(err, stderr), "copying to %s", path)
+	}
+	return nil
+}
+
+// WriteFile writes data to path on the host, see Upload
+func (c *Client) WriteFile(ctx context.Context, path string, data []byte, mode os.FileMode) error {
+	return c.Upload(ctx, bytes.NewReader(data), path, mode)
+}
+
+// Download copies path on the host, a Linux host, to w
+func (c *Client) Download(ctx context.Context, path string, w io.Writer) error {
+	var stderr bytes.Buffer
+	if err := c.Stream(ctx, "cat "+helpers.ShellQuote(path), nil, w, &stderr); err != nil {
+		return errors.Wrapf(withStderr(err, stderr), "copying from %s", path)
+	}
+	return nil
+}
+
+// ReadFile reads path on the host, see Download
+func (c *Client) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	var b bytes.Buffer
+	if err := c.Download(ctx, path, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func withStderr(err error, stderr bytes.Buffer) error {
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return errors.WithMessage(err, message)
+	}
+	return err
+}
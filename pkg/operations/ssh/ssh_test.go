@@ -0,0 +1,596 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server that runs the commands it is sent with sh, and forwards the connections it is asked to
+// open, to test clients with
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	hostKey  gossh.Signer
+
+	mu          sync.Mutex
+	connections []net.Conn
+	users       []string
+	commands    []string
+}
+
+func newTestServer(t *testing.T, clientKey gossh.PublicKey) *testServer {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server runs commands with sh")
+	}
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the host key: %s", err)
+	}
+	s := &testServer{t: t}
+	if s.hostKey, err = gossh.NewSignerFromKey(hostKey); err != nil {
+		t.Fatalf("failed to create the host key signer: %s", err)
+	}
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(meta gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if !keysEqual(key, clientKey) {
+				return nil, errors.New("unknown client key")
+			}
+			s.mu.Lock()
+			s.users = append(s.users, meta.User())
+			s.mu.Unlock()
+			return nil, nil
+		},
+	}
+	config.AddHostKey(s.hostKey)
+	if s.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections = append(s.connections, conn)
+			s.mu.Unlock()
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) endpoint() Endpoint {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return Endpoint{Host: addr.IP.String(), Port: addr.Port}
+}
+
+func (s *testServer) close() {
+	s.listener.Close()
+	s.dropConnections()
+}
+
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.connections {
+		conn.Close()
+	}
+	s.connections = nil
+}
+
+func (s *testServer) connectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.connections)
+}
+
+func (s *testServer) loggedInUsers() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.users, ",")
+}
+
+func (s *testServer) commandsRun() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+func (s *testServer) serve(conn net.Conn, config *gossh.ServerConfig) {
+	_, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.session(newChannel)
+		case "direct-tcpip":
+			go s.forward(newChannel)
+		default:
+			newChannel.Reject(gossh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+func (s *testServer) session(newChannel gossh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		command := string(req.Payload[4:])
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 255
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			}
+		}
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, uint32(status))
+		channel.SendRequest("exit-status", false, payload)
+		return
+	}
+}
+
+func (s *testServer) forward(newChannel gossh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := gossh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(gossh.ConnectionFailed, "invalid payload")
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChannel.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go gossh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+	}()
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+func newTestClientKey(t *testing.T) ([]byte, gossh.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the client key: %s", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal the client key: %s", err)
+	}
+	publicKey, err := gossh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create the client public key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), publicKey
+}
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		input     string
+		expected  Endpoint
+		expectErr bool
+	}{
+		{input: "master.westus2.cloudapp.azure.com", expected: Endpoint{Host: "master.westus2.cloudapp.azure.com", Port: 22}},
+		{input: "azureuser@10.0.0.4:2200", expected: Endpoint{User: "azureuser", Host: "10.0.0.4", Port: 2200}},
+		{input: "[fd00::4]:22", expected: Endpoint{Host: "fd00::4", Port: 22}},
+		{input: "user@[fd00::4]", expected: Endpoint{User: "user", Host: "fd00::4", Port: 22}},
+		{input: "jumpbox:ssh", expectErr: true},
+		{input: "jumpbox:70000", expectErr: true},
+		{input: "user@", expectErr: true},
+	}
+	for _, c := range cases {
+		actual, err := ParseEndpoint(c.input)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expected an error parsing %q", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %s", c.input, err)
+		} else if actual != c.expected {
+			t.Errorf("expected %q to parse to %+v, got %+v", c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	privateKey, _ := newTestClientKey(t)
+	cases := []struct {
+		name   string
+		config Config
+	}{
+		{"no host key verifier", Config{PrivateKeys: [][]byte{privateKey}}},
+		{"no authentication", Config{HostKeys: InsecureIgnoreHostKey()}},
+		{"invalid private key", Config{PrivateKeys: [][]byte{[]byte("key")}, HostKeys: InsecureIgnoreHostKey()}},
+	}
+	for _, c := range cases {
+		if _, err := NewPool(c.config); err == nil {
+			t.Errorf("%s: expected an error creating the pool", c.name)
+		}
+	}
+}
+
+func TestPoolRunThroughJumpHost(t *testing.T) {
+	privateKey, publicKey := newTestClientKey(t)
+	jumpHost := newTestServer(t, publicKey)
+	defer jumpHost.close()
+	node := newTestServer(t, publicKey)
+	defer node.close()
+
+	jumpEndpoint := jumpHost.endpoint()
+	jumpEndpoint.User = "jumpuser"
+	pool, err := NewPool(Config{
+		User:        "azureuser",
+		PrivateKeys: [][]byte{privateKey},
+		HostKeys: PinnedHostKeys{
+			jumpHost.endpoint().String(): {jumpHost.hostKey.PublicKey()},
+			node.endpoint().String():     {node.hostKey.PublicKey()},
+		},
+		JumpHosts: []Endpoint{jumpEndpoint},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating the pool: %s", err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		out, err := pool.Run(ctx, node.endpoint(), "echo hello")
+		if err != nil {
+			t.Fatalf("unexpected error running a command: %s", err)
+		}
+		if out != "hello\n" {
+			t.Errorf("expected the output of the command, got %q", out)
+		}
+	}
+	if _, err = pool.Run(ctx, jumpEndpoint, "true"); err != nil {
+		t.Fatalf("unexpected error running a command on the jump host: %s", err)
+	}
+	if count := jumpHost.connectionCount(); count != 1 {
+		t.Errorf("expected the connection to the jump host to be reused, got %d connections", count)
+	}
+	if count := node.connectionCount(); count != 1 {
+		t.Errorf("expected the connection to the node to be reused, got %d connections", count)
+	}
+	if users := jumpHost.loggedInUsers(); users != "jumpuser" {
+		t.Errorf("expected the jump host to be logged in to as jumpuser, got %s", users)
+	}
+	if users := node.loggedInUsers(); users != "azureuser" {
+		t.Errorf("expected the node to be logged in to as azureuser, got %s", users)
+	}
+
+	// a lost connection is dialed again
+	node.dropConnections()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err = pool.Run(ctx, node.endpoint(), "true"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the lost connection to be dialed again: %s", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestClientRunErrors(t *testing.T) {
+	privateKey, publicKey := newTestClientKey(t)
+	node := newTestServer(t, publicKey)
+	defer node.close()
+
+	pool, err := NewPool(Config{
+		PrivateKeys:    [][]byte{privateKey},
+		HostKeys:       InsecureIgnoreHostKey(),
+		CommandTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating the pool: %s", err)
+	}
+	defer pool.Close()
+	client, err := pool.Client(node.endpoint())
+	if err != nil {
+		t.Fatalf("unexpected error connecting: %s", err)
+	}
+
+	ctx := context.Background()
+	_, err = client.Run(ctx, "echo failed >&2; exit 3")
+	if err == nil {
+		t.Fatalf("expected an error running a failing command")
+	}
+	if exitErr, ok := errors.Cause(err).(*gossh.ExitError); !ok || exitErr.ExitStatus() != 3 {
+		t.Errorf("expected the exit status of the command, got %#v", errors.Cause(err))
+	}
+	if !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected the error to have the standard error of the command, got %s", err)
+	}
+
+	start := time.Now()
+	if _, err = client.Run(ctx, "sleep 5"); errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected the command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the command timeout to return early, returned after %s", elapsed)
+	}
+
+	out, err := client.CombinedOutput(ctx, "echo out; echo err >&2")
+	if err != nil {
+		t.Fatalf("unexpected error running a command: %s", err)
+	}
+	if !strings.Contains(string(out), "out") || !strings.Contains(string(out), "err") {
+		t.Errorf("expected the standard output and error of the command, got %q", out)
+	}
+}
+
+func TestClientFiles(t *testing.T) {
+	privateKey, publicKey := newTestClientKey(t)
+	node := newTestServer(t, publicKey)
+	defer node.close()
+
+	dir, err := ioutil.TempDir("", "ssh")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pool, err := NewPool(Config{PrivateKeys: [][]byte{privateKey}, HostKeys: InsecureIgnoreHostKey()})
+	if err != nil {
+		t.Fatalf("unexpected error creating the pool: %s", err)
+	}
+	defer pool.Close()
+	client, err := pool.Client(node.endpoint())
+	if err != nil {
+		t.Fatalf("unexpected error connecting: %s", err)
+	}
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "it's a file")
+	data := []byte("-----BEGIN CERTIFICATE-----\n\"$HOME\" `id`\n-----END CERTIFICATE-----\n")
+	if err = client.WriteFile(ctx, path, data, 0640); err != nil {
+		t.Fatalf("unexpected error writing the file: %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the file to be written: %s", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("expected the file to have mode 0640, got %s", info.Mode())
+	}
+	read, err := client.ReadFile(ctx, path)
+	if err != nil {
+		t.Fatalf("unexpected error reading the file: %s", err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Errorf("expected to read %q back, got %q", data, read)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.*")); len(matches) != 0 {
+		t.Errorf("expected no temporary files to be left, got %v", matches)
+	}
+
+	if err = client.WriteFile(ctx, filepath.Join(dir, "missing", "file"), data, 0600); err == nil {
+		t.Errorf("expected an error writing to a missing directory")
+	}
+	if _, err = client.ReadFile(ctx, filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Errorf("expected an error with the standard error of cat reading a missing file, got %v", err)
+	}
+}
+
+func TestClientSudo(t *testing.T) {
+	privateKey, publicKey := newTestClientKey(t)
+	node := newTestServer(t, publicKey)
+	defer node.close()
+
+	pool, err := NewPool(Config{PrivateKeys: [][]byte{privateKey}, HostKeys: InsecureIgnoreHostKey()})
+	if err != nil {
+		t.Fatalf("unexpected error creating the pool: %s", err)
+	}
+	defer pool.Close()
+	client, err := pool.Client(node.endpoint())
+	if err != nil {
+		t.Fatalf("unexpected error connecting: %s", err)
+	}
+	// sudo is not expected to be there, only the command sent matters
+	client.Sudo().Run(context.Background(), "systemctl restart 'kubelet'")
+	expected := `sudo -n sh -c 'systemctl restart '\''kubelet'\'''`
+	if commands := node.commandsRun(); len(commands) != 1 || commands[0] != expected {
+		t.Errorf("expected the command to be run through sudo as %s, got %v", expected, commands)
+	}
+}
+
+func TestPoolHostKeyVerification(t *testing.T) {
+	privateKey, publicKey := newTestClientKey(t)
+	node := newTestServer(t, publicKey)
+	defer node.close()
+	other := newTestServer(t, publicKey)
+	defer other.close()
+
+	run := func(hostKeys HostKeyVerifier) error {
+		pool, err := NewPool(Config{PrivateKeys: [][]byte{privateKey}, HostKeys: hostKeys})
+		if err != nil {
+			t.Fatalf("unexpected error creating the pool: %s", err)
+		}
+		defer pool.Close()
+		_, err = pool.Run(context.Background(), node.endpoint(), "true")
+		return err
+	}
+
+	if err := run(PinnedHostKeys{node.endpoint().Host: {other.hostKey.PublicKey()}}); err == nil {
+		t.Errorf("expected a host key that is not pinned to be refused")
+	}
+	if err := run(PinnedHostKeys{node.endpoint().Host: {other.hostKey.PublicKey(), node.hostKey.PublicKey()}}); err != nil {
+		t.Errorf("unexpected error connecting with a pinned host key: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "known_hosts")
+	if err != nil {
+		t.Fatalf("failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	knownHostsPath := filepath.Join(dir, ".ssh", "known_hosts")
+
+	knownHosts, _ := LoadKnownHosts(knownHostsPath, false)
+	if err = run(knownHosts); err == nil {
+		t.Errorf("expected an unknown host to be refused")
+	}
+	knownHosts, _ = LoadKnownHosts(knownHostsPath, true)
+	if err = run(knownHosts); err != nil {
+		t.Errorf("unexpected error accepting a new host: %s", err)
+	}
+	b, err := ioutil.ReadFile(knownHostsPath)
+	if err != nil {
+		t.Fatalf("expected the new host to be added to the known hosts file: %s", err)
+	}
+	if !strings.HasPrefix(string(b), "|1|") || strings.Contains(string(b), node.endpoint().Host) {
+		t.Errorf("expected the new host to be added hashed, got %s", b)
+	}
+	if knownHosts, err = LoadKnownHosts(knownHostsPath, false); err != nil {
+		t.Fatalf("unexpected error loading the known hosts: %s", err)
+	}
+	if err = run(knownHosts); err != nil {
+		t.Errorf("unexpected error connecting to a known host: %s", err)
+	}
+
+	// the same host presenting another key is refused, even when accepting new hosts
+	line := knownHostsName(node.endpoint().String()) + " " + string(gossh.MarshalAuthorizedKey(other.hostKey.PublicKey()))
+	if err = ioutil.WriteFile(knownHostsPath, []byte(line), 0600); err != nil {
+		t.Fatalf("failed to write the known hosts file: %s", err)
+	}
+	knownHosts, _ = LoadKnownHosts(knownHostsPath, true)
+	if err = run(knownHosts); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a changed host key to be refused, got %v", err)
+	}
+}
+
+func TestKnownHostsMatching(t *testing.T) {
+	_, key := newTestClientKey(t)
+	_, otherKey := newTestClientKey(t)
+	hashed, err := hashKnownHost("[10.0.0.5]:2200")
+	if err != nil {
+		t.Fatalf("unexpected error hashing a host: %s", err)
+	}
+	authorizedKey := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key)))
+	otherAuthorizedKey := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(otherKey)))
+	knownHostsFile := strings.Join([]string{
+		"# comment",
+		"k8s-master-*,!k8s-master-bad " + authorizedKey,
+		hashed + " " + authorizedKey,
+		"master.westus2.cloudapp.azure.com " + authorizedKey,
+		"@cert-authority *.cloudapp.azure.com " + otherAuthorizedKey,
+		"@revoked k8s-agent-? " + authorizedKey,
+		"k8s-agent-? " + authorizedKey,
+	}, "\n")
+	entries, err := parseKnownHosts([]byte(knownHostsFile))
+	if err != nil {
+		t.Fatalf("unexpected error parsing the known hosts: %s", err)
+	}
+	knownHosts := &KnownHosts{entries: entries}
+
+	cases := []struct {
+		hostname  string
+		key       gossh.PublicKey
+		expectErr bool
+	}{
+		{"k8s-master-12345-0:22", key, false},
+		{"k8s-master-12345-0:22", otherKey, true},
+		{"k8s-master-bad:22", key, true},
+		{"k8s-master-12345-0:2200", key, true},
+		{"10.0.0.5:2200", key, false},
+		{"10.0.0.5:22", key, true},
+		{"master.westus2.cloudapp.azure.com:22", key, false},
+		{"other.westus2.cloudapp.azure.com:22", otherKey, true},
+		{"k8s-agent-0:22", key, true},
+		{"unknown:22", key, true},
+	}
+	for _, c := range cases {
+		err := knownHosts.VerifyHostKey(c.hostname, nil, c.key)
+		if c.expectErr && err == nil {
+			t.Errorf("expected the key of %s to be refused", c.hostname)
+		}
+		if !c.expectErr && err != nil {
+			t.Errorf("unexpected error verifying the key of %s: %s", c.hostname, err)
+		}
+	}
+	if algorithms := knownHosts.HostKeyAlgorithms("master.westus2.cloudapp.azure.com:22"); len(algorithms) != 1 || algorithms[0] != key.Type() {
+		t.Errorf("expected the algorithm of the known key, got %v", algorithms)
+	}
+	if algorithms := knownHosts.HostKeyAlgorithms("unknown:22"); algorithms != nil {
+		t.Errorf("expected any algorithm for an unknown host, got %v", algorithms)
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"*", "", true},
+		{"k8s-*", "k8s-master-0", true},
+		{"k8s-*-0", "k8s-master-12345-0", true},
+		{"k8s-*-0", "k8s-master-12345-1", false},
+		{"k8s-agent-?", "k8s-agent-1", true},
+		{"k8s-agent-?", "k8s-agent-10", false},
+		{"[10.0.0.5]:2200", "[10.0.0.5]:2200", true},
+		{"10.0.0.*", "10.0.1.5", false},
+	}
+	for _, c := range cases {
+		if actual := wildcardMatch(c.pattern, c.s); actual != c.expected {
+			t.Errorf("expected %q matching %q to be %t", c.s, c.pattern, c.expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Age encrypts secrets with age, https://age-encryption.org
+	Age = "age"
+	// GPG encrypts secrets with GnuPG
+	GPG = "gpg"
+)
+
+// commandRunner runs a command with stdin as its standard input and returns its standard output
+type commandRunner func(stdin []byte, name string, args ...string) ([]byte, error)
+
+// EncryptedFileProvider keeps every secret in a file of its own under Dir, encrypted with the age or gpg
+// command line tool for Recipients. Reading secrets back decrypts them with the age Identity file, or
+// with the keys in the gpg keyring of the current user.
+type EncryptedFileProvider struct {
+	Tool       string
+	Dir        string
+	Recipients []string
+	Identity   string
+
+	run commandRunner
+}
+
+// NewEncryptedFileProvider returns an EncryptedFileProvider that encrypts secrets with tool, either Age or GPG
+func NewEncryptedFileProvider(tool, dir string, recipients []string, identity string) (*EncryptedFileProvider, error) {
+	if tool != Age && tool != GPG {
+		return nil, errors.Errorf("unsupported encryption tool %s, must be %s or %s", tool, Age, GPG)
+	}
+	return &EncryptedFileProvider{
+		Tool:       tool,
+		Dir:        dir,
+		Recipients: recipients,
+		Identity:   identity,
+		run:        runCommand,
+	}, nil
+}
+
+// Put encrypts value to the file Dir/name and returns its age:// or gpg:// reference
+func (p *EncryptedFileProvider) Put(name, value string) (string, error) {
+	encrypted, err := p.Encrypt([]byte(value))
+	if err != nil {
+		return "", errors.Wrapf(err, "encrypting secret %s", name)
+	}
+	path, err := writeSecretFile(p.Dir, name, encrypted)
+	if err != nil {
+		return "", err
+	}
+	return p.scheme() + path, nil
+}
+
+// Get decrypts the file an age:// or gpg:// reference refers to
+func (p *EncryptedFileProvider) Get(reference string) (string, error) {
+	if !p.Owns(reference) {
+		return "", errors.Errorf("%s is not a %s secret reference", reference, p.Tool)
+	}
+	decrypted, err := p.Decrypt(strings.TrimPrefix(reference, p.scheme()))
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// Encrypt encrypts data for Recipients
+func (p *EncryptedFileProvider) Encrypt(data []byte) ([]byte, error) {
+	if len(p.Recipients) == 0 {
+		return nil, errors.Errorf("at least one recipient is required to encrypt secrets with %s", p.Tool)
+	}
+	var args []string
+	switch p.Tool {
+	case Age:
+		for _, recipient := range p.Recipients {
+			args = append(args, "--recipient", recipient)
+		}
+	case GPG:
+		// the recipients are named explicitly, so do not ask whether their keys are trusted
+		args = []string{"--batch", "--yes", "--trust-model", "always", "--encrypt", "--output", "-"}
+		for _, recipient := range p.Recipients {
+			args = append(args, "--recipient", recipient)
+		}
+	}
+	return p.run(data, p.Tool, args...)
+}
+
+// Decrypt decrypts the file at path with the age Identity file, or the gpg keyring of the current user
+func (p *EncryptedFileProvider) Decrypt(path string) ([]byte, error) {
+	var args []string
+	switch p.Tool {
+	case Age:
+		if p.Identity == "" {
+			return nil, errors.Errorf("an age identity file is required to decrypt %s", path)
+		}
+		args = []string{"--decrypt", "--identity", p.Identity, path}
+	case GPG:
+		args = []string{"--batch", "--quiet", "--decrypt", path}
+	}
+	decrypted, err := p.run(nil, p.Tool, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypting secret %s", path)
+	}
+	return decrypted, nil
+}
+
+// Owns returns true for the references of the encryption tool of the provider
+func (p *EncryptedFileProvider) Owns(reference string) bool {
+	return strings.HasPrefix(reference, p.scheme())
+}
+
+func (p *EncryptedFileProvider) scheme() string {
+	if p.Tool == GPG {
+		return gpgScheme
+	}
+	return ageScheme
+}
+
+func runCommand(stdin []byte, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.Wrap(err, message)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
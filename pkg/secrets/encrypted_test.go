@@ -0,0 +1,123 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// fakeEncryption records the commands it runs and "encrypts" by reversing the input
+type fakeEncryption struct {
+	commands [][]string
+}
+
+func (f *fakeEncryption) run(stdin []byte, name string, args ...string) ([]byte, error) {
+	f.commands = append(f.commands, append([]string{name}, args...))
+	if len(stdin) == 0 {
+		b, err := ioutil.ReadFile(args[len(args)-1])
+		if err != nil {
+			return nil, errors.New("no such file")
+		}
+		stdin = b
+	}
+	reversed := make([]byte, len(stdin))
+	for i, b := range stdin {
+		reversed[len(stdin)-1-i] = b
+	}
+	return reversed, nil
+}
+
+func TestEncryptedFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		tool     string
+		identity string
+		scheme   string
+		encrypt  []string
+		decrypt  []string
+	}{
+		{
+			tool:     Age,
+			identity: "key.txt",
+			scheme:   "age://",
+			encrypt:  []string{"age", "--recipient", "age1first", "--recipient", "age1second"},
+			decrypt:  []string{"age", "--decrypt", "--identity", "key.txt"},
+		},
+		{
+			tool:    GPG,
+			scheme:  "gpg://",
+			encrypt: []string{"gpg", "--batch", "--yes", "--trust-model", "always", "--encrypt", "--output", "-", "--recipient", "age1first", "--recipient", "age1second"},
+			decrypt: []string{"gpg", "--batch", "--quiet", "--decrypt"},
+		},
+	}
+	for _, c := range cases {
+		p, err := NewEncryptedFileProvider(c.tool, dir, []string{"age1first", "age1second"}, c.identity)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.tool, err)
+		}
+		fake := &fakeEncryption{}
+		p.run = fake.run
+
+		reference, err := p.Put(c.tool+"Secret", "secret")
+		if err != nil {
+			t.Fatalf("%s: unexpected error putting a secret: %s", c.tool, err)
+		}
+		if !strings.HasPrefix(reference, c.scheme) || !p.Owns(reference) || !IsReference(reference) {
+			t.Errorf("%s: expected a %s reference, got %s", c.tool, c.scheme, reference)
+		}
+		path := strings.TrimPrefix(reference, c.scheme)
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error reading %s: %s", c.tool, path, err)
+		}
+		if string(b) != "terces" {
+			t.Errorf("%s: expected the secret file to hold the encrypted secret, got %q", c.tool, b)
+		}
+
+		value, err := p.Get(reference)
+		if err != nil {
+			t.Fatalf("%s: unexpected error getting %s: %s", c.tool, reference, err)
+		}
+		if value != "secret" {
+			t.Errorf("%s: expected the secret to be %q, got %q", c.tool, "secret", value)
+		}
+
+		expected := [][]string{c.encrypt, append(c.decrypt, path)}
+		if diff := cmp.Diff(expected, fake.commands); diff != "" {
+			t.Errorf("%s: unexpected commands (-want +got):\n%s", c.tool, diff)
+		}
+	}
+}
+
+func TestEncryptedFileProviderErrors(t *testing.T) {
+	if _, err := NewEncryptedFileProvider("rot13", "dir", nil, ""); err == nil {
+		t.Errorf("expected an error for an unsupported tool")
+	}
+
+	p, err := NewEncryptedFileProvider(Age, "dir", nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.run = (&fakeEncryption{}).run
+	if _, err = p.Put("secret", "secret"); err == nil {
+		t.Errorf("expected an error putting a secret without recipients")
+	}
+	if _, err = p.Get("age:///dir/secret"); err == nil {
+		t.Errorf("expected an error decrypting an age secret without an identity")
+	}
+	if _, err = p.Get("gpg:///dir/secret"); err == nil {
+		t.Errorf("expected an error getting a secret of another provider")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileProvider keeps every secret in a file of its own under Dir, readable only by the current user
+type FileProvider struct {
+	Dir string
+}
+
+// Put writes value to the file Dir/name and returns its file:// reference
+func (p *FileProvider) Put(name, value string) (string, error) {
+	path, err := writeSecretFile(p.Dir, name, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return fileScheme + path, nil
+}
+
+// Get reads the file a file:// reference refers to
+func (p *FileProvider) Get(reference string) (string, error) {
+	if !p.Owns(reference) {
+		return "", errors.Errorf("%s is not a file secret reference", reference)
+	}
+	b, err := ioutil.ReadFile(strings.TrimPrefix(reference, fileScheme))
+	if err != nil {
+		return "", errors.Wrap(err, "reading secret")
+	}
+	return string(b), nil
+}
+
+// Owns returns true for file:// references
+func (p *FileProvider) Owns(reference string) bool {
+	return strings.HasPrefix(reference, fileScheme)
+}
+
+// writeSecretFile writes data to dir/name with permissions for the current user only, and returns the
+// absolute path of the file so that the reference to it does not depend on the working directory
+func writeSecretFile(dir, name string, data []byte) (string, error) {
+	if dir == "" {
+		return "", errors.New("a directory is required to store secrets in files")
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", errors.Errorf("invalid secret name %q", name)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrapf(err, "creating secret directory %s", dir)
+	}
+	path := filepath.Join(dir, name)
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", errors.Wrapf(err, "writing secret %s", name)
+	}
+	// WriteFile leaves the permissions of an existing file alone
+	if err = os.Chmod(path, 0600); err != nil {
+		return "", errors.Wrapf(err, "writing secret %s", name)
+	}
+	return filepath.ToSlash(path), nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("unexpected error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &FileProvider{Dir: filepath.Join(dir, "cluster")}
+	reference, err := p.Put("caPrivateKey", "private key")
+	if err != nil {
+		t.Fatalf("unexpected error putting a secret: %s", err)
+	}
+	if !p.Owns(reference) || !IsReference(reference) {
+		t.Errorf("expected %s to be a file secret reference", reference)
+	}
+	if IsKeyVaultReference(reference) {
+		t.Errorf("expected %s not to be a Key Vault reference", reference)
+	}
+
+	// a provider for another directory reads the reference just the same
+	value, err := (&FileProvider{}).Get(reference)
+	if err != nil {
+		t.Fatalf("unexpected error getting %s: %s", reference, err)
+	}
+	if value != "private key" {
+		t.Errorf("expected the secret to be %q, got %q", "private key", value)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "cluster", "caPrivateKey"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("expected the secret file to only be readable by its owner, got %s", info.Mode().Perm())
+		}
+	}
+
+	if _, err = p.Get("age:///cluster/caPrivateKey"); err == nil {
+		t.Errorf("expected an error getting a secret of another provider")
+	}
+	if _, err = p.Put("../caPrivateKey", "private key"); err == nil {
+		t.Errorf("expected an error putting a secret outside of the directory")
+	}
+	if _, err = (&FileProvider{}).Put("caPrivateKey", "private key"); err == nil {
+		t.Errorf("expected an error putting a secret without a directory")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/pkg/errors"
+)
+
+// keyVaultDeploymentTimeout bounds the deployment that writes the secrets into the vault
+const keyVaultDeploymentTimeout = 20 * time.Minute
+
+var (
+	keyVaultIDRe         = regexp.MustCompile(`^/subscriptions/([^/\s]+)/resourceGroups/([^/\s]+)/providers/Microsoft.KeyVault/vaults/([^/\s]+)$`)
+	keyVaultSecretNameRe = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+)
+
+// TemplateDeployer deploys ARM templates, such as an armhelpers.AKSEngineClient
+type TemplateDeployer interface {
+	DeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) (resources.DeploymentExtended, error)
+}
+
+// KeyVaultProvider keeps secrets in an Azure Key Vault. Its references are secret IDs, the same ones a
+// KeyvaultSecretRef or a certificateProfile can refer to, which Azure Resource Manager resolves when deploying
+// the cluster. For that the vault must be enabled for template deployment.
+//
+// The secrets are written through an ARM deployment, with the same credentials as the rest of the cluster,
+// and only once Flush is called. They cannot be read back: they never need to leave the vault.
+type KeyVaultProvider struct {
+	VaultID string
+
+	subscriptionID string
+	resourceGroup  string
+	vaultName      string
+	deployer       TemplateDeployer
+	pending        []keyVaultSecret
+}
+
+type keyVaultSecret struct {
+	name  string
+	value string
+}
+
+// NewKeyVaultProvider returns a KeyVaultProvider for the vault with the resource ID vaultID
+func NewKeyVaultProvider(vaultID string, deployer TemplateDeployer) (*KeyVaultProvider, error) {
+	parts := keyVaultIDRe.FindStringSubmatch(vaultID)
+	if parts == nil {
+		return nil, errors.Errorf("%s is not a Key Vault resource ID", vaultID)
+	}
+	return &KeyVaultProvider{
+		VaultID:        vaultID,
+		subscriptionID: parts[1],
+		resourceGroup:  parts[2],
+		vaultName:      parts[3],
+		deployer:       deployer,
+	}, nil
+}
+
+// SubscriptionID returns the subscription of the vault
+func (p *KeyVaultProvider) SubscriptionID() string {
+	return p.subscriptionID
+}
+
+// Put queues value to be written as the latest version of the secret called name, and returns the secret ID
+func (p *KeyVaultProvider) Put(name, value string) (string, error) {
+	if !keyVaultSecretNameRe.MatchString(name) {
+		return "", errors.Errorf("invalid Key Vault secret name %q, it must only contain letters, digits and dashes", name)
+	}
+	for i := range p.pending {
+		if p.pending[i].name == name {
+			p.pending[i].value = value
+			return p.reference(name), nil
+		}
+	}
+	p.pending = append(p.pending, keyVaultSecret{name: name, value: value})
+	return p.reference(name), nil
+}
+
+// Get always fails, Azure Resource Manager resolves Key Vault references instead
+func (p *KeyVaultProvider) Get(reference string) (string, error) {
+	return "", errors.Errorf("%s is resolved by Azure Resource Manager when deploying, it cannot be read", reference)
+}
+
+// Owns returns true for the IDs of the secrets of the vault
+func (p *KeyVaultProvider) Owns(reference string) bool {
+	vaultID, _, _, ok := ParseKeyVaultReference(reference)
+	return ok && strings.EqualFold(vaultID, p.VaultID)
+}
+
+// Flush writes the queued secrets into the vault with a single deployment to its resource group
+func (p *KeyVaultProvider) Flush() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+	template, parameters := p.template()
+	ctx, cancel := context.WithTimeout(context.Background(), keyVaultDeploymentTimeout)
+	defer cancel()
+	deploymentName := fmt.Sprintf("aks-engine-secrets-%d", time.Now().Unix())
+	if _, err := p.deployer.DeployTemplate(ctx, p.resourceGroup, deploymentName, template, parameters); err != nil {
+		return errors.Wrapf(err, "writing secrets to Key Vault %s", p.vaultName)
+	}
+	p.pending = nil
+	return nil
+}
+
+// template returns the ARM template and parameters that write the queued secrets. The values are passed as
+// secure strings so that they are not recorded in the deployment history.
+func (p *KeyVaultProvider) template() (map[string]interface{}, map[string]interface{}) {
+	templateParameters := map[string]interface{}{}
+	parameters := map[string]interface{}{}
+	var secretResources []interface{}
+	for i, secret := range p.pending {
+		parameter := fmt.Sprintf("secret%d", i)
+		templateParameters[parameter] = map[string]interface{}{
+			"type": "securestring",
+		}
+		parameters[parameter] = map[string]interface{}{
+			"value": secret.value,
+		}
+		secretResources = append(secretResources, map[string]interface{}{
+			"type":       "Microsoft.KeyVault/vaults/secrets",
+			"apiVersion": "2018-02-14",
+			"name":       p.vaultName + "/" + secret.name,
+			"properties": map[string]interface{}{
+				"value": fmt.Sprintf("[parameters('%s')]", parameter),
+			},
+		})
+	}
+	template := map[string]interface{}{
+		"$schema":        "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+		"contentVersion": "1.0.0.0",
+		"parameters":     templateParameters,
+		"resources":      secretResources,
+	}
+	return template, parameters
+}
+
+func (p *KeyVaultProvider) reference(name string) string {
+	return p.VaultID + "/secrets/" + name
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-05-01/resources"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type fakeDeployer struct {
+	resourceGroups []string
+	templates      []map[string]interface{}
+	parameters     []map[string]interface{}
+	fail           bool
+}
+
+func (f *fakeDeployer) DeployTemplate(ctx context.Context, resourceGroup, name string, template, parameters map[string]interface{}) (resources.DeploymentExtended, error) {
+	if f.fail {
+		return resources.DeploymentExtended{}, errors.New("DeployTemplate failed")
+	}
+	f.resourceGroups = append(f.resourceGroups, resourceGroup)
+	f.templates = append(f.templates, template)
+	f.parameters = append(f.parameters, parameters)
+	return resources.DeploymentExtended{}, nil
+}
+
+func TestKeyVaultProvider(t *testing.T) {
+	if _, err := NewKeyVaultProvider("vault", &fakeDeployer{}); err == nil {
+		t.Errorf("expected an error for an invalid vault ID")
+	}
+
+	deployer := &fakeDeployer{}
+	p, err := NewKeyVaultProvider(testVaultID, deployer)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.SubscriptionID() != "00000000-0000-0000-0000-000000000001" {
+		t.Errorf("unexpected subscription ID %s", p.SubscriptionID())
+	}
+
+	reference, err := p.Put("cluster-caPrivateKey", "first")
+	if err != nil {
+		t.Fatalf("unexpected error putting a secret: %s", err)
+	}
+	if reference != testVaultID+"/secrets/cluster-caPrivateKey" {
+		t.Errorf("unexpected reference %s", reference)
+	}
+	if !p.Owns(reference) || !IsKeyVaultReference(reference) {
+		t.Errorf("expected %s to be a reference to a secret of the vault", reference)
+	}
+	if p.Owns("/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/other/secrets/cluster-caPrivateKey") {
+		t.Errorf("expected a secret of another vault not to be owned by the provider")
+	}
+	if _, err = p.Put("cluster-caPrivateKey", "second"); err != nil {
+		t.Fatalf("unexpected error putting a secret: %s", err)
+	}
+	if _, err = p.Put("cluster-windowsAdminPassword", "password"); err != nil {
+		t.Fatalf("unexpected error putting a secret: %s", err)
+	}
+	if _, err = p.Put("cluster_caPrivateKey", "first"); err == nil {
+		t.Errorf("expected an error for an invalid secret name")
+	}
+	if _, err = p.Get(reference); err == nil {
+		t.Errorf("expected an error reading a Key Vault secret")
+	}
+	if len(deployer.templates) != 0 {
+		t.Fatalf("expected no deployment before Flush")
+	}
+
+	if err = p.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %s", err)
+	}
+	if err = p.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %s", err)
+	}
+	if len(deployer.templates) != 1 {
+		t.Fatalf("expected a single deployment, got %d", len(deployer.templates))
+	}
+	if deployer.resourceGroups[0] != "rg" {
+		t.Errorf("expected the deployment to the resource group of the vault, got %s", deployer.resourceGroups[0])
+	}
+	expectedResources := []interface{}{
+		map[string]interface{}{
+			"type":       "Microsoft.KeyVault/vaults/secrets",
+			"apiVersion": "2018-02-14",
+			"name":       "vault/cluster-caPrivateKey",
+			"properties": map[string]interface{}{"value": "[parameters('secret0')]"},
+		},
+		map[string]interface{}{
+			"type":       "Microsoft.KeyVault/vaults/secrets",
+			"apiVersion": "2018-02-14",
+			"name":       "vault/cluster-windowsAdminPassword",
+			"properties": map[string]interface{}{"value": "[parameters('secret1')]"},
+		},
+	}
+	if diff := cmp.Diff(expectedResources, deployer.templates[0]["resources"]); diff != "" {
+		t.Errorf("unexpected template resources (-want +got):\n%s", diff)
+	}
+	expectedParameters := map[string]interface{}{
+		"secret0": map[string]interface{}{"value": "second"},
+		"secret1": map[string]interface{}{"value": "password"},
+	}
+	if diff := cmp.Diff(expectedParameters, deployer.parameters[0]); diff != "" {
+		t.Errorf("unexpected template parameters (-want +got):\n%s", diff)
+	}
+
+	failing, err := NewKeyVaultProvider(testVaultID, &fakeDeployer{fail: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err = failing.Put("cluster-caPrivateKey", "first"); err != nil {
+		t.Fatalf("unexpected error putting a secret: %s", err)
+	}
+	if err = failing.Flush(); err == nil {
+		t.Errorf("expected an error when the deployment fails")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+// Package secrets keeps generated credentials, such as private keys and passwords,
+// in a secret provider so that the api model only holds references to them.
+package secrets
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Provider stores secrets and reads them back from the references it returns for them
+type Provider interface {
+	// Put stores value as the secret called name and returns a reference to it
+	Put(name, value string) (string, error)
+	// Get returns the value of the secret reference refers to
+	Get(reference string) (string, error)
+	// Owns returns true if reference refers to a secret kept by the provider
+	Owns(reference string) bool
+}
+
+// Flusher is implemented by providers that hold on to the secrets put in them until Flush is called
+type Flusher interface {
+	Flush() error
+}
+
+const (
+	fileScheme = "file://"
+	ageScheme  = "age://"
+	gpgScheme  = "gpg://"
+)
+
+// keyVaultSecretIDRe matches the ID of a Key Vault secret, the same format the api model already
+// accepts in place of certificates and keys
+var keyVaultSecretIDRe = regexp.MustCompile(`^(/subscriptions/[^/\s]+/resourceGroups/([^/\s]+)/providers/Microsoft.KeyVault/vaults/([^/\s]+))/secrets/([^/\s]+)(/([^/\s]+))?$`)
+
+// IsReference returns true if value refers to a secret kept by a provider rather than holding the secret itself
+func IsReference(value string) bool {
+	for _, scheme := range []string{fileScheme, ageScheme, gpgScheme} {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return IsKeyVaultReference(value)
+}
+
+// IsKeyVaultReference returns true if reference is the ID of a Key Vault secret, which Azure Resource Manager
+// resolves itself when the secret is referenced from a parameters file
+func IsKeyVaultReference(reference string) bool {
+	return keyVaultSecretIDRe.MatchString(reference)
+}
+
+// ParseKeyVaultReference splits the ID of a Key Vault secret into the ID of its vault, its name and its
+// version, which is empty for the latest version
+func ParseKeyVaultReference(reference string) (vaultID, name, version string, ok bool) {
+	parts := keyVaultSecretIDRe.FindStringSubmatch(reference)
+	if parts == nil {
+		return "", "", "", false
+	}
+	return parts[1], parts[4], parts[6], true
+}
@@ -0,0 +1,256 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/engine"
+	"github.com/Azure/aks-engine/pkg/engine/transform"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
+	"github.com/Azure/aks-engine/pkg/operations/nodeexec"
+)
+
+// GenerateResponse is the response of template generation requests. The api model holds the certificates and keys
+// generated with the template, it must be kept to manage the cluster
+type GenerateResponse struct {
+	APIModel   json.RawMessage `json:"apiModel"`
+	Template   json.RawMessage `json:"template"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// UpgradePlanResponse is the response of upgrade plan requests. Deployed tells whether the plan comes from the VMs
+// of the cluster in Azure. Otherwise, it lists all the nodes of the api model, which the upgrade replaces
+type UpgradePlanResponse struct {
+	CurrentVersion string `json:"currentVersion"`
+	Deployed       bool   `json:"deployed"`
+	*kubernetesupgrade.UpgradePlan
+}
+
+func (s *Server) healthz(r *http.Request, t *i18n.Translator) (interface{}, error) {
+	return map[string]string{"status": "ok"}, nil
+}
+
+// versions lists the supported Kubernetes versions and their upgrades, like get-versions
+func (s *Server) versions(r *http.Request, t *i18n.Translator) (interface{}, error) {
+	query := r.URL.Query()
+	windows := false
+	if w := query.Get("windows"); w != "" {
+		var err error
+		if windows, err = strconv.ParseBool(w); err != nil {
+			return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidRequest, t.T("windows must be true or false, got %q", w)}
+		}
+	}
+	versions, err := api.GetOrchestratorVersionProfileListVLabs(api.Kubernetes, query.Get("version"), windows)
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeUnsupportedVersion, t.T("error listing the versions of Kubernetes: %s", err)}
+	}
+	return versions, nil
+}
+
+// validate returns the api model of the request, validated, in its API version. It is not defaulted, defaulting
+// generates the certificates and keys of the cluster, too expensive for a request that only validates
+func (s *Server) validate(r *http.Request, t *i18n.Translator) (interface{}, error) {
+	cs, apiVersion, err := loadAPIModel(r, t, false)
+	if err != nil {
+		return nil, err
+	}
+	return serializeAPIModel(cs, apiVersion, t)
+}
+
+// generate returns the ARM template and parameters of the api model of the request, like generate. Generating the
+// certificates and keys of a cluster takes seconds of CPU, at most MaxConcurrentGenerations requests generate at once
+func (s *Server) generate(r *http.Request, t *i18n.Translator) (interface{}, error) {
+	if !s.acquireGeneration() {
+		return nil, &Error{http.StatusServiceUnavailable, ErrorCodeServerBusy, t.T("the server is busy generating other templates, retry later")}
+	}
+	defer s.releaseGeneration()
+
+	cs, apiVersion, err := loadAPIModel(r, t, false)
+	if err != nil {
+		return nil, err
+	}
+	// the server has no secret provider, only the Key Vault references Azure Resource Manager resolves are left
+	if err = api.NewSecretStore(nil).Resolve(cs); err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("the server cannot read the secrets of the api model: %s", err)}
+	}
+	templateGenerator, err := engine.InitializeTemplateGenerator(engine.Context{Translator: t})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = cs.SetPropertiesDefaults(false, false); err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("error setting the defaults of the api model: %s", err)}
+	}
+	template, parameters, err := templateGenerator.GenerateTemplateV2(cs, engine.DefaultGeneratorCode, s.BuildTag)
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("error generating the template: %s", err)}
+	}
+	if template, err = transform.PrettyPrintArmTemplate(template); err != nil {
+		return nil, err
+	}
+	if parameters, err = transform.BuildAzureParametersFile(parameters); err != nil {
+		return nil, err
+	}
+
+	apiModel, err := serializeAPIModel(cs, apiVersion, t)
+	if err != nil {
+		return nil, err
+	}
+	return &GenerateResponse{
+		APIModel:   apiModel,
+		Template:   json.RawMessage(template),
+		Parameters: json.RawMessage(parameters),
+	}, nil
+}
+
+// upgradePlan returns the nodes the upgrade of the api model of the request to the version query parameter would
+// replace. With the resourceGroup query parameter, and Azure credentials, they are those of the deployed cluster not
+// at that version yet.
+func (s *Server) upgradePlan(r *http.Request, t *i18n.Translator) (interface{}, error) {
+	query := r.URL.Query()
+	targetVersion := query.Get("version")
+	if targetVersion == "" {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidRequest, t.T("the version query parameter must be specified")}
+	}
+	force := false
+	if f := query.Get("force"); f != "" {
+		var err error
+		if force, err = strconv.ParseBool(f); err != nil {
+			return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidRequest, t.T("force must be true or false, got %q", f)}
+		}
+	}
+	resourceGroup := query.Get("resourceGroup")
+	if resourceGroup != "" && s.Client == nil {
+		return nil, &Error{http.StatusNotImplemented, ErrorCodeAzureCredentialsRequired, t.T("the server has no Azure credentials to look up the cluster in resource group %s with", resourceGroup)}
+	}
+
+	cs, _, err := loadAPIModel(r, t, true)
+	if err != nil {
+		return nil, err
+	}
+	properties := cs.Properties
+	if !properties.OrchestratorProfile.IsKubernetes() || properties.MasterProfile == nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("only the upgrades of Kubernetes clusters with a masterProfile can be planned")}
+	}
+	response := &UpgradePlanResponse{CurrentVersion: properties.OrchestratorProfile.OrchestratorVersion}
+	if !force {
+		if err = validateTargetVersion(properties, targetVersion, t); err != nil {
+			return nil, err
+		}
+	}
+	properties.OrchestratorProfile.OrchestratorVersion = targetVersion
+
+	if resourceGroup == "" {
+		response.UpgradePlan, err = planOffline(cs, t)
+		return response, err
+	}
+
+	uc := kubernetesupgrade.UpgradeCluster{
+		Translator: t,
+		Logger:     s.logger(),
+		Client:     s.Client,
+		Force:      force,
+	}
+	uc.ResourceGroup = resourceGroup
+	uc.DataModel = cs
+	uc.NameSuffix = properties.GetClusterID()
+	uc.AgentPoolsToUpgrade = map[string]bool{kubernetesupgrade.MasterPoolName: true}
+	for _, pool := range properties.AgentPoolProfiles {
+		uc.AgentPoolsToUpgrade[pool.Name] = true
+	}
+	// without a kubeconfig, the versions of the nodes are only read from the tags of their VMs
+	var kubeConfig string
+	if cs.Location != "" {
+		if kubeConfig, err = engine.GenerateKubeConfig(properties, cs.Location); err != nil {
+			s.logger().Warnf("generating the kubeconfig of the cluster: %s", err)
+		}
+	}
+	if response.UpgradePlan, err = uc.PlanUpgrade(kubeConfig); err != nil {
+		return nil, &Error{http.StatusBadGateway, ErrorCodeAzureRequestFailed, t.T("error listing the nodes of the cluster in resource group %s: %s", resourceGroup, err)}
+	}
+	response.Deployed = true
+	return response, nil
+}
+
+// validateTargetVersion returns an error if the cluster cannot be upgraded to targetVersion, like upgrade
+func validateTargetVersion(properties *api.Properties, targetVersion string, t *i18n.Translator) error {
+	orchestratorInfo, err := api.GetOrchestratorVersionProfile(properties.OrchestratorProfile, properties.HasWindows())
+	if err != nil {
+		return &Error{http.StatusBadRequest, ErrorCodeUnsupportedVersion, t.T("error getting the upgrades of Kubernetes version %s: %s", properties.OrchestratorProfile.OrchestratorVersion, err)}
+	}
+	for _, up := range orchestratorInfo.Upgrades {
+		if up.OrchestratorVersion == targetVersion {
+			return nil
+		}
+	}
+	return &Error{http.StatusBadRequest, ErrorCodeUnsupportedUpgrade, t.T("upgrading from Kubernetes version %s to version %s is not supported", properties.OrchestratorProfile.OrchestratorVersion, targetVersion)}
+}
+
+// planOffline plans the upgrade of all the nodes of the api model, sorted like the plans of deployed clusters.
+// The api model is defaulted as on upgrades first, the names of the nodes depend on the availability profiles
+func planOffline(cs *api.ContainerService, t *i18n.Translator) (*kubernetesupgrade.UpgradePlan, error) {
+	if _, err := cs.SetPropertiesDefaults(true, false); err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("error setting the defaults of the api model: %s", err)}
+	}
+	nodes, err := nodeexec.FromContainerService(cs)
+	if err != nil {
+		return nil, err
+	}
+	plan := &kubernetesupgrade.UpgradePlan{
+		TargetVersion: cs.Properties.OrchestratorProfile.OrchestratorVersion,
+		Masters: kubernetesupgrade.PoolUpgradePlan{
+			Name:     kubernetesupgrade.MasterPoolName,
+			ScaleSet: cs.Properties.MasterProfile.IsVirtualMachineScaleSets(),
+		},
+	}
+	pools := map[string]int{}
+	for _, pool := range cs.Properties.AgentPoolProfiles {
+		pools[pool.Name] = len(plan.AgentPools)
+		plan.AgentPools = append(plan.AgentPools, kubernetesupgrade.PoolUpgradePlan{
+			Name:     pool.Name,
+			ScaleSet: pool.IsVirtualMachineScaleSets(),
+		})
+	}
+	for _, node := range nodes {
+		if node.Role == nodeexec.RoleMaster {
+			plan.Masters.ToUpgrade = append(plan.Masters.ToUpgrade, node.Name)
+		} else {
+			pool := &plan.AgentPools[pools[node.Pool]]
+			pool.ToUpgrade = append(pool.ToUpgrade, node.Name)
+		}
+	}
+	sort.Slice(plan.AgentPools, func(i, j int) bool {
+		return plan.AgentPools[i].Name < plan.AgentPools[j].Name
+	})
+	return plan, nil
+}
+
+// loadAPIModel loads the api model in the body of r. Upgrades load it as an update, the api models of deployed
+// clusters are not validated as strictly as new ones
+func loadAPIModel(r *http.Request, t *i18n.Translator, isUpdate bool) (*api.ContainerService, string, error) {
+	body, err := readBody(r, t)
+	if err != nil {
+		return nil, "", err
+	}
+	apiloader := &api.Apiloader{Translator: t}
+	cs, apiVersion, err := apiloader.DeserializeContainerService(body, true, isUpdate, nil)
+	if err != nil {
+		return nil, "", &Error{http.StatusBadRequest, ErrorCodeInvalidAPIModel, t.T("error parsing the api model: %s", err)}
+	}
+	return cs, apiVersion, nil
+}
+
+func serializeAPIModel(cs *api.ContainerService, apiVersion string, t *i18n.Translator) (json.RawMessage, error) {
+	apiloader := &api.Apiloader{Translator: t}
+	b, err := apiloader.SerializeContainerService(cs, apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(b), nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/helpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/leonelquinteros/gotext"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxRequestBytes is the largest api model the server accepts
+const MaxRequestBytes = 10 << 20
+
+// Error codes of the responses of failed requests
+const (
+	ErrorCodeInvalidRequest           = "InvalidRequest"
+	ErrorCodeInvalidAPIModel          = "InvalidAPIModel"
+	ErrorCodeUnsupportedVersion       = "UnsupportedVersion"
+	ErrorCodeUnsupportedUpgrade       = "UnsupportedUpgrade"
+	ErrorCodeAzureCredentialsRequired = "AzureCredentialsRequired"
+	ErrorCodeAzureRequestFailed       = "AzureRequestFailed"
+	ErrorCodeNotFound                 = "NotFound"
+	ErrorCodeMethodNotAllowed         = "MethodNotAllowed"
+	ErrorCodeInternalError            = "InternalError"
+	ErrorCodeServerBusy               = "ServerBusy"
+)
+
+// Error is the error of a failed request. Its message is translated in the language of the request
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorResponse is the body of the responses of failed requests
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// Server serves over HTTP the operations of aks-engine on api models: validating them, generating
+// their templates, listing the supported versions and planning upgrades. They all work offline, but for the upgrade
+// plans of deployed clusters, which need Client.
+type Server struct {
+	// BuildTag is the version of aks-engine stamped on the generated templates
+	BuildTag string
+	// DefaultLanguage translates the errors of the requests without a supported Accept-Language, they are not
+	// translated if it is empty
+	DefaultLanguage string
+	// Client queries ARM for the VMs of deployed clusters, upgrade plans only list the nodes of the api model without it
+	Client armhelpers.AKSEngineClient
+	// MaxConcurrentGenerations is how many generate requests are served at once, the others are rejected as busy.
+	// It is the number of CPUs if zero
+	MaxConcurrentGenerations int
+	Logger                   *logrus.Entry
+
+	mu          sync.Mutex
+	locales     map[string]*gotext.Locale
+	generations chan struct{}
+}
+
+// handler handles a request, returning the body of its response
+type handler func(r *http.Request, t *i18n.Translator) (interface{}, error)
+
+// Handler returns the HTTP handler of the API of s
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", s.route(http.MethodGet, s.healthz))
+	mux.Handle("/v1/versions", s.route(http.MethodGet, s.versions))
+	mux.Handle("/v1/apimodel/validate", s.route(http.MethodPost, s.validate))
+	mux.Handle("/v1/apimodel/generate", s.route(http.MethodPost, s.generate))
+	mux.Handle("/v1/apimodel/upgradeplan", s.route(http.MethodPost, s.upgradePlan))
+	mux.Handle("/", s.route("", func(r *http.Request, t *i18n.Translator) (interface{}, error) {
+		return nil, &Error{http.StatusNotFound, ErrorCodeNotFound, t.T("%s is not found", r.URL.Path)}
+	}))
+	return mux
+}
+
+func (s *Server) route(method string, h handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := s.translator(r)
+		if method != "" && r.Method != method {
+			w.Header().Set("Allow", method)
+			s.writeError(w, r, t, &Error{http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, t.T("method %s is not allowed, use %s", r.Method, method)})
+			return
+		}
+		body, err := s.handle(h, r, t)
+		if err != nil {
+			s.writeError(w, r, t, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, body)
+	})
+}
+
+// handle calls h, turning a panic into an internal error so that the client still gets a response
+func (s *Server) handle(h handler, r *http.Request, t *i18n.Translator) (body interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			s.logger().Errorf("%s %s: panic: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			body, err = nil, &Error{http.StatusInternalServerError, ErrorCodeInternalError, t.T("internal error handling the request")}
+		}
+	}()
+	return h(r, t)
+}
+
+// writeError writes the response of a failed request. Unexpected errors are only logged, the client gets a
+// translated internal error
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, t *i18n.Translator, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		s.logger().Errorf("%s %s: %s", r.Method, r.URL.Path, err)
+		e = &Error{http.StatusInternalServerError, ErrorCodeInternalError, t.T("internal error handling the request")}
+	} else if e.Status >= http.StatusInternalServerError {
+		s.logger().Errorf("%s %s: %s", r.Method, r.URL.Path, e.Message)
+	}
+	s.writeJSON(w, e.Status, ErrorResponse{Error: e})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	b, err := helpers.JSONMarshalIndent(body, "", "  ", false)
+	if err != nil {
+		s.logger().Errorf("marshalling the response: %s", err)
+		status = http.StatusInternalServerError
+		b = []byte(`{"error": {"code": "` + ErrorCodeInternalError + `", "message": "marshalling the response"}}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func (s *Server) logger() *logrus.Entry {
+	if s.Logger == nil {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	return s.Logger
+}
+
+// acquireGeneration returns whether a generate request can be served now, releaseGeneration must be called after it
+func (s *Server) acquireGeneration() bool {
+	s.mu.Lock()
+	if s.generations == nil {
+		n := s.MaxConcurrentGenerations
+		if n <= 0 {
+			n = runtime.NumCPU()
+		}
+		s.generations = make(chan struct{}, n)
+	}
+	generations := s.generations
+	s.mu.Unlock()
+	select {
+	case generations <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) releaseGeneration() {
+	<-s.generations
+}
+
+// readBody returns the body of r, which must not be empty nor larger than MaxRequestBytes
+func readBody(r *http.Request, t *i18n.Translator) ([]byte, error) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, MaxRequestBytes))
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidRequest, t.T("error reading the request: %s", err)}
+	}
+	if len(b) == 0 {
+		return nil, &Error{http.StatusBadRequest, ErrorCodeInvalidRequest, t.T("the request must contain an api model")}
+	}
+	return b, nil
+}
+
+// translator returns the translator of the first language of the Accept-Language header of r with translations
+func (s *Server) translator(r *http.Request) *i18n.Translator {
+	language := s.DefaultLanguage
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		if l := toLanguage(tag); i18n.IsSupportedLanguage(l) {
+			language = l
+			break
+		}
+	}
+	return &i18n.Translator{Locale: s.locale(language)}
+}
+
+// toLanguage turns a language tag of an Accept-Language header, such as de-de;q=0.8, into the language of its
+// translations, de_DE. A tag without a region, such as fr, is taken as the language of its own country, fr_FR
+func toLanguage(tag string) string {
+	tag = strings.TrimSpace(strings.SplitN(tag, ";", 2)[0])
+	parts := strings.SplitN(strings.Replace(tag, "-", "_", -1), "_", 2)
+	language, region := strings.ToLower(parts[0]), parts[0]
+	if len(parts) == 2 {
+		region = parts[1]
+	}
+	return language + "_" + strings.ToUpper(region)
+}
+
+// locale returns the locale of language, loading its translations once
+func (s *Server) locale(language string) *gotext.Locale {
+	if language == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if locale, ok := s.locales[language]; ok {
+		return locale
+	}
+	locale, err := i18n.LoadLanguage(language)
+	if err != nil {
+		// the messages are left untranslated
+		s.logger().Warnf("loading the translations of %s: %s", language, err)
+	}
+	if s.locales == nil {
+		s.locales = map[string]*gotext.Locale{}
+	}
+	s.locales[language] = locale
+	return locale
+}
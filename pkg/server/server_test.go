@@ -0,0 +1,337 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-engine/pkg/api"
+	"github.com/Azure/aks-engine/pkg/api/vlabs"
+	"github.com/Azure/aks-engine/pkg/armhelpers"
+	"github.com/Azure/aks-engine/pkg/i18n"
+	"github.com/Azure/aks-engine/pkg/operations/kubernetesupgrade"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2018-10-01/compute"
+	"github.com/google/go-cmp/cmp"
+)
+
+const simpleAPIModel = "../engine/testdata/simple/kubernetes.json"
+
+func readAPIModel(t *testing.T) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(simpleAPIModel)
+	if err != nil {
+		t.Fatalf("unexpected error reading the api model: %s", err)
+	}
+	return b
+}
+
+// do sends a request to s and decodes its response into response, returning its status
+func do(t *testing.T, s *Server, method, url string, body []byte, response interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(method, url, bytes.NewReader(body)))
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected a JSON response, got %s", contentType)
+	}
+	if response != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("unexpected error decoding the response %s: %s", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// expectError sends a request to s and checks that it fails with status and code
+func expectError(t *testing.T, s *Server, method, url string, body []byte, status int, code string) *Error {
+	t.Helper()
+	var response ErrorResponse
+	if got := do(t, s, method, url, body, &response); got != status {
+		t.Errorf("expected the status %d, got %d", status, got)
+	}
+	if response.Error == nil || response.Error.Code != code {
+		t.Fatalf("expected an error with the code %s, got %+v", code, response.Error)
+	}
+	return response.Error
+}
+
+func TestRoutes(t *testing.T) {
+	s := &Server{}
+	var health map[string]string
+	if status := do(t, s, http.MethodGet, "/healthz", nil, &health); status != http.StatusOK || health["status"] != "ok" {
+		t.Errorf("expected the server to be healthy, got %d %v", status, health)
+	}
+	e := expectError(t, s, http.MethodGet, "/v1/apimodel/validate", nil, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed)
+	if e.Message != "method GET is not allowed, use POST" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+	e = expectError(t, s, http.MethodPost, "/v1/clusters", nil, http.StatusNotFound, ErrorCodeNotFound)
+	if e.Message != "/v1/clusters is not found" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	s := &Server{}
+	// the UnmarshalJSON of the embedded vlabs.OrchestratorProfile would drop the upgrades
+	var versions struct {
+		Orchestrators []struct {
+			OrchestratorVersion string `json:"orchestratorVersion"`
+			Upgrades            []struct {
+				OrchestratorVersion string `json:"orchestratorVersion"`
+			} `json:"upgrades"`
+		} `json:"orchestrators"`
+	}
+	if status := do(t, s, http.MethodGet, "/v1/versions", nil, &versions); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	expected, err := api.GetOrchestratorVersionProfileListVLabs(api.Kubernetes, "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := map[string][]string{}
+	for _, orchestrator := range versions.Orchestrators {
+		got[orchestrator.OrchestratorVersion] = []string{}
+		for _, upgrade := range orchestrator.Upgrades {
+			got[orchestrator.OrchestratorVersion] = append(got[orchestrator.OrchestratorVersion], upgrade.OrchestratorVersion)
+		}
+	}
+	want := map[string][]string{}
+	for _, orchestrator := range expected.Orchestrators {
+		want[orchestrator.OrchestratorVersion] = []string{}
+		for _, upgrade := range orchestrator.Upgrades {
+			want[orchestrator.OrchestratorVersion] = append(want[orchestrator.OrchestratorVersion], upgrade.OrchestratorVersion)
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected versions (-want +got):\n%s", diff)
+	}
+
+	version := expected.Orchestrators[0].OrchestratorVersion
+	if status := do(t, s, http.MethodGet, "/v1/versions?windows=true&version="+version, nil, &versions); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	if len(versions.Orchestrators) != 1 || versions.Orchestrators[0].OrchestratorVersion != version {
+		t.Errorf("expected the upgrades of %s only, got %+v", version, versions.Orchestrators)
+	}
+
+	expectError(t, s, http.MethodGet, "/v1/versions?windows=maybe", nil, http.StatusBadRequest, ErrorCodeInvalidRequest)
+	e := expectError(t, s, http.MethodGet, "/v1/versions?version=0.1.0", nil, http.StatusBadRequest, ErrorCodeUnsupportedVersion)
+	if !strings.HasPrefix(e.Message, "error listing the versions of Kubernetes: ") {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := &Server{}
+	var model struct {
+		api.TypeMeta
+		Properties *vlabs.Properties `json:"properties"`
+	}
+	if status := do(t, s, http.MethodPost, "/v1/apimodel/validate", readAPIModel(t), &model); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	if model.APIVersion != vlabs.APIVersion {
+		t.Errorf("expected the api model in its API version, got %s", model.APIVersion)
+	}
+	if model.Properties.MasterProfile.DNSPrefix != "masterdns1" {
+		t.Errorf("expected the api model of the request, got %+v", model.Properties.MasterProfile)
+	}
+	if model.Properties.MasterProfile.Distro != "" {
+		t.Errorf("expected the api model not to be defaulted, got the distro %s", model.Properties.MasterProfile.Distro)
+	}
+
+	expectError(t, s, http.MethodPost, "/v1/apimodel/validate", nil, http.StatusBadRequest, ErrorCodeInvalidRequest)
+	invalid := bytes.Replace(readAPIModel(t), []byte(`"count": 1,`), []byte(`"count": 2,`), 1)
+	e := expectError(t, s, http.MethodPost, "/v1/apimodel/validate", invalid, http.StatusBadRequest, ErrorCodeInvalidAPIModel)
+	if e.Message != "error parsing the api model: MasterProfile count needs to be 1, 3, or 5" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s := &Server{BuildTag: "v0.0.0"}
+	var response struct {
+		APIModel   vlabs.ContainerService `json:"apiModel"`
+		Template   map[string]interface{} `json:"template"`
+		Parameters map[string]interface{} `json:"parameters"`
+	}
+	if status := do(t, s, http.MethodPost, "/v1/apimodel/generate", readAPIModel(t), &response); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	if response.APIModel.Properties == nil || response.APIModel.Properties.MasterProfile.DNSPrefix != "masterdns1" {
+		t.Errorf("expected the generated api model, got %+v", response.APIModel.Properties)
+	}
+	if _, ok := response.Template["resources"]; !ok {
+		t.Errorf("expected the template to have resources, got %v", response.Template)
+	}
+	if _, ok := response.Parameters["parameters"]; !ok {
+		t.Errorf("expected the parameters file to have parameters, got %v", response.Parameters)
+	}
+
+	withReference := bytes.Replace(readAPIModel(t), []byte(`"caPrivateKey": "caPrivateKey"`), []byte(`"caPrivateKey": "file:///secrets/caPrivateKey"`), 1)
+	e := expectError(t, s, http.MethodPost, "/v1/apimodel/generate", withReference, http.StatusBadRequest, ErrorCodeInvalidAPIModel)
+	if e.Message != "the server cannot read the secrets of the api model: resolving caPrivateKey: no secret provider can read file:///secrets/caPrivateKey" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+}
+
+func TestGenerateBusy(t *testing.T) {
+	s := &Server{BuildTag: "v0.0.0", MaxConcurrentGenerations: 1}
+	if !s.acquireGeneration() {
+		t.Fatal("expected the first generation to be served")
+	}
+	e := expectError(t, s, http.MethodPost, "/v1/apimodel/generate", readAPIModel(t), http.StatusServiceUnavailable, ErrorCodeServerBusy)
+	if e.Message != "the server is busy generating other templates, retry later" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+	s.releaseGeneration()
+	if status := do(t, s, http.MethodPost, "/v1/apimodel/generate", readAPIModel(t), nil); status != http.StatusOK {
+		t.Errorf("expected the status 200 once the generation is released, got %d", status)
+	}
+}
+
+func TestGenerateOrchestrators(t *testing.T) {
+	s := &Server{BuildTag: "v0.0.0"}
+	for _, apiModel := range []string{
+		"../engine/testdata/largeclusters/dcos.json",
+		"../engine/testdata/largeclusters/swarm.json",
+		"../engine/testdata/largeclusters/swarmmode.json",
+	} {
+		b, err := ioutil.ReadFile(apiModel)
+		if err != nil {
+			t.Fatalf("unexpected error reading the api model: %s", err)
+		}
+		var response struct {
+			Template map[string]interface{} `json:"template"`
+		}
+		if status := do(t, s, http.MethodPost, "/v1/apimodel/generate", b, &response); status != http.StatusOK {
+			t.Errorf("expected the status 200 generating %s, got %d", apiModel, status)
+			continue
+		}
+		if _, ok := response.Template["resources"]; !ok {
+			t.Errorf("expected the template of %s to have resources, got %v", apiModel, response.Template)
+		}
+	}
+}
+
+func TestRoutePanic(t *testing.T) {
+	s := &Server{}
+	w := httptest.NewRecorder()
+	s.route(http.MethodGet, func(r *http.Request, t *i18n.Translator) (interface{}, error) {
+		panic("handler bug")
+	}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected the status 500, got %d", w.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unexpected error decoding the response %s: %s", w.Body.String(), err)
+	}
+	if response.Error == nil || response.Error.Code != ErrorCodeInternalError {
+		t.Errorf("expected an error with the code %s, got %+v", ErrorCodeInternalError, response.Error)
+	}
+}
+
+func TestUpgradePlan(t *testing.T) {
+	apiloader := &api.Apiloader{Translator: &i18n.Translator{}}
+	cs, _, err := apiloader.LoadContainerServiceFromFile(simpleAPIModel, true, true, nil)
+	if err != nil {
+		t.Fatalf("unexpected error loading the api model: %s", err)
+	}
+	currentVersion := cs.Properties.OrchestratorProfile.OrchestratorVersion
+	orchestrator, err := api.GetOrchestratorVersionProfile(cs.Properties.OrchestratorProfile, false)
+	if err != nil || len(orchestrator.Upgrades) == 0 {
+		t.Fatalf("expected upgrades of %s, got %v", currentVersion, err)
+	}
+	targetVersion := orchestrator.Upgrades[0].OrchestratorVersion
+	id := cs.Properties.GetClusterID()
+
+	s := &Server{}
+	var plan UpgradePlanResponse
+	if status := do(t, s, http.MethodPost, "/v1/apimodel/upgradeplan?version="+targetVersion, readAPIModel(t), &plan); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	expected := UpgradePlanResponse{
+		CurrentVersion: currentVersion,
+		UpgradePlan: &kubernetesupgrade.UpgradePlan{
+			TargetVersion: targetVersion,
+			Masters: kubernetesupgrade.PoolUpgradePlan{
+				Name:      kubernetesupgrade.MasterPoolName,
+				ToUpgrade: []string{"k8s-master-" + id + "-0"},
+			},
+			AgentPools: []kubernetesupgrade.PoolUpgradePlan{
+				{Name: "agentpool1", ToUpgrade: []string{"k8s-agentpool1-" + id + "-0", "k8s-agentpool1-" + id + "-1", "k8s-agentpool1-" + id + "-2"}},
+				{Name: "agentpool2", ToUpgrade: []string{"k8s-agentpool2-" + id + "-0", "k8s-agentpool2-" + id + "-1", "k8s-agentpool2-" + id + "-2"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Errorf("unexpected offline plan (-want +got):\n%s", diff)
+	}
+
+	expectError(t, s, http.MethodPost, "/v1/apimodel/upgradeplan", readAPIModel(t), http.StatusBadRequest, ErrorCodeInvalidRequest)
+	e := expectError(t, s, http.MethodPost, "/v1/apimodel/upgradeplan?version=1.0.0", readAPIModel(t), http.StatusBadRequest, ErrorCodeUnsupportedUpgrade)
+	if e.Message != "upgrading from Kubernetes version "+currentVersion+" to version 1.0.0 is not supported" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+	expectError(t, s, http.MethodPost, "/v1/apimodel/upgradeplan?version="+targetVersion+"&resourceGroup=rg", readAPIModel(t), http.StatusNotImplemented, ErrorCodeAzureCredentialsRequired)
+
+	// with credentials, the plan lists the VMs of the deployed cluster
+	mockClient := &armhelpers.MockAKSEngineClient{}
+	mockClient.FakeListVirtualMachineResult = func() []compute.VirtualMachine {
+		return []compute.VirtualMachine{
+			mockClient.MakeFakeVirtualMachine("k8s-master-"+id+"-0", "Kubernetes:"+currentVersion),
+			mockClient.MakeFakeVirtualMachine("k8s-agentpool1-"+id+"-0", "Kubernetes:"+targetVersion),
+			mockClient.MakeFakeVirtualMachine("k8s-agentpool1-"+id+"-1", "Kubernetes:"+currentVersion),
+			mockClient.MakeFakeVirtualMachine("k8s-agentpool1-12345678-0", "Kubernetes:"+currentVersion),
+		}
+	}
+	s.Client = mockClient
+	plan = UpgradePlanResponse{}
+	if status := do(t, s, http.MethodPost, "/v1/apimodel/upgradeplan?version="+targetVersion+"&resourceGroup=rg", readAPIModel(t), &plan); status != http.StatusOK {
+		t.Fatalf("expected the status 200, got %d", status)
+	}
+	expected = UpgradePlanResponse{
+		CurrentVersion: currentVersion,
+		Deployed:       true,
+		UpgradePlan: &kubernetesupgrade.UpgradePlan{
+			TargetVersion: targetVersion,
+			Masters: kubernetesupgrade.PoolUpgradePlan{
+				Name:      kubernetesupgrade.MasterPoolName,
+				ToUpgrade: []string{"k8s-master-" + id + "-0"},
+			},
+			AgentPools: []kubernetesupgrade.PoolUpgradePlan{
+				{Name: "agentpool1", ToUpgrade: []string{"k8s-agentpool1-" + id + "-1"}, Upgraded: []string{"k8s-agentpool1-" + id + "-0"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Errorf("unexpected plan of the deployed cluster (-want +got):\n%s", diff)
+	}
+
+	mockClient.FailListVirtualMachines = true
+	expectError(t, s, http.MethodPost, "/v1/apimodel/upgradeplan?version="+targetVersion+"&resourceGroup=rg", readAPIModel(t), http.StatusBadGateway, ErrorCodeAzureRequestFailed)
+}
+
+func TestToLanguage(t *testing.T) {
+	cases := map[string]string{
+		"de-DE":        "de_DE",
+		" de-de;q=0.8": "de_DE",
+		"fr":           "fr_FR",
+		"zh-TW":        "zh_TW",
+		"pt_br":        "pt_BR",
+		"*":            "*_*",
+	}
+	for tag, expected := range cases {
+		if language := toLanguage(tag); language != expected {
+			t.Errorf("expected %q to be the language %s, got %s", tag, expected, language)
+		}
+	}
+}
@@ -117,7 +117,6 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 		It("should validate host OS DNS", func() {
 			kubeConfig, err := GetConfig()
 			Expect(err).NotTo(HaveOccurred())
-			master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 			nodeList, err := node.GetReady()
 			Expect(err).NotTo(HaveOccurred())
 			hostOSDNSValidateScript := "host-os-dns-validate.sh"
@@ -126,22 +125,17 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 				envString += fmt.Sprintf("%s ", node.Metadata.Name)
 			}
 			envString += "'"
-			cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, hostOSDNSValidateScript), master+":/tmp/"+hostOSDNSValidateScript)
-			util.PrintCommand(cmd)
-			out, err := cmd.CombinedOutput()
-			log.Printf("%s\n", out)
+			conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 			Expect(err).NotTo(HaveOccurred())
-			var conn *remote.Connection
-			conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+			defer conn.Close()
+			err = conn.CopyTo(filepath.Join(ScriptsDir, hostOSDNSValidateScript), "/tmp/"+hostOSDNSValidateScript)
 			Expect(err).NotTo(HaveOccurred())
 			for _, node := range nodeList.Nodes {
 				if node.IsLinux() {
 					err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+hostOSDNSValidateScript)
 					Expect(err).NotTo(HaveOccurred())
-					netConfigValidationCommand := fmt.Sprintf("\"%s /tmp/%s\"", envString, hostOSDNSValidateScript)
-					cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, netConfigValidationCommand)
-					util.PrintCommand(cmd)
-					out, err = cmd.CombinedOutput()
+					netConfigValidationCommand := fmt.Sprintf("%s /tmp/%s", envString, hostOSDNSValidateScript)
+					out, err := conn.ExecuteRemote(node.Metadata.Name, netConfigValidationCommand)
 					log.Printf("%s\n", out)
 					Expect(err).NotTo(HaveOccurred())
 				}
@@ -160,19 +154,17 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.MasterProfile.IsUbuntu() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				Expect(err).NotTo(HaveOccurred())
+				defer conn.Close()
 
-				lsbReleaseCmd := fmt.Sprintf("lsb_release -a && uname -r")
-				cmd := exec.Command("ssh", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, lsbReleaseCmd)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
+				lsbReleaseCmd := "lsb_release -a && uname -r"
+				out, err := conn.Execute(lsbReleaseCmd)
 				log.Printf("%s\n", out)
 				Expect(err).NotTo(HaveOccurred())
 
-				kernelVerCmd := fmt.Sprintf("cat /proc/version")
-				cmd = exec.Command("ssh", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, kernelVerCmd)
-				util.PrintCommand(cmd)
-				out, err = cmd.CombinedOutput()
+				kernelVerCmd := "cat /proc/version"
+				out, err = conn.Execute(kernelVerCmd)
 				log.Printf("%s\n", out)
 				Expect(err).NotTo(HaveOccurred())
 			} else {
@@ -184,14 +176,14 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.OrchestratorProfile.KubernetesConfig.RequiresDocker() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				Expect(err).NotTo(HaveOccurred())
+				defer conn.Close()
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
-				dockerVersionCmd := fmt.Sprintf("\"docker version\"")
+				dockerVersionCmd := "docker version"
 				for _, node := range nodeList.Nodes {
-					cmd := exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, dockerVersionCmd)
-					util.PrintCommand(cmd)
-					out, err := cmd.CombinedOutput()
+					out, err := conn.ExecuteRemote(node.Metadata.Name, dockerVersionCmd)
 					log.Printf("%s\n", out)
 					Expect(err).NotTo(HaveOccurred())
 				}
@@ -205,15 +197,15 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				Expect(err).NotTo(HaveOccurred())
+				defer conn.Close()
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
-				rootPasswdCmd := fmt.Sprintf("\"sudo grep '^root:[!*]:' /etc/shadow\"")
+				rootPasswdCmd := "sudo grep '^root:[!*]:' /etc/shadow"
 				for _, node := range nodeList.Nodes {
 					if node.IsUbuntu() {
-						cmd := exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, rootPasswdCmd)
-						util.PrintCommand(cmd)
-						out, err := cmd.CombinedOutput()
+						out, err := conn.ExecuteRemote(node.Metadata.Name, rootPasswdCmd)
 						log.Printf("%s\n", out)
 						Expect(err).To(HaveOccurred())
 					}
@@ -227,26 +219,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.IsVHDDistroForAllNodes() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				netConfigValidateScript := "net-config-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, netConfigValidateScript), master+":/tmp/"+netConfigValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, netConfigValidateScript), "/tmp/"+netConfigValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					if node.IsUbuntu() {
 						err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+netConfigValidateScript)
 						Expect(err).NotTo(HaveOccurred())
-						netConfigValidationCommand := fmt.Sprintf("\"/tmp/%s\"", netConfigValidateScript)
-						cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, netConfigValidationCommand)
-						util.PrintCommand(cmd)
-						_, err = cmd.CombinedOutput()
+						netConfigValidationCommand := fmt.Sprintf("/tmp/%s", netConfigValidateScript)
+						_, err = conn.ExecuteRemote(node.Metadata.Name, netConfigValidationCommand)
 						Expect(err).NotTo(HaveOccurred())
 					}
 				}
@@ -259,25 +245,19 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.IsVHDDistroForAllNodes() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				CISFilesValidateScript := "CIS-files-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, CISFilesValidateScript), master+":/tmp/"+CISFilesValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, CISFilesValidateScript), "/tmp/"+CISFilesValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+CISFilesValidateScript)
 					Expect(err).NotTo(HaveOccurred())
-					CISValidationCommand := fmt.Sprintf("\"/tmp/%s\"", CISFilesValidateScript)
-					cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, CISValidationCommand)
-					util.PrintCommand(cmd)
-					_, err = cmd.CombinedOutput()
+					CISValidationCommand := fmt.Sprintf("/tmp/%s", CISFilesValidateScript)
+					_, err = conn.ExecuteRemote(node.Metadata.Name, CISValidationCommand)
 					Expect(err).NotTo(HaveOccurred())
 				}
 			} else {
@@ -289,26 +269,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.IsVHDDistroForAllNodes() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				modprobeConfigValidateScript := "modprobe-config-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, modprobeConfigValidateScript), master+":/tmp/"+modprobeConfigValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, modprobeConfigValidateScript), "/tmp/"+modprobeConfigValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					if node.IsUbuntu() {
 						err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+modprobeConfigValidateScript)
 						Expect(err).NotTo(HaveOccurred())
-						netConfigValidationCommand := fmt.Sprintf("\"/tmp/%s\"", modprobeConfigValidateScript)
-						cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, netConfigValidationCommand)
-						util.PrintCommand(cmd)
-						_, err = cmd.CombinedOutput()
+						netConfigValidationCommand := fmt.Sprintf("/tmp/%s", modprobeConfigValidateScript)
+						_, err = conn.ExecuteRemote(node.Metadata.Name, netConfigValidationCommand)
 						Expect(err).NotTo(HaveOccurred())
 					}
 				}
@@ -320,26 +294,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 		It("should validate installed software packages", func() {
 			kubeConfig, err := GetConfig()
 			Expect(err).NotTo(HaveOccurred())
-			master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 			nodeList, err := node.GetReady()
 			Expect(err).NotTo(HaveOccurred())
 			installedPackagesValidateScript := "ubuntu-installed-packages-validate.sh"
-			cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, installedPackagesValidateScript), master+":/tmp/"+installedPackagesValidateScript)
-			util.PrintCommand(cmd)
-			out, err := cmd.CombinedOutput()
-			log.Printf("%s\n", out)
+			conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 			Expect(err).NotTo(HaveOccurred())
-			var conn *remote.Connection
-			conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+			defer conn.Close()
+			err = conn.CopyTo(filepath.Join(ScriptsDir, installedPackagesValidateScript), "/tmp/"+installedPackagesValidateScript)
 			Expect(err).NotTo(HaveOccurred())
 			for _, node := range nodeList.Nodes {
 				if node.IsUbuntu() {
 					err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+installedPackagesValidateScript)
 					Expect(err).NotTo(HaveOccurred())
-					netConfigValidationCommand := fmt.Sprintf("\"/tmp/%s\"", installedPackagesValidateScript)
-					cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, netConfigValidationCommand)
-					util.PrintCommand(cmd)
-					_, err = cmd.CombinedOutput()
+					netConfigValidationCommand := fmt.Sprintf("/tmp/%s", installedPackagesValidateScript)
+					_, err = conn.ExecuteRemote(node.Metadata.Name, netConfigValidationCommand)
 					Expect(err).NotTo(HaveOccurred())
 				}
 			}
@@ -349,26 +317,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.IsVHDDistroForAllNodes() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				sshdConfigValidateScript := "sshd-config-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, sshdConfigValidateScript), master+":/tmp/"+sshdConfigValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, sshdConfigValidateScript), "/tmp/"+sshdConfigValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					if node.IsUbuntu() {
 						err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+sshdConfigValidateScript)
 						Expect(err).NotTo(HaveOccurred())
-						sshdConfigValidationCommand := fmt.Sprintf("\"/tmp/%s\"", sshdConfigValidateScript)
-						cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, sshdConfigValidationCommand)
-						util.PrintCommand(cmd)
-						_, err = cmd.CombinedOutput()
+						sshdConfigValidationCommand := fmt.Sprintf("/tmp/%s", sshdConfigValidateScript)
+						_, err = conn.ExecuteRemote(node.Metadata.Name, sshdConfigValidationCommand)
 						Expect(err).NotTo(HaveOccurred())
 					}
 				}
@@ -381,26 +343,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.ExpandedDefinition.Properties.IsVHDDistroForAllNodes() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				pwQualityValidateScript := "pwquality-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, pwQualityValidateScript), master+":/tmp/"+pwQualityValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, pwQualityValidateScript), "/tmp/"+pwQualityValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					if node.IsUbuntu() {
 						err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+pwQualityValidateScript)
 						Expect(err).NotTo(HaveOccurred())
-						pwQualityValidationCommand := fmt.Sprintf("\"/tmp/%s\"", pwQualityValidateScript)
-						cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, pwQualityValidationCommand)
-						util.PrintCommand(cmd)
-						_, err = cmd.CombinedOutput()
+						pwQualityValidationCommand := fmt.Sprintf("/tmp/%s", pwQualityValidateScript)
+						_, err = conn.ExecuteRemote(node.Metadata.Name, pwQualityValidationCommand)
 						Expect(err).NotTo(HaveOccurred())
 					}
 				}
@@ -424,17 +380,13 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 				}
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 				auditdValidateScript := "auditd-validate.sh"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, auditdValidateScript), master+":/tmp/"+auditdValidateScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, auditdValidateScript), "/tmp/"+auditdValidateScript)
 				Expect(err).NotTo(HaveOccurred())
 				for _, node := range nodeList.Nodes {
 					var enabled bool
@@ -443,10 +395,8 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 					}
 					err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+auditdValidateScript)
 					Expect(err).NotTo(HaveOccurred())
-					auditdValidationCommand := fmt.Sprintf("\"ENABLED=%t /tmp/%s\"", enabled, auditdValidateScript)
-					cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, auditdValidationCommand)
-					util.PrintCommand(cmd)
-					_, err = cmd.CombinedOutput()
+					auditdValidationCommand := fmt.Sprintf("ENABLED=%t /tmp/%s", enabled, auditdValidateScript)
+					_, err = conn.ExecuteRemote(node.Metadata.Name, auditdValidationCommand)
 					Expect(err).NotTo(HaveOccurred())
 				}
 			} else {
@@ -892,7 +842,9 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 
 					kubeConfig, err := GetConfig()
 					Expect(err).NotTo(HaveOccurred())
-					master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
+					conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+					Expect(err).NotTo(HaveOccurred())
+					defer conn.Close()
 
 					if dashboardPort == 80 {
 						By("Ensuring that we can connect via HTTP to the dashboard on any one node")
@@ -911,10 +863,8 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 							Expect(address).NotTo(BeNil())
 							dashboardURL := fmt.Sprintf("http://%s:%v", address.Address, port)
 							curlCMD := fmt.Sprintf("curl --max-time 60 %s", dashboardURL)
-							cmd := exec.Command("ssh", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, curlCMD)
-							util.PrintCommand(cmd)
 							var out []byte
-							out, err = cmd.CombinedOutput()
+							out, err = conn.Execute(curlCMD)
 							if err == nil {
 								success = true
 								break
@@ -947,19 +897,14 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 			if eng.HasWindowsAgents() {
 				kubeConfig, err := GetConfig()
 				Expect(err).NotTo(HaveOccurred())
-				master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 				nodeList, err := node.GetReady()
 				Expect(err).NotTo(HaveOccurred())
 
 				simulateDockerdCrashScript := "simulate-dockerd-crash.cmd"
-				cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, simulateDockerdCrashScript), master+":/tmp/"+simulateDockerdCrashScript)
-				util.PrintCommand(cmd)
-				out, err := cmd.CombinedOutput()
-				log.Printf("%s\n", out)
+				conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.WindowsProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 				Expect(err).NotTo(HaveOccurred())
-
-				var conn *remote.Connection
-				conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.WindowsProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+				defer conn.Close()
+				err = conn.CopyTo(filepath.Join(ScriptsDir, simulateDockerdCrashScript), "/tmp/"+simulateDockerdCrashScript)
 				Expect(err).NotTo(HaveOccurred())
 
 				for _, node := range nodeList.Nodes {
@@ -967,10 +912,8 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 						By(fmt.Sprintf("simulating docker and subsequent kubelet service crash on node: %s", node.Metadata.Name))
 						err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+simulateDockerdCrashScript)
 						Expect(err).NotTo(HaveOccurred())
-						simulateDockerCrashCommand := fmt.Sprintf("\"/tmp/%s\"", simulateDockerdCrashScript)
-						cmd := exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, simulateDockerCrashCommand)
-						util.PrintCommand(cmd)
-						out, err := cmd.CombinedOutput()
+						simulateDockerCrashCommand := fmt.Sprintf("/tmp/%s", simulateDockerdCrashScript)
+						out, err := conn.ExecuteRemote(node.Metadata.Name, simulateDockerCrashCommand)
 						log.Printf("%s\n", out)
 						Expect(err).NotTo(HaveOccurred())
 					}
@@ -982,10 +925,8 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 				for _, node := range nodeList.Nodes {
 					if node.IsWindows() {
 						By(fmt.Sprintf("restarting kubelet service on node: %s", node.Metadata.Name))
-						restartKubeletCommand := fmt.Sprintf("\"Powershell Start-Service kubelet\"")
-						cmd := exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, restartKubeletCommand)
-						util.PrintCommand(cmd)
-						out, err := cmd.CombinedOutput()
+						restartKubeletCommand := "Powershell Start-Service kubelet"
+						out, err := conn.ExecuteRemote(node.Metadata.Name, restartKubeletCommand)
 						log.Printf("%s\n", out)
 						Expect(err).NotTo(HaveOccurred())
 					}
@@ -1724,9 +1665,11 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 					Expect(len(iisPods)).ToNot(BeZero())
 					kubeConfig, err := GetConfig()
 					Expect(err).NotTo(HaveOccurred())
-					master := fmt.Sprintf("azureuser@%s", kubeConfig.GetServerName())
+					conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+					Expect(err).NotTo(HaveOccurred())
+					defer conn.Close()
 					for _, iisPod := range iisPods {
-						valid := iisPod.ValidateHostPort("(IIS Windows Server)", 10, 10*time.Second, master, masterSSHPrivateKeyFilepath)
+						valid := iisPod.ValidateHostPort("(IIS Windows Server)", 10, 10*time.Second, conn)
 						Expect(valid).To(BeTrue())
 					}
 					err = iisDeploy.Delete(kubectlOutput)
@@ -1810,26 +1753,20 @@ var _ = Describe("Azure Container Cluster using the Kubernetes Orchestrator", fu
 		It("should have healthy time synchronization", func() {
 			kubeConfig, err := GetConfig()
 			Expect(err).NotTo(HaveOccurred())
-			master := fmt.Sprintf("%s@%s", eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, kubeConfig.GetServerName())
 			nodeList, err := node.GetReady()
 			Expect(err).NotTo(HaveOccurred())
 			timeSyncValidateScript := "time-sync-validate.sh"
-			cmd := exec.Command("scp", "-i", masterSSHPrivateKeyFilepath, "-P", masterSSHPort, "-o", "StrictHostKeyChecking=no", filepath.Join(ScriptsDir, timeSyncValidateScript), master+":/tmp/"+timeSyncValidateScript)
-			util.PrintCommand(cmd)
-			out, err := cmd.CombinedOutput()
-			log.Printf("%s\n", out)
+			conn, err := remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
 			Expect(err).NotTo(HaveOccurred())
-			var conn *remote.Connection
-			conn, err = remote.NewConnection(kubeConfig.GetServerName(), masterSSHPort, eng.ExpandedDefinition.Properties.LinuxProfile.AdminUsername, masterSSHPrivateKeyFilepath)
+			defer conn.Close()
+			err = conn.CopyTo(filepath.Join(ScriptsDir, timeSyncValidateScript), "/tmp/"+timeSyncValidateScript)
 			Expect(err).NotTo(HaveOccurred())
 			for _, node := range nodeList.Nodes {
 				if node.IsUbuntu() {
 					err := conn.CopyToRemote(node.Metadata.Name, "/tmp/"+timeSyncValidateScript)
 					Expect(err).NotTo(HaveOccurred())
-					netConfigValidationCommand := fmt.Sprintf("\"/tmp/%s\"", timeSyncValidateScript)
-					cmd = exec.Command("ssh", "-A", "-i", masterSSHPrivateKeyFilepath, "-p", masterSSHPort, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", master, "ssh", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", node.Metadata.Name, netConfigValidationCommand)
-					util.PrintCommand(cmd)
-					out, err = cmd.CombinedOutput()
+					netConfigValidationCommand := fmt.Sprintf("/tmp/%s", timeSyncValidateScript)
+					out, err := conn.ExecuteRemote(node.Metadata.Name, netConfigValidationCommand)
 					log.Printf("%s\n", out)
 					Expect(err).NotTo(HaveOccurred())
 				}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/Azure/aks-engine/pkg/api"
 	"github.com/Azure/aks-engine/test/e2e/kubernetes/util"
+	"github.com/Azure/aks-engine/test/e2e/remote"
 	"github.com/pkg/errors"
 )
 
@@ -836,8 +837,8 @@ func (p *Pod) CheckWindowsOutboundConnection(sleep, duration time.Duration) (boo
 	}
 }
 
-// ValidateHostPort will attempt to run curl against the POD's hostIP and hostPort
-func (p *Pod) ValidateHostPort(check string, attempts int, sleep time.Duration, master, sshKeyPath string) bool {
+// ValidateHostPort will attempt to run curl against the POD's hostIP and hostPort from the master
+func (p *Pod) ValidateHostPort(check string, attempts int, sleep time.Duration, conn *remote.Connection) bool {
 	hostIP := p.Status.HostIP
 	if len(p.Spec.Containers) == 0 || len(p.Spec.Containers[0].Ports) == 0 {
 		log.Printf("Unexpected POD container spec: %v. Should have hostPort.\n", p.Spec)
@@ -849,8 +850,7 @@ func (p *Pod) ValidateHostPort(check string, attempts int, sleep time.Duration,
 	curlCMD := fmt.Sprintf("curl --max-time 60 %s", url)
 
 	for i := 0; i < attempts; i++ {
-		cmd := exec.Command("ssh", "-i", sshKeyPath, "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", master, curlCMD)
-		out, err := util.RunAndLogCommand(cmd, commandTimeout)
+		out, err := conn.Execute(curlCMD)
 		if err == nil {
 			matched, _ := regexp.MatchString(check, string(out))
 			if matched {
@@ -10,154 +10,197 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/Azure/aks-engine/test/e2e/kubernetes/util"
+	"github.com/Azure/aks-engine/pkg/operations/ssh"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/ssh"
+	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
 
-// Connection is
+// Connection is an SSH connection to the master of a cluster, and through it to the other nodes
 type Connection struct {
 	Host           string
 	Port           string
 	User           string
 	PrivateKeyPath string
-	ClientConfig   *ssh.ClientConfig
-	Client         *ssh.Client
+
+	pool   *ssh.Pool
+	master ssh.Endpoint
 }
 
 // NewConnection will build and return a new Connection object
 func NewConnection(host, port, user, keyPath string) (*Connection, error) {
-	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	privateKeyBytes, err := ioutil.ReadFile(keyPath)
 	if err != nil {
-		log.Printf("unable to establish net connection $SSH_AUTH_SOCK has value %s\n", os.Getenv("SSH_AUTH_SOCK"))
 		return nil, err
 	}
-	defer conn.Close()
-	ag := agent.NewClient(conn)
-
-	privateKeyBytes, err := ioutil.ReadFile(keyPath)
-	if err != nil {
+	// the tests that run ssh themselves forward the agent to reach the nodes from the master
+	if err = addToAgent(privateKeyBytes); err != nil {
 		return nil, err
 	}
 
-	privateKey, err := ssh.ParseRawPrivateKey(privateKeyBytes)
+	masterPort, err := strconv.Atoi(port)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "parsing the SSH port %s", port)
 	}
-	addKey := agent.AddedKey{
-		PrivateKey: privateKey,
-	}
-
-	ag.Add(addKey)
-	signers, err := ag.Signers()
+	master := ssh.Endpoint{Host: host, Port: masterPort}
+	// the cluster is new, its host keys are trusted on first use and verified for the life of the connection
+	knownHosts, err := ssh.LoadKnownHosts("", true)
 	if err != nil {
-		log.Println("unable to add key to agent")
 		return nil, err
 	}
-	auths := []ssh.AuthMethod{ssh.PublicKeys(signers...)}
-
-	cfg := &ssh.ClientConfig{
-		User:            user,
-		Auth:            auths,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	cnctStr := fmt.Sprintf("%s:%s", host, port)
-	sshClient, err := ssh.Dial("tcp", cnctStr, cfg)
+	pool, err := ssh.NewPool(ssh.Config{
+		User:        user,
+		PrivateKeys: [][]byte{privateKeyBytes},
+		HostKeys:    knownHosts,
+		JumpHosts:   []ssh.Endpoint{master},
+		DialTimeout: 30 * time.Second,
+	})
 	if err != nil {
 		return nil, err
 	}
+	// connect now, to fail early
+	if _, err = pool.Client(master); err != nil {
+		pool.Close()
+		return nil, err
+	}
 
 	return &Connection{
 		Host:           host,
 		Port:           port,
 		User:           user,
 		PrivateKeyPath: keyPath,
-		ClientConfig:   cfg,
-		Client:         sshClient,
+		pool:           pool,
+		master:         master,
 	}, nil
 }
 
+func addToAgent(privateKeyBytes []byte) error {
+	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	if err != nil {
+		log.Printf("unable to establish net connection $SSH_AUTH_SOCK has value %s\n", os.Getenv("SSH_AUTH_SOCK"))
+		return err
+	}
+	defer conn.Close()
+
+	privateKey, err := gossh.ParseRawPrivateKey(privateKeyBytes)
+	if err != nil {
+		return err
+	}
+	if err = agent.NewClient(conn).Add(agent.AddedKey{PrivateKey: privateKey}); err != nil {
+		log.Println("unable to add key to agent")
+		return err
+	}
+	return nil
+}
+
+// Close closes the connections to the master and the nodes
+func (c *Connection) Close() error {
+	return c.pool.Close()
+}
+
+// node returns a client of the node hostname, reached through the master
+func (c *Connection) node(hostname string) (*ssh.Client, error) {
+	return c.pool.Client(ssh.Endpoint{Host: hostname})
+}
+
 // Execute will execute a given cmd on a remote host
 func (c *Connection) Execute(cmd string) ([]byte, error) {
-	session, err := c.Client.NewSession()
+	client, err := c.pool.Client(c.master)
+	if err != nil {
+		return nil, err
+	}
+	return client.CombinedOutput(context.Background(), cmd)
+}
+
+// ExecuteRemote will execute a given cmd on the node hostname, reached through the master
+func (c *Connection) ExecuteRemote(hostname, cmd string) ([]byte, error) {
+	node, err := c.node(hostname)
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
+	return node.CombinedOutput(context.Background(), cmd)
+}
 
-	return session.CombinedOutput(cmd)
+// CopyTo copies the local file localPath to remotePath on the master, with the same permissions
+func (c *Connection) CopyTo(localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	master, err := c.pool.Client(c.master)
+	if err != nil {
+		return err
+	}
+	return master.WriteFile(context.Background(), remotePath, data, info.Mode().Perm())
 }
 
+// Write writes data, and a newline, to path on the master
 func (c *Connection) Write(data, path string) error {
-	remoteCommand := fmt.Sprintf("echo %s > %s", data, path)
-	connectString := fmt.Sprintf("%s@%s", c.User, c.Host)
-	cmd := exec.Command("ssh", "-i", c.PrivateKeyPath, "-o", "ConnectTimeout=30", "-o", "StrictHostKeyChecking=no", connectString, "-p", c.Port, remoteCommand)
-	util.PrintCommand(cmd)
-	out, err := cmd.CombinedOutput()
+	client, err := c.pool.Client(c.master)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return err
 	}
-	return nil
+	return client.WriteFile(context.Background(), path, []byte(data+"\n"), 0644)
 }
 
+// Read reads path on the master
 func (c *Connection) Read(path string) ([]byte, error) {
-	remoteCommand := fmt.Sprintf("cat %s", path)
-	connectString := fmt.Sprintf("%s@%s", c.User, c.Host)
-	cmd := exec.Command("ssh", "-i", c.PrivateKeyPath, "-o", "ConnectTimeout=30", "-o", "StrictHostKeyChecking=no", connectString, "-p", c.Port, remoteCommand)
-	util.PrintCommand(cmd)
-	out, err := cmd.CombinedOutput()
+	client, err := c.pool.Client(c.master)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return nil, err
 	}
-	return out, nil
+	return client.ReadFile(context.Background(), path)
 }
 
-// CopyFromRemote uses this ssh connection to get remote files via scp
-func (c *Connection) CopyFromRemote(hostname, path string) error {
-	cmd := exec.Command("ssh-add", c.PrivateKeyPath)
-	out, err := cmd.CombinedOutput()
+// CopyFromRemote copies path on the node hostname to dir, named after the node and the file
+func (c *Connection) CopyFromRemote(hostname, path, dir string) error {
+	node, err := c.node(hostname)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return err
 	}
-	remoteCommand := fmt.Sprintf("scp -o StrictHostKeyChecking=no %s:%s /tmp/%s-%s", hostname, path, hostname, filepath.Base(path))
-	connectString := fmt.Sprintf("%s@%s", c.User, c.Host)
-	cmd = exec.Command("ssh", "-A", "-i", c.PrivateKeyPath, "-o", "ConnectTimeout=30", "-o", "StrictHostKeyChecking=no", connectString, "-p", c.Port, remoteCommand)
-	util.PrintCommand(cmd)
-	out, err = cmd.CombinedOutput()
+	data, err := node.ReadFile(context.Background(), path)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return err
 	}
-	return nil
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%s-%s", hostname, filepath.Base(path))), data, 0644)
 }
 
-// CopyToRemote uses this ssh connection to send files via scp
+// CopyToRemote copies path on the master to the same path on the node hostname, with the same permissions
 func (c *Connection) CopyToRemote(hostname, path string) error {
-	cmd := exec.Command("ssh-add", c.PrivateKeyPath)
-	out, err := cmd.CombinedOutput()
+	master, err := c.pool.Client(c.master)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return err
 	}
-	remoteCommand := fmt.Sprintf("scp -o StrictHostKeyChecking=no %s %s:%s", path, hostname, path)
-	connectString := fmt.Sprintf("%s@%s", c.User, c.Host)
-	cmd = exec.Command("ssh", "-A", "-i", c.PrivateKeyPath, "-o", "ConnectTimeout=30", "-o", "StrictHostKeyChecking=no", connectString, "-p", c.Port, remoteCommand)
-	util.PrintCommand(cmd)
-	out, err = cmd.CombinedOutput()
+	ctx := context.Background()
+	mode, err := master.Run(ctx, "stat -c %a "+path)
 	if err != nil {
-		log.Printf("Error output:%s\n", out)
 		return err
 	}
-	return nil
+	perm, err := strconv.ParseUint(strings.TrimSpace(mode), 8, 32)
+	if err != nil {
+		return errors.Wrapf(err, "parsing the mode of %s", path)
+	}
+	data, err := master.ReadFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	node, err := c.node(hostname)
+	if err != nil {
+		return err
+	}
+	return node.WriteFile(ctx, path, data, os.FileMode(perm))
 }
 
 // ExecuteWithRetries will keep retrying a command until it does not return an error or the duration is exceeded
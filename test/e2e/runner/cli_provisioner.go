@@ -4,6 +4,7 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/Azure/go-autorest/autorest/to"
 
+	"github.com/Azure/aks-engine/pkg/operations/nodeexec"
 	"github.com/Azure/aks-engine/test/e2e/azure"
 	"github.com/Azure/aks-engine/test/e2e/config"
 	"github.com/Azure/aks-engine/test/e2e/engine"
@@ -375,32 +377,28 @@ func (cli *CLIProvisioner) FetchProvisioningMetrics(path string, cfg *config.Con
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+	logsPath := filepath.Join(cfg.CurrentWorkingDir, "_logs", hostname)
+	var nodes []nodeexec.Node
 	for _, master := range cli.Masters {
-		for _, fp := range masterFiles {
-			err = conn.CopyFromRemote(master.Name, fp)
-			if err != nil {
-				log.Printf("Error reading file from path (%s):%s", path, err)
-			}
-		}
+		nodes = append(nodes, nodeexec.Node{Name: master.Name, Role: nodeexec.RoleMaster})
 	}
-
 	for _, agent := range cli.Agents {
-		for _, fp := range agentFiles {
-			err = conn.CopyFromRemote(agent.Name, fp)
-			if err != nil {
+		nodes = append(nodes, nodeexec.Node{Name: agent.Name, Role: nodeexec.RoleAgent})
+	}
+	executor := &nodeexec.Executor{}
+	executor.Run(context.Background(), nodes, func(ctx context.Context, node nodeexec.Node) (string, error) {
+		files := agentFiles
+		if node.Role == nodeexec.RoleMaster {
+			files = masterFiles
+		}
+		for _, fp := range files {
+			if err := conn.CopyFromRemote(node.Name, fp, logsPath); err != nil {
 				log.Printf("Error reading file from path (%s):%s", path, err)
 			}
 		}
-	}
-	connectString := fmt.Sprintf("%s@%s:/tmp/k8s-*", conn.User, hostname)
-	logsPath := filepath.Join(cfg.CurrentWorkingDir, "_logs", hostname)
-	cmd = exec.Command("scp", "-i", conn.PrivateKeyPath, "-o", "ConnectTimeout=30", "-o", "StrictHostKeyChecking=no", connectString, logsPath)
-	util.PrintCommand(cmd)
-	out, err = cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("Error output:%s\n", out)
-		return err
-	}
+		return "", nil
+	})
 
 	return nil
 }